@@ -0,0 +1,168 @@
+// Package clickhouse is the authz.Checker of the ClickHouse backend, and the
+// check and lookup queries the clickhouse benchmark times. It reads the
+// user_resource_permissions table of cmd/clickhouse/migrations.
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+
+	"test-tls/authz"
+	"test-tls/ids"
+)
+
+// Relations maps the permission names of package authz onto the relation
+// values stored in user_resource_permissions.
+var Relations = map[string]string{authz.Manage: "manager", authz.View: "viewer"}
+
+// CheckSQL is the user_resource_permissions lookup of Check, with the
+// resource, the user and the relation as arguments. A missing row means the
+// permission is not held.
+const CheckSQL = `
+	SELECT 1
+	FROM user_resource_permissions
+	WHERE resource_id = ? AND user_id = ? AND relation = ?
+	LIMIT 1
+	`
+
+// Check runs CheckSQL through database/sql.
+func Check(ctx context.Context, db *sql.DB, resourceID, userID any, relation string) (bool, error) {
+	var exists int
+	err := db.QueryRowContext(ctx, CheckSQL, resourceID, userID, relation).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CheckParamsSQL is CheckSQL with server-side parameters: the resource, the
+// user and the relation.
+const CheckParamsSQL = `
+	SELECT 1
+	FROM user_resource_permissions
+	WHERE resource_id = {resource:UInt32} AND user_id = {user:UInt32} AND relation = {relation:String}
+	LIMIT 1
+	`
+
+// CheckParams is Check with its arguments bound by the server instead of
+// written into the query text by the driver, so every call sends the same
+// text; ClickHouse has no prepared statements, this is the closest to them.
+func CheckParams(ctx context.Context, db *sql.DB, resourceID, userID any, relation string) (bool, error) {
+	ctx = clickhouse.Context(ctx, clickhouse.WithParameters(clickhouse.Parameters{
+		"resource": fmt.Sprint(resourceID),
+		"user":     fmt.Sprint(userID),
+		"relation": relation,
+	}))
+	var exists int
+	err := db.QueryRowContext(ctx, CheckParamsSQL).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// LookupResources streams the resources a user holds a relation on, from the
+// user_resource_permissions table Check reads.
+func LookupResources(ctx context.Context, db *sql.DB, userID, relation string, handle func(resID uint32)) error {
+	rows, err := db.QueryContext(ctx, `
+	SELECT DISTINCT urp.resource_id
+	FROM user_resource_permissions urp
+	WHERE urp.user_id = ? AND urp.relation = ?
+	`, userID, relation)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var resID uint32
+		if err := rows.Scan(&resID); err != nil {
+			return err
+		}
+		handle(resID)
+	}
+	return rows.Err()
+}
+
+// CountAccessSQL counts the distinct resources of the user (the argument)
+// per relation in one pass over its user_resource_permissions rows.
+const CountAccessSQL = `
+	SELECT uniqExactIf(resource_id, relation = 'viewer'), uniqExactIf(resource_id, relation = 'manager')
+	FROM user_resource_permissions
+	WHERE user_id = ?
+	`
+
+// CountAccess runs CountAccessSQL: how many resources userID can view and
+// manage, as LookupResources would list them.
+func CountAccess(ctx context.Context, db *sql.DB, userID any) (view, manage int, err error) {
+	var v, m uint64
+	err = db.QueryRowContext(ctx, CountAccessSQL, userID).Scan(&v, &m)
+	return int(v), int(m), err
+}
+
+// checker adapts Check, LookupResources and CountAccess to authz.Checker and
+// authz.AccessCounter, converting external ids to the integer columns and
+// back.
+type checker struct {
+	db     *sql.DB
+	format string
+}
+
+// Config is the configuration of a Checker.
+type Config struct {
+	// IDFormat is the ids.ParseFormat format of the ids the Checker returns;
+	// "" is ids.FormatInt.
+	IDFormat string
+}
+
+// NewChecker returns the benchmark queries over db as an authz.Checker,
+// for embedding in services. It fails on an unknown cfg.IDFormat.
+func NewChecker(db *sql.DB, cfg Config) (authz.Checker, error) {
+	format, err := ids.ParseFormat(cfg.IDFormat)
+	if err != nil {
+		return nil, err
+	}
+	return checker{db: db, format: format}, nil
+}
+
+func (c checker) Check(ctx context.Context, resourceID, userID, permission string) (bool, error) {
+	resID, err := ids.Parse(ids.Resource, resourceID)
+	if err != nil {
+		return false, err
+	}
+	user, err := ids.Parse(ids.User, userID)
+	if err != nil {
+		return false, err
+	}
+	relation, ok := Relations[permission]
+	if !ok {
+		return false, authz.UnknownPermission(permission)
+	}
+	return Check(ctx, c.db, resID, user, relation)
+}
+
+func (c checker) LookupResources(ctx context.Context, userID, permission string, handle func(resourceID string)) error {
+	user, err := ids.Decimal(ids.User, userID)
+	if err != nil {
+		return err
+	}
+	relation, ok := Relations[permission]
+	if !ok {
+		return authz.UnknownPermission(permission)
+	}
+	return LookupResources(ctx, c.db, user, relation, func(resID uint32) {
+		handle(ids.FormatIn(c.format, ids.Resource, int(resID)))
+	})
+}
+
+func (c checker) CountAccess(ctx context.Context, userID string) (int, int, error) {
+	user, err := ids.Parse(ids.User, userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return CountAccess(ctx, c.db, user)
+}
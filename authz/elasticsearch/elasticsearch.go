@@ -0,0 +1,357 @@
+// Package elasticsearch is the authz.Checker of the Elasticsearch backend, and
+// the check and lookup queries the elasticsearch benchmark times. It reads the
+// denormalized resource documents cmd/elasticsearch indexes, one per resource
+// with the resource id as _id.
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+
+	esv9 "github.com/elastic/go-elasticsearch/v9"
+
+	"test-tls/authz"
+	"test-tls/ids"
+)
+
+// PermissionFields maps the permission names of package authz onto the
+// denormalized user id arrays of the resource documents.
+var PermissionFields = map[string]string{authz.Manage: "allowed_manage_user_id", authz.View: "allowed_view_user_id"}
+
+// LookupPagings are the ways LookupResources pages through the matches:
+//
+//	search_after  by the last resource_id seen; reads every match (default)
+//	from          from+size, which fails once a user's matches pass
+//	              index.max_result_window (10,000 by default)
+var LookupPagings = []string{"search_after", "from"}
+
+// Check counts the resource document of index (its _id is the resource id)
+// when userID appears in the permission's allowed_*_user_id field.
+func Check(ctx context.Context, es *esv9.Client, index, resourceID, userID, permission string) (bool, error) {
+	query := CheckQuery(resourceID, userID, permission)
+	res, err := es.Count(
+		es.Count.WithContext(ctx),
+		es.Count.WithIndex(index),
+		es.Count.WithBody(bytes.NewReader([]byte(query))),
+	)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return false, fmt.Errorf("count: %s body=%s", res.Status(), readBody(res.Body))
+	}
+
+	var out struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return false, fmt.Errorf("decode count body: %w", err)
+	}
+	return out.Count > 0, nil
+}
+
+// CheckQuery is the _count body Check sends.
+func CheckQuery(resourceID, userID, permission string) string {
+	return `{"query":{"bool":{"filter":[` +
+		`{"ids":{"values":["` + resourceID + `"]}},` +
+		`{"term":{"` + PermissionFields[permission] + `":{"value":` + userID + `}}}]}}}`
+}
+
+// LookupQuery is the _search body of the first page LookupResources fetches;
+// later pages add from, or search_after with the last resource_id seen.
+func LookupQuery(paging, userID, permission string) string {
+	if paging == "from" {
+		return `{"query":{"term":{"` + PermissionFields[permission] + `":{"value":` + userID + `}}}}`
+	}
+	return searchAfterQuery(PermissionFields[permission], userID, "")
+}
+
+// LookupResources streams the ids of the resources of index whose
+// allowed_*_user_id field contains userID, as timed by the lookup_resources_*
+// scenarios, paging as paging says (see LookupPagings).
+func LookupResources(ctx context.Context, es *esv9.Client, index, paging, userID, permission string, handle func(resID string)) error {
+	if paging == "from" {
+		return ScrollFrom(ctx, es, index, []byte(LookupQuery(paging, userID, permission)), handle)
+	}
+	return SearchAfter(ctx, es, index, PermissionFields[permission], userID, handle)
+}
+
+// CountResources counts the resources of index whose allowed_*_user_id field
+// contains userID without fetching them: a size 0 search with
+// track_total_hits, so the total is exact past 10,000. A resource is one
+// document, so this is the COUNT(DISTINCT resource_id) of the SQL backends.
+func CountResources(ctx context.Context, es *esv9.Client, index, userID, permission string) (int, error) {
+	res, err := es.Search(
+		es.Search.WithContext(ctx),
+		es.Search.WithIndex(index),
+		es.Search.WithBody(bytes.NewReader([]byte(CountQuery(userID, permission)))),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return 0, fmt.Errorf("search: %s body=%s", res.Status(), readBody(res.Body))
+	}
+
+	var out struct {
+		Hits struct {
+			Total struct {
+				Value    int    `json:"value"`
+				Relation string `json:"relation"`
+			} `json:"total"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return 0, fmt.Errorf("decode search body: %w", err)
+	}
+	if out.Hits.Total.Relation != "eq" {
+		return 0, fmt.Errorf("hits.total is a lower bound (relation=%q)", out.Hits.Total.Relation)
+	}
+	return out.Hits.Total.Value, nil
+}
+
+// CountQuery is the _search body CountResources sends.
+func CountQuery(userID, permission string) string {
+	return `{"size":0,"track_total_hits":true,"query":{"term":{"` + PermissionFields[permission] + `":{"value":` + userID + `}}}}`
+}
+
+// CountAccess counts the resources of index userID can view and manage in
+// one size 0 search: the documents naming the user in either
+// allowed_*_user_id field, split by a filter aggregation per permission,
+// whose doc_count is exact.
+func CountAccess(ctx context.Context, es *esv9.Client, index, userID string) (view, manage int, err error) {
+	res, err := es.Search(
+		es.Search.WithContext(ctx),
+		es.Search.WithIndex(index),
+		es.Search.WithBody(bytes.NewReader([]byte(CountAccessQuery(userID)))),
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return 0, 0, fmt.Errorf("search: %s body=%s", res.Status(), readBody(res.Body))
+	}
+
+	var out struct {
+		Aggregations struct {
+			View struct {
+				DocCount int `json:"doc_count"`
+			} `json:"view"`
+			Manage struct {
+				DocCount int `json:"doc_count"`
+			} `json:"manage"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return 0, 0, fmt.Errorf("decode search body: %w", err)
+	}
+	return out.Aggregations.View.DocCount, out.Aggregations.Manage.DocCount, nil
+}
+
+// CountAccessQuery is the _search body CountAccess sends.
+func CountAccessQuery(userID string) string {
+	view := `{"term":{"` + PermissionFields[authz.View] + `":{"value":` + userID + `}}}`
+	manage := `{"term":{"` + PermissionFields[authz.Manage] + `":{"value":` + userID + `}}}`
+	return `{"size":0,"query":{"bool":{"should":[` + view + `,` + manage + `]}},` +
+		`"aggs":{"view":{"filter":` + view + `},"manage":{"filter":` + manage + `}}}`
+}
+
+// ScrollFrom pages through the hits of query on index with from+size and
+// hands each id to handle. Search and decode errors are returned so callers
+// can account for them.
+func ScrollFrom(ctx context.Context, es *esv9.Client, index string, query []byte, handle func(resID string)) error {
+	req := es.Search.WithBody(bytes.NewReader(query))
+	// Use a small page size to keep memory bounded, rely on streaming iteration.
+	from := 0
+	for {
+		// Add pagination via from+size (basic) to avoid keeping state server-side
+		res, err := es.Search(
+			req,
+			es.Search.WithContext(ctx),
+			es.Search.WithIndex(index),
+			es.Search.WithSize(1000),
+			es.Search.WithFrom(from),
+		)
+		if err != nil {
+			return err
+		}
+		var hits struct {
+			Hits struct {
+				Hits []struct {
+					ID string `json:"_id"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&hits); err != nil {
+			res.Body.Close()
+			return fmt.Errorf("decode search body: %w", err)
+		}
+		res.Body.Close()
+
+		if len(hits.Hits.Hits) == 0 {
+			break
+		}
+		for _, h := range hits.Hits.Hits {
+			handle(h.ID)
+		}
+		from += 1000
+	}
+	return nil
+}
+
+// SearchAfter pages through the resources of index whose field contains value
+// in resource_id order, each page starting after the last sort value of the
+// one before, and hands each id to handle. Unlike from+size it is not capped
+// by index.max_result_window and keeps no state server-side.
+func SearchAfter(ctx context.Context, es *esv9.Client, index, field, value string, handle func(resID string)) error {
+	after := ""
+	for {
+		query := searchAfterQuery(field, value, after)
+		res, err := es.Search(
+			es.Search.WithContext(ctx),
+			es.Search.WithIndex(index),
+			es.Search.WithBody(bytes.NewReader([]byte(query))),
+		)
+		if err != nil {
+			return err
+		}
+		if res.IsError() {
+			err := fmt.Errorf("search: %s body=%s", res.Status(), readBody(res.Body))
+			res.Body.Close()
+			return err
+		}
+		var hits struct {
+			Hits struct {
+				Hits []struct {
+					ID   string            `json:"_id"`
+					Sort []json.RawMessage `json:"sort"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&hits); err != nil {
+			res.Body.Close()
+			return fmt.Errorf("decode search body: %w", err)
+		}
+		res.Body.Close()
+
+		for _, h := range hits.Hits.Hits {
+			handle(h.ID)
+		}
+		if len(hits.Hits.Hits) < searchAfterPage {
+			return nil
+		}
+		last := hits.Hits.Hits[len(hits.Hits.Hits)-1]
+		if len(last.Sort) == 0 {
+			return fmt.Errorf("search_after: hit %s has no sort value", last.ID)
+		}
+		after = string(last.Sort[0])
+	}
+}
+
+// searchAfterPage is the page size of SearchAfter.
+const searchAfterPage = 1000
+
+// searchAfterQuery is a SearchAfter page of the resources whose field contains
+// value, after the sort value after (none for the first page).
+func searchAfterQuery(field, value, after string) string {
+	query := `{"size":` + strconv.Itoa(searchAfterPage) + `,"_source":false,"track_total_hits":false,` +
+		`"sort":[{"resource_id":"asc"}],"query":{"term":{"` + field + `":{"value":` + value + `}}}`
+	if after != "" {
+		query += `,"search_after":[` + after + `]`
+	}
+	return query + `}`
+}
+
+func readBody(r io.Reader) string {
+	b := new(bytes.Buffer)
+	if _, err := b.ReadFrom(r); err != nil {
+		return fmt.Sprintf("<read error: %v>", err)
+	}
+	return b.String()
+}
+
+// Config is the configuration of a Checker.
+type Config struct {
+	// Index holds the resource documents; required.
+	Index string
+	// Paging is one of LookupPagings; "" is "search_after".
+	Paging string
+	// IDFormat is the ids.ParseFormat format of the ids the Checker returns;
+	// "" is ids.FormatInt.
+	IDFormat string
+}
+
+// checker adapts Check, LookupResources and CountAccess to authz.Checker and
+// authz.AccessCounter, converting external ids to the integer fields and
+// back.
+type checker struct {
+	es  *esv9.Client
+	cfg Config
+}
+
+// NewChecker returns the benchmark queries over es as an authz.Checker,
+// for embedding in services. It fails without cfg.Index or on an unknown
+// cfg.Paging or cfg.IDFormat.
+func NewChecker(es *esv9.Client, cfg Config) (authz.Checker, error) {
+	if cfg.Index == "" {
+		return nil, errors.New("no index given")
+	}
+	if cfg.Paging == "" {
+		cfg.Paging = "search_after"
+	}
+	if !slices.Contains(LookupPagings, cfg.Paging) {
+		return nil, fmt.Errorf("lookup paging %q: want search_after or from", cfg.Paging)
+	}
+	format, err := ids.ParseFormat(cfg.IDFormat)
+	if err != nil {
+		return nil, err
+	}
+	cfg.IDFormat = format
+	return checker{es: es, cfg: cfg}, nil
+}
+
+func (c checker) Check(ctx context.Context, resourceID, userID, permission string) (bool, error) {
+	resID, err := ids.Decimal(ids.Resource, resourceID)
+	if err != nil {
+		return false, err
+	}
+	user, err := ids.Decimal(ids.User, userID)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := PermissionFields[permission]; !ok {
+		return false, authz.UnknownPermission(permission)
+	}
+	return Check(ctx, c.es, c.cfg.Index, resID, user, permission)
+}
+
+func (c checker) LookupResources(ctx context.Context, userID, permission string, handle func(resourceID string)) error {
+	user, err := ids.Decimal(ids.User, userID)
+	if err != nil {
+		return err
+	}
+	if _, ok := PermissionFields[permission]; !ok {
+		return authz.UnknownPermission(permission)
+	}
+	return LookupResources(ctx, c.es, c.cfg.Index, c.cfg.Paging, user, permission, func(resID string) {
+		n, _ := strconv.Atoi(resID)
+		handle(ids.FormatIn(c.cfg.IDFormat, ids.Resource, n))
+	})
+}
+
+func (c checker) CountAccess(ctx context.Context, userID string) (int, int, error) {
+	user, err := ids.Decimal(ids.User, userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return CountAccess(ctx, c.es, c.cfg.Index, user)
+}
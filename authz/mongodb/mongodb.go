@@ -0,0 +1,371 @@
+// Package mongodb is the authz.Checker of the MongoDB backend, and the check
+// and lookup queries the mongodb benchmark times. It reads the collections
+// cmd/mongodb creates: resources with their ACL arrays, organizations, groups
+// and group_members_expanded.
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"test-tls/authz"
+)
+
+// ExpandedCollection holds the effective members of every group, nested
+// groups included: { group_id, user_id, role } with role "manager" or
+// "member" (managers are members too), as group_members_expanded in Scylla.
+const ExpandedCollection = "group_members_expanded"
+
+// GroupModes are the ways LookupFilter resolves the groups a user holds a
+// role in:
+//
+//	direct:      direct membership only, nested groups are not expanded (default)
+//	graphlookup: direct membership, then $graphLookup up the group hierarchy
+//	expanded:    one indexed Distinct on group_members_expanded
+var GroupModes = []string{"direct", "graphlookup", "expanded"}
+
+// ManagerGroups returns the groups userID is an effective manager of: the
+// groups it manages directly and, unless mode is "direct", every group that
+// nests one of them through manager_group_ids.
+func ManagerGroups(ctx context.Context, db *mongo.Database, mode, userID string) (bson.A, error) {
+	direct := bson.D{{Key: "direct_manager_user_ids", Value: userID}}
+	switch mode {
+	case "graphlookup":
+		return ancestorGroups(ctx, db, direct, "manager_group_ids")
+	case "expanded":
+		return expandedGroups(ctx, db, userID, "manager")
+	}
+	return distinctGroups(ctx, db, direct)
+}
+
+// MemberGroups returns the groups userID is an effective member of, given its
+// effective manager groups: the groups it is a direct member of or manages
+// and, unless mode is "direct", every group that nests one of them through
+// member_group_ids.
+func MemberGroups(ctx context.Context, db *mongo.Database, mode, userID string, managers bson.A) (bson.A, error) {
+	direct := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "direct_member_user_ids", Value: userID}},
+		bson.D{{Key: "group_id", Value: bson.D{{Key: "$in", Value: managers}}}},
+	}}}
+	switch mode {
+	case "graphlookup":
+		return ancestorGroups(ctx, db, direct, "member_group_ids")
+	case "expanded":
+		return expandedGroups(ctx, db, userID, "member")
+	}
+	return distinctGroups(ctx, db, direct)
+}
+
+// LookupGroups returns the groups userID is a direct member or manager of
+// or, with effective set, an effective member of as MemberGroups resolves
+// them under mode.
+func LookupGroups(ctx context.Context, db *mongo.Database, mode, userID string, effective bool) (bson.A, error) {
+	if !effective {
+		return distinctGroups(ctx, db, bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "direct_member_user_ids", Value: userID}},
+			bson.D{{Key: "direct_manager_user_ids", Value: userID}},
+		}}})
+	}
+	managers, err := ManagerGroups(ctx, db, mode, userID)
+	if err != nil {
+		return nil, err
+	}
+	return MemberGroups(ctx, db, mode, userID, managers)
+}
+
+func distinctGroups(ctx context.Context, db *mongo.Database, filter bson.D) (bson.A, error) {
+	groups, err := db.Collection("groups").Distinct(ctx, "group_id", filter)
+	return bson.A(groups), err
+}
+
+// ancestorGroups returns the groups matching filter together with every group
+// nesting them, transitively, through edge (member_group_ids or
+// manager_group_ids, which list a parent's child groups). $graphLookup walks
+// the hierarchy upwards from each match and stops at cycles.
+func ancestorGroups(ctx context.Context, db *mongo.Database, filter bson.D, edge string) (bson.A, error) {
+	cur, err := db.Collection("groups").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$graphLookup", Value: bson.D{
+			{Key: "from", Value: "groups"},
+			{Key: "startWith", Value: "$group_id"},
+			{Key: "connectFromField", Value: "group_id"},
+			{Key: "connectToField", Value: edge},
+			{Key: "as", Value: "ancestors"},
+		}}},
+		{{Key: "$project", Value: bson.D{{Key: "ids", Value: bson.D{{Key: "$concatArrays", Value: bson.A{bson.A{"$group_id"}, "$ancestors.group_id"}}}}}}},
+		{{Key: "$unwind", Value: "$ids"}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: nil}, {Key: "ids", Value: bson.D{{Key: "$addToSet", Value: "$ids"}}}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	out := struct {
+		IDs bson.A `bson:"ids"`
+	}{IDs: bson.A{}}
+	if cur.Next(ctx) {
+		if err := cur.Decode(&out); err != nil {
+			return nil, err
+		}
+	}
+	return out.IDs, cur.Err()
+}
+
+func expandedGroups(ctx context.Context, db *mongo.Database, userID, role string) (bson.A, error) {
+	groups, err := db.Collection(ExpandedCollection).Distinct(ctx, "group_id", bson.D{{Key: "user_id", Value: userID}, {Key: "role", Value: role}})
+	return bson.A(groups), err
+}
+
+// DirectFields maps the permission names of package authz onto the direct user ACL arrays of the resources collection.
+var DirectFields = map[string]string{authz.Manage: "manager_user_ids", authz.View: "viewer_user_ids"}
+
+// Check is the direct ACL FindOne timed by the check_* scenarios. A
+// missing document means the permission is not held.
+func Check(ctx context.Context, db *mongo.Database, resourceID, userID, permission string) (bool, error) {
+	err := db.Collection("resources").FindOne(ctx, bson.D{{Key: "resource_id", Value: resourceID}, {Key: DirectFields[permission], Value: userID}}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CheckResolved is Check over every grant path of LookupFilter: one FindOne
+// of the resource with the user's $or. A missing document means the
+// permission is not held.
+func CheckResolved(ctx context.Context, db *mongo.Database, mode, resourceID, userID, permission string) (bool, error) {
+	filter, err := LookupFilter(ctx, db, mode, userID, permission)
+	if err != nil {
+		return false, err
+	}
+	err = db.Collection("resources").FindOne(ctx, append(bson.D{{Key: "resource_id", Value: resourceID}}, filter...)).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// LookupFilter builds the resources filter matching permission for userID in
+// one indexed $or. The orgs and groups the user holds a role in are resolved
+// first; they are a handful of ids per user, so the resource query itself is
+// a single pass. mode picks how groups are resolved (see GroupModes); in
+// "direct" mode nested groups (member_group_ids, manager_group_ids) are not
+// expanded.
+//
+//	manage: direct manager_user, org admin, manager_group where user is manager
+//	view:   manage, direct viewer_user, org member, viewer_group where user is member or manager
+func LookupFilter(ctx context.Context, db *mongo.Database, mode, userID, permission string) (bson.D, error) {
+	managers, err := ManagerGroups(ctx, db, mode, userID)
+	if err != nil {
+		return nil, err
+	}
+	var members bson.A
+	if permission != authz.Manage {
+		if members, err = MemberGroups(ctx, db, mode, userID, managers); err != nil {
+			return nil, err
+		}
+	}
+	orgs, err := db.Collection("organizations").Distinct(ctx, "org_id", OrgRolesFilter(userID, permission))
+	if err != nil {
+		return nil, err
+	}
+	return ResolvedFilter(userID, permission, managers, members, orgs), nil
+}
+
+// OrgRolesFilter is the organizations filter of the orgs whose roles grant
+// permission to userID: admin for manage, admin or member for view.
+func OrgRolesFilter(userID, permission string) bson.D {
+	orgRoles := bson.A{bson.D{{Key: "admin_user_ids", Value: userID}}}
+	if permission != authz.Manage {
+		orgRoles = append(orgRoles, bson.D{{Key: "member_user_ids", Value: userID}})
+	}
+	return bson.D{{Key: "$or", Value: orgRoles}}
+}
+
+// ResolvedFilter is the $or of LookupFilter once the groups userID manages
+// (managers), is a member of (members, view only) and the orgs of
+// OrgRolesFilter are resolved.
+func ResolvedFilter(userID, permission string, managers, members, orgs bson.A) bson.D {
+	paths := bson.A{
+		bson.D{{Key: "manager_user_ids", Value: userID}},
+		bson.D{{Key: "manager_group_ids", Value: bson.D{{Key: "$in", Value: managers}}}},
+	}
+	if permission != authz.Manage {
+		paths = append(paths,
+			bson.D{{Key: "viewer_user_ids", Value: userID}},
+			bson.D{{Key: "viewer_group_ids", Value: bson.D{{Key: "$in", Value: members}}}},
+		)
+	}
+	paths = append(paths, bson.D{{Key: "org_id", Value: bson.D{{Key: "$in", Value: orgs}}}})
+	return bson.D{{Key: "$or", Value: paths}}
+}
+
+// LookupResources streams the resources matching permission for userID, as
+// timed by the lookup_resources_* scenarios.
+func LookupResources(ctx context.Context, db *mongo.Database, mode, userID, permission string, handle func(resID string)) error {
+	filter, err := LookupFilter(ctx, db, mode, userID, permission)
+	if err != nil {
+		return err
+	}
+	cur, err := db.Collection("resources").Find(ctx, filter, options.Find().SetProjection(bson.D{{Key: "resource_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var m bson.M
+		if err := cur.Decode(&m); err != nil {
+			return err
+		}
+		resID, _ := m["resource_id"].(string)
+		handle(resID)
+	}
+	return cur.Err()
+}
+
+// CountResources counts the resources matching permission for userID with
+// the same filter as LookupResources, but server-side through $count, so only
+// the count crosses the wire; the counterpart of the SQL backends' COUNT.
+func CountResources(ctx context.Context, db *mongo.Database, mode, userID, permission string) (int, error) {
+	filter, err := LookupFilter(ctx, db, mode, userID, permission)
+	if err != nil {
+		return 0, err
+	}
+	cur, err := db.Collection("resources").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$count", Value: "resources"}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	var out struct {
+		Resources int `bson:"resources"`
+	}
+	if cur.Next(ctx) {
+		if err := cur.Decode(&out); err != nil {
+			return 0, err
+		}
+	}
+	// no document at all means nothing matched
+	return out.Resources, cur.Err()
+}
+
+// CountAccess counts the resources userID can view and manage with the
+// filters of LookupFilter, resolving the groups once for both: a $match on
+// the view filter, which every manageable resource passes too, then a $facet
+// counting all of them and those passing the manage filter.
+func CountAccess(ctx context.Context, db *mongo.Database, mode, userID string) (view, manage int, err error) {
+	managers, err := ManagerGroups(ctx, db, mode, userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	members, err := MemberGroups(ctx, db, mode, userID, managers)
+	if err != nil {
+		return 0, 0, err
+	}
+	filters := map[string]bson.D{}
+	for _, permission := range []string{authz.View, authz.Manage} {
+		orgs, err := db.Collection("organizations").Distinct(ctx, "org_id", OrgRolesFilter(userID, permission))
+		if err != nil {
+			return 0, 0, err
+		}
+		filters[permission] = ResolvedFilter(userID, permission, managers, members, orgs)
+	}
+	cur, err := db.Collection("resources").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filters[authz.View]}},
+		{{Key: "$facet", Value: bson.D{
+			{Key: "view", Value: bson.A{bson.D{{Key: "$count", Value: "n"}}}},
+			{Key: "manage", Value: bson.A{bson.D{{Key: "$match", Value: filters[authz.Manage]}}, bson.D{{Key: "$count", Value: "n"}}}},
+		}}},
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cur.Close(ctx)
+
+	var out struct {
+		View []struct {
+			N int `bson:"n"`
+		} `bson:"view"`
+		Manage []struct {
+			N int `bson:"n"`
+		} `bson:"manage"`
+	}
+	if cur.Next(ctx) {
+		if err := cur.Decode(&out); err != nil {
+			return 0, 0, err
+		}
+	}
+	// $count emits no document when nothing matched
+	if len(out.View) > 0 {
+		view = out.View[0].N
+	}
+	if len(out.Manage) > 0 {
+		manage = out.Manage[0].N
+	}
+	return view, manage, cur.Err()
+}
+
+// Config is the configuration of a Checker.
+type Config struct {
+	// GroupMode is one of GroupModes; "" is "direct".
+	GroupMode string
+}
+
+// checker adapts CheckResolved, LookupResources, LookupGroups and CountAccess
+// to authz.Checker, authz.GroupLister and authz.AccessCounter.
+type checker struct {
+	db   *mongo.Database
+	mode string
+}
+
+// NewChecker returns the benchmark queries over db as an authz.Checker,
+// for embedding in services. It fails on an unknown cfg.GroupMode.
+func NewChecker(db *mongo.Database, cfg Config) (authz.Checker, error) {
+	if cfg.GroupMode == "" {
+		cfg.GroupMode = "direct"
+	}
+	if !slices.Contains(GroupModes, cfg.GroupMode) {
+		return nil, fmt.Errorf("group mode %q: want one of %s", cfg.GroupMode, strings.Join(GroupModes, ", "))
+	}
+	return checker{db: db, mode: cfg.GroupMode}, nil
+}
+
+func (c checker) Check(ctx context.Context, resourceID, userID, permission string) (bool, error) {
+	if _, ok := DirectFields[permission]; !ok {
+		return false, authz.UnknownPermission(permission)
+	}
+	return CheckResolved(ctx, c.db, c.mode, resourceID, userID, permission)
+}
+
+func (c checker) LookupResources(ctx context.Context, userID, permission string, handle func(resourceID string)) error {
+	if _, ok := DirectFields[permission]; !ok {
+		return authz.UnknownPermission(permission)
+	}
+	return LookupResources(ctx, c.db, c.mode, userID, permission, handle)
+}
+
+func (c checker) LookupGroups(ctx context.Context, userID string, effective bool, handle func(groupID string)) error {
+	groups, err := LookupGroups(ctx, c.db, c.mode, userID, effective)
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		handle(fmt.Sprint(g))
+	}
+	return nil
+}
+
+func (c checker) CountAccess(ctx context.Context, userID string) (int, int, error) {
+	return CountAccess(ctx, c.db, c.mode, userID)
+}
@@ -0,0 +1,472 @@
+// Package postgres is the authz.Checker of the Postgres backend, and the
+// check and lookup queries the postgres benchmark times. It expects the
+// schema of cmd/postgres/migrations.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"test-tls/authz"
+	"test-tls/ids"
+)
+
+// Relations maps the permission names of package authz onto the relation
+// values stored in user_resource_permissions. ViewGranted is View without
+// the denials of resource_bans (see Config.Denials).
+var Relations = map[string]string{authz.Manage: "manager", authz.View: "viewer", authz.ViewGranted: "viewer"}
+
+// Querier is the query side of *sql.DB the check and lookup functions run
+// on, so they can also run through prepared statements (see
+// utils.PreparedDB).
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// GroupResolutions are the ways the check and lookup queries resolve nested
+// groups:
+//
+//	view:    the user_resource_permissions materialized view (default)
+//	cte:     resource_acl joined to a recursive CTE over group_hierarchy, per query
+//	closure: resource_acl joined to the maintained group_closure table
+var GroupResolutions = []string{"view", "cte", "closure"}
+
+// CheckSQL is the existence check of the view resolution, with $1 the
+// resource, $2 the user and $3 the relation.
+const CheckSQL = `SELECT EXISTS(SELECT 1 FROM user_resource_permissions WHERE resource_id = $1 AND user_id = $2 AND relation = $3)`
+
+// Check reports whether userID holds relation on resourceID under the group
+// resolution mode.
+func Check(ctx context.Context, db Querier, mode string, resourceID, userID any, relation string) (bool, error) {
+	var exists bool
+	query, args := CheckStatement(mode, resourceID, userID, relation)
+	err := db.QueryRowContext(ctx, query, args...).Scan(&exists)
+	return exists, err
+}
+
+// CheckStatement returns the query and arguments Check runs.
+func CheckStatement(mode string, resourceID, userID any, relation string) (string, []any) {
+	if mode != "view" {
+		return `SELECT EXISTS(` + resolutionSQL(mode, relation, true) + `)`, []any{userID, resourceID}
+	}
+	return CheckSQL, []any{resourceID, userID, relation}
+}
+
+// Pair is one (resource, user) pair of a batched check.
+type Pair struct {
+	ResourceID, UserID int
+}
+
+// CheckBulk emulates SpiceDB's CheckBulkPermissions: every pair goes out as
+// one row of a VALUES list joined against user_resource_permissions, so N
+// checks cost one round trip. The row indexes that join are the granted
+// pairs.
+func CheckBulk(ctx context.Context, db *sql.DB, pairs []Pair, relation string) ([]bool, error) {
+	var values strings.Builder
+	args := []any{relation}
+	for i, p := range pairs {
+		if i > 0 {
+			values.WriteString(", ")
+		}
+		fmt.Fprintf(&values, "(%d, $%d::int, $%d::int)", i, len(args)+1, len(args)+2)
+		args = append(args, p.ResourceID, p.UserID)
+	}
+	rows, err := db.QueryContext(ctx, `SELECT DISTINCT v.idx
+		FROM (VALUES `+values.String()+`) AS v(idx, resource_id, user_id)
+		JOIN user_resource_permissions p
+		  ON p.resource_id = v.resource_id AND p.user_id = v.user_id AND p.relation = $1`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	allowed := make([]bool, len(pairs))
+	for rows.Next() {
+		var idx int
+		if err := rows.Scan(&idx); err != nil {
+			return nil, err
+		}
+		allowed[idx] = true
+	}
+	return allowed, rows.Err()
+}
+
+// LookupResources calls handle with every resource userID holds relation on
+// under the group resolution mode.
+func LookupResources(ctx context.Context, db Querier, mode, userID, relation string, handle func(resID int)) error {
+	query, args := LookupStatement(mode, userID, relation)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var resID int
+		if err := rows.Scan(&resID); err != nil {
+			return err
+		}
+		handle(resID)
+	}
+	return rows.Err()
+}
+
+// LookupStatement returns the query and arguments LookupResources runs.
+func LookupStatement(mode string, userID any, relation string) (string, []any) {
+	if mode != "view" {
+		return resolutionSQL(mode, relation, false), []any{userID}
+	}
+	return `SELECT resource_id FROM user_resource_permissions WHERE user_id = $1 AND relation = $2`, []any{userID, relation}
+}
+
+// CountAccess returns how many resources userID holds the view and the
+// manage relation on under the group resolution mode, as LookupResources
+// would list them, in one query; with bans set views are counted net of the
+// user's resource_bans.
+func CountAccess(ctx context.Context, db Querier, mode string, bans bool, userID any) (view, manage int, err error) {
+	query, args := CountAccessStatement(mode, bans, userID)
+	err = db.QueryRowContext(ctx, query, args...).Scan(&view, &manage)
+	return view, manage, err
+}
+
+// CountAccessStatement returns the query and arguments CountAccess runs: one
+// pass over the user's rows of user_resource_permissions in the view mode,
+// the two lookup queries as counted subqueries otherwise or with bans.
+func CountAccessStatement(mode string, bans bool, userID any) (string, []any) {
+	if bans {
+		return `SELECT (SELECT count(DISTINCT resource_id) FROM (` + withoutBans(termSQL(mode, authz.View, false)) + `) v),
+		(SELECT count(DISTINCT resource_id) FROM (` + termSQL(mode, authz.Manage, false) + `) m)`, []any{userID}
+	}
+	if mode == "view" {
+		return `SELECT count(DISTINCT resource_id) FILTER (WHERE relation = 'viewer'),
+		count(DISTINCT resource_id) FILTER (WHERE relation = 'manager')
+		FROM user_resource_permissions WHERE user_id = $1`, []any{userID}
+	}
+	return `SELECT (SELECT count(*) FROM (` + resolutionSQL(mode, "viewer", false) + `) v),
+		(SELECT count(*) FROM (` + resolutionSQL(mode, "manager", false) + `) m)`, []any{userID}
+}
+
+// LookupGroups calls handle with every group userID belongs to, directly or,
+// with effective set, through nested groups too, streaming the rows.
+func LookupGroups(ctx context.Context, db Querier, mode string, userID any, effective bool, handle func(groupID int)) error {
+	query, args := GroupsStatement(mode, userID, effective)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupID int
+		if err := rows.Scan(&groupID); err != nil {
+			return err
+		}
+		handle(groupID)
+	}
+	return rows.Err()
+}
+
+// GroupsStatement returns the query and arguments LookupGroups runs. The
+// effective groups come from group_closure in the closure mode and from the
+// recursive CTE of the member groups otherwise, since the view holds
+// resources, not groups.
+func GroupsStatement(mode string, userID any, effective bool) (string, []any) {
+	switch {
+	case !effective:
+		return `SELECT DISTINCT group_id FROM group_memberships WHERE user_id = $1`, []any{userID}
+	case mode == "closure":
+		return `SELECT DISTINCT c.ancestor_group_id FROM group_memberships gm
+		JOIN group_closure c ON c.descendant_group_id = gm.group_id AND c.member_role = gm.role AND c.relation = 'member'
+		WHERE gm.user_id = $1`, []any{userID}
+	}
+	return `WITH RECURSIVE ` + managerGroupsCTE + `,
+		` + memberGroupsCTE + `
+		SELECT group_id FROM mem`, []any{userID}
+}
+
+// groupACL maps a user_resource_permissions relation onto the resource_acl
+// relations it expands (legacy values included, as in the view), the group
+// role that grants it and the org_memberships roles that grant it.
+var groupACL = map[string]struct{ user, group, role, org string }{
+	"manager": {user: "'manager_user', 'manager'", group: "'manager_group', 'manager'", role: "manager", org: "'admin'"},
+	"viewer":  {user: "'viewer_user', 'viewer'", group: "'viewer_group', 'viewer'", role: "member", org: "'admin', 'member'"},
+}
+
+// Recursive CTEs of the groups user $1 is an effective manager (mgr) or
+// member (mem) of, with the rules of the user_resource_permissions view.
+// UNION, not UNION ALL, so a cycle in group_hierarchy ends the recursion.
+const (
+	managerGroupsCTE = `mgr(group_id) AS (
+		SELECT group_id FROM group_memberships WHERE user_id = $1 AND role = 'direct_manager'
+		UNION
+		SELECT gh.parent_group_id FROM group_hierarchy gh
+		JOIN mgr ON gh.child_group_id = mgr.group_id AND gh.relation = 'manager_group'
+	)`
+	memberGroupsCTE = `mem(group_id) AS (
+		SELECT group_id FROM group_memberships WHERE user_id = $1 AND role = 'direct_member'
+		UNION
+		SELECT group_id FROM mgr
+		UNION
+		SELECT gh.parent_group_id FROM group_hierarchy gh
+		JOIN mem ON gh.child_group_id = mem.group_id AND gh.relation = 'member_group'
+	)`
+)
+
+// activeGrant is the filter keeping the resource_acl rows (columns qualified
+// with prefix, e.g. "ra.") whose valid_from/valid_until window covers now(),
+// as cmd/postgres/migrations/0005_grant_windows.sql does in user_resource_permissions.
+func activeGrant(prefix string) string {
+	return " AND (" + prefix + "valid_from IS NULL OR " + prefix + "valid_from <= now())" +
+		" AND (" + prefix + "valid_until IS NULL OR " + prefix + "valid_until > now())"
+}
+
+// orgGrantSQL returns the org path of relation for user $1, as in
+// cmd/postgres/migrations/0006_org_permissions.sql: the resources of every org the user
+// holds a granting role in (org admins manage, org members view); with check
+// set it is narrowed to resource $2.
+func orgGrantSQL(relation string, check bool) string {
+	query := `SELECT r.resource_id FROM org_memberships om
+		JOIN resources r ON r.org_id = om.org_id
+		WHERE om.user_id = $1 AND om.role IN (` + groupACL[relation].org + `)`
+	if check {
+		query += " AND r.resource_id = $2"
+	}
+	return query
+}
+
+// resolutionSQL returns the lookup query of relation for user $1 under
+// the cte or closure mode: direct grants, group grants and the org path;
+// with check set it is narrowed to resource $2.
+func resolutionSQL(mode, relation string, check bool) string {
+	acl := groupACL[relation]
+	userFilter, groupFilter := "", ""
+	if check {
+		userFilter, groupFilter = " AND resource_id = $2", " AND ra.resource_id = $2"
+	}
+	direct := `SELECT resource_id FROM resource_acl
+		WHERE subject_type = 'user' AND subject_id = $1 AND relation IN (` + acl.user + `)` + activeGrant("") + userFilter
+	if mode == "closure" {
+		return direct + `
+		UNION
+		SELECT ra.resource_id FROM group_memberships gm
+		JOIN group_closure c ON c.descendant_group_id = gm.group_id AND c.member_role = gm.role AND c.relation = '` + acl.role + `'
+		JOIN resource_acl ra ON ra.subject_type = 'group' AND ra.subject_id = c.ancestor_group_id AND ra.relation IN (` + acl.group + `)
+		WHERE gm.user_id = $1` + activeGrant("ra.") + groupFilter + `
+		UNION
+		` + orgGrantSQL(relation, check)
+	}
+	ctes, groups := managerGroupsCTE, "mgr"
+	if acl.role == "member" {
+		ctes, groups = managerGroupsCTE+",\n"+memberGroupsCTE, "mem"
+	}
+	return `WITH RECURSIVE ` + ctes + `
+		` + direct + `
+		UNION
+		SELECT ra.resource_id FROM resource_acl ra
+		JOIN ` + groups + ` g ON ra.subject_type = 'group' AND ra.subject_id = g.group_id
+		WHERE ra.relation IN (` + acl.group + `)` + activeGrant("ra.") + groupFilter + `
+		UNION
+		` + orgGrantSQL(relation, check)
+}
+
+// termSQL returns the resources user $1 holds term (see authz.Expand) on
+// under the group resolution mode, as a query with a resource_id column;
+// with check set it is narrowed to resource $2.
+func termSQL(mode, term string, check bool) string {
+	filter := ""
+	if check {
+		filter = " AND resource_id = $2"
+	}
+	switch term {
+	case authz.Manage, authz.View:
+		relation := Relations[term]
+		if mode != "view" {
+			return `SELECT resource_id FROM (` + resolutionSQL(mode, relation, check) + `) r`
+		}
+		return `SELECT resource_id FROM user_resource_permissions WHERE user_id = $1 AND relation = '` + relation + `'` + filter
+	case "manager_user", "viewer_user":
+		return `SELECT resource_id FROM resource_acl
+		WHERE subject_type = 'user' AND subject_id = $1 AND relation IN (` + groupACL[termRelation[term]].user + `)` + activeGrant("") + filter
+	case "org_admin", "org_member":
+		return orgGrantSQL(termRelation[term], check)
+	}
+	return ""
+}
+
+// withoutBans narrows query, the resource_id rows of user $1, to the
+// resources resource_bans does not ban the user from: the anti-join of the
+// denials.
+func withoutBans(query string) string {
+	return `SELECT resource_id FROM (` + query + `) g
+		WHERE NOT EXISTS (SELECT 1 FROM resource_bans b WHERE b.resource_id = g.resource_id AND b.user_id = $1)`
+}
+
+// termRelation maps a term of authz.RuleTerms onto the relation of
+// user_resource_permissions whose grants it narrows.
+var termRelation = map[string]string{
+	"manager_user": "manager", "viewer_user": "viewer",
+	"org_admin": "manager", "org_member": "viewer",
+}
+
+// CheckDerived reports whether userID holds the derived permission expanded
+// to terms (see authz.Expand) on resourceID under the group resolution mode,
+// with the View term net of resource_bans when bans is set.
+func CheckDerived(ctx context.Context, db Querier, mode string, terms []string, bans bool, resourceID, userID any) (bool, error) {
+	var exists bool
+	query, args := DerivedStatement(mode, terms, bans, resourceID, userID, true)
+	err := db.QueryRowContext(ctx, query, args...).Scan(&exists)
+	return exists, err
+}
+
+// LookupDerived calls handle with every resource userID holds the derived
+// permission expanded to terms on under the group resolution mode, streaming
+// the rows; bans as in CheckDerived.
+func LookupDerived(ctx context.Context, db Querier, mode string, terms []string, bans bool, userID any, handle func(resID int)) error {
+	query, args := DerivedStatement(mode, terms, bans, nil, userID, false)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var resID int
+		if err := rows.Scan(&resID); err != nil {
+			return err
+		}
+		handle(resID)
+	}
+	return rows.Err()
+}
+
+// DerivedStatement returns the query and arguments CheckDerived (check set;
+// user, resource) or LookupDerived (user) runs: the union of the queries of
+// the terms, the View one anti-joined to resource_bans with bans set.
+func DerivedStatement(mode string, terms []string, bans bool, resourceID, userID any, check bool) (string, []any) {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = termSQL(mode, term, check)
+		if bans && term == authz.View {
+			parts[i] = withoutBans(parts[i])
+		}
+	}
+	query := strings.Join(parts, "\n\t\tUNION\n\t\t")
+	if check {
+		return `SELECT EXISTS(` + query + `)`, []any{userID, resourceID}
+	}
+	return query, []any{userID}
+}
+
+// Config is the configuration of a Checker.
+type Config struct {
+	// GroupResolution is one of GroupResolutions; "" is "view".
+	GroupResolution string
+	// Rules derive the permissions the Checker answers beyond Manage and
+	// View (see authz.ParseRules).
+	Rules []authz.Rule
+	// Denials enforce resource_bans: a banned user loses View (see
+	// authz.BannedUser), and authz.ViewGranted answers View without them.
+	Denials bool
+	// IDFormat is the ids.ParseFormat format of the ids the Checker returns;
+	// "" is ids.FormatInt.
+	IDFormat string
+}
+
+// checker adapts Check, LookupResources, LookupGroups and CountAccess to
+// authz.Checker, authz.GroupLister and authz.AccessCounter, converting
+// external ids to the integer columns and back.
+type checker struct {
+	db     *sql.DB
+	mode   string
+	rules  []authz.Rule
+	bans   bool
+	format string
+}
+
+// NewChecker returns the benchmark queries over db as an authz.Checker,
+// for embedding in services. It fails on an unknown cfg.GroupResolution or
+// cfg.IDFormat.
+func NewChecker(db *sql.DB, cfg Config) (authz.Checker, error) {
+	mode := cfg.GroupResolution
+	if mode == "" {
+		mode = "view"
+	}
+	if !slices.Contains(GroupResolutions, mode) {
+		return nil, fmt.Errorf("group resolution %q: want one of %s", mode, strings.Join(GroupResolutions, ", "))
+	}
+	format, err := ids.ParseFormat(cfg.IDFormat)
+	if err != nil {
+		return nil, err
+	}
+	return checker{db: db, mode: mode, rules: cfg.Rules, bans: cfg.Denials, format: format}, nil
+}
+
+// expand returns the terms of a derived permission (see authz.Expand), and
+// View's own with denials, so they go through the anti-join of
+// DerivedStatement.
+func (c checker) expand(permission string) ([]string, bool) {
+	if c.bans && permission == authz.View {
+		return []string{authz.View}, true
+	}
+	return authz.Expand(c.rules, permission)
+}
+
+func (c checker) Check(ctx context.Context, resourceID, userID, permission string) (bool, error) {
+	resID, err := ids.Parse(ids.Resource, resourceID)
+	if err != nil {
+		return false, err
+	}
+	user, err := ids.Parse(ids.User, userID)
+	if err != nil {
+		return false, err
+	}
+	if terms, ok := c.expand(permission); ok {
+		return CheckDerived(ctx, c.db, c.mode, terms, c.bans, resID, user)
+	}
+	relation, ok := Relations[permission]
+	if !ok {
+		return false, authz.UnknownPermission(permission)
+	}
+	return Check(ctx, c.db, c.mode, resID, user, relation)
+}
+
+func (c checker) LookupResources(ctx context.Context, userID, permission string, handle func(resourceID string)) error {
+	user, err := ids.Decimal(ids.User, userID)
+	if err != nil {
+		return err
+	}
+	if terms, ok := c.expand(permission); ok {
+		return LookupDerived(ctx, c.db, c.mode, terms, c.bans, user, func(resID int) {
+			handle(ids.FormatIn(c.format, ids.Resource, resID))
+		})
+	}
+	relation, ok := Relations[permission]
+	if !ok {
+		return authz.UnknownPermission(permission)
+	}
+	return LookupResources(ctx, c.db, c.mode, user, relation, func(resID int) {
+		handle(ids.FormatIn(c.format, ids.Resource, resID))
+	})
+}
+
+func (c checker) LookupGroups(ctx context.Context, userID string, effective bool, handle func(groupID string)) error {
+	user, err := ids.Parse(ids.User, userID)
+	if err != nil {
+		return err
+	}
+	return LookupGroups(ctx, c.db, c.mode, user, effective, func(groupID int) {
+		handle(ids.FormatIn(c.format, ids.Group, groupID))
+	})
+}
+
+func (c checker) CountAccess(ctx context.Context, userID string) (int, int, error) {
+	user, err := ids.Parse(ids.User, userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return CountAccess(ctx, c.db, c.mode, c.bans, user)
+}
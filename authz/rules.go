@@ -0,0 +1,95 @@
+package authz
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Derived permissions of StandardRules, beyond Manage and View.
+const (
+	Edit   = "edit"
+	Delete = "delete"
+	Share  = "share"
+)
+
+// Rule derives a permission from the model: whoever holds any of its terms
+// holds it. A term is Manage, View, one of RuleTerms or the permission of an
+// earlier rule, so rules inherit from one another the way the permissions of
+// a SpiceDB definition do.
+type Rule struct {
+	Permission string
+	Terms      []string
+}
+
+// RuleTerms are the relations a Rule may grant on besides permissions:
+//
+//	manager_user  a direct manager_user grant on the resource
+//	viewer_user   a direct viewer_user grant on the resource
+//	org_admin     admin of the resource's organization
+//	org_member    member or admin of the resource's organization
+var RuleTerms = []string{"manager_user", "viewer_user", "org_admin", "org_member"}
+
+// StandardRules are the rules of edit, delete and share: editors are the
+// managers and the direct viewers, only direct managers and org admins may
+// delete, and whoever may delete or holds a direct view grant may share.
+const StandardRules = "edit=manage+viewer_user,delete=manager_user+org_admin,share=delete+viewer_user"
+
+var ruleNameRe = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ParseRules parses comma-separated rules of the form
+// permission=term+term..., e.g. StandardRules.
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+	known := append([]string{Manage, View}, RuleTerms...)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, body, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !ruleNameRe.MatchString(name) {
+			return nil, fmt.Errorf("rule %q: want permission=term+term...", part)
+		}
+		if slices.Contains(known, name) {
+			return nil, fmt.Errorf("rule %q: %s is already defined", part, name)
+		}
+		rule := Rule{Permission: name}
+		for _, term := range strings.Split(body, "+") {
+			term = strings.TrimSpace(term)
+			if !slices.Contains(known, term) {
+				return nil, fmt.Errorf("rule %q: unknown term %q (want %s or an earlier rule)", part, term, strings.Join(append([]string{Manage, View}, RuleTerms...), ", "))
+			}
+			if !slices.Contains(rule.Terms, term) {
+				rule.Terms = append(rule.Terms, term)
+			}
+		}
+		rules = append(rules, rule)
+		known = append(known, name)
+	}
+	return rules, nil
+}
+
+// Expand returns the terms permission is derived from down to Manage, View
+// and RuleTerms, each once, or false when no rule defines permission.
+func Expand(rules []Rule, permission string) ([]string, bool) {
+	i := slices.IndexFunc(rules, func(r Rule) bool { return r.Permission == permission })
+	if i < 0 {
+		return nil, false
+	}
+	var terms []string
+	for _, term := range rules[i].Terms {
+		sub, derived := Expand(rules[:i], term)
+		if !derived {
+			sub = []string{term}
+		}
+		for _, t := range sub {
+			if !slices.Contains(terms, t) {
+				terms = append(terms, t)
+			}
+		}
+	}
+	return terms, true
+}
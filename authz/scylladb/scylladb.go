@@ -0,0 +1,227 @@
+// Package scylladb is the authz.Checker of the ScyllaDB backend, and the check
+// and lookup queries the scylladb benchmark times. The benchmark queries read
+// the direct grants of the resource_acl_by_* tables; the Checker reads the
+// user_resource_perms_by_* tables, where the loader resolves every grant path
+// (see cmd/scylladb/migrations).
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"test-tls/authz"
+	"test-tls/ids"
+	"test-tls/utils"
+)
+
+// Relations maps the permission names of package authz onto the direct user
+// relations of the resource_acl_by_* tables.
+var Relations = map[string]string{authz.Manage: "manager_user", authz.View: "viewer_user"}
+
+// Queries of Check, with the resource, the relation and the user as
+// arguments, and of LookupResources, with the user and the relation.
+const (
+	CheckCQL = `SELECT valid_from, valid_until FROM resource_acl_by_resource
+		WHERE resource_id = ? AND relation = ? AND subject_type = 'user' AND subject_id = ?`
+	LookupCQL = `SELECT resource_id FROM resource_acl_by_subject
+		WHERE subject_type = 'user' AND subject_id = ? AND relation = ?`
+)
+
+// Check is the resource_acl_by_resource check timed by the check_* scenarios:
+// the grant must exist and its valid_from/valid_until window cover the
+// current time.
+func Check(ctx context.Context, session *gocql.Session, resourceID, userID any, relation string) (bool, error) {
+	var validFrom, validUntil time.Time
+	err := session.Query(CheckCQL, resourceID, relation, userID).WithContext(ctx).Scan(&validFrom, &validUntil)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var window utils.GrantWindow
+	if !validFrom.IsZero() {
+		window.From = &validFrom
+	}
+	if !validUntil.IsZero() {
+		window.Until = &validUntil
+	}
+	return window.ActiveAt(time.Now()), nil
+}
+
+// LookupResources streams the resources a user holds a relation on via
+// resource_acl_by_subject, as timed by the lookup_resources_* scenarios. The
+// table only holds the grants active when they were loaded.
+func LookupResources(ctx context.Context, session *gocql.Session, userID, relation string, handle func(resID int)) error {
+	iter := session.Query(LookupCQL, userID, relation).WithContext(ctx).Iter()
+
+	var resID int
+	for iter.Scan(&resID) {
+		handle(resID)
+	}
+	return iter.Close()
+}
+
+// CheckEffective reads the user_resource_perms_by_resource row of the pair:
+// every grant path of the SpiceDB schema (nested groups, org roles, manage
+// implying view), resolved by the loader from the grants active at load time.
+func CheckEffective(ctx context.Context, session *gocql.Session, resourceID, userID any, permission string) (bool, error) {
+	var canManage, canView bool
+	err := session.Query(`SELECT can_manage, can_view FROM user_resource_perms_by_resource
+		WHERE resource_id = ? AND user_id = ?`,
+		resourceID, userID).WithContext(ctx).Scan(&canManage, &canView)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if permission == authz.Manage {
+		return canManage, nil
+	}
+	return canView, nil
+}
+
+// LookupEffective streams the resources of the user's
+// user_resource_perms_by_user partition that grant permission, with the
+// paths of CheckEffective.
+func LookupEffective(ctx context.Context, session *gocql.Session, userID any, permission string, handle func(resID int)) error {
+	iter := session.Query(`SELECT resource_id, can_manage, can_view FROM user_resource_perms_by_user
+		WHERE user_id = ?`, userID).WithContext(ctx).Iter()
+
+	var resID int
+	var canManage, canView bool
+	for iter.Scan(&resID, &canManage, &canView) {
+		if canManage || (permission == authz.View && canView) {
+			handle(resID)
+		}
+	}
+	return iter.Close()
+}
+
+// CountAccess counts the resources userID can view and manage in one read
+// of its user_resource_perms_by_user partition, with the rules of
+// LookupEffective.
+func CountAccess(ctx context.Context, session *gocql.Session, userID any) (view, manage int, err error) {
+	iter := session.Query(`SELECT can_manage, can_view FROM user_resource_perms_by_user
+		WHERE user_id = ?`, userID).WithContext(ctx).Iter()
+
+	var canManage, canView bool
+	for iter.Scan(&canManage, &canView) {
+		if canManage {
+			manage++
+		}
+		if canManage || canView {
+			view++
+		}
+	}
+	return view, manage, iter.Close()
+}
+
+// LookupGroups streams the groups of the user's group_memberships partition
+// or, with effective set, the groups group_members_expanded holds the user as
+// an effective member of, read through its user_id index.
+func LookupGroups(ctx context.Context, session *gocql.Session, userID any, effective bool, handle func(groupID int)) error {
+	query := `SELECT group_id, role FROM group_memberships WHERE user_id = ?`
+	if effective {
+		query = `SELECT group_id, role FROM group_members_expanded WHERE user_id = ?`
+	}
+	iter := session.Query(query, userID).WithContext(ctx).Iter()
+
+	var groupID int
+	var role string
+	last := -1
+	for iter.Scan(&groupID, &role) {
+		if (effective && role != "member") || groupID == last {
+			continue // a group is listed once per role
+		}
+		last = groupID
+		handle(groupID)
+	}
+	return iter.Close()
+}
+
+// checker adapts CheckEffective, LookupEffective, LookupGroups and
+// CountAccess to authz.Checker, authz.GroupLister and authz.AccessCounter,
+// converting external ids to the integer columns and back.
+type checker struct {
+	session *gocql.Session
+	format  string
+}
+
+// Config is the configuration of a Checker.
+type Config struct {
+	// IDFormat is the ids.ParseFormat format of the ids the Checker returns;
+	// "" is ids.FormatInt.
+	IDFormat string
+}
+
+// NewChecker returns CheckEffective and LookupEffective over session as an
+// authz.Checker, for embedding in services. It fails on an unknown
+// cfg.IDFormat.
+func NewChecker(session *gocql.Session, cfg Config) (authz.Checker, error) {
+	format, err := ids.ParseFormat(cfg.IDFormat)
+	if err != nil {
+		return nil, err
+	}
+	return checker{session: session, format: format}, nil
+}
+
+// checkPermission fails on a permission other than Manage and View, the
+// two columns of the permission tables.
+func checkPermission(permission string) error {
+	if permission != authz.Manage && permission != authz.View {
+		return authz.UnknownPermission(permission)
+	}
+	return nil
+}
+
+func (c checker) Check(ctx context.Context, resourceID, userID, permission string) (bool, error) {
+	resID, err := ids.Parse(ids.Resource, resourceID)
+	if err != nil {
+		return false, err
+	}
+	user, err := ids.Parse(ids.User, userID)
+	if err != nil {
+		return false, err
+	}
+	if err := checkPermission(permission); err != nil {
+		return false, err
+	}
+	return CheckEffective(ctx, c.session, resID, user, permission)
+}
+
+func (c checker) LookupResources(ctx context.Context, userID, permission string, handle func(resourceID string)) error {
+	user, err := ids.Parse(ids.User, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkPermission(permission); err != nil {
+		return err
+	}
+	return LookupEffective(ctx, c.session, user, permission, func(resID int) {
+		handle(ids.FormatIn(c.format, ids.Resource, resID))
+	})
+}
+
+func (c checker) LookupGroups(ctx context.Context, userID string, effective bool, handle func(groupID string)) error {
+	user, err := ids.Parse(ids.User, userID)
+	if err != nil {
+		return err
+	}
+	return LookupGroups(ctx, c.session, user, effective, func(groupID int) {
+		handle(ids.FormatIn(c.format, ids.Group, groupID))
+	})
+}
+
+func (c checker) CountAccess(ctx context.Context, userID string) (int, int, error) {
+	user, err := ids.Parse(ids.User, userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return CountAccess(ctx, c.session, user)
+}
@@ -0,0 +1,263 @@
+// Package spicedb is the authz.Checker of the SpiceDB (Authzed) backends, and
+// the calls the authzed_crdb and authzed_pgdb benchmarks time. Both
+// datastores share it: they differ in how SpiceDB is deployed, not in the
+// API the checks go through.
+package spicedb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	authzed "github.com/authzed/authzed-go/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"test-tls/authz"
+)
+
+// FullyConsistent evaluates every request at the datastore's head revision.
+var FullyConsistent = &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}}
+
+// Consistencies are the names ParseConsistency understands:
+//
+//	full               fully_consistent, always at the datastore's head revision
+//	minimize_latency   any revision SpiceDB considers fresh enough, cache friendly
+//	at_least_as_fresh  at least the revision ReadSchema returns now
+var Consistencies = []string{"full", "minimize_latency", "at_least_as_fresh"}
+
+// AtLeastAsFresh evaluates every request at token's revision or later, e.g.
+// the ZedToken an earlier write returned.
+func AtLeastAsFresh(token string) *v1.Consistency {
+	return &v1.Consistency{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: &v1.ZedToken{Token: token}}}
+}
+
+// ParseConsistency returns the consistency named mode (see Consistencies);
+// at_least_as_fresh reads the current revision from client.
+func ParseConsistency(ctx context.Context, client *authzed.Client, mode string) (*v1.Consistency, error) {
+	switch mode {
+	case "full":
+		return FullyConsistent, nil
+	case "minimize_latency":
+		return &v1.Consistency{Requirement: &v1.Consistency_MinimizeLatency{MinimizeLatency: true}}, nil
+	case "at_least_as_fresh":
+		resp, err := client.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+		if err != nil {
+			return nil, fmt.Errorf("consistency at_least_as_fresh: ReadSchema: %w", err)
+		}
+		return &v1.Consistency{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: resp.GetReadAt()}}, nil
+	}
+	return nil, fmt.Errorf("unknown consistency %q (expected full|minimize_latency|at_least_as_fresh)", mode)
+}
+
+// NowContext is the caveat context for a request evaluated at the current
+// time, so valid_window grants resolve instead of coming back conditional.
+func NowContext() *structpb.Struct {
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"now": structpb.NewStringValue(time.Now().UTC().Format(time.RFC3339)),
+	}}
+}
+
+// Check is the CheckPermission call timed by the check_* scenarios.
+func Check(ctx context.Context, client *authzed.Client, consistency *v1.Consistency, resourceID, userID, permission string) (bool, error) {
+	resp, err := client.CheckPermission(ctx, CheckRequest(consistency, resourceID, userID, permission))
+	if err != nil {
+		return false, err
+	}
+	return resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, nil
+}
+
+// CheckRequest is the request Check sends.
+func CheckRequest(consistency *v1.Consistency, resourceID, userID, permission string) *v1.CheckPermissionRequest {
+	return &v1.CheckPermissionRequest{
+		Resource:    &v1.ObjectReference{ObjectType: "resource", ObjectId: resourceID},
+		Permission:  permission,
+		Subject:     &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: userID}},
+		Consistency: consistency,
+		Context:     NowContext(),
+	}
+}
+
+// Pair is one (resource, user) pair of a batched check.
+type Pair struct {
+	ResourceID, UserID string
+}
+
+// CheckBulk is the CheckBulkPermissions call timed by the check_bulk_*
+// scenarios: all pairs in one request, answered in order.
+func CheckBulk(ctx context.Context, client *authzed.Client, consistency *v1.Consistency, pairs []Pair, permission string) ([]bool, error) {
+	items := make([]*v1.CheckBulkPermissionsRequestItem, len(pairs))
+	caveatContext := NowContext()
+	for i, p := range pairs {
+		items[i] = &v1.CheckBulkPermissionsRequestItem{
+			Resource:   &v1.ObjectReference{ObjectType: "resource", ObjectId: p.ResourceID},
+			Permission: permission,
+			Subject:    &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: p.UserID}},
+			Context:    caveatContext,
+		}
+	}
+	resp, err := client.CheckBulkPermissions(ctx, &v1.CheckBulkPermissionsRequest{
+		Items:       items,
+		Consistency: consistency,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make([]bool, len(pairs))
+	for i, pair := range resp.Pairs {
+		if perr := pair.GetError(); perr != nil {
+			return nil, fmt.Errorf("pair %d: %s", i, perr.GetMessage())
+		}
+		allowed[i] = pair.GetItem().GetPermissionship() == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+	}
+	return allowed, nil
+}
+
+// LookupResources streams the LookupResources results for a user at
+// consistency, as timed by the lookup_resources_* scenarios.
+func LookupResources(ctx context.Context, client *authzed.Client, consistency *v1.Consistency, userID, permission string, handle func(resID string)) error {
+	stream, err := client.LookupResources(ctx, LookupRequest(consistency, userID, permission))
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		handle(resp.ResourceObjectId)
+	}
+}
+
+// LookupRequest is the request LookupResources sends.
+func LookupRequest(consistency *v1.Consistency, userID, permission string) *v1.LookupResourcesRequest {
+	return &v1.LookupResourcesRequest{
+		ResourceObjectType: "resource",
+		Permission:         permission,
+		Subject: &v1.SubjectReference{
+			Object: &v1.ObjectReference{
+				ObjectType: "user",
+				ObjectId:   userID,
+			},
+		},
+		Consistency: consistency,
+		Context:     NowContext(),
+	}
+}
+
+// CountAccess counts the resources userID can view and manage. SpiceDB has
+// no count API, so it is the two LookupResources streams, run concurrently as
+// a dashboard would, with only their lengths kept.
+func CountAccess(ctx context.Context, client *authzed.Client, consistency *v1.Consistency, userID string) (view, manage int, err error) {
+	var manageErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		manageErr = LookupResources(ctx, client, consistency, userID, authz.Manage, func(string) { manage++ })
+	}()
+	err = LookupResources(ctx, client, consistency, userID, authz.View, func(string) { view++ })
+	<-done
+	return view, manage, errors.Join(err, manageErr)
+}
+
+// LookupGroups streams the usergroups userID holds the member permission on
+// or, without effective, one of the direct_member_user and
+// direct_manager_user relations, each group once.
+func LookupGroups(ctx context.Context, client *authzed.Client, consistency *v1.Consistency, userID string, effective bool, handle func(groupID string)) error {
+	relations := []string{"direct_member_user", "direct_manager_user"}
+	if effective {
+		relations = []string{"member"}
+	}
+	seen := map[string]bool{}
+	for _, relation := range relations {
+		req := LookupRequest(consistency, userID, relation)
+		req.ResourceObjectType = "usergroup"
+		stream, err := client.LookupResources(ctx, req)
+		if err != nil {
+			return err
+		}
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if !seen[resp.ResourceObjectId] {
+				seen[resp.ResourceObjectId] = true
+				handle(resp.ResourceObjectId)
+			}
+		}
+	}
+	return nil
+}
+
+// schemaTerms maps the terms of authz.RuleTerms onto the resource definition
+// of the Zed schemas; permissions and earlier rules keep their names.
+var schemaTerms = map[string]string{"org_admin": "org->admin", "org_member": "org->member"}
+
+// RulePermissions returns the permission lines rules add to the resource
+// definition of the schema, e.g. "permission edit = manage + viewer_user".
+// Check and LookupResources answer a derived permission once the schema has
+// it: SpiceDB resolves it, inheritance included, like any other.
+func RulePermissions(rules []authz.Rule) []string {
+	lines := make([]string, len(rules))
+	for i, rule := range rules {
+		terms := make([]string, len(rule.Terms))
+		for j, term := range rule.Terms {
+			terms[j] = term
+			if t, ok := schemaTerms[term]; ok {
+				terms[j] = t
+			}
+		}
+		lines[i] = "permission " + rule.Permission + " = " + strings.Join(terms, " + ")
+	}
+	return lines
+}
+
+// Config is the configuration of a Checker.
+type Config struct {
+	// Consistency of every call; nil is FullyConsistent.
+	Consistency *v1.Consistency
+}
+
+// checker adapts Check, LookupResources, LookupGroups and CountAccess to
+// authz.Checker, authz.GroupLister and authz.AccessCounter.
+type checker struct {
+	client      *authzed.Client
+	consistency *v1.Consistency
+}
+
+// NewChecker returns the benchmark calls over client as an authz.Checker,
+// for embedding in services.
+func NewChecker(client *authzed.Client, cfg Config) authz.Checker {
+	if cfg.Consistency == nil {
+		cfg.Consistency = FullyConsistent
+	}
+	return checker{client: client, consistency: cfg.Consistency}
+}
+
+func (c checker) Check(ctx context.Context, resourceID, userID, permission string) (bool, error) {
+	return Check(ctx, c.client, c.consistency, resourceID, userID, permission)
+}
+
+func (c checker) LookupResources(ctx context.Context, userID, permission string, handle func(resourceID string)) error {
+	return LookupResources(ctx, c.client, c.consistency, userID, permission, handle)
+}
+
+func (c checker) LookupGroups(ctx context.Context, userID string, effective bool, handle func(groupID string)) error {
+	return LookupGroups(ctx, c.client, c.consistency, userID, effective, handle)
+}
+
+func (c checker) CountAccess(ctx context.Context, userID string) (int, int, error) {
+	return CountAccess(ctx, c.client, c.consistency, userID)
+}
@@ -91,12 +91,28 @@ scenario_clickhouse() { log_engine_header "clickhouse"; run_with_log "$LOG_CREAT
 scenario_mongodb() { log_engine_header "mongodb"; run_with_log "$LOG_CREATE" go run cmd/main.go mongodb create-schema; run_with_log "$LOG_LOAD" go run cmd/main.go mongodb load-data; benchmark_loop mongodb; }
 scenario_elasticsearch() { log_engine_header "elasticsearch"; run_with_log "$LOG_CREATE" go run cmd/main.go elasticsearch create-schema; run_with_log "$LOG_LOAD" go run cmd/main.go elasticsearch load-data; benchmark_loop elasticsearch; }
 
+# BENCH_RUNS benchmark runs per engine (default 3). With VERIFY_FIXTURE=true
+# the engine is checked against testdata/fixture/expected.json once instead
+# (load it with RLP_DATA_DIR=testdata/fixture); failures are reported at the end.
+# BENCH_CPUSET (e.g. "0-3") pins the benchmark client to those CPUs with
+# taskset, keeping it off the cores of the containers under test; the LIMITS
+# line of each run records the affinity it got.
+FIXTURE_FAILED=()
+bench_pin=()
+if [[ -n ${BENCH_CPUSET:-} ]]; then
+	bench_pin=(taskset -c "$BENCH_CPUSET")
+fi
 benchmark_loop() {
 	local engine="$1"
-	for i in {1..3}; do
-		echo "[benchmark][$engine] run $i/3 (delay ${DELAY_SECS}s after)" | tee -a "$LOG_BENCH"
-		run_with_log "$LOG_BENCH" go run cmd/main.go "$engine" benchmark
-		if [[ $i -lt 3 ]]; then
+	if [[ ${VERIFY_FIXTURE:-false} == true ]]; then
+		run_with_log "$LOG_BENCH" go run cmd/main.go "$engine" verify-fixture || FIXTURE_FAILED+=("$engine")
+		return 0
+	fi
+	local runs=${BENCH_RUNS:-3}
+	for i in {1..$runs}; do
+		echo "[benchmark][$engine] run $i/$runs (delay ${DELAY_SECS}s after)" | tee -a "$LOG_BENCH"
+		run_with_log "$LOG_BENCH" $bench_pin go run cmd/main.go "$engine" benchmark
+		if [[ $i -lt $runs ]]; then
 			echo "[benchmark][$engine] sleep ${DELAY_SECS}s" | tee -a "$LOG_BENCH"
 			run_no_log sleep $DELAY_SECS
 		fi
@@ -111,6 +127,14 @@ wait_clickhouse_ready() { local tries=30; for i in {1..$tries}; do if docker com
 wait_mongodb_ready() { local tries=40; for i in {1..$tries}; do if docker compose exec mongodb mongosh --quiet --eval 'db.runCommand({ping:1})' >/dev/null 2>&1; then echo "[ready] mongodb"; return 0; fi; echo "[wait] mongodb ($i/$tries)"; sleep 2; done; echo "[error] mongodb not ready"; return 1; }
 wait_elasticsearch_ready() { local tries=40; for i in {1..$tries}; do if curl -s localhost:9200 >/dev/null 2>&dirname efi || docker compose exec elasticsearch curl -s localhost:9200 >/dev/null 2>&1; then echo "[ready] elasticsearch"; return 0; fi; echo "[wait] elasticsearch ($i/$tries)"; sleep 3; done; echo "[error] elasticsearch not ready"; return 1; }
 
+# Closed-world check: every engine loaded the same dataset, so lookup counts
+# for the bench users must agree (LOOKUP_COUNT_TOLERANCE, default exact).
+verify_lookup_counts() {
+	echo "[verify] lookup counts across engines"
+	( cd "$ROOT_DIR" && go run ./benchmark/parse_all.go "$LOG_BENCH" | sed -n '/^## Lookup count verification/,$p' ) \
+		|| echo "[verify] WARNING: lookup counts differ across engines (see MISMATCH rows)"
+}
+
 usage() { echo "Usage: $0 [all|cockroachdb|authzed_crdb|postgres|authzed_pgdb|scylladb|clickhouse|mongodb|elasticsearch]"; exit 1; }
 
 main() {
@@ -132,6 +156,7 @@ main() {
 			echo "[run] mongodb"; setup_mongodb; scenario_mongodb
 			echo "[run] clickhouse"; setup_clickhouse; scenario_clickhouse
 			echo "[run] elasticsearch"; setup_elasticsearch; scenario_elasticsearch
+			verify_lookup_counts
 			;;
 		cockroachdb) setup_crdb; scenario_cockroachdb ;;
 		authzed_crdb|authzed-crdb) scenario_authzed_crdb ;;
@@ -143,6 +168,10 @@ main() {
 		elasticsearch) setup_elasticsearch; scenario_elasticsearch ;;
 		*) usage ;;
 	esac
+	if (( ${#FIXTURE_FAILED} )); then
+		echo "[fixture] FAILED: ${FIXTURE_FAILED[*]}"
+		exit 1
+	fi
 }
 
 main "$@"
@@ -0,0 +1,123 @@
+#!/usr/bin/env zsh
+
+# ACL density sweep: regenerate the dataset at several viewer densities
+# (RLP_VIEWER_USERS_PER_RESOURCE) and run a reduced benchmark at each point.
+# Every point's benchmark log is appended to 4-sweep.log under a
+# "==== SWEEP: viewers_per_resource=N ====" header; parse_all.go turns the
+# log into a scaling table per backend.
+#
+#   SWEEP_DENSITIES   viewers per resource to sweep (default "1 10 100 1000")
+#   SWEEP_ENGINES     engines to run at each point (default: all)
+#   SWEEP_RUNS        benchmark runs per engine and point (default 1)
+#   SWEEP_CHECK_ITER  iterations of each check_* scenario (default 200)
+#   SWEEP_LOOKUP_ITER iterations of each lookup_resources_* scenario (default 3)
+#   SWEEP_RUN_ID      run to resume (default: a new one, named by the time)
+#
+# Each finished cell (density, engine) keeps its log in
+# benchmark/runs/sweep-<run id>/, and 4-sweep.log is assembled from them.
+# Rerunning with SWEEP_RUN_ID set to the id it printed skips the cells
+# already there, so a crash costs only the cell it interrupted. The run
+# directory also records the densities, engines and random seed, and a
+# resumed run reuses them, so every cell sees the same datasets.
+# The generator caps viewers at the users of an org, so RLP_USERS_PER_ORG is
+# raised to the density where it is lower. The dataset of the last point is
+# left in data/ and the ones it replaced in data/datasets/ (`data list`);
+# load one with --data-dir, or rerun 1-prepare.sh for the default one.
+
+set -euo pipefail
+
+SCRIPT_DIR=${0:a:h}
+ROOT_DIR=${SCRIPT_DIR:h}
+
+LOG_SWEEP="$SCRIPT_DIR/4-sweep.log"
+LOG_BENCH="$SCRIPT_DIR/3-3-benchmark.log"
+RUN_ID=${SWEEP_RUN_ID:-$(date +%Y%m%d-%H%M%S)}
+RUN_DIR="$SCRIPT_DIR/runs/sweep-$RUN_ID"
+
+if [[ -f "$ROOT_DIR/.env" ]]; then
+	echo "[env] load $ROOT_DIR/.env"
+	set -a; source "$ROOT_DIR/.env"; set +a
+fi
+
+# A resumed run keeps the settings it started with.
+if [[ -f "$RUN_DIR/run.env" ]]; then
+	echo "[sweep] resume $RUN_DIR"
+	source "$RUN_DIR/run.env"
+fi
+densities=(${=SWEEP_DENSITIES:-1 10 100 1000})
+engines=(${=SWEEP_ENGINES:-authzed_crdb authzed_pgdb scylladb cockroachdb postgres mongodb clickhouse elasticsearch})
+users_per_org=${RLP_USERS_PER_ORG:-200}
+seed=${RLP_RANDOM_SEED:-$(date +%s)}
+
+# Reduced benchmark: written to .env.bench after each generate, since
+# cmd/main.go loads it after .env.
+reduced_env() {
+	local check=${SWEEP_CHECK_ITER:-200} lookup=${SWEEP_LOOKUP_ITER:-3}
+	cat <<-ENV
+
+		# sweep: reduced benchmark (benchmark/4-sweep.sh)
+		BENCH_CHECK_DIRECT_SUPER_ITER=$check
+		BENCH_CHECK_ORGADMIN_ITER=$check
+		BENCH_CHECK_VIEW_GROUP_ITER=$check
+		BENCH_CHECK_TIME_BOUNDED_ITER=$check
+		BENCH_CHECK_BULK_ITER=$(( check / 10 > 0 ? check / 10 : 1 ))
+		BENCH_LOOKUPRES_MANAGE_ITER=$lookup
+		BENCH_LOOKUPRES_VIEW_ITER=$lookup
+		BENCH_LOOKUPRES_MIX_ITER=$lookup
+	ENV
+}
+
+# cell_log is where the benchmark log of a finished cell is kept.
+cell_log() { echo "$RUN_DIR/viewers-$1.$2.log"; }
+
+# assemble_log rewrites 4-sweep.log from the finished cells, in sweep order.
+assemble_log() {
+	: > "$LOG_SWEEP"
+	for density in $densities; do
+		{ echo ""; echo "==== SWEEP: viewers_per_resource=$density ===="; } >> "$LOG_SWEEP"
+		for engine in $engines; do
+			[[ -f $(cell_log $density $engine) ]] && cat "$(cell_log $density $engine)" >> "$LOG_SWEEP"
+		done
+	done
+}
+
+main() {
+	cd "$ROOT_DIR"
+	mkdir -p "$RUN_DIR"
+	if [[ ! -f "$RUN_DIR/run.env" ]]; then
+		cat > "$RUN_DIR/run.env" <<-ENV
+			SWEEP_DENSITIES="$densities"
+			SWEEP_ENGINES="$engines"
+			RLP_USERS_PER_ORG=$users_per_org
+			RLP_RANDOM_SEED=$seed
+		ENV
+	fi
+	echo "[sweep] run $RUN_ID (resume with SWEEP_RUN_ID=$RUN_ID)"
+	for density in $densities; do
+		local pending=()
+		for engine in $engines; do
+			[[ -f $(cell_log $density $engine) ]] || pending+=($engine)
+		done
+		if (( ! ${#pending} )); then
+			echo "[sweep] viewers_per_resource=$density: every engine done, skipped"
+			continue
+		fi
+		local users=$(( density > users_per_org ? density : users_per_org ))
+		echo "[sweep] viewers_per_resource=$density users_per_org=$users"
+		RLP_VIEWER_USERS_PER_RESOURCE=$density RLP_USERS_PER_ORG=$users RLP_RANDOM_SEED=$seed RLP_WRITE_BENCH_USERS=env \
+			go run ./cmd/main.go csv generate
+		reduced_env >> "$ROOT_DIR/.env.bench"
+
+		for engine in $pending; do
+			echo "[sweep] viewers_per_resource=$density engine=$engine"
+			BENCH_RUNS=${SWEEP_RUNS:-1} "$SCRIPT_DIR/3-benchmark.sh" "$engine"
+			cp "$LOG_BENCH" "$(cell_log $density $engine).tmp"
+			mv "$(cell_log $density $engine).tmp" "$(cell_log $density $engine)"
+			assemble_log
+		done
+	done
+	assemble_log
+	echo "[sweep] done; report: go run ./benchmark/parse_all.go $LOG_SWEEP"
+}
+
+main "$@"
@@ -0,0 +1,110 @@
+#!/usr/bin/env zsh
+
+# SpiceDB datastore head-to-head: the same benchmark against authzed_crdb
+# (SpiceDB on CockroachDB) and authzed_pgdb (SpiceDB on Postgres) only, at
+# every read consistency of H2H_CONSISTENCY, with the write scenarios on.
+# Every run is appended to 5-spicedb-datastores.log; the report, with a
+# "Head-to-head" table per scenario and consistency, is written to
+# 5-spicedb-datastores.md.
+#
+#   H2H_CONSISTENCY        SPICEDB_CONSISTENCY levels to sweep
+#                          (default "full minimize_latency at_least_as_fresh")
+#   H2H_RUNS               benchmark runs per datastore and level (default 1)
+#   H2H_WRITE_ITER         write_grant/write_revoke iterations (default 200)
+#   H2H_ESCALATION_CYCLES  org_admin_escalation cycles (default 5)
+#   H2H_WATCH              follow the writes on the Watch API (SPICEDB_WATCH,
+#                          default false)
+#   H2H_RUN_ID             run to resume (default: a new one, named by the time)
+#
+# Each finished cell (level, datastore) keeps its log in
+# benchmark/runs/h2h-<run id>/, and 5-spicedb-datastores.log is assembled
+# from them. Rerunning with H2H_RUN_ID set to the id it printed skips the
+# cells already there; a resumed run keeps the levels it started with.
+# Settings go through .env.bench, since cmd/main.go loads it after .env; the
+# original .env.bench is restored on exit.
+
+set -euo pipefail
+
+SCRIPT_DIR=${0:a:h}
+ROOT_DIR=${SCRIPT_DIR:h}
+
+LOG_H2H="$SCRIPT_DIR/5-spicedb-datastores.log"
+REPORT_H2H="$SCRIPT_DIR/5-spicedb-datastores.md"
+LOG_BENCH="$SCRIPT_DIR/3-3-benchmark.log"
+BENCH_ENV="$ROOT_DIR/.env.bench"
+BENCH_ENV_SAVED="$ROOT_DIR/.env.bench.h2h"
+RUN_ID=${H2H_RUN_ID:-$(date +%Y%m%d-%H%M%S)}
+RUN_DIR="$SCRIPT_DIR/runs/h2h-$RUN_ID"
+
+# A resumed run keeps the settings it started with.
+if [[ -f "$RUN_DIR/run.env" ]]; then
+	echo "[h2h] resume $RUN_DIR"
+	source "$RUN_DIR/run.env"
+fi
+levels=(${=H2H_CONSISTENCY:-full minimize_latency at_least_as_fresh})
+datastores=(authzed_crdb authzed_pgdb)
+
+restore_bench_env() {
+	if [[ -f "$BENCH_ENV_SAVED" ]]; then
+		mv "$BENCH_ENV_SAVED" "$BENCH_ENV"
+	else
+		rm -f "$BENCH_ENV"
+	fi
+}
+
+h2h_env() {
+	local level=$1
+	cat <<-ENV
+
+		# head-to-head (benchmark/5-spicedb-datastores.sh)
+		SPICEDB_CONSISTENCY=$level
+		BENCH_WRITE_ITER=${H2H_WRITE_ITER:-200}
+		BENCH_ESCALATION_CYCLES=${H2H_ESCALATION_CYCLES:-5}
+		SPICEDB_WATCH=${H2H_WATCH:-false}
+	ENV
+}
+
+# cell_log is where the benchmark log of a finished cell is kept.
+cell_log() { echo "$RUN_DIR/$1.$2.log"; }
+
+# assemble_log rewrites 5-spicedb-datastores.log from the finished cells.
+assemble_log() {
+	: > "$LOG_H2H"
+	for level in $levels; do
+		for datastore in $datastores; do
+			[[ -f $(cell_log $level $datastore) ]] && cat "$(cell_log $level $datastore)" >> "$LOG_H2H"
+		done
+	done
+}
+
+main() {
+	cd "$ROOT_DIR"
+	mkdir -p "$RUN_DIR"
+	[[ -f "$RUN_DIR/run.env" ]] || echo "H2H_CONSISTENCY=\"$levels\"" > "$RUN_DIR/run.env"
+	echo "[h2h] run $RUN_ID (resume with H2H_RUN_ID=$RUN_ID)"
+	[[ -f "$BENCH_ENV" ]] && cp "$BENCH_ENV" "$BENCH_ENV_SAVED"
+	trap restore_bench_env EXIT INT TERM
+
+	for level in $levels; do
+		for datastore in $datastores; do
+			if [[ -f $(cell_log $level $datastore) ]]; then
+				echo "[h2h] consistency=$level datastore=$datastore: done, skipped"
+				continue
+			fi
+			echo "[h2h] consistency=$level datastore=$datastore"
+			restore_bench_env
+			[[ -f "$BENCH_ENV" ]] && cp "$BENCH_ENV" "$BENCH_ENV_SAVED"
+			h2h_env "$level" >> "$BENCH_ENV"
+			BENCH_RUNS=${H2H_RUNS:-1} "$SCRIPT_DIR/3-benchmark.sh" "$datastore"
+			cp "$LOG_BENCH" "$(cell_log $level $datastore).tmp"
+			mv "$(cell_log $level $datastore).tmp" "$(cell_log $level $datastore)"
+		done
+	done
+	assemble_log
+
+	HEAD_TO_HEAD=authzed_crdb,authzed_pgdb go run ./benchmark/parse_all.go "$LOG_H2H" > "$REPORT_H2H" \
+		|| echo "[h2h] WARNING: lookup counts differ between the datastores (see MISMATCH rows)"
+	echo "[h2h] done; report: $REPORT_H2H"
+}
+
+main "$@"
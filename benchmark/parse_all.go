@@ -2,26 +2,118 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"path"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Generalized parser for all read scenarios.
-// Streaming sampled scenarios: check_manage_direct_user, check_manage_org_admin, check_view_via_group_member
-// Enumeration scenarios: lookup_resources_manage_super, lookup_resources_view_regular
+// Streaming sampled scenarios: check_manage_direct_user, check_manage_org_admin, check_view_via_group_member,
+// check_time_bounded_direct_user, check_bulk_manage_direct_user, and any custom_* scenarios
+// (BENCH_CUSTOM_SCENARIOS), which are reported after the builtin ones
+// Enumeration scenarios: lookup_resources_manage_super, lookup_resources_view_regular, and their
+// count-only *_count variants (Elasticsearch) when logged
+// The viral fan-in scenarios (check_view_viral_direct_user, lookup_resources_view_viral),
+// the worst-case check (check_view_worst_case),
+// the authorized listing (list_recent_viewable),
+// the SpiceDB write scenarios (write_grant, write_revoke), the SpiceDB
+// read_relationships_* scenarios and the lookup mixes are
+// reported only when logged.
 // Computes mean, p95, min, max over collected sample durations per engine + scenario.
+// ERRORS lines (per-iteration failures by class) are summed into an error-rate table.
+// SHEDDING lines (when the server pushed back, per second of a scenario) get a "Load
+// shedding" table, so throttled latencies are not read as slow queries.
+// lookup_resources_*_mix MIX lines (BENCH_LOOKUPRES_MIX_USERS) are grouped into latency by
+// result-set size.
+// lookup_resources_*_pct PCT lines (BENCH_LOOKUPRES_*_PCT_USERS) give latency per user
+// percentile of the grant counts (p10/p50/p90/p99) across backends.
+// All lookup iterations of an engine are fitted as duration = fixed + perRow*resources
+// (latency vs result size).
+// org_admin_escalation ESCALATION lines (BENCH_ESCALATION_CYCLES) count how often a cached
+// lookup missed a role change, per backend and role.
+// driver_overhead DONE lines (BENCH_DRIVER_COMPARE) are listed after the scenarios.
+// middleware_overhead DONE lines (BENCH_MIDDLEWARE_ITER) get a "Middleware overhead"
+// table: a resource fetch with and without a view check in front of it.
+// SpiceDB lookup STREAM lines (time to first result, inter-item gaps) get a "Lookup
+// streaming" table.
+// SpiceDB write VISIBILITY lines (SPICEDB_WATCH) get a "Watch visibility" table.
+// SpiceDB read_relationships_* and export_full_acl THROUGHPUT lines
+// (BENCH_READ_RELS_ITER, BENCH_EXPORT_ITER) get a "Relationship reads" table.
+// BENCH_ORACLE_URL ORACLE lines (timed checks verified against an oracle
+// backend) get an "Oracle verification" table; a disagreement also makes the
+// parser exit with status 2.
+// BENCH_WORKLOAD OP lines (per-operation latency within a blended workload) get
+// a "Workload mix" table.
+// Finally the lookup scenarios' lastCount is compared across engines (closed-world
+// check: every backend holds the same dataset, so every backend should find the
+// same resources for the same user). Counts off the majority by more than
+// LOOKUP_COUNT_TOLERANCE (fraction, default 0) are flagged MISMATCH and the
+// parser exits with status 2.
+// Logs from benchmark/4-sweep.sh ("==== SWEEP: param=N ====" headers) also get a
+// per-point latency table with a scaling exponent per backend and scenario.
+// Runs with BENCH_CACHE_COMPARE ("CACHE: phase=cold|warm" lines) also get a cold vs
+// warm cache table per backend and scenario.
+// Each scenario mean gets a 95% confidence interval, over the run means when the log holds
+// several runs (BENCH_RUNS) and over the samples otherwise.
+// HEAD_TO_HEAD=a,b narrows the report to two engines and adds a table comparing them per
+// scenario and read consistency (benchmark/5-spicedb-datastores.sh), with a Mann-Whitney U
+// test and a Welch CI of the difference of the means.
+// SLO_FILE=<file> checks each backend against per-scenario service level objectives
+// (e.g. check_* p99 under 20ms, see benchmark/slo.example.conf) and prints a pass/fail
+// decision matrix.
+// COMPARE_LOG=<earlier log> adds the same comparison of this run against that one per
+// engine and scenario.
+// CONSISTENCY lines (per-engine read settings) and SCHEMA lines (SpiceDB schema
+// variant and hash) are listed ahead of the scenarios, and so are LIMITS lines
+// (GOMAXPROCS, CPU affinity and cgroup quota of the benchmark client). The
+// CONFIG line each scenario starts with (its effective iterations, timeout,
+// users, consistency and seed) is summarized in a "Configuration" table.
+// `clickhouse sort-keys`, `scylladb index-compare`, `mongodb
+// group-resolution` and `postgres|cockroachdb group-closure` results get a
+// table per query and variant.
 
 var (
 	reEngineHeader2         = regexp.MustCompile(`^==== ENGINE: (.+?) ====`)
-	reStreamingStart        = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>check_[a-z_]+)\] streaming mode\. iterations=(?P<iters>\d+)`)
-	reStreamingLookupSample = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>check_[a-z_]+)\] lookup iter=(?P<iter>\d+) .* dur=(?P<dur>[0-9.]+)(?P<unit>ms|µs|ns|s)`)
-	reStreamingIterSample   = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>check_[a-z_]+)\] iter=(?P<iter>\d+) .* dur=(?P<dur>[0-9.]+)(?P<unit>ms|µs|ns|s)`)
-	reStreamingDone         = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>check_[a-z_]+)\] DONE: iters=(?P<iters>\d+)`)
-	reEnumStart             = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>lookup_resources_[a-z_]+)\] iterations=(?P<iters>\d+) user=\d+`)
+	reSweepHeader           = regexp.MustCompile(`^==== SWEEP: (?P<param>[a-z_]+)=(?P<value>\d+) ====`)
+	reStreamingStart        = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>(?:check|custom|write|list|read|export|workload|count)_[a-z0-9_]+)\] streaming mode\. iterations=(?P<iters>\d+)`)
+	reStreamingLookupSample = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>(?:check|custom)_[a-z0-9_]+)\] lookup iter=(?P<iter>\d+) .* dur=(?P<dur>[0-9.]+)(?P<unit>ms|µs|ns|s)`)
+	reStreamingIterSample   = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>(?:check|custom|write|list|read|export|workload|count)_[a-z0-9_]+)\] iter=(?P<iter>\d+) .* dur=(?P<dur>[0-9.]+)(?P<unit>ms|µs|ns|s)`)
+	reStreamingDone         = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>(?:check|custom|write|list|read|export|workload|count)_[a-z0-9_]+)\] DONE: iters=(?P<iters>\d+)`)
+	reEnumStart             = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>lookup_resources_[a-z_]+)\] iterations=(?P<iters>\d+) user=\S+`)
 	reEnumIter              = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>lookup_resources_[a-z_]+)\] iter=\d+ resources=(?P<count>\d+) duration=(?P<dur>[0-9.]+)(?P<unit>ms|µs|ns|s)`)
+	reErrors                = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>[a-z0-9_]+)\] ERRORS: attempts=(?P<attempts>\d+) errors=(?P<errors>\d+) rate=[0-9.]+ (?P<classes>.*)$`)
+	reErrorClass            = regexp.MustCompile(`([a-z-]+)=(\d+)`)
+	reConsistency           = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] CONSISTENCY: (?P<settings>.*)$`)
+	reDriverDone            = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[driver_overhead\] query=(?P<query>\S+) driver=(?P<driver>\S+) DONE: iters=(?P<iters>\d+) avg=(?P<avg>\S+) p50=(?P<p50>\S+) p95=(?P<p95>\S+) p99=(?P<p99>\S+)(?: overhead_avg=(?P<oavg>\S+) overhead_p50=\S+ overhead_p99=(?P<op99>\S+))?`)
+	reMiddlewareDone        = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[middleware_overhead\] variant=(?P<variant>\S+) DONE: iters=(?P<iters>\d+) avg=(?P<avg>\S+) p50=(?P<p50>\S+) p95=\S+ p99=(?P<p99>\S+)(?: overhead_avg=(?P<oavg>\S+) overhead_p50=\S+ overhead_p99=(?P<op99>\S+) overhead_pct=(?P<opct>\S+))?`)
+	reStatement             = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] STATEMENT: calls=(?P<calls>\d+) mean=(?P<mean>\S+) total=(?P<total>\S+) io=(?P<io>\S+) query=(?P<query>.*)$`)
+	reCost                  = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>[a-z0-9_]+)\] COST: (?P<counters>.*)$`)
+	rePercentile            = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>lookup_resources_[a-z]+_pct)\] PCT: user=\S+ percentile=p(?P<pct>\d+) resources=(?P<count>\d+) avg=(?P<avg>\S+) p50=\S+ p95=\S+ p99=(?P<p99>\S+)`)
+	reMix                   = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>lookup_resources_[a-z_]+)\] MIX: user=\S+ decile=(?P<decile>\d+) grants=\d+ resources=(?P<count>\d+) avg=(?P<avg>\S+) p50=(?P<p50>\S+) p95=\S+ p99=(?P<p99>\S+)`)
+	reEscalation            = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[org_admin_escalation\] ESCALATION: cycle=\d+ role=(?P<role>\S+) cached=\d+ cached_dur=(?P<cachedDur>\S+) fresh=(?P<fresh>\d+) fresh_dur=(?P<freshDur>\S+) stale=(?P<stale>true|false) write=(?P<write>\S+)`)
+	reStream                = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>lookup_resources_[a-z_]+)\] STREAM: streams=(?P<streams>\d+) first_avg=(?P<favg>\S+) first_p50=(?P<fp50>\S+) first_p95=(?P<fp95>\S+) first_p99=(?P<fp99>\S+) gaps=(?P<gaps>\d+) gap_avg=(?P<gavg>\S+) gap_p50=(?P<gp50>\S+) gap_p95=(?P<gp95>\S+) gap_p99=(?P<gp99>\S+) (?P<hist>.*)$`)
+	reShedding              = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>[a-z0-9_]+)\] SHEDDING: events=(?P<events>\d+) first=(?P<first>\S+) last=(?P<last>\S+) timeline=(?P<timeline>\S+)`)
+	reThroughput            = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>read_relationships_[a-z_]+|export_full_acl)\] THROUGHPUT: rels=(?P<rels>\d+) total=(?P<total>\S+) rels_per_sec=(?P<rate>\S+)`)
+	reWorkloadOp            = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>workload_[a-z0-9_]+)\] OP: op=(?P<op>\S+) share=(?P<share>\S+) calls=(?P<calls>\d+) avg=(?P<avg>\S+) p50=(?P<p50>\S+) p95=(?P<p95>\S+) p99=(?P<p99>\S+)`)
+	reSlow                  = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>[a-z0-9_]+)\] SLOW: rank=(?P<rank>\d+) latency=(?P<latency>\S+) kind=(?P<kind>\S+) resource=(?P<resource>\S+) user=(?P<user>\S+) permission=(?P<permission>\S+) result=(?P<result>\S+) ts=(?P<ts>\S+)`)
+	reOracle                = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>[a-z0-9_]+)\] ORACLE: checks=(?P<checks>\d+) agree=(?P<agree>\d+) disagree=(?P<disagree>\d+) errors=(?P<errors>\d+) skipped=(?P<skipped>\d+) accuracy=(?P<accuracy>\S+)`)
+	reVisibility            = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>write_[a-z_]+)\] VISIBILITY: writes=(?P<writes>\d+) seen=(?P<seen>\d+) missed=(?P<missed>\d+) early=(?P<early>\d+) avg=(?P<avg>\S+) p50=(?P<p50>\S+) p95=(?P<p95>\S+) p99=(?P<p99>\S+)`)
+	reCachePhase            = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] CACHE: phase=(?P<phase>cold|warm)(?: flush=(?P<flush>\S+))?`)
+	reMaintenancePhase      = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] MAINTENANCE: phase=(?P<phase>quiet|maintenance)(?: task=(?P<task>\S+))?`)
+	reMaintenanceDone       = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] MAINTENANCE: DONE task=\S+ rounds=(?P<rounds>\d+) failed=(?P<failed>\d+) busy=(?P<busy>\S+)`)
+	reLimits                = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] LIMITS: (?P<limits>gomaxprocs=.*)$`)
+	reConfig                = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>[a-z0-9_]+)\] CONFIG: (?P<config>\{.*\})$`)
+	reLayout                = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<experiment>sort_keys|index_compare|group_resolution|group_closure|group_churn|bitmaps|offboard|permission_refresh|check_batching|permissions|denials)\] variant=(?P<variant>\S+) query=(?P<query>\S+) DONE: iters=(?P<iters>\d+) rows=(?P<rows>\d+) avg=(?P<avg>\S+) p50=(?P<p50>\S+) p95=(?P<p95>\S+) p99=(?P<p99>\S+)`)
+	reLayoutSize            = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<experiment>index_compare)\] variant=(?P<variant>\S+) table=(?P<table>\S+) size=(?P<size>\S+)`)
+	reSchema                = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] SCHEMA: (?P<schema>.*)$`)
 	reEnumDone              = regexp.MustCompile(`\[(?P<engine>[^\]]+)\] \[(?P<scenario>lookup_resources_[a-z_]+)\] DONE: iters=(?P<iters>\d+) lastCount=(?P<count>\d+) avg=(?P<avg>[0-9.]+)(?P<avgUnit>ms|µs|ns|s) total=(?P<total>[0-9.]+)(?P<totalUnit>ms|µs|ns|s)`)
 )
 
@@ -31,14 +123,23 @@ type ScenarioMetrics struct {
 	IterationsCfg int
 	Runs          int
 	DurationsMs   []float64
+	RunOf         []int // run (1-based start count) of each sample, parallel to DurationsMs
+	Counts        []int // lookup scenarios: resources returned, parallel to DurationsMs
 	MeanMs        float64
+	CIMs          float64 // half-width of the 95% CI of MeanMs, over run means when Runs > 1
 	P95Ms         float64
 	MinMs         float64
 	MaxMs         float64
 	SamplesPerRun int
 	LastCount     int
+	Attempts      int
+	Errors        int
+	ErrorClasses  map[string]int
 }
 
+// errorClasses mirrors utils.ErrClasses; kept local so this tool stays standalone.
+var errorClasses = []string{"timeout", "unavailable", "shed", "deadlock", "not-found", "other"}
+
 func main() {
 	logPath := "benchmark/3-3-benchmark.log"
 	if len(os.Args) > 1 {
@@ -53,11 +154,141 @@ func main() {
 
 	metrics := map[string]*ScenarioMetrics{}
 	runStarts := map[string]int{}
+	consistency := map[string][]string{}
+	schemas := map[string][]string{}
+	limits := map[string][]string{}
+	var configs []scenarioConfig
+	var drivers, middleware, statements, costs, mixes, percentiles, escalations, layouts, layoutSizes, streams, visibility, throughput, shedding, workloadOps, oracles, slow [][]string
+	sw := &sweep{samples: map[string]map[int][]float64{}}
+	h2h := newHeadToHead(os.Getenv("HEAD_TO_HEAD"))
+	cache := &cachePhases{flush: map[string]string{}, samples: map[string]map[string][]float64{}}
+	maint := &maintenancePhases{task: map[string]string{}, rounds: map[string]string{}, samples: map[string]map[string][]float64{}}
 
 	scanner := bufio.NewScanner(fh)
 	for scanner.Scan() {
 		line := scanner.Text()
 		if reEngineHeader2.MatchString(line) {
+			cache.phase = ""
+			maint.phase = ""
+			continue
+		}
+		if m := reCachePhase.FindStringSubmatch(line); m != nil {
+			cache.phase = m[2]
+			if m[2] == "cold" {
+				cache.flush[m[1]] = m[3]
+			}
+			continue
+		}
+		if m := reMaintenancePhase.FindStringSubmatch(line); m != nil {
+			maint.phase = m[2]
+			if m[2] == "maintenance" {
+				maint.task[m[1]] = m[3]
+			}
+			continue
+		}
+		if m := reMaintenanceDone.FindStringSubmatch(line); m != nil {
+			maint.phase = ""
+			maint.rounds[m[1]] = fmt.Sprintf("%s (%s failed, busy %s)", m[2], m[3], m[4])
+			continue
+		}
+		if m := reSweepHeader.FindStringSubmatch(line); m != nil {
+			sw.param, sw.point = m[1], atoi(m[2])
+			if !slices.Contains(sw.points, sw.point) {
+				sw.points = append(sw.points, sw.point)
+			}
+			continue
+		}
+		if m := reConsistency.FindStringSubmatch(line); m != nil {
+			engine, settings := m[1], m[2]
+			if !slices.Contains(consistency[engine], settings) {
+				consistency[engine] = append(consistency[engine], settings)
+			}
+			h2h.consistency[engine] = settings
+			continue
+		}
+		if m := reLayoutSize.FindStringSubmatch(line); m != nil {
+			layoutSizes = append(layoutSizes, m[1:])
+			continue
+		}
+		if m := reLayout.FindStringSubmatch(line); m != nil {
+			layouts = append(layouts, m[1:])
+			continue
+		}
+		if m := reDriverDone.FindStringSubmatch(line); m != nil {
+			drivers = append(drivers, m[1:])
+			continue
+		}
+		if m := reMiddlewareDone.FindStringSubmatch(line); m != nil {
+			middleware = append(middleware, m[1:])
+			continue
+		}
+		if m := reStream.FindStringSubmatch(line); m != nil {
+			streams = append(streams, m[1:])
+			continue
+		}
+		if m := reVisibility.FindStringSubmatch(line); m != nil {
+			visibility = append(visibility, m[1:])
+			continue
+		}
+		if m := reShedding.FindStringSubmatch(line); m != nil {
+			shedding = append(shedding, m[1:])
+			continue
+		}
+		if m := reThroughput.FindStringSubmatch(line); m != nil {
+			throughput = append(throughput, m[1:])
+			continue
+		}
+		if m := reOracle.FindStringSubmatch(line); m != nil {
+			oracles = append(oracles, m[1:])
+			continue
+		}
+		if m := reSlow.FindStringSubmatch(line); m != nil {
+			slow = append(slow, m[1:])
+			continue
+		}
+		if m := reWorkloadOp.FindStringSubmatch(line); m != nil {
+			workloadOps = append(workloadOps, m[1:])
+			continue
+		}
+		if m := rePercentile.FindStringSubmatch(line); m != nil {
+			percentiles = append(percentiles, m[1:])
+			continue
+		}
+		if m := reMix.FindStringSubmatch(line); m != nil {
+			mixes = append(mixes, m[1:])
+			continue
+		}
+		if m := reEscalation.FindStringSubmatch(line); m != nil {
+			escalations = append(escalations, m[1:])
+			continue
+		}
+		if m := reCost.FindStringSubmatch(line); m != nil {
+			costs = append(costs, m[1:])
+			continue
+		}
+		if m := reStatement.FindStringSubmatch(line); m != nil {
+			statements = append(statements, m[1:])
+			continue
+		}
+		if m := reConfig.FindStringSubmatch(line); m != nil {
+			var cfg scenarioConfig
+			if err := json.Unmarshal([]byte(m[3]), &cfg); err == nil && !slices.ContainsFunc(configs, cfg.same) {
+				configs = append(configs, cfg)
+			}
+			continue
+		}
+		if m := reLimits.FindStringSubmatch(line); m != nil {
+			engine, l := m[1], m[2]
+			if !slices.Contains(limits[engine], l) {
+				limits[engine] = append(limits[engine], l)
+			}
+			continue
+		}
+		if m := reSchema.FindStringSubmatch(line); m != nil {
+			engine, schema := m[1], m[2]
+			if !slices.Contains(schemas[engine], schema) {
+				schemas[engine] = append(schemas[engine], schema)
+			}
 			continue
 		}
 		if m := reStreamingStart.FindStringSubmatch(line); m != nil {
@@ -87,6 +318,11 @@ func main() {
 				metrics[key] = &ScenarioMetrics{Engine: engine, Scenario: scenario, IterationsCfg: 1000}
 			}
 			metrics[key].DurationsMs = append(metrics[key].DurationsMs, dur)
+			metrics[key].RunOf = append(metrics[key].RunOf, runStarts[key])
+			sw.record(key, dur)
+			cache.record(key, dur)
+			maint.record(key, dur)
+			h2h.record(engine, scenario, dur)
 			continue
 		}
 		if m := reStreamingIterSample.FindStringSubmatch(line); m != nil {
@@ -97,6 +333,11 @@ func main() {
 				metrics[key] = &ScenarioMetrics{Engine: engine, Scenario: scenario, IterationsCfg: 1000}
 			}
 			metrics[key].DurationsMs = append(metrics[key].DurationsMs, dur)
+			metrics[key].RunOf = append(metrics[key].RunOf, runStarts[key])
+			sw.record(key, dur)
+			cache.record(key, dur)
+			maint.record(key, dur)
+			h2h.record(engine, scenario, dur)
 			continue
 		}
 		if m := reEnumIter.FindStringSubmatch(line); m != nil {
@@ -108,6 +349,12 @@ func main() {
 				metrics[key] = &ScenarioMetrics{Engine: engine, Scenario: scenario, IterationsCfg: 10}
 			}
 			metrics[key].DurationsMs = append(metrics[key].DurationsMs, dur)
+			metrics[key].RunOf = append(metrics[key].RunOf, runStarts[key])
+			sw.record(key, dur)
+			cache.record(key, dur)
+			maint.record(key, dur)
+			h2h.record(engine, scenario, dur)
+			metrics[key].Counts = append(metrics[key].Counts, atoi(m[3]))
 			continue
 		}
 		if m := reEnumDone.FindStringSubmatch(line); m != nil {
@@ -123,6 +370,23 @@ func main() {
 			}
 			continue
 		}
+		if m := reErrors.FindStringSubmatch(line); m != nil {
+			engine, scenario := m[1], m[2]
+			key := key(engine, scenario)
+			if metrics[key] == nil {
+				metrics[key] = &ScenarioMetrics{Engine: engine, Scenario: scenario}
+			}
+			sm := metrics[key]
+			sm.Attempts += atoi(m[3])
+			sm.Errors += atoi(m[4])
+			if sm.ErrorClasses == nil {
+				sm.ErrorClasses = map[string]int{}
+			}
+			for _, c := range reErrorClass.FindAllStringSubmatch(m[5], -1) {
+				sm.ErrorClasses[c[1]] += atoi(c[2])
+			}
+			continue
+		}
 		if reStreamingDone.MatchString(line) {
 			continue
 		}
@@ -149,6 +413,10 @@ func main() {
 			sum += v
 		}
 		sm.MeanMs = sum / float64(len(sm.DurationsMs))
+		_, sm.CIMs = meanCI(sm.DurationsMs)
+		if means := runMeans(sm); len(means) > 1 {
+			_, sm.CIMs = meanCI(means)
+		}
 		idx := int(0.95*float64(len(sorted))) - 1
 		if idx < 0 {
 			idx = 0
@@ -160,21 +428,1529 @@ func main() {
 	}
 
 	orderEngines := []string{"authzed_crdb", "authzed_pgdb", "cockroachdb", "postgres", "scylladb", "clickhouse", "elasticsearch", "mongodb"}
-	scenarios := []string{"check_manage_direct_user", "check_manage_org_admin", "check_view_via_group_member", "lookup_resources_manage_super", "lookup_resources_view_regular"}
+	scenarios := []string{"check_manage_direct_user", "check_manage_org_admin", "check_view_via_group_member", "check_time_bounded_direct_user", "check_bulk_manage_direct_user", "lookup_resources_manage_super", "lookup_resources_view_regular"}
+	for _, optional := range []string{"lookup_resources_manage_super_count", "lookup_resources_view_regular_count", "lookup_resources_manage_mix", "lookup_resources_view_mix", "lookup_resources_manage_pct", "lookup_resources_view_pct", "check_view_viral_direct_user", "lookup_resources_view_viral", "check_view_worst_case", "list_recent_viewable", "list_my_groups_direct", "list_my_groups_effective", "count_access_summary", "write_grant", "write_revoke", "read_relationships_relation", "read_relationships_subject_type", "read_relationships_subject_id", "export_full_acl"} {
+		if scenarioLogged(metrics, optional) {
+			scenarios = append(scenarios, optional)
+		}
+	}
+	scenarios = append(scenarios, customScenarios(metrics)...)
+	if h2h != nil {
+		orderEngines = h2h.engines[:]
+	}
+
+	printConsistency(consistency, orderEngines)
+	printSchemas(schemas, orderEngines)
+	printLimits(limits, orderEngines)
+	printConfigs(configs, orderEngines)
 
 	for _, scenario := range scenarios {
 		fmt.Printf("\n## Scenario: %s\n", scenario)
-		fmt.Println("| Backend | Runs | Samples/Run | Mean (ms) | p95 (ms) | Min (ms) | Max (ms) | Iterations (cfg) | LastCount/Resources | Notes |")
-		fmt.Println("|---------|------|-------------|-----------|----------|----------|----------|------------------|----------------------|-------|")
+		fmt.Println("| Backend | Runs | Samples/Run | Mean (ms) | ±95% CI (ms) | p95 (ms) | Min (ms) | Max (ms) | Iterations (cfg) | LastCount/Resources | Notes |")
+		fmt.Println("|---------|------|-------------|-----------|--------------|----------|----------|----------|------------------|----------------------|-------|")
 		for _, engine := range orderEngines {
 			key := key(engine, scenario)
 			sm := metrics[key]
 			if sm == nil || len(sm.DurationsMs) == 0 {
 				continue
 			}
-			fmt.Printf("| %s | %d | %d | %s | %s | %s | %s | %d | %d | samples aggregated across runs |\n", sm.Engine, sm.Runs, sm.SamplesPerRun, fmtMs(sm.MeanMs), fmtMs(sm.P95Ms), fmtMs(sm.MinMs), fmtMs(sm.MaxMs), sm.IterationsCfg, sm.LastCount)
+			notes := "samples aggregated across runs; CI over run means"
+			if len(runMeans(sm)) < 2 {
+				notes = "single run; CI over samples"
+			}
+			if n := sm.ErrorClasses["timeout"]; n > 0 {
+				notes += fmt.Sprintf("; %d timeouts excluded", n)
+			}
+			if n := sm.ErrorClasses["shed"]; n > 0 {
+				notes += fmt.Sprintf("; %d shed by the server", n)
+			}
+			fmt.Printf("| %s | %d | %d | %s | %s | %s | %s | %s | %d | %d | %s |\n", sm.Engine, sm.Runs, sm.SamplesPerRun, fmtMs(sm.MeanMs), fmtMs(sm.CIMs), fmtMs(sm.P95Ms), fmtMs(sm.MinMs), fmtMs(sm.MaxMs), sm.IterationsCfg, sm.LastCount, notes)
+		}
+		printErrorTable(metrics, orderEngines, scenario)
+	}
+	printShedding(shedding, orderEngines)
+	printLookupMix(mixes, orderEngines)
+	printPercentileLookups(percentiles, orderEngines)
+	printLookupFit(metrics, orderEngines)
+	printStreams(streams, orderEngines)
+	printVisibility(visibility, orderEngines)
+	printThroughput(throughput, orderEngines)
+	printWorkloadOps(workloadOps, orderEngines)
+	oracleOK := printOracle(oracles, orderEngines)
+	printSlowest(slow, orderEngines)
+	printSweep(sw, orderEngines, scenarios)
+	printCachePhases(cache, orderEngines, scenarios)
+	printMaintenancePhases(maint, orderEngines, scenarios)
+	printHeadToHead(h2h, scenarios)
+	printRunComparison(os.Getenv("COMPARE_LOG"), metrics, orderEngines, scenarios)
+	printSLOs(os.Getenv("SLO_FILE"), metrics, orderEngines, scenarios)
+	printEscalation(escalations, orderEngines)
+	printDriverOverhead(drivers)
+	printMiddlewareOverhead(middleware)
+	printLayouts(layouts, "sort_keys", "ClickHouse sort keys", "current")
+	printLayouts(layouts, "index_compare", "ScyllaDB indexes vs tables", "tables")
+	printLayouts(layouts, "group_resolution", "MongoDB nested group resolution", "direct")
+	printLayouts(layouts, "group_closure", "SQL nested group resolution", "view")
+	printLayouts(layouts, "group_churn", "Group closure maintenance per burst", "1")
+	printLayouts(layouts, "bitmaps", "Permission bitmaps vs normalized tables", "normalized")
+	printLayouts(layouts, "offboard", "User offboarding (rows of verify: grants left)", "delete")
+	printLayouts(layouts, "permission_refresh", "Postgres permission refresh: incremental vs full", "full")
+	printLayouts(layouts, "check_batching", "Postgres check batching (latency per check)", "statement")
+	printLayouts(layouts, "permissions", "Derived permissions (rows of check: allowed)", "manage")
+	printLayouts(layouts, "denials", "Denials (rows of check: allowed)", "view_granted")
+	printLayoutSizes(layoutSizes)
+	printStatements(statements)
+	printCosts(costs, orderEngines)
+	if !verifyLookupCounts(metrics, orderEngines, scenarios) || !oracleOK {
+		os.Exit(2)
+	}
+}
+
+// verifyLookupCounts prints each lookup scenario's lastCount per engine next to
+// the count most engines agree on, and reports whether all are within
+// LOOKUP_COUNT_TOLERANCE of it. Drift here means a backend answers a different
+// question (truncated results, a diverging data model), not that it is slow.
+func verifyLookupCounts(metrics map[string]*ScenarioMetrics, engines, scenarios []string) bool {
+	tolerance := 0.0
+	if v := os.Getenv("LOOKUP_COUNT_TOLERANCE"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 {
+			fmt.Fprintf(os.Stderr, "invalid LOOKUP_COUNT_TOLERANCE %q\n", v)
+			os.Exit(1)
+		}
+		tolerance = t
+	}
+
+	ok := true
+	header := false
+	for _, scenario := range scenarios {
+		if !strings.HasPrefix(scenario, "lookup_resources_") {
+			continue
+		}
+		var found []*ScenarioMetrics
+		votes := map[int]int{}
+		for _, engine := range engines {
+			if sm := metrics[key(engine, scenario)]; sm != nil && len(sm.DurationsMs) > 0 {
+				found = append(found, sm)
+				votes[sm.LastCount]++
+			}
+		}
+		if len(found) < 2 {
+			continue
+		}
+		// Majority count; ties go to the engine listed first.
+		ref := found[0].LastCount
+		for _, sm := range found {
+			if votes[sm.LastCount] > votes[ref] {
+				ref = sm.LastCount
+			}
+		}
+
+		if !header {
+			fmt.Printf("\n## Lookup count verification (tolerance %.2f%%)\n", tolerance*100)
+			fmt.Println("| Scenario | Backend | LastCount | Majority | Diff | Status |")
+			fmt.Println("|----------|---------|-----------|----------|------|--------|")
+			header = true
+		}
+		for _, sm := range found {
+			diff := sm.LastCount - ref
+			status := "ok"
+			if drift := float64(abs(diff)); (ref == 0 && drift > 0) || (ref > 0 && drift/float64(ref) > tolerance) {
+				status = "MISMATCH"
+				ok = false
+			}
+			fmt.Printf("| %s | %s | %d | %d | %+d | %s |\n", scenario, sm.Engine, sm.LastCount, ref, diff, status)
+		}
+	}
+	return ok
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// printDriverOverhead lists the database/sql vs native driver comparisons in
+// log order; overhead is relative to the first driver of each query.
+func printDriverOverhead(rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Println("\n## Driver overhead")
+	fmt.Println("| Backend | Query | Driver | Iters | Avg | p50 | p95 | p99 | Overhead avg | Overhead p99 |")
+	fmt.Println("|---------|-------|--------|-------|-----|-----|-----|-----|--------------|--------------|")
+	for _, r := range rows {
+		oavg, op99 := r[8], r[9]
+		if oavg == "" {
+			oavg, op99 = "baseline", "baseline"
+		}
+		fmt.Printf("| %s | %s | %s | %s | %s | %s | %s | %s | %s | %s |\n", r[0], r[1], r[2], r[3], r[4], r[5], r[6], r[7], oavg, op99)
+	}
+}
+
+// printMiddlewareOverhead lists, per backend, a resource fetch alone and with
+// a view check in front of it, and what the check adds.
+func printMiddlewareOverhead(rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Println("\n## Middleware overhead")
+	fmt.Println("| Backend | Variant | Iters | Avg | p50 | p99 | Overhead avg | Overhead p99 | Overhead % |")
+	fmt.Println("|---------|---------|-------|-----|-----|-----|--------------|--------------|------------|")
+	for _, r := range rows {
+		oavg, op99, opct := r[6], r[7], r[8]
+		if oavg == "" {
+			oavg, op99, opct = "baseline", "baseline", "baseline"
+		}
+		fmt.Printf("| %s | %s | %s | %s | %s | %s | %s | %s | %s |\n", r[0], r[1], r[2], r[3], r[4], r[5], oavg, op99, opct)
+	}
+}
+
+// printLayouts lists the results of a layout experiment (`clickhouse
+// sort-keys`, `scylladb index-compare`, ...) per query, with each variant's
+// mean relative to the engine's baseline variant when that was run.
+func printLayouts(rows [][]string, experiment, title, baseline string) {
+	var own [][]string
+	engines := map[string]bool{}
+	for _, r := range rows {
+		if r[1] == experiment {
+			own = append(own, r)
+			engines[r[0]] = true
+		}
+	}
+	if len(own) == 0 {
+		return
+	}
+	base := map[string]time.Duration{} // engine|query -> baseline avg
+	for _, r := range own {
+		if r[2] == baseline {
+			base[r[0]+"|"+r[3]], _ = time.ParseDuration(r[6])
+		}
+	}
+	sort.SliceStable(own, func(i, j int) bool {
+		if own[i][0] != own[j][0] {
+			return own[i][0] < own[j][0]
+		}
+		return own[i][3] < own[j][3]
+	})
+	fmt.Printf("\n## %s\n", title)
+	fmt.Printf("| Query | Variant | Iters | Rows | Avg | p50 | p95 | p99 | Avg vs %s |\n", baseline)
+	fmt.Printf("|-------|---------|-------|------|-----|-----|-----|-----|%s|\n", strings.Repeat("-", len(baseline)+8))
+	for _, r := range own {
+		ratio := "-"
+		avg, err := time.ParseDuration(r[6])
+		if b := base[r[0]+"|"+r[3]]; err == nil && b > 0 {
+			ratio = fmt.Sprintf("%.2fx", float64(avg)/float64(b))
+		}
+		query := r[3]
+		if len(engines) > 1 {
+			// experiments run on several engines (group_closure, group_churn, bitmaps, offboard, permissions, denials) name the engine
+			query = r[0] + " " + query
+		}
+		fmt.Printf("| %s | %s | %s | %s | %s | %s | %s | %s | %s |\n", query, r[2], r[4], r[5], r[6], r[7], r[8], r[9], ratio)
+	}
+}
+
+// printStreams lists the STREAM lines of the SpiceDB lookups per backend and
+// scenario: time to first result and inter-item gaps next to each other, with
+// the gap histogram, since a caller that needs only the first page waits for
+// the first result, not for the stream.
+func printStreams(rows [][]string, engines []string) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Println("\n## Lookup streaming")
+	fmt.Println("| Backend | Scenario | Streams | First avg | First p50 | First p95 | First p99 | Gaps | Gap avg | Gap p50 | Gap p95 | Gap p99 | Gap histogram |")
+	fmt.Println("|---------|----------|---------|-----------|-----------|-----------|-----------|------|---------|---------|---------|---------|---------------|")
+	for _, engine := range engines {
+		for _, r := range rows {
+			if r[0] == engine {
+				fmt.Printf("| %s |\n", strings.Join(r, " | "))
+			}
+		}
+	}
+}
+
+// printVisibility lists the VISIBILITY lines of the SpiceDB write scenarios:
+// the delay from WriteRelationships returning to the change arriving on the
+// Watch API, which bounds how fast a watch-driven cache can be invalidated.
+func printVisibility(rows [][]string, engines []string) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Println("\n## Watch visibility")
+	fmt.Println("| Backend | Scenario | Writes | Seen | Missed | Early | Avg | p50 | p95 | p99 |")
+	fmt.Println("|---------|----------|--------|------|--------|-------|-----|-----|-----|-----|")
+	for _, engine := range engines {
+		for _, r := range rows {
+			if r[0] == engine {
+				fmt.Printf("| %s |\n", strings.Join(r, " | "))
+			}
+		}
+	}
+}
+
+// printShedding lists the SHEDDING lines: per backend and scenario how often
+// and when (seconds into the scenario) the server pushed back instead of
+// answering.
+func printShedding(rows [][]string, engines []string) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Println("\n## Load shedding")
+	fmt.Println("| Backend | Scenario | Events | First | Last | Events per second |")
+	fmt.Println("|---------|----------|--------|-------|------|-------------------|")
+	for _, engine := range engines {
+		for _, r := range rows {
+			if r[0] == engine {
+				fmt.Printf("| %s |\n", strings.Join(r, " | "))
+			}
+		}
+	}
+}
+
+// printThroughput lists the THROUGHPUT lines of the SpiceDB
+// read_relationships_* scenarios and of export_full_acl: the tuples or ACL
+// rows streamed per second with each ReadRelationships filter or full
+// export, the rate bulk sync tooling can expect.
+func printThroughput(rows [][]string, engines []string) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Println("\n## Relationship reads")
+	fmt.Println("| Backend | Scenario | Tuples | Total | Tuples/s |")
+	fmt.Println("|---------|----------|--------|-------|----------|")
+	for _, engine := range engines {
+		for _, r := range rows {
+			if r[0] == engine {
+				fmt.Printf("| %s |\n", strings.Join(r, " | "))
+			}
+		}
+	}
+}
+
+// printWorkloadOps lists the OP lines of the BENCH_WORKLOAD blended
+// workloads: each operation's share of the mix and its latency within it,
+// next to the blended workload_* row of the scenario table.
+func printWorkloadOps(rows [][]string, engines []string) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Println("\n## Workload mix")
+	fmt.Println("| Backend | Workload | Op | Share | Calls | Avg | p50 | p95 | p99 |")
+	fmt.Println("|---------|----------|----|-------|-------|-----|-----|-----|-----|")
+	for _, engine := range engines {
+		for _, r := range rows {
+			if r[0] == engine {
+				fmt.Printf("| %s |\n", strings.Join(r, " | "))
+			}
+		}
+	}
+}
+
+// printSlowest lists the SLOW lines of BENCH_SLOWEST_N: per backend and
+// scenario, the slowest calls with the parameters to replay them.
+func printSlowest(rows [][]string, engines []string) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Println("\n## Slowest calls")
+	fmt.Println("| Backend | Scenario | Rank | Latency | Kind | Resource | User | Permission | Result | Time |")
+	fmt.Println("|---------|----------|------|---------|------|----------|------|------------|--------|------|")
+	for _, engine := range engines {
+		for _, r := range rows {
+			if r[0] == engine {
+				fmt.Printf("| %s |\n", strings.Join(r, " | "))
+			}
+		}
+	}
+}
+
+// printOracle lists the ORACLE lines of BENCH_ORACLE_URL runs: per backend
+// and scenario, how many of the timed checks the oracle agreed with. Any
+// disagreement is flagged MISMATCH, since the latency of wrong answers is not
+// comparable, and makes it return false.
+func printOracle(rows [][]string, engines []string) bool {
+	if len(rows) == 0 {
+		return true
+	}
+	ok := true
+	fmt.Println("\n## Oracle verification")
+	fmt.Println("| Backend | Scenario | Checks | Agree | Disagree | Oracle errors | Skipped | Accuracy | |")
+	fmt.Println("|---------|----------|--------|-------|----------|---------------|---------|----------|-|")
+	for _, engine := range engines {
+		for _, r := range rows {
+			if r[0] != engine {
+				continue
+			}
+			flag := ""
+			if r[4] != "0" {
+				flag = "MISMATCH"
+				ok = false
+			}
+			fmt.Printf("| %s | %s |\n", strings.Join(r, " | "), flag)
+		}
+	}
+	return ok
+}
+
+// printLayoutSizes lists the table and index view sizes `scylladb
+// index-compare` logged per variant, the storage side of its trade-off.
+func printLayoutSizes(rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Println("\n## ScyllaDB index storage")
+	fmt.Println("| Variant | Table | Estimated size |")
+	fmt.Println("|---------|-------|----------------|")
+	for _, r := range rows {
+		fmt.Printf("| %s | %s | %s |\n", r[2], r[3], r[4])
+	}
+}
+
+// printStatements lists the server-side statement stats (pg_stat_statements,
+// CockroachDB SQL stats) captured with BENCH_STATEMENT_STATS=true, so server
+// cost can be read against the client-observed latencies above.
+func printStatements(rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Println("\n## Server-side statements")
+	fmt.Println("| Backend | Calls | Mean | Total | IO | Query |")
+	fmt.Println("|---------|-------|------|-------|----|-------|")
+	for _, r := range rows {
+		query := r[5]
+		if len(query) > 120 {
+			query = query[:117] + "..."
+		}
+		io := strings.ReplaceAll(r[4], ",", " ")
+		fmt.Printf("| %s | %s | %s | %s | %s | `%s` |\n", r[0], r[1], r[2], r[3], io, strings.ReplaceAll(query, "|", "\\|"))
+	}
+}
+
+// printCosts prints the server-side cost per query of each scenario from the
+// COST lines of BENCH_COST, summed over runs and cache phases. The counters
+// are the backend's own (rows and bytes read, buffer hits, docs examined), so
+// compare them within a backend across scenarios and schema variants, and
+// across backends only where the names match.
+func printCosts(rows [][]string, engines []string) {
+	if len(rows) == 0 {
+		return
+	}
+	type cost struct {
+		unit    string // the first counter: queries, statements, commands, ...
+		queries float64
+		names   []string
+		totals  map[string]float64
+	}
+	costs := map[string]*cost{}
+	var order []string
+	for _, r := range rows {
+		k := key(r[0], r[1])
+		c := costs[k]
+		for i, field := range strings.Fields(r[2]) {
+			name, value, _ := strings.Cut(field, "=")
+			total, _, _ := strings.Cut(value, "/")
+			v, err := strconv.ParseFloat(total, 64)
+			if err != nil {
+				continue
+			}
+			if i == 0 {
+				if c == nil {
+					c = &cost{unit: name, totals: map[string]float64{}}
+					costs[k] = c
+					order = append(order, k)
+				}
+				c.queries += v
+				continue
+			}
+			if c == nil {
+				break
+			}
+			if _, ok := c.totals[name]; !ok {
+				c.names = append(c.names, name)
+			}
+			c.totals[name] += v
+		}
+	}
+
+	fmt.Println("\n## Server-side cost per query")
+	fmt.Println("| Backend | Scenario | Queries | Per query |")
+	fmt.Println("|---------|----------|---------|-----------|")
+	for _, engine := range engines {
+		for _, k := range order {
+			c := costs[k]
+			eng, scenario, _ := strings.Cut(k, "|")
+			if eng != engine {
+				continue
+			}
+			per := make([]string, len(c.names))
+			for i, name := range c.names {
+				v := 0.0
+				if c.queries > 0 {
+					v = c.totals[name] / c.queries
+				}
+				per[i] = fmt.Sprintf("%s=%.1f", name, v)
+			}
+			fmt.Printf("| %s | %s | %.0f %s | %s |\n", eng, scenario, c.queries, c.unit, strings.Join(per, " "))
+		}
+	}
+}
+
+// printErrorTable prints per-backend error rates for a scenario, if any backend
+// reported an ERRORS line for it.
+func printErrorTable(metrics map[string]*ScenarioMetrics, engines []string, scenario string) {
+	header := false
+	for _, engine := range engines {
+		sm := metrics[key(engine, scenario)]
+		if sm == nil || (sm.Attempts == 0 && sm.Errors == 0) {
+			continue
+		}
+		if !header {
+			fmt.Printf("\n### Errors: %s\n", scenario)
+			fmt.Println("| Backend | Attempts | Errors | Rate | timeout | unavailable | shed | deadlock | not-found | other |")
+			fmt.Println("|---------|----------|--------|------|---------|-------------|------|----------|-----------|-------|")
+			header = true
+		}
+		rate := 0.0
+		if sm.Attempts > 0 {
+			rate = float64(sm.Errors) / float64(sm.Attempts)
+		}
+		fmt.Printf("| %s | %d | %d | %.4f", sm.Engine, sm.Attempts, sm.Errors, rate)
+		for _, class := range errorClasses {
+			fmt.Printf(" | %d", sm.ErrorClasses[class])
+		}
+		fmt.Println(" |")
+	}
+}
+
+// printConsistency lists the read consistency settings each backend reported,
+// so results at relaxed consistency are not mistaken for strong ones.
+func printConsistency(consistency map[string][]string, engines []string) {
+	if len(consistency) == 0 {
+		return
+	}
+	fmt.Println("\n## Consistency")
+	fmt.Println("| Backend | Settings |")
+	fmt.Println("|---------|----------|")
+	for _, engine := range engines {
+		if settings, ok := consistency[engine]; ok {
+			fmt.Printf("| %s | %s |\n", engine, strings.Join(settings, "; "))
+		}
+	}
+}
+
+// printLimits lists the CPU limits of the benchmark client per backend, so
+// runs from differently sized machines or containers are not compared as
+// equals.
+func printLimits(limits map[string][]string, engines []string) {
+	if len(limits) == 0 {
+		return
+	}
+	fmt.Println("\n## Runner limits")
+	fmt.Println("| Backend | Limits |")
+	fmt.Println("|---------|--------|")
+	for _, engine := range engines {
+		if l, ok := limits[engine]; ok {
+			fmt.Printf("| %s | %s |\n", engine, strings.Join(l, "; "))
+		}
+	}
+}
+
+// scenarioConfig is the part of a CONFIG line (utils.ScenarioConfig) the
+// Configuration table shows; the env vars stay in the log.
+type scenarioConfig struct {
+	Engine      string   `json:"engine"`
+	Scenario    string   `json:"scenario"`
+	Iterations  int      `json:"iterations"`
+	Timeout     string   `json:"timeout"`
+	Users       []string `json:"users"`
+	Consistency string   `json:"consistency"`
+	Concurrency int      `json:"concurrency"`
+	Seed        int64    `json:"seed"`
+	GoMaxProcs  int      `json:"gomaxprocs"`
+}
+
+func (c scenarioConfig) same(o scenarioConfig) bool {
+	return c.Engine == o.Engine && c.Scenario == o.Scenario && c.Iterations == o.Iterations &&
+		c.Timeout == o.Timeout && slices.Equal(c.Users, o.Users) && c.Consistency == o.Consistency &&
+		c.Concurrency == o.Concurrency && c.Seed == o.Seed && c.GoMaxProcs == o.GoMaxProcs
+}
+
+// printConfigs lists the distinct effective configurations each backend ran
+// its scenarios with, so runs that differ in more than the backend show it.
+// The full record, env vars included, is the scenario's CONFIG line in the log.
+func printConfigs(configs []scenarioConfig, engines []string) {
+	if len(configs) == 0 {
+		return
+	}
+	fmt.Println("\n## Configuration")
+	fmt.Println("| Backend | Scenario | Iterations | Timeout | Users | Consistency | Concurrency | Seed | GOMAXPROCS |")
+	fmt.Println("|---------|----------|------------|---------|-------|-------------|-------------|------|------------|")
+	for _, engine := range engines {
+		for _, c := range configs {
+			if c.Engine != engine {
+				continue
+			}
+			users := strings.Join(c.Users, ", ")
+			if len(c.Users) > 3 {
+				users = fmt.Sprintf("%s (+%d)", strings.Join(c.Users[:3], ", "), len(c.Users)-3)
+			}
+			fmt.Printf("| %s | %s | %d | %s | %s | %s | %d | %d | %d |\n",
+				c.Engine, c.Scenario, c.Iterations, c.Timeout, users, c.Consistency, c.Concurrency, c.Seed, c.GoMaxProcs)
+		}
+	}
+}
+
+// printSchemas lists the schema each backend ran against (SpiceDB variant and
+// hash, Postgres resource_acl partitioning), so results of different models
+// or layouts are not compared as equals.
+func printSchemas(schemas map[string][]string, engines []string) {
+	if len(schemas) == 0 {
+		return
+	}
+	fmt.Println("\n## Schema")
+	fmt.Println("| Backend | Schema |")
+	fmt.Println("|---------|--------|")
+	for _, engine := range engines {
+		if s, ok := schemas[engine]; ok {
+			fmt.Printf("| %s | %s |\n", engine, strings.Join(s, "; "))
+		}
+	}
+}
+
+// scenarioLogged reports whether any engine logged scenario.
+func scenarioLogged(metrics map[string]*ScenarioMetrics, scenario string) bool {
+	for _, sm := range metrics {
+		if sm.Scenario == scenario {
+			return true
+		}
+	}
+	return false
+}
+
+// printLookupMix reports the lookup user-mix scenarios (BENCH_LOOKUPRES_MIX_USERS)
+// as latency by result-set size: each user's MIX line falls into a bucket by
+// its resource count (0, 1-9, 10-99, ...), and the bucket shows the mean of
+// the users' average latencies and the worst p99.
+func printLookupMix(rows [][]string, engines []string) {
+	if len(rows) == 0 {
+		return
+	}
+	type bucket struct {
+		users  int
+		avgSum float64
+		maxP99 float64
+	}
+	// buckets[engine|scenario][size exponent]
+	buckets := map[string]map[int]*bucket{}
+	for _, r := range rows {
+		engine, scenario, count := r[0], r[1], atoi(r[3])
+		exp := -1
+		for n := count; n > 0; n /= 10 {
+			exp++
+		}
+		k := key(engine, scenario)
+		if buckets[k] == nil {
+			buckets[k] = map[int]*bucket{}
+		}
+		b := buckets[k][exp]
+		if b == nil {
+			b = &bucket{}
+			buckets[k][exp] = b
+		}
+		b.users++
+		b.avgSum += durMs(r[4])
+		b.maxP99 = math.Max(b.maxP99, durMs(r[6]))
+	}
+
+	fmt.Println("\n## Lookup latency by result-set size")
+	fmt.Println("| Backend | Scenario | Resources | Users | Mean avg (ms) | Max p99 (ms) |")
+	fmt.Println("|---------|----------|-----------|-------|---------------|--------------|")
+	for _, scenario := range []string{"lookup_resources_manage_mix", "lookup_resources_view_mix"} {
+		for _, engine := range engines {
+			bs := buckets[key(engine, scenario)]
+			exps := make([]int, 0, len(bs))
+			for exp := range bs {
+				exps = append(exps, exp)
+			}
+			sort.Ints(exps)
+			for _, exp := range exps {
+				b := bs[exp]
+				size := "0"
+				if exp >= 0 {
+					lo := int(math.Pow10(exp))
+					size = fmt.Sprintf("%d-%d", lo, lo*10-1)
+				}
+				fmt.Printf("| %s | %s | %s | %d | %s | %s |\n", engine, scenario, size, b.users, fmtMs(b.avgSum/float64(b.users)), fmtMs(b.maxP99))
+			}
+		}
+	}
+}
+
+// printPercentileLookups reports the percentile-user lookups
+// (BENCH_LOOKUPRES_*_PCT_USERS): per scenario and percentile of the grant
+// counts, each backend's mean of the user's average latency over the runs
+// and its result size, so backends compare per user weight rather than for
+// one heavy and one regular user.
+func printPercentileLookups(rows [][]string, engines []string) {
+	if len(rows) == 0 {
+		return
+	}
+	type cell struct {
+		runs   int
+		avgSum float64
+		maxP99 float64
+		count  int
+	}
+	cells := map[string]*cell{} // engine|scenario|pct
+	pcts := map[string][]int{}  // scenario -> percentiles seen
+	for _, r := range rows {
+		engine, scenario, pct := r[0], r[1], atoi(r[2])
+		k := key(engine, scenario) + "|" + r[2]
+		c := cells[k]
+		if c == nil {
+			c = &cell{}
+			cells[k] = c
+			if !slices.Contains(pcts[scenario], pct) {
+				pcts[scenario] = append(pcts[scenario], pct)
+			}
+		}
+		c.runs++
+		c.avgSum += durMs(r[4])
+		c.maxP99 = math.Max(c.maxP99, durMs(r[5]))
+		c.count = atoi(r[3])
+	}
+
+	fmt.Println("\n## Lookup latency by user percentile")
+	fmt.Println("Mean avg (ms) / max p99 (ms) / resources of the user at each percentile of the direct grant counts.")
+	fmt.Printf("| Scenario | Percentile | %s |\n", strings.Join(engines, " | "))
+	fmt.Printf("|----------|------------|%s\n", strings.Repeat("---|", len(engines)))
+	for _, scenario := range []string{"lookup_resources_manage_pct", "lookup_resources_view_pct"} {
+		slices.Sort(pcts[scenario])
+		for _, pct := range pcts[scenario] {
+			out := make([]string, len(engines))
+			for i, engine := range engines {
+				if c := cells[key(engine, scenario)+"|"+strconv.Itoa(pct)]; c != nil {
+					out[i] = fmt.Sprintf("%s / %s / %d", fmtMs(c.avgSum/float64(c.runs)), fmtMs(c.maxP99), c.count)
+				}
+			}
+			fmt.Printf("| %s | p%d | %s |\n", scenario, pct, strings.Join(out, " | "))
+		}
+	}
+}
+
+// printEscalation reports org_admin_escalation (BENCH_ESCALATION_CYCLES) per
+// backend and role the user was switched to: how many cached lookups still
+// returned the count from before the switch, and the mean cached lookup, fresh
+// lookup and write latencies.
+func printEscalation(rows [][]string, engines []string) {
+	if len(rows) == 0 {
+		return
+	}
+	type agg struct {
+		cycles, stale        int
+		cached, fresh, write float64
+		lastFresh            string
+	}
+	byKey := map[string]*agg{}
+	for _, r := range rows {
+		k := key(r[0], r[1])
+		a := byKey[k]
+		if a == nil {
+			a = &agg{}
+			byKey[k] = a
+		}
+		a.cycles++
+		if r[5] == "true" {
+			a.stale++
+		}
+		a.cached += durMs(r[2])
+		a.fresh += durMs(r[4])
+		a.write += durMs(r[6])
+		a.lastFresh = r[3]
+	}
+
+	fmt.Println("\n## Org-admin escalation")
+	fmt.Println("| Backend | Role | Cycles | Stale cached | Cached lookup (ms) | Fresh lookup (ms) | Write (ms) | Fresh resources |")
+	fmt.Println("|---------|------|--------|--------------|--------------------|-------------------|------------|-----------------|")
+	for _, engine := range engines {
+		for _, role := range []string{"admin", "member"} {
+			a := byKey[key(engine, role)]
+			if a == nil {
+				continue
+			}
+			n := float64(a.cycles)
+			fmt.Printf("| %s | %s | %d | %d | %s | %s | %s | %s |\n", engine, role, a.cycles, a.stale, fmtMs(a.cached/n), fmtMs(a.fresh/n), fmtMs(a.write/n), a.lastFresh)
+		}
+	}
+}
+
+// printLookupFit pools every lookup_resources_* iteration of an engine and
+// fits duration = fixed + perRow*resources by least squares, so engines with
+// a high fixed cost can be told apart from engines with a high per-row cost.
+// Per-resource latency is total duration over total resources returned. The
+// fit needs at least two distinct result sizes (e.g. BENCH_LOOKUPRES_MIX_USERS);
+// otherwise only per-resource latency is shown.
+func printLookupFit(metrics map[string]*ScenarioMetrics, engines []string) {
+	var rows []string
+	for _, engine := range engines {
+		var xs, ys []float64
+		for _, sm := range metrics {
+			if sm.Engine != engine || len(sm.Counts) != len(sm.DurationsMs) {
+				continue
+			}
+			for i, c := range sm.Counts {
+				xs = append(xs, float64(c))
+				ys = append(ys, sm.DurationsMs[i])
+			}
+		}
+		if len(xs) == 0 {
+			continue
+		}
+		var sumX, sumY float64
+		for i := range xs {
+			sumX += xs[i]
+			sumY += ys[i]
+		}
+		perRes := "n/a"
+		if sumX > 0 {
+			perRes = fmtMs(sumY / sumX * 1000)
+		}
+		fixed, perRow, r2 := "n/a", "n/a", "n/a"
+		if a, b, r, ok := linearFit(xs, ys); ok {
+			fixed, perRow, r2 = fmtMs(a), fmtMs(b*1000), fmt.Sprintf("%.3f", r)
+		}
+		rows = append(rows, fmt.Sprintf("| %s | %d | %s | %s | %s | %s |", engine, len(xs), perRes, fixed, perRow, r2))
+	}
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Println("\n## Lookup latency vs result size")
+	fmt.Println("| Backend | Samples | Per resource (µs) | Fixed cost (ms) | Per row (µs) | R² |")
+	fmt.Println("|---------|---------|-------------------|-----------------|--------------|----|")
+	for _, r := range rows {
+		fmt.Println(r)
+	}
+}
+
+// linearFit is the least-squares fit y = a + b*x with its R². ok is false
+// when x does not vary.
+func linearFit(xs, ys []float64) (a, b, r2 float64, ok bool) {
+	n := float64(len(xs))
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx, my = mx/n, my/n
+	var sxx, sxy, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, 0, 0, false
+	}
+	b = sxy / sxx
+	a = my - b*mx
+	r2 = 1
+	if syy > 0 {
+		r2 = sxy * sxy / (sxx * syy)
+	}
+	return a, b, r2, true
+}
+
+// sweep collects samples per sweep point from a benchmark/4-sweep.sh log, where
+// every point's runs follow a "==== SWEEP: <param>=<value> ====" header.
+type sweep struct {
+	param   string
+	point   int
+	points  []int
+	samples map[string]map[int][]float64 // engine|scenario -> point -> durations (ms)
+}
+
+func (sw *sweep) record(key string, durMs float64) {
+	if sw.param == "" {
+		return
+	}
+	if sw.samples[key] == nil {
+		sw.samples[key] = map[int][]float64{}
+	}
+	sw.samples[key][sw.point] = append(sw.samples[key][sw.point], durMs)
+}
+
+// printSweep lists the mean latency of every scenario and engine at each sweep
+// point, with a bar per point scaled to the row's slowest point and the
+// scaling exponent k of mean ~ point^k (log-log least squares): k near 0 means
+// flat, near 1 linear in the swept parameter.
+func printSweep(sw *sweep, engines, scenarios []string) {
+	if len(sw.points) == 0 {
+		return
+	}
+	sort.Ints(sw.points)
+	bars := []rune("▁▂▃▄▅▆▇█")
+
+	fmt.Printf("\n## Sweep: %s\n", sw.param)
+	header, sep := "| Scenario | Backend |", "|----------|---------|"
+	for _, p := range sw.points {
+		header += fmt.Sprintf(" %d (ms) |", p)
+		sep += "------|"
+	}
+	fmt.Println(header + " Plot | Scaling k |")
+	fmt.Println(sep + "------|-----------|")
+	for _, scenario := range scenarios {
+		for _, engine := range engines {
+			byPoint := sw.samples[key(engine, scenario)]
+			if len(byPoint) == 0 {
+				continue
+			}
+			means := make([]float64, len(sw.points))
+			var peak float64
+			for i, p := range sw.points {
+				means[i] = math.NaN()
+				if ds := byPoint[p]; len(ds) > 0 {
+					var sum float64
+					for _, d := range ds {
+						sum += d
+					}
+					means[i] = sum / float64(len(ds))
+					peak = math.Max(peak, means[i])
+				}
+			}
+			row := fmt.Sprintf("| %s | %s |", scenario, engine)
+			var plot []rune
+			var xs, ys []float64
+			for i, mean := range means {
+				if math.IsNaN(mean) {
+					row += " - |"
+					plot = append(plot, ' ')
+					continue
+				}
+				row += " " + fmtMs(mean) + " |"
+				level := 0
+				if peak > 0 {
+					level = int(mean / peak * float64(len(bars)-1))
+				}
+				plot = append(plot, bars[level])
+				if sw.points[i] > 0 && mean > 0 {
+					xs = append(xs, math.Log(float64(sw.points[i])))
+					ys = append(ys, math.Log(mean))
+				}
+			}
+			k := "n/a"
+			if _, b, _, ok := linearFit(xs, ys); ok {
+				k = fmt.Sprintf("%.2f", b)
+			}
+			fmt.Printf("%s `%s` | %s |\n", row, string(plot), k)
+		}
+	}
+}
+
+// cachePhases collects samples per cache phase from a BENCH_CACHE_COMPARE run,
+// where each engine's scenarios run once cold and once warm, each pass after a
+// "CACHE: phase=<cold|warm>" line.
+type cachePhases struct {
+	phase   string
+	flush   map[string]string               // engine -> flush outcome of its cold pass
+	samples map[string]map[string][]float64 // engine|scenario -> phase -> durations (ms)
+}
+
+func (c *cachePhases) record(key string, durMs float64) {
+	if c.phase == "" {
+		return
+	}
+	if c.samples[key] == nil {
+		c.samples[key] = map[string][]float64{}
+	}
+	c.samples[key][c.phase] = append(c.samples[key][c.phase], durMs)
+}
+
+// printCachePhases lists, per scenario and engine, the first cold sample and
+// the mean of the cold and warm passes, with their ratio: well above 1 means
+// the backend leans on its caches, about 1 means it does not (or that the
+// cold pass was not actually cold, see the Flush column).
+func printCachePhases(c *cachePhases, engines, scenarios []string) {
+	if len(c.samples) == 0 {
+		return
+	}
+	mean := func(ds []float64) float64 {
+		var sum float64
+		for _, d := range ds {
+			sum += d
+		}
+		return sum / float64(len(ds))
+	}
+
+	fmt.Println("\n## Cache: cold vs warm")
+	fmt.Println("| Scenario | Backend | Flush | Cold first (ms) | Cold mean (ms) | Warm mean (ms) | Cold/Warm |")
+	fmt.Println("|----------|---------|-------|-----------------|----------------|----------------|-----------|")
+	for _, scenario := range scenarios {
+		for _, engine := range engines {
+			byPhase := c.samples[key(engine, scenario)]
+			cold, warm := byPhase["cold"], byPhase["warm"]
+			if len(cold) == 0 || len(warm) == 0 {
+				continue
+			}
+			ratio := "n/a"
+			if w := mean(warm); w > 0 {
+				ratio = fmt.Sprintf("%.2f", mean(cold)/w)
+			}
+			flush := c.flush[engine]
+			if flush == "" {
+				flush = "-"
+			}
+			fmt.Printf("| %s | %s | %s | %s | %s | %s | %s |\n", scenario, engine, flush, fmtMs(cold[0]), fmtMs(mean(cold)), fmtMs(mean(warm)), ratio)
+		}
+	}
+}
+
+// maintenancePhases collects samples per maintenance phase from a
+// BENCH_MAINTENANCE run, where each engine's scenarios run once quiet and once
+// under background maintenance, each pass after a
+// "MAINTENANCE: phase=<quiet|maintenance>" line and the second followed by a
+// "MAINTENANCE: DONE" line.
+type maintenancePhases struct {
+	phase   string
+	task    map[string]string               // engine -> maintenance task
+	rounds  map[string]string               // engine -> rounds of its maintenance pass
+	samples map[string]map[string][]float64 // engine|scenario -> phase -> durations (ms)
+}
+
+func (c *maintenancePhases) record(key string, durMs float64) {
+	if c.phase == "" {
+		return
+	}
+	if c.samples[key] == nil {
+		c.samples[key] = map[string][]float64{}
+	}
+	c.samples[key][c.phase] = append(c.samples[key][c.phase], durMs)
+}
+
+// printMaintenancePhases lists, per scenario and engine, the mean and p99 of
+// the quiet pass and of the pass under maintenance, with the ratio of the
+// means: the read latency background maintenance costs. When every round of
+// the Rounds column failed, no maintenance finished during the pass and the
+// comparison says little.
+func printMaintenancePhases(c *maintenancePhases, engines, scenarios []string) {
+	if len(c.samples) == 0 {
+		return
+	}
+	mean := func(ds []float64) float64 {
+		var sum float64
+		for _, d := range ds {
+			sum += d
+		}
+		return sum / float64(len(ds))
+	}
+
+	fmt.Println("\n## Maintenance: quiet vs under maintenance")
+	fmt.Println("| Scenario | Backend | Task | Rounds | Quiet mean (ms) | Quiet p99 (ms) | Maint. mean (ms) | Maint. p99 (ms) | Maint./Quiet |")
+	fmt.Println("|----------|---------|------|--------|-----------------|----------------|------------------|-----------------|--------------|")
+	for _, scenario := range scenarios {
+		for _, engine := range engines {
+			byPhase := c.samples[key(engine, scenario)]
+			quiet, busy := byPhase["quiet"], byPhase["maintenance"]
+			if len(quiet) == 0 || len(busy) == 0 {
+				continue
+			}
+			ratio := "n/a"
+			if q := mean(quiet); q > 0 {
+				ratio = fmt.Sprintf("%.2f", mean(busy)/q)
+			}
+			rounds := c.rounds[engine]
+			if rounds == "" {
+				rounds = "-"
+			}
+			fmt.Printf("| %s | %s | %s | %s | %s | %s | %s | %s | %s |\n", scenario, engine, c.task[engine], rounds,
+				fmtMs(mean(quiet)), fmtMs(percentileMs(quiet, 0.99)), fmtMs(mean(busy)), fmtMs(percentileMs(busy, 0.99)), ratio)
+		}
+	}
+}
+
+// headToHead collects the samples of the two HEAD_TO_HEAD engines per scenario
+// and per read consistency the engine last logged, so runs at several
+// consistency levels in one log are compared level by level.
+type headToHead struct {
+	engines     [2]string
+	consistency map[string]string                          // engine -> current CONSISTENCY settings
+	modes       []string                                   // consistency settings, in log order
+	samples     map[string]map[string]map[string][]float64 // scenario -> consistency -> engine -> durations (ms)
+}
+
+// newHeadToHead parses spec ("a,b"); empty spec disables the comparison.
+func newHeadToHead(spec string) *headToHead {
+	if spec == "" {
+		return nil
+	}
+	a, b, ok := strings.Cut(spec, ",")
+	if !ok || a == "" || b == "" || strings.Contains(b, ",") {
+		fmt.Fprintf(os.Stderr, "invalid HEAD_TO_HEAD %q (expected engine_a,engine_b)\n", spec)
+		os.Exit(1)
+	}
+	return &headToHead{
+		engines:     [2]string{strings.TrimSpace(a), strings.TrimSpace(b)},
+		consistency: map[string]string{},
+		samples:     map[string]map[string]map[string][]float64{},
+	}
+}
+
+func (h *headToHead) record(engine, scenario string, durMs float64) {
+	if h == nil || (engine != h.engines[0] && engine != h.engines[1]) {
+		return
+	}
+	mode := h.consistency[engine]
+	if !slices.Contains(h.modes, mode) {
+		h.modes = append(h.modes, mode)
+	}
+	if h.samples[scenario] == nil {
+		h.samples[scenario] = map[string]map[string][]float64{}
+	}
+	if h.samples[scenario][mode] == nil {
+		h.samples[scenario][mode] = map[string][]float64{}
+	}
+	h.samples[scenario][mode][engine] = append(h.samples[scenario][mode][engine], durMs)
+}
+
+// printHeadToHead lists mean and p95 of both engines per scenario and
+// consistency, the ratio of the second engine's mean to the first's, the 95%
+// CI of the difference of the means (Welch), the Mann-Whitney U p-value of
+// the two samples and the faster engine. Within 5%, or p >= 0.05, is a tie.
+func printHeadToHead(h *headToHead, scenarios []string) {
+	if h == nil {
+		return
+	}
+	a, b := h.engines[0], h.engines[1]
+	stats := func(ds []float64) (mean, p95 float64) {
+		sorted := append([]float64{}, ds...)
+		sort.Float64s(sorted)
+		for _, d := range sorted {
+			mean += d
+		}
+		return mean / float64(len(sorted)), sorted[max(int(0.95*float64(len(sorted)))-1, 0)]
+	}
+
+	fmt.Printf("\n## Head-to-head: %s vs %s\n", a, b)
+	fmt.Printf("| Scenario | Consistency | %s mean (ms) | %s p95 (ms) | %s mean (ms) | %s p95 (ms) | %s/%s | %s-%s 95%% CI (ms) | p (Mann-Whitney) | Faster |\n", a, a, b, b, b, a, b, a)
+	fmt.Println("|----------|-------------|------|------|------|------|------|------|------|--------|")
+	for _, scenario := range scenarios {
+		for _, mode := range h.modes {
+			byEngine := h.samples[scenario][mode]
+			if len(byEngine[a]) == 0 || len(byEngine[b]) == 0 {
+				continue
+			}
+			meanA, p95A := stats(byEngine[a])
+			meanB, p95B := stats(byEngine[b])
+			diff, half := welchCI(byEngine[a], byEngine[b])
+			p := mannWhitneyP(byEngine[a], byEngine[b])
+			ratio, faster := "n/a", "-"
+			if meanA > 0 {
+				r := meanB / meanA
+				ratio = fmt.Sprintf("%.2f", r)
+				switch {
+				case p >= significance:
+					faster = "tie"
+				case r < 0.95:
+					faster = b
+				case r > 1.05:
+					faster = a
+				default:
+					faster = "tie"
+				}
+			}
+			if mode == "" {
+				mode = "-"
+			}
+			fmt.Printf("| %s | %s | %s | %s | %s | %s | %s | %s ± %s | %.4f | %s |\n", scenario, mode, fmtMs(meanA), fmtMs(p95A), fmtMs(meanB), fmtMs(p95B), ratio, fmtSignedMs(diff), fmtMs(half), p, faster)
+		}
+	}
+}
+
+// printRunComparison compares this log with the earlier run in path
+// (COMPARE_LOG) per backend and scenario: both means, the change, the 95% CI
+// of the difference of the means (Welch) and the Mann-Whitney U p-value of
+// the samples. A change is only called faster or slower at p < 0.05.
+func printRunComparison(path string, metrics map[string]*ScenarioMetrics, engines, scenarios []string) {
+	if path == "" {
+		return
+	}
+	baseline, err := readSamples(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "COMPARE_LOG: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\n## Run comparison vs %s\n", path)
+	fmt.Println("| Backend | Scenario | Baseline mean (ms) | Mean (ms) | Change | Diff 95% CI (ms) | p (Mann-Whitney) | Verdict |")
+	fmt.Println("|---------|----------|--------------------|-----------|--------|------------------|------------------|---------|")
+	for _, scenario := range scenarios {
+		for _, engine := range engines {
+			sm := metrics[key(engine, scenario)]
+			before := baseline[key(engine, scenario)]
+			if sm == nil || len(sm.DurationsMs) == 0 || len(before) == 0 {
+				continue
+			}
+			meanBefore, _ := meanCI(before)
+			diff, half := welchCI(before, sm.DurationsMs)
+			p := mannWhitneyP(before, sm.DurationsMs)
+			change, verdict := "n/a", "no significant change"
+			if meanBefore > 0 {
+				change = fmt.Sprintf("%+.1f%%", 100*diff/meanBefore)
+			}
+			if p < significance {
+				verdict = "slower"
+				if diff < 0 {
+					verdict = "faster"
+				}
+			}
+			fmt.Printf("| %s | %s | %s | %s | %s | %s ± %s | %.4f | %s |\n", engine, scenario, fmtMs(meanBefore), fmtMs(sm.MeanMs), change, fmtSignedMs(diff), fmtMs(half), p, verdict)
+		}
+	}
+}
+
+// slo is one line of SLO_FILE: every logged scenario matching pattern
+// (path.Match) must keep metric at or under limit, in ms for the latency
+// metrics and as a fraction of the attempts for error_rate.
+type slo struct {
+	pattern, metric, spec string
+	limit                 float64
+}
+
+// sloMetrics are the metrics an SLO_FILE line can bound.
+var sloMetrics = []string{"mean", "p50", "p95", "p99", "max", "error_rate"}
+
+// readSLOs parses SLO_FILE: one "<scenario pattern> <metric> <limit>" per
+// line, limits as Go durations (20ms) or, for error_rate, a fraction. Blank
+// lines and # comments are skipped.
+func readSLOs(file string) ([]slo, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var slos []slo
+	for i, line := range strings.Split(string(b), "\n") {
+		if j := strings.Index(line, "#"); j >= 0 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: want <scenario pattern> <metric> <limit>, got %q", i+1, line)
+		}
+		s := slo{pattern: fields[0], metric: fields[1], spec: fields[2]}
+		if _, err := path.Match(s.pattern, ""); err != nil {
+			return nil, fmt.Errorf("line %d: pattern %q: %v", i+1, s.pattern, err)
+		}
+		if !slices.Contains(sloMetrics, s.metric) {
+			return nil, fmt.Errorf("line %d: metric %q: want one of %s", i+1, s.metric, strings.Join(sloMetrics, ", "))
+		}
+		if s.metric == "error_rate" {
+			s.limit, err = strconv.ParseFloat(s.spec, 64)
+		} else {
+			var d time.Duration
+			d, err = time.ParseDuration(s.spec)
+			s.limit = float64(d) / float64(time.Millisecond)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: limit %q: %v", i+1, s.spec, err)
+		}
+		slos = append(slos, s)
+	}
+	return slos, nil
+}
+
+// sloValue is metric of sm, and whether sm has it: latencies need samples,
+// error_rate attempts.
+func sloValue(sm *ScenarioMetrics, metric string) (float64, bool) {
+	if metric == "error_rate" {
+		if sm.Attempts == 0 {
+			return 0, false
+		}
+		return float64(sm.Errors) / float64(sm.Attempts), true
+	}
+	if len(sm.DurationsMs) == 0 {
+		return 0, false
+	}
+	switch metric {
+	case "mean":
+		return sm.MeanMs, true
+	case "max":
+		return sm.MaxMs, true
+	case "p50":
+		return percentileMs(sm.DurationsMs, 0.50), true
+	case "p95":
+		return sm.P95Ms, true
+	}
+	return percentileMs(sm.DurationsMs, 0.99), true
+}
+
+// percentileMs is the q-th percentile of samples, picked as the scenario
+// table picks p95.
+func percentileMs(samples []float64, q float64) float64 {
+	sorted := append([]float64{}, samples...)
+	sort.Float64s(sorted)
+	idx := min(max(int(q*float64(len(sorted)))-1, 0), len(sorted)-1)
+	return sorted[idx]
+}
+
+// printSLOs checks every backend against the SLOs of SLO_FILE: a row per SLO
+// and scenario it matches with PASS or FAIL and the measured value per
+// backend, then the decision matrix of how many SLOs each backend met. A
+// backend that did not log a scenario has no verdict for it; the exit status
+// is unaffected.
+func printSLOs(file string, metrics map[string]*ScenarioMetrics, engines, scenarios []string) {
+	if file == "" {
+		return
+	}
+	slos, err := readSLOs(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "SLO_FILE %s: %v\n", file, err)
+		os.Exit(1)
+	}
+	fmt.Printf("\n## SLOs (%s)\n", file)
+	fmt.Printf("| Scenario | SLO | %s |\n", strings.Join(engines, " | "))
+	fmt.Printf("|----------|-----|%s\n", strings.Repeat("---|", len(engines)))
+	passed, failed := map[string]int{}, map[string]int{}
+	for _, s := range slos {
+		for _, scenario := range scenarios {
+			if ok, _ := path.Match(s.pattern, scenario); !ok {
+				continue
+			}
+			cells := make([]string, len(engines))
+			for i, engine := range engines {
+				sm := metrics[key(engine, scenario)]
+				if sm == nil {
+					continue
+				}
+				v, ok := sloValue(sm, s.metric)
+				if !ok {
+					continue
+				}
+				verdict := "PASS"
+				if v > s.limit {
+					verdict = "FAIL"
+					failed[engine]++
+				} else {
+					passed[engine]++
+				}
+				if s.metric == "error_rate" {
+					cells[i] = fmt.Sprintf("%s %.4f", verdict, v)
+				} else {
+					cells[i] = fmt.Sprintf("%s %s", verdict, fmtMs(v))
+				}
+			}
+			fmt.Printf("| %s | %s ≤ %s | %s |\n", scenario, s.metric, s.spec, strings.Join(cells, " | "))
+		}
+	}
+
+	fmt.Println("\n### SLO decision matrix")
+	fmt.Println("| Backend | Passed | Failed | Verdict |")
+	fmt.Println("|---------|--------|--------|---------|")
+	for _, engine := range engines {
+		if passed[engine]+failed[engine] == 0 {
+			continue
+		}
+		verdict := "PASS"
+		if failed[engine] > 0 {
+			verdict = "FAIL"
+		}
+		fmt.Printf("| %s | %d | %d | %s |\n", engine, passed[engine], failed[engine], verdict)
+	}
+}
+
+// readSamples reads the per-iteration durations (ms) of the log at path per
+// engine|scenario, the samples the scenario tables are built from.
+func readSamples(path string) (map[string][]float64, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	samples := map[string][]float64{}
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for _, re := range []*regexp.Regexp{reStreamingLookupSample, reStreamingIterSample, reEnumIter} {
+			if m := re.FindStringSubmatch(line); m != nil {
+				k := key(m[1], m[2])
+				samples[k] = append(samples[k], toMs(m[4], m[5]))
+				break
+			}
+		}
+	}
+	return samples, scanner.Err()
+}
+
+// significance is the p-value below which a difference counts as real.
+const significance = 0.05
+
+// runMeans returns the mean of each run of sm, in run order.
+func runMeans(sm *ScenarioMetrics) []float64 {
+	sums, counts := map[int]float64{}, map[int]int{}
+	var runs []int
+	for i, d := range sm.DurationsMs {
+		r := sm.RunOf[i]
+		if counts[r] == 0 {
+			runs = append(runs, r)
+		}
+		sums[r] += d
+		counts[r]++
+	}
+	means := make([]float64, len(runs))
+	for i, r := range runs {
+		means[i] = sums[r] / float64(counts[r])
+	}
+	return means
+}
+
+// meanCI returns the mean of xs and the half-width of its 95% confidence
+// interval (Student's t); the half-width is 0 below two values.
+func meanCI(xs []float64) (mean, half float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	mean, variance := meanVar(xs)
+	if len(xs) < 2 {
+		return mean, 0
+	}
+	return mean, tCrit95(float64(len(xs)-1)) * math.Sqrt(variance/float64(len(xs)))
+}
+
+// welchCI returns mean(b)-mean(a) and the half-width of its 95% confidence
+// interval by Welch's t-test, which does not assume equal variances.
+func welchCI(a, b []float64) (diff, half float64) {
+	meanA, varA := meanVar(a)
+	meanB, varB := meanVar(b)
+	diff = meanB - meanA
+	if len(a) < 2 || len(b) < 2 {
+		return diff, 0
+	}
+	sa, sb := varA/float64(len(a)), varB/float64(len(b))
+	if sa+sb == 0 {
+		return diff, 0
+	}
+	df := (sa + sb) * (sa + sb) / (sa*sa/float64(len(a)-1) + sb*sb/float64(len(b)-1))
+	return diff, tCrit95(df) * math.Sqrt(sa+sb)
+}
+
+// meanVar returns the mean and sample variance of xs.
+func meanVar(xs []float64) (mean, variance float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	if len(xs) < 2 {
+		return mean, 0
+	}
+	for _, x := range xs {
+		variance += (x - mean) * (x - mean)
+	}
+	return mean, variance / float64(len(xs)-1)
+}
+
+// tTable95 holds the two-sided 95% critical values of Student's t for 1 to
+// 30 degrees of freedom.
+var tTable95 = []float64{12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042}
+
+// tCrit95 returns the two-sided 95% critical value of Student's t with df
+// degrees of freedom: from tTable95 (df rounded down), above 30 by the
+// Cornish-Fisher expansion around the normal quantile.
+func tCrit95(df float64) float64 {
+	if df < 1 {
+		df = 1
+	}
+	if df <= 30 {
+		return tTable95[int(df)-1]
+	}
+	const z = 1.959964
+	return z + (z*z*z+z)/(4*df) + (5*math.Pow(z, 5)+16*z*z*z+3*z)/(96*df*df)
+}
+
+// mannWhitneyP returns the two-sided p-value of the Mann-Whitney U test of a
+// against b: normal approximation with tie and continuity correction, which
+// is fair from about ten samples a side. It is 1 when either side is empty.
+func mannWhitneyP(a, b []float64) float64 {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	type obs struct {
+		v     float64
+		fromA bool
+	}
+	all := make([]obs, 0, len(a)+len(b))
+	for _, v := range a {
+		all = append(all, obs{v, true})
+	}
+	for _, v := range b {
+		all = append(all, obs{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// average ranks over ties
+	rankA, ties := 0.0, 0.0
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // ranks i+1..j
+		for k := i; k < j; k++ {
+			if all[k].fromA {
+				rankA += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+	n := n1 + n2
+	u := rankA - n1*(n1+1)/2
+	mu := n1 * n2 / 2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 {
+		return 1
+	}
+	z := math.Max(math.Abs(u-mu)-0.5, 0) / sigma
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// fmtSignedMs is fmtMs with an explicit sign, for differences.
+func fmtSignedMs(v float64) string {
+	if v < 0 {
+		return "-" + fmtMs(-v)
+	}
+	return "+" + fmtMs(v)
+}
+
+// durMs parses a Go duration string (as logged by time.Duration) into ms.
+func durMs(s string) float64 {
+	d, _ := time.ParseDuration(s)
+	return float64(d) / float64(time.Millisecond)
+}
+
+// customScenarios lists the user-defined (BENCH_CUSTOM_SCENARIOS) scenarios
+// and blended workloads (BENCH_WORKLOAD) found in the log, sorted, to report
+// after the builtin ones.
+func customScenarios(metrics map[string]*ScenarioMetrics) []string {
+	var names []string
+	for _, sm := range metrics {
+		if (strings.HasPrefix(sm.Scenario, "custom_") || strings.HasPrefix(sm.Scenario, "workload_")) && !slices.Contains(names, sm.Scenario) {
+			names = append(names, sm.Scenario)
 		}
 	}
+	sort.Strings(names)
+	return names
 }
 
 func key(engine, scenario string) string { return engine + "|" + scenario }
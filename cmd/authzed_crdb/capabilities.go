@@ -0,0 +1,46 @@
+package authzed_crdb
+
+import "test-tls/utils"
+
+// Capabilities lists what the authzed_crdb module supports, for the capabilities
+// command. Keep it in step with the benchmark scenarios in bechmark_reads.go.
+func Capabilities() utils.Capabilities {
+	return utils.Capabilities{
+		Scenarios: []string{
+			"check_manage_direct_user",
+			"check_manage_org_admin",
+			"check_view_via_group_member",
+			"check_time_bounded_direct_user",
+			"check_bulk_manage_direct_user",
+			"lookup_resources_manage_super",
+			"lookup_resources_view_regular",
+			"lookup_resources_*_mix (BENCH_LOOKUPRES_MIX_USERS)",
+			"lookup_resources_*_pct (BENCH_LOOKUPRES_*_PCT_USERS)",
+			"check_view_viral_direct_user (BENCH_VIRAL_RESOURCES)",
+			"lookup_resources_view_viral (BENCH_LOOKUPRES_VIRAL_USER)",
+			"check_view_worst_case (nested-group-only grants, BENCH_CHECK_WORST_ITER)",
+			"workload_* (blended request mix, BENCH_WORKLOAD)",
+			"list_my_groups_direct, list_my_groups_effective (groups of a user, BENCH_MY_GROUPS_ITER)",
+			"count_access_summary (view and manage counts of a user, BENCH_ACCESS_SUMMARY_ITER)",
+			"permissions (checks and lookups of derived permissions such as edit, delete and share, BENCH_PERMISSION_RULES)",
+			"denials (view with and without the banned_user exclusion of resource_bans.csv, BENCH_DENIALS)",
+			"export_full_acl (full ACL stream, BENCH_EXPORT_ITER)",
+			"custom_* (BENCH_CUSTOM_SCENARIOS)",
+			"org_admin_escalation (BENCH_ESCALATION_CYCLES)",
+			"write_grant / write_revoke (BENCH_WRITE_ITER)",
+			"read_relationships_* (BENCH_READ_RELS_ITER)",
+		},
+		Schemas: schemaCapabilities(),
+		Consistency: []string{
+			"SPICEDB_CONSISTENCY=full|minimize_latency|at_least_as_fresh|zedtoken (timed checks and lookups)",
+			"zedtoken: at_least_as_fresh as the ZedToken saved by the last load or write phase (SPICEDB_ZEDTOKEN_FILE, SPICEDB_ZEDTOKEN_PHASE)",
+			"minimize_latency vs at_least_as_fresh (org_admin_escalation)",
+		},
+		Writes: []string{
+			"org admin role toggle (org_admin_escalation)",
+			"viewer_user grant and revoke (write_grant, write_revoke)",
+			"Watch visibility of the grant and revoke writes (SPICEDB_WATCH)",
+			"user offboarding: DeleteRelationships by subject (offboard, BENCH_OFFBOARD_USERS)",
+		},
+	}
+}
@@ -0,0 +1,53 @@
+package authzed_crdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	authzed "github.com/authzed/authzed-go/v1"
+
+	"test-tls/authz/spicedb"
+	"test-tls/utils"
+)
+
+// runCustomScenarios runs the BENCH_CUSTOM_SCENARIOS scenarios that define a
+// spicedb check; each iteration is one CheckPermission, counted as 1 row when
+// allowed.
+func runCustomScenarios(client *authzed.Client) {
+	utils.RunCustomScenarios("authzed_crdb", func(ctx context.Context, sc utils.CustomScenario, row utils.CustomRow) (int, error) {
+		resource, err := objectRef(row.Expand(sc.SpiceDB.Resource))
+		if err != nil {
+			return 0, err
+		}
+		subject, relation, _ := strings.Cut(row.Expand(sc.SpiceDB.Subject), "#")
+		subjectRef, err := objectRef(subject)
+		if err != nil {
+			return 0, err
+		}
+		resp, err := client.CheckPermission(ctx, &v1.CheckPermissionRequest{
+			Resource:    resource,
+			Permission:  sc.SpiceDB.Permission,
+			Subject:     &v1.SubjectReference{Object: subjectRef, OptionalRelation: relation},
+			Consistency: benchConsistency,
+			Context:     spicedb.NowContext(),
+		})
+		if err != nil {
+			return 0, err
+		}
+		if resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+			return 1, nil
+		}
+		return 0, nil
+	})
+}
+
+// objectRef parses "type:id".
+func objectRef(s string) (*v1.ObjectReference, error) {
+	typ, id, ok := strings.Cut(s, ":")
+	if !ok || typ == "" || id == "" {
+		return nil, fmt.Errorf("invalid object %q (expected type:id)", s)
+	}
+	return &v1.ObjectReference{ObjectType: typ, ObjectId: id}, nil
+}
@@ -0,0 +1,50 @@
+package authzed_crdb
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// AuthzedExport streams every relationship back to the dataset CSVs of `csv
+// generate` (see infrastructure.ExportRelationships), fully consistent, into
+// SPICEDB_EXPORT_DIR: a round trip to diff against the loaded dataset, or a
+// backup before a destructive schema change. It refuses to write into the
+// dataset directory itself.
+//
+// Env vars:
+//
+//	SPICEDB_EXPORT_DIR  (default: export/authzed_crdb)
+func AuthzedExport() {
+	dir := utils.GetEnvWithDefault("SPICEDB_EXPORT_DIR", filepath.Join("export", "authzed_crdb"))
+	if utils.SameDir(dir, utils.DataDir()) {
+		log.Fatalf("[authzed_crdb] export: SPICEDB_EXPORT_DIR=%s is the dataset directory; pick another one", dir)
+	}
+
+	client, _, cancel, err := infrastructure.NewAuthzedCrdbClientFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[authzed_crdb] failed to create authzed client: %v", err)
+	}
+	defer cancel()
+	defer client.Close()
+
+	start := time.Now()
+	rows, err := infrastructure.ExportRelationships(context.Background(), client, fullyConsistent, dir)
+	if err != nil {
+		log.Fatalf("[authzed_crdb] export: %v", err)
+	}
+	names := make([]string, 0, len(rows))
+	for name := range rows {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		log.Printf("[authzed_crdb] export: %-22s %d", name, rows[name])
+	}
+	log.Printf("[authzed_crdb] == SpiceDB export to %s DONE: elapsed=%s ==", dir, time.Since(start).Truncate(time.Millisecond))
+}
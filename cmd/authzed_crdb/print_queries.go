@@ -0,0 +1,51 @@
+package authzed_crdb
+
+import (
+	"context"
+	"log"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"test-tls/authz/spicedb"
+	"test-tls/utils"
+)
+
+// AuthzedPrintQueries prints the SpiceDB requests of the read scenarios with
+// sampled parameters instead of sending them (see utils.PrintQueries), as
+// protojson. Under SPICEDB_CONSISTENCY=at_least_as_fresh or zedtoken the
+// ZedToken is a placeholder for the revision the benchmark reads at start.
+func AuthzedPrintQueries() {
+	consistency := printConsistency()
+	utils.PrintQueries("authzed_crdb", func(s utils.QuerySample) string {
+		switch s.Scenario {
+		case "check_time_bounded_direct_user":
+			return "CheckPermission " + protoText(spicedb.CheckRequest(consistency, s.Resource, s.User, s.Relation))
+		case "lookup_resources_manage_super", "lookup_resources_view_regular":
+			return "LookupResources " + protoText(spicedb.LookupRequest(consistency, s.User, s.Permission))
+		}
+		return "CheckPermission " + protoText(spicedb.CheckRequest(consistency, s.Resource, s.User, s.Permission))
+	})
+}
+
+// printConsistency is the SPICEDB_CONSISTENCY of useBenchConsistency without
+// a connection.
+func printConsistency() *v1.Consistency {
+	mode := utils.GetEnvWithDefault("SPICEDB_CONSISTENCY", "full")
+	if mode == "at_least_as_fresh" {
+		return &v1.Consistency{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: &v1.ZedToken{Token: "<ReadSchema read_at>"}}}
+	}
+	if mode == "zedtoken" {
+		return spicedb.AtLeastAsFresh("<saved ZedToken>")
+	}
+	consistency, err := spicedb.ParseConsistency(context.Background(), nil, mode)
+	if err != nil {
+		log.Fatalf("[authzed_crdb] SPICEDB_CONSISTENCY: %v", err)
+	}
+	return consistency
+}
+
+func protoText(m proto.Message) string {
+	return protojson.MarshalOptions{Multiline: true}.Format(m)
+}
@@ -0,0 +1,203 @@
+package authzed_crdb
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	authzed "github.com/authzed/authzed-go/v1"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// AuthzedSchemaRead prints the schema currently deployed in SpiceDB.
+func AuthzedSchemaRead() {
+	client, _, cancel, err := infrastructure.NewAuthzedCrdbClientFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[authzed_crdb] create authzed client: %v", err)
+	}
+	defer cancel()
+	defer client.Close()
+
+	fmt.Print(readDeployedSchema(client))
+}
+
+// AuthzedSchemaDiff prints a line diff from the deployed schema to the
+// selected variant (SPICEDB_SCHEMA) and lists the definitions, caveats,
+// relations and permissions that writing it would delete.
+func AuthzedSchemaDiff() {
+	client, _, cancel, err := infrastructure.NewAuthzedCrdbClientFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[authzed_crdb] create authzed client: %v", err)
+	}
+	defer cancel()
+	defer client.Close()
+
+	name, local := loadSchema()
+	deployed := readDeployedSchema(client)
+	logDeployedSchema(deployed)
+	if !printSchemaDiff(deployed, local, name) {
+		log.Printf("[authzed_crdb] deployed schema matches %s", name)
+		return
+	}
+	if removed := removedSchemaElements(deployed, local); len(removed) > 0 {
+		log.Printf("[authzed_crdb] WARNING: writing %s would delete: %s", name, strings.Join(removed, ", "))
+	}
+}
+
+// AuthzedSchemaWrite applies the selected variant (SPICEDB_SCHEMA) to SpiceDB.
+// When the write would delete definitions, caveats, relations or permissions
+// it shows the diff and asks for confirmation first; SCHEMA_FORCE=true skips
+// the prompt (for scripts). SpiceDB itself still rejects deleting relations that have data.
+func AuthzedSchemaWrite() {
+	client, ctx, cancel, err := infrastructure.NewAuthzedCrdbClientFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[authzed_crdb] create authzed client: %v", err)
+	}
+	defer cancel()
+	defer client.Close()
+
+	name, local := loadSchema()
+	deployed := readDeployedSchema(client)
+	if schemaSignature(deployed) == schemaSignature(local) {
+		log.Printf("[authzed_crdb] deployed schema matches %s, nothing to write", name)
+		return
+	}
+
+	if removed := removedSchemaElements(deployed, local); len(removed) > 0 {
+		printSchemaDiff(deployed, local, name)
+		log.Printf("[authzed_crdb] WARNING: writing %s deletes: %s", name, strings.Join(removed, ", "))
+		if utils.GetEnvWithDefault("SCHEMA_FORCE", "false") != "true" && !confirm("apply schema anyway? [y/N] ") {
+			log.Fatalf("[authzed_crdb] schema write aborted")
+		}
+	}
+
+	log.Printf("[authzed_crdb] == Writing schema %s (sha256=%s) to SpiceDB ==", name, schemaHash(local))
+	resp, err := client.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: local})
+	if err != nil {
+		log.Fatalf("[authzed_crdb] WriteSchema failed: %v", err)
+	}
+	log.Printf("[authzed_crdb] Schema written at revision: %s", resp.WrittenAt.Token)
+}
+
+// readDeployedSchema is readCurrentSchema, but an empty SpiceDB (no schema
+// written yet) reads as an empty schema instead of failing.
+func readDeployedSchema(client *authzed.Client) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := client.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+	if err != nil {
+		if strings.Contains(err.Error(), "No schema has been defined") {
+			return ""
+		}
+		log.Fatalf("[authzed_crdb] ReadSchema failed: %v", err)
+	}
+	return resp.SchemaText
+}
+
+func confirm(prompt string) bool {
+	fmt.Fprint(os.Stderr, prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// printSchemaDiff prints a unified-style line diff (whitespace-trimmed lines,
+// blank lines ignored) and reports whether there was any difference.
+func printSchemaDiff(from, to, toName string) bool {
+	a, b := schemaLines(from), schemaLines(to)
+
+	// Longest common subsequence table; schemas are small enough for O(n*m).
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	fmt.Println("--- deployed")
+	fmt.Printf("+++ %s\n", toName)
+	changed := false
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			fmt.Printf("+ %s\n", b[j])
+			j++
+			changed = true
+		default:
+			fmt.Printf("- %s\n", a[i])
+			i++
+			changed = true
+		}
+	}
+	return changed
+}
+
+func schemaLines(schema string) []string {
+	var lines []string
+	for _, line := range strings.Split(schema, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+var (
+	schemaBlockRe  = regexp.MustCompile(`^(definition|caveat)\s+([a-zA-Z0-9_/]+)`)
+	schemaMemberRe = regexp.MustCompile(`^(relation|permission)\s+([a-zA-Z0-9_]+)`)
+)
+
+// schemaElements lists the named elements of a schema as "definition user",
+// "relation resource#viewer_user", "caveat valid_window", ...
+func schemaElements(schema string) map[string]struct{} {
+	elems := make(map[string]struct{})
+	current := ""
+	for _, line := range schemaLines(schema) {
+		if line = strings.TrimSpace(strings.SplitN(line, "//", 2)[0]); line == "" {
+			continue
+		}
+		if m := schemaBlockRe.FindStringSubmatch(line); m != nil {
+			current = m[2]
+			elems[m[1]+" "+m[2]] = struct{}{}
+			continue
+		}
+		if m := schemaMemberRe.FindStringSubmatch(line); m != nil && current != "" {
+			elems[m[1]+" "+current+"#"+m[2]] = struct{}{}
+		}
+	}
+	return elems
+}
+
+// removedSchemaElements lists the elements of from that to no longer has.
+func removedSchemaElements(from, to string) []string {
+	kept := schemaElements(to)
+	var removed []string
+	for e := range schemaElements(from) {
+		if _, ok := kept[e]; !ok {
+			removed = append(removed, e)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
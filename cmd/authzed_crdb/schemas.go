@@ -0,0 +1,177 @@
+package authzed_crdb
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"test-tls/authz"
+	"test-tls/authz/spicedb"
+	"test-tls/utils"
+)
+
+// schemaFS holds the Zed schema variants. They differ only in how group
+// nesting is modeled, so load_data writes the same relationships for all of
+// them:
+//
+//	schema1  flat groups, hierarchy edges stored but not traversed
+//	schema2  nesting propagates membership only, management is direct
+//	schema3  (default) full nesting of both membership and management
+//
+//go:embed schemas/*.zed
+var schemaFS embed.FS
+
+const defaultSchemaVariant = "schema3"
+
+// schemaVariant returns the variant picked by SPICEDB_SCHEMA (or --schema on
+// the command line); "2" and "schema2" are the same.
+func schemaVariant() string {
+	v := strings.TrimSpace(utils.GetEnvWithDefault("SPICEDB_SCHEMA", defaultSchemaVariant))
+	if !strings.HasPrefix(v, "schema") {
+		v = "schema" + v
+	}
+	return v
+}
+
+// loadSchema returns the name and text of the selected schema variant, with
+// the extensions of the environment (see extendSchema).
+func loadSchema() (string, string) {
+	name := schemaVariant()
+	b, err := schemaFS.ReadFile("schemas/" + name + ".zed")
+	if err != nil {
+		log.Fatalf("[authzed_crdb] unknown SPICEDB_SCHEMA %q (expected one of: %s)", name, strings.Join(schemaVariants(), ", "))
+	}
+	return extendSchema(name, string(b))
+}
+
+// extendSchema adds to the variant name the permissions of
+// BENCH_PERMISSION_RULES (withRules) and the denials of BENCH_DENIALS
+// (withDenials) when set, naming the result <variant>+rules,
+// <variant>+denials or <variant>+rules+denials.
+func extendSchema(name, schema string) (string, string) {
+	if rules := utils.PermissionRulesFromEnv(); len(rules) > 0 {
+		name, schema = name+"+rules", withRules(schema, rules)
+	}
+	if utils.DenialsFromEnv() {
+		name, schema = name+"+denials", withDenials(schema)
+	}
+	return name, schema
+}
+
+// withRules adds the permissions rules derive (edit, delete, share, ...) to
+// the resource definition of schema. They only add permissions, so the
+// relationships load-data writes are the same with and without them.
+func withRules(schema string, rules []authz.Rule) string {
+	start := strings.Index(schema, "definition resource {")
+	if start < 0 {
+		return schema
+	}
+	end := start + strings.Index(schema[start:], "\n}")
+	var b strings.Builder
+	b.WriteString(schema[:end])
+	b.WriteString("\n\n    // Derived permissions (BENCH_PERMISSION_RULES)")
+	for _, line := range spicedb.RulePermissions(rules) {
+		b.WriteString("\n    " + line)
+	}
+	b.WriteString(schema[end:])
+	return b.String()
+}
+
+// viewPermissionRe matches the view permission of the resource definition,
+// the only view permission of the variants.
+var viewPermissionRe = regexp.MustCompile(`(?m)^([ \t]*)permission view = (.+)$`)
+
+// withDenials adds the banned_user relation (authz.BannedUser) to the
+// resource definition of schema and excludes it from view. view_granted
+// (authz.ViewGranted) keeps what the grants alone give, for the denials
+// experiment. load-data writes the banned_user relationships of
+// resource_bans.csv with BENCH_DENIALS too.
+func withDenials(schema string) string {
+	return viewPermissionRe.ReplaceAllString(schema, "\n${1}// Denials (BENCH_DENIALS): banned users lose view whatever grants it\n"+
+		"${1}relation "+authz.BannedUser+": user\n"+
+		"${1}permission "+authz.ViewGranted+" = ${2}\n"+
+		"${1}permission view = (${2}) - "+authz.BannedUser)
+}
+
+func schemaVariants() []string {
+	entries, _ := schemaFS.ReadDir("schemas")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".zed"))
+	}
+	return names
+}
+
+// schemaCapabilities lists the variants as SPICEDB_SCHEMA values, for the
+// capabilities command.
+func schemaCapabilities() []string {
+	var caps []string
+	for _, name := range schemaVariants() {
+		if name == defaultSchemaVariant {
+			name += " (default)"
+		}
+		caps = append(caps, "SPICEDB_SCHEMA="+name)
+	}
+	return caps
+}
+
+// schemaHash is a short sha256 of a schema text, logged with results so runs
+// can be grouped by the exact schema they used.
+func schemaHash(schema string) string {
+	sum := sha256.Sum256([]byte(schema))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// deployedSchemaVariant names the embedded variant whose relations and
+// permissions match the deployed schema, and returns its hash. SpiceDB returns
+// the schema reformatted without comments, so the comparison is on the
+// normalized relation/permission lines rather than the raw text. A variant
+// with the extensions of the environment is named as extendSchema names it.
+// An unrecognized schema is reported as "unknown" with the hash of the
+// deployed text.
+func deployedSchemaVariant(deployed string) (string, string) {
+	sig := schemaSignature(deployed)
+	for _, name := range schemaVariants() {
+		b, _ := schemaFS.ReadFile("schemas/" + name + ".zed")
+		if schemaSignature(string(b)) == sig {
+			return name, schemaHash(string(b))
+		}
+		if extName, extended := extendSchema(name, string(b)); extName != name && schemaSignature(extended) == sig {
+			return extName, schemaHash(extended)
+		}
+	}
+	return "unknown", schemaHash(deployed)
+}
+
+var schemaSpaceRe = regexp.MustCompile(`\s+`)
+
+func schemaSignature(schema string) string {
+	var lines []string
+	current := ""
+	for _, line := range schemaLines(schema) {
+		if line = strings.TrimSpace(strings.SplitN(line, "//", 2)[0]); line == "" {
+			continue
+		}
+		if m := schemaBlockRe.FindStringSubmatch(line); m != nil {
+			current = m[2]
+			lines = append(lines, m[1]+" "+m[2])
+			continue
+		}
+		if schemaMemberRe.MatchString(line) {
+			lines = append(lines, current+": "+schemaSpaceRe.ReplaceAllString(line, " "))
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// logDeployedSchema logs the SCHEMA line benchmark/parse_all.go reports with
+// the results.
+func logDeployedSchema(deployed string) {
+	name, hash := deployedSchemaVariant(deployed)
+	log.Printf("[authzed_crdb] SCHEMA: variant=%s sha256=%s", name, hash)
+}
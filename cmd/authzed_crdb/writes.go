@@ -0,0 +1,87 @@
+package authzed_crdb
+
+import (
+	"context"
+	"log"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	authzed "github.com/authzed/authzed-go/v1"
+
+	"test-tls/ids"
+	"test-tls/utils"
+)
+
+// runWriteGrantRevoke times single-relationship writes, the path where the
+// SpiceDB datastores differ most. Each of BENCH_WRITE_ITER iterations
+// (default 0 = skip, as it writes) touches a viewer_user grant and deletes it
+// again; the two writes are logged as the write_grant and write_revoke
+// scenarios. The subject is BENCH_WRITE_USER (default rlp_write_bench), a user
+// that is not in the dataset, so a delete never removes a loaded grant. The
+// resources cycle through the first BENCH_WRITE_RESOURCES (default 100).
+//
+// With SPICEDB_WATCH=true the writes are also followed on the Watch API, and
+// each scenario logs a VISIBILITY line: how long after WriteRelationships
+// returned its change arrived in the stream (see utils.VisibilityTracker).
+//
+// The ZedToken of each scenario's last write is saved (see
+// utils.SaveZedToken) for SPICEDB_CONSISTENCY=zedtoken runs.
+func runWriteGrantRevoke(client *authzed.Client) {
+	iters := utils.GetEnvInt("BENCH_WRITE_ITER", 0)
+	if iters <= 0 {
+		return
+	}
+	userID := utils.GetEnvWithDefault("BENCH_WRITE_USER", "rlp_write_bench")
+	resources := max(utils.GetEnvInt("BENCH_WRITE_RESOURCES", 100), 1)
+
+	log.Printf("[authzed_crdb] [write_grant] streaming mode. iterations=%d user=%s", iters, userID)
+	utils.LogScenarioConfig("authzed_crdb", "write_grant", iters, 10*time.Second, userID)
+	log.Printf("[authzed_crdb] [write_revoke] streaming mode. iterations=%d user=%s", iters, userID)
+	utils.LogScenarioConfig("authzed_crdb", "write_revoke", iters, 10*time.Second, userID)
+	errs := map[string]*utils.ErrorTally{"write_grant": utils.NewErrorTally(), "write_revoke": utils.NewErrorTally()}
+	var visibility map[string]*utils.VisibilityTracker
+	if utils.GetEnvWithDefault("SPICEDB_WATCH", "false") == "true" {
+		grant, revoke := utils.NewVisibilityTracker(), utils.NewVisibilityTracker()
+		stop, err := watchWrites(client, userID, grant, revoke)
+		if err != nil {
+			log.Printf("[authzed_crdb] [write_grant] watch failed, visibility not measured: %v", err)
+		} else {
+			defer stop()
+			visibility = map[string]*utils.VisibilityTracker{"write_grant": grant, "write_revoke": revoke}
+		}
+	}
+	written := map[string]string{}
+	for i := range iters {
+		resourceID := ids.Format(ids.Resource, i%resources+1)
+		for _, name := range []string{"write_grant", "write_revoke"} {
+			update := mkCreateRel("resource", resourceID, "viewer_user", "user", userID, "")
+			if name == "write_revoke" {
+				update.Operation = v1.RelationshipUpdate_OPERATION_DELETE
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			start := time.Now()
+			resp, err := client.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: []*v1.RelationshipUpdate{update}})
+			dur := time.Since(start)
+			cancel()
+			if err != nil {
+				class := errs[name].Record(err)
+				log.Printf("[authzed_crdb] [%s] iter=%d write failed class=%s: %v", name, i, class, err)
+				continue
+			}
+			written[name] = resp.GetWrittenAt().GetToken()
+			if visibility != nil {
+				visibility[name].Written(resourceID)
+			}
+			log.Printf("[authzed_crdb] [%s] iter=%d resource=%s dur=%s", name, i, resourceID, dur)
+		}
+	}
+	for _, name := range []string{"write_grant", "write_revoke"} {
+		log.Printf("[authzed_crdb] [%s] DONE: iters=%d", name, iters)
+		log.Printf("[authzed_crdb] [%s] ERRORS: %s", name, errs[name].Summary(iters))
+		utils.SaveZedToken("authzed_crdb", name, written[name])
+		if visibility != nil {
+			visibility[name].Log("authzed_crdb", name)
+		}
+	}
+}
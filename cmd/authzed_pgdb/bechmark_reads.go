@@ -10,6 +10,8 @@ import (
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	authzed "github.com/authzed/authzed-go/v1"
 
+	"test-tls/authz/spicedb"
+	"test-tls/ids"
 	"test-tls/infrastructure"
 	"test-tls/utils"
 )
@@ -31,6 +33,10 @@ func AuthzedBenchmarkReads() {
 	}
 	defer cancel()
 	defer client.Close()
+	logDeployedSchema(readDeployedSchema(client))
+	utils.LogConsistency("authzed_pgdb", useBenchConsistency(client))
+	stopAudit := utils.StartAudit("authzed_pgdb")
+	defer stopAudit()
 	// Log startup summary including any env-overridden lookup users.
 	start := time.Now()
 	heavyManageUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
@@ -40,11 +46,29 @@ func AuthzedBenchmarkReads() {
 		elapsed, heavyManageUser, regularViewUser)
 
 	// Run individual benchmark scenarios
-	runCheckManageDirectUser(client)          // Test direct manager_user relationships in resource_acl
-	runCheckManageOrgAdmin(client)            // Test org->admin permission paths
-	runCheckViewViaGroupMember(client)        // Test permissions via viewer_group and group membership
-	runLookupResourcesManageHeavyUser(client) // Test resource lookup for users with many manage permissions
-	runLookupResourcesViewRegularUser(client) // Test resource lookup for users with regular view permissions
+	utils.RunCachePhases("authzed_pgdb", nil, func() {
+		runCheckManageDirectUser(client)          // Test direct manager_user relationships in resource_acl
+		runCheckManageOrgAdmin(client)            // Test org->admin permission paths
+		runCheckViewViaGroupMember(client)        // Test permissions via viewer_group and group membership
+		runCheckTimeBoundedDirectUser(client)     // Test valid_window caveated user grants at the current time
+		runCheckBulkManageDirectUser(client)      // Test CheckBulkPermissions batches of direct manager pairs
+		runLookupResourcesManageHeavyUser(client) // Test resource lookup for users with many manage permissions
+		runLookupResourcesViewRegularUser(client) // Test resource lookup for users with regular view permissions
+		runLookupResourcesMix(client)             // Test resource lookup for a stratified mix of users (BENCH_LOOKUPRES_MIX_USERS)
+		runLookupResourcesPercentiles(client)     // Test resource lookup for the p10/p50/p90/p99 users by grant count (BENCH_LOOKUPRES_*_PCT_USERS)
+		runViralFanIn(client)                     // Test checks and lookups on resources shared with many users directly (BENCH_VIRAL_RESOURCES)
+		runWorstCaseChecks(client)                // Test view checks whose only grant is the deepest nested group path
+		runCustomScenarios(client)                // Run user-defined scenarios from BENCH_CUSTOM_SCENARIOS
+		runWorkload(client)                       // Run the blended request mix of BENCH_WORKLOAD
+		runMyGroups(client)                       // List the groups of sampled users (my-groups query)
+		runAccessSummary(client)                  // Count the resources sampled users can view and manage (dashboard summary)
+		runDerivedPermissions(client)             // Check and look up manage, view and the permissions of BENCH_PERMISSION_RULES
+		runDenials(client)                        // Check and look up view with and without the banned_user denials of BENCH_DENIALS
+	})
+	runOrgAdminEscalation(client) // Toggle a user's org admin role and compare cached vs fresh lookups (BENCH_ESCALATION_CYCLES)
+	runWriteGrantRevoke(client)   // Time viewer_user grant and revoke writes (BENCH_WRITE_ITER)
+	runReadRelationships(client)  // Stream filtered manager_user tuples with ReadRelationships (BENCH_READ_RELS_ITER)
+	runFullExport(client)         // Stream every relationship of the resource type (BENCH_EXPORT_ITER)
 
 	log.Println("[authzed_pgdb] == Authzed read benchmarks DONE ==")
 }
@@ -74,7 +98,10 @@ func streamReadRels(ctx context.Context, client *authzed.Client, filter *v1.Rela
 
 // runLookupBench runs LookupResources calls for a given user and permission,
 // counting the number of resources returned and reporting timing metrics.
-// Each iteration streams all accessible resources and counts them.
+// Each iteration streams all accessible resources and counts them. Next to the
+// stream duration it logs the time to the first resource (ttfr) per iteration
+// and a STREAM line with the first-result and inter-item arrival
+// distributions (see utils.StreamTiming).
 //
 // Parameters:
 //   - client: The Authzed client for making API calls
@@ -90,59 +117,47 @@ func runLookupBench(client *authzed.Client, name, permission, userID string, ite
 	}
 
 	log.Printf("[authzed_pgdb] [%s] iterations=%d user=%s", name, iters, userID)
+	utils.LogScenarioConfig("authzed_pgdb", name, iters, timeout, userID)
 
 	var total time.Duration
-	var lastCount int
+	var lastCount, ok int
+	errs := utils.NewErrorTally()
+	timing := utils.NewStreamTiming()
 
 	for i := range iters {
 		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		start := time.Now()
+		timing.Start(start)
 
-		stream, err := client.LookupResources(ctx, &v1.LookupResourcesRequest{
-			ResourceObjectType: "resource",
-			Permission:         permission,
-			Subject: &v1.SubjectReference{
-				Object: &v1.ObjectReference{
-					ObjectType: "user",
-					ObjectId:   userID,
-				},
-			},
-			Consistency: &v1.Consistency{
-				Requirement: &v1.Consistency_FullyConsistent{
-					FullyConsistent: true,
-				},
-			},
-		})
-		if err != nil {
-			cancel()
-			log.Fatalf("[authzed_pgdb] [%s] LookupResources failed: %v", name, err)
-		}
-
-		// Count resources returned in the stream
 		count := 0
-		for {
-			_, err := stream.Recv()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				cancel()
-				log.Fatalf("[authzed_pgdb] [%s] stream Recv failed: %v", name, err)
-			}
+		err := lookupResources(ctx, client, userID, permission, func(string) {
+			timing.Item()
 			count++
-		}
+		})
 		cancel()
+		utils.AuditLookup(name, userID, permission, count, time.Since(start), err)
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[authzed_pgdb] [%s] iter=%d LookupResources failed class=%s: %v", name, i, class, err)
+			continue
+		}
 
 		dur := time.Since(start)
 		total += dur
 		lastCount = count
+		ok++
 
-		log.Printf("[authzed_pgdb] [%s] iter=%d resources=%d duration=%s", name, i, count, dur.Truncate(time.Millisecond))
+		log.Printf("[authzed_pgdb] [%s] iter=%d resources=%d duration=%s ttfr=%s", name, i, count, dur.Truncate(time.Millisecond), timing.First())
 	}
 
-	avg := time.Duration(int64(total) / int64(iters))
+	avg := time.Duration(0)
+	if ok > 0 {
+		avg = time.Duration(int64(total) / int64(ok))
+	}
 	log.Printf("[authzed_pgdb] [%s] DONE: iters=%d lastCount=%d avg=%s total=%s",
 		name, iters, lastCount, avg, total)
+	log.Printf("[authzed_pgdb] [%s] ERRORS: %s", name, errs.Summary(iters))
+	timing.Log("authzed_pgdb", name)
 }
 
 // runCheckManageDirectUser benchmarks CheckPermission calls for "manage" permission
@@ -153,7 +168,9 @@ func runCheckManageDirectUser(client *authzed.Client) {
 	iters := utils.GetEnvInt("BENCH_CHECK_DIRECT_SUPER_ITER", 1000)
 
 	log.Printf("[authzed_pgdb] [check_manage_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("authzed_pgdb", "check_manage_direct_user", iters, 2*time.Second)
 	done := 0
+	errs := utils.NewErrorTally()
 	// Hybrid behavior: if BENCH_LOOKUPRES_MANAGE_USER is set, prefer LookupResources for that user
 	lookupUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
@@ -172,7 +189,8 @@ func runCheckManageDirectUser(client *authzed.Client) {
 			})
 			if err != nil {
 				cancel()
-				log.Fatalf("[authzed_pgdb] [check_manage_direct_user] LookupResources failed: %v", err)
+				errs.Survive(err, iters, "[authzed_pgdb] [check_manage_direct_user] LookupResources failed: %v", err)
+				continue
 			}
 
 			streamed := 0
@@ -186,27 +204,30 @@ func runCheckManageDirectUser(client *authzed.Client) {
 				}
 				if err != nil {
 					cancel()
-					log.Fatalf("[authzed_pgdb] [check_manage_direct_user] Lookup stream Recv failed: %v", err)
+					errs.Survive(err, iters, "[authzed_pgdb] [check_manage_direct_user] Lookup stream Recv failed: %v", err)
+					break
 				}
 				streamed++
 				resID := resp.GetResourceObjectId()
 
+				if !utils.InOrgScope(ids.Resource, resID) {
+					continue
+				}
+
 				// Call CheckPermission for each resource as it arrives (no buffering)
 				cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 				start := time.Now()
-				_, err = client.CheckPermission(cctx, &v1.CheckPermissionRequest{
-					Resource:    &v1.ObjectReference{ObjectType: "resource", ObjectId: resID},
-					Permission:  "manage",
-					Subject:     &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: lookupUser}},
-					Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
-				})
+				granted, err := checkPermission(cctx, client, resID, lookupUser, "manage")
 				ccancel()
+				utils.AuditCheck("check_manage_direct_user", resID, lookupUser, "manage", granted, time.Since(start), err)
 				if err != nil {
-					cancel()
-					log.Fatalf("[authzed_pgdb] [check_manage_direct_user] CheckPermission failed: %v", err)
+					class := errs.Record(err)
+					log.Printf("[authzed_pgdb] [check_manage_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+					done++
+					continue
 				}
 				dur := time.Since(start)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[authzed_pgdb] [check_manage_direct_user] lookup iter=%d resource=%s user=%s dur=%s", done, resID, lookupUser, dur)
 				}
 				done++
@@ -231,30 +252,34 @@ func runCheckManageDirectUser(client *authzed.Client) {
 			resID := rel.Resource.ObjectId
 			userID := rel.Subject.Object.ObjectId
 
+			if !utils.InOrgScope(ids.Resource, resID) {
+				return
+			}
+
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			start := time.Now()
-			_, err := client.CheckPermission(ctx, &v1.CheckPermissionRequest{
-				Resource:    &v1.ObjectReference{ObjectType: "resource", ObjectId: resID},
-				Permission:  "manage",
-				Subject:     &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: userID}},
-				Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
-			})
+			granted, err := checkPermission(ctx, client, resID, userID, "manage")
 			cancel()
+			utils.AuditCheck("check_manage_direct_user", resID, userID, "manage", granted, time.Since(start), err)
 			if err != nil {
-				log.Fatalf("[authzed_pgdb] [check_manage_direct_user] CheckPermission failed: %v", err)
+				class := errs.Record(err)
+				log.Printf("[authzed_pgdb] [check_manage_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+				done++
+				return
 			}
 			dur := time.Since(start)
 			// Log every 100th iteration to avoid excessive output
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[authzed_pgdb] [check_manage_direct_user] iter=%d resource=%s user=%s dur=%s", done, resID, userID, dur)
 			}
 			done++
 		})
 		if err != nil {
-			log.Fatalf("[authzed_pgdb] [check_manage_direct_user] streamReadRels failed: %v", err)
+			errs.Survive(err, iters, "[authzed_pgdb] [check_manage_direct_user] streamReadRels failed: %v", err)
 		}
 	}
 	log.Printf("[authzed_pgdb] [check_manage_direct_user] DONE: iters=%d", iters)
+	log.Printf("[authzed_pgdb] [check_manage_direct_user] ERRORS: %s", errs.Summary(done))
 }
 
 // runCheckManageOrgAdmin benchmarks CheckPermission calls for "manage" permission
@@ -265,7 +290,9 @@ func runCheckManageOrgAdmin(client *authzed.Client) {
 	iters := utils.GetEnvInt("BENCH_CHECK_ORGADMIN_ITER", 1000)
 
 	log.Printf("[authzed_pgdb] [check_manage_org_admin] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("authzed_pgdb", "check_manage_org_admin", iters, 2*time.Second)
 	done := 0
+	errs := utils.NewErrorTally()
 	// Hybrid behavior: if BENCH_LOOKUPRES_MANAGE_USER is set, prefer LookupResources for that user
 	lookupUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
@@ -284,7 +311,8 @@ func runCheckManageOrgAdmin(client *authzed.Client) {
 			})
 			if err != nil {
 				cancel()
-				log.Fatalf("[authzed_pgdb] [check_manage_org_admin] LookupResources failed: %v", err)
+				errs.Survive(err, iters, "[authzed_pgdb] [check_manage_org_admin] LookupResources failed: %v", err)
+				continue
 			}
 
 			streamed := 0
@@ -298,27 +326,30 @@ func runCheckManageOrgAdmin(client *authzed.Client) {
 				}
 				if err != nil {
 					cancel()
-					log.Fatalf("[authzed_pgdb] [check_manage_org_admin] Lookup stream Recv failed: %v", err)
+					errs.Survive(err, iters, "[authzed_pgdb] [check_manage_org_admin] Lookup stream Recv failed: %v", err)
+					break
 				}
 				streamed++
 				resID := resp.GetResourceObjectId()
 
+				if !utils.InOrgScope(ids.Resource, resID) {
+					continue
+				}
+
 				// CheckPermission for returned resource
 				cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 				start := time.Now()
-				_, err = client.CheckPermission(cctx, &v1.CheckPermissionRequest{
-					Resource:    &v1.ObjectReference{ObjectType: "resource", ObjectId: resID},
-					Permission:  "manage",
-					Subject:     &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: lookupUser}},
-					Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
-				})
+				granted, err := checkPermission(cctx, client, resID, lookupUser, "manage")
 				ccancel()
+				utils.AuditCheck("check_manage_org_admin", resID, lookupUser, "manage", granted, time.Since(start), err)
 				if err != nil {
-					cancel()
-					log.Fatalf("[authzed_pgdb] [check_manage_org_admin] CheckPermission failed: %v", err)
+					class := errs.Record(err)
+					log.Printf("[authzed_pgdb] [check_manage_org_admin] iter=%d check failed class=%s: %v", done, class, err)
+					done++
+					continue
 				}
 				dur := time.Since(start)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[authzed_pgdb] [check_manage_org_admin] lookup iter=%d resource=%s user=%s dur=%s", done, resID, lookupUser, dur)
 				}
 				done++
@@ -361,30 +392,34 @@ func runCheckManageOrgAdmin(client *authzed.Client) {
 				return
 			}
 
+			if !utils.InOrgScope(ids.Resource, resID) {
+				return
+			}
+
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			start := time.Now()
-			_, err := client.CheckPermission(ctx, &v1.CheckPermissionRequest{
-				Resource:    &v1.ObjectReference{ObjectType: "resource", ObjectId: resID},
-				Permission:  "manage",
-				Subject:     &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: adminUser}},
-				Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
-			})
+			granted, err := checkPermission(ctx, client, resID, adminUser, "manage")
 			cancel()
+			utils.AuditCheck("check_manage_org_admin", resID, adminUser, "manage", granted, time.Since(start), err)
 			if err != nil {
-				log.Fatalf("[authzed_pgdb] [check_manage_org_admin] CheckPermission failed: %v", err)
+				class := errs.Record(err)
+				log.Printf("[authzed_pgdb] [check_manage_org_admin] iter=%d check failed class=%s: %v", done, class, err)
+				done++
+				return
 			}
 			dur := time.Since(start)
 			// Log every 100th iteration to avoid excessive output
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[authzed_pgdb] [check_manage_org_admin] iter=%d resource=%s org=%s admin=%s dur=%s", done, resID, orgID, adminUser, dur)
 			}
 			done++
 		})
 		if err != nil {
-			log.Fatalf("[authzed_pgdb] [check_manage_org_admin] streamReadRels failed: %v", err)
+			errs.Survive(err, iters, "[authzed_pgdb] [check_manage_org_admin] streamReadRels failed: %v", err)
 		}
 	}
 	log.Printf("[authzed_pgdb] [check_manage_org_admin] DONE: iters=%d", iters)
+	log.Printf("[authzed_pgdb] [check_manage_org_admin] ERRORS: %s", errs.Summary(done))
 }
 
 // runCheckViewViaGroupMember benchmarks CheckPermission calls for "view" permission
@@ -395,7 +430,9 @@ func runCheckViewViaGroupMember(client *authzed.Client) {
 	iters := utils.GetEnvInt("BENCH_CHECK_VIEW_GROUP_ITER", 1000)
 
 	log.Printf("[authzed_pgdb] [check_view_via_group_member] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("authzed_pgdb", "check_view_via_group_member", iters, 2*time.Second)
 	done := 0
+	errs := utils.NewErrorTally()
 	// Hybrid behavior: if BENCH_LOOKUPRES_VIEW_USER is set, prefer LookupResources for that user
 	lookupUser := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
@@ -414,7 +451,8 @@ func runCheckViewViaGroupMember(client *authzed.Client) {
 			})
 			if err != nil {
 				cancel()
-				log.Fatalf("[authzed_pgdb] [check_view_via_group_member] LookupResources failed: %v", err)
+				errs.Survive(err, iters, "[authzed_pgdb] [check_view_via_group_member] LookupResources failed: %v", err)
+				continue
 			}
 
 			streamed := 0
@@ -428,27 +466,30 @@ func runCheckViewViaGroupMember(client *authzed.Client) {
 				}
 				if err != nil {
 					cancel()
-					log.Fatalf("[authzed_pgdb] [check_view_via_group_member] Lookup stream Recv failed: %v", err)
+					errs.Survive(err, iters, "[authzed_pgdb] [check_view_via_group_member] Lookup stream Recv failed: %v", err)
+					break
 				}
 				streamed++
 				resID := resp.GetResourceObjectId()
 
+				if !utils.InOrgScope(ids.Resource, resID) {
+					continue
+				}
+
 				// Call CheckPermission for each resource
 				cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 				start := time.Now()
-				_, err = client.CheckPermission(cctx, &v1.CheckPermissionRequest{
-					Resource:    &v1.ObjectReference{ObjectType: "resource", ObjectId: resID},
-					Permission:  "view",
-					Subject:     &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: lookupUser}},
-					Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
-				})
+				granted, err := checkPermission(cctx, client, resID, lookupUser, "view")
 				ccancel()
+				utils.AuditCheck("check_view_via_group_member", resID, lookupUser, "view", granted, time.Since(start), err)
 				if err != nil {
-					cancel()
-					log.Fatalf("[authzed_pgdb] [check_view_via_group_member] CheckPermission failed: %v", err)
+					class := errs.Record(err)
+					log.Printf("[authzed_pgdb] [check_view_via_group_member] iter=%d check failed class=%s: %v", done, class, err)
+					done++
+					continue
 				}
 				dur := time.Since(start)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[authzed_pgdb] [check_view_via_group_member] lookup iter=%d resource=%s user=%s dur=%s", done, resID, lookupUser, dur)
 				}
 				done++
@@ -506,30 +547,174 @@ func runCheckViewViaGroupMember(client *authzed.Client) {
 				return
 			}
 
+			if !utils.InOrgScope(ids.Resource, resID) {
+				return
+			}
+
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			start := time.Now()
-			_, err := client.CheckPermission(ctx, &v1.CheckPermissionRequest{
-				Resource:    &v1.ObjectReference{ObjectType: "resource", ObjectId: resID},
-				Permission:  "view",
-				Subject:     &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: pickedUser}},
-				Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
-			})
+			granted, err := checkPermission(ctx, client, resID, pickedUser, "view")
 			cancel()
+			utils.AuditCheck("check_view_via_group_member", resID, pickedUser, "view", granted, time.Since(start), err)
 			if err != nil {
-				log.Fatalf("[authzed_pgdb] [check_view_via_group_member] CheckPermission failed: %v", err)
+				class := errs.Record(err)
+				log.Printf("[authzed_pgdb] [check_view_via_group_member] iter=%d check failed class=%s: %v", done, class, err)
+				done++
+				return
 			}
 			dur := time.Since(start)
 			// Log every 100th iteration to avoid excessive output
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[authzed_pgdb] [check_view_via_group_member] iter=%d resource=%s group=%s user=%s dur=%s", done, resID, groupID, pickedUser, dur)
 			}
 			done++
 		})
 		if err != nil {
-			log.Fatalf("[authzed_pgdb] [check_view_via_group_member] streamReadRels failed: %v", err)
+			errs.Survive(err, iters, "[authzed_pgdb] [check_view_via_group_member] streamReadRels failed: %v", err)
 		}
 	}
 	log.Printf("[authzed_pgdb] [check_view_via_group_member] DONE: iters=%d", iters)
+	log.Printf("[authzed_pgdb] [check_view_via_group_member] ERRORS: %s", errs.Summary(done))
+}
+
+// runCheckTimeBoundedDirectUser benchmarks CheckPermission on direct user
+// grants carrying the valid_window caveat. Each grant is checked on its own
+// relation with the current time as caveat context, so the expired (soft
+// deleted) share of the sample is denied.
+// The number of iterations is controlled by BENCH_CHECK_TIME_BOUNDED_ITER env variable.
+func runCheckTimeBoundedDirectUser(client *authzed.Client) {
+	iters := utils.GetEnvInt("BENCH_CHECK_TIME_BOUNDED_ITER", 1000)
+	log.Printf("[authzed_pgdb] [check_time_bounded_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("authzed_pgdb", "check_time_bounded_direct_user", iters, 2*time.Second)
+	done, allowed := 0, 0
+	errs := utils.NewErrorTally()
+
+	for done < iters {
+		streamed := 0
+		err := streamReadRels(context.Background(), client, &v1.RelationshipFilter{
+			ResourceType: "resource",
+		}, func(rel *v1.Relationship) {
+			if done >= iters || rel.OptionalCaveat == nil || rel.Subject.Object.ObjectType != "user" {
+				return
+			}
+			streamed++
+			resID := rel.Resource.ObjectId
+			userID := rel.Subject.Object.ObjectId
+
+			if !utils.InOrgScope(ids.Resource, resID) {
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			start := time.Now()
+			ok, err := checkPermission(ctx, client, resID, userID, rel.Relation)
+			cancel()
+			utils.AuditCheck("check_time_bounded_direct_user", resID, userID, rel.Relation, ok, time.Since(start), err)
+			if err != nil {
+				class := errs.Record(err)
+				log.Printf("[authzed_pgdb] [check_time_bounded_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+				done++
+				return
+			}
+			dur := time.Since(start)
+			if ok {
+				allowed++
+			}
+			if utils.LogIteration(done, 100) {
+				log.Printf("[authzed_pgdb] [check_time_bounded_direct_user] iter=%d resource=%s user=%s allowed=%t dur=%s", done, resID, userID, ok, dur)
+			}
+			done++
+		})
+		if err != nil {
+			errs.Survive(err, iters, "[authzed_pgdb] [check_time_bounded_direct_user] streamReadRels failed: %v", err)
+			continue
+		}
+		if streamed == 0 {
+			log.Printf("[authzed_pgdb] [check_time_bounded_direct_user] skipped: no time-bounded grants (generate with RLP_EXPIRING_GRANT_FRACTION)")
+			break
+		}
+	}
+	log.Printf("[authzed_pgdb] [check_time_bounded_direct_user] DONE: iters=%d allowed=%d denied=%d", done, allowed, done-allowed-errs.Total())
+	log.Printf("[authzed_pgdb] [check_time_bounded_direct_user] ERRORS: %s", errs.Summary(done))
+}
+
+// runCheckBulkManageDirectUser benchmarks CheckBulkPermissions: each iteration
+// checks BENCH_CHECK_BULK_SIZE (resource, user) pairs for "manage" in one
+// request. Pairs come from resource.manager_user relationships; every other
+// pair is given the next relationship's user, so batches mix allowed and
+// (mostly) denied answers. The SQL backends answer the same batches with one
+// VALUES-join query.
+func runCheckBulkManageDirectUser(client *authzed.Client) {
+	iters := utils.GetEnvInt("BENCH_CHECK_BULK_ITER", 100)
+	size := utils.GetEnvInt("BENCH_CHECK_BULK_SIZE", 100)
+	log.Printf("[authzed_pgdb] [check_bulk_manage_direct_user] streaming mode. iterations=%d batch=%d", iters, size)
+	utils.LogScenarioConfig("authzed_pgdb", "check_bulk_manage_direct_user", iters, 10*time.Second)
+	done, allowed := 0, 0
+	errs := utils.NewErrorTally()
+
+	batch := make([]spicedb.Pair, 0, size)
+	runBatch := func() {
+		for i := 1; i < len(batch); i += 2 {
+			batch[i].UserID = batch[(i+1)%len(batch)].UserID
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		start := time.Now()
+		granted, err := checkPermissionsBulk(ctx, client, batch, "manage")
+		dur := time.Since(start)
+		cancel()
+		for i, p := range batch {
+			utils.AuditCheck("check_bulk_manage_direct_user", p.ResourceID, p.UserID, "manage", err == nil && granted[i], dur, err)
+		}
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[authzed_pgdb] [check_bulk_manage_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+		} else {
+			n := 0
+			for _, ok := range granted {
+				if ok {
+					n++
+				}
+			}
+			allowed += n
+			if utils.LogIteration(done, 10) {
+				log.Printf("[authzed_pgdb] [check_bulk_manage_direct_user] iter=%d pairs=%d allowed=%d dur=%s", done, len(batch), n, dur)
+			}
+		}
+		done++
+		batch = batch[:0]
+	}
+
+	for done < iters {
+		streamed := 0
+		err := streamReadRels(context.Background(), client, &v1.RelationshipFilter{
+			ResourceType:     "resource",
+			OptionalRelation: "manager_user",
+		}, func(rel *v1.Relationship) {
+			if done >= iters {
+				return
+			}
+			streamed++
+			p := spicedb.Pair{ResourceID: rel.Resource.ObjectId, UserID: rel.Subject.Object.ObjectId}
+
+			if !utils.InOrgScope(ids.Resource, p.ResourceID) {
+				return
+			}
+
+			if batch = append(batch, p); len(batch) == size {
+				runBatch()
+			}
+		})
+		if err != nil {
+			errs.Survive(err, iters, "[authzed_pgdb] [check_bulk_manage_direct_user] streamReadRels failed: %v", err)
+			continue
+		}
+		if streamed == 0 {
+			log.Printf("[authzed_pgdb] [check_bulk_manage_direct_user] skipped: no manager_user relationships")
+			break
+		}
+	}
+	log.Printf("[authzed_pgdb] [check_bulk_manage_direct_user] DONE: iters=%d pairs=%d allowed=%d", done, done*size, allowed)
+	log.Printf("[authzed_pgdb] [check_bulk_manage_direct_user] ERRORS: %s", errs.Summary(done))
 }
 
 // runLookupResourcesManageHeavyUser benchmarks LookupResources for "manage" permission
@@ -553,3 +738,82 @@ func runLookupResourcesViewRegularUser(client *authzed.Client) {
 	userID := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
 	runLookupBench(client, "lookup_resources_view_regular", "view", userID, iters, 60*time.Second)
 }
+
+// runLookupResourcesMix benchmarks lookups for a mix of users stratified by
+// grant count, reporting latency against result-set size. The number of users
+// is set by BENCH_LOOKUPRES_MIX_USERS (default 0 = skip); see utils.RunLookupMix.
+func runLookupResourcesMix(client *authzed.Client) {
+	utils.RunLookupMix("authzed_pgdb", newChecker(client))
+}
+
+// runLookupResourcesPercentiles benchmarks lookups for the users at the p10,
+// p50, p90 and p99 of the grant counts (BENCH_LOOKUPRES_*_PCT_USERS); see
+// utils.RunPercentileLookups.
+func runLookupResourcesPercentiles(client *authzed.Client) {
+	utils.RunPercentileLookups("authzed_pgdb", newChecker(client))
+}
+
+// runViralFanIn benchmarks checks and lookups on the resources shared with many
+// users directly (RLP_VIRAL_RESOURCES); see utils.RunViralFanIn.
+func runViralFanIn(client *authzed.Client) {
+	utils.RunViralFanIn("authzed_pgdb", newChecker(client))
+}
+
+// runWorstCaseChecks benchmarks view checks on the pairs granted only through
+// nested groups (check_view_worst_case); see utils.RunWorstCaseChecks.
+func runWorstCaseChecks(client *authzed.Client) {
+	utils.RunWorstCaseChecks("authzed_pgdb", newChecker(client))
+}
+
+// runWorkload benchmarks the blended mix of checks and lookups named by
+// BENCH_WORKLOAD (default "" = skip); see utils.RunWorkload.
+func runWorkload(client *authzed.Client) {
+	utils.RunWorkload("authzed_pgdb", newChecker(client))
+}
+
+// runMyGroups benchmarks listing the direct and effective groups of sampled
+// users (BENCH_MY_GROUPS_ITER, default 100; 0 skips); see utils.RunMyGroups.
+func runMyGroups(client *authzed.Client) {
+	utils.RunMyGroups("authzed_pgdb", newChecker(client))
+}
+
+// runAccessSummary benchmarks counting the resources sampled users can view
+// and manage (BENCH_ACCESS_SUMMARY_ITER, default 100; 0 skips); see
+// utils.RunAccessSummary.
+func runAccessSummary(client *authzed.Client) {
+	utils.RunAccessSummary("authzed_pgdb", newChecker(client))
+}
+
+// runDerivedPermissions benchmarks checks and lookups of manage, view and
+// the permissions of BENCH_PERMISSION_RULES (skipped when unset), which the
+// deployed schema must define (create-schema with the same rules); see
+// utils.RunDerivedPermissions.
+func runDerivedPermissions(client *authzed.Client) {
+	utils.RunDerivedPermissions("authzed_pgdb", newChecker(client))
+}
+
+// runDenials benchmarks checks and lookups of view_granted and view with
+// BENCH_DENIALS (skipped when unset), which the deployed schema must define
+// (create-schema and load-data with BENCH_DENIALS=1); see utils.RunDenials.
+func runDenials(client *authzed.Client) {
+	utils.RunDenials("authzed_pgdb", newChecker(client))
+}
+
+// runReadRelationships benchmarks filtered ReadRelationships bulk reads of
+// resource#manager_user tuples (BENCH_READ_RELS_ITER); see
+// utils.RunReadRelationships.
+func runReadRelationships(client *authzed.Client) {
+	utils.RunReadRelationships("authzed_pgdb", func(ctx context.Context, f utils.RelsFilter, page int) (int, error) {
+		return infrastructure.ReadRelationshipsPaged(ctx, client, benchConsistency, f, page)
+	})
+}
+
+// runFullExport benchmarks streaming every relationship of the resource type
+// (export_full_acl, BENCH_EXPORT_ITER), in pages of BENCH_READ_RELS_PAGE; see
+// utils.RunFullExport.
+func runFullExport(client *authzed.Client) {
+	page := utils.GetEnvInt("BENCH_READ_RELS_PAGE", 0)
+	utils.RunFullExport("authzed_pgdb", func(ctx context.Context) (int, error) {
+		return infrastructure.ReadRelationshipsPaged(ctx, client, benchConsistency, utils.RelsFilter{ResourceType: "resource"}, page)
+	})
+}
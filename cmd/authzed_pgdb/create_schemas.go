@@ -3,19 +3,16 @@ package authzed_pgdb
 import (
 	"context"
 	"log"
-	"os"
 
 	"test-tls/infrastructure"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 )
 
+// AuthzedCreateSchema writes the selected schema variant (SPICEDB_SCHEMA,
+// default schema3) to SpiceDB.
 func AuthzedCreateSchema() {
-	schemaPath := "cmd/authzed_pgdb/schemas.zed"
-	schemaBytes, err := os.ReadFile(schemaPath)
-	if err != nil {
-		log.Fatalf("[authzed_pgdb] read schema file %s: %v", schemaPath, err)
-	}
+	name, schema := loadSchema()
 
 	client, ctx, cancel, err := infrastructure.NewAuthzedPgdbClientFromEnv(context.Background())
 	if err != nil {
@@ -24,11 +21,11 @@ func AuthzedCreateSchema() {
 	defer cancel()
 	defer client.Close()
 
-	log.Printf("[authzed_pgdb] == Writing schema to SpiceDB from %s ==", schemaPath)
+	log.Printf("[authzed_pgdb] == Writing schema %s (sha256=%s) to SpiceDB ==", name, schemaHash(schema))
 
 	resp, err := client.WriteSchema(ctx, &v1.WriteSchemaRequest{
 		// WARNING: this overwrites the entire schema in SpiceDB
-		Schema: string(schemaBytes),
+		Schema: schema,
 	})
 	if err != nil {
 		log.Fatalf("[authzed_pgdb] WriteSchema failed: %v", err)
@@ -2,8 +2,10 @@ package authzed_pgdb
 
 import (
 	"context"
+	"io"
 	"log"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -11,10 +13,12 @@ import (
 	authzed "github.com/authzed/authzed-go/v1"
 
 	"test-tls/infrastructure"
+	"test-tls/utils"
 )
 
 // AuthzedDropSchemas deletes ALL relationship data for the resource types we care about.
 // It does NOT drop the schema itself, so you can recreate/recreate data afterwards.
+// Relationships of other definitions in the schema are left in place.
 func AuthzedDropSchemas() {
 	start := time.Now()
 	client, _, cancel, err := infrastructure.NewAuthzedPgdbClientFromEnv(context.Background())
@@ -54,28 +58,26 @@ func AuthzedDropSchemas() {
 
 	// If we couldn't parse any definitions, fall back to the common types.
 	if len(resourceTypes) == 0 {
-		resourceTypes = []string{"resource", "organization", "usergroup", "user"}
+		resourceTypes = slices.Clone(ownedDefinitions)
 	}
+	// Relationships of definitions another application added to the schema
+	// stay; there is no unconditional DeleteRelationships.
+	own := utils.OwnedObjects("authzed_pgdb", resourceTypes, ownedDefinitions)
+	resourceTypes = slices.DeleteFunc(resourceTypes, func(rt string) bool { return !own[rt] })
+	utils.GuardDrop("authzed_pgdb", countRelationships(client, resourceTypes))
 
 	for _, rt := range resourceTypes {
 		dropRelationshipsForType(client, rt)
 	}
 
-	// Aggressive deletion: remove all relationships unconditionally to ensure
-	// no stale tuples remain in the backend (use on test/dev instances).
-	log.Printf("[authzed_pgdb] Aggressive: deleting ALL relationships (unconditional)")
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
-	if _, err := client.DeleteRelationships(ctx, &v1.DeleteRelationshipsRequest{RelationshipFilter: &v1.RelationshipFilter{}}); err != nil {
-		log.Printf("[authzed_pgdb] Aggressive DeleteRelationships failed: %v", err)
-	} else {
-		log.Printf("[authzed_pgdb] Aggressive: DeleteRelationships succeeded (all relationships deleted)")
-	}
-
 	elapsed := time.Since(start).Truncate(time.Millisecond)
-	log.Printf("[authzed_pgdb] DONE: delete attempt finished for resource, organization, usergroup. elapsed=%s", elapsed)
+	log.Printf("[authzed_pgdb] DONE: delete attempt finished for %s. elapsed=%s", strings.Join(resourceTypes, ", "), elapsed)
 }
 
+// ownedDefinitions are the definitions of the benchmark schemas, the only
+// ones drop deletes relationships of.
+var ownedDefinitions = []string{"resource", "organization", "usergroup", "user"}
+
 // readCurrentSchema reads the current schema text from SpiceDB.
 func readCurrentSchema(client *authzed.Client) string {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -111,3 +113,30 @@ func dropRelationshipsForType(client *authzed.Client, resourceType string) {
 
 	log.Printf("[authzed_pgdb] Deleted all relationships for resource_type=%q", resourceType)
 }
+
+// countRelationships counts the relationships of each resource type for the
+// drop guard. SpiceDB has no count API, so each type is streamed only up to
+// utils.DropCountCap relationships.
+func countRelationships(client *authzed.Client, resourceTypes []string) []utils.DropCount {
+	limit := utils.DropCountCap()
+	counts := make([]utils.DropCount, 0, len(resourceTypes))
+	for _, rt := range resourceTypes {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		var n int64
+		stream, err := client.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
+			Consistency:        fullyConsistent,
+			RelationshipFilter: &v1.RelationshipFilter{ResourceType: rt},
+		})
+		for err == nil && n < limit {
+			if _, err = stream.Recv(); err == nil {
+				n++
+			}
+		}
+		cancel()
+		if err == io.EOF {
+			err = nil
+		}
+		counts = append(counts, utils.DropCount{Table: "relationships " + rt, Rows: n, AtLeast: n >= limit, Missing: err != nil})
+	}
+	return counts
+}
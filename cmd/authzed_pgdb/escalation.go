@@ -0,0 +1,131 @@
+package authzed_pgdb
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	authzed "github.com/authzed/authzed-go/v1"
+
+	"test-tls/authz/spicedb"
+	"test-tls/utils"
+)
+
+// runOrgAdminEscalation toggles one user between org member and org admin
+// BENCH_ESCALATION_CYCLES times (default 0 = skip, as it writes) and, after
+// each write, times a manage lookup twice: at minimize_latency, which may be
+// served from SpiceDB's cache, and at_least_as_fresh as the write's ZedToken.
+// Promotion grants manage on every resource of the org, so the two counts
+// differ whenever the cached answer predates the write.
+//
+// The user is BENCH_ESCALATION_USER, else BENCH_LOOKUPRES_VIEW_USER; it must
+// be a plain member (organization#member_user) of exactly one org, and is
+// left a member when the scenario ends. The ZedToken of the last write is
+// saved (see utils.SaveZedToken).
+func runOrgAdminEscalation(client *authzed.Client) {
+	const name = "org_admin_escalation"
+	cycles := utils.GetEnvInt("BENCH_ESCALATION_CYCLES", 0)
+	if cycles <= 0 {
+		return
+	}
+	userID := utils.GetEnvWithDefault("BENCH_ESCALATION_USER", os.Getenv("BENCH_LOOKUPRES_VIEW_USER"))
+	if userID == "" {
+		log.Printf("[authzed_pgdb] [%s] skipped: no user specified", name)
+		return
+	}
+
+	orgID, isAdmin := escalationOrg(client, userID)
+	if orgID == "" {
+		log.Printf("[authzed_pgdb] [%s] skipped: user %s is not a member of any org", name, userID)
+		return
+	}
+	if isAdmin {
+		log.Printf("[authzed_pgdb] [%s] skipped: user %s is already admin of org %s", name, userID, orgID)
+		return
+	}
+
+	log.Printf("[authzed_pgdb] [%s] iterations=%d user=%s org=%s", name, cycles, userID, orgID)
+	utils.LogScenarioConfig("authzed_pgdb", name, cycles, 10*time.Second, userID)
+	errs := utils.NewErrorTally()
+	written := ""
+	for i := range cycles {
+		for _, role := range []string{"admin", "member"} {
+			op := v1.RelationshipUpdate_OPERATION_TOUCH
+			if role == "member" {
+				op = v1.RelationshipUpdate_OPERATION_DELETE
+			}
+			update := mkCreateRel("organization", orgID, "admin_user", "user", userID, "")
+			update.Operation = op
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			start := time.Now()
+			resp, err := client.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: []*v1.RelationshipUpdate{update}})
+			cancel()
+			if err != nil {
+				errs.Survive(err, cycles, "[authzed_pgdb] [%s] cycle=%d write role=%s failed: %v", name, i, role, err)
+				continue
+			}
+			write := time.Since(start)
+			written = resp.GetWrittenAt().GetToken()
+
+			cached, cachedDur := escalationLookup(client, "escalation_"+role+"_cached", i, userID, errs, cycles,
+				&v1.Consistency{Requirement: &v1.Consistency_MinimizeLatency{MinimizeLatency: true}})
+			fresh, freshDur := escalationLookup(client, "escalation_"+role+"_fresh", i, userID, errs, cycles,
+				&v1.Consistency{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: resp.WrittenAt}})
+			if cached < 0 || fresh < 0 {
+				continue
+			}
+			log.Printf("[authzed_pgdb] [%s] ESCALATION: cycle=%d role=%s cached=%d cached_dur=%s fresh=%d fresh_dur=%s stale=%t write=%s",
+				name, i, role, cached, cachedDur.Truncate(time.Microsecond), fresh, freshDur.Truncate(time.Microsecond), cached != fresh, write.Truncate(time.Microsecond))
+		}
+	}
+	log.Printf("[authzed_pgdb] [%s] DONE: iters=%d", name, cycles)
+	log.Printf("[authzed_pgdb] [%s] ERRORS: %s", name, errs.Summary(cycles))
+	utils.SaveZedToken("authzed_pgdb", name, written)
+}
+
+// escalationOrg finds the org userID belongs to and whether it is already a
+// direct admin there.
+func escalationOrg(client *authzed.Client, userID string) (orgID string, isAdmin bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := streamReadRels(ctx, client, &v1.RelationshipFilter{
+		ResourceType: "organization",
+		OptionalSubjectFilter: &v1.SubjectFilter{
+			SubjectType:       "user",
+			OptionalSubjectId: userID,
+		},
+	}, func(rel *v1.Relationship) {
+		switch rel.Relation {
+		case "member_user":
+			orgID = rel.Resource.ObjectId
+		case "admin_user":
+			orgID, isAdmin = rel.Resource.ObjectId, true
+		}
+	})
+	if err != nil {
+		log.Fatalf("[authzed_pgdb] [org_admin_escalation] read org of user %s: %v", userID, err)
+	}
+	return orgID, isAdmin
+}
+
+// escalationLookup times one manage lookup for the escalation scenario under
+// the given consistency and returns the count, or -1 when it failed, and the
+// duration.
+func escalationLookup(client *authzed.Client, scenario string, iter int, userID string, errs *utils.ErrorTally, iters int, consistency *v1.Consistency) (int, time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	start := time.Now()
+	count := 0
+	err := spicedb.LookupResources(ctx, client, consistency, userID, "manage", func(string) { count++ })
+	dur := time.Since(start)
+	utils.AuditLookup(scenario, userID, "manage", count, dur, err)
+	if err != nil {
+		errs.Survive(err, iters, "[authzed_pgdb] [%s] iter=%d LookupResources failed: %v", scenario, iter, err)
+		return -1, dur
+	}
+	log.Printf("[authzed_pgdb] [%s] iter=%d resources=%d duration=%s", scenario, iter, count, dur.Truncate(time.Millisecond))
+	return count, dur
+}
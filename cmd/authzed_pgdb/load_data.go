@@ -2,22 +2,22 @@ package authzed_pgdb
 
 import (
 	"context"
-	"encoding/csv"
-	"io"
+	"iter"
 	"log"
-	"os"
-	"path/filepath"
 	"time"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	authzed "github.com/authzed/authzed-go/v1"
+	"google.golang.org/protobuf/types/known/structpb"
 
+	"test-tls/authz"
+	"test-tls/dataset"
 	"test-tls/infrastructure"
+	"test-tls/utils"
 )
 
 const (
 	// We now treat CSV as the single source of truth.
-	dataDir   = "data"
 	batchSize = 1000 // maximize SpiceDB's WriteRelationships limit
 )
 
@@ -25,7 +25,9 @@ const (
 var lastConsistencyToken *v1.ZedToken
 
 // AuthzedCreateData loads the deterministic relational ACL dataset generated by
-// cmd/csv/load_data.go into SpiceDB, using schemas.zed as the schema.
+// cmd/csv/load_data.go into SpiceDB. Every schemas/*.zed variant has the same
+// relations, so the data loads under any of them. The ZedToken of the last
+// batch is saved as the load phase's (see utils.SaveZedToken).
 func AuthzedCreateData() {
 	client, _, cancel, err := infrastructure.NewAuthzedPgdbClientFromEnv(context.Background())
 
@@ -37,47 +39,29 @@ func AuthzedCreateData() {
 
 	start := time.Now()
 	relCount := 0
-	batch := make([]*v1.RelationshipUpdate, 0, batchSize)
-
-	log.Printf("[authzed_pgdb] == Starting Authzed data import from CSV in %q ==", dataDir)
 
-	loadOrgMemberships(client, &batch, &relCount, start)
-	loadGroups(client, &batch, &relCount, start)
-	loadGroupMemberships(client, &batch, &relCount, start)
-	loadGroupHierarchy(client, &batch, &relCount, start)
-	loadResources(client, &batch, &relCount, start)
-	loadResourceACL(client, &batch, &relCount, start)
+	log.Printf("[authzed_pgdb] == Starting Authzed data import from CSV in %q ==", utils.DataDir())
 
-	// Flush remaining batch
-	if len(batch) > 0 {
-		writeBatchWithToken(client, batch)
-	}
+	loadOrgMemberships(client, &relCount)
+	loadGroups(client, &relCount)
+	loadGroupMemberships(client, &relCount)
+	loadGroupHierarchy(client, &relCount)
+	loadResources(client, &relCount)
+	loadResourceACL(client, &relCount)
+	loadResourceBans(client, &relCount)
 
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 	log.Printf("[authzed_pgdb] Authzed data import DONE: totalRelationships=%d elapsed=%s lastConsistencyToken=%v", relCount, elapsed, lastConsistencyToken)
-}
-
-// =========================
-// CSV loading helper
-// =========================
-
-func openCSV(name string) (*csv.Reader, *os.File) {
-	full := filepath.Join(dataDir, name)
-	f, err := os.Open(full)
-	if err != nil {
-		log.Fatalf("[authzed_pgdb] open %s: %v", full, err)
-	}
-	r := csv.NewReader(f)
-	return r, f
+	utils.SaveZedToken("authzed_pgdb", "load", lastConsistencyToken.GetToken())
 }
 
 // We deliberately keep object IDs equal to the CSV IDs (plain decimal strings)
 // for 1:1 mapping across backends. If you ever want prefixes like "user42",
 // change these helpers consistently.
-func orgObjectID(id string) string      { return id }
-func userObjectID(id string) string     { return id }
-func groupObjectID(id string) string    { return id }
-func resourceObjectID(id string) string { return id }
+func orgObjectID(id dataset.ID) string      { return id.Raw }
+func userObjectID(id dataset.ID) string     { return id.Raw }
+func groupObjectID(id dataset.ID) string    { return id.Raw }
+func resourceObjectID(id dataset.ID) string { return id.Raw }
 
 // =========================
 // Phase 1: Organizations & org_memberships.csv
@@ -87,59 +71,15 @@ func resourceObjectID(id string) string { return id }
 //   - role in {"member","admin"}
 //   - maps to organization.admin_user / organization.member_user
 
-func loadOrgMemberships(client *authzed.Client, batch *[]*v1.RelationshipUpdate, relCount *int, start time.Time) {
-	r, f := openCSV("org_memberships.csv")
-	defer f.Close()
-
-	// Skip header
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[authzed_pgdb] read org_memberships header: %v", err)
-	}
-
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[authzed_pgdb] read org_memberships row: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[authzed_pgdb] invalid org_memberships row: %#v", rec)
-		}
-
-		orgIDRaw := rec[0]
-		userIDRaw := rec[1]
-		role := rec[2]
-
-		orgID := orgObjectID(orgIDRaw)
-		userID := userObjectID(userIDRaw)
-
-		var relation string
-		switch role {
-		case "admin":
-			relation = "admin_user"
-		default:
-			// treat anything non-admin as member
-			relation = "member_user"
-		}
-
-		*batch = append(*batch, mkCreateRel(
-			"organization", orgID,
-			relation,
-			"user", userID,
+func loadOrgMemberships(client *authzed.Client, relCount *int) {
+	writeRels(client, relCount, "org_memberships", dataset.OrgMemberships(), func(m dataset.OrgMembership) *v1.RelationshipUpdate {
+		return mkCreateRel(
+			"organization", orgObjectID(m.Org),
+			m.Role+"_user",
+			"user", userObjectID(m.User),
 			"",
-		))
-		*relCount++
-		count++
-		flushIfNeeded(client, batch, relCount, start)
-		if count%10000 == 0 {
-			log.Printf("[authzed_pgdb] Loaded org_memberships progress: %d rows (cumulative=%d) elapsed=%s", count, *relCount, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-
-	log.Printf("[authzed_pgdb] Loaded org_memberships: %d relationships (cumulative=%d)", count, *relCount)
+		)
+	})
 }
 
 // =========================
@@ -148,57 +88,23 @@ func loadOrgMemberships(client *authzed.Client, batch *[]*v1.RelationshipUpdate,
 //
 // groups.csv: group_id,org_id
 // We model each group as belonging to its organization via member_group,
-// using the usergroup#member userset as per schemas.zed:
+// using the usergroup#member userset as per schemas/schema3.zed:
 //
 //   relation member_group: usergroup#member
 //
 // This makes members of those groups count as organization members via
 // organization.permission member.
 
-func loadGroups(client *authzed.Client, batch *[]*v1.RelationshipUpdate, relCount *int, start time.Time) {
-	r, f := openCSV("groups.csv")
-	defer f.Close()
-
-	// header: group_id,org_id
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[authzed_pgdb] read groups header: %v", err)
-	}
-
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[authzed_pgdb] read groups row: %v", err)
-		}
-		if len(rec) < 2 {
-			log.Fatalf("[authzed_pgdb] invalid groups row: %#v", rec)
-		}
-
-		groupIDRaw := rec[0]
-		orgIDRaw := rec[1]
-
-		groupID := groupObjectID(groupIDRaw)
-		orgID := orgObjectID(orgIDRaw)
-
+func loadGroups(client *authzed.Client, relCount *int) {
+	writeRels(client, relCount, "groups", dataset.Groups(), func(g dataset.Group) *v1.RelationshipUpdate {
 		// organization#member_group@usergroup:group#member
-		*batch = append(*batch, mkCreateRel(
-			"organization", orgID,
+		return mkCreateRel(
+			"organization", orgObjectID(g.Org),
 			"member_group",
-			"usergroup", groupID,
+			"usergroup", groupObjectID(g.ID),
 			"member",
-		))
-		*relCount++
-		count++
-		flushIfNeeded(client, batch, relCount, start)
-		if count%10000 == 0 {
-			log.Printf("[authzed_pgdb] Loaded groups progress: %d rows (cumulative=%d) elapsed=%s", count, *relCount, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-
-	log.Printf("[authzed_pgdb] Loaded groups -> org.member_group: %d relationships (cumulative=%d)", count, *relCount)
+		)
+	})
 }
 
 // =========================
@@ -217,66 +123,15 @@ func loadGroups(client *authzed.Client, batch *[]*v1.RelationshipUpdate, relCoun
 // Both managers and members get member permission (via permission logic)
 // Managers get escalated permissions
 
-func loadGroupMemberships(client *authzed.Client, batch *[]*v1.RelationshipUpdate, relCount *int, start time.Time) {
-	r, f := openCSV("group_memberships.csv")
-	defer f.Close()
-
-	// header: group_id,user_id,role
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[authzed_pgdb] read group_memberships header: %v", err)
-	}
-
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[authzed_pgdb] read group_memberships row: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[authzed_pgdb] invalid group_memberships row: %#v", rec)
-		}
-
-		groupIDRaw := rec[0]
-		userIDRaw := rec[1]
-		role := rec[2]
-
-		groupID := groupObjectID(groupIDRaw)
-		userID := userObjectID(userIDRaw)
-
-		// Schema 3: support both direct_member and direct_manager roles
-		var relation string
-		switch role {
-		case "direct_manager":
-			relation = "direct_manager_user"
-		case "direct_member":
-			relation = "direct_member_user"
-		default:
-			// Backward compat: treat "member" as direct_member, "admin" as direct_manager
-			if role == "admin" {
-				relation = "direct_manager_user"
-			} else {
-				relation = "direct_member_user"
-			}
-		}
-
-		*batch = append(*batch, mkCreateRel(
-			"usergroup", groupID,
-			relation,
-			"user", userID,
+func loadGroupMemberships(client *authzed.Client, relCount *int) {
+	writeRels(client, relCount, "group_memberships", dataset.GroupMemberships(), func(m dataset.GroupMembership) *v1.RelationshipUpdate {
+		return mkCreateRel(
+			"usergroup", groupObjectID(m.Group),
+			m.Role+"_user",
+			"user", userObjectID(m.User),
 			"",
-		))
-		*relCount++
-		count++
-		flushIfNeeded(client, batch, relCount, start)
-		if count%10000 == 0 {
-			log.Printf("[authzed_pgdb] Loaded group_memberships progress: %d rows (cumulative=%d) elapsed=%s", count, *relCount, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-
-	log.Printf("[authzed_pgdb] Loaded group_memberships: %d relationships (cumulative=%d)", count, *relCount)
+		)
+	})
 }
 
 // =========================
@@ -294,66 +149,16 @@ func loadGroupMemberships(client *authzed.Client, batch *[]*v1.RelationshipUpdat
 //   permission manager = direct_manager_user + manager_group->manager
 // Users in child groups transitively gain parent group permissions
 
-func loadGroupHierarchy(client *authzed.Client, batch *[]*v1.RelationshipUpdate, relCount *int, start time.Time) {
-	r, f := openCSV("group_hierarchy.csv")
-	defer f.Close()
-
-	// header: parent_group_id,child_group_id,relation
-	if _, err := r.Read(); err != nil {
-		// File may not exist if no hierarchy data
-		if os.IsNotExist(err) {
-			log.Printf("[authzed_pgdb] group_hierarchy.csv not found, skipping nested groups")
-			return
-		}
-		log.Fatalf("[authzed_pgdb] read group_hierarchy header: %v", err)
-	}
-
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[authzed_pgdb] read group_hierarchy row: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[authzed_pgdb] invalid group_hierarchy row: %#v", rec)
-		}
-
-		parentGroupIDRaw := rec[0]
-		childGroupIDRaw := rec[1]
-		hierarchyRelation := rec[2]
-
-		parentGroupID := groupObjectID(parentGroupIDRaw)
-		childGroupID := groupObjectID(childGroupIDRaw)
-
-		var relation string
-		switch hierarchyRelation {
-		case "manager_group":
-			relation = "manager_group"
-		case "member_group":
-			relation = "member_group"
-		default:
-			log.Fatalf("[authzed_pgdb] unknown group hierarchy relation: %q", hierarchyRelation)
-		}
-
+func loadGroupHierarchy(client *authzed.Client, relCount *int) {
+	writeRels(client, relCount, "group_hierarchy", dataset.GroupHierarchy(), func(e dataset.GroupEdge) *v1.RelationshipUpdate {
 		// usergroup:parent_group#member_group@usergroup:child_group (no userset reference)
-		*batch = append(*batch, mkCreateRel(
-			"usergroup", parentGroupID,
-			relation,
-			"usergroup", childGroupID,
+		return mkCreateRel(
+			"usergroup", groupObjectID(e.Parent),
+			e.Relation,
+			"usergroup", groupObjectID(e.Child),
 			"",
-		))
-		*relCount++
-		count++
-		flushIfNeeded(client, batch, relCount, start)
-		if count%10000 == 0 {
-			log.Printf("[authzed_pgdb] Loaded group_hierarchy progress: %d rows (cumulative=%d) elapsed=%s", count, *relCount, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-
-	log.Printf("[authzed_pgdb] Loaded group_hierarchy: %d relationships (cumulative=%d)", count, *relCount)
+		)
+	})
 }
 
 // =========================
@@ -363,64 +168,31 @@ func loadGroupHierarchy(client *authzed.Client, batch *[]*v1.RelationshipUpdate,
 // resources.csv: resource_id,org_id
 // We assign each resource to its owning organization via resource.org.
 
-func loadResources(client *authzed.Client, batch *[]*v1.RelationshipUpdate, relCount *int, start time.Time) {
-	r, f := openCSV("resources.csv")
-	defer f.Close()
-
-	// header: resource_id,org_id
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[authzed_pgdb] read resources header: %v", err)
-	}
-
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[authzed_pgdb] read resources row: %v", err)
-		}
-		if len(rec) < 2 {
-			log.Fatalf("[authzed_pgdb] invalid resources row: %#v", rec)
-		}
-
-		resIDRaw := rec[0]
-		orgIDRaw := rec[1]
-
-		resID := resourceObjectID(resIDRaw)
-		orgID := orgObjectID(orgIDRaw)
-
-		*batch = append(*batch, mkCreateRel(
-			"resource", resID,
+func loadResources(client *authzed.Client, relCount *int) {
+	writeRels(client, relCount, "resources", dataset.Resources(), func(r dataset.Resource) *v1.RelationshipUpdate {
+		return mkCreateRel(
+			"resource", resourceObjectID(r.ID),
 			"org",
-			"organization", orgID,
+			"organization", orgObjectID(r.Org),
 			"",
-		))
-		*relCount++
-		count++
-		flushIfNeeded(client, batch, relCount, start)
-		if count%10000 == 0 {
-			log.Printf("[authzed_pgdb] Loaded resources -> resource.org: %d relationships (cumulative=%d) elapsed=%s", count, *relCount, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-
-	log.Printf("[authzed_pgdb] Loaded resources -> resource.org: %d relationships (cumulative=%d)", count, *relCount)
+		)
+	})
 }
 
 // =========================
 // Phase 5: resource_acl.csv -> resource.*
 // =========================
 //
-// resource_acl.csv: resource_id,subject_type,subject_id,relation
+// resource_acl.csv: resource_id,subject_type,subject_id,relation[,valid_from,valid_until]
 //   - subject_type in {"user","group"}
 //   - relation in {"manager_user","viewer_user","manager_group","viewer_group"}
 //
-// Schema 3 mapping to schemas.zed:
+// Schema 3 mapping to schemas/schema3.zed:
 //   - user + manager_user -> resource.manager_user@user
 //   - user + viewer_user -> resource.viewer_user@user
 //   - group + manager_group -> resource.manager_group@usergroup#manager
 //   - group + viewer_group -> resource.viewer_group@usergroup#member
+//   - user grants with a validity window carry the valid_window caveat
 //
 // Permission inheritance:
 //   permission manage = manager_user + manager_group + org->admin
@@ -431,125 +203,40 @@ func loadResources(client *authzed.Client, batch *[]*v1.RelationshipUpdate, relC
 // Key benefit: explicit user/group split enables clear auditability
 // of who can do what without ambiguity
 
-func loadResourceACL(client *authzed.Client, batch *[]*v1.RelationshipUpdate, relCount *int, start time.Time) {
-	r, f := openCSV("resource_acl.csv")
-	defer f.Close()
-
-	// header: resource_id,subject_type,subject_id,relation
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[authzed_pgdb] read resource_acl header: %v", err)
-	}
-
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[authzed_pgdb] read resource_acl row: %v", err)
-		}
-		if len(rec) < 4 {
-			log.Fatalf("[authzed_pgdb] invalid resource_acl row: %#v", rec)
-		}
-
-		resIDRaw := rec[0]
-		subjectType := rec[1]
-		subjectIDRaw := rec[2]
-		aclRelation := rec[3]
-
-		resID := resourceObjectID(resIDRaw)
-
-		switch subjectType {
-		case "user":
-			userID := userObjectID(subjectIDRaw)
-
-			switch aclRelation {
-			case "manager_user":
-				*batch = append(*batch, mkCreateRel(
-					"resource", resID,
-					"manager_user",
-					"user", userID,
-					"",
-				))
-			case "viewer_user":
-				*batch = append(*batch, mkCreateRel(
-					"resource", resID,
-					"viewer_user",
-					"user", userID,
-					"",
-				))
-			// Backward compat: old schema used "manager", "viewer"
-			case "manager":
-				*batch = append(*batch, mkCreateRel(
-					"resource", resID,
-					"manager_user",
-					"user", userID,
-					"",
-				))
-			case "viewer":
-				*batch = append(*batch, mkCreateRel(
-					"resource", resID,
-					"viewer_user",
-					"user", userID,
-					"",
-				))
-			default:
-				log.Fatalf("[authzed_pgdb] unknown ACL relation for user: %q", aclRelation)
-			}
-
-		case "group":
-			groupID := groupObjectID(subjectIDRaw)
-
-			switch aclRelation {
-			case "manager_group":
-				// resource.manager_group: usergroup#manager (Schema 3)
-				*batch = append(*batch, mkCreateRel(
-					"resource", resID,
-					"manager_group",
-					"usergroup", groupID,
-					"manager",
-				))
-			case "viewer_group":
-				// resource.viewer_group: usergroup#member (Schema 3)
-				*batch = append(*batch, mkCreateRel(
-					"resource", resID,
-					"viewer_group",
-					"usergroup", groupID,
-					"member",
-				))
-			// Backward compat: old schema used "manager", "viewer"
-			case "manager":
-				*batch = append(*batch, mkCreateRel(
-					"resource", resID,
-					"manager_group",
-					"usergroup", groupID,
-					"manager",
-				))
-			case "viewer":
-				*batch = append(*batch, mkCreateRel(
-					"resource", resID,
-					"viewer_group",
-					"usergroup", groupID,
-					"member",
-				))
-			default:
-				log.Fatalf("[authzed_pgdb] unknown ACL relation for group: %q", aclRelation)
-			}
-
+func loadResourceACL(client *authzed.Client, relCount *int) {
+	writeRels(client, relCount, "resource_acl", dataset.ResourceACL(), func(a dataset.ACL) *v1.RelationshipUpdate {
+		resID := resourceObjectID(a.Resource)
+		switch a.Relation {
+		case "manager_group":
+			// resource.manager_group: usergroup#manager (Schema 3)
+			return mkCreateRel("resource", resID, a.Relation, "usergroup", groupObjectID(a.Subject), "manager")
+		case "viewer_group":
+			// resource.viewer_group: usergroup#member (Schema 3)
+			return mkCreateRel("resource", resID, a.Relation, "usergroup", groupObjectID(a.Subject), "member")
 		default:
-			log.Fatalf("[authzed_pgdb] unknown subject_type in resource_acl: %q", subjectType)
+			return withGrantWindow(mkCreateRel("resource", resID, a.Relation, "user", userObjectID(a.Subject), ""), a.Window)
 		}
+	})
+}
 
-		*relCount++
-		count++
-		flushIfNeeded(client, batch, relCount, start)
-		if count%10000 == 0 {
-			log.Printf("[authzed_pgdb] Loaded resource_acl progress: %d rows (cumulative=%d) elapsed=%s", count, *relCount, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
+// =========================
+// Phase 6: resource_bans.csv -> resource.banned_user (BENCH_DENIALS)
+// =========================
+//
+// resource_bans.csv: resource_id,user_id
+//   - user -> resource.banned_user@user
+//
+// Only the schema of create-schema with BENCH_DENIALS=1 has the relation
+// (see withDenials), so without it the bans are skipped:
+//   permission view = (viewer_user + viewer_group + manage + org->member) - banned_user
 
-	log.Printf("[authzed_pgdb] Loaded resource_acl: %d relationships (cumulative=%d)", count, *relCount)
+func loadResourceBans(client *authzed.Client, relCount *int) {
+	if !utils.DenialsFromEnv() {
+		return
+	}
+	writeRels(client, relCount, "resource_bans", dataset.ResourceBans(), func(b dataset.Ban) *v1.RelationshipUpdate {
+		return mkCreateRel("resource", resourceObjectID(b.Resource), authz.BannedUser, "user", userObjectID(b.User), "")
+	})
 }
 
 // ============================
@@ -579,17 +266,44 @@ func mkCreateRel(
 	}
 }
 
-func flushIfNeeded(client *authzed.Client, batch *[]*v1.RelationshipUpdate, relCount *int, start time.Time) {
-	if len(*batch) < batchSize {
-		return
+// withGrantWindow attaches the valid_window caveat to a user grant when the
+// resource_acl row carries a validity window; unbounded grants stay plain.
+func withGrantWindow(u *v1.RelationshipUpdate, w utils.GrantWindow) *v1.RelationshipUpdate {
+	if !w.Bounded() {
+		return u
+	}
+	from, until := utils.GrantWindowMin, utils.GrantWindowMax
+	if w.From != nil {
+		from = *w.From
+	}
+	if w.Until != nil {
+		until = *w.Until
 	}
-	writeBatchWithToken(client, *batch)
-	*batch = (*batch)[:0]
+	ctx, err := structpb.NewStruct(map[string]any{
+		"valid_from":  from.UTC().Format(time.RFC3339),
+		"valid_until": until.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		log.Fatalf("[authzed_pgdb] build valid_window context: %v", err)
+	}
+	u.Relationship.OptionalCaveat = &v1.ContextualizedCaveat{CaveatName: "valid_window", Context: ctx}
+	return u
+}
 
-	if (*relCount)%1_000_000 == 0 {
-		log.Printf("[authzed_pgdb] ... %d relationships inserted (elapsed=%s)",
-			*relCount, time.Since(start).Truncate(time.Millisecond))
+// writeRels writes one relationship per record of rows in batches of
+// batchSize, counting them for table into relCount.
+func writeRels[T any](client *authzed.Client, relCount *int, table string, rows iter.Seq[T], rel func(T) *v1.RelationshipUpdate) {
+	progress := dataset.NewProgress("authzed_pgdb", table, relCount)
+	batch := make([]*v1.RelationshipUpdate, 0, batchSize)
+	for records := range dataset.Batches(rows, batchSize) {
+		batch = batch[:0]
+		for _, rec := range records {
+			batch = append(batch, rel(rec))
+		}
+		writeBatchWithToken(client, batch)
+		progress.Add(len(records))
 	}
+	progress.Done()
 }
 
 func writeBatchWithToken(client *authzed.Client, batch []*v1.RelationshipUpdate) {
@@ -0,0 +1,55 @@
+package authzed_pgdb
+
+import (
+	"context"
+	"log"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	authzed "github.com/authzed/authzed-go/v1"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// userOffboarder deletes every relationship with a user as subject
+// (utils.UserOffboarder): one DeleteRelationships per resource type that
+// relates users, filtered on the subject. SpiceDB reports no counts, so rows
+// is 0.
+type userOffboarder struct {
+	client *authzed.Client
+}
+
+func (o userOffboarder) Offboard(ctx context.Context, userID string) (int, error) {
+	for _, resourceType := range []string{"resource", "organization", "usergroup"} {
+		_, err := o.client.DeleteRelationships(ctx, &v1.DeleteRelationshipsRequest{
+			RelationshipFilter: &v1.RelationshipFilter{
+				ResourceType: resourceType,
+				OptionalSubjectFilter: &v1.SubjectFilter{
+					SubjectType:       "user",
+					OptionalSubjectId: userID,
+				},
+			},
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
+
+// AuthzedOffboard benchmarks deleting the users of BENCH_OFFBOARD_USERS and
+// checks they hold nothing afterwards; see utils.RunOffboarding. The checks
+// run at SPICEDB_CONSISTENCY, so a stale verify count under
+// minimize_latency is the cache window, not a failed delete.
+func AuthzedOffboard() {
+	client, _, cancel, err := infrastructure.NewAuthzedPgdbClientFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[authzed_pgdb] failed to create authzed client: %v", err)
+	}
+	defer cancel()
+	defer client.Close()
+
+	utils.LogConsistency("authzed_pgdb", useBenchConsistency(client))
+	utils.RunOffboarding("authzed_pgdb", userOffboarder{client: client}, newChecker(client))
+	log.Println("[authzed_pgdb] == SpiceDB user offboarding DONE ==")
+}
@@ -0,0 +1,134 @@
+package authzed_pgdb
+
+import (
+	"context"
+	"log"
+	"time"
+
+	authzed "github.com/authzed/authzed-go/v1"
+
+	"test-tls/authz"
+	"test-tls/authz/spicedb"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// fullyConsistent is the consistency of the sampling reads, and the default
+// of the timed calls.
+var fullyConsistent = spicedb.FullyConsistent
+
+// benchConsistency is the consistency the timed checks and lookups are made
+// with; see useBenchConsistency.
+var benchConsistency = fullyConsistent
+
+// useBenchConsistency sets benchConsistency from SPICEDB_CONSISTENCY (see
+// spicedb.Consistencies, default full) and returns the setting for the
+// benchmark's CONSISTENCY line. zedtoken reads at_least_as_fresh as the
+// ZedToken a load or write phase of an earlier run saved: that of
+// SPICEDB_ZEDTOKEN_PHASE, else the latest (see utils.LoadZedToken).
+func useBenchConsistency(client *authzed.Client) string {
+	mode := utils.GetEnvWithDefault("SPICEDB_CONSISTENCY", "full")
+	if mode == "zedtoken" {
+		phase, token, err := utils.LoadZedToken("authzed_pgdb", utils.GetEnvWithDefault("SPICEDB_ZEDTOKEN_PHASE", ""))
+		if err != nil {
+			log.Fatalf("[authzed_pgdb] SPICEDB_CONSISTENCY=zedtoken: %v", err)
+		}
+		log.Printf("[authzed_pgdb] ZEDTOKEN: using phase=%s token=%s age=%s", phase, token.Token, time.Since(token.At).Truncate(time.Second))
+		benchConsistency = spicedb.AtLeastAsFresh(token.Token)
+		return "spicedb=zedtoken phase=" + phase
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	consistency, err := spicedb.ParseConsistency(ctx, client, mode)
+	cancel()
+	if err != nil {
+		log.Fatalf("[authzed_pgdb] SPICEDB_CONSISTENCY: %v", err)
+	}
+	benchConsistency = consistency
+	return "spicedb=" + mode
+}
+
+// checkPermission is the CheckPermission call timed by the check_* scenarios.
+func checkPermission(ctx context.Context, client *authzed.Client, resourceID, userID, permission string) (bool, error) {
+	return spicedb.Check(ctx, client, benchConsistency, resourceID, userID, permission)
+}
+
+// checkPermissionsBulk is the CheckBulkPermissions call timed by the
+// check_bulk_* scenarios.
+func checkPermissionsBulk(ctx context.Context, client *authzed.Client, pairs []spicedb.Pair, permission string) ([]bool, error) {
+	return spicedb.CheckBulk(ctx, client, benchConsistency, pairs, permission)
+}
+
+// lookupResources streams LookupResources results for a user, as timed by the
+// lookup_resources_* scenarios.
+func lookupResources(ctx context.Context, client *authzed.Client, userID, permission string, handle func(resID string)) error {
+	return spicedb.LookupResources(ctx, client, benchConsistency, userID, permission, handle)
+}
+
+// newChecker returns the benchmark calls over client at benchConsistency.
+func newChecker(client *authzed.Client) authz.Checker {
+	return spicedb.NewChecker(client, spicedb.Config{Consistency: benchConsistency})
+}
+
+// AuthzedServe serves Check/Lookup over HTTP using the benchmark calls.
+func AuthzedServe() {
+	client, _, cancel, err := infrastructure.NewAuthzedPgdbClientFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[authzed_pgdb] failed to create authzed client: %v", err)
+	}
+	defer cancel()
+	defer client.Close()
+
+	utils.Serve("authzed_pgdb", newChecker(client))
+}
+
+// AuthzedReplayAudit re-runs a recorded audit file (REPLAY_AUDIT_FILE) through
+// the benchmark calls.
+func AuthzedReplayAudit() {
+	client, _, cancel, err := infrastructure.NewAuthzedPgdbClientFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[authzed_pgdb] failed to create authzed client: %v", err)
+	}
+	defer cancel()
+	defer client.Close()
+
+	utils.ReplayAudit("authzed_pgdb", newChecker(client))
+}
+
+// AuthzedVerifyFixture checks the benchmark calls against the known
+// answers of the fixture dataset (see utils.VerifyFixture).
+func AuthzedVerifyFixture() {
+	client, _, cancel, err := infrastructure.NewAuthzedPgdbClientFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[authzed_pgdb] failed to create authzed client: %v", err)
+	}
+	defer cancel()
+	defer client.Close()
+
+	utils.VerifyFixture("authzed_pgdb", newChecker(client))
+}
+
+// AuthzedExportPermissions writes a permission snapshot of the benchmark
+// queries for diff-permissions (see utils.ExportPermissions).
+func AuthzedExportPermissions() {
+	client, _, cancel, err := infrastructure.NewAuthzedPgdbClientFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[authzed_pgdb] failed to create authzed client: %v", err)
+	}
+	defer cancel()
+	defer client.Close()
+
+	utils.ExportPermissions("authzed_pgdb", newChecker(client))
+}
+
+// AuthzedWorker replays the shard a distributed coordinator hands out and
+// streams the results back (see utils.RunWorker).
+func AuthzedWorker() {
+	client, _, cancel, err := infrastructure.NewAuthzedPgdbClientFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[authzed_pgdb] failed to create authzed client: %v", err)
+	}
+	defer cancel()
+	defer client.Close()
+
+	utils.RunWorker("authzed_pgdb", newChecker(client))
+}
@@ -0,0 +1,63 @@
+package authzed_pgdb
+
+import (
+	"context"
+	"log"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	authzed "github.com/authzed/authzed-go/v1"
+
+	"test-tls/utils"
+)
+
+// watchWrites subscribes to the Watch API for the resource#viewer_user grants
+// of userID, starting at the current revision, and hands each change to the
+// tracker of its operation, keyed by resource id: touches to grant, deletes to
+// revoke. It returns a stop func, or an error when the stream cannot be
+// opened (Watch needs track_commit_timestamp on in Postgres).
+func watchWrites(client *authzed.Client, userID string, grant, revoke *utils.VisibilityTracker) (func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	schema, err := client.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stream, err := client.Watch(ctx, &v1.WatchRequest{
+		OptionalStartCursor: schema.GetReadAt(),
+		OptionalRelationshipFilters: []*v1.RelationshipFilter{{
+			ResourceType:          "resource",
+			OptionalRelation:      "viewer_user",
+			OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: "user", OptionalSubjectId: userID},
+		}},
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("[authzed_pgdb] watch stream ended: %v", err)
+				}
+				return
+			}
+			for _, u := range resp.GetUpdates() {
+				resourceID := u.GetRelationship().GetResource().GetObjectId()
+				if u.GetOperation() == v1.RelationshipUpdate_OPERATION_DELETE {
+					revoke.Seen(resourceID)
+				} else {
+					grant.Seen(resourceID)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
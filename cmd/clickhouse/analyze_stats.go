@@ -0,0 +1,101 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// ClickhouseAnalyzeStats reports row counts, per-relation ACL cardinality and the
+// same relation fan-out summaries printed by `csv generate`, computed from the
+// loaded tables so drift between the CSV dataset and ClickHouse is visible.
+func ClickhouseAnalyzeStats() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	db, cleanup, err := infrastructure.NewClickhouseFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[clickhouse] failed to create clickhouse client: %v", err)
+	}
+	defer cleanup()
+
+	start := time.Now()
+
+	tables := []string{"organizations", "users", "groups", "org_memberships", "group_memberships", "group_hierarchy", "group_members_expanded", "resources", "resource_acl", "user_resource_permissions"}
+	for _, table := range tables {
+		var n int64
+		if err := db.QueryRowContext(ctx, `SELECT count() FROM `+table).Scan(&n); err != nil {
+			log.Fatalf("[clickhouse] stats: count %s failed: %v", table, err)
+		}
+		log.Printf("[clickhouse] table %s: %d rows", table, n)
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT toString(subject_type), toString(relation), toInt64(count()), toInt64(uniqExact(subject_id)), toInt64(uniqExact(resource_id))
+		FROM resource_acl
+		GROUP BY subject_type, relation
+		ORDER BY subject_type, relation`)
+	if err != nil {
+		log.Fatalf("[clickhouse] stats: resource_acl cardinality failed: %v", err)
+	}
+	for rows.Next() {
+		var subjectType, relation string
+		var edges, subjects, resources int64
+		if err := rows.Scan(&subjectType, &relation, &edges, &subjects, &resources); err != nil {
+			log.Fatalf("[clickhouse] stats: scan resource_acl cardinality failed: %v", err)
+		}
+		log.Printf("[clickhouse] acl %s/%s: %d edges, %d distinct subjects, %d distinct resources",
+			subjectType, relation, edges, subjects, resources)
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatalf("[clickhouse] stats: resource_acl cardinality failed: %v", err)
+	}
+	rows.Close()
+
+	// group_memberships.role is Enum8('member','manager'); ids are cast so the
+	// scan target does not depend on the UInt32 column type.
+	stats := utils.NewRelationStats()
+	fanouts := []struct {
+		dst   map[int]int
+		query string
+	}{
+		{stats.OrgToUsers, `SELECT toInt64(org_id), toInt64(count()) FROM org_memberships GROUP BY org_id`},
+		{stats.UserToOrgs, `SELECT toInt64(user_id), toInt64(count()) FROM org_memberships GROUP BY user_id`},
+		{stats.GroupToMembers, `SELECT toInt64(group_id), toInt64(count()) FROM group_memberships WHERE role = 'member' GROUP BY group_id`},
+		{stats.GroupToManagers, `SELECT toInt64(group_id), toInt64(count()) FROM group_memberships WHERE role = 'manager' GROUP BY group_id`},
+		{stats.UserToGroups, `SELECT toInt64(user_id), toInt64(count()) FROM group_memberships GROUP BY user_id`},
+		{stats.GroupToChildGroups, `SELECT toInt64(parent_group_id), toInt64(count()) FROM group_hierarchy GROUP BY parent_group_id`},
+		{stats.UserToResources, `SELECT toInt64(subject_id), toInt64(count()) FROM resource_acl WHERE subject_type = 'user' GROUP BY subject_id`},
+		{stats.ResourceToUsers, `SELECT toInt64(resource_id), toInt64(count()) FROM resource_acl WHERE subject_type = 'user' GROUP BY resource_id`},
+	}
+	for _, f := range fanouts {
+		if err := scanCounts(ctx, db, f.query, f.dst); err != nil {
+			log.Fatalf("[clickhouse] stats: %q failed: %v", f.query, err)
+		}
+	}
+	stats.Summarize("clickhouse")
+
+	log.Printf("[clickhouse] analyze stats DONE elapsed=%s", time.Since(start).Truncate(time.Millisecond))
+}
+
+// scanCounts reads (id, count) rows produced by query into dst.
+func scanCounts(ctx context.Context, db *sql.DB, query string, dst map[int]int) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, n int64
+		if err := rows.Scan(&id, &n); err != nil {
+			return err
+		}
+		dst[int(id)] = int(n)
+	}
+	return rows.Err()
+}
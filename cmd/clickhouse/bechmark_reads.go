@@ -3,10 +3,13 @@ package clickhouse
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log"
-	"os"
 	"time"
 
+	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+
+	"test-tls/ids"
 	"test-tls/infrastructure"
 	"test-tls/utils"
 )
@@ -30,25 +33,78 @@ func ClickhouseBenchmarkReads() {
 		log.Fatalf("[clickhouse] failed to create clickhouse client: %v", err)
 	}
 	defer cleanup()
+	utils.GuardDataset("clickhouse", datasetStore{db})
+	stopAudit := utils.StartAudit("clickhouse")
+	defer stopAudit()
 
 	// Log startup summary including any env-overridden lookup users.
 	start := time.Now()
-	heavyManageUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
-	regularViewUser := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	heavyManageUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
+	regularViewUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 	log.Printf("[clickhouse] Running in streaming-only mode (no precollection). elapsed=%s heavyManageUser=%q regularViewUser=%q",
 		elapsed, heavyManageUser, regularViewUser)
 
+	stopCost := utils.StartCost("clickhouse", costMeter(db, start)) // Per-scenario query_log costs (BENCH_COST)
+
 	// Run individual benchmark scenarios
-	runCheckManageDirectUser(db)          // Test direct manager_user relationships in resource_acl
-	runCheckManageOrgAdmin(db)            // Test org->admin permission paths
-	runCheckViewViaGroupMember(db)        // Test permissions via group members and group membership
-	runLookupResourcesManageHeavyUser(db) // Test resource lookup for users with many manage permissions
-	runLookupResourcesViewRegularUser(db) // Test resource lookup for users with regular view permissions
+	reads := func() {
+		runCheckManageDirectUser(db)          // Test direct manager_user relationships in resource_acl
+		runCheckManageOrgAdmin(db)            // Test org->admin permission paths
+		runCheckViewViaGroupMember(db)        // Test permissions via group members and group membership
+		runCheckTimeBoundedDirectUser(db)     // Test direct grants filtered by valid_from/valid_until
+		runLookupResourcesManageHeavyUser(db) // Test resource lookup for users with many manage permissions
+		runLookupResourcesViewRegularUser(db) // Test resource lookup for users with regular view permissions
+		runLookupResourcesMix(db)             // Test resource lookup for a stratified mix of users (BENCH_LOOKUPRES_MIX_USERS)
+		runLookupResourcesPercentiles(db)     // Test resource lookup for the p10/p50/p90/p99 users by grant count (BENCH_LOOKUPRES_*_PCT_USERS)
+		runViralFanIn(db)                     // Test checks and lookups on resources shared with many users directly (BENCH_VIRAL_RESOURCES)
+		runWorstCaseChecks(db)                // Test view checks whose only grant is the deepest nested group path
+		runListRecentViewable(db)             // Test the authorized listing of the newest viewable resources (BENCH_LIST_RECENT_*)
+		runCustomScenarios(db)                // Run user-defined scenarios from BENCH_CUSTOM_SCENARIOS
+		runWorkload(db)                       // Run the blended request mix of BENCH_WORKLOAD
+		runAccessSummary(db)                  // Count the resources sampled users can view and manage (dashboard summary)
+		runMiddlewareOverhead(db)             // Fetch a resource with and without a view check in front (authorization middleware)
+	}
+	utils.RunMaintenancePhases("clickhouse", "optimize_final", optimizeTables(db), func() {
+		utils.RunCachePhases("clickhouse", flushCaches(db), reads)
+	})
+	runFullExport(db) // Stream every resource_acl row (BENCH_EXPORT_ITER)
+	stopCost()
+	runDriverOverhead(db) // Compare database/sql against the native driver (BENCH_DRIVER_COMPARE)
 
 	log.Println("[clickhouse] == ClickHouse read benchmarks DONE ==")
 }
 
+// flushCaches drops ClickHouse's mark and uncompressed block caches for the
+// cold pass of BENCH_CACHE_COMPARE. The page cache of the OS is left alone.
+// The read-only bench user (CH_BENCH_USER) needs the SYSTEM DROP CACHE grant.
+func flushCaches(db *sql.DB) utils.CacheFlush {
+	return func(ctx context.Context) error {
+		for _, stmt := range []string{"SYSTEM DROP MARK CACHE", "SYSTEM DROP UNCOMPRESSED CACHE"} {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("%s: %w", stmt, err)
+			}
+		}
+		return nil
+	}
+}
+
+// optimizeTables forces a merge of every part of the ACL tables the scenarios
+// read, the maintenance of BENCH_MAINTENANCE. OPTIMIZE ... FINAL rewrites
+// each table even when it is down to one part, so every round costs a full
+// read and write of it. The bench user (CH_BENCH_USER) needs the OPTIMIZE
+// grant.
+func optimizeTables(db *sql.DB) utils.Maintenance {
+	return func(ctx context.Context) error {
+		for _, table := range []string{"resource_acl", "user_resource_permissions"} {
+			if _, err := db.ExecContext(ctx, "OPTIMIZE TABLE "+table+" FINAL"); err != nil {
+				return fmt.Errorf("optimize %s: %w", table, err)
+			}
+		}
+		return nil
+	}
+}
+
 // streamQuery executes a query and invokes the handle callback for each row.
 // This helper avoids collecting results into memory, making it suitable for
 // processing large datasets without memory overhead.
@@ -67,6 +123,25 @@ func streamQuery(ctx context.Context, db *sql.DB, query string, args []any, hand
 	return rows.Err()
 }
 
+// lookupCountSQL counts the resources the user (first argument) holds the
+// relation (second argument) on directly, as timed by the lookup_resources_*
+// scenarios.
+const lookupCountSQL = `
+	SELECT COUNT(DISTINCT resource_id)
+	FROM resource_acl
+	WHERE subject_type = 'user' AND subject_id = ? AND relation = ?
+	  AND ` + activeGrantSQL + `
+	`
+
+// lookupCountParamsSQL is lookupCountSQL with server-side parameters, run
+// when BENCH_PREPARED_STATEMENTS=true (see chauthz.CheckParams).
+const lookupCountParamsSQL = `
+	SELECT COUNT(DISTINCT resource_id)
+	FROM resource_acl
+	WHERE subject_type = 'user' AND subject_id = {user:UInt32} AND relation = {relation:String}
+	  AND ` + activeGrantSQL + `
+	`
+
 // runLookupBench runs SELECT COUNT(*) queries for a given user and relation,
 // counting the number of resources returned and reporting timing metrics.
 // Each iteration queries all accessible resources and counts them.
@@ -84,37 +159,47 @@ func runLookupBench(db *sql.DB, name, relation, userID string, iters int) {
 	}
 
 	log.Printf("[clickhouse] [%s] iterations=%d user=%s", name, iters, userID)
+	utils.LogScenarioConfig("clickhouse", name, iters, 30*time.Second, userID)
 
 	var total time.Duration
-	var lastCount int
+	var lastCount, ok int
+	errs := utils.NewErrorTally()
 
 	for i := range iters {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		start := time.Now()
 
-		// Query for all resources where the user has the specified relation
-		query := `
-		SELECT COUNT(DISTINCT resource_id)
-		FROM resource_acl
-		WHERE subject_type = 'user' AND subject_id = ? AND relation = ?
-		`
 		var count int
-		err := db.QueryRowContext(ctx, query, userID, relation).Scan(&count)
+		var err error
+		if utils.PreparedStatements() {
+			pctx := clickhouse.Context(ctx, clickhouse.WithParameters(clickhouse.Parameters{"user": userID, "relation": relation}))
+			err = db.QueryRowContext(pctx, lookupCountParamsSQL).Scan(&count)
+		} else {
+			err = db.QueryRowContext(ctx, lookupCountSQL, userID, relation).Scan(&count)
+		}
 		cancel()
+		utils.AuditLookup(name, userID, relation, count, time.Since(start), err)
 		if err != nil {
-			log.Fatalf("[clickhouse] [%s] query failed: %v", name, err)
+			class := errs.Record(err)
+			log.Printf("[clickhouse] [%s] iter=%d LookupResources failed class=%s: %v", name, i, class, err)
+			continue
 		}
 
 		dur := time.Since(start)
 		total += dur
 		lastCount = count
+		ok++
 
 		log.Printf("[clickhouse] [%s] iter=%d resources=%d duration=%s", name, i, count, dur.Truncate(time.Millisecond))
 	}
 
-	avg := time.Duration(int64(total) / int64(iters))
+	avg := time.Duration(0)
+	if ok > 0 {
+		avg = time.Duration(int64(total) / int64(ok))
+	}
 	log.Printf("[clickhouse] [%s] DONE: iters=%d lastCount=%d avg=%s total=%s",
 		name, iters, lastCount, avg, total)
+	log.Printf("[clickhouse] [%s] ERRORS: %s", name, errs.Summary(iters))
 }
 
 // runCheckManageDirectUser benchmarks queries for "manager" relation
@@ -125,9 +210,11 @@ func runCheckManageDirectUser(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_CHECK_DIRECT_SUPER_ITER", 1000)
 
 	log.Printf("[clickhouse] [check_manage_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("clickhouse", "check_manage_direct_user", iters, 2*time.Second)
 	done := 0
+	errs := utils.NewErrorTally()
 	// Hybrid behavior: if BENCH_LOOKUPRES_MANAGE_USER is set, prefer LookupResources for that user
-	lookupUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 
 	for done < iters {
@@ -149,6 +236,10 @@ func runCheckManageDirectUser(db *sql.DB) {
 					return err
 				}
 
+				if !utils.InOrgScope(ids.Resource, resourceID) {
+					return nil
+				}
+
 				// For each resource, verify the permission exists (mimics CheckPermission)
 				cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 				start := time.Now()
@@ -156,17 +247,25 @@ func runCheckManageDirectUser(db *sql.DB) {
 				SELECT 1
 				FROM resource_acl
 				WHERE resource_id = ? AND subject_type = 'user' AND subject_id = ? AND relation = 'manager'
+				  AND ` + activeGrantSQL + `
 				LIMIT 1
 				`
 				var exists int
 				err := db.QueryRowContext(cctx, checkQuery, resourceID, lookupUser).Scan(&exists)
 				ccancel()
+				if err == sql.ErrNoRows {
+					err = nil
+				}
+				utils.AuditCheck("check_manage_direct_user", resourceID, lookupUser, "manager", exists == 1, time.Since(start), err)
 				if err != nil && err != sql.ErrNoRows {
-					return err
+					class := errs.Record(err)
+					log.Printf("[clickhouse] [check_manage_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+					done++
+					return nil
 				}
 
 				dur := time.Since(start)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[clickhouse] [check_manage_direct_user] lookup iter=%d resource=%d user=%s dur=%s", done, resourceID, lookupUser, dur)
 				}
 				done++
@@ -174,8 +273,8 @@ func runCheckManageDirectUser(db *sql.DB) {
 			})
 			cancel()
 
-			if err != nil && err != context.DeadlineExceeded {
-				log.Fatalf("[clickhouse] [check_manage_direct_user] streamQuery failed: %v", err)
+			if err != nil {
+				errs.Survive(err, iters, "[clickhouse] [check_manage_direct_user] streamQuery failed: %v", err)
 			}
 
 			if done < sampleLimit {
@@ -206,6 +305,10 @@ func runCheckManageDirectUser(db *sql.DB) {
 				return err
 			}
 
+			if !utils.InOrgScope(ids.Resource, resourceID) {
+				return nil
+			}
+
 			// Verify the permission exists
 			cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 			start := time.Now()
@@ -213,18 +316,26 @@ func runCheckManageDirectUser(db *sql.DB) {
 			SELECT 1
 			FROM resource_acl
 			WHERE resource_id = ? AND subject_type = 'user' AND subject_id = ? AND relation = 'manager'
+			  AND ` + activeGrantSQL + `
 			LIMIT 1
 			`
 			var exists int
 			err := db.QueryRowContext(cctx, checkQuery, resourceID, userID).Scan(&exists)
 			ccancel()
+			if err == sql.ErrNoRows {
+				err = nil
+			}
+			utils.AuditCheck("check_manage_direct_user", resourceID, userID, "manager", exists == 1, time.Since(start), err)
 			if err != nil && err != sql.ErrNoRows {
-				return err
+				class := errs.Record(err)
+				log.Printf("[clickhouse] [check_manage_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+				done++
+				return nil
 			}
 
 			dur := time.Since(start)
 			// Log every 100th iteration to avoid excessive output
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[clickhouse] [check_manage_direct_user] iter=%d resource=%d user=%d dur=%s", done, resourceID, userID, dur)
 			}
 			done++
@@ -232,12 +343,13 @@ func runCheckManageDirectUser(db *sql.DB) {
 		})
 		cancel()
 
-		if err != nil && err != context.DeadlineExceeded {
-			log.Fatalf("[clickhouse] [check_manage_direct_user] streamQuery failed: %v", err)
+		if err != nil {
+			errs.Survive(err, iters, "[clickhouse] [check_manage_direct_user] streamQuery failed: %v", err)
 		}
 		break
 	}
 	log.Printf("[clickhouse] [check_manage_direct_user] DONE: iters=%d", iters)
+	log.Printf("[clickhouse] [check_manage_direct_user] ERRORS: %s", errs.Summary(done))
 }
 
 // runCheckManageOrgAdmin benchmarks queries for "manager" permission
@@ -248,9 +360,11 @@ func runCheckManageOrgAdmin(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_CHECK_ORGADMIN_ITER", 1000)
 
 	log.Printf("[clickhouse] [check_manage_org_admin] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("clickhouse", "check_manage_org_admin", iters, 2*time.Second)
 	done := 0
+	errs := utils.NewErrorTally()
 	// Hybrid behavior: if BENCH_LOOKUPRES_MANAGE_USER is set, prefer LookupResources for that user
-	lookupUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 
 	for done < iters {
@@ -273,24 +387,25 @@ func runCheckManageOrgAdmin(db *sql.DB) {
 					return err
 				}
 
+				if !utils.InOrgScope(ids.Resource, resourceID) {
+					return nil
+				}
+
 				// Verify permission via user_resource_permissions materialized view
 				cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 				start := time.Now()
-				checkQuery := `
-				SELECT 1
-				FROM user_resource_permissions
-				WHERE resource_id = ? AND user_id = ? AND relation = 'manager'
-				LIMIT 1
-				`
-				var exists int
-				err := db.QueryRowContext(cctx, checkQuery, resourceID, lookupUser).Scan(&exists)
+				granted, err := checkPermissionCH(cctx, db, resourceID, lookupUser, "manager")
 				ccancel()
-				if err != nil && err != sql.ErrNoRows {
-					return err
+				utils.AuditCheck("check_manage_org_admin", resourceID, lookupUser, "manager", granted, time.Since(start), err)
+				if err != nil {
+					class := errs.Record(err)
+					log.Printf("[clickhouse] [check_manage_org_admin] iter=%d check failed class=%s: %v", done, class, err)
+					done++
+					return nil
 				}
 
 				dur := time.Since(start)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[clickhouse] [check_manage_org_admin] lookup iter=%d resource=%d user=%s dur=%s", done, resourceID, lookupUser, dur)
 				}
 				done++
@@ -298,8 +413,8 @@ func runCheckManageOrgAdmin(db *sql.DB) {
 			})
 			cancel()
 
-			if err != nil && err != context.DeadlineExceeded {
-				log.Fatalf("[clickhouse] [check_manage_org_admin] streamQuery failed: %v", err)
+			if err != nil {
+				errs.Survive(err, iters, "[clickhouse] [check_manage_org_admin] streamQuery failed: %v", err)
 			}
 
 			if done < sampleLimit {
@@ -345,24 +460,25 @@ func runCheckManageOrgAdmin(db *sql.DB) {
 				return nil
 			}
 
+			if !utils.InOrgScope(ids.Resource, resourceID) {
+				return nil
+			}
+
 			cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 			start := time.Now()
-			checkQuery := `
-			SELECT 1
-			FROM user_resource_permissions
-			WHERE resource_id = ? AND user_id = ? AND relation = 'manager'
-			LIMIT 1
-			`
-			var exists int
-			err := db.QueryRowContext(cctx, checkQuery, resourceID, adminUser).Scan(&exists)
+			granted, err := checkPermissionCH(cctx, db, resourceID, adminUser, "manager")
 			ccancel()
-			if err != nil && err != sql.ErrNoRows {
-				return err
+			utils.AuditCheck("check_manage_org_admin", resourceID, adminUser, "manager", granted, time.Since(start), err)
+			if err != nil {
+				class := errs.Record(err)
+				log.Printf("[clickhouse] [check_manage_org_admin] iter=%d check failed class=%s: %v", done, class, err)
+				done++
+				return nil
 			}
 
 			dur := time.Since(start)
 			// Log every 100th iteration to avoid excessive output
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[clickhouse] [check_manage_org_admin] iter=%d resource=%d org=%d admin=%d dur=%s", done, resourceID, orgID, adminUser, dur)
 			}
 			done++
@@ -370,12 +486,13 @@ func runCheckManageOrgAdmin(db *sql.DB) {
 		})
 		cancel()
 
-		if err != nil && err != context.DeadlineExceeded {
-			log.Fatalf("[clickhouse] [check_manage_org_admin] streamQuery failed: %v", err)
+		if err != nil {
+			errs.Survive(err, iters, "[clickhouse] [check_manage_org_admin] streamQuery failed: %v", err)
 		}
 		break
 	}
 	log.Printf("[clickhouse] [check_manage_org_admin] DONE: iters=%d", iters)
+	log.Printf("[clickhouse] [check_manage_org_admin] ERRORS: %s", errs.Summary(done))
 }
 
 // runCheckViewViaGroupMember benchmarks queries for "viewer" permission
@@ -386,9 +503,11 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_CHECK_VIEW_GROUP_ITER", 1000)
 
 	log.Printf("[clickhouse] [check_view_via_group_member] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("clickhouse", "check_view_via_group_member", iters, 2*time.Second)
 	done := 0
+	errs := utils.NewErrorTally()
 	// Hybrid behavior: if BENCH_LOOKUPRES_VIEW_USER is set, prefer LookupResources for that user
-	lookupUser := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 
 	for done < iters {
@@ -410,24 +529,25 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 					return err
 				}
 
+				if !utils.InOrgScope(ids.Resource, resourceID) {
+					return nil
+				}
+
 				// Verify permission via materialized view
 				cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 				start := time.Now()
-				checkQuery := `
-				SELECT 1
-				FROM user_resource_permissions
-				WHERE resource_id = ? AND user_id = ? AND relation = 'viewer'
-				LIMIT 1
-				`
-				var exists int
-				err := db.QueryRowContext(cctx, checkQuery, resourceID, lookupUser).Scan(&exists)
+				granted, err := checkPermissionCH(cctx, db, resourceID, lookupUser, "viewer")
 				ccancel()
-				if err != nil && err != sql.ErrNoRows {
-					return err
+				utils.AuditCheck("check_view_via_group_member", resourceID, lookupUser, "viewer", granted, time.Since(start), err)
+				if err != nil {
+					class := errs.Record(err)
+					log.Printf("[clickhouse] [check_view_via_group_member] iter=%d check failed class=%s: %v", done, class, err)
+					done++
+					return nil
 				}
 
 				dur := time.Since(start)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[clickhouse] [check_view_via_group_member] lookup iter=%d resource=%d user=%s dur=%s", done, resourceID, lookupUser, dur)
 				}
 				done++
@@ -435,8 +555,8 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 			})
 			cancel()
 
-			if err != nil && err != context.DeadlineExceeded {
-				log.Fatalf("[clickhouse] [check_view_via_group_member] streamQuery failed: %v", err)
+			if err != nil {
+				errs.Survive(err, iters, "[clickhouse] [check_view_via_group_member] streamQuery failed: %v", err)
 			}
 
 			if done < sampleLimit {
@@ -483,24 +603,25 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 				return nil
 			}
 
+			if !utils.InOrgScope(ids.Resource, resourceID) {
+				return nil
+			}
+
 			cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 			start := time.Now()
-			checkQuery := `
-			SELECT 1
-			FROM user_resource_permissions
-			WHERE resource_id = ? AND user_id = ? AND relation = 'viewer'
-			LIMIT 1
-			`
-			var exists int
-			err := db.QueryRowContext(cctx, checkQuery, resourceID, pickedUser).Scan(&exists)
+			granted, err := checkPermissionCH(cctx, db, resourceID, pickedUser, "viewer")
 			ccancel()
-			if err != nil && err != sql.ErrNoRows {
-				return err
+			utils.AuditCheck("check_view_via_group_member", resourceID, pickedUser, "viewer", granted, time.Since(start), err)
+			if err != nil {
+				class := errs.Record(err)
+				log.Printf("[clickhouse] [check_view_via_group_member] iter=%d check failed class=%s: %v", done, class, err)
+				done++
+				return nil
 			}
 
 			dur := time.Since(start)
 			// Log every 100th iteration to avoid excessive output
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[clickhouse] [check_view_via_group_member] iter=%d resource=%d group=%d user=%d dur=%s", done, resourceID, groupID, pickedUser, dur)
 			}
 			done++
@@ -508,12 +629,95 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 		})
 		cancel()
 
-		if err != nil && err != context.DeadlineExceeded {
-			log.Fatalf("[clickhouse] [check_view_via_group_member] streamQuery failed: %v", err)
+		if err != nil {
+			errs.Survive(err, iters, "[clickhouse] [check_view_via_group_member] streamQuery failed: %v", err)
 		}
 		break
 	}
 	log.Printf("[clickhouse] [check_view_via_group_member] DONE: iters=%d", iters)
+	log.Printf("[clickhouse] [check_view_via_group_member] ERRORS: %s", errs.Summary(done))
+}
+
+// runCheckTimeBoundedDirectUser streams direct user grants carrying a validity
+// window and checks each against resource_acl at now(), so the expired (soft
+// deleted) share of the sample is denied.
+// Iterations are controlled by BENCH_CHECK_TIME_BOUNDED_ITER env variable (default: 1000).
+func runCheckTimeBoundedDirectUser(db *sql.DB) {
+	iters := utils.GetEnvInt("BENCH_CHECK_TIME_BOUNDED_ITER", 1000)
+
+	log.Printf("[clickhouse] [check_time_bounded_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("clickhouse", "check_time_bounded_direct_user", iters, 2*time.Second)
+	done, allowed := 0, 0
+	errs := utils.NewErrorTally()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	query := `
+	SELECT resource_id, subject_id, relation
+	FROM resource_acl
+	WHERE subject_type = 'user' AND valid_until IS NOT NULL
+	LIMIT ?
+	`
+	err := streamQuery(ctx, db, query, []any{iters}, func(rows *sql.Rows) error {
+		var resourceID, userID uint32
+		var relation string
+		if err := rows.Scan(&resourceID, &userID, &relation); err != nil {
+			return err
+		}
+
+		if !utils.InOrgScope(ids.Resource, resourceID) {
+			return nil
+		}
+
+		cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
+		start := time.Now()
+		ok, err := checkTimeBoundedCH(cctx, db, resourceID, userID, relation)
+		ccancel()
+		utils.AuditCheck("check_time_bounded_direct_user", resourceID, userID, relation, ok, time.Since(start), err)
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[clickhouse] [check_time_bounded_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+			done++
+			return nil
+		}
+
+		dur := time.Since(start)
+		if ok {
+			allowed++
+		}
+		if utils.LogIteration(done, 100) {
+			log.Printf("[clickhouse] [check_time_bounded_direct_user] iter=%d resource=%d user=%d allowed=%t dur=%s", done, resourceID, userID, ok, dur)
+		}
+		done++
+		return nil
+	})
+	cancel()
+
+	if err != nil {
+		errs.Survive(err, iters, "[clickhouse] [check_time_bounded_direct_user] streamQuery failed: %v", err)
+	}
+	if done == 0 {
+		log.Printf("[clickhouse] [check_time_bounded_direct_user] skipped: no time-bounded grants (generate with RLP_EXPIRING_GRANT_FRACTION)")
+	}
+	log.Printf("[clickhouse] [check_time_bounded_direct_user] DONE: iters=%d allowed=%d denied=%d", done, allowed, done-allowed-errs.Total())
+	log.Printf("[clickhouse] [check_time_bounded_direct_user] ERRORS: %s", errs.Summary(done))
+}
+
+// timeBoundedCheckSQL is the query of checkTimeBoundedCH, with the resource,
+// the user and the relation as arguments.
+const timeBoundedCheckSQL = `
+	SELECT count()
+	FROM resource_acl
+	WHERE resource_id = ? AND subject_type = 'user' AND subject_id = ? AND relation = ?
+	  AND (valid_from IS NULL OR valid_from <= now())
+	  AND (valid_until IS NULL OR valid_until > now())
+	`
+
+// checkTimeBoundedCH checks a direct grant in resource_acl, honouring its
+// valid_from/valid_until window.
+func checkTimeBoundedCH(ctx context.Context, db *sql.DB, resourceID, userID uint32, relation string) (bool, error) {
+	var n uint64
+	err := db.QueryRowContext(ctx, timeBoundedCheckSQL, resourceID, userID, relation).Scan(&n)
+	return n > 0, err
 }
 
 // runLookupResourcesManageHeavyUser benchmarks LookupResources for "manager" relation
@@ -523,7 +727,7 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 // Iterations are controlled by BENCH_LOOKUPRES_MANAGE_ITER env variable (default: 10).
 func runLookupResourcesManageHeavyUser(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_LOOKUPRES_MANAGE_ITER", 10)
-	userID := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	userID := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	runLookupBench(db, "lookup_resources_manage_super", "manager", userID, iters)
 }
 
@@ -534,6 +738,83 @@ func runLookupResourcesManageHeavyUser(db *sql.DB) {
 // Iterations are controlled by BENCH_LOOKUPRES_VIEW_ITER env variable (default: 10).
 func runLookupResourcesViewRegularUser(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_LOOKUPRES_VIEW_ITER", 10)
-	userID := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	userID := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	runLookupBench(db, "lookup_resources_view_regular", "viewer", userID, iters)
 }
+
+// runLookupResourcesMix benchmarks lookups for a mix of users stratified by
+// grant count, reporting latency against result-set size. The number of users
+// is set by BENCH_LOOKUPRES_MIX_USERS (default 0 = skip); see utils.RunLookupMix.
+func runLookupResourcesMix(db *sql.DB) {
+	utils.RunLookupMix("clickhouse", newChecker(db))
+}
+
+// runLookupResourcesPercentiles benchmarks lookups for the users at the p10,
+// p50, p90 and p99 of the grant counts (BENCH_LOOKUPRES_*_PCT_USERS); see
+// utils.RunPercentileLookups.
+func runLookupResourcesPercentiles(db *sql.DB) {
+	utils.RunPercentileLookups("clickhouse", newChecker(db))
+}
+
+// runViralFanIn benchmarks checks and lookups on the resources shared with many
+// users directly (RLP_VIRAL_RESOURCES); see utils.RunViralFanIn.
+func runViralFanIn(db *sql.DB) {
+	utils.RunViralFanIn("clickhouse", newChecker(db))
+}
+
+// runWorstCaseChecks benchmarks view checks on the pairs granted only through
+// nested groups (check_view_worst_case); see utils.RunWorstCaseChecks.
+func runWorstCaseChecks(db *sql.DB) {
+	utils.RunWorstCaseChecks("clickhouse", newChecker(db))
+}
+
+// runWorkload benchmarks the blended mix of checks and lookups named by
+// BENCH_WORKLOAD (default "" = skip); see utils.RunWorkload.
+func runWorkload(db *sql.DB) {
+	utils.RunWorkload("clickhouse", newChecker(db))
+}
+
+// runAccessSummary benchmarks counting the resources sampled users can view
+// and manage (BENCH_ACCESS_SUMMARY_ITER, default 100; 0 skips); see
+// utils.RunAccessSummary.
+func runAccessSummary(db *sql.DB) {
+	utils.RunAccessSummary("clickhouse", newChecker(db))
+}
+
+// runMiddlewareOverhead benchmarks a resource fetch with and without a view
+// check in front of it (BENCH_MIDDLEWARE_ITER, default 1000; 0 skips); see
+// utils.RunMiddlewareOverhead.
+func runMiddlewareOverhead(db *sql.DB) {
+	utils.RunMiddlewareOverhead("clickhouse", newChecker(db), func(ctx context.Context, resourceID string) error {
+		id, err := ids.Parse(ids.Resource, resourceID)
+		if err != nil {
+			return err
+		}
+		_, err = utils.CountSQLRows(ctx, db, `SELECT * FROM resources WHERE resource_id = ?`, id)
+		return err
+	})
+}
+
+// runFullExport benchmarks streaming every resource_acl row (export_full_acl,
+// BENCH_EXPORT_ITER); see utils.RunFullExport.
+func runFullExport(db *sql.DB) {
+	utils.RunFullExport("clickhouse", func(ctx context.Context) (int, error) {
+		n := 0
+		err := streamQuery(ctx, db, `SELECT resource_id, subject_type, subject_id, relation, valid_from, valid_until FROM resource_acl`, nil, func(rows *sql.Rows) error {
+			var resID, subjectID uint32
+			var subjectType, relation string
+			var from, until *time.Time
+			n++
+			return rows.Scan(&resID, &subjectType, &subjectID, &relation, &from, &until)
+		})
+		return n, err
+	})
+}
+
+// runListRecentViewable benchmarks the authorized listing of the newest
+// resources a user can view; see utils.RunRecentListing.
+func runListRecentViewable(db *sql.DB) {
+	utils.RunRecentListing("clickhouse", func(ctx context.Context, userID string, limit int, after *utils.ListedResource) ([]utils.ListedResource, error) {
+		return listRecentViewableCH(ctx, db, userID, limit, after)
+	})
+}
@@ -0,0 +1,46 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"test-tls/infrastructure"
+)
+
+// ensureBenchUser provisions the read-only user the benchmark connects as
+// (see infrastructure.UseBenchCredentials). No-op unless CH_BENCH_USER is set.
+// readonly=2 still lets queries override settings such as max_threads. The
+// connecting user needs access_management enabled.
+func ensureBenchUser(ctx context.Context, db *sql.DB) {
+	user, password, ok := infrastructure.BenchCredentialsFromEnv("CH")
+	if !ok {
+		return
+	}
+	ident := "`" + strings.ReplaceAll(user, "`", "``") + "`"
+	secret := "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(password) + "'"
+
+	var dbName string
+	if err := db.QueryRowContext(ctx, "SELECT currentDatabase()").Scan(&dbName); err != nil {
+		log.Fatalf("[clickhouse] bench user: currentDatabase failed: %v", err)
+	}
+
+	stmts := []string{
+		fmt.Sprintf("CREATE USER IF NOT EXISTS %s IDENTIFIED WITH sha256_password BY %s SETTINGS readonly = 2", ident, secret),
+		fmt.Sprintf("ALTER USER %s IDENTIFIED WITH sha256_password BY %s SETTINGS readonly = 2", ident, secret),
+		fmt.Sprintf("GRANT SELECT ON `%s`.* TO %s", dbName, ident),
+	}
+	for _, stmt := range stmts {
+		cctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		_, err := db.ExecContext(cctx, stmt)
+		cancel()
+		if err != nil {
+			log.Fatalf("[clickhouse] bench user: %v", err)
+		}
+	}
+
+	log.Printf("[clickhouse] Read-only bench user %q ready", user)
+}
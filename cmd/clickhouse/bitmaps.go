@@ -0,0 +1,261 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	chauthz "test-tls/authz/clickhouse"
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// bitmapsTable holds one groupBitmap per (user, relation) with every
+// resource of user_resource_permissions the user holds the relation on.
+const bitmapsTable = "user_permission_bitmaps"
+
+// Queries of `clickhouse bitmaps` on bitmapsTable: check is bitmapContains
+// with the resource, the user and the relation as arguments; lookup expands
+// the bitmap of a user and relation to resource ids. groupBitmapMergeState
+// folds the rows of a key that are not merged yet, and yields an empty
+// bitmap when there is none.
+const (
+	bitmapCheckSQL = `
+	SELECT bitmapContains(groupBitmapMergeState(resources), toUInt32(?))
+	FROM user_permission_bitmaps
+	WHERE user_id = ? AND relation = ?
+	`
+	bitmapLookupSQL = `
+	SELECT arrayJoin(bitmapToArray(b))
+	FROM (
+		SELECT groupBitmapMergeState(resources) AS b
+		FROM user_permission_bitmaps
+		WHERE user_id = ? AND relation = ?
+	)
+	`
+)
+
+// ClickhouseBitmaps benchmarks precomputed permission sets stored as roaring
+// bitmaps (groupBitmap) against the normalized user_resource_permissions
+// table: user_resource_permissions is aggregated into user_permission_bitmaps,
+// an AggregatingMergeTree with one bitmap per user and relation, and
+// check_manage, check_view, lookup_manage and lookup_view are timed on both,
+// the normalized side with the chauthz queries of the benchmark. Per variant
+// and query it logs a "[bitmaps] ... DONE:" line with the latency percentiles
+// and the rows returned; rows that differ from normalized are logged as a
+// mismatch. The size of both tables is logged after the build. The bitmap
+// table is dropped afterwards unless CH_BITMAP_KEEP is true (`clickhouse
+// drop` removes a kept one).
+//
+// Check pairs are manager and viewer rows of user_resource_permissions, in a
+// fixed pseudo-random order; the lookup users are BENCH_LOOKUPRES_MANAGE_USER
+// and BENCH_LOOKUPRES_VIEW_USER, or the user of the first pair.
+//
+// Env vars:
+//
+//	CH_BITMAP_CHECK_ITER    (default: 1000)
+//	CH_BITMAP_LOOKUP_ITER   (default: 10)
+//	CH_BITMAP_KEEP          (default: false)
+func ClickhouseBitmaps() {
+	ctx := context.Background()
+	db, cleanup, err := infrastructure.NewClickhouseFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[clickhouse] bitmaps: connect failed: %v", err)
+	}
+	defer cleanup()
+
+	checkIters := utils.GetEnvInt("CH_BITMAP_CHECK_ITER", 1000)
+	lookupIters := utils.GetEnvInt("CH_BITMAP_LOOKUP_ITER", 10)
+	keep := utils.GetEnvWithDefault("CH_BITMAP_KEEP", "false") == "true"
+
+	pairs := map[string][][2]uint32{
+		"manager": bitmapPairs(ctx, db, "manager", checkIters),
+		"viewer":  bitmapPairs(ctx, db, "viewer", checkIters),
+	}
+	users := map[string]string{
+		"manager": ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER"),
+		"viewer":  ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER"),
+	}
+	for relation, user := range users {
+		if user == "" {
+			users[relation] = fmt.Sprint(pairs[relation][0][1])
+		}
+	}
+
+	buildBitmaps(ctx, db)
+	log.Printf("[clickhouse] [bitmaps] check_iters=%d lookup_iters=%d manage_user=%s view_user=%s user_resource_permissions=%s %s=%s",
+		checkIters, lookupIters, users["manager"], users["viewer"],
+		tableSize(ctx, db, "user_resource_permissions"), bitmapsTable, tableSize(ctx, db, bitmapsTable))
+
+	queries := []struct {
+		name, relation string
+		check          bool
+	}{
+		{"check_manage", "manager", true},
+		{"check_view", "viewer", true},
+		{"lookup_manage", "manager", false},
+		{"lookup_view", "viewer", false},
+	}
+	rows := map[string]int{} // query -> rows of normalized
+	for _, variant := range []string{"normalized", "bitmap"} {
+		for _, q := range queries {
+			var n int
+			if q.check {
+				query := chauthz.CheckSQL
+				if variant == "bitmap" {
+					query = bitmapCheckSQL
+				}
+				n = runBitmapChecks(db, variant, q.name, query, pairs[q.relation], q.relation, checkIters)
+			} else {
+				n = runBitmapLookups(ctx, db, variant, q.name, users[q.relation], q.relation, lookupIters)
+			}
+			if variant == "normalized" {
+				rows[q.name] = n
+			} else if want := rows[q.name]; n != want {
+				log.Printf("[clickhouse] [bitmaps] variant=%s query=%s MISMATCH: rows=%d, normalized returned %d", variant, q.name, n, want)
+			}
+		}
+	}
+	if !keep {
+		dropBitmaps(ctx, db)
+	}
+	log.Println("[clickhouse] == ClickHouse bitmap encoding comparison DONE ==")
+}
+
+// bitmapPairs returns up to n (resource, user) pairs of user_resource_permissions
+// holding relation, ordered by a hash so every run samples the same pairs.
+func bitmapPairs(ctx context.Context, db *sql.DB, relation string, n int) [][2]uint32 {
+	var pairs [][2]uint32
+	query := `
+	SELECT resource_id, user_id
+	FROM user_resource_permissions
+	WHERE relation = ?
+	ORDER BY cityHash64(resource_id, user_id)
+	LIMIT ?
+	`
+	qctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	err := streamQuery(qctx, db, query, []any{relation, n}, func(rows *sql.Rows) error {
+		var p [2]uint32
+		if err := rows.Scan(&p[0], &p[1]); err != nil {
+			return err
+		}
+		pairs = append(pairs, p)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("[clickhouse] bitmaps: sample %s pairs: %v", relation, err)
+	}
+	if len(pairs) == 0 {
+		log.Fatalf("[clickhouse] bitmaps: no %s rows in user_resource_permissions; run load-data first", relation)
+	}
+	return pairs
+}
+
+// buildBitmaps (re)creates user_permission_bitmaps from
+// user_resource_permissions and merges it to one row per key.
+func buildBitmaps(ctx context.Context, db *sql.DB) {
+	start := time.Now()
+	dropBitmaps(ctx, db)
+	stmts := []string{
+		`CREATE TABLE user_permission_bitmaps (
+		user_id UInt32,
+		relation Enum8('viewer' = 1, 'manager' = 2),
+		resources AggregateFunction(groupBitmap, UInt32)
+	) ENGINE = AggregatingMergeTree ORDER BY (user_id, relation)`,
+		`INSERT INTO user_permission_bitmaps
+		SELECT user_id, relation, groupBitmapState(resource_id)
+		FROM user_resource_permissions
+		GROUP BY user_id, relation`,
+		`OPTIMIZE TABLE user_permission_bitmaps FINAL`,
+	}
+	if err := recordCreated(ctx, db, func() error {
+		for _, stmt := range stmts {
+			if err := execTimeout(ctx, db, stmt, 30*time.Minute); err != nil {
+				return fmt.Errorf("executing %q: %w", stmt, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		log.Fatalf("[clickhouse] bitmaps: %v", err)
+	}
+	log.Printf("[clickhouse] [bitmaps] %s built in %s", bitmapsTable, time.Since(start).Truncate(time.Millisecond))
+}
+
+func dropBitmaps(ctx context.Context, db *sql.DB) {
+	if err := execTimeout(ctx, db, "DROP TABLE IF EXISTS "+bitmapsTable, 60*time.Second); err != nil {
+		log.Printf("[clickhouse] bitmaps: warning: drop %s failed: %v", bitmapsTable, err)
+	}
+}
+
+// runBitmapChecks times query over pairs round robin and returns how many
+// checks were granted. CheckSQL answers with a row or none, bitmapCheckSQL
+// with 0 or 1.
+func runBitmapChecks(db *sql.DB, variant, name, query string, pairs [][2]uint32, relation string, iters int) int {
+	durations := make([]time.Duration, 0, iters)
+	granted := 0
+	errs := utils.NewErrorTally()
+	for i := range iters {
+		p := pairs[i%len(pairs)]
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		start := time.Now()
+		var contains uint8
+		err := db.QueryRowContext(ctx, query, p[0], p[1], relation).Scan(&contains)
+		dur := time.Since(start)
+		cancel()
+		if err == sql.ErrNoRows {
+			err = nil
+		} else if err == nil && contains == 1 {
+			granted++
+		}
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[clickhouse] [bitmaps] variant=%s query=%s iter=%d failed class=%s: %v", variant, name, i, class, err)
+			continue
+		}
+		durations = append(durations, dur)
+	}
+	logBitmapsDone(variant, name, durations, granted, errs, iters)
+	return granted
+}
+
+// runBitmapLookups times the lookup of variant for userID and returns the
+// rows of the last successful iteration.
+func runBitmapLookups(ctx context.Context, db *sql.DB, variant, name, userID, relation string, iters int) int {
+	durations := make([]time.Duration, 0, iters)
+	count := 0
+	errs := utils.NewErrorTally()
+	for i := range iters {
+		ictx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		start := time.Now()
+		n := 0
+		var err error
+		if variant == "bitmap" {
+			err = streamQuery(ictx, db, bitmapLookupSQL, []any{userID, relation}, func(*sql.Rows) error {
+				n++
+				return nil
+			})
+		} else {
+			err = chauthz.LookupResources(ictx, db, userID, relation, func(uint32) { n++ })
+		}
+		dur := time.Since(start)
+		cancel()
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[clickhouse] [bitmaps] variant=%s query=%s iter=%d failed class=%s: %v", variant, name, i, class, err)
+			continue
+		}
+		count = n
+		durations = append(durations, dur)
+	}
+	logBitmapsDone(variant, name, durations, count, errs, iters)
+	return count
+}
+
+func logBitmapsDone(variant, name string, durations []time.Duration, rows int, errs *utils.ErrorTally, iters int) {
+	log.Printf("[clickhouse] [bitmaps] variant=%s query=%s DONE: iters=%d rows=%d %s", variant, name, len(durations), rows, utils.LatencySummary(durations))
+	log.Printf("[clickhouse] [bitmaps] variant=%s query=%s ERRORS: %s", variant, name, errs.Summary(iters))
+}
@@ -0,0 +1,43 @@
+package clickhouse
+
+import "test-tls/utils"
+
+// Capabilities lists what the clickhouse module supports, for the capabilities
+// command. Keep it in step with the benchmark scenarios in bechmark_reads.go.
+func Capabilities() utils.Capabilities {
+	return utils.Capabilities{
+		Scenarios: []string{
+			"check_manage_direct_user",
+			"check_manage_org_admin",
+			"check_view_via_group_member",
+			"check_time_bounded_direct_user",
+			"lookup_resources_manage_super",
+			"lookup_resources_view_regular",
+			"lookup_resources_*_mix (BENCH_LOOKUPRES_MIX_USERS)",
+			"lookup_resources_*_pct (BENCH_LOOKUPRES_*_PCT_USERS)",
+			"check_view_viral_direct_user (BENCH_VIRAL_RESOURCES)",
+			"lookup_resources_view_viral (BENCH_LOOKUPRES_VIRAL_USER)",
+			"check_view_worst_case (nested-group-only grants, BENCH_CHECK_WORST_ITER)",
+			"workload_* (blended request mix, BENCH_WORKLOAD)",
+			"count_access_summary (view and manage counts of a user, BENCH_ACCESS_SUMMARY_ITER)",
+			"middleware_overhead (resource fetch with and without a view check, BENCH_MIDDLEWARE_ITER)",
+			"export_full_acl (full ACL stream, BENCH_EXPORT_ITER)",
+			"list_recent_viewable (authorized listing by created_at)",
+			"custom_* (BENCH_CUSTOM_SCENARIOS)",
+			"per-scenario cost from system.query_log (BENCH_COST)",
+			"driver_overhead (BENCH_DRIVER_COMPARE)",
+			"background maintenance: OPTIMIZE ... FINAL of the ACL tables (BENCH_MAINTENANCE)",
+		},
+		Schemas: []string{
+			"migrations/0001_init.sql layout (default)",
+			"sort-keys copies: current|resource_first|user_first|resource_first_proj (CH_SORTKEY_VARIANTS)",
+			"bitmaps: groupBitmap permission sets per user (clickhouse bitmaps)",
+		},
+		Consistency: []string{
+			"server default (no knob)",
+		},
+		Writes: []string{
+			"user offboarding: lightweight DELETEs, derived tables included (offboard, BENCH_OFFBOARD_USERS)",
+		},
+	}
+}
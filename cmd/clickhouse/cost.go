@@ -0,0 +1,41 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"test-tls/utils"
+)
+
+// costMeter reads the cost of the benchmark's SELECTs from system.query_log:
+// rows and bytes read, result rows and peak memory, summed over the queries
+// finished since since. The log is flushed first, which needs the SYSTEM
+// FLUSH LOGS grant. Queries of other databases and of the meter itself are
+// left out.
+func costMeter(db *sql.DB, since time.Time) utils.CostMeter {
+	return func(ctx context.Context) ([]utils.CostCounter, error) {
+		if _, err := db.ExecContext(ctx, "SYSTEM FLUSH LOGS"); err != nil {
+			return nil, err
+		}
+		var queries, readRows, readBytes, resultRows, memory float64
+		err := db.QueryRowContext(ctx, `
+			SELECT toFloat64(count()), toFloat64(sum(read_rows)), toFloat64(sum(read_bytes)),
+			       toFloat64(sum(result_rows)), toFloat64(sum(memory_usage))
+			FROM system.query_log
+			WHERE type = 'QueryFinish' AND query_kind = 'Select'
+			  AND event_time >= ?
+			  AND has(databases, currentDatabase())
+			  AND query NOT LIKE '%system.query_log%'`, since).Scan(&queries, &readRows, &readBytes, &resultRows, &memory)
+		if err != nil {
+			return nil, err
+		}
+		return []utils.CostCounter{
+			{Name: "queries", Value: queries},
+			{Name: "read_rows", Value: readRows},
+			{Name: "read_bytes", Value: readBytes},
+			{Name: "result_rows", Value: resultRows},
+			{Name: "memory_bytes", Value: memory},
+		}, nil
+	}
+}
@@ -3,17 +3,20 @@ package clickhouse
 import (
 	"context"
 	"database/sql"
+	"embed"
+	"fmt"
 	"log"
-	"os"
-	"strings"
 	"time"
 
 	"test-tls/infrastructure"
+	"test-tls/migrate"
 )
 
 // ClickhouseCreateSchemas creates all tables and indexes in ClickHouse
 // optimized for RLS-style "check" and "list" queries that walk the
-// org -> group -> user -> resource chains.
+// org -> group -> user -> resource chains. It applies the migrations in
+// migrations/ that the database has not recorded yet, so rerunning it is a
+// no-op; CH_MIGRATIONS_DIR reads them from a directory instead of the binary.
 func ClickhouseCreateSchemas() {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
@@ -24,33 +27,57 @@ func ClickhouseCreateSchemas() {
 	}
 	defer cleanup()
 
-	// Read SQL statements from external file (allows easier editing and
-	// keeps raw DDL separate from Go code).
-	schemaFile := "cmd/clickhouse/schemas.sql"
-	content, err := os.ReadFile(schemaFile)
+	migrations := migrate.Load("clickhouse", migrationFiles, "CH_MIGRATIONS_DIR")
+	migrate.Run(ctx, "clickhouse", migrationStore{db}, migrations)
+
+	log.Println("[clickhouse] Schemas created successfully.")
+
+	ensureBenchUser(ctx, db)
+}
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationStore records applied migrations in rlp_schema_migrations. ClickHouse
+// has no DDL transactions: statements run one by one with a 30s timeout each
+// and the migration is recorded after the last one succeeds.
+type migrationStore struct{ db *sql.DB }
+
+func (s migrationStore) Applied(ctx context.Context) (map[int]bool, error) {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS rlp_schema_migrations (
+		version    UInt32,
+		name       String,
+		applied_at DateTime DEFAULT now()
+	) ENGINE = MergeTree ORDER BY version`); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT version FROM rlp_schema_migrations`)
 	if err != nil {
-		log.Fatalf("[clickhouse] read %s failed: %v", schemaFile, err)
+		return nil, err
 	}
-
-	// Naive split on semicolons; skip empty statements and comments.
-	raw := string(content)
-	parts := strings.Split(raw, ";")
-	for _, p := range parts {
-		stmt := strings.TrimSpace(p)
-		if stmt == "" {
-			continue
+	defer rows.Close()
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var v uint32
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
 		}
-		execWithTimeout(ctx, db, stmt, 30*time.Second)
+		applied[int(v)] = true
 	}
-
-	log.Println("[clickhouse] Schemas created successfully.")
+	return applied, rows.Err()
 }
 
-func execWithTimeout(parent context.Context, db *sql.DB, stmt string, timeout time.Duration) {
-	ctx, cancel := context.WithTimeout(parent, timeout)
-	defer cancel()
-
-	if _, err := db.ExecContext(ctx, stmt); err != nil {
-		log.Fatalf("[clickhouse] executing %q failed: %v", stmt, err)
+func (s migrationStore) Apply(ctx context.Context, m migrate.Migration) error {
+	if err := recordCreated(ctx, s.db, func() error {
+		for _, stmt := range m.Statements() {
+			if err := execTimeout(ctx, s.db, stmt, 30*time.Second); err != nil {
+				return fmt.Errorf("executing %q: %w", stmt, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
 	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO rlp_schema_migrations (version, name) VALUES (?, ?)`, uint32(m.Version), m.Name)
+	return err
 }
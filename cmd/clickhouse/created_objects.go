@@ -0,0 +1,54 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"test-tls/utils"
+)
+
+// listTablesSQL lists the tables and views of the database.
+const listTablesSQL = `SELECT name FROM system.tables WHERE database = currentDatabase()`
+
+// recordCreated runs create and records the tables and views it created in
+// rlp_objects, leaving out those it found already there, so that drop
+// removes what the tool created and nothing else of the same name
+// (utils.OwnedRecorded). A create that fails part way still records the
+// tables it made, which its IF NOT EXISTS rerun would find in place.
+func recordCreated(ctx context.Context, db *sql.DB, create func() error) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS rlp_objects (
+		name String
+	) ENGINE = ReplacingMergeTree ORDER BY name`); err != nil {
+		return err
+	}
+	before, err := utils.ListNames(ctx, db, listTablesSQL)
+	if err != nil {
+		return err
+	}
+	createErr := create()
+	after, err := utils.ListNames(ctx, db, listTablesSQL)
+	if err != nil {
+		return err
+	}
+	for _, name := range utils.NewObjects(before, after) {
+		if _, err := db.ExecContext(ctx, `INSERT INTO rlp_objects (name) VALUES (?)`, name); err != nil {
+			return err
+		}
+	}
+	return createErr
+}
+
+// recordedObjects returns the names recorded in rlp_objects, none when found,
+// the tables of the database, holds no such table.
+func recordedObjects(ctx context.Context, db *sql.DB, found []string) (map[string]bool, error) {
+	recorded := map[string]bool{}
+	if !slices.Contains(found, "rlp_objects") {
+		return recorded, nil
+	}
+	names, err := utils.ListNames(ctx, db, `SELECT DISTINCT name FROM rlp_objects`)
+	for _, name := range names {
+		recorded[name] = true
+	}
+	return recorded, err
+}
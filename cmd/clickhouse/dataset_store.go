@@ -0,0 +1,31 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// datasetStore records the dataset load-data loaded in rlp_dataset, one row
+// per load; the latest row is the one loaded (utils.GuardDataset).
+type datasetStore struct{ db *sql.DB }
+
+func (s datasetStore) RecordDataset(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS rlp_dataset (
+		id        String,
+		loaded_at DateTime64(6) DEFAULT now64(6)
+	) ENGINE = MergeTree ORDER BY loaded_at`); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO rlp_dataset (id) VALUES (?)`, id)
+	return err
+}
+
+func (s datasetStore) LoadedDataset(ctx context.Context) (string, error) {
+	var id string
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM rlp_dataset ORDER BY loaded_at DESC LIMIT 1`).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return id, err
+}
@@ -0,0 +1,62 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	chauthz "test-tls/authz/clickhouse"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// runDriverOverhead compares clickhouse-go through database/sql against its
+// native API (the ch-go protocol without the database/sql layer) on a bare
+// round trip and on the check query, when BENCH_DRIVER_COMPARE=true. Both
+// drivers check the same granted (resource, user) pair, so the difference is
+// client-side cost only.
+func runDriverOverhead(db *sql.DB) {
+	if utils.GetEnvWithDefault("BENCH_DRIVER_COMPARE", "false") != "true" {
+		return
+	}
+	conn, cleanup, err := infrastructure.NewClickhouseNativeFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[clickhouse] failed to create native connection: %v", err)
+	}
+	defer cleanup()
+
+	var resourceID, userID uint32
+	err = db.QueryRowContext(context.Background(), `SELECT resource_id, user_id FROM user_resource_permissions WHERE relation = 'manager' LIMIT 1`).Scan(&resourceID, &userID)
+	if err != nil {
+		log.Fatalf("[clickhouse] [driver_overhead] pick check pair: %v", err)
+	}
+	log.Printf("[clickhouse] [driver_overhead] check resource=%d user=%d relation=manager", resourceID, userID)
+
+	utils.CompareDrivers("clickhouse", []string{"select1", "check"}, []utils.DriverBench{
+		{Driver: "database-sql", Queries: map[string]utils.DriverCall{
+			"select1": func(ctx context.Context) error {
+				var one uint8
+				return db.QueryRowContext(ctx, `SELECT 1`).Scan(&one)
+			},
+			"check": func(ctx context.Context) error {
+				_, err := checkPermissionCH(ctx, db, resourceID, userID, "manager")
+				return err
+			},
+		}},
+		{Driver: "native", Queries: map[string]utils.DriverCall{
+			"select1": func(ctx context.Context) error {
+				var one uint8
+				return conn.QueryRow(ctx, `SELECT 1`).Scan(&one)
+			},
+			"check": func(ctx context.Context) error {
+				var exists uint8
+				err := conn.QueryRow(ctx, chauthz.CheckSQL, resourceID, userID, "manager").Scan(&exists)
+				if errors.Is(err, sql.ErrNoRows) {
+					return nil
+				}
+				return err
+			},
+		}},
+	})
+}
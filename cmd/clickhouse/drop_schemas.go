@@ -7,10 +7,13 @@ import (
 	"time"
 
 	"test-tls/infrastructure"
+	"test-tls/utils"
 )
 
 // ClickhouseDropSchemas drops all tables and the materialized view created
-// by the ClickHouse schema for benchmarks. Logging mirrors other modules.
+// by the ClickHouse schema for benchmarks, as recorded in rlp_objects. Other
+// tables of the database, including ones that share a name with ours, are
+// listed and left in place (utils.OwnedRecorded). Logging mirrors other modules.
 func ClickhouseDropSchemas() {
 	ctx := context.Background()
 
@@ -23,20 +26,52 @@ func ClickhouseDropSchemas() {
 	start := time.Now()
 	log.Printf("[clickhouse] == Starting ClickHouse drop schemas ==")
 
-	// Drop materialized view first
-	stmts := []string{
-		`DROP VIEW IF EXISTS user_resource_permissions_mv`,
-		// Tables (children first where applicable)
-		`DROP TABLE IF EXISTS user_resource_permissions`,
-		`DROP TABLE IF EXISTS resource_acl`,
-		`DROP TABLE IF EXISTS resources`,
-		`DROP TABLE IF EXISTS group_members_expanded`,
-		`DROP TABLE IF EXISTS group_hierarchy`,
-		`DROP TABLE IF EXISTS group_memberships`,
-		`DROP TABLE IF EXISTS org_memberships`,
-		`DROP TABLE IF EXISTS groups`,
-		`DROP TABLE IF EXISTS users`,
-		`DROP TABLE IF EXISTS organizations`,
+	found, err := utils.ListNames(ctx, db, listTablesSQL)
+	if err != nil {
+		log.Fatalf("[clickhouse] drop: list tables: %v", err)
+	}
+	recorded, err := recordedObjects(ctx, db, found)
+	if err != nil {
+		log.Fatalf("[clickhouse] drop: list recorded tables: %v", err)
+	}
+	own := utils.OwnedRecorded("clickhouse", found, recorded, ownedObjects)
+
+	utils.GuardDrop("clickhouse", utils.CountSQLTables(ctx, db, utils.OwnedNames(own, []string{"resource_acl", "resources", "group_members_expanded", "group_hierarchy", "group_memberships", "org_memberships", "groups", "users", "organizations"})))
+
+	// Drop materialized view first, then tables (children first where applicable)
+	tables := []string{
+		"user_resource_permissions",
+		"resource_acl",
+		"resources",
+		"group_members_expanded",
+		"group_hierarchy",
+		"group_memberships",
+		"org_memberships",
+		"groups",
+		"users",
+		"organizations",
+		// Applied migrations, so create-schema rebuilds everything
+		"rlp_schema_migrations",
+		// The dataset load-data recorded (utils.GuardDataset)
+		"rlp_dataset",
+	}
+	// Copies kept by `clickhouse sort-keys` (CH_SORTKEY_KEEP=true)
+	for _, v := range sortKeyVariants {
+		tables = append(tables, v.aclTable(), v.urpTable())
+	}
+	// Bitmaps kept by `clickhouse bitmaps` (CH_BITMAP_KEEP=true)
+	tables = append(tables, bitmapsTable)
+	// The record of what the tool created, once that is gone.
+	tables = append(tables, "rlp_objects")
+
+	var stmts []string
+	if own["user_resource_permissions_mv"] {
+		stmts = append(stmts, `DROP VIEW IF EXISTS user_resource_permissions_mv`)
+	}
+	for _, t := range tables {
+		if own[t] {
+			stmts = append(stmts, `DROP TABLE IF EXISTS `+t)
+		}
 	}
 
 	for _, s := range stmts {
@@ -51,6 +86,16 @@ func ClickhouseDropSchemas() {
 	log.Printf("[clickhouse] ClickHouse drop schemas DONE: elapsed=%s", elapsed)
 }
 
+// ownedObjects are the tables and views create-schema, load-data and the
+// experiments create; drop removes nothing else, and of these only the ones
+// recorded as created (recordCreated).
+var ownedObjects = []string{
+	"organizations", "users", "groups", "org_memberships", "group_memberships", "group_hierarchy",
+	"group_members_expanded", "resources", "resource_acl", "user_resource_permissions",
+	"user_resource_permissions_mv", "rlp_schema_migrations", "rlp_dataset", "rlp_objects", bitmapsTable,
+	"resource_acl_sk_*", "user_resource_permissions_sk_*",
+}
+
 func execTimeout(parent context.Context, db *sql.DB, stmt string, timeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
@@ -0,0 +1,223 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// expandedMaintainer keeps group_members_expanded up to date with
+// group_hierarchy changes (utils.GroupMaintainer). It holds group_hierarchy
+// and the direct group memberships in memory, read once at start: hierarchy
+// changes leave the memberships as they are. After a burst it recomputes the
+// rows of every group above a changed edge, with the rules of the loader, and
+// replaces them with a lightweight DELETE and an INSERT.
+type expandedMaintainer struct {
+	db     *sql.DB
+	graph  *utils.GroupGraph
+	direct map[int]map[int]string // group -> user -> 'member'|'manager'
+}
+
+func newExpandedMaintainer(ctx context.Context, db *sql.DB) *expandedMaintainer {
+	m := &expandedMaintainer{db: db, graph: utils.NewGroupGraph(), direct: map[int]map[int]string{}}
+
+	rs, err := db.QueryContext(ctx, `SELECT parent_group_id, child_group_id, toString(relation) FROM group_hierarchy`)
+	if err != nil {
+		log.Fatalf("[clickhouse] group_churn: read group_hierarchy: %v", err)
+	}
+	for rs.Next() {
+		c, err := scanGroupEdge(rs)
+		if err != nil {
+			log.Fatalf("[clickhouse] group_churn: scan group_hierarchy: %v", err)
+		}
+		m.graph.Apply(c)
+	}
+	rs.Close()
+
+	rs, err = db.QueryContext(ctx, `SELECT group_id, user_id, toString(role) FROM group_memberships`)
+	if err != nil {
+		log.Fatalf("[clickhouse] group_churn: read group_memberships: %v", err)
+	}
+	defer rs.Close()
+	for rs.Next() {
+		var group, user uint32
+		var role string
+		if err := rs.Scan(&group, &user, &role); err != nil {
+			log.Fatalf("[clickhouse] group_churn: scan group_memberships: %v", err)
+		}
+		if m.direct[int(group)] == nil {
+			m.direct[int(group)] = map[int]string{}
+		}
+		m.direct[int(group)][int(user)] = role
+	}
+	if err := rs.Err(); err != nil {
+		log.Fatalf("[clickhouse] group_churn: read group_memberships: %v", err)
+	}
+	return m
+}
+
+func (m *expandedMaintainer) Edges(ctx context.Context, n int) ([]utils.GroupEdgeChange, error) {
+	rs, err := m.db.QueryContext(ctx, `SELECT parent_group_id, child_group_id, toString(relation) FROM group_hierarchy
+		ORDER BY parent_group_id, child_group_id LIMIT ?`, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var edges []utils.GroupEdgeChange
+	for rs.Next() {
+		e, err := scanGroupEdge(rs)
+		if err != nil {
+			return nil, err
+		}
+		edges = append(edges, e)
+	}
+	return edges, rs.Err()
+}
+
+// scanGroupEdge scans a (parent_group_id, child_group_id, relation) row as an add.
+func scanGroupEdge(rs *sql.Rows) (utils.GroupEdgeChange, error) {
+	var parent, child uint32
+	e := utils.GroupEdgeChange{Add: true}
+	err := rs.Scan(&parent, &child, &e.Relation)
+	e.Parent, e.Child = int(parent), int(child)
+	return e, err
+}
+
+// Apply writes the edges of burst to group_hierarchy and then replaces the
+// group_members_expanded rows of the groups above them once. It returns the
+// rows inserted; ClickHouse does not report deleted rows.
+func (m *expandedMaintainer) Apply(ctx context.Context, burst []utils.GroupEdgeChange) (int, error) {
+	var parents []int
+	for _, c := range burst {
+		if _, ok := m.graph.Children[c.Parent][c.Child]; ok == c.Add {
+			continue // nothing to change
+		}
+		var err error
+		if c.Add {
+			_, err = m.db.ExecContext(ctx, `INSERT INTO group_hierarchy (parent_group_id, child_group_id, relation) VALUES (?, ?, ?)`,
+				c.Parent, c.Child, c.Relation)
+		} else {
+			_, err = m.db.ExecContext(ctx, `DELETE FROM group_hierarchy WHERE parent_group_id = ? AND child_group_id = ?`,
+				c.Parent, c.Child)
+		}
+		if err != nil {
+			return 0, err
+		}
+		m.graph.Apply(c)
+		parents = append(parents, c.Parent)
+	}
+	if len(parents) == 0 {
+		return 0, nil
+	}
+
+	affected := m.graph.Ancestors(parents...)
+	list := make([]string, len(affected))
+	for i, g := range affected {
+		list[i] = strconv.Itoa(g)
+	}
+	if _, err := m.db.ExecContext(ctx, `DELETE FROM group_members_expanded WHERE group_id IN (`+strings.Join(list, ",")+`)`); err != nil {
+		return 0, err
+	}
+
+	expand := m.expander()
+	rows := 0
+	args := make([]any, 0, 3*batchSize)
+	flush := func() error {
+		if len(args) == 0 {
+			return nil
+		}
+		values := strings.TrimSuffix(strings.Repeat("(?,?,?),", len(args)/3), ",")
+		_, err := m.db.ExecContext(ctx, `INSERT INTO group_members_expanded (group_id, user_id, role) VALUES `+values, args...)
+		rows += len(args) / 3
+		args = args[:0]
+		return err
+	}
+	for _, g := range affected {
+		managers, members := expand(g)
+		for _, r := range []struct {
+			users map[int]bool
+			role  string
+		}{{managers, "manager"}, {members, "member"}} {
+			for u := range r.users {
+				args = append(args, g, u, r.role)
+				if len(args) == 3*batchSize {
+					if err := flush(); err != nil {
+						return 0, err
+					}
+				}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	return rows, nil
+}
+
+// expander returns the group_members_expanded users of a group as the loader
+// computes them: its effective managers (direct managers and the managers of
+// manager_group children) and its effective members (its managers, direct
+// members and the members of member_group children). Results are memoized
+// for one burst; a cycle is cut where it closes.
+func (m *expandedMaintainer) expander() func(group int) (managers, members map[int]bool) {
+	type roles struct{ managers, members map[int]bool }
+	memo := map[int]roles{}
+	visiting := map[int]bool{}
+	var expand func(group int) roles
+	expand = func(group int) roles {
+		if users, ok := memo[group]; ok {
+			return users
+		}
+		users := roles{managers: map[int]bool{}, members: map[int]bool{}}
+		if visiting[group] {
+			return users
+		}
+		visiting[group] = true
+		for u, r := range m.direct[group] {
+			if r == "manager" {
+				users.managers[u] = true
+			}
+			users.members[u] = true
+		}
+		for child, rel := range m.graph.Children[group] {
+			sub := expand(child)
+			if rel == "manager_group" {
+				for u := range sub.managers {
+					users.managers[u] = true
+					users.members[u] = true
+				}
+				continue
+			}
+			for u := range sub.members {
+				users.members[u] = true
+			}
+		}
+		visiting[group] = false
+		memo[group] = users
+		return users
+	}
+	return func(group int) (map[int]bool, map[int]bool) {
+		users := expand(group)
+		return users.managers, users.members
+	}
+}
+
+// ClickhouseGroupChurn benchmarks the incremental maintenance of
+// group_members_expanded per burst of group hierarchy changes; see
+// utils.RunGroupChurn.
+func ClickhouseGroupChurn() {
+	ctx := context.Background()
+	db, cleanup, err := infrastructure.NewClickhouseFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[clickhouse] group_churn: connect failed: %v", err)
+	}
+	defer cleanup()
+
+	utils.RunGroupChurn("clickhouse", newExpandedMaintainer(ctx, db))
+	log.Println("[clickhouse] == ClickHouse group churn DONE ==")
+}
@@ -2,20 +2,19 @@ package clickhouse
 
 import (
 	"context"
-	"encoding/csv"
+	"database/sql"
 	"fmt"
-	"io"
+	"iter"
 	"log"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"test-tls/dataset"
 	"test-tls/infrastructure"
+	"test-tls/utils"
 )
 
 const (
-	dataDir   = "data"
 	batchSize = 2000
 )
 
@@ -35,10 +34,8 @@ func ClickhouseCreateData() {
 	defer cleanup()
 
 	start := time.Now()
-	log.Printf("[clickhouse] == Starting Clickhouse data import from CSV in %q ==", dataDir)
-
-	// Read resources first to be able to look up org_id when inserting resource_acl
-	resourcesMap := make(map[string]uint32)
+	log.Printf("[clickhouse] == Starting Clickhouse data import from CSV in %q ==", utils.DataDir())
+	utils.MarkDatasetLoading("clickhouse", datasetStore{db})
 
 	// Truncate target tables to ensure overwrite semantics
 	tablesToTruncate := []string{
@@ -53,540 +50,177 @@ func ClickhouseCreateData() {
 		}
 	}
 
-	// Helper to open a csv reader
-	openCSV := func(name string) (*csv.Reader, *os.File) {
-		full := filepath.Join(dataDir, name)
-		f, err := os.Open(full)
-		if err != nil {
-			log.Fatalf("[clickhouse] open %s: %v", full, err)
-		}
-		return csv.NewReader(f), f
-	}
-
-	// Bulk insert helper: builds a multi-row INSERT with placeholders.
-	insertRows := func(table string, cols []string, rows [][]interface{}) error {
-		if len(rows) == 0 {
-			return nil
-		}
-		// build query like: INSERT INTO table (c1,c2) VALUES (?,?),(?,?)...
-		colList := strings.Join(cols, ",")
-		var sb strings.Builder
-		sb.WriteString("INSERT INTO ")
-		sb.WriteString(table)
-		sb.WriteString(" (")
-		sb.WriteString(colList)
-		sb.WriteString(") VALUES ")
-
-		placeholdersPerRow := make([]string, len(cols))
-		for i := range placeholdersPerRow {
-			placeholdersPerRow[i] = "?"
-		}
-		rowPlaceholder := "(" + strings.Join(placeholdersPerRow, ",") + ")"
-
-		args := make([]interface{}, 0, len(rows)*len(cols))
-		for i, r := range rows {
-			if i > 0 {
-				sb.WriteString(",")
-			}
-			sb.WriteString(rowPlaceholder)
-			args = append(args, r...)
-		}
-
-		_, err := db.ExecContext(ctx, sb.String(), args...)
-		if err != nil {
-			return fmt.Errorf("insert %s: %w", table, err)
-		}
-		return nil
-	}
-
-	// organizations
-	func() {
-		r, f := openCSV("organizations.csv")
-		defer f.Close()
-		// header
-		if _, err := r.Read(); err != nil {
-			log.Fatalf("[clickhouse] read organizations header: %v", err)
-		}
-		rows := make([][]interface{}, 0, batchSize)
-		count := 0
-		for {
-			rec, err := r.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Fatalf("[clickhouse] read organizations row: %v", err)
-			}
-			if len(rec) < 1 {
-				log.Fatalf("[clickhouse] invalid organizations row: %#v", rec)
-			}
-			rows = append(rows, []interface{}{rec[0]})
-			count++
-			if count%10000 == 0 {
-				log.Printf("[clickhouse] Loaded organizations progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
-			}
-			if len(rows) >= batchSize {
-				if err := insertRows("organizations", []string{"org_id"}, rows); err != nil {
-					log.Fatalf("[clickhouse] %v", err)
-				}
-				rows = rows[:0]
-			}
-		}
-		if len(rows) > 0 {
-			if err := insertRows("organizations", []string{"org_id"}, rows); err != nil {
-				log.Fatalf("[clickhouse] %v", err)
-			}
-		}
-		log.Printf("[clickhouse] Loaded organizations: %d rows", count)
-	}()
-
-	// users
-	func() {
-		r, f := openCSV("users.csv")
-		defer f.Close()
-		if _, err := r.Read(); err != nil {
-			log.Fatalf("[clickhouse] read users header: %v", err)
-		}
-		rows := make([][]interface{}, 0, batchSize)
-		count := 0
-		for {
-			rec, err := r.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Fatalf("[clickhouse] read users row: %v", err)
-			}
-			if len(rec) < 2 {
-				log.Fatalf("[clickhouse] invalid users row: %#v", rec)
-			}
-			rows = append(rows, []interface{}{rec[0], rec[1]})
-			count++
-			if count%10000 == 0 {
-				log.Printf("[clickhouse] Loaded users progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
-			}
-			if len(rows) >= batchSize {
-				if err := insertRows("users", []string{"user_id", "primary_org_id"}, rows); err != nil {
-					log.Fatalf("[clickhouse] %v", err)
-				}
-				rows = rows[:0]
-			}
-		}
-		if len(rows) > 0 {
-			if err := insertRows("users", []string{"user_id", "primary_org_id"}, rows); err != nil {
-				log.Fatalf("[clickhouse] %v", err)
-			}
-		}
-		log.Printf("[clickhouse] Loaded users: %d rows", count)
-	}()
-
-	// groups
-	func() {
-		r, f := openCSV("groups.csv")
-		defer f.Close()
-		if _, err := r.Read(); err != nil {
-			log.Fatalf("[clickhouse] read groups header: %v", err)
-		}
-		rows := make([][]interface{}, 0, batchSize)
-		count := 0
-		for {
-			rec, err := r.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Fatalf("[clickhouse] read groups row: %v", err)
-			}
-			if len(rec) < 2 {
-				log.Fatalf("[clickhouse] invalid groups row: %#v", rec)
-			}
-			rows = append(rows, []interface{}{rec[0], rec[1]})
-			count++
-			if count%10000 == 0 {
-				log.Printf("[clickhouse] Loaded groups progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
-			}
-			if len(rows) >= batchSize {
-				if err := insertRows("groups", []string{"group_id", "org_id"}, rows); err != nil {
-					log.Fatalf("[clickhouse] %v", err)
-				}
-				rows = rows[:0]
-			}
-		}
-		if len(rows) > 0 {
-			if err := insertRows("groups", []string{"group_id", "org_id"}, rows); err != nil {
-				log.Fatalf("[clickhouse] %v", err)
-			}
-		}
-		log.Printf("[clickhouse] Loaded groups: %d rows", count)
-	}()
-
-	// org_memberships
-	func() {
-		r, f := openCSV("org_memberships.csv")
-		defer f.Close()
-		if _, err := r.Read(); err != nil {
-			log.Fatalf("[clickhouse] read org_memberships header: %v", err)
-		}
-		rows := make([][]interface{}, 0, batchSize)
-		count := 0
-		for {
-			rec, err := r.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Fatalf("[clickhouse] read org_memberships row: %v", err)
-			}
-			if len(rec) < 3 {
-				log.Fatalf("[clickhouse] invalid org_memberships row: %#v", rec)
-			}
-			role := rec[2]
-			if role != "admin" {
-				role = "member"
-			}
-			rows = append(rows, []interface{}{rec[0], rec[1], role})
-			count++
-			if count%10000 == 0 {
-				log.Printf("[clickhouse] Loaded org_memberships progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
-			}
-			if len(rows) >= batchSize {
-				if err := insertRows("org_memberships", []string{"org_id", "user_id", "role"}, rows); err != nil {
-					log.Fatalf("[clickhouse] %v", err)
-				}
-				rows = rows[:0]
-			}
-		}
-		if len(rows) > 0 {
-			if err := insertRows("org_memberships", []string{"org_id", "user_id", "role"}, rows); err != nil {
-				log.Fatalf("[clickhouse] %v", err)
-			}
-		}
-		log.Printf("[clickhouse] Loaded org_memberships: %d rows", count)
-	}()
+	total := 0
+	insertTable(ctx, db, &total, "organizations", []string{"org_id"}, dataset.Organizations(),
+		func(o dataset.Organization) []any { return []any{o.ID.N} })
+	insertTable(ctx, db, &total, "users", []string{"user_id", "primary_org_id"}, dataset.Users(),
+		func(u dataset.User) []any { return []any{u.ID.N, u.Org.N} })
+	insertTable(ctx, db, &total, "groups", []string{"group_id", "org_id"}, dataset.Groups(),
+		func(g dataset.Group) []any { return []any{g.ID.N, g.Org.N} })
+	insertTable(ctx, db, &total, "org_memberships", []string{"org_id", "user_id", "role"}, dataset.OrgMemberships(),
+		func(m dataset.OrgMembership) []any { return []any{m.Org.N, m.User.N, m.Role} })
 
 	// group_memberships (also build direct membership map for expansion)
-	groupMembersDirect := make(map[string]map[string]string) // groupID -> userID -> role
-	func() {
-		r, f := openCSV("group_memberships.csv")
-		defer f.Close()
-		if _, err := r.Read(); err != nil {
-			log.Fatalf("[clickhouse] read group_memberships header: %v", err)
-		}
-		rows := make([][]interface{}, 0, batchSize)
-		count := 0
-		for {
-			rec, err := r.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Fatalf("[clickhouse] read group_memberships row: %v", err)
-			}
-			if len(rec) < 3 {
-				log.Fatalf("[clickhouse] invalid group_memberships row: %#v", rec)
-			}
-			role := rec[2]
-			switch role {
-			case "direct_manager", "admin", "manager":
-				role = "manager"
-			default:
-				role = "member"
-			}
-			rows = append(rows, []interface{}{rec[0], rec[1], role})
-			// store direct
-			gm := groupMembersDirect[rec[0]]
+	groupMembersDirect := make(map[int]map[int]string) // groupID -> userID -> role
+	insertTable(ctx, db, &total, "group_memberships", []string{"group_id", "user_id", "role"}, dataset.GroupMemberships(),
+		func(m dataset.GroupMembership) []any {
+			role := groupRole(m.Role)
+			gm := groupMembersDirect[m.Group.N]
 			if gm == nil {
-				gm = make(map[string]string)
-				groupMembersDirect[rec[0]] = gm
-			}
-			gm[rec[1]] = role
-			count++
-			if count%10000 == 0 {
-				log.Printf("[clickhouse] Loaded group_memberships progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
-			}
-			if len(rows) >= batchSize {
-				if err := insertRows("group_memberships", []string{"group_id", "user_id", "role"}, rows); err != nil {
-					log.Fatalf("[clickhouse] %v", err)
-				}
-				rows = rows[:0]
+				gm = make(map[int]string)
+				groupMembersDirect[m.Group.N] = gm
 			}
-		}
-		if len(rows) > 0 {
-			if err := insertRows("group_memberships", []string{"group_id", "user_id", "role"}, rows); err != nil {
-				log.Fatalf("[clickhouse] %v", err)
-			}
-		}
-		log.Printf("[clickhouse] Loaded group_memberships: %d rows", count)
-	}()
+			gm[m.User.N] = role
+			return []any{m.Group.N, m.User.N, role}
+		})
 
 	// group_hierarchy (also build adjacency)
-	groupChildren := make(map[string]map[string]string) // parent -> child -> relation
-	func() {
-		r, f := openCSV("group_hierarchy.csv")
-		defer f.Close()
-		// header may not exist
-		if _, err := r.Read(); err != nil {
-			if os.IsNotExist(err) {
-				log.Printf("[clickhouse] group_hierarchy.csv not found, skipping nested groups")
-				return
-			}
-			log.Fatalf("[clickhouse] read group_hierarchy header: %v", err)
-		}
-		rows := make([][]interface{}, 0, batchSize)
-		count := 0
-		for {
-			rec, err := r.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Fatalf("[clickhouse] read group_hierarchy row: %v", err)
-			}
-			if len(rec) < 3 {
-				log.Fatalf("[clickhouse] invalid group_hierarchy row: %#v", rec)
-			}
-			rel := rec[2]
-			if rel != "manager_group" && rel != "member_group" {
-				log.Fatalf("[clickhouse] unknown group_hierarchy relation: %q", rel)
-			}
-			rows = append(rows, []interface{}{rec[0], rec[1], rel})
-			// store adjacency
-			m := groupChildren[rec[0]]
+	groupChildren := make(map[int]map[int]string) // parent -> child -> relation
+	insertTable(ctx, db, &total, "group_hierarchy", []string{"parent_group_id", "child_group_id", "relation"}, dataset.GroupHierarchy(),
+		func(e dataset.GroupEdge) []any {
+			m := groupChildren[e.Parent.N]
 			if m == nil {
-				m = make(map[string]string)
-				groupChildren[rec[0]] = m
-			}
-			m[rec[1]] = rel
-			count++
-			if count%10000 == 0 {
-				log.Printf("[clickhouse] Loaded group_hierarchy progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
-			}
-			if len(rows) >= batchSize {
-				if err := insertRows("group_hierarchy", []string{"parent_group_id", "child_group_id", "relation"}, rows); err != nil {
-					log.Fatalf("[clickhouse] %v", err)
-				}
-				rows = rows[:0]
-			}
-		}
-		if len(rows) > 0 {
-			if err := insertRows("group_hierarchy", []string{"parent_group_id", "child_group_id", "relation"}, rows); err != nil {
-				log.Fatalf("[clickhouse] %v", err)
-			}
-		}
-		log.Printf("[clickhouse] Loaded group_hierarchy: %d rows", count)
-	}()
-
-	// resources
-	func() {
-		r, f := openCSV("resources.csv")
-		defer f.Close()
-		if _, err := r.Read(); err != nil {
-			log.Fatalf("[clickhouse] read resources header: %v", err)
-		}
-		rows := make([][]interface{}, 0, batchSize)
-		count := 0
-		for {
-			rec, err := r.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Fatalf("[clickhouse] read resources row: %v", err)
-			}
-			if len(rec) < 2 {
-				log.Fatalf("[clickhouse] invalid resources row: %#v", rec)
-			}
-			// store mapping for resource_acl org lookup
-			resourcesMap[rec[0]] = toUint32StringVal(rec[1])
-			rows = append(rows, []interface{}{rec[0], rec[1]})
-			count++
-			if count%10000 == 0 {
-				log.Printf("[clickhouse] Loaded resources progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
-			}
-			if len(rows) >= batchSize {
-				if err := insertRows("resources", []string{"resource_id", "org_id"}, rows); err != nil {
-					log.Fatalf("[clickhouse] %v", err)
-				}
-				rows = rows[:0]
-			}
-		}
-		if len(rows) > 0 {
-			if err := insertRows("resources", []string{"resource_id", "org_id"}, rows); err != nil {
-				log.Fatalf("[clickhouse] %v", err)
-			}
-		}
-		log.Printf("[clickhouse] Loaded resources: %d rows", count)
-	}()
-
-	// resource_acl
-	func() {
-		r, f := openCSV("resource_acl.csv")
-		defer f.Close()
-		if _, err := r.Read(); err != nil {
-			log.Fatalf("[clickhouse] read resource_acl header: %v", err)
-		}
-		rows := make([][]interface{}, 0, batchSize)
-		count := 0
-		for {
-			rec, err := r.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Fatalf("[clickhouse] read resource_acl row: %v", err)
-			}
-			if len(rec) < 4 {
-				log.Fatalf("[clickhouse] invalid resource_acl row: %#v", rec)
-			}
-			resID := rec[0]
-			subjType := rec[1]
-			subjID := rec[2]
-			rel := rec[3]
-
-			// map relation names to simple 'viewer'|'manager'
-			switch rel {
-			case "manager_user", "manager_group", "manager":
-				rel = "manager"
-			case "viewer_user", "viewer_group", "viewer":
-				rel = "viewer"
-			default:
-				log.Fatalf("[clickhouse] unknown resource_acl relation: %q", rel)
-			}
-
-			// lookup org_id from resourcesMap
-			orgIDVal, ok := resourcesMap[resID]
+				m = make(map[int]string)
+				groupChildren[e.Parent.N] = m
+			}
+			m[e.Child.N] = e.Relation
+			return []any{e.Parent.N, e.Child.N, e.Relation}
+		})
+
+	// group_members_expanded before resource_acl: user_resource_permissions_mv
+	// joins it when the grants are inserted
+	loadGroupMembersExpanded(ctx, db, groupMembersDirect, groupChildren)
+
+	// resources (kept in memory to look up org_id when inserting resource_acl)
+	resourceOrgs := make(map[int]int)
+	insertTable(ctx, db, &total, "resources", []string{"resource_id", "org_id", "created_at", "updated_at"}, dataset.Resources(),
+		func(r dataset.Resource) []any {
+			resourceOrgs[r.ID.N] = r.Org.N
+			return []any{r.ID.N, r.Org.N, r.CreatedAt, r.UpdatedAt}
+		})
+
+	insertTable(ctx, db, &total, "resource_acl", []string{"resource_id", "org_id", "subject_type", "subject_id", "relation", "valid_from", "valid_until", "created_at"}, dataset.ResourceACL(),
+		func(a dataset.ACL) []any {
+			orgID, ok := resourceOrgs[a.Resource.N]
 			if !ok {
-				log.Fatalf("[clickhouse] resource %s not found in resources.csv (needed for org_id)", resID)
-			}
-
-			// subject_type must be 'user' or 'group'
-			if subjType != "user" && subjType != "group" {
-				log.Fatalf("[clickhouse] unknown subject_type in resource_acl: %q", subjType)
+				log.Fatalf("[clickhouse] resource %s not found in resources.csv (needed for org_id)", a.Resource)
 			}
+			// map relation names to simple 'viewer'|'manager'
+			rel, _, _ := strings.Cut(a.Relation, "_")
+			return []any{a.Resource.N, orgID, a.SubjectType, a.Subject.N, rel, a.Window.From, a.Window.Until, a.CreatedAt}
+		})
+	utils.RecordDataset("clickhouse", datasetStore{db})
 
-			rows = append(rows, []interface{}{resID, fmt.Sprintf("%d", orgIDVal), subjType, subjID, rel})
-			count++
-			if count%10000 == 0 {
-				log.Printf("[clickhouse] Loaded resource_acl progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
-			}
-			if len(rows) >= batchSize {
-				if err := insertRows("resource_acl", []string{"resource_id", "org_id", "subject_type", "subject_id", "relation"}, rows); err != nil {
-					log.Fatalf("[clickhouse] %v", err)
-				}
-				rows = rows[:0]
-			}
-		}
-		if len(rows) > 0 {
-			if err := insertRows("resource_acl", []string{"resource_id", "org_id", "subject_type", "subject_id", "relation"}, rows); err != nil {
-				log.Fatalf("[clickhouse] %v", err)
-			}
-		}
-		log.Printf("[clickhouse] Loaded resource_acl: %d rows", count)
-	}()
+	elapsed := time.Since(start).Truncate(time.Millisecond)
+	log.Printf("[clickhouse] Clickhouse data import DONE: elapsed=%s", elapsed)
+}
 
-	// Compute group_members_expanded transitive closure via iterative propagation
-	func() {
-		// start with direct memberships
-		expanded := make(map[string]map[string]string) // group -> user -> role
-		for g, m := range groupMembersDirect {
-			em := make(map[string]string)
-			for u, r := range m {
-				em[u] = r
+// loadGroupMembersExpanded writes the effective members of every group,
+// nested groups included, to group_members_expanded: a 'manager' row for the
+// group's direct managers and the managers of its manager_group children, and
+// a 'member' row for its managers, its direct members and the members of its
+// member_group children, transitively. direct maps a group to its users'
+// roles (see groupRole), children a parent to its child groups' relations.
+func loadGroupMembersExpanded(ctx context.Context, db *sql.DB, direct map[int]map[int]string, children map[int]map[int]string) {
+	managers := make(map[int]map[int]bool)
+	members := make(map[int]map[int]bool)
+	add := func(sets map[int]map[int]bool, group, user int) bool {
+		set := sets[group]
+		if set == nil {
+			set = make(map[int]bool)
+			sets[group] = set
+		}
+		if set[user] {
+			return false
+		}
+		set[user] = true
+		return true
+	}
+	for g, users := range direct {
+		for u, role := range users {
+			if role == "manager" {
+				add(managers, g, u)
 			}
-			expanded[g] = em
+			add(members, g, u)
 		}
+	}
 
-		changed := true
-		for changed {
-			changed = false
-			// for every parent -> child edge
-			for parent, children := range groupChildren {
-				em := expanded[parent]
-				if em == nil {
-					em = make(map[string]string)
-					expanded[parent] = em
-				}
-				for child, rel := range children {
-					// ensure child has an expanded set (may be empty)
-					childSet := expanded[child]
-					if childSet == nil {
-						childSet = make(map[string]string)
-					}
-					// for each user in child's expanded set, decide whether to import
-					for u, r := range childSet {
-						// if edge is member_group, propagate only 'member' roles
-						// if edge is manager_group, propagate only 'manager' roles
-						if rel == "member_group" {
-							if r != "member" {
-								continue
-							}
-							if _, ok := em[u]; !ok {
-								em[u] = "member"
-								changed = true
-							}
-						} else if rel == "manager_group" {
-							if r != "manager" {
-								continue
-							}
-							if _, ok := em[u]; !ok {
-								em[u] = "manager"
-								changed = true
-							}
-						}
+	// propagate up the hierarchy until nothing changes; cycles end it too
+	for changed := true; changed; {
+		changed = false
+		for parent, edges := range children {
+			for child, rel := range edges {
+				if rel == "manager_group" {
+					for u := range managers[child] {
+						changed = add(managers, parent, u) || changed
 					}
 				}
-			}
-			// also ensure direct members propagate into expanded (in case children referenced new groups)
-			for g, m := range groupMembersDirect {
-				em := expanded[g]
-				if em == nil {
-					em = make(map[string]string)
-					expanded[g] = em
+				for u := range managers[parent] {
+					changed = add(members, parent, u) || changed
 				}
-				for u, r := range m {
-					if _, ok := em[u]; !ok {
-						em[u] = r
-						changed = true
+				if rel == "member_group" {
+					for u := range members[child] {
+						changed = add(members, parent, u) || changed
 					}
 				}
 			}
 		}
+	}
 
-		// write expanded into table
-		rows := make([][]interface{}, 0, batchSize)
-		total := 0
-		for g, m := range expanded {
-			for u, r := range m {
-				rows = append(rows, []interface{}{g, u, r})
-				total++
-				if len(rows) >= batchSize {
-					if err := insertRows("group_members_expanded", []string{"group_id", "user_id", "role"}, rows); err != nil {
-						log.Fatalf("[clickhouse] %v", err)
+	type member struct {
+		group, user int
+		role        string
+	}
+	all := func(yield func(member) bool) {
+		for _, r := range []struct {
+			sets map[int]map[int]bool
+			role string
+		}{{managers, "manager"}, {members, "member"}} {
+			for g, users := range r.sets {
+				for u := range users {
+					if !yield(member{g, u, r.role}) {
+						return
 					}
-					rows = rows[:0]
 				}
 			}
 		}
-		if len(rows) > 0 {
-			if err := insertRows("group_members_expanded", []string{"group_id", "user_id", "role"}, rows); err != nil {
-				log.Fatalf("[clickhouse] %v", err)
-			}
-		}
-		log.Printf("[clickhouse] Populated group_members_expanded: %d rows", total)
-	}()
+	}
+	expandedRows := 0
+	insertTable(ctx, db, &expandedRows, "group_members_expanded", []string{"group_id", "user_id", "role"}, all,
+		func(m member) []any { return []any{m.group, m.user, m.role} })
+}
 
-	elapsed := time.Since(start).Truncate(time.Millisecond)
-	log.Printf("[clickhouse] Clickhouse data import DONE: elapsed=%s", elapsed)
+// groupRole maps a group_memberships role onto the 'manager'|'member' values
+// of the ClickHouse tables.
+func groupRole(role string) string {
+	if role == "direct_manager" {
+		return "manager"
+	}
+	return "member"
 }
 
-// helper: parse string to uint32 with fallback 0
-func toUint32StringVal(s string) uint32 {
-	var v uint32
-	var tmp uint64
-	if _, err := fmt.Sscan(strings.TrimSpace(s), &tmp); err == nil {
-		v = uint32(tmp)
-	} else {
-		v = 0
+// insertTable writes rows into table as multi-row INSERTs of batchSize rows.
+func insertTable[T any](ctx context.Context, db *sql.DB, total *int, table string, cols []string, rows iter.Seq[T], values func(T) []any) {
+	progress := dataset.NewProgress("clickhouse", table, total)
+
+	// build query like: INSERT INTO table (c1,c2) VALUES (?,?),(?,?)...
+	rowPlaceholder := "(" + strings.TrimSuffix(strings.Repeat("?,", len(cols)), ",") + ")"
+	args := make([]any, 0, batchSize*len(cols))
+	for batch := range dataset.Batches(rows, batchSize) {
+		var sb strings.Builder
+		sb.WriteString("INSERT INTO " + table + " (" + strings.Join(cols, ",") + ") VALUES ")
+		args = args[:0]
+		for i, row := range batch {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString(rowPlaceholder)
+			args = append(args, values(row)...)
+		}
+		if _, err := db.ExecContext(ctx, sb.String(), args...); err != nil {
+			log.Fatalf("[clickhouse] insert %s: %v", table, err)
+		}
+		progress.Add(len(batch))
 	}
-	return v
+	progress.Done()
 }
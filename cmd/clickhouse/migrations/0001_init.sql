@@ -78,7 +78,10 @@ CREATE TABLE IF NOT EXISTS resource_acl (
     org_id UInt32,
     subject_type Enum8('user' = 1, 'group' = 2),
     subject_id UInt32,
-    relation Enum8('viewer' = 1, 'manager' = 2)
+    relation Enum8('viewer' = 1, 'manager' = 2),
+    -- Optional validity window; expired rows are kept as soft deletes.
+    valid_from Nullable(DateTime),
+    valid_until Nullable(DateTime)
 ) ENGINE = MergeTree
 PARTITION BY org_id
 ORDER BY (org_id, resource_id, relation, subject_type, subject_id);
@@ -0,0 +1,4 @@
+-- Creation time of each resource, for the authorized listing scenario
+-- (list_recent_viewable). NULL for data loaded from a resources.csv without
+-- the column.
+ALTER TABLE resources ADD COLUMN IF NOT EXISTS created_at Nullable(DateTime);
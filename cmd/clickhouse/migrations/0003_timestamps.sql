@@ -0,0 +1,6 @@
+-- When a resource was last edited and when a grant was made, for recency
+-- ordering, time-window filters and TTL-style experiments. NULL for data
+-- loaded from CSVs without the columns.
+ALTER TABLE resources ADD COLUMN IF NOT EXISTS updated_at Nullable(DateTime);
+
+ALTER TABLE resource_acl ADD COLUMN IF NOT EXISTS created_at Nullable(DateTime);
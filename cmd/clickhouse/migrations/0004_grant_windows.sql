@@ -0,0 +1,47 @@
+-- user_resource_permissions_mv without the grants whose valid_from/valid_until
+-- window does not cover now(), so the table denies expired (and not yet
+-- active) grants the way the valid_window caveat of the SpiceDB schema does.
+-- The view runs on insert, so the window is evaluated when a grant is loaded:
+-- one that expires afterwards stays in user_resource_permissions until the
+-- data is reloaded. The definition is otherwise that of 0001_init.sql.
+DROP VIEW IF EXISTS user_resource_permissions_mv;
+
+CREATE MATERIALIZED VIEW user_resource_permissions_mv
+TO user_resource_permissions AS
+SELECT
+    ra.resource_id AS resource_id,
+    ra.subject_id AS user_id,
+    ra.relation AS relation
+FROM resource_acl AS ra
+WHERE ra.subject_type = 'user'
+  AND (ra.valid_from IS NULL OR ra.valid_from <= now())
+  AND (ra.valid_until IS NULL OR ra.valid_until > now())
+UNION ALL
+SELECT
+    ra.resource_id AS resource_id,
+    gme.user_id AS user_id,
+    ra.relation AS relation
+FROM resource_acl AS ra
+JOIN group_members_expanded AS gme
+    ON gme.group_id = ra.subject_id
+WHERE ra.subject_type = 'group'
+  AND (ra.valid_from IS NULL OR ra.valid_from <= now())
+  AND (ra.valid_until IS NULL OR ra.valid_until > now())
+UNION ALL
+SELECT
+    r.resource_id AS resource_id,
+    om.user_id AS user_id,
+    'manager' AS relation
+FROM resources AS r
+JOIN org_memberships AS om
+    ON om.org_id = r.org_id
+WHERE om.role = 'admin'
+UNION ALL
+SELECT
+    r.resource_id AS resource_id,
+    om.user_id AS user_id,
+    'viewer' AS relation
+FROM resources AS r
+JOIN org_memberships AS om
+    ON om.org_id = r.org_id
+WHERE om.role = 'member' OR om.role = 'admin';
@@ -0,0 +1,49 @@
+-- user_resource_permissions_mv with the group branch split by role: a
+-- manager_group grant makes the effective managers of the group managers, a
+-- viewer_group grant makes its effective members (managers included) viewers.
+-- 0004_grant_windows.sql joined every row of group_members_expanded, so plain
+-- members of a manager group came out as managers. The loader writes a
+-- 'manager' and a 'member' row for every effective manager since this
+-- migration. The definition is otherwise that of 0004_grant_windows.sql.
+DROP VIEW IF EXISTS user_resource_permissions_mv;
+
+CREATE MATERIALIZED VIEW user_resource_permissions_mv
+TO user_resource_permissions AS
+SELECT
+    ra.resource_id AS resource_id,
+    ra.subject_id AS user_id,
+    ra.relation AS relation
+FROM resource_acl AS ra
+WHERE ra.subject_type = 'user'
+  AND (ra.valid_from IS NULL OR ra.valid_from <= now())
+  AND (ra.valid_until IS NULL OR ra.valid_until > now())
+UNION ALL
+SELECT
+    ra.resource_id AS resource_id,
+    gme.user_id AS user_id,
+    ra.relation AS relation
+FROM resource_acl AS ra
+JOIN group_members_expanded AS gme
+    ON gme.group_id = ra.subject_id
+WHERE ra.subject_type = 'group'
+  AND ((ra.relation = 'manager' AND gme.role = 'manager') OR (ra.relation = 'viewer' AND gme.role = 'member'))
+  AND (ra.valid_from IS NULL OR ra.valid_from <= now())
+  AND (ra.valid_until IS NULL OR ra.valid_until > now())
+UNION ALL
+SELECT
+    r.resource_id AS resource_id,
+    om.user_id AS user_id,
+    'manager' AS relation
+FROM resources AS r
+JOIN org_memberships AS om
+    ON om.org_id = r.org_id
+WHERE om.role = 'admin'
+UNION ALL
+SELECT
+    r.resource_id AS resource_id,
+    om.user_id AS user_id,
+    'viewer' AS relation
+FROM resources AS r
+JOIN org_memberships AS om
+    ON om.org_id = r.org_id
+WHERE om.role = 'member' OR om.role = 'admin';
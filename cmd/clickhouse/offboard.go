@@ -0,0 +1,56 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// offboardSQL deletes a user with every row naming it, derived tables
+// included: user_resource_permissions is filled by a materialized view on
+// insert and does not follow deletes.
+var offboardSQL = []string{
+	`DELETE FROM org_memberships WHERE user_id = ?`,
+	`DELETE FROM group_memberships WHERE user_id = ?`,
+	`DELETE FROM group_members_expanded WHERE user_id = ?`,
+	`DELETE FROM resource_acl WHERE subject_type = 'user' AND subject_id = ?`,
+	`DELETE FROM user_resource_permissions WHERE user_id = ?`,
+	`DELETE FROM users WHERE user_id = ?`,
+}
+
+// userOffboarder deletes users with lightweight DELETEs, which report no
+// row counts (utils.UserOffboarder).
+type userOffboarder struct {
+	db *sql.DB
+}
+
+func (o userOffboarder) Offboard(ctx context.Context, userID string) (int, error) {
+	id, err := ids.Parse(ids.User, userID)
+	if err != nil {
+		return 0, err
+	}
+	for _, stmt := range offboardSQL {
+		if _, err := o.db.ExecContext(ctx, stmt, id); err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
+
+// ClickhouseOffboard benchmarks deleting the users of BENCH_OFFBOARD_USERS
+// and checks they hold nothing afterwards; see utils.RunOffboarding.
+func ClickhouseOffboard() {
+	ctx := context.Background()
+	db, cleanup, err := infrastructure.NewClickhouseFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[clickhouse] offboard: connect failed: %v", err)
+	}
+	defer cleanup()
+
+	utils.RunOffboarding("clickhouse", userOffboarder{db: db}, newChecker(db))
+	log.Println("[clickhouse] == ClickHouse user offboarding DONE ==")
+}
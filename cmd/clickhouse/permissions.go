@@ -0,0 +1,163 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"test-tls/authz"
+	chauthz "test-tls/authz/clickhouse"
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// activeGrantSQL keeps the resource_acl rows whose valid_from/valid_until
+// window covers now(), as user_resource_permissions_mv does since
+// migrations/0004_grant_windows.sql.
+const activeGrantSQL = `(valid_from IS NULL OR valid_from <= now()) AND (valid_until IS NULL OR valid_until > now())`
+
+// checkPermissionCH is the check timed by the check_* scenarios, with
+// server-side parameters when BENCH_PREPARED_STATEMENTS=true.
+func checkPermissionCH(ctx context.Context, db *sql.DB, resourceID, userID any, relation string) (bool, error) {
+	if utils.PreparedStatements() {
+		return chauthz.CheckParams(ctx, db, resourceID, userID, relation)
+	}
+	return chauthz.Check(ctx, db, resourceID, userID, relation)
+}
+
+// listRecentViewableSQL is the authorized listing of list_recent_viewable:
+// the newest resources a user can view. The keyset condition for the pages
+// after the first is inserted at %s.
+const listRecentViewableSQL = `
+	SELECT r.resource_id, assumeNotNull(r.created_at)
+	FROM resources r
+	WHERE r.resource_id IN (
+		SELECT resource_id FROM user_resource_permissions WHERE user_id = ? AND relation = 'viewer'
+	) AND r.created_at IS NOT NULL%s
+	ORDER BY r.created_at DESC, r.resource_id DESC
+	LIMIT ?
+	`
+
+// listRecentViewableCH returns a page of listRecentViewableSQL (see
+// utils.ListPage), after (created_at, resource_id) of the previous page.
+func listRecentViewableCH(ctx context.Context, db *sql.DB, userID string, limit int, after *utils.ListedResource) ([]utils.ListedResource, error) {
+	query, args := fmt.Sprintf(listRecentViewableSQL, ""), []any{userID, limit}
+	if after != nil {
+		resID, err := ids.Parse(ids.Resource, after.ID)
+		if err != nil {
+			return nil, err
+		}
+		query = fmt.Sprintf(listRecentViewableSQL, "\n\t  AND (r.created_at, r.resource_id) < (?, ?)")
+		args = []any{userID, after.CreatedAt, uint32(resID), limit}
+	}
+
+	var page []utils.ListedResource
+	err := streamQuery(ctx, db, query, args, func(rows *sql.Rows) error {
+		var resID uint32
+		var createdAt time.Time
+		if err := rows.Scan(&resID, &createdAt); err != nil {
+			return err
+		}
+		page = append(page, utils.ListedResource{ID: ids.Format(ids.Resource, int(resID)), CreatedAt: createdAt})
+		return nil
+	})
+	return page, err
+}
+
+// ClickhouseServe serves Check/Lookup over HTTP using the benchmark queries.
+func ClickhouseServe() {
+	db, cleanup, err := infrastructure.NewClickhouseFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[clickhouse] failed to create clickhouse client: %v", err)
+	}
+	defer cleanup()
+
+	checker, done := pacedChecker(db)
+	utils.Serve("clickhouse", checker)
+	done()
+}
+
+// ClickhouseReplayAudit re-runs a recorded audit file (REPLAY_AUDIT_FILE) through
+// the benchmark queries.
+func ClickhouseReplayAudit() {
+	db, cleanup, err := infrastructure.NewClickhouseFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[clickhouse] failed to create clickhouse client: %v", err)
+	}
+	defer cleanup()
+
+	utils.ReplayAudit("clickhouse", newChecker(db))
+}
+
+// ClickhouseVerifyFixture checks the benchmark queries against the known
+// answers of the fixture dataset (see utils.VerifyFixture).
+func ClickhouseVerifyFixture() {
+	db, cleanup, err := infrastructure.NewClickhouseFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[clickhouse] failed to create clickhouse client: %v", err)
+	}
+	defer cleanup()
+
+	utils.VerifyFixture("clickhouse", newChecker(db))
+}
+
+// ClickhouseExportPermissions writes a permission snapshot of the benchmark
+// queries for diff-permissions (see utils.ExportPermissions).
+func ClickhouseExportPermissions() {
+	db, cleanup, err := infrastructure.NewClickhouseFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[clickhouse] failed to create clickhouse client: %v", err)
+	}
+	defer cleanup()
+
+	utils.ExportPermissions("clickhouse", newChecker(db))
+}
+
+// ClickhouseWorker replays the shard a distributed coordinator hands out and
+// streams the results back (see utils.RunWorker).
+func ClickhouseWorker() {
+	db, cleanup, err := infrastructure.NewClickhouseFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[clickhouse] failed to create clickhouse client: %v", err)
+	}
+	defer cleanup()
+
+	checker, done := pacedChecker(db)
+	utils.RunWorker("clickhouse", checker)
+	done()
+}
+
+// newChecker returns the benchmark queries over db with the ids of
+// RLP_ID_FORMAT.
+func newChecker(db *sql.DB) authz.Checker {
+	checker, err := chauthz.NewChecker(db, chauthz.Config{IDFormat: ids.CurrentFormat()})
+	if err != nil {
+		log.Fatalf("[clickhouse] %v", err)
+	}
+	return checker
+}
+
+// pacedChecker is newChecker(db) for the concurrent runs (serve,
+// worker). With CH_PACE_MAX_QUERIES set it lets only that many queries run at
+// once (see utils.PacedBackend), below the server's max_concurrent_queries,
+// so high-concurrency results show ClickHouse at a load it accepts rather
+// than its query queue; done then logs the queued and rejected counts.
+//
+// Env vars:
+//
+//	CH_PACE_MAX_QUERIES  (default: 0 = unpaced)
+//	CH_PACE_MAX_WAIT_MS  (default: 0 = until the call's timeout)
+func pacedChecker(db *sql.DB) (authz.Checker, func()) {
+	checker := newChecker(db)
+	limit := utils.GetEnvInt("CH_PACE_MAX_QUERIES", 0)
+	if limit <= 0 {
+		return checker, func() {}
+	}
+	maxWait := time.Duration(utils.GetEnvInt("CH_PACE_MAX_WAIT_MS", 0)) * time.Millisecond
+	log.Printf("[clickhouse] pacing: at most %d queries at once, max wait %s", limit, maxWait)
+	paced := utils.NewPacedBackend("clickhouse", checker, limit, maxWait)
+	return paced, paced.LogStats
+}
@@ -0,0 +1,22 @@
+package clickhouse
+
+import (
+	chauthz "test-tls/authz/clickhouse"
+	"test-tls/ids"
+	"test-tls/utils"
+)
+
+// ClickhousePrintQueries prints the queries of the read scenarios with
+// sampled parameters instead of running them (see utils.PrintQueries).
+func ClickhousePrintQueries() {
+	utils.PrintQueries("clickhouse", func(s utils.QuerySample) string {
+		user := utils.SampleInt(ids.User, s.User)
+		switch s.Scenario {
+		case "check_time_bounded_direct_user":
+			return utils.RenderSQL(timeBoundedCheckSQL, utils.SampleInt(ids.Resource, s.Resource), user, s.Relation)
+		case "lookup_resources_manage_super", "lookup_resources_view_regular":
+			return utils.RenderSQL(lookupCountSQL, user, chauthz.Relations[s.Permission])
+		}
+		return utils.RenderSQL(chauthz.CheckSQL, utils.SampleInt(ids.Resource, s.Resource), user, chauthz.Relations[s.Permission])
+	})
+}
@@ -0,0 +1,347 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// sortKeyVariant is one layout of resource_acl and user_resource_permissions
+// for `clickhouse sort-keys`: the table settings (PARTITION BY, ORDER BY) that
+// follow the column list of each copy, and an optional projection each.
+type sortKeyVariant struct {
+	name         string
+	acl, aclProj string
+	urp, urpProj string
+}
+
+// Projections of the variants: a second sort order, so one table serves
+// lookups by resource and by subject.
+const (
+	currentACLProjection = `PROJECTION resource_acl_by_subject (
+		SELECT org_id, subject_type, subject_id, relation, resource_id
+		ORDER BY (org_id, subject_type, subject_id, relation, resource_id)
+	)`
+	aclBySubjectProjection = `PROJECTION acl_by_subject (
+		SELECT org_id, subject_type, subject_id, relation, resource_id
+		ORDER BY (subject_type, subject_id, relation, resource_id)
+	)`
+	urpByUserProjection = `PROJECTION urp_by_user (
+		SELECT resource_id, user_id, relation
+		ORDER BY (user_id, relation, resource_id)
+	)`
+)
+
+// sortKeyVariants are the layouts compared by `clickhouse sort-keys`.
+// "current" repeats migrations/0001_init.sql, so every variant is a fresh,
+// fully merged copy and only the layout differs. The skip indexes of the
+// migration are left out of every copy.
+var sortKeyVariants = []sortKeyVariant{
+	{
+		name:    "current",
+		acl:     `PARTITION BY org_id ORDER BY (org_id, resource_id, relation, subject_type, subject_id)`,
+		aclProj: currentACLProjection,
+		urp:     `PARTITION BY intDiv(user_id, 10000) ORDER BY (user_id, resource_id, relation)`,
+	},
+	{
+		name: "resource_first",
+		acl:  `ORDER BY (resource_id, relation, subject_type, subject_id)`,
+		urp:  `ORDER BY (resource_id, user_id, relation)`,
+	},
+	{
+		name: "user_first",
+		acl:  `ORDER BY (subject_type, subject_id, relation, resource_id)`,
+		urp:  `ORDER BY (user_id, relation, resource_id)`,
+	},
+	{
+		name:    "resource_first_proj",
+		acl:     `ORDER BY (resource_id, relation, subject_type, subject_id)`,
+		aclProj: aclBySubjectProjection,
+		urp:     `ORDER BY (resource_id, user_id, relation)`,
+		urpProj: urpByUserProjection,
+	},
+}
+
+func (v sortKeyVariant) aclTable() string { return "resource_acl_sk_" + v.name }
+func (v sortKeyVariant) urpTable() string { return "user_resource_permissions_sk_" + v.name }
+
+// createSQL returns the CREATE TABLE statement of the variant's copy of
+// resource_acl (acl) or user_resource_permissions.
+func (v sortKeyVariant) createSQL(acl bool) string {
+	table, cols, proj, settings := v.urpTable(), `resource_id UInt32,
+		user_id UInt32,
+		relation Enum8('viewer' = 1, 'manager' = 2)`, v.urpProj, v.urp
+	if acl {
+		table, cols, proj, settings = v.aclTable(), `resource_id UInt32,
+		org_id UInt32,
+		subject_type Enum8('user' = 1, 'group' = 2),
+		subject_id UInt32,
+		relation Enum8('viewer' = 1, 'manager' = 2),
+		valid_from Nullable(DateTime),
+		valid_until Nullable(DateTime),
+		created_at Nullable(DateTime)`, v.aclProj, v.acl
+	}
+	if proj != "" {
+		cols += ",\n\t\t" + proj
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n\t\t%s\n\t) ENGINE = MergeTree %s", table, cols, settings)
+}
+
+// sortKeyQuery is one of the queries timed on every variant; the %s is the
+// variant's table. check queries take a (resource, user) pair, lookup
+// queries a user and count the rows they stream.
+type sortKeyQuery struct {
+	name  string
+	acl   bool
+	check bool
+	sql   string
+}
+
+// sortKeyQueries are the shapes of the benchmark's queries: the
+// chauthz.CheckSQL check and chauthz.LookupResources lookup on
+// user_resource_permissions, and the direct-grant check and list of the
+// check_* scenarios on resource_acl.
+var sortKeyQueries = []sortKeyQuery{
+	{name: "check_urp", check: true, sql: `SELECT 1 FROM %s WHERE resource_id = ? AND user_id = ? AND relation = 'manager' LIMIT 1`},
+	{name: "lookup_urp", sql: `SELECT DISTINCT resource_id FROM %s WHERE user_id = ? AND relation = 'manager'`},
+	{name: "check_acl", acl: true, check: true, sql: `SELECT 1 FROM %s WHERE resource_id = ? AND subject_type = 'user' AND subject_id = ? AND relation = 'manager' LIMIT 1`},
+	{name: "lookup_acl", acl: true, sql: `SELECT resource_id FROM %s WHERE subject_type = 'user' AND subject_id = ? AND relation = 'manager'`},
+}
+
+// ClickhouseSortKeys benchmarks the same check and lookup queries against
+// copies of resource_acl and user_resource_permissions with different sort
+// keys: resource-first, user-first and resource-first with a user-first
+// projection, next to a copy of the current layout. Each copy is built from
+// the loaded tables with INSERT ... SELECT and merged with OPTIMIZE FINAL.
+// Per variant and query it logs a "[sort_keys] ... DONE:" line with the
+// latency percentiles and the rows returned; differing row counts are logged
+// as a mismatch. The copies are dropped afterwards unless CH_SORTKEY_KEEP is
+// true (`clickhouse drop` removes kept copies).
+//
+// Check pairs are direct manager grants of resource_acl, in a fixed
+// pseudo-random order; the lookup user is BENCH_LOOKUPRES_MANAGE_USER, or
+// the user of the first pair.
+//
+// Env vars:
+//
+//	CH_SORTKEY_VARIANTS      (default: all, comma-separated)
+//	CH_SORTKEY_CHECK_ITER    (default: 1000)
+//	CH_SORTKEY_LOOKUP_ITER   (default: 10)
+//	CH_SORTKEY_KEEP          (default: false)
+func ClickhouseSortKeys() {
+	ctx := context.Background()
+	db, cleanup, err := infrastructure.NewClickhouseFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[clickhouse] sort_keys: connect failed: %v", err)
+	}
+	defer cleanup()
+
+	variants := selectSortKeyVariants(utils.GetEnvWithDefault("CH_SORTKEY_VARIANTS", ""))
+	checkIters := utils.GetEnvInt("CH_SORTKEY_CHECK_ITER", 1000)
+	lookupIters := utils.GetEnvInt("CH_SORTKEY_LOOKUP_ITER", 10)
+	keep := utils.GetEnvWithDefault("CH_SORTKEY_KEEP", "false") == "true"
+
+	pairs := sortKeyPairs(ctx, db, checkIters)
+	if len(pairs) == 0 {
+		log.Fatalf("[clickhouse] sort_keys: no direct manager grants in resource_acl; run load-data first")
+	}
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
+	if lookupUser == "" {
+		lookupUser = fmt.Sprint(pairs[0][1])
+	}
+	log.Printf("[clickhouse] [sort_keys] variants=%d pairs=%d check_iters=%d lookup_iters=%d lookup_user=%s",
+		len(variants), len(pairs), checkIters, lookupIters, lookupUser)
+
+	rows := map[string]int{} // query -> rows of the first variant
+	for _, v := range variants {
+		buildSortKeyVariant(ctx, db, v)
+		for _, q := range sortKeyQueries {
+			table := v.urpTable()
+			if q.acl {
+				table = v.aclTable()
+			}
+			query := fmt.Sprintf(q.sql, table)
+			var n int
+			if q.check {
+				n = runSortKeyChecks(db, v.name, q.name, query, pairs, checkIters)
+			} else {
+				n = runSortKeyLookups(db, v.name, q.name, query, lookupUser, lookupIters)
+			}
+			if first, ok := rows[q.name]; !ok {
+				rows[q.name] = n
+			} else if n != first {
+				log.Printf("[clickhouse] [sort_keys] variant=%s query=%s MISMATCH: rows=%d, first variant returned %d", v.name, q.name, n, first)
+			}
+		}
+		if !keep {
+			dropSortKeyVariant(ctx, db, v)
+		}
+	}
+	log.Println("[clickhouse] == ClickHouse sort key experiments DONE ==")
+}
+
+// selectSortKeyVariants returns the variants named in spec, or all of them
+// for an empty spec.
+func selectSortKeyVariants(spec string) []sortKeyVariant {
+	if spec == "" {
+		return sortKeyVariants
+	}
+	var out []sortKeyVariant
+	for name := range strings.SplitSeq(spec, ",") {
+		name = strings.TrimSpace(name)
+		i := slices.IndexFunc(sortKeyVariants, func(v sortKeyVariant) bool { return v.name == name })
+		if i < 0 {
+			log.Fatalf("[clickhouse] sort_keys: unknown variant %q in CH_SORTKEY_VARIANTS", name)
+		}
+		out = append(out, sortKeyVariants[i])
+	}
+	return out
+}
+
+// sortKeyPairs returns up to n (resource, user) pairs of direct manager
+// grants, ordered by a hash so every run samples the same pairs.
+func sortKeyPairs(ctx context.Context, db *sql.DB, n int) [][2]uint32 {
+	var pairs [][2]uint32
+	query := `
+	SELECT resource_id, subject_id
+	FROM resource_acl
+	WHERE subject_type = 'user' AND relation = 'manager'
+	ORDER BY cityHash64(resource_id, subject_id)
+	LIMIT ?
+	`
+	qctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	err := streamQuery(qctx, db, query, []any{n}, func(rows *sql.Rows) error {
+		var p [2]uint32
+		if err := rows.Scan(&p[0], &p[1]); err != nil {
+			return err
+		}
+		pairs = append(pairs, p)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("[clickhouse] sort_keys: sample check pairs: %v", err)
+	}
+	return pairs
+}
+
+// buildSortKeyVariant (re)creates the variant's copies from the loaded tables
+// and merges them to one part per partition.
+func buildSortKeyVariant(ctx context.Context, db *sql.DB, v sortKeyVariant) {
+	start := time.Now()
+	dropSortKeyVariant(ctx, db, v)
+	stmts := []string{
+		v.createSQL(true),
+		v.createSQL(false),
+		fmt.Sprintf("INSERT INTO %s SELECT * FROM resource_acl", v.aclTable()),
+		fmt.Sprintf("INSERT INTO %s SELECT * FROM user_resource_permissions", v.urpTable()),
+		fmt.Sprintf("OPTIMIZE TABLE %s FINAL", v.aclTable()),
+		fmt.Sprintf("OPTIMIZE TABLE %s FINAL", v.urpTable()),
+	}
+	if err := recordCreated(ctx, db, func() error {
+		for _, stmt := range stmts {
+			if err := execTimeout(ctx, db, stmt, 30*time.Minute); err != nil {
+				return fmt.Errorf("executing %q: %w", stmt, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		log.Fatalf("[clickhouse] sort_keys: variant %s: %v", v.name, err)
+	}
+	log.Printf("[clickhouse] [sort_keys] variant=%s built in %s: %s=%s %s=%s", v.name, time.Since(start).Truncate(time.Millisecond),
+		v.aclTable(), tableSize(ctx, db, v.aclTable()), v.urpTable(), tableSize(ctx, db, v.urpTable()))
+}
+
+func dropSortKeyVariant(ctx context.Context, db *sql.DB, v sortKeyVariant) {
+	for _, table := range []string{v.aclTable(), v.urpTable()} {
+		if err := execTimeout(ctx, db, "DROP TABLE IF EXISTS "+table, 60*time.Second); err != nil {
+			log.Printf("[clickhouse] sort_keys: warning: drop %s failed: %v", table, err)
+		}
+	}
+}
+
+// tableSize returns the on-disk size of table's active parts, projections
+// included, or "?" if system.parts cannot be read.
+func tableSize(ctx context.Context, db *sql.DB, table string) string {
+	var size string
+	err := db.QueryRowContext(ctx, `
+		SELECT formatReadableSize(sum(bytes_on_disk))
+		FROM system.parts
+		WHERE active AND database = currentDatabase() AND table = ?`, table).Scan(&size)
+	if err != nil {
+		return "?"
+	}
+	return size
+}
+
+// runSortKeyChecks times query over pairs round robin and returns how many
+// checks found a row.
+func runSortKeyChecks(db *sql.DB, variant, name, query string, pairs [][2]uint32, iters int) int {
+	durations := make([]time.Duration, 0, iters)
+	found := 0
+	errs := utils.NewErrorTally()
+	for i := range iters {
+		p := pairs[i%len(pairs)]
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		start := time.Now()
+		var exists int
+		err := db.QueryRowContext(ctx, query, p[0], p[1]).Scan(&exists)
+		dur := time.Since(start)
+		cancel()
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		} else if err == nil {
+			found++
+		}
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[clickhouse] [sort_keys] variant=%s query=%s iter=%d failed class=%s: %v", variant, name, i, class, err)
+			continue
+		}
+		durations = append(durations, dur)
+	}
+	logSortKeyDone(variant, name, durations, found, errs, iters)
+	return found
+}
+
+// runSortKeyLookups times query for userID and returns the rows of the last
+// successful iteration.
+func runSortKeyLookups(db *sql.DB, variant, name, query, userID string, iters int) int {
+	durations := make([]time.Duration, 0, iters)
+	count := 0
+	errs := utils.NewErrorTally()
+	for i := range iters {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		start := time.Now()
+		n := 0
+		err := streamQuery(ctx, db, query, []any{userID}, func(*sql.Rows) error {
+			n++
+			return nil
+		})
+		dur := time.Since(start)
+		cancel()
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[clickhouse] [sort_keys] variant=%s query=%s iter=%d failed class=%s: %v", variant, name, i, class, err)
+			continue
+		}
+		count = n
+		durations = append(durations, dur)
+	}
+	logSortKeyDone(variant, name, durations, count, errs, iters)
+	return count
+}
+
+func logSortKeyDone(variant, name string, durations []time.Duration, rows int, errs *utils.ErrorTally, iters int) {
+	log.Printf("[clickhouse] [sort_keys] variant=%s query=%s DONE: iters=%d rows=%d %s", variant, name, len(durations), rows, utils.LatencySummary(durations))
+	log.Printf("[clickhouse] [sort_keys] variant=%s query=%s ERRORS: %s", variant, name, errs.Summary(iters))
+}
@@ -0,0 +1,99 @@
+package cockroachdb
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// CockroachdbAnalyzeStats reports row counts, per-relation ACL cardinality and the
+// same relation fan-out summaries printed by `csv generate`, computed from the
+// loaded tables so drift between the CSV dataset and CockroachDB is visible.
+func CockroachdbAnalyzeStats() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	db, cleanup, err := infrastructure.NewCockroachDBFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[cockroachdb] failed to create database connection: %v", err)
+	}
+	defer cleanup()
+
+	start := time.Now()
+
+	tables := []string{"organizations", "users", "groups", "org_memberships", "group_memberships", "group_hierarchy", "resources", "resource_acl", "resource_bans"}
+	for _, table := range tables {
+		var n int64
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table).Scan(&n); err != nil {
+			log.Fatalf("[cockroachdb] stats: count %s failed: %v", table, err)
+		}
+		log.Printf("[cockroachdb] table %s: %d rows", table, n)
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT subject_type, relation, COUNT(*), COUNT(DISTINCT subject_id), COUNT(DISTINCT resource_id)
+		FROM resource_acl
+		GROUP BY subject_type, relation
+		ORDER BY subject_type, relation`)
+	if err != nil {
+		log.Fatalf("[cockroachdb] stats: resource_acl cardinality failed: %v", err)
+	}
+	for rows.Next() {
+		var subjectType, relation string
+		var edges, subjects, resources int64
+		if err := rows.Scan(&subjectType, &relation, &edges, &subjects, &resources); err != nil {
+			log.Fatalf("[cockroachdb] stats: scan resource_acl cardinality failed: %v", err)
+		}
+		log.Printf("[cockroachdb] acl %s/%s: %d edges, %d distinct subjects, %d distinct resources",
+			subjectType, relation, edges, subjects, resources)
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatalf("[cockroachdb] stats: resource_acl cardinality failed: %v", err)
+	}
+	rows.Close()
+
+	stats := utils.NewRelationStats()
+	fanouts := []struct {
+		dst   map[int]int
+		query string
+	}{
+		{stats.OrgToUsers, `SELECT org_id, COUNT(*) FROM org_memberships GROUP BY org_id`},
+		{stats.UserToOrgs, `SELECT user_id, COUNT(*) FROM org_memberships GROUP BY user_id`},
+		{stats.GroupToMembers, `SELECT group_id, COUNT(*) FROM group_memberships WHERE role NOT IN ('direct_manager', 'manager') GROUP BY group_id`},
+		{stats.GroupToManagers, `SELECT group_id, COUNT(*) FROM group_memberships WHERE role IN ('direct_manager', 'manager') GROUP BY group_id`},
+		{stats.UserToGroups, `SELECT user_id, COUNT(*) FROM group_memberships GROUP BY user_id`},
+		{stats.GroupToChildGroups, `SELECT parent_group_id, COUNT(*) FROM group_hierarchy GROUP BY parent_group_id`},
+		{stats.UserToResources, `SELECT subject_id, COUNT(*) FROM resource_acl WHERE subject_type = 'user' GROUP BY subject_id`},
+		{stats.ResourceToUsers, `SELECT resource_id, COUNT(*) FROM resource_acl WHERE subject_type = 'user' GROUP BY resource_id`},
+	}
+	for _, f := range fanouts {
+		if err := scanCounts(ctx, db, f.query, f.dst); err != nil {
+			log.Fatalf("[cockroachdb] stats: %q failed: %v", f.query, err)
+		}
+	}
+	stats.Summarize("cockroachdb")
+
+	log.Printf("[cockroachdb] analyze stats DONE elapsed=%s", time.Since(start).Truncate(time.Millisecond))
+}
+
+// scanCounts reads (id, count) rows produced by query into dst.
+func scanCounts(ctx context.Context, db *sql.DB, query string, dst map[int]int) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return err
+		}
+		dst[id] = n
+	}
+	return rows.Err()
+}
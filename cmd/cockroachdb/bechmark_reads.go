@@ -3,10 +3,14 @@ package cockroachdb
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log"
-	"os"
 	"time"
 
+	"github.com/lib/pq"
+
+	crdbauthz "test-tls/authz/cockroachdb"
+	"test-tls/ids"
 	"test-tls/infrastructure"
 	"test-tls/utils"
 )
@@ -28,25 +32,74 @@ func CockroachdbBenchmarkReads() {
 		log.Fatalf("[cockroachdb] failed to create database connection: %v", err)
 	}
 	defer cleanup()
+	utils.GuardDataset("cockroachdb", datasetStore{db})
+	stopAudit := utils.StartAudit("cockroachdb")
+	defer stopAudit()
 
 	// Log startup summary including any env-overridden lookup users.
 	start := time.Now()
-	heavyManageUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
-	regularViewUser := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	heavyManageUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
+	regularViewUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 	log.Printf("[cockroachdb] Running in streaming-only mode (no precollection). elapsed=%s heavyManageUser=%q regularViewUser=%q",
 		elapsed, heavyManageUser, regularViewUser)
 
+	log.Printf("[cockroachdb] SCHEMA: groups=%s", groupResolutionFromEnv())
+
+	snapshotStats := startStatementStats(db)                  // Reset server-side statement stats (BENCH_STATEMENT_STATS)
+	stopCost := utils.StartCost("cockroachdb", costMeter(db)) // Per-scenario statement statistics (BENCH_COST)
+
 	// Run individual benchmark scenarios
-	runCheckManageDirectUser(db)          // Test direct manager_user relationships in resource_acl
-	runCheckManageOrgAdmin(db)            // Test org->admin permission paths
-	runCheckViewViaGroupMember(db)        // Test permissions via viewer_group and group membership
-	runLookupResourcesManageHeavyUser(db) // Test resource lookup for users with many manage permissions
-	runLookupResourcesViewRegularUser(db) // Test resource lookup for users with regular view permissions
+	reads := func() {
+		runCheckManageDirectUser(db)          // Test direct manager_user relationships in resource_acl
+		runCheckManageOrgAdmin(db)            // Test org->admin permission paths
+		runCheckViewViaGroupMember(db)        // Test permissions via viewer_group and group membership
+		runCheckTimeBoundedDirectUser(db)     // Test direct grants filtered by valid_from/valid_until
+		runCheckBulkManageDirectUser(db)      // Test batched checks of many pairs in one VALUES-join query
+		runLookupResourcesManageHeavyUser(db) // Test resource lookup for users with many manage permissions
+		runLookupResourcesViewRegularUser(db) // Test resource lookup for users with regular view permissions
+		runLookupResourcesMix(db)             // Test resource lookup for a stratified mix of users (BENCH_LOOKUPRES_MIX_USERS)
+		runLookupResourcesPercentiles(db)     // Test resource lookup for the p10/p50/p90/p99 users by grant count (BENCH_LOOKUPRES_*_PCT_USERS)
+		runViralFanIn(db)                     // Test checks and lookups on resources shared with many users directly (BENCH_VIRAL_RESOURCES)
+		runWorstCaseChecks(db)                // Test view checks whose only grant is the deepest nested group path
+		runListRecentViewable(db)             // Test the authorized listing of the newest viewable resources (BENCH_LIST_RECENT_*)
+		runCustomScenarios(db)                // Run user-defined scenarios from BENCH_CUSTOM_SCENARIOS
+		runWorkload(db)                       // Run the blended request mix of BENCH_WORKLOAD
+		runMyGroups(db)                       // List the groups of sampled users (my-groups query)
+		runAccessSummary(db)                  // Count the resources sampled users can view and manage (dashboard summary)
+		runMiddlewareOverhead(db)             // Fetch a resource with and without a view check in front (authorization middleware)
+		runDerivedPermissions(db)             // Check and look up manage, view and the permissions of BENCH_PERMISSION_RULES
+		runDenials(db)                        // Check and look up view with and without the banned_user denials of BENCH_DENIALS
+	}
+	utils.RunMaintenancePhases("cockroachdb", "backup", backupDatabase(db), func() {
+		utils.RunCachePhases("cockroachdb", nil, reads)
+	})
+	runFullExport(db)     // Stream every resource_acl row (BENCH_EXPORT_ITER)
+	stopCost()            // Log the COST line of the last scenario
+	snapshotStats()       // Log server-side statement stats for the scenarios above
+	runDriverOverhead(db) // Compare database/sql against the native driver (BENCH_DRIVER_COMPARE)
 
 	log.Println("[cockroachdb] == CockroachDB read benchmarks DONE ==")
 }
 
+// backupDatabase takes a full backup of the benchmarked database, the
+// maintenance of BENCH_MAINTENANCE: a backup reads every range of it, as the
+// scheduled backups of a production cluster do. Each round writes a new backup
+// to CRDB_MAINTENANCE_BACKUP_URI (default nodelocal://1/rlp-maintenance), so
+// clear it after the run. The connecting user needs the BACKUP privilege.
+func backupDatabase(db *sql.DB) utils.Maintenance {
+	uri := utils.GetEnvWithDefault("CRDB_MAINTENANCE_BACKUP_URI", "nodelocal://1/rlp-maintenance")
+	return func(ctx context.Context) error {
+		var name string
+		if err := db.QueryRowContext(ctx, "SELECT current_database()").Scan(&name); err != nil {
+			return err
+		}
+		stmt := fmt.Sprintf("BACKUP DATABASE %s INTO %s", pq.QuoteIdentifier(name), pq.QuoteLiteral(uri))
+		_, err := db.ExecContext(ctx, stmt)
+		return err
+	}
+}
+
 // streamQuery streams rows from a SQL query and invokes handle for each row.
 // This helper avoids collecting results into memory, making it suitable for
 // processing large datasets without memory overhead.
@@ -83,39 +136,41 @@ func runLookupBench(db *sql.DB, name, permission, userID string, iters int, time
 	}
 
 	log.Printf("[cockroachdb] [%s] iterations=%d user=%s", name, iters, userID)
+	utils.LogScenarioConfig("cockroachdb", name, iters, timeout, userID)
 
 	var total time.Duration
-	var lastCount int
+	var lastCount, ok int
+	errs := utils.NewErrorTally()
 
 	for i := range iters {
 		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		start := time.Now()
 
-		// Query resources where the user has the specified permission
-		query := `SELECT resource_id FROM resource_acl
-			WHERE subject_type = 'user' AND subject_id = $1 AND relation = $2
-			ORDER BY resource_id`
-
 		count := 0
-		err := streamQuery(ctx, db, query, []interface{}{userID, permission}, func(rows *sql.Rows) error {
-			count++
-			return nil
-		})
+		err := lookupResourcesCRDB(ctx, db, userID, permission, func(int) { count++ })
 		cancel()
+		utils.AuditLookup(name, userID, permission, count, time.Since(start), err)
 		if err != nil {
-			log.Fatalf("[cockroachdb] [%s] query failed: %v", name, err)
+			class := errs.Record(err)
+			log.Printf("[cockroachdb] [%s] iter=%d LookupResources failed class=%s: %v", name, i, class, err)
+			continue
 		}
 
 		dur := time.Since(start)
 		total += dur
 		lastCount = count
+		ok++
 
 		log.Printf("[cockroachdb] [%s] iter=%d resources=%d duration=%s", name, i, count, dur.Truncate(time.Millisecond))
 	}
 
-	avg := time.Duration(int64(total) / int64(iters))
+	avg := time.Duration(0)
+	if ok > 0 {
+		avg = time.Duration(int64(total) / int64(ok))
+	}
 	log.Printf("[cockroachdb] [%s] DONE: iters=%d lastCount=%d avg=%s total=%s",
 		name, iters, lastCount, avg, total)
+	log.Printf("[cockroachdb] [%s] ERRORS: %s", name, errs.Summary(iters))
 }
 
 // runCheckManageDirectUser benchmarks CheckPermission calls for "manage" permission
@@ -126,9 +181,11 @@ func runCheckManageDirectUser(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_CHECK_DIRECT_SUPER_ITER", 1000)
 
 	log.Printf("[cockroachdb] [check_manage_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("cockroachdb", "check_manage_direct_user", iters, 2*time.Second)
 	done := 0
+	errs := utils.NewErrorTally()
 	// Hybrid behavior: if BENCH_LOOKUPRES_MANAGE_USER is set, prefer lookup for that user
-	lookupUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 
 	for done < iters {
@@ -136,7 +193,7 @@ func runCheckManageDirectUser(db *sql.DB) {
 			// Query resources where the user has manager_user permission
 			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 			query := `SELECT resource_id FROM resource_acl
-				WHERE subject_type = 'user' AND subject_id = $1 AND relation = 'manager_user'
+				WHERE subject_type = 'user' AND subject_id = $1 AND relation = 'manager_user'` + crdbauthz.ActiveGrant("") + `
 				ORDER BY resource_id`
 
 			streamed := 0
@@ -150,20 +207,24 @@ func runCheckManageDirectUser(db *sql.DB) {
 				}
 				streamed++
 
+				if !utils.InOrgScope(ids.Resource, resID) {
+					return nil
+				}
+
 				// Check permission existence for returned resource
 				cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 				start := time.Now()
-				var exists int
-				checkQuery := `SELECT COUNT(1) FROM resource_acl
-					WHERE resource_id = $1 AND subject_type = 'user' AND subject_id = $2
-					AND relation = 'manager_user'`
-				err := db.QueryRowContext(cctx, checkQuery, resID, lookupUser).Scan(&exists)
+				granted, err := checkPermissionCRDB(cctx, db, resID, lookupUser, "manager_user")
 				ccancel()
+				utils.AuditCheck("check_manage_direct_user", resID, lookupUser, "manager_user", granted, time.Since(start), err)
 				if err != nil {
-					log.Fatalf("[cockroachdb] [check_manage_direct_user] permission check failed: %v", err)
+					class := errs.Record(err)
+					log.Printf("[cockroachdb] [check_manage_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+					done++
+					return nil
 				}
 				dur := time.Since(start)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[cockroachdb] [check_manage_direct_user] lookup iter=%d resource=%d user=%s dur=%s", done, resID, lookupUser, dur)
 				}
 				done++
@@ -171,7 +232,8 @@ func runCheckManageDirectUser(db *sql.DB) {
 			})
 			cancel()
 			if err != nil {
-				log.Fatalf("[cockroachdb] [check_manage_direct_user] query failed: %v", err)
+				errs.Survive(err, iters, "[cockroachdb] [check_manage_direct_user] query failed: %v", err)
+				continue
 			}
 			if streamed == 0 {
 				log.Printf("[cockroachdb] [check_manage_direct_user] lookup-mode: no resources returned for user=%s", lookupUser)
@@ -195,86 +257,92 @@ func runCheckManageDirectUser(db *sql.DB) {
 				return err
 			}
 
+			if !utils.InOrgScope(ids.Resource, resID) {
+				return nil
+			}
+
 			cctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			start := time.Now()
-			var exists int
-			checkQuery := `SELECT COUNT(1) FROM resource_acl
-				WHERE resource_id = $1 AND subject_type = 'user' AND subject_id = $2
-				AND relation = 'manager_user'`
-			queryErr := db.QueryRowContext(cctx, checkQuery, resID, userID).Scan(&exists)
+			granted, queryErr := checkPermissionCRDB(cctx, db, resID, userID, "manager_user")
 			cancel()
+			utils.AuditCheck("check_manage_direct_user", resID, userID, "manager_user", granted, time.Since(start), queryErr)
 			if queryErr != nil {
-				log.Fatalf("[cockroachdb] [check_manage_direct_user] permission check failed: %v", queryErr)
+				class := errs.Record(queryErr)
+				log.Printf("[cockroachdb] [check_manage_direct_user] iter=%d check failed class=%s: %v", done, class, queryErr)
+				done++
+				return nil
 			}
 			dur := time.Since(start)
 			// Log every 100th iteration to avoid excessive output
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[cockroachdb] [check_manage_direct_user] iter=%d resource=%d user=%d dur=%s", done, resID, userID, dur)
 			}
 			done++
 			return nil
 		})
 		if err != nil {
-			log.Fatalf("[cockroachdb] [check_manage_direct_user] streaming failed: %v", err)
+			errs.Survive(err, iters, "[cockroachdb] [check_manage_direct_user] streaming failed: %v", err)
 		}
 	}
 	log.Printf("[cockroachdb] [check_manage_direct_user] DONE: iters=%d", iters)
+	log.Printf("[cockroachdb] [check_manage_direct_user] ERRORS: %s", errs.Summary(done))
 }
 
 // runCheckManageOrgAdmin benchmarks CheckPermission calls for "manage" permission
-// where access is granted through organizational admin relationships (org->admin path).
-// This tests permission inheritance through organizational hierarchies.
+// on (resource, admin of its org) pairs, as the Postgres benchmark does: the
+// pairs come from resources joined with the org's admins in org_memberships,
+// and each is checked through CRDB_GROUP_RESOLUTION, whose org path grants
+// manage to org admins as org->admin does in SpiceDB. In lookup mode the
+// resources are the ones BENCH_LOOKUPRES_MANAGE_USER manages.
 // The number of iterations is controlled by BENCH_CHECK_ORGADMIN_ITER env variable.
 func runCheckManageOrgAdmin(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_CHECK_ORGADMIN_ITER", 1000)
 
 	log.Printf("[cockroachdb] [check_manage_org_admin] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("cockroachdb", "check_manage_org_admin", iters, 2*time.Second)
 	done := 0
+	errs := utils.NewErrorTally()
 	// Hybrid behavior: if BENCH_LOOKUPRES_MANAGE_USER is set, prefer lookup for that user
-	lookupUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 
 	for done < iters {
 		if lookupUser != "" {
-			// Query resources where the user has manager_user permission
+			// Resources the user manages, resolved like the check itself
 			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-			query := `SELECT resource_id FROM resource_acl
-				WHERE subject_type = 'user' AND subject_id = $1 AND relation = 'manager_user'
-				ORDER BY resource_id`
-
 			streamed := 0
-			err := streamQuery(ctx, db, query, []interface{}{lookupUser}, func(rows *sql.Rows) error {
+			err := lookupResourcesCRDB(ctx, db, lookupUser, "manager_user", func(resID int) {
 				if done >= iters || streamed >= sampleLimit {
-					return nil
-				}
-				var resID int
-				if err := rows.Scan(&resID); err != nil {
-					return err
+					return
 				}
 				streamed++
 
+				if !utils.InOrgScope(ids.Resource, resID) {
+					return
+				}
+
 				// Check permission existence
 				cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 				start := time.Now()
-				var exists int
-				checkQuery := `SELECT COUNT(1) FROM resource_acl
-					WHERE resource_id = $1 AND subject_type = 'user' AND subject_id = $2
-					AND relation = 'manager_user'`
-				err := db.QueryRowContext(cctx, checkQuery, resID, lookupUser).Scan(&exists)
+				granted, err := checkPermissionCRDB(cctx, db, resID, lookupUser, "manager_user")
 				ccancel()
+				utils.AuditCheck("check_manage_org_admin", resID, lookupUser, "manager_user", granted, time.Since(start), err)
 				if err != nil {
-					log.Fatalf("[cockroachdb] [check_manage_org_admin] permission check failed: %v", err)
+					class := errs.Record(err)
+					log.Printf("[cockroachdb] [check_manage_org_admin] iter=%d check failed class=%s: %v", done, class, err)
+					done++
+					return
 				}
 				dur := time.Since(start)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[cockroachdb] [check_manage_org_admin] lookup iter=%d resource=%d user=%s dur=%s", done, resID, lookupUser, dur)
 				}
 				done++
-				return nil
 			})
 			cancel()
 			if err != nil {
-				log.Fatalf("[cockroachdb] [check_manage_org_admin] query failed: %v", err)
+				errs.Survive(err, iters, "[cockroachdb] [check_manage_org_admin] query failed: %v", err)
+				continue
 			}
 			if streamed == 0 {
 				log.Printf("[cockroachdb] [check_manage_org_admin] lookup-mode: no resources returned for user=%s", lookupUser)
@@ -283,100 +351,114 @@ func runCheckManageOrgAdmin(db *sql.DB) {
 			continue
 		}
 
-		// Stream all resource.org relationships and check admin permissions
+		// Stream every resource with one admin of its org
 		ctx := context.Background()
-		query := `SELECT resource_id, subject_id FROM resource_acl
-			WHERE subject_type = 'user' AND relation = 'manager_user'
-			ORDER BY resource_id`
+		query := `SELECT DISTINCT ON (r.resource_id) r.resource_id, r.org_id, om.user_id
+			FROM resources r
+			JOIN org_memberships om ON om.org_id = r.org_id AND om.role = 'admin'
+			ORDER BY r.resource_id, om.user_id`
 
+		streamed := 0
 		err := streamQuery(ctx, db, query, nil, func(rows *sql.Rows) error {
 			if done >= iters {
 				return nil
 			}
-			var resID, userID int
-			if err := rows.Scan(&resID, &userID); err != nil {
+			var resID, orgID, adminUser int
+			if err := rows.Scan(&resID, &orgID, &adminUser); err != nil {
 				return err
 			}
+			streamed++
+
+			if !utils.InOrgScope(ids.Resource, resID) {
+				return nil
+			}
 
 			cctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			start := time.Now()
-			var exists int
-			checkQuery := `SELECT COUNT(1) FROM resource_acl
-				WHERE resource_id = $1 AND subject_type = 'user' AND subject_id = $2
-				AND relation = 'manager_user'`
-			queryErr := db.QueryRowContext(cctx, checkQuery, resID, userID).Scan(&exists)
+			granted, queryErr := checkPermissionCRDB(cctx, db, resID, adminUser, "manager_user")
 			cancel()
+			utils.AuditCheck("check_manage_org_admin", resID, adminUser, "manager_user", granted, time.Since(start), queryErr)
 			if queryErr != nil {
-				log.Fatalf("[cockroachdb] [check_manage_org_admin] permission check failed: %v", queryErr)
+				class := errs.Record(queryErr)
+				log.Printf("[cockroachdb] [check_manage_org_admin] iter=%d check failed class=%s: %v", done, class, queryErr)
+				done++
+				return nil
 			}
 			dur := time.Since(start)
-			if done%100 == 0 {
-				log.Printf("[cockroachdb] [check_manage_org_admin] iter=%d resource=%d user=%d dur=%s", done, resID, userID, dur)
+			if utils.LogIteration(done, 100) {
+				log.Printf("[cockroachdb] [check_manage_org_admin] iter=%d resource=%d org=%d admin=%d dur=%s", done, resID, orgID, adminUser, dur)
 			}
 			done++
 			return nil
 		})
 		if err != nil {
-			log.Fatalf("[cockroachdb] [check_manage_org_admin] streaming failed: %v", err)
+			errs.Survive(err, iters, "[cockroachdb] [check_manage_org_admin] streaming failed: %v", err)
+			continue
+		}
+		if streamed == 0 {
+			log.Printf("[cockroachdb] [check_manage_org_admin] no org admins found")
+			break
 		}
 	}
 	log.Printf("[cockroachdb] [check_manage_org_admin] DONE: iters=%d", iters)
+	log.Printf("[cockroachdb] [check_manage_org_admin] ERRORS: %s", errs.Summary(done))
 }
 
 // runCheckViewViaGroupMember benchmarks CheckPermission calls for "view" permission
-// where access is granted through user group membership (viewer_group + group membership path).
-// This tests permission inheritance through group hierarchies without transitive expansion.
+// where access is granted through user group membership (viewer_group + group membership path),
+// as the Postgres benchmark does: the pairs come from viewer_group grants joined
+// with one member of the group (a direct_member, else a direct_manager), and
+// each is checked through CRDB_GROUP_RESOLUTION. In lookup mode the resources
+// are the ones BENCH_LOOKUPRES_VIEW_USER can view.
 // The number of iterations is controlled by BENCH_CHECK_VIEW_GROUP_ITER env variable.
 func runCheckViewViaGroupMember(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_CHECK_VIEW_GROUP_ITER", 1000)
 
 	log.Printf("[cockroachdb] [check_view_via_group_member] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("cockroachdb", "check_view_via_group_member", iters, 2*time.Second)
 	done := 0
+	errs := utils.NewErrorTally()
 	// Hybrid behavior: if BENCH_LOOKUPRES_VIEW_USER is set, prefer lookup for that user
-	lookupUser := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 
 	for done < iters {
 		if lookupUser != "" {
-			// Query resources where the user has viewer_user permission
+			// Resources the user can view, resolved like the check itself
 			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-			query := `SELECT resource_id FROM resource_acl
-				WHERE subject_type = 'user' AND subject_id = $1 AND relation = 'viewer_user'
-				ORDER BY resource_id`
-
 			streamed := 0
-			err := streamQuery(ctx, db, query, []interface{}{lookupUser}, func(rows *sql.Rows) error {
+			err := lookupResourcesCRDB(ctx, db, lookupUser, "viewer_user", func(resID int) {
 				if done >= iters || streamed >= sampleLimit {
-					return nil
-				}
-				var resID int
-				if err := rows.Scan(&resID); err != nil {
-					return err
+					return
 				}
 				streamed++
 
+				if !utils.InOrgScope(ids.Resource, resID) {
+					return
+				}
+
 				// Check permission existence
 				cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 				start := time.Now()
-				var exists int
-				checkQuery := `SELECT COUNT(1) FROM resource_acl
-					WHERE resource_id = $1 AND subject_type = 'user' AND subject_id = $2
-					AND relation = 'viewer_user'`
-				err := db.QueryRowContext(cctx, checkQuery, resID, lookupUser).Scan(&exists)
+				granted, err := checkPermissionCRDB(cctx, db, resID, lookupUser, "viewer_user")
 				ccancel()
+				utils.AuditCheck("check_view_via_group_member", resID, lookupUser, "viewer_user", granted, time.Since(start), err)
 				if err != nil {
-					log.Fatalf("[cockroachdb] [check_view_via_group_member] permission check failed: %v", err)
+					class := errs.Record(err)
+					log.Printf("[cockroachdb] [check_view_via_group_member] iter=%d check failed class=%s: %v", done, class, err)
+					done++
+					return
 				}
 				dur := time.Since(start)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[cockroachdb] [check_view_via_group_member] lookup iter=%d resource=%d user=%s dur=%s", done, resID, lookupUser, dur)
 				}
 				done++
-				return nil
 			})
 			cancel()
 			if err != nil {
-				log.Fatalf("[cockroachdb] [check_view_via_group_member] query failed: %v", err)
+				errs.Survive(err, iters, "[cockroachdb] [check_view_via_group_member] query failed: %v", err)
+				continue
 			}
 			if streamed == 0 {
 				log.Printf("[cockroachdb] [check_view_via_group_member] lookup-mode: no resources returned for user=%s", lookupUser)
@@ -385,58 +467,141 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 			continue
 		}
 
-		// Stream resource.viewer_group relations and check permissions for group members
+		// Stream viewer_group grants with one member of the group;
+		// 'direct_member' sorts after 'direct_manager', so DESC prefers members.
 		ctx := context.Background()
-		query := `SELECT resource_id, subject_id FROM resource_acl
-			WHERE subject_type = 'group' AND relation = 'viewer_group'
-			ORDER BY resource_id`
+		query := `SELECT DISTINCT ON (ra.resource_id, ra.subject_id) ra.resource_id, ra.subject_id, gm.user_id
+			FROM resource_acl ra
+			JOIN group_memberships gm ON gm.group_id = ra.subject_id
+			WHERE ra.subject_type = 'group' AND ra.relation IN ('viewer_group', 'viewer')
+			ORDER BY ra.resource_id, ra.subject_id, gm.role DESC, gm.user_id`
 
+		streamed := 0
 		err := streamQuery(ctx, db, query, nil, func(rows *sql.Rows) error {
 			if done >= iters {
 				return nil
 			}
-			var resID, groupID int
-			if err := rows.Scan(&resID, &groupID); err != nil {
+			var resID, groupID, pickedUser int
+			if err := rows.Scan(&resID, &groupID, &pickedUser); err != nil {
 				return err
 			}
+			streamed++
 
-			// Find a direct member of this group
-			var pickedUser int
-			memberQuery := `SELECT user_id FROM group_memberships
-				WHERE group_id = $1
-				LIMIT 1`
-			err := db.QueryRow(memberQuery, groupID).Scan(&pickedUser)
-			if err == sql.ErrNoRows {
-				// No member found, skip
+			if !utils.InOrgScope(ids.Resource, resID) {
 				return nil
 			}
-			if err != nil {
-				log.Fatalf("[cockroachdb] [check_view_via_group_member] find member failed: %v", err)
-			}
 
 			cctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			start := time.Now()
-			var exists int
-			checkQuery := `SELECT COUNT(1) FROM resource_acl
-				WHERE resource_id = $1 AND subject_type = 'user' AND subject_id = $2
-				AND relation = 'viewer_user'`
-			queryErr := db.QueryRowContext(cctx, checkQuery, resID, pickedUser).Scan(&exists)
+			granted, queryErr := checkPermissionCRDB(cctx, db, resID, pickedUser, "viewer_user")
 			cancel()
+			utils.AuditCheck("check_view_via_group_member", resID, pickedUser, "viewer_user", granted, time.Since(start), queryErr)
 			if queryErr != nil {
-				log.Fatalf("[cockroachdb] [check_view_via_group_member] permission check failed: %v", queryErr)
+				class := errs.Record(queryErr)
+				log.Printf("[cockroachdb] [check_view_via_group_member] iter=%d check failed class=%s: %v", done, class, queryErr)
+				done++
+				return nil
 			}
 			dur := time.Since(start)
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[cockroachdb] [check_view_via_group_member] iter=%d resource=%d group=%d user=%d dur=%s", done, resID, groupID, pickedUser, dur)
 			}
 			done++
 			return nil
 		})
 		if err != nil {
-			log.Fatalf("[cockroachdb] [check_view_via_group_member] streaming failed: %v", err)
+			errs.Survive(err, iters, "[cockroachdb] [check_view_via_group_member] streaming failed: %v", err)
+			continue
+		}
+		if streamed == 0 {
+			log.Printf("[cockroachdb] [check_view_via_group_member] no viewer_group grants with members found")
+			break
 		}
 	}
 	log.Printf("[cockroachdb] [check_view_via_group_member] DONE: iters=%d", iters)
+	log.Printf("[cockroachdb] [check_view_via_group_member] ERRORS: %s", errs.Summary(done))
+}
+
+// runCheckTimeBoundedDirectUser streams direct user grants carrying a validity
+// window and checks each against resource_acl at now(), so the expired (soft
+// deleted) share of the sample is denied.
+// Iterations are controlled by BENCH_CHECK_TIME_BOUNDED_ITER env variable (default: 1000).
+func runCheckTimeBoundedDirectUser(db *sql.DB) {
+	iters := utils.GetEnvInt("BENCH_CHECK_TIME_BOUNDED_ITER", 1000)
+
+	log.Printf("[cockroachdb] [check_time_bounded_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("cockroachdb", "check_time_bounded_direct_user", iters, 2*time.Second)
+	done, allowed := 0, 0
+	errs := utils.NewErrorTally()
+
+	for done < iters {
+		query := `SELECT resource_id, subject_id, relation FROM resource_acl
+			WHERE subject_type = 'user' AND valid_until IS NOT NULL
+			ORDER BY resource_id`
+
+		streamed := 0
+		err := streamQuery(context.Background(), db, query, nil, func(rows *sql.Rows) error {
+			if done >= iters {
+				return nil
+			}
+			var resID, userID int
+			var relation string
+			if err := rows.Scan(&resID, &userID, &relation); err != nil {
+				return err
+			}
+			streamed++
+
+			if !utils.InOrgScope(ids.Resource, resID) {
+				return nil
+			}
+
+			cctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			start := time.Now()
+			ok, err := checkTimeBoundedCRDB(cctx, db, resID, userID, relation)
+			cancel()
+			utils.AuditCheck("check_time_bounded_direct_user", resID, userID, relation, ok, time.Since(start), err)
+			if err != nil {
+				class := errs.Record(err)
+				log.Printf("[cockroachdb] [check_time_bounded_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+				done++
+				return nil
+			}
+			dur := time.Since(start)
+			if ok {
+				allowed++
+			}
+			if utils.LogIteration(done, 100) {
+				log.Printf("[cockroachdb] [check_time_bounded_direct_user] iter=%d resource=%d user=%d allowed=%t dur=%s", done, resID, userID, ok, dur)
+			}
+			done++
+			return nil
+		})
+		if err != nil {
+			errs.Survive(err, iters, "[cockroachdb] [check_time_bounded_direct_user] streaming failed: %v", err)
+			continue
+		}
+		if streamed == 0 {
+			log.Printf("[cockroachdb] [check_time_bounded_direct_user] skipped: no time-bounded grants (generate with RLP_EXPIRING_GRANT_FRACTION)")
+			break
+		}
+	}
+	log.Printf("[cockroachdb] [check_time_bounded_direct_user] DONE: iters=%d allowed=%d denied=%d", done, allowed, done-allowed-errs.Total())
+	log.Printf("[cockroachdb] [check_time_bounded_direct_user] ERRORS: %s", errs.Summary(done))
+}
+
+// timeBoundedCheckSQL is the query of checkTimeBoundedCRDB, with $1 the
+// resource, $2 the user and $3 the relation.
+const timeBoundedCheckSQL = `SELECT COUNT(1) FROM resource_acl
+		WHERE resource_id = $1 AND subject_type = 'user' AND subject_id = $2 AND relation = $3
+		AND (valid_from IS NULL OR valid_from <= now())
+		AND (valid_until IS NULL OR valid_until > now())`
+
+// checkTimeBoundedCRDB checks a direct grant in resource_acl, honouring its
+// valid_from/valid_until window.
+func checkTimeBoundedCRDB(ctx context.Context, db *sql.DB, resourceID, userID int, relation string) (bool, error) {
+	var n int
+	err := db.QueryRowContext(ctx, timeBoundedCheckSQL, resourceID, userID, relation).Scan(&n)
+	return n > 0, err
 }
 
 // runLookupResourcesManageHeavyUser benchmarks resource lookup for "manage" permission
@@ -444,9 +609,91 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 // of resource enumeration for users with extensive access rights.
 // User ID is specified via BENCH_LOOKUPRES_MANAGE_USER env variable.
 // Iterations are controlled by BENCH_LOOKUPRES_MANAGE_ITER env variable (default: 10).
+// runCheckBulkManageDirectUser times batched checks: each iteration checks
+// BENCH_CHECK_BULK_SIZE (resource, user) pairs in one query, the way SpiceDB
+// answers CheckBulkPermissions, instead of one round trip per pair. Pairs come
+// from direct manager grants; every other pair is given the next grant's user,
+// so batches mix allowed and (mostly) denied answers.
+func runCheckBulkManageDirectUser(db *sql.DB) {
+	iters := utils.GetEnvInt("BENCH_CHECK_BULK_ITER", 100)
+	size := utils.GetEnvInt("BENCH_CHECK_BULK_SIZE", 100)
+	log.Printf("[cockroachdb] [check_bulk_manage_direct_user] streaming mode. iterations=%d batch=%d", iters, size)
+	utils.LogScenarioConfig("cockroachdb", "check_bulk_manage_direct_user", iters, 10*time.Second)
+	done, allowed := 0, 0
+	errs := utils.NewErrorTally()
+
+	batch := make([]crdbauthz.Pair, 0, size)
+	runBatch := func() {
+		for i := 1; i < len(batch); i += 2 {
+			batch[i].UserID = batch[(i+1)%len(batch)].UserID
+		}
+		cstart := time.Now()
+		qctx, qcancel := context.WithTimeout(context.Background(), 10*time.Second)
+		granted, err := crdbauthz.CheckBulk(qctx, db, batch, "manager_user")
+		qcancel()
+		dur := time.Since(cstart)
+		for i, p := range batch {
+			utils.AuditCheck("check_bulk_manage_direct_user", p.ResourceID, p.UserID, "manager_user", err == nil && granted[i], dur, err)
+		}
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[cockroachdb] [check_bulk_manage_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+		} else {
+			n := 0
+			for _, ok := range granted {
+				if ok {
+					n++
+				}
+			}
+			allowed += n
+			if utils.LogIteration(done, 10) {
+				log.Printf("[cockroachdb] [check_bulk_manage_direct_user] iter=%d pairs=%d allowed=%d dur=%s", done, len(batch), n, dur)
+			}
+		}
+		done++
+		batch = batch[:0]
+	}
+
+	query := `SELECT resource_id, subject_id FROM resource_acl
+		WHERE subject_type = 'user' AND relation = 'manager_user'
+		ORDER BY resource_id`
+	for done < iters {
+		streamed := 0
+		err := streamQuery(context.Background(), db, query, nil, func(rows *sql.Rows) error {
+			if done >= iters {
+				return nil
+			}
+			var p crdbauthz.Pair
+			if err := rows.Scan(&p.ResourceID, &p.UserID); err != nil {
+				return err
+			}
+			streamed++
+
+			if !utils.InOrgScope(ids.Resource, p.ResourceID) {
+				return nil
+			}
+
+			if batch = append(batch, p); len(batch) == size {
+				runBatch()
+			}
+			return nil
+		})
+		if err != nil {
+			errs.Survive(err, iters, "[cockroachdb] [check_bulk_manage_direct_user] streaming failed: %v", err)
+			continue
+		}
+		if streamed == 0 {
+			log.Printf("[cockroachdb] [check_bulk_manage_direct_user] skipped: no direct manager grants")
+			break
+		}
+	}
+	log.Printf("[cockroachdb] [check_bulk_manage_direct_user] DONE: iters=%d pairs=%d allowed=%d", done, done*size, allowed)
+	log.Printf("[cockroachdb] [check_bulk_manage_direct_user] ERRORS: %s", errs.Summary(done))
+}
+
 func runLookupResourcesManageHeavyUser(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_LOOKUPRES_MANAGE_ITER", 10)
-	userID := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	userID := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	runLookupBench(db, "lookup_resources_manage_super", "manager_user", userID, iters, 60*time.Second)
 }
 
@@ -457,6 +704,91 @@ func runLookupResourcesManageHeavyUser(db *sql.DB) {
 // Iterations are controlled by BENCH_LOOKUPRES_VIEW_ITER env variable (default: 10).
 func runLookupResourcesViewRegularUser(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_LOOKUPRES_VIEW_ITER", 10)
-	userID := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	userID := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	runLookupBench(db, "lookup_resources_view_regular", "viewer_user", userID, iters, 60*time.Second)
 }
+
+// runLookupResourcesMix benchmarks lookups for a mix of users stratified by
+// grant count, reporting latency against result-set size. The number of users
+// is set by BENCH_LOOKUPRES_MIX_USERS (default 0 = skip); see utils.RunLookupMix.
+func runLookupResourcesMix(db *sql.DB) {
+	utils.RunLookupMix("cockroachdb", newChecker(db))
+}
+
+// runLookupResourcesPercentiles benchmarks lookups for the users at the p10,
+// p50, p90 and p99 of the grant counts (BENCH_LOOKUPRES_*_PCT_USERS); see
+// utils.RunPercentileLookups.
+func runLookupResourcesPercentiles(db *sql.DB) {
+	utils.RunPercentileLookups("cockroachdb", newChecker(db))
+}
+
+// runViralFanIn benchmarks checks and lookups on the resources shared with many
+// users directly (RLP_VIRAL_RESOURCES); see utils.RunViralFanIn.
+func runViralFanIn(db *sql.DB) {
+	utils.RunViralFanIn("cockroachdb", newChecker(db))
+}
+
+// runWorstCaseChecks benchmarks view checks on the pairs granted only through
+// nested groups (check_view_worst_case); see utils.RunWorstCaseChecks.
+func runWorstCaseChecks(db *sql.DB) {
+	utils.RunWorstCaseChecks("cockroachdb", newChecker(db))
+}
+
+// runWorkload benchmarks the blended mix of checks and lookups named by
+// BENCH_WORKLOAD (default "" = skip); see utils.RunWorkload.
+func runWorkload(db *sql.DB) {
+	utils.RunWorkload("cockroachdb", newChecker(db))
+}
+
+// runMyGroups benchmarks listing the direct and effective groups of sampled
+// users (BENCH_MY_GROUPS_ITER, default 100; 0 skips); see utils.RunMyGroups.
+func runMyGroups(db *sql.DB) {
+	utils.RunMyGroups("cockroachdb", newChecker(db))
+}
+
+// runAccessSummary benchmarks counting the resources sampled users can view
+// and manage (BENCH_ACCESS_SUMMARY_ITER, default 100; 0 skips); see
+// utils.RunAccessSummary.
+func runAccessSummary(db *sql.DB) {
+	utils.RunAccessSummary("cockroachdb", newChecker(db))
+}
+
+// runMiddlewareOverhead benchmarks a resource fetch with and without a view
+// check in front of it (BENCH_MIDDLEWARE_ITER, default 1000; 0 skips); see
+// utils.RunMiddlewareOverhead.
+func runMiddlewareOverhead(db *sql.DB) {
+	utils.RunMiddlewareOverhead("cockroachdb", newChecker(db), func(ctx context.Context, resourceID string) error {
+		_, err := utils.CountSQLRows(ctx, db, `SELECT * FROM resources WHERE resource_id = $1`, resourceID)
+		return err
+	})
+}
+
+// runDerivedPermissions benchmarks checks and lookups of manage, view and
+// the permissions of BENCH_PERMISSION_RULES (skipped when unset); see
+// utils.RunDerivedPermissions.
+func runDerivedPermissions(db *sql.DB) {
+	utils.RunDerivedPermissions("cockroachdb", newChecker(db))
+}
+
+// runDenials benchmarks checks and lookups of view with and without the
+// anti-join of resource_bans (BENCH_DENIALS, skipped when unset); see
+// utils.RunDenials.
+func runDenials(db *sql.DB) {
+	utils.RunDenials("cockroachdb", newChecker(db))
+}
+
+// runFullExport benchmarks streaming every resource_acl row (export_full_acl,
+// BENCH_EXPORT_ITER); see utils.RunFullExport.
+func runFullExport(db *sql.DB) {
+	utils.RunFullExport("cockroachdb", func(ctx context.Context) (int, error) {
+		return utils.CountSQLRows(ctx, db, `SELECT resource_id, subject_type, subject_id, relation, valid_from, valid_until FROM resource_acl`)
+	})
+}
+
+// runListRecentViewable benchmarks the authorized listing of the newest
+// resources a user can view; see utils.RunRecentListing.
+func runListRecentViewable(db *sql.DB) {
+	utils.RunRecentListing("cockroachdb", func(ctx context.Context, userID string, limit int, after *utils.ListedResource) ([]utils.ListedResource, error) {
+		return listRecentViewableCRDB(ctx, db, userID, limit, after)
+	})
+}
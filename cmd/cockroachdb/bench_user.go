@@ -0,0 +1,58 @@
+package cockroachdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// ensureBenchUser provisions the read-only user the benchmark connects as
+// (see infrastructure.UseBenchCredentials). No-op unless CRDB_BENCH_USER is set.
+// An empty CRDB_BENCH_PASSWORD creates a password-less user, which is the only
+// kind an --insecure cluster accepts.
+func ensureBenchUser(ctx context.Context, db *sql.DB) {
+	user, password, ok := infrastructure.BenchCredentialsFromEnv("CRDB")
+	if !ok {
+		return
+	}
+	role := pq.QuoteIdentifier(user)
+
+	var dbName string
+	if err := db.QueryRowContext(ctx, "SELECT current_database()").Scan(&dbName); err != nil {
+		log.Fatalf("[cockroachdb] bench user: current_database failed: %v", err)
+	}
+
+	stmts := []string{fmt.Sprintf("CREATE USER IF NOT EXISTS %s", role)}
+	if password != "" {
+		stmts = append(stmts, fmt.Sprintf("ALTER USER %s WITH PASSWORD %s", role, pq.QuoteLiteral(password)))
+	}
+	stmts = append(stmts, fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO %s", pq.QuoteIdentifier(dbName), role))
+	if ns := utils.Namespace(); ns != "" {
+		schema := pq.QuoteIdentifier(ns)
+		stmts = append(stmts,
+			fmt.Sprintf("GRANT USAGE ON SCHEMA %s TO %s", schema, role),
+			fmt.Sprintf("GRANT SELECT ON TABLE %s.* TO %s", schema, role),
+			fmt.Sprintf("ALTER DEFAULT PRIVILEGES IN SCHEMA %s GRANT SELECT ON TABLES TO %s", schema, role),
+		)
+	} else {
+		stmts = append(stmts,
+			fmt.Sprintf("GRANT SELECT ON TABLE * TO %s", role),
+			fmt.Sprintf("ALTER DEFAULT PRIVILEGES GRANT SELECT ON TABLES TO %s", role),
+		)
+	}
+	stmts = append(stmts, fmt.Sprintf("ALTER ROLE %s SET default_transaction_read_only = true", role))
+
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			log.Fatalf("[cockroachdb] bench user: %v", err)
+		}
+	}
+
+	log.Printf("[cockroachdb] Read-only bench user %q ready", user)
+}
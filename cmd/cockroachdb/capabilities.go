@@ -0,0 +1,47 @@
+package cockroachdb
+
+import "test-tls/utils"
+
+// Capabilities lists what the cockroachdb module supports, for the capabilities
+// command. Keep it in step with the benchmark scenarios in bechmark_reads.go.
+func Capabilities() utils.Capabilities {
+	return utils.Capabilities{
+		Scenarios: []string{
+			"check_manage_direct_user",
+			"check_manage_org_admin",
+			"check_view_via_group_member",
+			"check_time_bounded_direct_user",
+			"check_bulk_manage_direct_user",
+			"lookup_resources_manage_super",
+			"lookup_resources_view_regular",
+			"lookup_resources_*_mix (BENCH_LOOKUPRES_MIX_USERS)",
+			"lookup_resources_*_pct (BENCH_LOOKUPRES_*_PCT_USERS)",
+			"check_view_viral_direct_user (BENCH_VIRAL_RESOURCES)",
+			"lookup_resources_view_viral (BENCH_LOOKUPRES_VIRAL_USER)",
+			"check_view_worst_case (nested-group-only grants, BENCH_CHECK_WORST_ITER)",
+			"workload_* (blended request mix, BENCH_WORKLOAD)",
+			"list_my_groups_direct, list_my_groups_effective (groups of a user, BENCH_MY_GROUPS_ITER)",
+			"count_access_summary (view and manage counts of a user, BENCH_ACCESS_SUMMARY_ITER)",
+			"middleware_overhead (resource fetch with and without a view check, BENCH_MIDDLEWARE_ITER)",
+			"permissions (checks and lookups of derived permissions such as edit, delete and share, BENCH_PERMISSION_RULES)",
+			"denials (view with and without the resource_bans anti-join, BENCH_DENIALS)",
+			"export_full_acl (full ACL stream, BENCH_EXPORT_ITER)",
+			"list_recent_viewable (authorized listing by created_at)",
+			"custom_* (BENCH_CUSTOM_SCENARIOS)",
+			"statement stats (BENCH_STATEMENT_STATS)",
+			"per-scenario cost from statement statistics (BENCH_COST)",
+			"driver_overhead (BENCH_DRIVER_COMPARE)",
+			"background maintenance: full database BACKUP to CRDB_MAINTENANCE_BACKUP_URI (BENCH_MAINTENANCE)",
+		},
+		Schemas: []string{
+			"migrations/0001_init.sql layout (default)",
+			"nested groups: direct grants only (default), user_resource_permissions view, recursive CTE or group_closure (CRDB_GROUP_RESOLUTION=direct|view|cte|closure)",
+		},
+		Consistency: []string{
+			"serializable",
+		},
+		Writes: []string{
+			"user offboarding: one transaction plus view refresh (offboard, BENCH_OFFBOARD_USERS)",
+		},
+	}
+}
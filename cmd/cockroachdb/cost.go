@@ -0,0 +1,42 @@
+package cockroachdb
+
+import (
+	"context"
+	"database/sql"
+
+	"test-tls/utils"
+)
+
+// costMeter sums the statement statistics of every non-internal fingerprint:
+// statements, rows and bytes read and service latency. It reads
+// crdb_internal.statement_statistics rather than the cluster_ view of
+// snapshotStatementStats, because that one drops the in-memory stats once
+// they are flushed (every sql.stats.flush.interval) and the totals would
+// shrink mid-run. Request units are only metered on CockroachDB Cloud and are
+// not read here.
+func costMeter(db *sql.DB) utils.CostMeter {
+	return func(ctx context.Context) ([]utils.CostCounter, error) {
+		var statements, rowsRead, bytesRead, serviceMs float64
+		err := db.QueryRowContext(ctx, `WITH s AS (
+				SELECT (statistics->'statistics'->>'cnt')::FLOAT8 AS cnt,
+				       (statistics->'statistics'->'svcLat'->>'mean')::FLOAT8 AS svc_lat,
+				       (statistics->'statistics'->'rowsRead'->>'mean')::FLOAT8 AS rows_read,
+				       (statistics->'statistics'->'bytesRead'->>'mean')::FLOAT8 AS bytes_read
+				FROM crdb_internal.statement_statistics
+				WHERE app_name NOT LIKE '$ internal%'
+				  AND metadata->>'query' NOT LIKE '%crdb_internal%'
+			)
+			SELECT COALESCE(SUM(cnt), 0), COALESCE(SUM(cnt * rows_read), 0),
+			       COALESCE(SUM(cnt * bytes_read), 0), COALESCE(SUM(cnt * svc_lat), 0) * 1000
+			FROM s`).Scan(&statements, &rowsRead, &bytesRead, &serviceMs)
+		if err != nil {
+			return nil, err
+		}
+		return []utils.CostCounter{
+			{Name: "statements", Value: statements},
+			{Name: "rows_read", Value: rowsRead},
+			{Name: "bytes_read", Value: bytesRead},
+			{Name: "service_ms", Value: serviceMs},
+		}, nil
+	}
+}
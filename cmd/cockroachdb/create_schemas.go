@@ -3,17 +3,19 @@ package cockroachdb
 import (
 	"context"
 	"log"
-	"os"
 	"time"
 
+	"github.com/lib/pq"
+
 	"test-tls/infrastructure"
+	"test-tls/migrate"
+	"test-tls/utils"
 )
 
-// default location schemas.sql relative ke root project.
-const defaultSchemasFile = "cmd/cockroachdb/schemas.sql"
-
-// CockroachdbCreateSchemas mengeksekusi file schemas.sql ke database Cockroachdb.
-// Path bisa dioverride via env COCKROACHDB_SCHEMAS_FILE.
+// CockroachdbCreateSchemas applies the migrations in migrations/ that the
+// database has not recorded yet, so rerunning it is a no-op. They are embedded
+// in the binary; COCKROACHDB_MIGRATIONS_DIR reads them from a directory instead.
+// With RLP_NAMESPACE everything goes to a schema of that name, created here.
 func CockroachdbCreateSchemas() {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
@@ -24,21 +26,18 @@ func CockroachdbCreateSchemas() {
 	}
 	defer cleanup()
 
-	path := os.Getenv("COCKROACHDB_SCHEMAS_FILE")
-	if path == "" {
-		path = defaultSchemasFile
-	}
-
-	log.Printf("[cockroachdb] Creating schemas using %s ...", path)
-
-	sqlBytes, err := os.ReadFile(path)
-	if err != nil {
-		log.Fatalf("[cockroachdb] create_schemas: read %s failed: %v", path, err)
+	log.Printf("[cockroachdb] Creating schemas ...")
+	if ns := utils.Namespace(); ns != "" {
+		if _, err := db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+pq.QuoteIdentifier(ns)); err != nil {
+			log.Fatalf("[cockroachdb] create_schemas: create schema %q: %v", ns, err)
+		}
+		log.Printf("[cockroachdb] Using schema %q (RLP_NAMESPACE)", ns)
 	}
 
-	if _, err := db.ExecContext(ctx, string(sqlBytes)); err != nil {
-		log.Fatalf("[cockroachdb] create_schemas: executing schemas.sql failed: %v", err)
-	}
+	migrations := migrate.Load("cockroachdb", migrationFiles, "COCKROACHDB_MIGRATIONS_DIR")
+	migrate.Run(ctx, "cockroachdb", migrationStore{db}, migrations)
 
 	log.Println("[cockroachdb] Schemas created successfully.")
+
+	ensureBenchUser(ctx, db)
 }
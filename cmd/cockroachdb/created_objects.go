@@ -0,0 +1,50 @@
+package cockroachdb
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"test-tls/utils"
+)
+
+// recordCreated runs create and records the tables and views it created in
+// rlp_objects, leaving out those it found already there, so that drop
+// removes what the tool created and nothing else of the same name
+// (utils.OwnedRecorded). A create that fails part way still records the
+// tables it made: schema changes do not roll back with it here (see
+// migrationStore), and its IF NOT EXISTS rerun would find them in place.
+func recordCreated(ctx context.Context, db *sql.DB, create func() error) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS rlp_objects (name TEXT PRIMARY KEY)`); err != nil {
+		return err
+	}
+	before, err := utils.ListNames(ctx, db, listTablesSQL)
+	if err != nil {
+		return err
+	}
+	createErr := create()
+	after, err := utils.ListNames(ctx, db, listTablesSQL)
+	if err != nil {
+		return err
+	}
+	for _, name := range utils.NewObjects(before, after) {
+		if _, err := db.ExecContext(ctx, `INSERT INTO rlp_objects (name) VALUES ($1) ON CONFLICT DO NOTHING`, name); err != nil {
+			return err
+		}
+	}
+	return createErr
+}
+
+// recordedObjects returns the names recorded in rlp_objects, none when found,
+// the tables of the schema, holds no such table.
+func recordedObjects(ctx context.Context, db *sql.DB, found []string) (map[string]bool, error) {
+	recorded := map[string]bool{}
+	if !slices.Contains(found, "rlp_objects") {
+		return recorded, nil
+	}
+	names, err := utils.ListNames(ctx, db, `SELECT name FROM rlp_objects`)
+	for _, name := range names {
+		recorded[name] = true
+	}
+	return recorded, err
+}
@@ -0,0 +1,56 @@
+package cockroachdb
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	crdbauthz "test-tls/authz/cockroachdb"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// runDriverOverhead compares database/sql + lib/pq against a native pgx pool
+// on a bare round trip and on the check query, when BENCH_DRIVER_COMPARE=true.
+// Both drivers check the same granted (resource, user) pair, so the difference
+// is client-side cost only.
+func runDriverOverhead(db *sql.DB) {
+	if utils.GetEnvWithDefault("BENCH_DRIVER_COMPARE", "false") != "true" {
+		return
+	}
+	pool, cleanup, err := infrastructure.NewCockroachDBPgxPoolFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[cockroachdb] failed to create pgx pool: %v", err)
+	}
+	defer cleanup()
+
+	var resourceID, userID int
+	err = db.QueryRowContext(context.Background(), `SELECT resource_id, subject_id FROM resource_acl WHERE subject_type = 'user' AND relation = 'manager_user' LIMIT 1`).Scan(&resourceID, &userID)
+	if err != nil {
+		log.Fatalf("[cockroachdb] [driver_overhead] pick check pair: %v", err)
+	}
+	log.Printf("[cockroachdb] [driver_overhead] check resource=%d user=%d relation=manager_user", resourceID, userID)
+
+	utils.CompareDrivers("cockroachdb", []string{"select1", "check"}, []utils.DriverBench{
+		{Driver: "database-sql", Queries: map[string]utils.DriverCall{
+			"select1": func(ctx context.Context) error {
+				var one int
+				return db.QueryRowContext(ctx, `SELECT 1`).Scan(&one)
+			},
+			"check": func(ctx context.Context) error {
+				_, err := checkPermissionCRDB(ctx, db, resourceID, userID, "manager_user")
+				return err
+			},
+		}},
+		{Driver: "pgx", Queries: map[string]utils.DriverCall{
+			"select1": func(ctx context.Context) error {
+				var one int
+				return pool.QueryRow(ctx, `SELECT 1`).Scan(&one)
+			},
+			"check": func(ctx context.Context) error {
+				var n int
+				return pool.QueryRow(ctx, crdbauthz.CheckSQL, resourceID, userID, "manager_user").Scan(&n)
+			},
+		}},
+	})
+}
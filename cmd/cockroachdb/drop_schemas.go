@@ -7,10 +7,13 @@ import (
 	"time"
 
 	"test-tls/infrastructure"
+	"test-tls/utils"
 )
 
 // CockroachdbDropSchemas drops all ACL-related tables used by cockroachdb benchmarks.
-// It does NOT drop the database or the public schema, only the tables we created.
+// It does NOT drop the database or the public schema, only the tables we created
+// and recorded in rlp_objects: other tables of the schema, including ones that
+// share a name with ours, are listed and left in place (utils.OwnedRecorded).
 func CockroachdbDropSchemas() {
 	ctx := context.Background()
 
@@ -23,47 +26,69 @@ func CockroachdbDropSchemas() {
 	start := time.Now()
 	log.Printf("[cockroachdb] == Starting CockroachDB drop schemas ==")
 
-	// Drop indexes explicitly, then materialized view, then tables (children first).
-	statements := []string{
-		// Indexes
-		`DROP INDEX IF EXISTS uq_user_resource_permissions`,
-		`DROP INDEX IF EXISTS idx_urp_user_rel_res`,
-		`DROP INDEX IF EXISTS idx_urp_org_user_rel`,
-		`DROP INDEX IF EXISTS idx_group_hierarchy_parent`,
-		`DROP INDEX IF EXISTS idx_group_hierarchy_child`,
-		`DROP INDEX IF EXISTS idx_resource_acl_res_rel_type_subject`,
-		`DROP INDEX IF EXISTS idx_resource_acl_by_subject`,
-		`DROP INDEX IF EXISTS idx_resource_acl_by_resource_subject`,
-		`DROP INDEX IF EXISTS idx_resources_org`,
-		`DROP INDEX IF EXISTS idx_group_memberships_user`,
-		`DROP INDEX IF EXISTS idx_org_memberships_user`,
-		`DROP INDEX IF EXISTS idx_users_org`,
+	found, err := utils.ListNames(ctx, db, listTablesSQL)
+	if err != nil {
+		log.Fatalf("[cockroachdb] drop: list tables: %v", err)
+	}
+	recorded, err := recordedObjects(ctx, db, found)
+	if err != nil {
+		log.Fatalf("[cockroachdb] drop: list recorded tables: %v", err)
+	}
+	own := utils.OwnedRecorded("cockroachdb", found, recorded, ownedObjects)
 
-		// Materialized view (Cockroach supports this; no function present)
-		`DROP MATERIALIZED VIEW IF EXISTS user_resource_permissions`,
+	utils.GuardDrop("cockroachdb", utils.CountSQLTables(ctx, db, utils.OwnedNames(own, []string{"resource_acl", "resource_bans", "resources", "group_closure", "group_memberships", "group_hierarchy", "org_memberships", "groups", "users", "organizations"})))
 
-		// Tables (children before parents)
-		`DROP TABLE IF EXISTS resource_acl CASCADE`,
-		`DROP TABLE IF EXISTS resources CASCADE`,
-		`DROP TABLE IF EXISTS group_memberships CASCADE`,
-		`DROP TABLE IF EXISTS group_hierarchy CASCADE`,
-		`DROP TABLE IF EXISTS org_memberships CASCADE`,
-		`DROP TABLE IF EXISTS groups CASCADE`,
-		`DROP TABLE IF EXISTS users CASCADE`,
-		`DROP TABLE IF EXISTS organizations CASCADE`,
+	// Materialized view first, then tables (children before parents); indexes
+	// go with their tables. There is no CASCADE: a view of another
+	// application on one of these tables makes its drop fail rather than
+	// disappear with it.
+	drops := []struct{ name, stmt string }{
+		// Materialized view (Cockroach supports this; no function present)
+		{"user_resource_permissions", `DROP MATERIALIZED VIEW IF EXISTS user_resource_permissions`},
+		{"group_closure", `DROP TABLE IF EXISTS group_closure`},
+		{"resource_acl", `DROP TABLE IF EXISTS resource_acl`},
+		{"resource_bans", `DROP TABLE IF EXISTS resource_bans`},
+		{"resources", `DROP TABLE IF EXISTS resources`},
+		{"group_memberships", `DROP TABLE IF EXISTS group_memberships`},
+		{"group_hierarchy", `DROP TABLE IF EXISTS group_hierarchy`},
+		{"org_memberships", `DROP TABLE IF EXISTS org_memberships`},
+		{"groups", `DROP TABLE IF EXISTS groups`},
+		{"users", `DROP TABLE IF EXISTS users`},
+		{"organizations", `DROP TABLE IF EXISTS organizations`},
+		// Applied migrations, so create-schema rebuilds everything
+		{"rlp_schema_migrations", `DROP TABLE IF EXISTS rlp_schema_migrations`},
+		{"rlp_dataset", `DROP TABLE IF EXISTS rlp_dataset`},
+		// The record of what the tool created, once that is gone.
+		{"rlp_objects", `DROP TABLE IF EXISTS rlp_objects`},
 	}
 
-	for _, stmt := range statements {
-		if err := execWithTimeout(ctx, db, stmt, 30*time.Second); err != nil {
-			log.Fatalf("[cockroachdb] executing %q failed: %v", stmt, err)
+	for _, d := range drops {
+		if !own[d.name] {
+			continue
+		}
+		if err := execWithTimeout(ctx, db, d.stmt, 30*time.Second); err != nil {
+			log.Fatalf("[cockroachdb] executing %q failed: %v", d.stmt, err)
 		}
-		log.Printf("[cockroachdb] Executed: %s", stmt)
+		log.Printf("[cockroachdb] Executed: %s", d.stmt)
 	}
 
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 	log.Printf("[cockroachdb] CockroachDB drop schemas DONE: elapsed=%s", elapsed)
 }
 
+// ownedObjects are the tables and views create-schema and load-data create;
+// drop removes nothing else, and of these only the ones recorded as created
+// (recordCreated).
+var ownedObjects = []string{
+	"organizations", "users", "groups", "org_memberships", "group_memberships", "group_hierarchy",
+	"resources", "resource_acl", "resource_bans", "group_closure", "user_resource_permissions", "rlp_schema_migrations",
+	"rlp_dataset", "rlp_objects",
+}
+
+// listTablesSQL lists the tables and views of the current schema (the
+// namespace, or public).
+const listTablesSQL = `SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema()`
+
 func execWithTimeout(parent context.Context, db *sql.DB, stmt string, timeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
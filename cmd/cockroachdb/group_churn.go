@@ -0,0 +1,167 @@
+package cockroachdb
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/lib/pq"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// rederiveGroupClosureSQL recomputes the group_closure rows of the ancestor
+// groups $1 by walking group_hierarchy down from each, after their rows were
+// deleted. The walk state is the path shape so far: 'm' member_group edges
+// only, 'mm' member_group then manager_group edges, 'g' manager_group edges
+// only (see migrations/0002_group_closure.sql). UNION ends it on cycles.
+const rederiveGroupClosureSQL = `WITH RECURSIVE down(ancestor, descendant, state) AS (
+		SELECT a, a, s FROM unnest($1::int[]) a CROSS JOIN (VALUES ('m'), ('g')) v(s)
+		UNION
+		SELECT d.ancestor, gh.child_group_id,
+			CASE WHEN d.state = 'm' AND gh.relation = 'member_group' THEN 'm' WHEN d.state = 'g' THEN 'g' ELSE 'mm' END
+		FROM down d
+		JOIN group_hierarchy gh ON gh.parent_group_id = d.descendant
+		WHERE d.state = 'm' OR gh.relation = 'manager_group'
+	)
+	INSERT INTO group_closure (descendant_group_id, member_role, relation, ancestor_group_id)
+	SELECT descendant, 'direct_member', 'member', ancestor FROM down WHERE state = 'm'
+	UNION
+	SELECT descendant, 'direct_manager', 'member', ancestor FROM down WHERE state IN ('m', 'mm')
+	UNION
+	SELECT descendant, 'direct_manager', 'manager', ancestor FROM down WHERE state = 'g'`
+
+// groupClosureMaintainer keeps group_closure up to date with group_hierarchy
+// changes (utils.GroupMaintainer). An added edge gets its rows from
+// addEdgeClosureSQL. A removed edge may still be bridged by another path, so
+// the rows of every ancestor of its parent are deleted and rederived; a run
+// of removals shares one rederive.
+type groupClosureMaintainer struct {
+	db *sql.DB
+}
+
+func (m groupClosureMaintainer) Edges(ctx context.Context, n int) ([]utils.GroupEdgeChange, error) {
+	rs, err := m.db.QueryContext(ctx, `SELECT parent_group_id, child_group_id, relation FROM group_hierarchy
+		ORDER BY parent_group_id, child_group_id, relation LIMIT $1`, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var edges []utils.GroupEdgeChange
+	for rs.Next() {
+		e := utils.GroupEdgeChange{Add: true}
+		if err := rs.Scan(&e.Parent, &e.Child, &e.Relation); err != nil {
+			return nil, err
+		}
+		edges = append(edges, e)
+	}
+	return edges, rs.Err()
+}
+
+// Apply applies burst in one transaction.
+func (m groupClosureMaintainer) Apply(ctx context.Context, burst []utils.GroupEdgeChange) (int, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows := int64(0)
+	var removed []utils.GroupEdgeChange
+	flush := func() error {
+		if len(removed) == 0 {
+			return nil
+		}
+		n, err := removeGroupEdges(ctx, tx, removed)
+		rows += n
+		removed = removed[:0]
+		return err
+	}
+	for _, c := range burst {
+		if !c.Add {
+			removed = append(removed, c)
+			continue
+		}
+		if err := flush(); err != nil {
+			return 0, err
+		}
+		res, err := tx.ExecContext(ctx, `INSERT INTO group_hierarchy (parent_group_id, child_group_id, relation)
+			VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, c.Parent, c.Child, c.Relation)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue // already there, and so are its closure rows
+		}
+		res, err = tx.ExecContext(ctx, addEdgeClosureSQL[c.Relation], c.Parent, c.Child)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		rows += n
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	return int(rows), tx.Commit()
+}
+
+// removeGroupEdges deletes edges from group_hierarchy and rederives the
+// closure rows of the ancestors of their parents, returning the closure rows
+// deleted plus inserted.
+func removeGroupEdges(ctx context.Context, tx *sql.Tx, edges []utils.GroupEdgeChange) (int64, error) {
+	parents := make([]int64, len(edges))
+	for i, e := range edges {
+		parents[i] = int64(e.Parent)
+	}
+	rs, err := tx.QueryContext(ctx, `SELECT DISTINCT ancestor_group_id FROM group_closure WHERE descendant_group_id = ANY($1)`, pq.Array(parents))
+	if err != nil {
+		return 0, err
+	}
+	var ancestors []int64
+	for rs.Next() {
+		var a int64
+		if err := rs.Scan(&a); err != nil {
+			rs.Close()
+			return 0, err
+		}
+		ancestors = append(ancestors, a)
+	}
+	rs.Close()
+	if err := rs.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, e := range edges {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM group_hierarchy WHERE parent_group_id = $1 AND child_group_id = $2 AND relation = $3`,
+			e.Parent, e.Child, e.Relation); err != nil {
+			return 0, err
+		}
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM group_closure WHERE ancestor_group_id = ANY($1)`, pq.Array(ancestors))
+	if err != nil {
+		return 0, err
+	}
+	deleted, _ := res.RowsAffected()
+	res, err = tx.ExecContext(ctx, rederiveGroupClosureSQL, pq.Array(ancestors))
+	if err != nil {
+		return 0, err
+	}
+	inserted, _ := res.RowsAffected()
+	return deleted + inserted, nil
+}
+
+// CockroachdbGroupChurn benchmarks the incremental maintenance of group_closure
+// per burst of group hierarchy changes; see utils.RunGroupChurn.
+func CockroachdbGroupChurn() {
+	ctx := context.Background()
+	db, cleanup, err := infrastructure.NewCockroachDBFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[cockroachdb] failed to create cockroachdb client: %v", err)
+	}
+	defer cleanup()
+
+	utils.RunGroupChurn("cockroachdb", groupClosureMaintainer{db: db})
+	log.Println("[cockroachdb] == CockroachDB group churn DONE ==")
+}
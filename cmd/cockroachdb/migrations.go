@@ -0,0 +1,54 @@
+package cockroachdb
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+
+	"test-tls/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationStore records applied migrations in rlp_schema_migrations. CockroachDB
+// runs schema changes asynchronously and restricts them inside explicit
+// transactions, so a migration runs on its own and is recorded after it
+// succeeds; a failed one may be partly applied, which the IF NOT EXISTS
+// statements make safe to rerun.
+type migrationStore struct{ db *sql.DB }
+
+func (s migrationStore) Applied(ctx context.Context) (map[int]bool, error) {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS rlp_schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT version FROM rlp_schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+func (s migrationStore) Apply(ctx context.Context, m migrate.Migration) error {
+	if err := recordCreated(ctx, s.db, func() error {
+		_, err := s.db.ExecContext(ctx, m.Body)
+		return err
+	}); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO rlp_schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+	return err
+}
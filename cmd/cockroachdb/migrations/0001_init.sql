@@ -1,4 +1,4 @@
--- cmd/postgres/schemas.sql
+-- cmd/cockroachdb/migrations/0001_init.sql
 -- Schema for the RLS dataset (mirror of cmd/csv/load_data.go)
 
 -- 1) Core entities
@@ -66,6 +66,9 @@ CREATE TABLE IF NOT EXISTS resource_acl (
     subject_type TEXT    NOT NULL,
     subject_id   INTEGER NOT NULL,
     relation     TEXT    NOT NULL,
+    -- Optional validity window; expired rows are kept as soft deletes.
+    valid_from   TIMESTAMPTZ,
+    valid_until  TIMESTAMPTZ,
     PRIMARY KEY (resource_id, subject_type, subject_id, relation)
 );
 
@@ -101,7 +104,13 @@ CREATE INDEX IF NOT EXISTS idx_resource_acl_by_subject
 CREATE INDEX IF NOT EXISTS idx_resource_acl_res_rel_type_subject
     ON resource_acl (resource_id, relation, subject_type, subject_id);
 
--- 2) Fast lookup of users by primary org (helps org-scoped queries/joins)
+-- 2) Time-bounded grants sampled by check_time_bounded_direct_user
+CREATE INDEX IF NOT EXISTS idx_resource_acl_time_bounded
+    ON resource_acl (resource_id, subject_id, relation)
+    STORING (valid_from, valid_until)
+    WHERE subject_type = 'user' AND valid_until IS NOT NULL;
+
+-- 3) Fast lookup of users by primary org (helps org-scoped queries/joins)
 CREATE INDEX IF NOT EXISTS idx_users_org
     ON users (org_id);
 
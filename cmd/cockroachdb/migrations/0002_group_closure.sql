@@ -0,0 +1,21 @@
+-- cmd/cockroachdb/migrations/0002_group_closure.sql
+-- Maintained transitive closure of group_hierarchy, the precomputed
+-- alternative to resolving nested groups with a recursive CTE per query.
+-- Each row means: a user holding member_role directly in the descendant group
+-- holds relation in the ancestor group. Every group is its own ancestor.
+--  - ('direct_manager', 'manager'): manager_group edges only
+--  - ('direct_member',  'member'):  member_group edges only
+--  - ('direct_manager', 'member'):  member_group edges, then manager_group edges
+--    (managers are members)
+-- load-data builds it after group_hierarchy; see group_closure.go.
+CREATE TABLE IF NOT EXISTS group_closure (
+    descendant_group_id INTEGER NOT NULL REFERENCES groups(group_id),
+    member_role         TEXT    NOT NULL,
+    relation            TEXT    NOT NULL,
+    ancestor_group_id   INTEGER NOT NULL REFERENCES groups(group_id),
+    PRIMARY KEY (descendant_group_id, member_role, relation, ancestor_group_id)
+);
+
+-- Closure maintenance joins from an edge's child down to its descendants
+CREATE INDEX IF NOT EXISTS idx_group_closure_ancestor
+    ON group_closure (ancestor_group_id, relation, member_role, descendant_group_id);
@@ -0,0 +1,11 @@
+-- cmd/cockroachdb/migrations/0004_timestamps.sql
+-- When a resource was last edited and when a grant was made, for recency
+-- ordering, time-window filters and TTL-style experiments. NULL for data
+-- loaded from CSVs without the columns.
+ALTER TABLE resources ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+
+ALTER TABLE resource_acl ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+
+-- resource_acl: grants made in a time window
+CREATE INDEX IF NOT EXISTS idx_resource_acl_created
+    ON resource_acl (created_at);
@@ -0,0 +1,105 @@
+-- cmd/cockroachdb/migrations/0006_org_permissions.sql
+-- user_resource_permissions with the org paths of the SpiceDB schema
+-- (manage includes org->admin, view includes org->member), as the ClickHouse
+-- view, the ScyllaDB perms tables and the Elasticsearch documents already
+-- have them. Every member of an org gets a row per resource of the org, so
+-- the view grows by members x resources per org.
+-- The definition is otherwise that of 0005_grant_windows.sql.
+DROP MATERIALIZED VIEW IF EXISTS user_resource_permissions;
+
+CREATE MATERIALIZED VIEW user_resource_permissions AS
+WITH RECURSIVE
+-- effective managers per group: start with direct_manager users
+mgr_users AS (
+  SELECT gm.group_id AS root_group, gm.user_id
+  FROM group_memberships gm
+  WHERE gm.role = 'direct_manager'
+
+  UNION ALL
+
+  -- parent.manager includes child.manager when relation = 'manager_group'
+  SELECT gh.parent_group_id AS root_group, mu.user_id
+  FROM group_hierarchy gh
+  JOIN mgr_users mu ON gh.child_group_id = mu.root_group
+  WHERE gh.relation = 'manager_group'
+),
+
+-- effective members per group: include direct_member users, recursively include child.member
+-- and include managers (managers are also members)
+member_users AS (
+    -- non-recursive base: direct members + managers (managers are also members)
+    SELECT gm.group_id AS root_group, gm.user_id
+    FROM group_memberships gm
+    WHERE gm.role = 'direct_member'
+
+    UNION
+
+    SELECT m.root_group, m.user_id FROM mgr_users m
+
+    UNION ALL
+
+    -- recursive term: parent.member includes child.member when relation = 'member_group'
+    SELECT gh.parent_group_id AS root_group, mu.user_id
+    FROM group_hierarchy gh
+    JOIN member_users mu ON gh.child_group_id = mu.root_group
+    WHERE gh.relation = 'member_group'
+)
+
+-- Now produce permission rows
+SELECT r.resource_id, r.org_id, ra.subject_id AS user_id,
+  CASE WHEN ra.relation LIKE 'manager%' THEN 'manager' ELSE 'viewer' END AS relation
+FROM resource_acl ra
+JOIN resources r ON r.resource_id = ra.resource_id
+WHERE ra.subject_type = 'user' AND ra.relation IN ('manager_user', 'viewer_user', 'manager', 'viewer')
+  AND (ra.valid_from IS NULL OR ra.valid_from <= now())
+  AND (ra.valid_until IS NULL OR ra.valid_until > now())
+
+UNION
+
+-- group ACL -> managers
+SELECT r.resource_id, r.org_id, mu.user_id, 'manager' AS relation
+FROM resource_acl ra
+JOIN resources r ON r.resource_id = ra.resource_id
+JOIN mgr_users mu ON ra.subject_type = 'group' AND ra.subject_id = mu.root_group
+WHERE (ra.relation = 'manager_group' OR ra.relation = 'manager')
+  AND (ra.valid_from IS NULL OR ra.valid_from <= now())
+  AND (ra.valid_until IS NULL OR ra.valid_until > now())
+
+UNION
+
+-- group ACL -> viewers (expand to effective members; managers included by member_users)
+SELECT r.resource_id, r.org_id, mem.user_id, 'viewer' AS relation
+FROM resource_acl ra
+JOIN resources r ON r.resource_id = ra.resource_id
+JOIN member_users mem ON ra.subject_type = 'group' AND ra.subject_id = mem.root_group
+WHERE (ra.relation = 'viewer_group' OR ra.relation = 'viewer')
+  AND (ra.valid_from IS NULL OR ra.valid_from <= now())
+  AND (ra.valid_until IS NULL OR ra.valid_until > now())
+
+UNION
+
+-- org admins -> managers (organization#admin in the SpiceDB schema)
+SELECT r.resource_id, r.org_id, om.user_id, 'manager' AS relation
+FROM resources r
+JOIN org_memberships om ON om.org_id = r.org_id
+WHERE om.role = 'admin'
+
+UNION
+
+-- org members -> viewers (organization#member, which includes admins)
+SELECT r.resource_id, r.org_id, om.user_id, 'viewer' AS relation
+FROM resources r
+JOIN org_memberships om ON om.org_id = r.org_id
+WHERE om.role IN ('admin', 'member');
+
+-- Ensure uniqueness (the UNION above deduplicates, but a unique index
+-- allows CONCURRENT refreshes and fast lookups)
+CREATE UNIQUE INDEX IF NOT EXISTS uq_user_resource_permissions
+    ON user_resource_permissions (resource_id, user_id, relation);
+
+-- Useful access patterns on the materialized view
+CREATE INDEX IF NOT EXISTS idx_urp_user_rel_res
+    ON user_resource_permissions (user_id, relation, resource_id);
+
+CREATE INDEX IF NOT EXISTS idx_urp_org_user_rel
+    ON user_resource_permissions (org_id, user_id, relation, resource_id);
@@ -0,0 +1,146 @@
+package cockroachdb
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"test-tls/authz"
+	crdbauthz "test-tls/authz/cockroachdb"
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// querier is db, or db through prepared statements when
+// BENCH_PREPARED_STATEMENTS=true, for the check and lookup queries.
+func querier(db *sql.DB) crdbauthz.Querier {
+	if utils.PreparedStatements() {
+		return utils.Prepared(db)
+	}
+	return db
+}
+
+// checkPermissionCRDB runs the check of CRDB_GROUP_RESOLUTION through
+// database/sql, as timed by the check_* scenarios.
+func checkPermissionCRDB(ctx context.Context, db *sql.DB, resourceID, userID any, relation string) (bool, error) {
+	return crdbauthz.Check(ctx, querier(db), groupResolutionFromEnv(), resourceID, userID, relation)
+}
+
+// lookupResourcesCRDB streams the resources a user holds a relation on, as
+// timed by the lookup_resources_* scenarios. CRDB_GROUP_RESOLUTION picks the
+// query; the direct mode reads direct user grants and the org path.
+func lookupResourcesCRDB(ctx context.Context, db *sql.DB, userID, relation string, handle func(resID int)) error {
+	return crdbauthz.LookupResources(ctx, querier(db), groupResolutionFromEnv(), userID, relation, handle)
+}
+
+// listRecentViewableSQL is the authorized listing of list_recent_viewable:
+// the newest resources user $1 can view, keyset-paginated after
+// (created_at, resource_id) = ($3, $4) unless $3 is NULL.
+const listRecentViewableSQL = `SELECT r.resource_id, r.created_at
+	FROM user_resource_permissions p
+	JOIN resources r ON r.resource_id = p.resource_id
+	WHERE p.user_id = $1 AND p.relation = 'viewer' AND r.created_at IS NOT NULL
+	  AND ($3::timestamptz IS NULL OR (r.created_at, r.resource_id) < ($3::timestamptz, $4::int))
+	ORDER BY r.created_at DESC, r.resource_id DESC
+	LIMIT $2`
+
+// listRecentViewableCRDB returns a page of listRecentViewableSQL (see utils.ListPage).
+func listRecentViewableCRDB(ctx context.Context, db *sql.DB, userID string, limit int, after *utils.ListedResource) ([]utils.ListedResource, error) {
+	var afterAt *time.Time
+	afterID := 0
+	if after != nil {
+		n, err := ids.Parse(ids.Resource, after.ID)
+		if err != nil {
+			return nil, err
+		}
+		afterAt, afterID = &after.CreatedAt, n
+	}
+	rows, err := db.QueryContext(ctx, listRecentViewableSQL, userID, limit, afterAt, afterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var page []utils.ListedResource
+	for rows.Next() {
+		var resID int
+		var createdAt time.Time
+		if err := rows.Scan(&resID, &createdAt); err != nil {
+			return nil, err
+		}
+		page = append(page, utils.ListedResource{ID: ids.Format(ids.Resource, resID), CreatedAt: createdAt})
+	}
+	return page, rows.Err()
+}
+
+// newChecker returns the benchmark queries over db under
+// CRDB_GROUP_RESOLUTION, with the permissions of BENCH_PERMISSION_RULES,
+// the denials of BENCH_DENIALS and the ids of RLP_ID_FORMAT.
+func newChecker(db *sql.DB) authz.Checker {
+	checker, err := crdbauthz.NewChecker(db, crdbauthz.Config{GroupResolution: groupResolutionFromEnv(), Rules: utils.PermissionRulesFromEnv(), Denials: utils.DenialsFromEnv(), IDFormat: ids.CurrentFormat()})
+	if err != nil {
+		log.Fatalf("[cockroachdb] %v", err)
+	}
+	return checker
+}
+
+// CockroachdbServe serves Check/Lookup over HTTP using the benchmark queries.
+func CockroachdbServe() {
+	db, cleanup, err := infrastructure.NewCockroachDBFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[cockroachdb] failed to create database connection: %v", err)
+	}
+	defer cleanup()
+
+	utils.Serve("cockroachdb", newChecker(db))
+}
+
+// CockroachdbReplayAudit re-runs a recorded audit file (REPLAY_AUDIT_FILE) through
+// the benchmark queries.
+func CockroachdbReplayAudit() {
+	db, cleanup, err := infrastructure.NewCockroachDBFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[cockroachdb] failed to create database connection: %v", err)
+	}
+	defer cleanup()
+
+	utils.ReplayAudit("cockroachdb", newChecker(db))
+}
+
+// CockroachdbVerifyFixture checks the benchmark queries against the known
+// answers of the fixture dataset (see utils.VerifyFixture).
+func CockroachdbVerifyFixture() {
+	db, cleanup, err := infrastructure.NewCockroachDBFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[cockroachdb] failed to create database connection: %v", err)
+	}
+	defer cleanup()
+
+	utils.VerifyFixture("cockroachdb", newChecker(db))
+}
+
+// CockroachdbExportPermissions writes a permission snapshot of the benchmark
+// queries for diff-permissions (see utils.ExportPermissions).
+func CockroachdbExportPermissions() {
+	db, cleanup, err := infrastructure.NewCockroachDBFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[cockroachdb] failed to create database connection: %v", err)
+	}
+	defer cleanup()
+
+	utils.ExportPermissions("cockroachdb", newChecker(db))
+}
+
+// CockroachdbWorker replays the shard a distributed coordinator hands out and
+// streams the results back (see utils.RunWorker).
+func CockroachdbWorker() {
+	db, cleanup, err := infrastructure.NewCockroachDBFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[cockroachdb] failed to create database connection: %v", err)
+	}
+	defer cleanup()
+
+	utils.RunWorker("cockroachdb", newChecker(db))
+}
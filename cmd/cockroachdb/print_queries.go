@@ -0,0 +1,28 @@
+package cockroachdb
+
+import (
+	crdbauthz "test-tls/authz/cockroachdb"
+	"test-tls/ids"
+	"test-tls/utils"
+)
+
+// CockroachdbPrintQueries prints the statements of the read scenarios under
+// CRDB_GROUP_RESOLUTION with sampled parameters instead of running them
+// (see utils.PrintQueries).
+func CockroachdbPrintQueries() {
+	mode := groupResolutionFromEnv()
+	utils.PrintQueries("cockroachdb", func(s utils.QuerySample) string {
+		user := utils.SampleInt(ids.User, s.User)
+		var query string
+		var args []any
+		switch s.Scenario {
+		case "check_time_bounded_direct_user":
+			query, args = timeBoundedCheckSQL, []any{utils.SampleInt(ids.Resource, s.Resource), user, s.Relation}
+		case "lookup_resources_manage_super", "lookup_resources_view_regular":
+			query, args = crdbauthz.LookupStatement(mode, user, crdbauthz.Relations[s.Permission])
+		default:
+			query, args = crdbauthz.CheckStatement(mode, utils.SampleInt(ids.Resource, s.Resource), user, crdbauthz.Relations[s.Permission])
+		}
+		return utils.RenderSQL(query, args...)
+	})
+}
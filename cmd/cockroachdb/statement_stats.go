@@ -0,0 +1,79 @@
+package cockroachdb
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"test-tls/utils"
+)
+
+// startStatementStats resets CockroachDB's SQL statistics before the
+// benchmark, when BENCH_STATEMENT_STATS=true, and returns the snapshot to take
+// after it. The snapshot logs one STATEMENT line per statement fingerprint
+// (top BENCH_STATEMENT_STATS_TOP by total service latency) with calls,
+// mean/total service latency and mean rows and bytes read, which
+// benchmark/parse_all.go reports next to the client latencies. CockroachDB has
+// no buffer counters, so rows/bytes read stand in for them.
+func startStatementStats(db *sql.DB) func() {
+	if utils.GetEnvWithDefault("BENCH_STATEMENT_STATS", "false") != "true" {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, `SELECT crdb_internal.reset_sql_stats()`); err != nil {
+		log.Printf("[cockroachdb] WARN: reset_sql_stats failed, skipping statement stats: %v", err)
+		return func() {}
+	}
+	log.Printf("[cockroachdb] SQL statement stats reset")
+	return snapshotStatementStats(db)
+}
+
+func snapshotStatementStats(db *sql.DB) func() {
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		// One row per fingerprint, node and aggregation interval; weight the
+		// per-row means by their counts to combine them.
+		rows, err := db.QueryContext(ctx, `WITH s AS (
+				SELECT metadata->>'query' AS query,
+				       (statistics->'statistics'->>'cnt')::FLOAT8 AS cnt,
+				       (statistics->'statistics'->'svcLat'->>'mean')::FLOAT8 AS svc_lat,
+				       (statistics->'statistics'->'rowsRead'->>'mean')::FLOAT8 AS rows_read,
+				       (statistics->'statistics'->'bytesRead'->>'mean')::FLOAT8 AS bytes_read
+				FROM crdb_internal.cluster_statement_statistics
+				WHERE app_name NOT LIKE '$ internal%'
+				  AND metadata->>'query' NOT LIKE '%crdb_internal%'
+			)
+			SELECT query, SUM(cnt)::INT8, SUM(cnt * svc_lat) / SUM(cnt) * 1000, SUM(cnt * svc_lat) * 1000,
+			       SUM(cnt * rows_read) / SUM(cnt), SUM(cnt * bytes_read) / SUM(cnt)
+			FROM s
+			GROUP BY query
+			ORDER BY 4 DESC
+			LIMIT $1`, utils.GetEnvInt("BENCH_STATEMENT_STATS_TOP", 10))
+		if err != nil {
+			log.Printf("[cockroachdb] WARN: statement stats snapshot failed: %v", err)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var query string
+			var calls int64
+			var mean, total, rowsRead, bytesRead float64
+			if err := rows.Scan(&query, &calls, &mean, &total, &rowsRead, &bytesRead); err != nil {
+				log.Printf("[cockroachdb] WARN: statement stats scan failed: %v", err)
+				return
+			}
+			log.Printf("[cockroachdb] STATEMENT: calls=%d mean=%.3fms total=%.1fms io=rows_read_avg=%.1f,bytes_read_avg=%.0f query=%s",
+				calls, mean, total, rowsRead, bytesRead, strings.Join(strings.Fields(query), " "))
+		}
+		if err := rows.Err(); err != nil {
+			log.Printf("[cockroachdb] WARN: statement stats snapshot failed: %v", err)
+		}
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"encoding/csv"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	"test-tls/ids"
+	"test-tls/utils"
 )
 
 // High-level dataset configuration (defaults).
@@ -27,6 +32,17 @@ import (
 //	RLP_VIEWER_GROUPS_PER_RESOURCE
 //	RLP_AVG_ORGS_PER_USER         // average orgs per user (default 2)
 //	RLP_RANDOM_SEED               // optional: fixed random seed for reproducibility
+//	RLP_EXPIRING_GRANT_FRACTION   // optional: fraction of direct user grants with valid_from/valid_until (default 0)
+//	RLP_EXPIRED_GRANT_SHARE       // optional: share of those windows already expired (default 0.5)
+//	RLP_VIRAL_RESOURCES           // optional: number of resources shared directly with many users (default 0)
+//	RLP_VIRAL_USER_FRACTION       // optional: share of all users given viewer_user on each of them (default 0.5)
+//	RLP_BANNED_RESOURCE_FRACTION  // optional: fraction of resources with banned_user denials in resource_bans.csv (default 0)
+//	RLP_BANNED_USERS_PER_RESOURCE // optional: direct viewers banned on each of them (default 1)
+//	RLP_HISTORY_DAYS              // optional: days before generation that created_at/updated_at span (default 365)
+//	RLP_ID_FORMAT                 // optional: int (default), prefixed (user_123) or uuid; see package ids
+//	RLP_DATA_DIR                  // optional: directory to write the CSVs to (default: data, --data-dir)
+//	RLP_DATA_ARCHIVE              // optional: false overwrites the dataset instead of moving it to <dir>/datasets (default: true)
+//	RLP_WRITE_BENCH_USERS         // optional: manifest and/or env (comma-separated) to save the picked bench users
 const (
 	defaultNumOrgs                 = 16
 	defaultUsersPerOrg             = 200
@@ -39,6 +55,10 @@ const (
 	defaultViewerUsersPerResource  = 10
 	defaultViewerGroupsPerRes      = 3
 	defaultAvgOrgsPerUser          = 2
+	defaultExpiredGrantShare       = 0.5
+	defaultViralUserFraction       = 0.5
+	defaultBannedUsersPerResource  = 1
+	defaultHistoryDays             = 365
 )
 
 type config struct {
@@ -53,6 +73,13 @@ type config struct {
 	ViewerUsersPerResource  int
 	ViewerGroupsPerRes      int
 	AvgOrgsPerUser          int
+	ExpiringGrantFraction   float64
+	ExpiredGrantShare       float64
+	ViralResources          int
+	ViralUserFraction       float64
+	BannedResourceFraction  float64
+	BannedUsersPerResource  int
+	HistoryDays             int
 }
 
 func loadConfig() config {
@@ -68,6 +95,13 @@ func loadConfig() config {
 		ViewerUsersPerResource:  getEnvInt("RLP_VIEWER_USERS_PER_RESOURCE", defaultViewerUsersPerResource),
 		ViewerGroupsPerRes:      getEnvInt("RLP_VIEWER_GROUPS_PER_RESOURCE", defaultViewerGroupsPerRes),
 		AvgOrgsPerUser:          getEnvInt("RLP_AVG_ORGS_PER_USER", defaultAvgOrgsPerUser),
+		ExpiringGrantFraction:   getEnvFloat("RLP_EXPIRING_GRANT_FRACTION", 0),
+		ExpiredGrantShare:       getEnvFloat("RLP_EXPIRED_GRANT_SHARE", defaultExpiredGrantShare),
+		ViralResources:          getEnvInt("RLP_VIRAL_RESOURCES", 0),
+		ViralUserFraction:       getEnvFloat("RLP_VIRAL_USER_FRACTION", defaultViralUserFraction),
+		BannedResourceFraction:  getEnvFloat("RLP_BANNED_RESOURCE_FRACTION", 0),
+		BannedUsersPerResource:  getEnvInt("RLP_BANNED_USERS_PER_RESOURCE", defaultBannedUsersPerResource),
+		HistoryDays:             getEnvInt("RLP_HISTORY_DAYS", defaultHistoryDays),
 	}
 
 	// Basic safety clamps.
@@ -83,6 +117,9 @@ func loadConfig() config {
 	if cfg.ResourcesPerOrg < 0 {
 		cfg.ResourcesPerOrg = 0
 	}
+	if cfg.HistoryDays < 1 {
+		cfg.HistoryDays = 1
+	}
 	if cfg.GroupsPerUser < 0 {
 		cfg.GroupsPerUser = 0
 	}
@@ -104,6 +141,12 @@ func loadConfig() config {
 	if cfg.AvgOrgsPerUser < 1 {
 		cfg.AvgOrgsPerUser = 1
 	}
+	cfg.ExpiringGrantFraction = min(max(cfg.ExpiringGrantFraction, 0), 1)
+	cfg.ExpiredGrantShare = min(max(cfg.ExpiredGrantShare, 0), 1)
+	cfg.ViralResources = max(cfg.ViralResources, 0)
+	cfg.ViralUserFraction = min(max(cfg.ViralUserFraction, 0), 1)
+	cfg.BannedResourceFraction = min(max(cfg.BannedResourceFraction, 0), 1)
+	cfg.BannedUsersPerResource = max(cfg.BannedUsersPerResource, 1)
 
 	return cfg
 }
@@ -121,6 +164,19 @@ func getEnvInt(key string, def int) int {
 	return n
 }
 
+// getEnvFloat reads a float from env, falling back to default if unset or invalid.
+func getEnvFloat(key string, def float64) float64 {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
 type csvSinks struct {
 	orgsFile           *os.File
 	usersFile          *os.File
@@ -130,6 +186,7 @@ type csvSinks struct {
 	groupHierarchyFile *os.File
 	resourcesFile      *os.File
 	resourceACLFile    *os.File
+	resourceBansFile   *os.File
 	orgs               *csv.Writer
 	users              *csv.Writer
 	groups             *csv.Writer
@@ -138,6 +195,7 @@ type csvSinks struct {
 	groupHierarchy     *csv.Writer
 	resources          *csv.Writer
 	resourceACL        *csv.Writer
+	resourceBans       *csv.Writer
 }
 
 func newCsvSinks(dir string) *csvSinks {
@@ -164,13 +222,14 @@ func newCsvSinks(dir string) *csvSinks {
 	s.groupHierarchyFile, s.groupHierarchy = makeWriter("group_hierarchy.csv")
 	s.resourcesFile, s.resources = makeWriter("resources.csv")
 	s.resourceACLFile, s.resourceACL = makeWriter("resource_acl.csv")
+	s.resourceBansFile, s.resourceBans = makeWriter("resource_bans.csv")
 
 	return s
 }
 
 func (s *csvSinks) close() {
 	writers := []*csv.Writer{
-		s.orgs, s.users, s.groups, s.orgMembers, s.groupMembers, s.groupHierarchy, s.resources, s.resourceACL,
+		s.orgs, s.users, s.groups, s.orgMembers, s.groupMembers, s.groupHierarchy, s.resources, s.resourceACL, s.resourceBans,
 	}
 	for _, w := range writers {
 		if w == nil {
@@ -185,7 +244,7 @@ func (s *csvSinks) close() {
 	files := []*os.File{
 		s.orgsFile, s.usersFile, s.groupsFile,
 		s.orgMembersFile, s.groupMembersFile, s.groupHierarchyFile,
-		s.resourcesFile, s.resourceACLFile,
+		s.resourcesFile, s.resourceACLFile, s.resourceBansFile,
 	}
 	for _, f := range files {
 		if f != nil {
@@ -202,83 +261,10 @@ func writeRow(w *csv.Writer, fields ...string) {
 	}
 }
 
-type idCount struct {
-	id    int
-	count int
-}
-
-// summarizeRelation logs:
-// - total A nodes
-// - average B per A
-// - 3 A with fewest B
-// - 3 A with "typical" B (around median)
-// - 3 A with most B
+// summarizeRelation logs the fan-out of a generated relation; see
+// utils.SummarizeRelation for the format.
 func summarizeRelation(name, aLabel, bLabel string, counts map[int]int) {
-	if len(counts) == 0 {
-		log.Printf("[csv] relation %s (%s -> %s): no data", name, aLabel, bLabel)
-		return
-	}
-
-	pairs := make([]idCount, 0, len(counts))
-	total := 0
-	for id, c := range counts {
-		pairs = append(pairs, idCount{id: id, count: c})
-		total += c
-	}
-
-	sort.Slice(pairs, func(i, j int) bool {
-		if pairs[i].count == pairs[j].count {
-			return pairs[i].id < pairs[j].id
-		}
-		return pairs[i].count < pairs[j].count
-	})
-
-	n := len(pairs)
-	avg := float64(total) / float64(n)
-
-	log.Printf("[csv] relation %s (%s -> %s): %d %s; avg %.2f %s per %s",
-		name, aLabel, bLabel, n, aLabel, avg, bLabel, aLabel)
-
-	printSamples := func(label string, sample []idCount) {
-		log.Printf("[csv]   %s %s:", label, aLabel)
-		for _, p := range sample {
-			log.Printf("[csv]     %s=%d => %d %s", aLabel, p.id, p.count, bLabel)
-		}
-	}
-
-	// fewest
-	fewN := 3
-	if n < fewN {
-		fewN = n
-	}
-	printSamples("fewest", pairs[:fewN])
-
-	// typical (around median)
-	midIndices := []int{}
-	mid := n / 2
-	for delta := -1; delta <= 1; delta++ {
-		i := mid + delta
-		if i >= 0 && i < n {
-			midIndices = append(midIndices, i)
-		}
-	}
-	typ := make([]idCount, 0, len(midIndices))
-	seenIdx := make(map[int]struct{}, len(midIndices))
-	for _, i := range midIndices {
-		if _, ok := seenIdx[i]; ok {
-			continue
-		}
-		seenIdx[i] = struct{}{}
-		typ = append(typ, pairs[i])
-	}
-	printSamples("typical", typ)
-
-	// most
-	mostN := 3
-	if n < mostN {
-		mostN = n
-	}
-	printSamples("most", pairs[n-mostN:])
+	utils.SummarizeRelation("csv", name, aLabel, bLabel, counts)
 }
 
 func intMin(a, b int) int {
@@ -435,11 +421,21 @@ func pickBenchUsersFromUserResources(counts map[int]int) (heavy int, regular int
 		return 0, 0
 	}
 
-	type idCount struct {
-		id    int
-		count int
-	}
+	pairs := rankUserCounts(counts)
+	n := len(pairs)
+	heavy = pairs[n-1].id
+	regular = pairs[n/2].id // median-ish
+
+	return heavy, regular
+}
 
+type idCount struct {
+	id    int
+	count int
+}
+
+// rankUserCounts orders the users of counts by count, then id.
+func rankUserCounts(counts map[int]int) []idCount {
 	pairs := make([]idCount, 0, len(counts))
 	for id, c := range counts {
 		pairs = append(pairs, idCount{id: id, count: c})
@@ -451,15 +447,47 @@ func pickBenchUsersFromUserResources(counts map[int]int) (heavy int, regular int
 		}
 		return pairs[i].count < pairs[j].count
 	})
+	return pairs
+}
 
-	n := len(pairs)
-	heavy = pairs[n-1].id
-	regular = pairs[n/2].id // median-ish
+// benchPercentiles are the points of the direct grant count distribution a
+// lookup user is picked at for the lookup_resources_*_pct scenarios.
+var benchPercentiles = []int{10, 50, 90, 99}
 
-	return heavy, regular
+// pickPercentileUsers returns the user at each of benchPercentiles of counts
+// as "p10:<id>,p50:<id>,...", the format of BENCH_LOOKUPRES_*_PCT_USERS, or ""
+// when counts is empty.
+func pickPercentileUsers(counts map[int]int) string {
+	if len(counts) == 0 {
+		return ""
+	}
+	pairs := rankUserCounts(counts)
+	picks := make([]string, len(benchPercentiles))
+	for i, p := range benchPercentiles {
+		u := pairs[min(p*len(pairs)/100, len(pairs)-1)]
+		picks[i] = fmt.Sprintf("p%d:%s", p, ids.Format(ids.User, u.id))
+	}
+	return strings.Join(picks, ",")
 }
 
-// CsvCreateData generates relational ACL data into ./data/*.csv
+// addPercentileUsers records the percentile users of the manage (direct
+// manager_user grants) and view (any direct user grant) distributions in m.
+func addPercentileUsers(m *utils.DatasetManifest, managed, viewable map[int]int) {
+	for _, pick := range []struct {
+		name   string
+		counts map[int]int
+	}{
+		{"BENCH_LOOKUPRES_MANAGE_PCT_USERS", managed},
+		{"BENCH_LOOKUPRES_VIEW_PCT_USERS", viewable},
+	} {
+		if spec := pickPercentileUsers(pick.counts); spec != "" {
+			m.BenchUsers[pick.name] = spec
+			log.Printf("[csv] %s=%s", pick.name, spec)
+		}
+	}
+}
+
+// CsvCreateData generates relational ACL data into RLP_DATA_DIR/*.csv
 // with a heterogeneous, random graph structure suitable for Zanzibar/RLS benchmarks.
 func CsvCreateData() {
 	cfg := loadConfig()
@@ -476,11 +504,32 @@ func CsvCreateData() {
 		seed = time.Now().UnixNano()
 	}
 	r := rand.New(rand.NewSource(seed))
-
-	log.Printf("[csv] == Generating CSV data into ./data with config: %+v ==", cfg)
+	// Grant windows draw from their own source so enabling them leaves the
+	// rest of the graph identical for a given seed.
+	windows := newGrantWindows(cfg, rand.New(rand.NewSource(seed+1)), start)
+
+	dir := utils.DataDir()
+	if utils.InDatasetsDir(dir) {
+		// Archived datasets are kept as generated, so their ids stay valid.
+		log.Fatalf("[csv] %s is inside %s; generate into another --data-dir", dir, utils.DatasetsDir())
+	}
+	log.Printf("[csv] == Generating CSV data into %s with config: %+v ==", dir, cfg)
 	log.Printf("[csv] using random seed=%d", seed)
+	log.Printf("[csv] using id format=%s", ids.CurrentFormat())
 
-	sinks := newCsvSinks("data")
+	// Keep the dataset being replaced instead of overwriting it; `data clean`
+	// removes old ones.
+	if utils.GetEnvWithDefault("RLP_DATA_ARCHIVE", "true") == "true" {
+		id, err := utils.ArchiveDataset(dir)
+		if err != nil {
+			log.Fatalf("[csv] archive previous dataset: %v", err)
+		}
+		if id != "" {
+			log.Printf("[csv] previous dataset moved to %s", filepath.Join(utils.DatasetsDir(), id))
+		}
+	}
+
+	sinks := newCsvSinks(dir)
 	defer sinks.close()
 
 	// Headers
@@ -490,8 +539,9 @@ func CsvCreateData() {
 	writeRow(sinks.orgMembers, "org_id", "user_id", "role")
 	writeRow(sinks.groupMembers, "group_id", "user_id", "role")
 	writeRow(sinks.groupHierarchy, "parent_group_id", "child_group_id", "relation")
-	writeRow(sinks.resources, "resource_id", "org_id")
-	writeRow(sinks.resourceACL, "resource_id", "subject_type", "subject_id", "relation")
+	writeRow(sinks.resources, "resource_id", "org_id", "created_at", "updated_at")
+	writeRow(sinks.resourceACL, "resource_id", "subject_type", "subject_id", "relation", "valid_from", "valid_until", "created_at")
+	writeRow(sinks.resourceBans, "resource_id", "user_id")
 
 	var (
 		userCount            int
@@ -501,6 +551,7 @@ func CsvCreateData() {
 		groupHierarchyCount  int
 		resourceCount        int
 		aclCount             int
+		groupACLCount        int
 	)
 
 	// Relation counters (Zanzibar-style A -> B):
@@ -511,6 +562,7 @@ func CsvCreateData() {
 	userToGroups := make(map[int]int)       // 4. user -> groups
 	groupToChildGroups := make(map[int]int) // 4b. group -> child groups (hierarchy)
 	userToResources := make(map[int]int)    // 5. user -> resources
+	userToManaged := make(map[int]int)      // 5b. user -> resources (manager_user)
 	resourceToUsers := make(map[int]int)    // 6. resource -> users
 
 	// orgID -> []userID, orgID -> []groupID, orgID -> []resourceID
@@ -540,14 +592,14 @@ func CsvCreateData() {
 
 	// 2) organizations
 	for orgID := 1; orgID <= cfg.NumOrgs; orgID++ {
-		writeRow(sinks.orgs, strconv.Itoa(orgID))
+		writeRow(sinks.orgs, ids.Format(ids.Org, orgID))
 		orgToUsers[orgID] = 0
 	}
 
 	// 3) users (global user space)
 	for userID := 1; userID <= totalUsers; userID++ {
 		primaryOrgID := ((userID - 1) % cfg.NumOrgs) + 1
-		writeRow(sinks.users, strconv.Itoa(userID), strconv.Itoa(primaryOrgID))
+		writeRow(sinks.users, ids.Format(ids.User, userID), ids.Format(ids.Org, primaryOrgID))
 		userCount++
 	}
 
@@ -584,7 +636,7 @@ func CsvCreateData() {
 				role = "admin"
 			}
 
-			writeRow(sinks.orgMembers, strconv.Itoa(orgID), strconv.Itoa(userID), role)
+			writeRow(sinks.orgMembers, ids.Format(ids.Org, orgID), ids.Format(ids.User, userID), role)
 			orgMembershipCount++
 
 			orgToUsers[orgID]++
@@ -597,7 +649,7 @@ func CsvCreateData() {
 	for userID := 1; userID <= totalUsers; userID++ {
 		if userToOrgs[userID] == 0 {
 			orgID := ((userID - 1) % cfg.NumOrgs) + 1
-			writeRow(sinks.orgMembers, strconv.Itoa(orgID), strconv.Itoa(userID), "member")
+			writeRow(sinks.orgMembers, ids.Format(ids.Org, orgID), ids.Format(ids.User, userID), "member")
 			orgMembershipCount++
 			orgToUsers[orgID]++
 			userToOrgs[userID]++
@@ -616,7 +668,7 @@ func CsvCreateData() {
 			groupID := nextGroupID
 			nextGroupID++
 
-			writeRow(sinks.groups, strconv.Itoa(groupID), strconv.Itoa(orgID))
+			writeRow(sinks.groups, ids.Format(ids.Group, groupID), ids.Format(ids.Org, orgID))
 			groupCount++
 			groupToUsers[groupID] = 0
 			orgGroups[orgID] = append(orgGroups[orgID], groupID)
@@ -680,10 +732,10 @@ func CsvCreateData() {
 				}
 
 				if isManager {
-					writeRow(sinks.groupMembers, strconv.Itoa(groupID), strconv.Itoa(userID), "direct_manager")
+					writeRow(sinks.groupMembers, ids.Format(ids.Group, groupID), ids.Format(ids.User, userID), "direct_manager")
 					groupToManagers[groupID]++
 				} else {
-					writeRow(sinks.groupMembers, strconv.Itoa(groupID), strconv.Itoa(userID), "direct_member")
+					writeRow(sinks.groupMembers, ids.Format(ids.Group, groupID), ids.Format(ids.User, userID), "direct_member")
 					groupToUsers[groupID]++
 				}
 				groupMembershipCount++
@@ -733,13 +785,15 @@ func CsvCreateData() {
 				relation = "manager_group"
 			}
 
-			writeRow(sinks.groupHierarchy, strconv.Itoa(parentGroupID), strconv.Itoa(childGroupID), relation)
+			writeRow(sinks.groupHierarchy, ids.Format(ids.Group, parentGroupID), ids.Format(ids.Group, childGroupID), relation)
 			groupHierarchyCount++
 			groupToChildGroups[parentGroupID]++
 		}
 	}
 
-	// 8) resources (per-org heterogeneous counts)
+	// 8) resources (per-org heterogeneous counts). Timestamps draw from their
+	// own source too.
+	stamps := newTimestamps(cfg, rand.New(rand.NewSource(seed+3)), start)
 	nextResourceID := 1
 	for orgID := 1; orgID <= cfg.NumOrgs; orgID++ {
 		numResources := orgResourceCap[orgID]
@@ -750,12 +804,17 @@ func CsvCreateData() {
 			resourceID := nextResourceID
 			nextResourceID++
 
-			writeRow(sinks.resources, strconv.Itoa(resourceID), strconv.Itoa(orgID))
+			created, updated := stamps.resource(resourceID)
+			writeRow(sinks.resources, ids.Format(ids.Resource, resourceID), ids.Format(ids.Org, orgID), created, updated)
 			resourceCount++
 			orgResources[orgID] = append(orgResources[orgID], resourceID)
 		}
 	}
 
+	// Viral resources draw from their own source too, for the same reason.
+	viral := newViralResources(cfg, rand.New(rand.NewSource(seed+2)), resourceCount)
+	bans := newResourceBans(cfg, rand.New(rand.NewSource(seed+4)))
+
 	// 9) resource_acl: random ACL fan-out per resource
 	for orgID := 1; orgID <= cfg.NumOrgs; orgID++ {
 		usersInOrg := orgUsers[orgID]
@@ -771,6 +830,7 @@ func CsvCreateData() {
 
 		for _, resourceID := range resourcesInOrg {
 			seen := make(map[string]struct{})
+			var viewers []int // direct viewer_user grantees, for bans
 
 			addACL := func(subjectType string, subjectID int, relation string) {
 				key := fmt.Sprintf("%s|%d|%s|%d", subjectType, subjectID, relation, resourceID)
@@ -778,17 +838,33 @@ func CsvCreateData() {
 					return
 				}
 				seen[key] = struct{}{}
+				validFrom, validUntil := "", ""
+				if subjectType == "user" {
+					validFrom, validUntil = windows.next()
+				}
 				writeRow(
 					sinks.resourceACL,
-					strconv.Itoa(resourceID),
+					ids.Format(ids.Resource, resourceID),
 					subjectType,
-					strconv.Itoa(subjectID),
+					ids.Format(ids.SubjectKind(subjectType), subjectID),
 					relation,
+					validFrom,
+					validUntil,
+					stamps.grant(resourceID),
 				)
 				aclCount++
+				if subjectType == "group" {
+					groupACLCount++
+				}
 
 				if subjectType == "user" {
 					userToResources[subjectID]++
+					if relation == "manager_user" {
+						userToManaged[subjectID]++
+					}
+					if relation == "viewer_user" {
+						viewers = append(viewers, subjectID)
+					}
 					resourceToUsers[resourceID]++
 				}
 			}
@@ -848,6 +924,27 @@ func CsvCreateData() {
 				groupID := groupsInOrg[gIdx]
 				addACL("group", groupID, "viewer_group")
 			}
+
+			// Viral resources: viewer_user for a share of all users (direct
+			// fan-in). These grants are unbounded and kept out of the
+			// user->resources counts, so the picked bench users stay the same.
+			if viral.has(resourceID) {
+				for userID := 1; userID <= totalUsers; userID++ {
+					key := fmt.Sprintf("user|%d|viewer_user|%d", userID, resourceID)
+					if _, ok := seen[key]; ok || !viral.grant(userID) {
+						continue
+					}
+					seen[key] = struct{}{}
+					writeRow(sinks.resourceACL, ids.Format(ids.Resource, resourceID), "user", ids.Format(ids.User, userID), "viewer_user", "", "", stamps.grant(resourceID))
+					aclCount++
+				}
+			}
+
+			// Bans: banned_user denials overriding some of the direct
+			// viewer grants above (resource_bans.csv, see authz.BannedUser).
+			for _, userID := range bans.pick(viewers) {
+				writeRow(sinks.resourceBans, ids.Format(ids.Resource, resourceID), ids.Format(ids.User, userID))
+			}
 		}
 	}
 
@@ -862,6 +959,9 @@ func CsvCreateData() {
 	log.Printf("[csv] group_hierarchy:      %d", groupHierarchyCount)
 	log.Printf("[csv] resources:            %d", resourceCount)
 	log.Printf("[csv] resource_acl entries: %d", aclCount)
+	log.Printf("[csv] time-bounded grants:  %d (expired=%d)", windows.bounded, windows.expired)
+	log.Printf("[csv] viral grants:         %d (resources=%d)", viral.total, len(viral.picked))
+	log.Printf("[csv] banned users:         %d (resources=%d)", bans.total, bans.resources)
 
 	// Zanzibar-style relation breakdown logs
 	summarizeRelation("org->users", "org_id", "users", orgToUsers)
@@ -874,11 +974,258 @@ func CsvCreateData() {
 	summarizeRelation("resource->users", "resource_id", "users", resourceToUsers)
 
 	// Pick bench users for lookup_resources benchmarks
+	manifest := utils.DatasetManifest{
+		GeneratedAt: start.UTC().Truncate(time.Second),
+		Seed:        seed,
+		IDFormat:    ids.CurrentFormat(),
+		BenchUsers:  map[string]string{},
+		DatasetRows: utils.DatasetRows{
+			Rows: map[string]int{
+				"organizations":     cfg.NumOrgs,
+				"users":             userCount,
+				"groups":            groupCount,
+				"org_memberships":   orgMembershipCount,
+				"group_memberships": groupMembershipCount,
+				"group_hierarchy":   groupHierarchyCount,
+				"resources":         resourceCount,
+				"resource_acl":      aclCount,
+				"resource_bans":     bans.total,
+			},
+			GroupGrants: groupACLCount,
+		},
+	}
 	heavy, regular := pickBenchUsersFromUserResources(userToResources)
 	if heavy != 0 {
-		log.Printf("[csv] BENCH_LOOKUPRES_MANAGE_USER=%d", heavy)
+		manifest.BenchUsers["BENCH_LOOKUPRES_MANAGE_USER"] = ids.Format(ids.User, heavy)
+		log.Printf("[csv] BENCH_LOOKUPRES_MANAGE_USER=%s", ids.Format(ids.User, heavy))
 	}
 	if regular != 0 {
-		log.Printf("[csv] BENCH_LOOKUPRES_VIEW_USER=%d", regular)
+		manifest.BenchUsers["BENCH_LOOKUPRES_VIEW_USER"] = ids.Format(ids.User, regular)
+		log.Printf("[csv] BENCH_LOOKUPRES_VIEW_USER=%s", ids.Format(ids.User, regular))
+	}
+	addPercentileUsers(&manifest, userToManaged, userToResources)
+	if len(viral.picked) > 0 {
+		resources := make([]string, len(viral.picked))
+		for i, id := range viral.picked {
+			resources[i] = ids.Format(ids.Resource, id)
+		}
+		manifest.BenchUsers["BENCH_VIRAL_RESOURCES"] = strings.Join(resources, ",")
+		log.Printf("[csv] BENCH_VIRAL_RESOURCES=%s", manifest.BenchUsers["BENCH_VIRAL_RESOURCES"])
+	}
+	if user := viral.user(); user != 0 {
+		manifest.BenchUsers["BENCH_LOOKUPRES_VIRAL_USER"] = ids.Format(ids.User, user)
+		log.Printf("[csv] BENCH_LOOKUPRES_VIRAL_USER=%s", ids.Format(ids.User, user))
+	}
+	writeBenchUsers(manifest)
+}
+
+// writeBenchUsers records the picked bench users where RLP_WRITE_BENCH_USERS
+// asks for them: "manifest" (manifest.json of the dataset), "env" (.env.bench) or both,
+// comma-separated. Unset only logs them, as before.
+func writeBenchUsers(m utils.DatasetManifest) {
+	for _, target := range strings.Split(os.Getenv("RLP_WRITE_BENCH_USERS"), ",") {
+		switch strings.TrimSpace(target) {
+		case "":
+		case "manifest":
+			if err := utils.WriteManifest(m); err != nil {
+				log.Fatalf("[csv] write %s: %v", utils.ManifestPath(), err)
+			}
+			log.Printf("[csv] bench users written to %s", utils.ManifestPath())
+		case "env":
+			if err := utils.WriteBenchEnv(m); err != nil {
+				log.Fatalf("[csv] write %s: %v", utils.BenchEnvPath, err)
+			}
+			log.Printf("[csv] bench users written to %s", utils.BenchEnvPath)
+		default:
+			log.Fatalf("[csv] unknown RLP_WRITE_BENCH_USERS target %q (expected manifest|env)", target)
+		}
+	}
+}
+
+// grantWindows assigns valid_from/valid_until to a fraction of direct user
+// grants: expired windows ended 1-30 days before generation (soft deletes),
+// active ones started 1-90 days before and end 1-90 days after.
+type grantWindows struct {
+	fraction float64
+	expShare float64
+	r        *rand.Rand
+	now      time.Time
+
+	bounded int
+	expired int
+}
+
+func newGrantWindows(cfg config, r *rand.Rand, now time.Time) *grantWindows {
+	return &grantWindows{
+		fraction: cfg.ExpiringGrantFraction,
+		expShare: cfg.ExpiredGrantShare,
+		r:        r,
+		now:      now.UTC().Truncate(time.Second),
+	}
+}
+
+// next returns the window for the next direct user grant, or two empty
+// strings when the grant is unbounded.
+func (g *grantWindows) next() (string, string) {
+	if g.fraction <= 0 || g.r.Float64() >= g.fraction {
+		return "", ""
+	}
+	day := 24 * time.Hour
+	g.bounded++
+
+	var from, until time.Time
+	if g.r.Float64() < g.expShare {
+		g.expired++
+		until = g.now.Add(-time.Duration(randInRange(g.r, 1, 30)) * day)
+		from = until.Add(-time.Duration(randInRange(g.r, 30, 180)) * day)
+	} else {
+		from = g.now.Add(-time.Duration(randInRange(g.r, 0, 90)) * day)
+		until = g.now.Add(time.Duration(randInRange(g.r, 1, 90)) * day)
+	}
+	return from.Format(time.RFC3339), until.Format(time.RFC3339)
+}
+
+// timestamps assigns created_at/updated_at to resources and created_at to
+// grants, within RLP_HISTORY_DAYS before generation. Creation follows a
+// growing system: the density of new resources rises linearly towards
+// generation, so recent days hold more than old ones. 40% of the resources
+// were edited later (updated_at after created_at). 70% of the grants are made
+// within the hour after their resource is created; the rest trickle in until
+// generation, as sharing does.
+type timestamps struct {
+	r       *rand.Rand
+	now     time.Time
+	history time.Duration
+	created []time.Time // created_at by resource id
+}
+
+func newTimestamps(cfg config, r *rand.Rand, now time.Time) *timestamps {
+	return &timestamps{
+		r:       r,
+		now:     now.UTC().Truncate(time.Second),
+		history: time.Duration(cfg.HistoryDays) * 24 * time.Hour,
+		created: []time.Time{{}}, // ids start at 1
+	}
+}
+
+// resource returns created_at and updated_at of the next resource, which
+// must be resourceID.
+func (t *timestamps) resource(resourceID int) (string, string) {
+	age := time.Duration(float64(t.history) * (1 - math.Sqrt(t.r.Float64())))
+	created := t.now.Add(-age).Truncate(time.Second)
+	t.created = append(t.created, created)
+	updated := created
+	if t.r.Float64() < 0.4 {
+		updated = t.between(created, t.now)
+	}
+	return created.Format(time.RFC3339), updated.Format(time.RFC3339)
+}
+
+// grant returns created_at of a grant on resourceID.
+func (t *timestamps) grant(resourceID int) string {
+	created := t.created[resourceID]
+	until := t.now
+	if t.r.Float64() < 0.7 && created.Add(time.Hour).Before(t.now) {
+		until = created.Add(time.Hour)
+	}
+	return t.between(created, until).Format(time.RFC3339)
+}
+
+// between returns a random second in [from, until].
+func (t *timestamps) between(from, until time.Time) time.Time {
+	span := int64(until.Sub(from) / time.Second)
+	if span <= 0 {
+		return from
+	}
+	return from.Add(time.Duration(t.r.Int63n(span+1)) * time.Second)
+}
+
+// viralResources picks RLP_VIRAL_RESOURCES resources at random and decides,
+// user by user, which of all users get a direct viewer_user grant on them.
+type viralResources struct {
+	fraction float64
+	r        *rand.Rand
+	picked   []int // sorted
+	ids      map[int]struct{}
+	grants   map[int]int // userID -> viral grants
+
+	total int
+}
+
+func newViralResources(cfg config, r *rand.Rand, numResources int) *viralResources {
+	v := &viralResources{
+		fraction: cfg.ViralUserFraction,
+		r:        r,
+		ids:      make(map[int]struct{}),
+		grants:   make(map[int]int),
+	}
+	for len(v.picked) < intMin(cfg.ViralResources, numResources) {
+		id := r.Intn(numResources) + 1
+		if _, ok := v.ids[id]; ok {
+			continue
+		}
+		v.ids[id] = struct{}{}
+		v.picked = append(v.picked, id)
+	}
+	sort.Ints(v.picked)
+	return v
+}
+
+func (v *viralResources) has(resourceID int) bool {
+	_, ok := v.ids[resourceID]
+	return ok
+}
+
+// grant draws whether userID gets viewer_user on the current viral resource.
+func (v *viralResources) grant(userID int) bool {
+	if v.r.Float64() >= v.fraction {
+		return false
+	}
+	v.grants[userID]++
+	v.total++
+	return true
+}
+
+// user returns the user with the most viral grants (lowest id on ties), the
+// lookup_resources_view_viral bench user, or 0 without viral grants.
+func (v *viralResources) user() int {
+	best := 0
+	for id, n := range v.grants {
+		if n > v.grants[best] || (n == v.grants[best] && id < best) {
+			best = id
+		}
+	}
+	return best
+}
+
+// resourceBans draws the resources that get banned_user denials
+// (RLP_BANNED_RESOURCE_FRACTION) and, on each, which of its direct viewers
+// are banned (up to RLP_BANNED_USERS_PER_RESOURCE), so every ban overrides a
+// grant the check workloads draw from.
+type resourceBans struct {
+	fraction    float64
+	perResource int
+	r           *rand.Rand
+
+	total     int
+	resources int
+}
+
+func newResourceBans(cfg config, r *rand.Rand) *resourceBans {
+	return &resourceBans{fraction: cfg.BannedResourceFraction, perResource: cfg.BannedUsersPerResource, r: r}
+}
+
+// pick returns the viewers banned on the current resource, none for most.
+func (b *resourceBans) pick(viewers []int) []int {
+	if b.fraction <= 0 || len(viewers) == 0 || b.r.Float64() >= b.fraction {
+		return nil
+	}
+	n := intMin(b.perResource, len(viewers))
+	banned := make([]int, n)
+	for i, j := range b.r.Perm(len(viewers))[:n] {
+		banned[i] = viewers[j]
 	}
+	b.resources++
+	b.total += n
+	return banned
 }
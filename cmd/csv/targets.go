@@ -0,0 +1,280 @@
+package csv
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"test-tls/utils"
+)
+
+// target is one request against the serve API (see utils.Serve).
+type target struct {
+	Scenario string `json:"scenario"`
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+}
+
+// CsvGenerateTargets writes request target files for external load generators
+// from the dataset CSVs, sampling check and lookup calls the same way the
+// relationship-driven benchmarks do:
+//
+//	check_manage_direct_user:    manager_user ACL rows, in file order
+//	check_manage_org_admin:      resources, checked as the first admin of their org
+//	check_view_via_group_member: viewer_group ACL rows, checked as the first
+//	                             direct_member (else direct_manager) of the group
+//	lookup_resources_*:          BENCH_LOOKUPRES_MANAGE_USER / BENCH_LOOKUPRES_VIEW_USER
+//
+// Iteration counts come from the same BENCH_* env vars as the benchmarks.
+// With TARGETS_AUDIT_FILE set, the targets are instead the exact checks and
+// lookups of a recorded benchmark run (BENCH_AUDIT_DIR), in order, so one
+// backend's request stream can be replayed against another over HTTP.
+// Output (in TARGETS_OUT_DIR, default "targets"):
+//
+//	vegeta.txt  vegeta HTTP format (vegeta attack -targets=targets/vegeta.txt)
+//	k6.json     JSON array of {scenario, method, url} for a k6 SharedArray
+//
+// Env vars:
+//
+//	TARGETS_BASE_URL   (default: "http://localhost:8080")
+//	TARGETS_OUT_DIR    (default: "targets")
+//	TARGETS_AUDIT_FILE (optional: <backend>-<ts>.ndjson.gz written by a benchmark)
+func CsvGenerateTargets() {
+	start := time.Now()
+	baseURL := strings.TrimRight(utils.GetEnvWithDefault("TARGETS_BASE_URL", "http://localhost:8080"), "/")
+	outDir := utils.GetEnvWithDefault("TARGETS_OUT_DIR", "targets")
+
+	log.Printf("[csv] == Generating load targets from %s for %s ==", utils.DataDir(), baseURL)
+
+	var targets []target
+	check := func(scenario, resourceID, userID, permission string) {
+		q := url.Values{"resource_id": {resourceID}, "user_id": {userID}, "permission": {permission}}
+		targets = append(targets, target{Scenario: scenario, Method: "GET", URL: baseURL + "/v1/check?" + q.Encode()})
+	}
+
+	lookup := func(scenario, userID, permission string) {
+		q := url.Values{"user_id": {userID}, "permission": {permission}, "limit": {"0"}}
+		targets = append(targets, target{Scenario: scenario, Method: "GET", URL: baseURL + "/v1/lookup?" + q.Encode()})
+	}
+
+	if auditFile := os.Getenv("TARGETS_AUDIT_FILE"); auditFile != "" {
+		replayAuditTargets(auditFile, check, lookup)
+	} else {
+		sampleChecks(check)
+		sampleLookups(lookup)
+	}
+
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		log.Fatalf("[csv] failed to create targets dir %q: %v", outDir, err)
+	}
+	writeVegetaTargets(filepath.Join(outDir, "vegeta.txt"), targets)
+	writeK6Targets(filepath.Join(outDir, "k6.json"), targets)
+
+	elapsed := time.Since(start).Truncate(time.Millisecond)
+	log.Printf("[csv] Load target generation DONE: targets=%d dir=%s elapsed=%s", len(targets), outDir, elapsed)
+}
+
+// sampleChecks emits the check_* targets sampled from the dataset CSVs.
+func sampleChecks(check func(scenario, resourceID, userID, permission string)) {
+	orgAdmins := firstUserByKey("org_memberships.csv", func(rec []string) bool { return rec[2] == "admin" })
+	groupMembers := firstUserByKey("group_memberships.csv", func(rec []string) bool { return rec[2] == "direct_member" })
+	groupManagers := firstUserByKey("group_memberships.csv", func(rec []string) bool { return rec[2] == "direct_manager" })
+
+	// resource_acl.csv: resource_id,subject_type,subject_id,relation
+	cycleCSV("check_manage_direct_user", "resource_acl.csv", utils.GetEnvInt("BENCH_CHECK_DIRECT_SUPER_ITER", 1000), func(rec []string) bool {
+		if rec[1] != "user" || (rec[3] != "manager_user" && rec[3] != "manager") {
+			return false
+		}
+		check("check_manage_direct_user", rec[0], rec[2], "manage")
+		return true
+	})
+
+	// resources.csv: resource_id,org_id
+	cycleCSV("check_manage_org_admin", "resources.csv", utils.GetEnvInt("BENCH_CHECK_ORGADMIN_ITER", 1000), func(rec []string) bool {
+		admin, ok := orgAdmins[rec[1]]
+		if !ok {
+			return false
+		}
+		check("check_manage_org_admin", rec[0], admin, "manage")
+		return true
+	})
+
+	cycleCSV("check_view_via_group_member", "resource_acl.csv", utils.GetEnvInt("BENCH_CHECK_VIEW_GROUP_ITER", 1000), func(rec []string) bool {
+		if rec[1] != "group" || (rec[3] != "viewer_group" && rec[3] != "viewer") {
+			return false
+		}
+		user, ok := groupMembers[rec[2]]
+		if !ok {
+			if user, ok = groupManagers[rec[2]]; !ok {
+				return false
+			}
+		}
+		check("check_view_via_group_member", rec[0], user, "view")
+		return true
+	})
+}
+
+// sampleLookups emits the lookup_resources_* targets for the BENCH_LOOKUPRES_*
+// users, BENCH_LOOKUPRES_*_ITER times each.
+func sampleLookups(lookup func(scenario, userID, permission string)) {
+	for _, l := range []struct{ scenario, userEnv, permission, iterEnv string }{
+		{"lookup_resources_manage_super", "BENCH_LOOKUPRES_MANAGE_USER", "manage", "BENCH_LOOKUPRES_MANAGE_ITER"},
+		{"lookup_resources_view_regular", "BENCH_LOOKUPRES_VIEW_USER", "view", "BENCH_LOOKUPRES_VIEW_ITER"},
+	} {
+		userID := os.Getenv(l.userEnv)
+		if userID == "" {
+			log.Printf("[csv] [%s] skipped: %s not set", l.scenario, l.userEnv)
+			continue
+		}
+		iters := utils.GetEnvInt(l.iterEnv, 10)
+		for range iters {
+			lookup(l.scenario, userID, l.permission)
+		}
+		log.Printf("[csv] [%s] targets=%d user=%s", l.scenario, iters, userID)
+	}
+}
+
+// replayAuditTargets emits one target per record of a benchmark audit file
+// (see utils.AuditRecord), keeping the recorded order and scenario.
+func replayAuditTargets(path string, check func(scenario, resourceID, userID, permission string), lookup func(scenario, userID, permission string)) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("[csv] open audit file %s: %v", path, err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		log.Fatalf("[csv] %s: gzip: %v", path, err)
+	}
+	defer zr.Close()
+
+	var scenarios []string
+	counts := make(map[string]int)
+	dec := json.NewDecoder(zr)
+	for {
+		var rec utils.AuditRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			log.Fatalf("[csv] %s: read failed: %v", path, err)
+		}
+		if rec.IsLookup() {
+			lookup(rec.Scenario, rec.UserID, rec.Permission)
+		} else {
+			check(rec.Scenario, rec.ResourceID, rec.UserID, rec.Permission)
+		}
+		if counts[rec.Scenario] == 0 {
+			scenarios = append(scenarios, rec.Scenario)
+		}
+		counts[rec.Scenario]++
+	}
+	for _, scenario := range scenarios {
+		log.Printf("[csv] [%s] targets=%d (replayed from %s)", scenario, counts[scenario], path)
+	}
+}
+
+// openDataCSV opens <name> in RLP_DATA_DIR, restricted to the org scope (RLP_ORGS),
+// and skips its header row.
+func openDataCSV(name string) (*csv.Reader, *os.File) {
+	full := filepath.Join(utils.DataDir(), name)
+	f, err := os.Open(full)
+	if err != nil {
+		log.Fatalf("[csv] open %s (run `csv generate` first): %v", full, err)
+	}
+	r := utils.ScopeCSV(name, csv.NewReader(f))
+	r.ReuseRecord = true
+	if _, err := r.Read(); err != nil {
+		f.Close()
+		log.Fatalf("[csv] %s: read header failed: %v", full, err)
+	}
+	return r, f
+}
+
+// firstUserByKey maps the first column of a membership file to the user_id of
+// its first row accepted by keep, mirroring the benchmarks' "LIMIT 1" picks.
+func firstUserByKey(name string, keep func(rec []string) bool) map[string]string {
+	r, f := openDataCSV(name)
+	defer f.Close()
+
+	out := make(map[string]string)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatalf("[csv] %s: read failed: %v", name, err)
+		}
+		if _, seen := out[rec[0]]; seen || !keep(rec) {
+			continue
+		}
+		out[rec[0]] = rec[1]
+	}
+	return out
+}
+
+// cycleCSV streams name until emit has accepted iters rows, restarting from the
+// top when the file runs out, as the benchmarks restart their row streams.
+func cycleCSV(scenario, name string, iters int, emit func(rec []string) bool) {
+	done := 0
+	for done < iters {
+		r, f := openDataCSV(name)
+		streamed := 0
+		for done < iters {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				log.Fatalf("[csv] [%s] %s: read failed: %v", scenario, name, err)
+			}
+			if emit(rec) {
+				streamed++
+				done++
+			}
+		}
+		f.Close()
+		if streamed == 0 {
+			log.Printf("[csv] [%s] no matching rows in %s, stopping at %d targets", scenario, name, done)
+			return
+		}
+	}
+	log.Printf("[csv] [%s] targets=%d", scenario, done)
+}
+
+func writeVegetaTargets(path string, targets []target) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("[csv] failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+
+	for _, t := range targets {
+		if _, err := fmt.Fprintf(f, "%s %s\n\n", t.Method, t.URL); err != nil {
+			log.Fatalf("[csv] failed to write %s: %v", path, err)
+		}
+	}
+}
+
+func writeK6Targets(path string, targets []target) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("[csv] failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(targets); err != nil {
+		log.Fatalf("[csv] failed to write %s: %v", path, err)
+	}
+}
@@ -0,0 +1,157 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"time"
+
+	esv9 "github.com/elastic/go-elasticsearch/v9"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// ElasticsearchAnalyzeStats reports the document count, per-relation ACL
+// cardinality and the resource-side fan-out summaries printed by `csv generate`,
+// computed from the indexed documents so drift between the CSV dataset and
+// the index is visible. The index only holds resource documents, so the
+// org/group relations are reported as "no data".
+func ElasticsearchAnalyzeStats() {
+	ctx := context.Background()
+	es, cleanup, err := infrastructure.NewElasticsearchFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[elasticsearch] failed to create client: %v", err)
+	}
+	defer cleanup()
+
+	start := time.Now()
+	stats := utils.NewRelationStats()
+
+	type aclCard struct {
+		edges     int
+		subjects  map[int]struct{}
+		resources map[int]struct{}
+	}
+	cards := make(map[string]*aclCard)
+	var manageEdges, viewEdges int
+	docs := 0
+
+	err = scrollDocs(ctx, es, func(doc resourceDoc) {
+		docs++
+		manageEdges += len(doc.AllowedManageUserID)
+		viewEdges += len(doc.AllowedViewUserID)
+		for _, a := range doc.ACL {
+			key := a.SubjectType + "/" + a.Relation
+			c, ok := cards[key]
+			if !ok {
+				c = &aclCard{subjects: make(map[int]struct{}), resources: make(map[int]struct{})}
+				cards[key] = c
+			}
+			c.edges++
+			c.subjects[a.SubjectID] = struct{}{}
+			c.resources[doc.ResourceID] = struct{}{}
+			if a.SubjectType == "user" {
+				stats.UserToResources[a.SubjectID]++
+				stats.ResourceToUsers[doc.ResourceID]++
+			}
+		}
+	})
+	if err != nil {
+		log.Fatalf("[elasticsearch] stats: scroll %s failed: %v", IndexName(), err)
+	}
+
+	log.Printf("[elasticsearch] index %s: %d documents", IndexName(), docs)
+	log.Printf("[elasticsearch] expanded allowed_manage_user_id=%d allowed_view_user_id=%d entries", manageEdges, viewEdges)
+
+	keys := make([]string, 0, len(cards))
+	for k := range cards {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		c := cards[k]
+		log.Printf("[elasticsearch] acl %s: %d edges, %d distinct subjects, %d distinct resources",
+			k, c.edges, len(c.subjects), len(c.resources))
+	}
+
+	stats.Summarize("elasticsearch")
+
+	log.Printf("[elasticsearch] analyze stats DONE elapsed=%s", time.Since(start).Truncate(time.Millisecond))
+}
+
+// scrollDocs walks every resource document with the scroll API, which unlike
+// from+size paging is not capped by index.max_result_window.
+func scrollDocs(ctx context.Context, es *esv9.Client, handle func(doc resourceDoc)) error {
+	type page struct {
+		ScrollID string `json:"_scroll_id"`
+		Hits     struct {
+			Hits []struct {
+				Source resourceDoc `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+
+	decode := func(body io.ReadCloser, isErr bool, status string) (page, error) {
+		defer body.Close()
+		var p page
+		if isErr {
+			return p, fmt.Errorf("status %s", status)
+		}
+		if err := json.NewDecoder(body).Decode(&p); err != nil {
+			return p, fmt.Errorf("decode scroll body: %w", err)
+		}
+		return p, nil
+	}
+
+	res, err := es.Search(
+		es.Search.WithContext(ctx),
+		es.Search.WithIndex(IndexName()),
+		es.Search.WithBody(bytes.NewReader(matchAllQuery())),
+		es.Search.WithSize(esBulkBatchSize),
+		es.Search.WithScroll(time.Minute),
+	)
+	if err != nil {
+		return err
+	}
+	p, err := decode(res.Body, res.IsError(), res.Status())
+	if err != nil {
+		return err
+	}
+
+	scrollID := p.ScrollID
+	defer func() {
+		if scrollID == "" {
+			return
+		}
+		if res, err := es.ClearScroll(es.ClearScroll.WithScrollID(scrollID)); err == nil {
+			res.Body.Close()
+		}
+	}()
+
+	for len(p.Hits.Hits) > 0 {
+		for _, h := range p.Hits.Hits {
+			handle(h.Source)
+		}
+
+		res, err := es.Scroll(
+			es.Scroll.WithContext(ctx),
+			es.Scroll.WithScrollID(scrollID),
+			es.Scroll.WithScroll(time.Minute),
+		)
+		if err != nil {
+			return err
+		}
+		if p, err = decode(res.Body, res.IsError(), res.Status()); err != nil {
+			return err
+		}
+		if p.ScrollID != "" {
+			scrollID = p.ScrollID
+		}
+	}
+	return nil
+}
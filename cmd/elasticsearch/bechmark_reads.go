@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
-	"os"
+	"strconv"
 	"time"
 
 	esv9 "github.com/elastic/go-elasticsearch/v9"
 
+	esauthz "test-tls/authz/elasticsearch"
+	"test-tls/ids"
 	"test-tls/infrastructure"
 	"test-tls/utils"
 )
@@ -24,31 +28,68 @@ func ElasticsearchBenchmarkReads() {
 		log.Fatalf("[elasticsearch] failed to create client: %v", err)
 	}
 	defer cleanup()
+	utils.GuardDataset("elasticsearch", datasetStore{es})
+	stopAudit := utils.StartAudit("elasticsearch")
+	defer stopAudit()
 
 	start := time.Now()
-	heavyManageUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
-	regularViewUser := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	heavyManageUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
+	regularViewUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 	log.Printf("[elasticsearch] Running in streaming-only mode (no precollection). elapsed=%s heavyManageUser=%q regularViewUser=%q", elapsed, heavyManageUser, regularViewUser)
-
-	runCheckManageDirectUser(es)
-	runCheckManageOrgAdmin(es)
-	runCheckViewViaGroupMember(es)
-	runLookupResourcesManageHeavyUser(es)
-	runLookupResourcesViewRegularUser(es)
+	log.Printf("[elasticsearch] SCHEMA: index=%s paging=%s", IndexName(), utils.GetEnvWithDefault("ES_LOOKUP_PAGING", "search_after"))
+
+	stopCost := utils.StartCost("elasticsearch", costMeter(es))
+	utils.RunCachePhases("elasticsearch", flushCaches(es), func() {
+		runCheckManageDirectUser(es)
+		runCheckManageOrgAdmin(es)
+		runCheckViewViaGroupMember(es)
+		runCheckTimeBoundedDirectUser(es)
+		runLookupResourcesManageHeavyUser(es)
+		runLookupResourcesViewRegularUser(es)
+		runLookupResourcesMix(es)
+		runLookupResourcesPercentiles(es)
+		runViralFanIn(es)
+		runWorstCaseChecks(es)
+		runWorkload(es)
+		runAccessSummary(es)
+		runMiddlewareOverhead(es)
+	})
+	runFullExport(es)
+	stopCost()
 
 	log.Println("[elasticsearch] == Elasticsearch read benchmarks DONE ==")
 }
 
+// flushCaches clears the query, request and fielddata caches of the rlp index
+// for the cold pass of BENCH_CACHE_COMPARE.
+func flushCaches(es *esv9.Client) utils.CacheFlush {
+	return func(ctx context.Context) error {
+		res, err := es.Indices.ClearCache(
+			es.Indices.ClearCache.WithContext(ctx),
+			es.Indices.ClearCache.WithIndex(IndexName()),
+		)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.IsError() {
+			return fmt.Errorf("clear cache: %s", res.Status())
+		}
+		return nil
+	}
+}
+
 // === Scenarios ===
 
 // runCheckManageDirectUser: stream resources where user has direct manage via allowed_manage_user_id
 func runCheckManageDirectUser(es *esv9.Client) {
 	iters := utils.GetEnvInt("BENCH_CHECK_DIRECT_SUPER_ITER", 1000)
 	log.Printf("[elasticsearch] [check_manage_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("elasticsearch", "check_manage_direct_user", iters, 0)
 
 	// If a heavy manage user is specified, iterate via that user and verify manage permission
-	user := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	user := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 	done := 0
 
@@ -62,7 +103,7 @@ func runCheckManageDirectUser(es *esv9.Client) {
 			start := time.Now()
 			// Single GET by id to simulate small per-item check (optional): skip to avoid extra cost
 			dur := time.Since(start)
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[elasticsearch] [check_manage_direct_user] lookup iter=%d resource=%s user=%s dur=%s", done, resID, user, dur)
 			}
 			done++
@@ -80,7 +121,7 @@ func runCheckManageDirectUser(es *esv9.Client) {
 			// We cannot extract array contents without source; rely on existence and count
 			start := time.Now()
 			dur := time.Since(start)
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[elasticsearch] [check_manage_direct_user] iter=%d resource=%s dur=%s", done, resID, dur)
 			}
 			done++
@@ -93,8 +134,9 @@ func runCheckManageDirectUser(es *esv9.Client) {
 func runCheckManageOrgAdmin(es *esv9.Client) {
 	iters := utils.GetEnvInt("BENCH_CHECK_ORGADMIN_ITER", 1000)
 	log.Printf("[elasticsearch] [check_manage_org_admin] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("elasticsearch", "check_manage_org_admin", iters, 0)
 
-	user := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	user := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 	done := 0
 
@@ -106,7 +148,7 @@ func runCheckManageOrgAdmin(es *esv9.Client) {
 			}
 			start := time.Now()
 			dur := time.Since(start)
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[elasticsearch] [check_manage_org_admin] lookup iter=%d resource=%s user=%s dur=%s", done, resID, user, dur)
 			}
 			done++
@@ -119,7 +161,7 @@ func runCheckManageOrgAdmin(es *esv9.Client) {
 			}
 			start := time.Now()
 			dur := time.Since(start)
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[elasticsearch] [check_manage_org_admin] iter=%d resource=%s dur=%s", done, resID, dur)
 			}
 			done++
@@ -132,8 +174,9 @@ func runCheckManageOrgAdmin(es *esv9.Client) {
 func runCheckViewViaGroupMember(es *esv9.Client) {
 	iters := utils.GetEnvInt("BENCH_CHECK_VIEW_GROUP_ITER", 1000)
 	log.Printf("[elasticsearch] [check_view_via_group_member] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("elasticsearch", "check_view_via_group_member", iters, 0)
 
-	user := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	user := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 	done := 0
 
@@ -144,7 +187,7 @@ func runCheckViewViaGroupMember(es *esv9.Client) {
 			}
 			start := time.Now()
 			dur := time.Since(start)
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[elasticsearch] [check_view_via_group_member] lookup iter=%d resource=%s user=%s dur=%s", done, resID, user, dur)
 			}
 			done++
@@ -156,7 +199,7 @@ func runCheckViewViaGroupMember(es *esv9.Client) {
 			}
 			start := time.Now()
 			dur := time.Since(start)
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[elasticsearch] [check_view_via_group_member] iter=%d resource=%s dur=%s", done, resID, dur)
 			}
 			done++
@@ -165,49 +208,176 @@ func runCheckViewViaGroupMember(es *esv9.Client) {
 	log.Printf("[elasticsearch] [check_view_via_group_member] DONE: iters=%d", iters)
 }
 
+// runCheckTimeBoundedDirectUser streams direct user grants carrying a validity
+// window from the nested acl entries and checks each with a count query at
+// now, so the expired (soft deleted) share of the sample is denied.
+func runCheckTimeBoundedDirectUser(es *esv9.Client) {
+	iters := utils.GetEnvInt("BENCH_CHECK_TIME_BOUNDED_ITER", 1000)
+	log.Printf("[elasticsearch] [check_time_bounded_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("elasticsearch", "check_time_bounded_direct_user", iters, 2*time.Second)
+	done, allowed := 0, 0
+	errs := utils.NewErrorTally()
+
+	for done < iters {
+		streamed := 0
+		err := scrollTimeBoundedGrants(context.Background(), es, func(resID, userID int, relation string) {
+			if done >= iters {
+				return
+			}
+			streamed++
+
+			if !utils.InOrgScope(ids.Resource, resID) {
+				return
+			}
+
+			cstart := time.Now()
+			qctx, qcancel := context.WithTimeout(context.Background(), 2*time.Second)
+			ok, err := checkTimeBounded(qctx, es, resID, userID, relation)
+			qcancel()
+			utils.AuditCheck("check_time_bounded_direct_user", resID, userID, relation, ok, time.Since(cstart), err)
+			if err != nil {
+				class := errs.Record(err)
+				log.Printf("[elasticsearch] [check_time_bounded_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+				done++
+				return
+			}
+			dur := time.Since(cstart)
+			if ok {
+				allowed++
+			}
+			if utils.LogIteration(done, 100) {
+				log.Printf("[elasticsearch] [check_time_bounded_direct_user] iter=%d resource=%d user=%d allowed=%t dur=%s", done, resID, userID, ok, dur)
+			}
+			done++
+		})
+		if err != nil {
+			errs.Survive(err, iters, "[elasticsearch] [check_time_bounded_direct_user] search failed: %v", err)
+			continue
+		}
+		if streamed == 0 {
+			log.Printf("[elasticsearch] [check_time_bounded_direct_user] skipped: no time-bounded grants (generate with RLP_EXPIRING_GRANT_FRACTION)")
+			break
+		}
+	}
+	log.Printf("[elasticsearch] [check_time_bounded_direct_user] DONE: iters=%d allowed=%d denied=%d", done, allowed, done-allowed-errs.Total())
+	log.Printf("[elasticsearch] [check_time_bounded_direct_user] ERRORS: %s", errs.Summary(done))
+}
+
+// checkTimeBounded counts the resource when a nested acl entry grants relation
+// to the user directly and its window (open bounds allowed) covers now.
+func checkTimeBounded(ctx context.Context, es *esv9.Client, resID, userID int, relation string) (bool, error) {
+	res, err := es.Count(
+		es.Count.WithContext(ctx),
+		es.Count.WithIndex(IndexName()),
+		es.Count.WithBody(bytes.NewReader([]byte(timeBoundedQuery(resID, userID, relation)))),
+	)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return false, fmt.Errorf("count error: %s", res.Status())
+	}
+	var body struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return false, fmt.Errorf("decode count body: %w", err)
+	}
+	return body.Count > 0, nil
+}
+
+// timeBoundedQuery is the _count body of checkTimeBounded.
+func timeBoundedQuery(resID, userID int, relation string) string {
+	return fmt.Sprintf(`{"query":{"bool":{"filter":[
+		{"term":{"resource_id":%d}},
+		{"nested":{"path":"acl","query":{"bool":{
+			"filter":[
+				{"term":{"acl.subject_type":"user"}},
+				{"term":{"acl.subject_id":%d}},
+				{"term":{"acl.relation":%q}}
+			],
+			"must_not":[
+				{"range":{"acl.valid_from":{"gt":"now"}}},
+				{"range":{"acl.valid_until":{"lte":"now"}}}
+			]
+		}}}}
+	]}}}`, resID, userID, relation)
+}
+
 // Lookup manage for heavy user
 func runLookupResourcesManageHeavyUser(es *esv9.Client) {
 	iters := utils.GetEnvInt("BENCH_LOOKUPRES_MANAGE_ITER", 10)
-	user := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
-	runLookupBench(es, "lookup_resources_manage_super", "allowed_manage_user_id", user, iters, 60*time.Second)
+	user := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
+	runLookupBench(es, "lookup_resources_manage_super", "manage", user, iters, 60*time.Second)
 }
 
 // Lookup view for regular user
 func runLookupResourcesViewRegularUser(es *esv9.Client) {
 	iters := utils.GetEnvInt("BENCH_LOOKUPRES_VIEW_ITER", 10)
-	user := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
-	runLookupBench(es, "lookup_resources_view_regular", "allowed_view_user_id", user, iters, 60*time.Second)
+	user := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
+	runLookupBench(es, "lookup_resources_view_regular", "view", user, iters, 60*time.Second)
 }
 
-// runLookupBench streams matching resources for a user and counts them.
-func runLookupBench(es *esv9.Client, name, field, user string, iters int, timeout time.Duration) {
+// runLookupBench times the lookups of a user twice, so Elasticsearch can be
+// compared with both kinds of backend: as name, streaming every matching
+// resource id to the client with the paging of ES_LOOKUP_PAGING; and, unless
+// ES_LOOKUP_COUNT=false, as name_count, only counting the matches with
+// track_total_hits, the same work as the SQL backends' COUNT(DISTINCT).
+func runLookupBench(es *esv9.Client, name, permission, user string, iters int, timeout time.Duration) {
 	if user == "" {
 		log.Printf("[elasticsearch] [%s] skipped: no user specified", name)
 		return
 	}
-	log.Printf("[elasticsearch] [%s] iterations=%d user=%s", name, iters, user)
+	runLookupMode(name, lookupPagingFromEnv(), permission, user, iters, timeout, func(ctx context.Context) (int, error) {
+		count := 0
+		err := lookupResources(ctx, es, user, permission, func(string) { count++ })
+		return count, err
+	})
+	if utils.GetEnvWithDefault("ES_LOOKUP_COUNT", "true") != "true" {
+		return
+	}
+	runLookupMode(name+"_count", "count", permission, user, iters, timeout, func(ctx context.Context) (int, error) {
+		return countResources(ctx, es, user, permission)
+	})
+}
+
+// runLookupMode runs iters timed lookups of scenario name, each returning the
+// number of resources found.
+func runLookupMode(name, mode, permission, user string, iters int, timeout time.Duration, lookup func(ctx context.Context) (int, error)) {
+	log.Printf("[elasticsearch] [%s] iterations=%d user=%s mode=%s", name, iters, user, mode)
+	utils.LogScenarioConfig("elasticsearch", name, iters, timeout, user)
 
 	var total time.Duration
-	var lastCount int
+	var lastCount, ok int
+	errs := utils.NewErrorTally()
 
 	for i := range iters {
 		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		start := time.Now()
 
-		count := 0
-		scrollQueryStreamWithCtx(ctx, es, buildTermQuery(field, user), func(_ string) {
-			count++
-		})
+		count, err := lookup(ctx)
 		cancel()
+		utils.AuditLookup(name, user, permission, count, time.Since(start), err)
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[elasticsearch] [%s] iter=%d LookupResources failed class=%s: %v", name, i, class, err)
+			continue
+		}
 
 		dur := time.Since(start)
 		total += dur
 		lastCount = count
+		ok++
 		log.Printf("[elasticsearch] [%s] iter=%d resources=%d duration=%s", name, i, count, dur.Truncate(time.Millisecond))
 	}
 
-	avg := time.Duration(int64(total) / int64(iters))
+	avg := time.Duration(0)
+	if ok > 0 {
+		avg = time.Duration(int64(total) / int64(ok))
+	}
 	log.Printf("[elasticsearch] [%s] DONE: iters=%d lastCount=%d avg=%s total=%s", name, iters, lastCount, avg, total)
+	log.Printf("[elasticsearch] [%s] ERRORS: %s", name, errs.Summary(iters))
 }
 
 // ===== Query helpers (streaming) =====
@@ -223,35 +393,55 @@ func buildTermQuery(field, value string) []byte {
 }
 
 func scrollQueryStream(es *esv9.Client, query []byte, handle func(resID string)) {
-	scrollQueryStreamWithCtx(context.Background(), es, query, handle)
+	if err := scrollQueryStreamWithCtx(context.Background(), es, query, handle); err != nil {
+		log.Fatalf("[elasticsearch] search failed: %v", err)
+	}
 }
 
-func scrollQueryStreamWithCtx(ctx context.Context, es *esv9.Client, query []byte, handle func(resID string)) {
-	req := es.Search.WithBody(bytes.NewReader(query))
-	// Use a small page size to keep memory bounded, rely on streaming iteration.
+// scrollQueryStreamWithCtx pages through the hits for query on IndexName()
+// with from+size and hands each id to handle (see esauthz.ScrollFrom).
+func scrollQueryStreamWithCtx(ctx context.Context, es *esv9.Client, query []byte, handle func(resID string)) error {
+	return esauthz.ScrollFrom(ctx, es, IndexName(), query, handle)
+}
+
+// scrollTimeBoundedGrants pages through resources holding a direct user grant
+// with valid_until set and hands each such acl entry (via inner_hits) to handle.
+func scrollTimeBoundedGrants(ctx context.Context, es *esv9.Client, handle func(resID, userID int, relation string)) error {
+	query := []byte(`{"_source":false,"query":{"nested":{"path":"acl","query":{"bool":{"filter":[
+		{"term":{"acl.subject_type":"user"}},
+		{"exists":{"field":"acl.valid_until"}}
+	]}},"inner_hits":{"size":100}}}}`)
 	from := 0
 	for {
-		// Add pagination via from+size (basic) to avoid keeping state server-side
 		res, err := es.Search(
-			req,
 			es.Search.WithContext(ctx),
-			es.Search.WithIndex(IndexName),
+			es.Search.WithIndex(IndexName()),
+			es.Search.WithBody(bytes.NewReader(query)),
 			es.Search.WithSize(1000),
 			es.Search.WithFrom(from),
 		)
 		if err != nil {
-			log.Fatalf("[elasticsearch] search failed: %v", err)
+			return err
 		}
 		var hits struct {
 			Hits struct {
 				Hits []struct {
-					ID string `json:"_id"`
+					ID        string `json:"_id"`
+					InnerHits struct {
+						ACL struct {
+							Hits struct {
+								Hits []struct {
+									Source aclEntry `json:"_source"`
+								} `json:"hits"`
+							} `json:"hits"`
+						} `json:"acl"`
+					} `json:"inner_hits"`
 				} `json:"hits"`
 			} `json:"hits"`
 		}
 		if err := json.NewDecoder(res.Body).Decode(&hits); err != nil {
 			res.Body.Close()
-			log.Fatalf("[elasticsearch] decode search body failed: %v", err)
+			return fmt.Errorf("decode search body: %w", err)
 		}
 		res.Body.Close()
 
@@ -259,8 +449,89 @@ func scrollQueryStreamWithCtx(ctx context.Context, es *esv9.Client, query []byte
 			break
 		}
 		for _, h := range hits.Hits.Hits {
-			handle(h.ID)
+			resID, err := strconv.Atoi(h.ID)
+			if err != nil {
+				return fmt.Errorf("unexpected document id %q: %w", h.ID, err)
+			}
+			for _, ih := range h.InnerHits.ACL.Hits.Hits {
+				handle(resID, ih.Source.SubjectID, ih.Source.Relation)
+			}
 		}
 		from += 1000
 	}
+	return nil
+}
+
+// runLookupResourcesMix benchmarks lookups for a mix of users stratified by
+// grant count, reporting latency against result-set size. The number of users
+// is set by BENCH_LOOKUPRES_MIX_USERS (default 0 = skip); see utils.RunLookupMix.
+func runLookupResourcesMix(es *esv9.Client) {
+	utils.RunLookupMix("elasticsearch", newChecker(es))
+}
+
+// runLookupResourcesPercentiles benchmarks lookups for the users at the p10,
+// p50, p90 and p99 of the grant counts (BENCH_LOOKUPRES_*_PCT_USERS); see
+// utils.RunPercentileLookups.
+func runLookupResourcesPercentiles(es *esv9.Client) {
+	utils.RunPercentileLookups("elasticsearch", newChecker(es))
+}
+
+// runViralFanIn benchmarks checks and lookups on the resources shared with many
+// users directly (RLP_VIRAL_RESOURCES); see utils.RunViralFanIn.
+func runViralFanIn(es *esv9.Client) {
+	utils.RunViralFanIn("elasticsearch", newChecker(es))
+}
+
+// runWorstCaseChecks benchmarks view checks on the pairs granted only through
+// nested groups (check_view_worst_case); see utils.RunWorstCaseChecks.
+func runWorstCaseChecks(es *esv9.Client) {
+	utils.RunWorstCaseChecks("elasticsearch", newChecker(es))
+}
+
+// runWorkload benchmarks the blended mix of checks and lookups named by
+// BENCH_WORKLOAD (default "" = skip); see utils.RunWorkload.
+func runWorkload(es *esv9.Client) {
+	utils.RunWorkload("elasticsearch", newChecker(es))
+}
+
+// runAccessSummary benchmarks counting the resources sampled users can view
+// and manage (BENCH_ACCESS_SUMMARY_ITER, default 100; 0 skips); see
+// utils.RunAccessSummary.
+func runAccessSummary(es *esv9.Client) {
+	utils.RunAccessSummary("elasticsearch", newChecker(es))
+}
+
+// runMiddlewareOverhead benchmarks a resource fetch with and without a view
+// check in front of it (BENCH_MIDDLEWARE_ITER, default 1000; 0 skips); see
+// utils.RunMiddlewareOverhead.
+func runMiddlewareOverhead(es *esv9.Client) {
+	utils.RunMiddlewareOverhead("elasticsearch", newChecker(es), func(ctx context.Context, resourceID string) error {
+		id, err := ids.Decimal(ids.Resource, resourceID)
+		if err != nil {
+			return err
+		}
+		// The resource fields only, not its permission arrays.
+		res, err := es.Get(IndexName(), id, es.Get.WithContext(ctx),
+			es.Get.WithSourceIncludes("resource_id", "org_id", "created_at", "updated_at"))
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.IsError() && res.StatusCode != 404 {
+			return fmt.Errorf("get %s: %s", id, res.Status())
+		}
+		_, err = io.Copy(io.Discard, res.Body)
+		return err
+	})
+}
+
+// runFullExport benchmarks scrolling every resource document (export_full_acl,
+// BENCH_EXPORT_ITER), counting one row per acl entry; see
+// utils.RunFullExport.
+func runFullExport(es *esv9.Client) {
+	utils.RunFullExport("elasticsearch", func(ctx context.Context) (int, error) {
+		n := 0
+		err := scrollDocs(ctx, es, func(doc resourceDoc) { n += len(doc.ACL) })
+		return n, err
+	})
 }
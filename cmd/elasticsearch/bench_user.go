@@ -0,0 +1,60 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	esv9 "github.com/elastic/go-elasticsearch/v9"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// benchRoleName is the role granted to the read-only bench user, prefixed
+// with RLP_NAMESPACE like the index it reads.
+const benchRoleName = "rlp_bench_read"
+
+// ensureBenchUser provisions the read-only user the benchmark connects as
+// (see infrastructure.UseBenchCredentials). No-op unless ELASTICSEARCH_BENCH_USER
+// is set. Requires xpack security; the role only grants read on IndexName().
+func ensureBenchUser(ctx context.Context, es *esv9.Client) {
+	user, password, ok := infrastructure.BenchCredentialsFromEnv("ELASTICSEARCH")
+	if !ok {
+		return
+	}
+
+	roleName := utils.Namespaced(benchRoleName)
+	role, _ := json.Marshal(map[string]any{
+		"indices": []map[string]any{{"names": []string{IndexName()}, "privileges": []string{"read"}}},
+	})
+	rctx, rcancel := context.WithTimeout(ctx, 30*time.Second)
+	defer rcancel()
+	res, err := es.Security.PutRole(roleName, bytes.NewReader(role), es.Security.PutRole.WithContext(rctx))
+	if err != nil {
+		log.Fatalf("[elasticsearch] bench user: put role %q failed: %v", roleName, err)
+	}
+	defer safeClose(res.Body)
+	if res.IsError() {
+		log.Fatalf("[elasticsearch] bench user: put role %q error: %s body=%s", roleName, res.Status(), readBodyString(res.Body))
+	}
+
+	body, _ := json.Marshal(map[string]any{
+		"password": password,
+		"roles":    []string{roleName},
+	})
+	uctx, ucancel := context.WithTimeout(ctx, 30*time.Second)
+	defer ucancel()
+	ures, err := es.Security.PutUser(user, bytes.NewReader(body), es.Security.PutUser.WithContext(uctx))
+	if err != nil {
+		log.Fatalf("[elasticsearch] bench user: put user %q failed: %v", user, err)
+	}
+	defer safeClose(ures.Body)
+	if ures.IsError() {
+		log.Fatalf("[elasticsearch] bench user: put user %q error: %s body=%s", user, ures.Status(), readBodyString(ures.Body))
+	}
+
+	log.Printf("[elasticsearch] Read-only bench user %q ready (role %q)", user, roleName)
+}
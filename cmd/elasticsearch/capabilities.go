@@ -0,0 +1,39 @@
+package elasticsearch
+
+import "test-tls/utils"
+
+// Capabilities lists what the elasticsearch module supports, for the capabilities
+// command. Keep it in step with the benchmark scenarios in bechmark_reads.go.
+func Capabilities() utils.Capabilities {
+	return utils.Capabilities{
+		Scenarios: []string{
+			"check_manage_direct_user",
+			"check_manage_org_admin",
+			"check_view_via_group_member",
+			"check_time_bounded_direct_user",
+			"lookup_resources_manage_super",
+			"lookup_resources_view_regular",
+			"lookup_resources_*_count (ES_LOOKUP_COUNT)",
+			"lookup_resources_*_mix (BENCH_LOOKUPRES_MIX_USERS)",
+			"lookup_resources_*_pct (BENCH_LOOKUPRES_*_PCT_USERS)",
+			"check_view_viral_direct_user (BENCH_VIRAL_RESOURCES)",
+			"lookup_resources_view_viral (BENCH_LOOKUPRES_VIRAL_USER)",
+			"check_view_worst_case (nested-group-only grants, BENCH_CHECK_WORST_ITER)",
+			"workload_* (blended request mix, BENCH_WORKLOAD)",
+			"count_access_summary (view and manage counts of a user, BENCH_ACCESS_SUMMARY_ITER)",
+			"middleware_overhead (resource fetch with and without a view check, BENCH_MIDDLEWARE_ITER)",
+			"export_full_acl (full ACL stream, BENCH_EXPORT_ITER)",
+			"per-scenario cost from index search stats (BENCH_COST)",
+		},
+		Schemas: []string{
+			"ES_LOOKUP_PAGING=search_after|from (lookup scenarios, --schema)",
+		},
+		Consistency: []string{
+			"server default (no knob)",
+			"ES_LOOKUP_COUNT=true|false (count-only lookup scenarios)",
+		},
+		Writes: []string{
+			"user offboarding: _update_by_query on the resource docs (offboard, BENCH_OFFBOARD_USERS)",
+		},
+	}
+}
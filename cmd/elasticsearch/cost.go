@@ -0,0 +1,55 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	esv9 "github.com/elastic/go-elasticsearch/v9"
+
+	"test-tls/utils"
+)
+
+// costMeter reads the search stats of the index: shard-level queries and
+// fetches and the time spent in each, the server side of what a search
+// response reports as took. Counts run as searches too. The index has one
+// shard (see create_schemas.go), so shard queries equal requests; with more
+// shards every request counts once per shard.
+func costMeter(es *esv9.Client) utils.CostMeter {
+	return func(ctx context.Context) ([]utils.CostCounter, error) {
+		res, err := es.Indices.Stats(
+			es.Indices.Stats.WithContext(ctx),
+			es.Indices.Stats.WithIndex(IndexName()),
+			es.Indices.Stats.WithMetric("search"),
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer safeClose(res.Body)
+		if res.IsError() {
+			return nil, fmt.Errorf("index stats: %s", res.Status())
+		}
+		var body struct {
+			All struct {
+				Total struct {
+					Search struct {
+						QueryTotal  float64 `json:"query_total"`
+						QueryMillis float64 `json:"query_time_in_millis"`
+						FetchTotal  float64 `json:"fetch_total"`
+						FetchMillis float64 `json:"fetch_time_in_millis"`
+					} `json:"search"`
+				} `json:"total"`
+			} `json:"_all"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("decode index stats: %w", err)
+		}
+		s := body.All.Total.Search
+		return []utils.CostCounter{
+			{Name: "shard_queries", Value: s.QueryTotal},
+			{Name: "query_ms", Value: s.QueryMillis},
+			{Name: "fetches", Value: s.FetchTotal},
+			{Name: "fetch_ms", Value: s.FetchMillis},
+		}, nil
+	}
+}
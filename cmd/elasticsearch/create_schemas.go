@@ -26,13 +26,17 @@ func ElasticsearchCreateSchemas() {
 	defer cleanup()
 
 	ensureResourceIndex(ctx, es)
+	ensureBenchUser(ctx, es)
 }
 
 func ensureResourceIndex(ctx context.Context, es *esv9.Client) {
 	// Mapping notes:
 	// - allowed_manage_user_id / allowed_view_user_id are arrays of integers
 	//   (multi-valued numeric fields) for fast term lookups.
-	// - acl is optional and modeled as nested for future auditing.
+	// - acl is optional and modeled as nested for future auditing; valid_from /
+	//   valid_until carry time-bounded grants for the expiry-filtering check.
+	// - created_at / updated_at (resource) and acl.created_at (grant) are set
+	//   when the CSVs carry timestamps, for recency and time-window queries.
 	// - dynamic is false to keep mapping stable.
 	mapping := `{
 			"settings": {
@@ -44,6 +48,8 @@ func ensureResourceIndex(ctx context.Context, es *esv9.Client) {
 				"properties": {
 					"resource_id": {"type": "integer"},
 					"org_id": {"type": "integer"},
+					"created_at": {"type": "date"},
+					"updated_at": {"type": "date"},
 					"allowed_manage_user_id": {"type": "integer"},
 					"allowed_view_user_id": {"type": "integer"},
 					"acl": {
@@ -51,7 +57,10 @@ func ensureResourceIndex(ctx context.Context, es *esv9.Client) {
 						"properties": {
 							"subject_type": {"type": "keyword"},
 							"subject_id": {"type": "integer"},
-							"relation": {"type": "keyword"}
+							"relation": {"type": "keyword"},
+							"valid_from": {"type": "date"},
+							"valid_until": {"type": "date"},
+							"created_at": {"type": "date"}
 						}
 					}
 				}
@@ -61,7 +70,7 @@ func ensureResourceIndex(ctx context.Context, es *esv9.Client) {
 	// Create index if missing; otherwise put mapping (idempotent).
 	existsCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
-	res, err := es.Indices.Exists([]string{IndexName}, es.Indices.Exists.WithContext(existsCtx))
+	res, err := es.Indices.Exists([]string{IndexName()}, es.Indices.Exists.WithContext(existsCtx))
 	if err != nil {
 		log.Fatalf("[elasticsearch] index exists check failed: %v", err)
 	}
@@ -71,40 +80,50 @@ func ensureResourceIndex(ctx context.Context, es *esv9.Client) {
 		// Create index with settings+mappings
 		createCtx, ccancel := context.WithTimeout(ctx, 60*time.Second)
 		defer ccancel()
-		cres, err := es.Indices.Create(IndexName,
+		cres, err := es.Indices.Create(IndexName(),
 			es.Indices.Create.WithBody(bytes.NewReader([]byte(mapping))),
 			es.Indices.Create.WithContext(createCtx),
 		)
 		if err != nil {
-			log.Fatalf("[elasticsearch] create index %q failed: %v", IndexName, err)
+			log.Fatalf("[elasticsearch] create index %q failed: %v", IndexName(), err)
 		}
 		defer safeClose(cres.Body)
 		if cres.IsError() {
 			body := readBodyString(cres.Body)
-			log.Fatalf("[elasticsearch] create index %q error: %s body=%s", IndexName, cres.Status(), body)
+			log.Fatalf("[elasticsearch] create index %q error: %s body=%s", IndexName(), cres.Status(), body)
 		}
-		log.Printf("[elasticsearch] created index %q with mappings", IndexName)
+		log.Printf("[elasticsearch] created index %q with mappings", IndexName())
 		return
 	}
 
 	// Exists: try to update mappings to ensure fields exist (no breaking changes).
 	putMapCtx, mcancel := context.WithTimeout(ctx, 60*time.Second)
 	defer mcancel()
-	mres, err := es.Indices.PutMapping([]string{IndexName}, bytes.NewReader([]byte(`{
+	mres, err := es.Indices.PutMapping([]string{IndexName()}, bytes.NewReader([]byte(`{
 			"properties": {
+				"created_at": {"type": "date"},
+				"updated_at": {"type": "date"},
 				"allowed_manage_user_id": {"type": "integer"},
-				"allowed_view_user_id": {"type": "integer"}
+				"allowed_view_user_id": {"type": "integer"},
+				"acl": {
+					"type": "nested",
+					"properties": {
+						"valid_from": {"type": "date"},
+						"valid_until": {"type": "date"},
+						"created_at": {"type": "date"}
+					}
+				}
 			}
 		}`)), es.Indices.PutMapping.WithContext(putMapCtx))
 	if err != nil {
-		log.Fatalf("[elasticsearch] put mapping on %q failed: %v", IndexName, err)
+		log.Fatalf("[elasticsearch] put mapping on %q failed: %v", IndexName(), err)
 	}
 	defer safeClose(mres.Body)
 	if mres.IsError() {
 		body := readBodyString(mres.Body)
-		log.Printf("[elasticsearch] put mapping on %q returned: %s body=%s", IndexName, mres.Status(), body)
+		log.Printf("[elasticsearch] put mapping on %q returned: %s body=%s", IndexName(), mres.Status(), body)
 	} else {
-		log.Printf("[elasticsearch] ensured mappings on %q", IndexName)
+		log.Printf("[elasticsearch] ensured mappings on %q", IndexName())
 	}
 }
 
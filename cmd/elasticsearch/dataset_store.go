@@ -0,0 +1,55 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	esv9 "github.com/elastic/go-elasticsearch/v9"
+)
+
+// datasetStore records the dataset load-data loaded as rlp_dataset in the
+// _meta of the index mapping, so it goes with the index (utils.GuardDataset).
+type datasetStore struct{ es *esv9.Client }
+
+func (s datasetStore) RecordDataset(ctx context.Context, id string) error {
+	body, err := json.Marshal(map[string]any{"_meta": map[string]string{"rlp_dataset": id}})
+	if err != nil {
+		return err
+	}
+	res, err := s.es.Indices.PutMapping([]string{IndexName()}, bytes.NewReader(body), s.es.Indices.PutMapping.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("put mapping on %q: %s", IndexName(), res.Status())
+	}
+	return nil
+}
+
+func (s datasetStore) LoadedDataset(ctx context.Context) (string, error) {
+	res, err := s.es.Indices.GetMapping(s.es.Indices.GetMapping.WithIndex(IndexName()), s.es.Indices.GetMapping.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return "", fmt.Errorf("get mapping of %q: %s", IndexName(), res.Status())
+	}
+	var indices map[string]struct {
+		Mappings struct {
+			Meta struct {
+				Dataset string `json:"rlp_dataset"`
+			} `json:"_meta"`
+		} `json:"mappings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return "", err
+	}
+	for _, index := range indices {
+		return index.Mappings.Meta.Dataset, nil
+	}
+	return "", nil
+}
@@ -2,14 +2,22 @@ package elasticsearch
 
 import (
 	"context"
+	"encoding/json"
 	"log"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
+	esv9 "github.com/elastic/go-elasticsearch/v9"
+
 	"test-tls/infrastructure"
+	"test-tls/utils"
 )
 
 // ElasticsearchDropSchemas removes the benchmark index and all documents.
-// It follows the logging style used by other loaders.
+// An alias of that name, or an index pattern, is left in place
+// (utils.OwnedObjects). It follows the logging style used by other loaders.
 func ElasticsearchDropSchemas() {
 	ctx := context.Background()
 	es, cleanup, err := infrastructure.NewElasticsearchFromEnv(ctx)
@@ -21,17 +29,28 @@ func ElasticsearchDropSchemas() {
 	start := time.Now()
 	log.Printf("[elasticsearch] == Starting Elasticsearch drop schemas ==")
 
+	if strings.ContainsAny(IndexName(), "*?,") {
+		log.Fatalf("[elasticsearch] drop: refusing index pattern %q; ES_INDEX must name one index", IndexName())
+	}
+	own := utils.OwnedObjects("elasticsearch", listIndices(ctx, es, IndexName()), []string{IndexName()})
+	if !own[IndexName()] {
+		log.Printf("[elasticsearch] Elasticsearch drop schemas DONE: no index %q", IndexName())
+		return
+	}
+
+	utils.GuardDrop("elasticsearch", []utils.DropCount{countIndex(ctx, es, IndexName())})
+
 	// Delete the index if exists.
 	delCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
-	res, err := es.Indices.Delete([]string{IndexName}, es.Indices.Delete.WithContext(delCtx))
+	res, err := es.Indices.Delete([]string{IndexName()}, es.Indices.Delete.WithContext(delCtx))
 	if err != nil {
-		log.Printf("[elasticsearch] delete index %q failed: %v", IndexName, err)
+		log.Printf("[elasticsearch] delete index %q failed: %v", IndexName(), err)
 	} else {
 		if res.IsError() {
-			log.Printf("[elasticsearch] delete index %q returned: %s", IndexName, res.Status())
+			log.Printf("[elasticsearch] delete index %q returned: %s", IndexName(), res.Status())
 		} else {
-			log.Printf("[elasticsearch] deleted index %q", IndexName)
+			log.Printf("[elasticsearch] deleted index %q", IndexName())
 		}
 		res.Body.Close()
 	}
@@ -39,3 +58,50 @@ func ElasticsearchDropSchemas() {
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 	log.Printf("[elasticsearch] Elasticsearch drop schemas DONE: elapsed=%s", elapsed)
 }
+
+// listIndices returns the concrete indices name resolves to: itself, the
+// indices behind it when it is an alias, none when it does not exist.
+func listIndices(ctx context.Context, es *esv9.Client, name string) []string {
+	gctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	res, err := es.Indices.Get([]string{name}, es.Indices.Get.WithContext(gctx), es.Indices.Get.WithIgnoreUnavailable(true))
+	if err != nil {
+		log.Fatalf("[elasticsearch] drop: get index %q: %v", name, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return nil
+	}
+	if res.IsError() {
+		log.Fatalf("[elasticsearch] drop: get index %q: %s", name, res.Status())
+	}
+	var indices map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		log.Fatalf("[elasticsearch] drop: get index %q: %v", name, err)
+	}
+	return slices.Sorted(maps.Keys(indices))
+}
+
+// countIndex counts the documents of index for the drop guard.
+func countIndex(ctx context.Context, es *esv9.Client, index string) utils.DropCount {
+	cctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	c := utils.DropCount{Table: index, Missing: true}
+	res, err := es.Count(es.Count.WithContext(cctx), es.Count.WithIndex(index))
+	if err != nil {
+		return c
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return c
+	}
+	var body struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return c
+	}
+	return utils.DropCount{Table: index, Rows: body.Count}
+}
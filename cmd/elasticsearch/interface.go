@@ -10,9 +10,16 @@ import (
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v9"
+
+	"test-tls/utils"
 )
 
-const IndexName = "rlp"
+// IndexName is the index the resource docs live in: ES_INDEX, default "rlp",
+// prefixed with RLP_NAMESPACE. Give each dataset its own index to keep several
+// loaded side by side.
+func IndexName() string {
+	return utils.Namespaced(utils.GetEnvWithDefault("ES_INDEX", "rlp"))
+}
 
 // =========================
 // Build and index resource docs
@@ -143,7 +150,7 @@ func indexResourceDocs(
 
 		meta := map[string]map[string]any{
 			"index": {
-				"_index": IndexName,
+				"_index": IndexName(),
 				"_id":    strconv.Itoa(resID),
 			},
 		}
@@ -174,14 +181,14 @@ func indexResourceDocs(
 	refreshCtx, cancelRefresh := context.WithTimeout(ctx, 30*time.Second)
 	defer cancelRefresh()
 	if _, err := es.Indices.Refresh(
-		es.Indices.Refresh.WithIndex([]string{IndexName}...),
+		es.Indices.Refresh.WithIndex([]string{IndexName()}...),
 		es.Indices.Refresh.WithContext(refreshCtx),
 	); err != nil {
 		log.Printf("[elasticsearch] index refresh failed: %v", err)
 	}
 
 	elapsed := time.Since(start).Truncate(time.Millisecond)
-	log.Printf("[elasticsearch] Indexed %d resource docs into %q in %s", docCount, IndexName, elapsed)
+	log.Printf("[elasticsearch] Indexed %d resource docs into %q in %s", docCount, IndexName(), elapsed)
 }
 
 // =========================
@@ -195,20 +202,20 @@ func ClearIndexDocs(ctx context.Context, es *elasticsearch.Client) {
 	body := `{"query":{"match_all":{}}}`
 
 	res, err := es.DeleteByQuery(
-		[]string{IndexName},
+		[]string{IndexName()},
 		strings.NewReader(body),
 		es.DeleteByQuery.WithContext(ctxTimeout),
 		es.DeleteByQuery.WithConflicts("proceed"),
 	)
 	if err != nil {
-		log.Fatalf("[elasticsearch] delete_by_query on %q failed: %v", IndexName, err)
+		log.Fatalf("[elasticsearch] delete_by_query on %q failed: %v", IndexName(), err)
 	}
 	defer res.Body.Close()
 
 	// If index is missing, DeleteByQuery returns 404; that is acceptable.
 	if res.IsError() && !strings.Contains(res.Status(), "404") {
-		log.Fatalf("[elasticsearch] delete_by_query on %q returned error: %s", IndexName, res.Status())
+		log.Fatalf("[elasticsearch] delete_by_query on %q returned error: %s", IndexName(), res.Status())
 	}
 
-	log.Printf("[elasticsearch] Cleared existing documents in index %q", IndexName)
+	log.Printf("[elasticsearch] Cleared existing documents in index %q", IndexName())
 }
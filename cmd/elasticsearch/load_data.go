@@ -3,28 +3,34 @@ package elasticsearch
 import (
 	"bytes"
 	"context"
-	"encoding/csv"
 	"encoding/json"
-	"io"
+	"fmt"
+	"iter"
 	"log"
-	"os"
-	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	esv9 "github.com/elastic/go-elasticsearch/v9"
 
+	"test-tls/dataset"
 	"test-tls/infrastructure"
-)
-
-const (
-	esDataDir = "data"
+	"test-tls/utils"
 )
 
 // ElasticsearchCreateData builds effective permission documents and bulk indexes
 // them into Elasticsearch index defined in create_schemas.go. Logging mirrors
 // cmd/authzed_crdb/load_data.go style and bulk operations overwrite by _id.
+//
+// Bulk ingestion can be tuned via env:
+//
+//	ES_BULK_BATCH_SIZE        // docs per _bulk request (default 1000)
+//	ES_BULK_WORKERS           // concurrent _bulk requests in flight (default 4)
+//	ES_LOAD_REFRESH_INTERVAL  // refresh_interval set after load (default: the
+//	                          // index's value before the load, the cluster default
+//	                          // when that is -1); it is -1 while loading
 func ElasticsearchCreateData() {
 	ctx := context.Background()
 	es, cleanup, err := infrastructure.NewElasticsearchFromEnv(ctx)
@@ -34,23 +40,83 @@ func ElasticsearchCreateData() {
 	defer cleanup()
 
 	start := time.Now()
-	log.Printf("[elasticsearch] == Starting Elasticsearch data import from CSV in %q ==", esDataDir)
+	log.Printf("[elasticsearch] == Starting Elasticsearch data import from CSV in %q ==", utils.DataDir())
 
 	// Ensure index exists
 	ElasticsearchCreateSchemas()
+	utils.MarkDatasetLoading("elasticsearch", datasetStore{es})
 
 	// Ingest CSVs into in-memory structures
-	resourceOrg := loadResourcesCSV()
-	orgAdmins, orgMembers := loadOrgMembershipsCSV()
-	groupDirectMembers, groupDirectManagers := loadGroupMembershipsCSV()
-	groupHierarchy := loadGroupHierarchyCSV()
-	directUserManagers, directUserViewers, groupManagers, groupViewers, resourceACL := loadResourceACLCsv()
+	total := 0
+	resourceOrg := make(map[int]int)
+	resourceTS := make(map[int]resourceTimes)
+	readTable(&total, "resources", dataset.Resources(), func(r dataset.Resource) {
+		resourceOrg[r.ID.N] = r.Org.N
+		if r.CreatedAt != nil || r.UpdatedAt != nil {
+			resourceTS[r.ID.N] = resourceTimes{r.CreatedAt, r.UpdatedAt}
+		}
+	})
+	orgAdmins, orgMembers := make(map[int]intSet), make(map[int]intSet)
+	readTable(&total, "org_memberships", dataset.OrgMemberships(), func(m dataset.OrgMembership) {
+		if m.Role == "admin" {
+			addTo(orgAdmins, m.Org.N, m.User.N)
+		} else {
+			addTo(orgMembers, m.Org.N, m.User.N)
+		}
+	})
+	groupDirectMembers, groupDirectManagers := make(map[int]intSet), make(map[int]intSet)
+	readTable(&total, "group_memberships", dataset.GroupMemberships(), func(m dataset.GroupMembership) {
+		if m.Role == "direct_manager" {
+			addTo(groupDirectManagers, m.Group.N, m.User.N)
+		} else {
+			addTo(groupDirectMembers, m.Group.N, m.User.N)
+		}
+	})
+	groupHierarchy := make(map[int]map[int]string)
+	readTable(&total, "group_hierarchy", dataset.GroupHierarchy(), func(e dataset.GroupEdge) {
+		cs := groupHierarchy[e.Parent.N]
+		if cs == nil {
+			cs = make(map[int]string)
+			groupHierarchy[e.Parent.N] = cs
+		}
+		cs[e.Child.N] = e.Relation
+	})
+	// direct grants per resource, keyed by relation, plus the audit entries.
+	// Only grants active now are expanded into the permission docs, so they
+	// deny expired grants as SpiceDB's valid_window caveat does; the acl
+	// entries keep every window for check_time_bounded_direct_user.
+	now := time.Now()
+	grants := map[string]map[int]intSet{
+		"manager_user":  {},
+		"viewer_user":   {},
+		"manager_group": {},
+		"viewer_group":  {},
+	}
+	resourceACL := make(map[int][]aclEntry)
+	readTable(&total, "resource_acl", dataset.ResourceACL(), func(a dataset.ACL) {
+		resourceACL[a.Resource.N] = append(resourceACL[a.Resource.N], aclEntry{
+			SubjectType: a.SubjectType,
+			SubjectID:   a.Subject.N,
+			Relation:    a.Relation,
+			ValidFrom:   a.Window.From,
+			ValidUntil:  a.Window.Until,
+			CreatedAt:   a.CreatedAt,
+		})
+		if a.Window.ActiveAt(now) {
+			addTo(grants[a.Relation], a.Resource.N, a.Subject.N)
+		}
+	})
+	directUserManagers, directUserViewers := grants["manager_user"], grants["viewer_user"]
+	groupManagers, groupViewers := grants["manager_group"], grants["viewer_group"]
 
 	// Precompute effective managers and members per group (with memoization)
 	effManagers, effMembers := precomputeEffectiveGroupSets(groupDirectMembers, groupDirectManagers, groupHierarchy)
 
 	// Build and index resource docs
-	indexPermissionDocs(ctx, es, resourceOrg, orgAdmins, orgMembers, effManagers, effMembers, directUserManagers, directUserViewers, groupManagers, groupViewers, resourceACL)
+	if err := indexPermissionDocs(ctx, es, resourceOrg, resourceTS, orgAdmins, orgMembers, effManagers, effMembers, directUserManagers, directUserViewers, groupManagers, groupViewers, resourceACL); err != nil {
+		log.Fatalf("[elasticsearch] index resource docs: %v", err)
+	}
+	utils.RecordDataset("elasticsearch", datasetStore{es})
 
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 	log.Printf("[elasticsearch] Elasticsearch data import DONE: elapsed=%s", elapsed)
@@ -58,278 +124,24 @@ func ElasticsearchCreateData() {
 
 // ===== CSV helpers =====
 
-func openCSV(name string) (*csv.Reader, *os.File) {
-	full := filepath.Join(esDataDir, name)
-	f, err := os.Open(full)
-	if err != nil {
-		log.Fatalf("[elasticsearch] open %s: %v", full, err)
-	}
-	r := csv.NewReader(f)
-	return r, f
-}
-
-func atoiStrict(s string) int {
-	n, err := strconv.Atoi(s)
-	if err != nil {
-		log.Fatalf("[elasticsearch] invalid int %q: %v", s, err)
-	}
-	return n
-}
-
-func loadResourcesCSV() map[int]int {
-	r, f := openCSV("resources.csv")
-	defer f.Close()
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[elasticsearch] read resources header: %v", err)
-	}
-	m := make(map[int]int)
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[elasticsearch] read resources row: %v", err)
-		}
-		if len(rec) < 2 {
-			log.Fatalf("[elasticsearch] invalid resources row: %#v", rec)
-		}
-		resID := atoiStrict(rec[0])
-		orgID := atoiStrict(rec[1])
-		m[resID] = orgID
-		count++
-		if count%100000 == 0 {
-			log.Printf("[elasticsearch] Loaded resources progress: %d rows", count)
-		}
-	}
-	log.Printf("[elasticsearch] Loaded resources: %d rows", count)
-	return m
-}
-
-func loadOrgMembershipsCSV() (map[int]intSet, map[int]intSet) {
-	r, f := openCSV("org_memberships.csv")
-	defer f.Close()
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[elasticsearch] read org_memberships header: %v", err)
-	}
-	admins := make(map[int]intSet)
-	members := make(map[int]intSet)
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[elasticsearch] read org_memberships row: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[elasticsearch] invalid org_memberships row: %#v", rec)
-		}
-		orgID := atoiStrict(rec[0])
-		userID := atoiStrict(rec[1])
-		role := rec[2]
-		switch role {
-		case "admin":
-			s := admins[orgID]
-			if s == nil {
-				s = make(intSet)
-			}
-			s.add(userID)
-			admins[orgID] = s
-		default:
-			s := members[orgID]
-			if s == nil {
-				s = make(intSet)
-			}
-			s.add(userID)
-			members[orgID] = s
-		}
-		count++
-		if count%100000 == 0 {
-			log.Printf("[elasticsearch] Loaded org_memberships progress: %d rows", count)
-		}
+// readTable feeds every record of rows to add, counting them for table.
+func readTable[T any](total *int, table string, rows iter.Seq[T], add func(T)) {
+	progress := dataset.NewProgress("elasticsearch", table, total)
+	for row := range rows {
+		add(row)
+		progress.Add(1)
 	}
-	log.Printf("[elasticsearch] Loaded org_memberships: %d rows", count)
-	return admins, members
+	progress.Done()
 }
 
-func loadGroupMembershipsCSV() (map[int]intSet, map[int]intSet) {
-	r, f := openCSV("group_memberships.csv")
-	defer f.Close()
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[elasticsearch] read group_memberships header: %v", err)
-	}
-	directMembers := make(map[int]intSet)
-	directManagers := make(map[int]intSet)
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[elasticsearch] read group_memberships row: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[elasticsearch] invalid group_memberships row: %#v", rec)
-		}
-		groupID := atoiStrict(rec[0])
-		userID := atoiStrict(rec[1])
-		role := rec[2]
-		switch role {
-		case "direct_manager", "admin":
-			s := directManagers[groupID]
-			if s == nil {
-				s = make(intSet)
-			}
-			s.add(userID)
-			directManagers[groupID] = s
-		default:
-			s := directMembers[groupID]
-			if s == nil {
-				s = make(intSet)
-			}
-			s.add(userID)
-			directMembers[groupID] = s
-		}
-		count++
-		if count%100000 == 0 {
-			log.Printf("[elasticsearch] Loaded group_memberships progress: %d rows", count)
-		}
-	}
-	log.Printf("[elasticsearch] Loaded group_memberships: %d rows", count)
-	return directMembers, directManagers
-}
-
-func loadGroupHierarchyCSV() map[int]map[int]string {
-	full := filepath.Join(esDataDir, "group_hierarchy.csv")
-	f, err := os.Open(full)
-	if err != nil {
-		if os.IsNotExist(err) {
-			log.Printf("[elasticsearch] group_hierarchy.csv not found, skipping nested groups")
-			return map[int]map[int]string{}
-		}
-		log.Fatalf("[elasticsearch] open group_hierarchy.csv: %v", err)
-	}
-	defer f.Close()
-	r := csv.NewReader(f)
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[elasticsearch] read group_hierarchy header: %v", err)
-	}
-	m := make(map[int]map[int]string)
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[elasticsearch] read group_hierarchy row: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[elasticsearch] invalid group_hierarchy row: %#v", rec)
-		}
-		parent := atoiStrict(rec[0])
-		child := atoiStrict(rec[1])
-		relation := rec[2]
-		cs := m[parent]
-		if cs == nil {
-			cs = make(map[int]string)
-		}
-		cs[child] = relation
-		m[parent] = cs
-		count++
-		if count%100000 == 0 {
-			log.Printf("[elasticsearch] Loaded group_hierarchy progress: %d rows", count)
-		}
-	}
-	log.Printf("[elasticsearch] Loaded group_hierarchy: %d rows", count)
-	return m
-}
-
-func loadResourceACLCsv() (map[int]intSet, map[int]intSet, map[int]intSet, map[int]intSet, map[int][]aclEntry) {
-	r, f := openCSV("resource_acl.csv")
-	defer f.Close()
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[elasticsearch] read resource_acl header: %v", err)
-	}
-	directManagers := make(map[int]intSet)
-	directViewers := make(map[int]intSet)
-	groupManagers := make(map[int]intSet)
-	groupViewers := make(map[int]intSet)
-	acl := make(map[int][]aclEntry)
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[elasticsearch] read resource_acl row: %v", err)
-		}
-		if len(rec) < 4 {
-			log.Fatalf("[elasticsearch] invalid resource_acl row: %#v", rec)
-		}
-		resID := atoiStrict(rec[0])
-		subjectType := rec[1]
-		subjectID := atoiStrict(rec[2])
-		relation := rec[3]
-
-		// save audit entry
-		acl[resID] = append(acl[resID], aclEntry{SubjectType: subjectType, SubjectID: subjectID, Relation: relation})
-
-		switch subjectType {
-		case "user":
-			switch relation {
-			case "manager_user", "manager":
-				s := directManagers[resID]
-				if s == nil {
-					s = make(intSet)
-				}
-				s.add(subjectID)
-				directManagers[resID] = s
-			case "viewer_user", "viewer":
-				s := directViewers[resID]
-				if s == nil {
-					s = make(intSet)
-				}
-				s.add(subjectID)
-				directViewers[resID] = s
-			default:
-				log.Fatalf("[elasticsearch] unknown ACL relation for user: %q", relation)
-			}
-		case "group":
-			switch relation {
-			case "manager_group", "manager":
-				s := groupManagers[resID]
-				if s == nil {
-					s = make(intSet)
-				}
-				s.add(subjectID)
-				groupManagers[resID] = s
-			case "viewer_group", "viewer":
-				s := groupViewers[resID]
-				if s == nil {
-					s = make(intSet)
-				}
-				s.add(subjectID)
-				groupViewers[resID] = s
-			default:
-				log.Fatalf("[elasticsearch] unknown ACL relation for group: %q", relation)
-			}
-		default:
-			log.Fatalf("[elasticsearch] unknown subject_type in resource_acl: %q", subjectType)
-		}
-
-		count++
-		if count%100000 == 0 {
-			log.Printf("[elasticsearch] Loaded resource_acl progress: %d rows", count)
-		}
+// addTo adds v to the set at m[k].
+func addTo(m map[int]intSet, k, v int) {
+	s := m[k]
+	if s == nil {
+		s = make(intSet)
+		m[k] = s
 	}
-	log.Printf("[elasticsearch] Loaded resource_acl: %d rows", count)
-	return directManagers, directViewers, groupManagers, groupViewers, acl
+	s.add(v)
 }
 
 // ===== Effective group computation =====
@@ -420,10 +232,14 @@ func precomputeEffectiveGroupSets(
 
 // ===== Bulk indexing =====
 
+// indexPermissionDocs bulk indexes one doc per resource with refreshes off.
+// The index's refresh_interval is restored on return, also when a bulk
+// request fails.
 func indexPermissionDocs(
 	ctx context.Context,
 	es *esv9.Client,
 	resourceOrg map[int]int,
+	resourceTS map[int]resourceTimes,
 	orgAdmins map[int]intSet,
 	orgMembers map[int]intSet,
 	effManagers map[int]intSet,
@@ -433,7 +249,7 @@ func indexPermissionDocs(
 	groupManagers map[int]intSet,
 	groupViewers map[int]intSet,
 	resourceACL map[int][]aclEntry,
-) {
+) (err error) {
 	// Optional: clear old docs to avoid stale permissions
 	ClearIndexDocs(ctx, es)
 
@@ -444,29 +260,76 @@ func indexPermissionDocs(
 	}
 	sort.Ints(resourceIDs)
 
+	batchSize := utils.GetEnvInt("ES_BULK_BATCH_SIZE", esBulkBatchSize)
+	if batchSize < 1 {
+		batchSize = esBulkBatchSize
+	}
+	workers := utils.GetEnvInt("ES_BULK_WORKERS", esBulkWorkers)
+	if workers < 1 {
+		workers = 1
+	}
+	restoreInterval, err := getRefreshInterval(ctx, es)
+	if err != nil {
+		return err
+	}
+	if v := utils.GetEnvWithDefault("ES_LOAD_REFRESH_INTERVAL", ""); v != "" {
+		restoreInterval = v
+	}
+
+	// Disable periodic refreshes while loading; every refresh creates a new
+	// segment and makes ingest throughput depend on the refresh cadence.
+	if err := setRefreshInterval(ctx, es, "-1"); err != nil {
+		return err
+	}
+	defer func() {
+		if rerr := setRefreshInterval(context.Background(), es, restoreInterval); rerr != nil && err == nil {
+			err = rerr
+		}
+	}()
+	log.Printf("[elasticsearch] bulk load: batch=%d workers=%d refresh_interval=-1 (restores to %s)", batchSize, workers, intervalName(restoreInterval))
+
 	start := time.Now()
 	var buf bytes.Buffer
 	docCount := 0
 
+	// The first failed request stops the load; the workers drain the
+	// remaining payloads without sending them.
+	payloads := make(chan []byte, workers)
+	bulkErr := make(chan error, 1)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for body := range payloads {
+				if len(bulkErr) > 0 {
+					continue
+				}
+				if err := sendBulk(ctx, es, body); err != nil {
+					select {
+					case bulkErr <- err:
+					default:
+					}
+				}
+			}
+		}()
+	}
+
 	flush := func() {
 		if buf.Len() == 0 {
 			return
 		}
-		bulkCtx, cancel := context.WithTimeout(ctx, esBulkTimeoutSec*time.Second)
-		defer cancel()
-		res, err := es.Bulk(bytes.NewReader(buf.Bytes()), es.Bulk.WithContext(bulkCtx), es.Bulk.WithRefresh("false"))
-		if err != nil {
-			log.Fatalf("[elasticsearch] bulk index failed: %v", err)
-		}
-		defer res.Body.Close()
-		if res.IsError() {
-			log.Fatalf("[elasticsearch] bulk index returned error: %s", res.Status())
-		}
+		body := make([]byte, buf.Len())
+		copy(body, buf.Bytes())
+		payloads <- body
 		buf.Reset()
 	}
 
 	// Iterate resources and build effective permission arrays
 	for _, resID := range resourceIDs {
+		if len(bulkErr) > 0 {
+			break
+		}
 		orgID := resourceOrg[resID]
 
 		manage := make(intSet)
@@ -531,6 +394,8 @@ func indexPermissionDocs(
 		doc := resourceDoc{
 			ResourceID:          resID,
 			OrgID:               orgID,
+			CreatedAt:           resourceTS[resID].created,
+			UpdatedAt:           resourceTS[resID].updated,
 			ACL:                 resourceACL[resID],
 			AllowedManageUserID: manageSlice,
 			AllowedViewUserID:   viewSlice,
@@ -538,7 +403,7 @@ func indexPermissionDocs(
 
 		// Bulk action lines
 		// Use the same metadata format as interface.go but inline here
-		buf.WriteString(`{"index":{"_index":"` + IndexName + `","_id":"` + strconv.Itoa(resID) + `"}}`)
+		buf.WriteString(`{"index":{"_index":"` + IndexName() + `","_id":"` + strconv.Itoa(resID) + `"}}`)
 		buf.WriteByte('\n')
 		// encode doc
 		b, _ := json.Marshal(doc)
@@ -546,7 +411,7 @@ func indexPermissionDocs(
 		buf.WriteByte('\n')
 
 		docCount++
-		if docCount%esBulkBatchSize == 0 {
+		if docCount%batchSize == 0 {
 			flush()
 		}
 		if docCount%100000 == 0 {
@@ -554,14 +419,136 @@ func indexPermissionDocs(
 		}
 	}
 	flush()
+	close(payloads)
+	wg.Wait()
+	if len(bulkErr) > 0 {
+		return <-bulkErr
+	}
+	ingest := time.Since(start)
 
-	// Refresh for visibility in benchmarks
-	refreshCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	// Force a refresh for visibility in benchmarks; the refresh interval is
+	// restored on return.
+	refreshStart := time.Now()
+	refreshCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
-	if _, err := es.Indices.Refresh(es.Indices.Refresh.WithIndex(IndexName), es.Indices.Refresh.WithContext(refreshCtx)); err != nil {
+	res, err := es.Indices.Refresh(es.Indices.Refresh.WithIndex(IndexName()), es.Indices.Refresh.WithContext(refreshCtx))
+	if err != nil {
 		log.Printf("[elasticsearch] index refresh failed: %v", err)
+	} else {
+		if res.IsError() {
+			log.Printf("[elasticsearch] index refresh returned: %s", res.Status())
+		}
+		res.Body.Close()
 	}
 
+	docsPerSec := 0.0
+	if ingest > 0 {
+		docsPerSec = float64(docCount) / ingest.Seconds()
+	}
 	elapsed := time.Since(start).Truncate(time.Millisecond)
-	log.Printf("[elasticsearch] Indexed %d resource docs into %q in %s", docCount, IndexName, elapsed)
+	log.Printf("[elasticsearch] Indexed %d resource docs into %q in %s (ingest=%s refresh=%s docs/sec=%.0f)",
+		docCount, IndexName(), elapsed, ingest.Truncate(time.Millisecond), time.Since(refreshStart).Truncate(time.Millisecond), docsPerSec)
+	return nil
+}
+
+// sendBulk posts one _bulk body and returns transport errors, HTTP errors and
+// the first per-item failure reported with "errors": true.
+func sendBulk(ctx context.Context, es *esv9.Client, body []byte) error {
+	bulkCtx, cancel := context.WithTimeout(ctx, esBulkTimeoutSec*time.Second)
+	defer cancel()
+	res, err := es.Bulk(bytes.NewReader(body), es.Bulk.WithContext(bulkCtx), es.Bulk.WithRefresh("false"))
+	if err != nil {
+		return fmt.Errorf("bulk index failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("bulk index returned error: %s", res.Status())
+	}
+
+	var out struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  any `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return fmt.Errorf("decode bulk response failed: %w", err)
+	}
+	if out.Errors {
+		for _, item := range out.Items {
+			for op, r := range item {
+				if r.Error != nil {
+					return fmt.Errorf("bulk %s failed status=%d: %v", op, r.Status, r.Error)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// getRefreshInterval returns the index.refresh_interval set on the resource
+// index, "" when it is not set and the cluster default applies. A -1 is taken
+// as unset too: it is the value of a load that was killed before restoring
+// the interval, and restoring it would leave the index without refreshes.
+func getRefreshInterval(ctx context.Context, es *esv9.Client) (string, error) {
+	settingsCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	res, err := es.Indices.GetSettings(
+		es.Indices.GetSettings.WithIndex(IndexName()),
+		es.Indices.GetSettings.WithName("index.refresh_interval"),
+		es.Indices.GetSettings.WithFlatSettings(true),
+		es.Indices.GetSettings.WithContext(settingsCtx),
+	)
+	if err != nil {
+		return "", fmt.Errorf("get refresh_interval failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return "", fmt.Errorf("get refresh_interval returned: %s body=%s", res.Status(), readBodyString(res.Body))
+	}
+	var out map[string]struct {
+		Settings map[string]string `json:"settings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode refresh_interval failed: %w", err)
+	}
+	for _, idx := range out {
+		if interval := idx.Settings["index.refresh_interval"]; interval != "-1" {
+			return interval, nil
+		}
+	}
+	return "", nil
+}
+
+// setRefreshInterval updates index.refresh_interval on the resource index;
+// "" removes the setting, restoring the cluster default.
+func setRefreshInterval(ctx context.Context, es *esv9.Client, interval string) error {
+	settingsCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	value := "null"
+	if interval != "" {
+		value = strconv.Quote(interval)
+	}
+	body := `{"index":{"refresh_interval":` + value + `}}`
+	res, err := es.Indices.PutSettings(strings.NewReader(body),
+		es.Indices.PutSettings.WithIndex(IndexName()),
+		es.Indices.PutSettings.WithContext(settingsCtx),
+	)
+	if err != nil {
+		return fmt.Errorf("set refresh_interval=%s failed: %w", intervalName(interval), err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("set refresh_interval=%s returned: %s body=%s", intervalName(interval), res.Status(), readBodyString(res.Body))
+	}
+	return nil
+}
+
+// intervalName names a refresh interval for logs, "" being the default.
+func intervalName(interval string) string {
+	if interval == "" {
+		return "default"
+	}
+	return interval
 }
@@ -0,0 +1,85 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	esv9 "github.com/elastic/go-elasticsearch/v9"
+
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// offboardScript removes params.user from the expanded allowed arrays and
+// the user entries of acl. The index holds resource docs only, with org
+// admins and group members already expanded into the arrays, so there is no
+// membership to delete.
+const offboardScript = `
+	ctx._source.allowed_manage_user_id?.removeIf(u -> u == params.user);
+	ctx._source.allowed_view_user_id?.removeIf(u -> u == params.user);
+	ctx._source.acl?.removeIf(a -> a.subject_type == 'user' && a.subject_id == params.user);
+`
+
+// userOffboarder rewrites every resource doc naming a user in one
+// _update_by_query, refreshed before it returns (utils.UserOffboarder).
+type userOffboarder struct {
+	es *esv9.Client
+}
+
+func (o userOffboarder) Offboard(ctx context.Context, userID string) (int, error) {
+	id, err := ids.Parse(ids.User, userID)
+	if err != nil {
+		return 0, err
+	}
+	body, err := json.Marshal(map[string]any{
+		"query": map[string]any{"bool": map[string]any{"should": []any{
+			map[string]any{"term": map[string]any{"allowed_manage_user_id": id}},
+			map[string]any{"term": map[string]any{"allowed_view_user_id": id}},
+			map[string]any{"nested": map[string]any{"path": "acl", "query": map[string]any{"bool": map[string]any{"filter": []any{
+				map[string]any{"term": map[string]any{"acl.subject_type": "user"}},
+				map[string]any{"term": map[string]any{"acl.subject_id": id}},
+			}}}}},
+		}}},
+		"script": map[string]any{"lang": "painless", "source": offboardScript, "params": map[string]any{"user": id}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	res, err := o.es.UpdateByQuery([]string{IndexName()},
+		o.es.UpdateByQuery.WithBody(strings.NewReader(string(body))),
+		o.es.UpdateByQuery.WithRefresh(true),
+		o.es.UpdateByQuery.WithContext(ctx),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return 0, fmt.Errorf("update_by_query: %s: %s", res.Status(), readBodyString(res.Body))
+	}
+	var out struct {
+		Updated int `json:"updated"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return 0, err
+	}
+	return out.Updated, nil
+}
+
+// ElasticsearchOffboard benchmarks deleting the users of BENCH_OFFBOARD_USERS
+// from the resource docs and checks they hold nothing afterwards; see
+// utils.RunOffboarding. rows counts the docs updated.
+func ElasticsearchOffboard() {
+	es, cleanup, err := infrastructure.NewElasticsearchFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[elasticsearch] failed to create client: %v", err)
+	}
+	defer cleanup()
+
+	utils.RunOffboarding("elasticsearch", userOffboarder{es: es}, newChecker(es))
+	log.Println("[elasticsearch] == Elasticsearch user offboarding DONE ==")
+}
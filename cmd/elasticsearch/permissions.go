@@ -0,0 +1,106 @@
+package elasticsearch
+
+import (
+	"context"
+	"log"
+
+	esv9 "github.com/elastic/go-elasticsearch/v9"
+
+	"test-tls/authz"
+	esauthz "test-tls/authz/elasticsearch"
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// lookupResources streams the resources of IndexName() whose
+// allowed_*_user_id field contains userID, as timed by the lookup_resources_*
+// scenarios, paging as ES_LOOKUP_PAGING says (see lookupPagingFromEnv).
+func lookupResources(ctx context.Context, es *esv9.Client, userID, permission string, handle func(resID string)) error {
+	return esauthz.LookupResources(ctx, es, IndexName(), lookupPagingFromEnv(), userID, permission, handle)
+}
+
+// countResources counts what lookupResources streams without fetching it
+// (see esauthz.CountResources).
+func countResources(ctx context.Context, es *esv9.Client, userID, permission string) (int, error) {
+	return esauthz.CountResources(ctx, es, IndexName(), userID, permission)
+}
+
+// lookupPagingFromEnv returns ES_LOOKUP_PAGING, one of esauthz.LookupPagings
+// (default: search_after).
+func lookupPagingFromEnv() string {
+	paging := utils.GetEnvWithDefault("ES_LOOKUP_PAGING", "search_after")
+	if paging != "search_after" && paging != "from" {
+		log.Fatalf("[elasticsearch] ES_LOOKUP_PAGING=%q: want search_after or from", paging)
+	}
+	return paging
+}
+
+// newChecker is esauthz.NewChecker over IndexName() with the ES_LOOKUP_PAGING
+// of the lookup_resources_* scenarios and the ids of RLP_ID_FORMAT.
+func newChecker(es *esv9.Client) authz.Checker {
+	checker, err := esauthz.NewChecker(es, esauthz.Config{Index: IndexName(), Paging: lookupPagingFromEnv(), IDFormat: ids.CurrentFormat()})
+	if err != nil {
+		log.Fatalf("[elasticsearch] %v", err)
+	}
+	return checker
+}
+
+// ElasticsearchServe serves Check/Lookup over HTTP using the benchmark queries.
+func ElasticsearchServe() {
+	es, cleanup, err := infrastructure.NewElasticsearchFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[elasticsearch] failed to create client: %v", err)
+	}
+	defer cleanup()
+
+	utils.Serve("elasticsearch", newChecker(es))
+}
+
+// ElasticsearchReplayAudit re-runs a recorded audit file (REPLAY_AUDIT_FILE) through
+// the benchmark queries.
+func ElasticsearchReplayAudit() {
+	es, cleanup, err := infrastructure.NewElasticsearchFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[elasticsearch] failed to create client: %v", err)
+	}
+	defer cleanup()
+
+	utils.ReplayAudit("elasticsearch", newChecker(es))
+}
+
+// ElasticsearchVerifyFixture checks the benchmark queries against the known
+// answers of the fixture dataset (see utils.VerifyFixture).
+func ElasticsearchVerifyFixture() {
+	es, cleanup, err := infrastructure.NewElasticsearchFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[elasticsearch] failed to create client: %v", err)
+	}
+	defer cleanup()
+
+	utils.VerifyFixture("elasticsearch", newChecker(es))
+}
+
+// ElasticsearchExportPermissions writes a permission snapshot of the benchmark
+// queries for diff-permissions (see utils.ExportPermissions).
+func ElasticsearchExportPermissions() {
+	es, cleanup, err := infrastructure.NewElasticsearchFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[elasticsearch] failed to create client: %v", err)
+	}
+	defer cleanup()
+
+	utils.ExportPermissions("elasticsearch", newChecker(es))
+}
+
+// ElasticsearchWorker replays the shard a distributed coordinator hands out and
+// streams the results back (see utils.RunWorker).
+func ElasticsearchWorker() {
+	es, cleanup, err := infrastructure.NewElasticsearchFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[elasticsearch] failed to create client: %v", err)
+	}
+	defer cleanup()
+
+	utils.RunWorker("elasticsearch", newChecker(es))
+}
@@ -0,0 +1,51 @@
+package elasticsearch
+
+import (
+	"fmt"
+
+	esauthz "test-tls/authz/elasticsearch"
+	"test-tls/ids"
+	"test-tls/utils"
+)
+
+// ElasticsearchPrintQueries prints the requests of the read scenarios with
+// sampled parameters instead of sending them (see utils.PrintQueries). The
+// check_manage_direct_user, check_manage_org_admin and
+// check_view_via_group_member scenarios time no request per check: they page
+// through the resources of BENCH_LOOKUPRES_MANAGE_USER or
+// BENCH_LOOKUPRES_VIEW_USER, which is what is printed for them.
+func ElasticsearchPrintQueries() {
+	index := IndexName()
+	paging := lookupPagingFromEnv()
+	utils.PrintQueries("elasticsearch", func(s utils.QuerySample) string {
+		switch s.Scenario {
+		case "check_manage_direct_user", "check_manage_org_admin", "check_view_via_group_member":
+			return "# no request per check; the scenario pages through (from+size)\nGET " + index + "/_search\n" + string(checkScrollQuery(s.Scenario))
+		case "check_time_bounded_direct_user":
+			resID, userID := utils.SampleInt(ids.Resource, s.Resource), utils.SampleInt(ids.User, s.User)
+			return "GET " + index + "/_count\n" + timeBoundedQuery(resID, userID, s.Relation)
+		}
+		user := fmt.Sprint(utils.SampleInt(ids.User, s.User))
+		out := "# paging=" + paging + "\nGET " + index + "/_search\n" + esauthz.LookupQuery(paging, user, s.Permission)
+		if utils.GetEnvWithDefault("ES_LOOKUP_COUNT", "true") == "true" {
+			out += "\n# " + s.Scenario + "_count\nGET " + index + "/_search\n" + esauthz.CountQuery(user, s.Permission)
+		}
+		return out
+	})
+}
+
+// checkScrollQuery is the query the check scenario pages through, as its
+// run* function picks it.
+func checkScrollQuery(scenario string) []byte {
+	field, env := "allowed_manage_user_id", "BENCH_LOOKUPRES_MANAGE_USER"
+	if scenario == "check_view_via_group_member" {
+		field, env = "allowed_view_user_id", "BENCH_LOOKUPRES_VIEW_USER"
+	}
+	if user := ids.DecimalEnv(ids.User, env); user != "" {
+		return buildTermQuery(field, user)
+	}
+	if scenario == "check_manage_org_admin" {
+		return matchAllQuery()
+	}
+	return buildExistsQuery(field)
+}
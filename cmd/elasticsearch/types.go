@@ -1,5 +1,7 @@
 package elasticsearch
 
+import "time"
+
 // Helper types and constants used by indexing utilities.
 
 // intSet is a small helper set for integer IDs.
@@ -10,23 +12,36 @@ func (s intSet) add(v int) {
 }
 
 // aclEntry represents a single ACL assignment used for optional auditing.
+// ValidFrom / ValidUntil are set only for time-bounded grants.
 type aclEntry struct {
-	SubjectType string `json:"subject_type"`
-	SubjectID   int    `json:"subject_id"`
-	Relation    string `json:"relation"`
+	SubjectType string     `json:"subject_type"`
+	SubjectID   int        `json:"subject_id"`
+	Relation    string     `json:"relation"`
+	ValidFrom   *time.Time `json:"valid_from,omitempty"`
+	ValidUntil  *time.Time `json:"valid_until,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// resourceTimes holds the optional created_at / updated_at of a resource.
+type resourceTimes struct {
+	created, updated *time.Time
 }
 
 // resourceDoc is the denormalized document stored in Elasticsearch.
 type resourceDoc struct {
 	ResourceID          int        `json:"resource_id"`
 	OrgID               int        `json:"org_id"`
+	CreatedAt           *time.Time `json:"created_at,omitempty"`
+	UpdatedAt           *time.Time `json:"updated_at,omitempty"`
 	ACL                 []aclEntry `json:"acl,omitempty"`
 	AllowedManageUserID []int      `json:"allowed_manage_user_id,omitempty"`
 	AllowedViewUserID   []int      `json:"allowed_view_user_id,omitempty"`
 }
 
-// Bulk helpers defaults; can be tuned via future env wiring if needed.
+// Bulk helpers defaults; batch size and workers can be overridden via
+// ES_BULK_BATCH_SIZE and ES_BULK_WORKERS.
 const (
 	esBulkTimeoutSec = 60
 	esBulkBatchSize  = 1000
+	esBulkWorkers    = 4
 )
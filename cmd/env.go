@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"test-tls/utils"
+)
+
+// envSources maps each variable an env file or flag set to where it came
+// from, for `config show`.
+var envSources = map[string]string{}
+
+// envLayers are the env files main loads, in order; a later file overrides
+// an earlier one, and all of them override the environment (the benchmark
+// scripts source .env and rely on .env.bench winning):
+//
+//	.env            shared settings
+//	.env.local      the machine's own overrides, not committed
+//	.env.<module>   a backend's settings that conflict with another's
+//	.env.bench      the bench users of `csv generate` and the scripts' runs
+func envLayers(module string) []string {
+	layers := []string{".env", ".env.local"}
+	if module != "" {
+		layers = append(layers, ".env."+module)
+	}
+	return append(layers, utils.BenchEnvPath)
+}
+
+// envModule returns the module of the command line, the first argument
+// naming one, so `config show postgres` layers .env.postgres too.
+func envModule(args []string) string {
+	for _, a := range args {
+		if _, ok := commands[a]; ok {
+			return a
+		}
+	}
+	return ""
+}
+
+// loadEnvLayers loads envLayers(module). Only a missing .env is worth a
+// warning; the other layers are optional.
+func loadEnvLayers(module string) {
+	for _, path := range envLayers(module) {
+		if _, err := os.Stat(path); err != nil && path != ".env" {
+			continue
+		}
+		if err := loadEnvFile(path); err != nil {
+			log.Printf("WARN: could not load env file %s: %v", path, err)
+		}
+	}
+}
+
+// environ returns the environment as a map.
+func environ() map[string]string {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+	return env
+}
+
+// recordFlagSources marks the variables the flags set or changed since
+// before was taken.
+func recordFlagSources(before map[string]string) {
+	for k, v := range environ() {
+		if old, ok := before[k]; !ok || old != v {
+			envSources[k] = "flag"
+		}
+	}
+}
+
+// loadEnvFile reads a simple KEY=VALUE env file and sets variables.
+// Lines starting with '#' are treated as comments; blank lines are skipped.
+// Values can be quoted with single or double quotes; surrounding quotes are trimmed.
+func loadEnvFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		// If the file doesn't exist, return a descriptive error so caller can warn.
+		if os.IsNotExist(err) {
+			return fmt.Errorf("env file not found: %s", path)
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Support export KEY=VALUE lines
+		if strings.HasPrefix(line, "export ") {
+			line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		}
+		// Split on first '=' only
+		eq := strings.IndexRune(line, '=')
+		if eq <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:eq])
+		val := strings.TrimSpace(line[eq+1:])
+		// Trim surrounding quotes
+		if len(val) >= 2 {
+			if (val[0] == '\'' && val[len(val)-1] == '\'') || (val[0] == '"' && val[len(val)-1] == '"') {
+				val = val[1 : len(val)-1]
+			}
+		}
+		// Expand existing env references like ${VAR}
+		val = os.ExpandEnv(val)
+		_ = os.Setenv(key, val)
+		envSources[key] = path
+	}
+	return scanner.Err()
+}
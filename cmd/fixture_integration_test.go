@@ -0,0 +1,292 @@
+//go:build integration
+
+package main
+
+// The fixture tests load testdata/fixture into every backend and compare the
+// checks and lookups of testdata/fixture/expected.json, as verify-fixture
+// does, through the importable checkers of the authz/ packages:
+//
+//	go test -tags integration ./cmd
+//	go test -tags integration ./cmd -run TestFixture/postgres
+//
+// Each backend is dropped, recreated and loaded under RLP_NAMESPACE=fixture,
+// next to any benchmark dataset. SpiceDB has no namespaces, so the authzed_*
+// backends are dropped outright and only run with FIXTURE_SPICEDB=true.
+// Backends that cannot be reached are skipped; the connection env vars are
+// those of the CLI.
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"test-tls/authz"
+	chauthz "test-tls/authz/clickhouse"
+	crdbauthz "test-tls/authz/cockroachdb"
+	esauthz "test-tls/authz/elasticsearch"
+	mongoauthz "test-tls/authz/mongodb"
+	pgauthz "test-tls/authz/postgres"
+	scyllaauthz "test-tls/authz/scylladb"
+	"test-tls/authz/spicedb"
+	"test-tls/cmd/authzed_crdb"
+	"test-tls/cmd/authzed_pgdb"
+	"test-tls/cmd/clickhouse"
+	"test-tls/cmd/cockroachdb"
+	"test-tls/cmd/elasticsearch"
+	"test-tls/cmd/mongodb"
+	"test-tls/cmd/postgres"
+	"test-tls/cmd/scylladb"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// fixtureBackend is one backend under test: load drops, recreates and loads
+// it from RLP_DATA_DIR, connect returns its checker in one of variants (the
+// group resolution, paging or consistency modes the checker takes). unsupported
+// lists the scenarios a variant does not model, with the reason.
+type fixtureBackend struct {
+	name        string
+	spicedb     bool
+	load        func()
+	variants    []string
+	unsupported map[string]map[string]string
+	connect     func(ctx context.Context, variant string) (authz.Checker, func(), error)
+}
+
+var fixtureBackends = []fixtureBackend{
+	{
+		name: "postgres",
+		load: func() {
+			postgres.PostgresDropSchemas()
+			postgres.PostgresCreateSchemas()
+			postgres.PostgresCreateData()
+		},
+		variants: pgauthz.GroupResolutions,
+		connect: func(ctx context.Context, variant string) (authz.Checker, func(), error) {
+			db, cleanup, err := infrastructure.NewPostgresFromEnv(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			checker, err := pgauthz.NewChecker(db, pgauthz.Config{GroupResolution: variant})
+			return checker, cleanup, err
+		},
+	},
+	{
+		name: "cockroachdb",
+		load: func() {
+			cockroachdb.CockroachdbDropSchemas()
+			cockroachdb.CockroachdbCreateSchemas()
+			cockroachdb.CockroachdbCreateData()
+			cockroachdb.CockroachdbRefreshUserResourcePermissions()
+		},
+		// direct ignores groups and fails the group scenarios.
+		variants: []string{"view", "cte", "closure"},
+		connect: func(ctx context.Context, variant string) (authz.Checker, func(), error) {
+			db, cleanup, err := infrastructure.NewCockroachDBFromEnv(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			checker, err := crdbauthz.NewChecker(db, crdbauthz.Config{GroupResolution: variant})
+			return checker, cleanup, err
+		},
+	},
+	{
+		name: "clickhouse",
+		load: func() {
+			clickhouse.ClickhouseDropSchemas()
+			clickhouse.ClickhouseCreateSchemas()
+			clickhouse.ClickhouseCreateData()
+		},
+		variants: []string{"default"},
+		connect: func(ctx context.Context, _ string) (authz.Checker, func(), error) {
+			db, cleanup, err := infrastructure.NewClickhouseFromEnv(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			checker, err := chauthz.NewChecker(db, chauthz.Config{})
+			return checker, cleanup, err
+		},
+	},
+	{
+		name: "scylladb",
+		load: func() {
+			scylladb.ScylladbDropSchemas()
+			scylladb.ScylladbCreateSchemas()
+			scylladb.ScylladbCreateData()
+		},
+		variants: []string{"default"},
+		connect: func(ctx context.Context, _ string) (authz.Checker, func(), error) {
+			session, cleanup, err := infrastructure.NewScyllaFromEnv(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			checker, err := scyllaauthz.NewChecker(session, scyllaauthz.Config{})
+			return checker, cleanup, err
+		},
+	},
+	{
+		name: "mongodb",
+		load: func() {
+			mongodb.MongodbDropSchemas()
+			mongodb.MongodbCreateSchemas()
+			mongodb.MongodbCreateData()
+		},
+		variants: mongoauthz.GroupModes,
+		unsupported: map[string]map[string]string{"direct": {
+			"check_manage_via_nested_group": "direct does not expand nested groups",
+			"check_view_via_nested_group":   "direct does not expand nested groups",
+		}},
+		connect: func(ctx context.Context, variant string) (authz.Checker, func(), error) {
+			_, db, cleanup, err := infrastructure.NewMongoFromEnv(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			checker, err := mongoauthz.NewChecker(db, mongoauthz.Config{GroupMode: variant})
+			return checker, cleanup, err
+		},
+	},
+	{
+		name: "elasticsearch",
+		load: func() {
+			elasticsearch.ElasticsearchDropSchemas()
+			elasticsearch.ElasticsearchCreateSchemas()
+			elasticsearch.ElasticsearchCreateData()
+		},
+		variants: esauthz.LookupPagings,
+		connect: func(ctx context.Context, variant string) (authz.Checker, func(), error) {
+			es, cleanup, err := infrastructure.NewElasticsearchFromEnv(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			checker, err := esauthz.NewChecker(es, esauthz.Config{Index: elasticsearch.IndexName(), Paging: variant})
+			return checker, cleanup, err
+		},
+	},
+	{
+		name:    "authzed_crdb",
+		spicedb: true,
+		load: func() {
+			authzed_crdb.AuthzedDropSchemas()
+			authzed_crdb.AuthzedCreateSchema()
+			authzed_crdb.AuthzedCreateData()
+		},
+		variants: spicedb.Consistencies,
+		connect: func(ctx context.Context, variant string) (authz.Checker, func(), error) {
+			client, _, cancel, err := infrastructure.NewAuthzedCrdbClientFromEnv(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			cleanup := func() { cancel(); client.Close() }
+			consistency, err := spicedb.ParseConsistency(ctx, client, variant)
+			return spicedb.NewChecker(client, spicedb.Config{Consistency: consistency}), cleanup, err
+		},
+	},
+	{
+		name:    "authzed_pgdb",
+		spicedb: true,
+		load: func() {
+			authzed_pgdb.AuthzedDropSchemas()
+			authzed_pgdb.AuthzedCreateSchema()
+			authzed_pgdb.AuthzedCreateData()
+		},
+		variants: spicedb.Consistencies,
+		connect: func(ctx context.Context, variant string) (authz.Checker, func(), error) {
+			client, _, cancel, err := infrastructure.NewAuthzedPgdbClientFromEnv(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			cleanup := func() { cancel(); client.Close() }
+			consistency, err := spicedb.ParseConsistency(ctx, client, variant)
+			return spicedb.NewChecker(client, spicedb.Config{Consistency: consistency}), cleanup, err
+		},
+	},
+}
+
+// TestMain runs the tests from the repository root, where the CLI runs, so
+// the default paths (testdata/, docker/spicedb/cert.pem) resolve the same.
+func TestMain(m *testing.M) {
+	if err := os.Chdir(".."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// TestFixture runs every scenario of utils.Scenarios against every backend
+// and variant, with the cases of testdata/fixture/expected.json.
+func TestFixture(t *testing.T) {
+	want, err := utils.ReadFixtureExpectations(filepath.Join("testdata", "fixture", "expected.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RLP_DATA_DIR", filepath.Join("testdata", "fixture"))
+	t.Setenv("DROP_FORCE", "true")
+	t.Setenv("DROP_SNAPSHOT_DIR", t.TempDir())
+
+	for _, b := range fixtureBackends {
+		t.Run(b.name, func(t *testing.T) {
+			if b.spicedb {
+				if os.Getenv("FIXTURE_SPICEDB") != "true" {
+					t.Skip("drops every relationship of the instance; set FIXTURE_SPICEDB=true to run")
+				}
+				// schema1 and schema2 do not nest management
+				t.Setenv("SPICEDB_SCHEMA", "schema3")
+			} else {
+				t.Setenv("RLP_NAMESPACE", "fixture")
+			}
+
+			ctx := context.Background()
+			_, cleanup, err := b.connect(ctx, b.variants[0])
+			if err != nil {
+				t.Skipf("%s not reachable: %v", b.name, err)
+			}
+			cleanup()
+			b.load()
+
+			for _, variant := range b.variants {
+				t.Run(variant, func(t *testing.T) {
+					checker, cleanup, err := b.connect(ctx, variant)
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer cleanup()
+
+					for _, sem := range utils.Scenarios {
+						t.Run(sem.Scenario, func(t *testing.T) {
+							if reason, ok := b.unsupported[variant][sem.Scenario]; ok {
+								t.Skip(reason)
+							}
+							cases := 0
+							for _, c := range want.Checks {
+								if c.Scenario != sem.Scenario {
+									continue
+								}
+								cases++
+								ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+								if err := c.Verify(ctx, checker); err != nil {
+									t.Errorf("%v (%s is allowed by %s)", err, sem.Permission, sem.Allows)
+								}
+								cancel()
+							}
+							for _, l := range want.Lookups {
+								if l.Scenario != sem.Scenario {
+									continue
+								}
+								cases++
+								ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+								if err := l.Verify(ctx, checker); err != nil {
+									t.Errorf("%v (%s is allowed by %s)", err, sem.Permission, sem.Allows)
+								}
+								cancel()
+							}
+							if cases == 0 {
+								t.Errorf("no cases in expected.json")
+							}
+						})
+					}
+				})
+			}
+		})
+	}
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
-	"bufio"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 
+	esauthz "test-tls/authz/elasticsearch"
+	mongoauthz "test-tls/authz/mongodb"
 	"test-tls/cmd/authzed_crdb"
 	"test-tls/cmd/authzed_pgdb"
 	"test-tls/cmd/clickhouse"
@@ -17,6 +22,8 @@ import (
 	"test-tls/cmd/mongodb"
 	"test-tls/cmd/postgres"
 	"test-tls/cmd/scylladb"
+	"test-tls/infrastructure"
+	"test-tls/utils"
 )
 
 // handler is a function that handles a module/subcommand.
@@ -24,15 +31,48 @@ type handler func(args []string) error
 
 // modules maps module names to their handlers.
 var modules = map[string]handler{
-	"csv":           runCsv,
-	"authzed_crdb":  runAuthzedCrdb,
-	"authzed_pgdb":  runAuthzedPgdb,
-	"clickhouse":    runClickhouse,
-	"cockroachdb":   runCockroachdb,
-	"postgres":      runPostgres,
-	"mongodb":       runMongodb,
-	"scylladb":      runScylladb,
-	"elasticsearch": runElasticsearch,
+	"csv":              runCsv,
+	"authzed_crdb":     runAuthzedCrdb,
+	"authzed_pgdb":     runAuthzedPgdb,
+	"clickhouse":       runClickhouse,
+	"cockroachdb":      runCockroachdb,
+	"postgres":         runPostgres,
+	"mongodb":          runMongodb,
+	"scylladb":         runScylladb,
+	"elasticsearch":    runElasticsearch,
+	"capabilities":     runCapabilities,
+	"diff-permissions": runDiffPermissions,
+	"config":           runConfig,
+	"coordinator":      runCoordinator,
+	"probe":            runProbe,
+	"data":             runData,
+}
+
+// capabilities maps backend modules to what they support, in report order.
+var capabilities = []struct {
+	module string
+	caps   func() utils.Capabilities
+}{
+	{"authzed_crdb", authzed_crdb.Capabilities},
+	{"authzed_pgdb", authzed_pgdb.Capabilities},
+	{"cockroachdb", cockroachdb.Capabilities},
+	{"postgres", postgres.Capabilities},
+	{"scylladb", scylladb.Capabilities},
+	{"clickhouse", clickhouse.Capabilities},
+	{"elasticsearch", elasticsearch.Capabilities},
+	{"mongodb", mongodb.Capabilities},
+}
+
+// queryPrinters are the --print-queries dry runs of `<module> benchmark`.
+var queryPrinters = map[string]func(){
+	"authzed_crdb":  authzed_crdb.AuthzedPrintQueries,
+	"authzed_pgdb":  authzed_pgdb.AuthzedPrintQueries,
+	"cockroachdb":   cockroachdb.CockroachdbPrintQueries,
+	"postgres":      postgres.PostgresPrintQueries,
+	"scylladb":      scylladb.ScylladbPrintQueries,
+	"clickhouse":    clickhouse.ClickhousePrintQueries,
+	"elasticsearch": elasticsearch.ElasticsearchPrintQueries,
+	"mongodb":       mongodb.MongodbPrintQueries,
 }
 
 func main() {
@@ -40,19 +80,206 @@ func main() {
 	// Use microsecond precision (includes milliseconds) for readable timing.
 	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
 
-	// Load root .env first, then benchmark env overrides if present.
-	if err := loadEnvFile(".env"); err != nil {
-		log.Printf("WARN: could not load env file .env: %v", err)
-	}
+	// Load the env file layers (.env, .env.local, .env.<module>), then the
+	// bench users `csv generate` recorded (.env.bench, then the dataset's
+	// manifest.json for anything still unset, once the flags have picked
+	// the dataset).
+	loadEnvLayers(envModule(os.Args[1:]))
 
-	if err := dispatch(os.Args[1:]); err != nil {
+	env := environ()
+	args, err := parseArgs(os.Args[1:])
+	recordFlagSources(env)
+	if errors.Is(err, flag.ErrHelp) {
+		help(args)
+		return
+	}
+	utils.ApplyManifestBenchUsers()
+	if err == nil && (len(args) == 0 || args[0] != "config") {
+		err = utils.ValidateEnv()
+	}
+	if err == nil {
+		err = dispatch(args)
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		fmt.Fprintln(os.Stderr)
-		usage()
+		help(args)
 		os.Exit(1)
 	}
 }
 
+// flagUsage describes the global flags for help output, in order.
+var flagUsage = []struct{ name, help string }{
+	{"--orgs=1-8", "RLP_ORGS, restrict load-data, benchmark and csv targets to these orgs"},
+	{"--schema=NAME", "the schema or modeling variant: SPICEDB_SCHEMA for authzed_*, MONGO_GROUP_MODE and MONGO_LOOKUP_MODE for mongodb (e.g. expanded,count), ES_LOOKUP_PAGING for elasticsearch"},
+	{"--iters=N", "every BENCH_CHECK_*_ITER and BENCH_LOOKUPRES_*_ITER, for quick benchmark runs"},
+	{"--force", "DROP_FORCE=true and BENCH_DATASET_FORCE=true, allow drop of more than DROP_FORCE_THRESHOLD rows and benchmarks of another dataset than RLP_DATA_DIR's"},
+	{"--tui", "BENCH_TUI=true, show a live dashboard instead of the log during benchmark"},
+	{"--gomaxprocs=N", "BENCH_GOMAXPROCS, cap the CPUs the benchmark client uses"},
+	{"--output=ndjson", "BENCH_OUTPUT, stream benchmark results to stdout as NDJSON (log on stderr)"},
+	{"--data-dir=DIR", "RLP_DATA_DIR, the dataset csv generate writes and every other command reads"},
+	{"--keep-latest=N", "RLP_DATA_KEEP_LATEST, archived datasets data clean keeps"},
+	{"--print-queries", "BENCH_PRINT_QUERIES=true, benchmark prints the queries it would run instead of running them"},
+	{"-h, --help", "show help for the module or action"},
+}
+
+// iterVars are the benchmark iteration counts --iters sets.
+var iterVars = []string{
+	"BENCH_CHECK_DIRECT_SUPER_ITER",
+	"BENCH_CHECK_ORGADMIN_ITER",
+	"BENCH_CHECK_VIEW_GROUP_ITER",
+	"BENCH_CHECK_TIME_BOUNDED_ITER",
+	"BENCH_CHECK_BULK_ITER",
+	"BENCH_CHECK_VIRAL_ITER",
+	"BENCH_CHECK_WORST_ITER",
+	"BENCH_CHECK_BATCH_ITER",
+	"BENCH_LOOKUPRES_MANAGE_ITER",
+	"BENCH_LOOKUPRES_VIEW_ITER",
+	"BENCH_LOOKUPRES_VIRAL_ITER",
+	"BENCH_LOOKUPRES_MIX_ITER",
+	"BENCH_LOOKUPRES_PCT_ITER",
+}
+
+// schemaVariant is an env var --schema sets and the values it takes.
+type schemaVariant struct {
+	env    string
+	values []string
+}
+
+// schemaVariants are the modeling variants --schema picks in the modules
+// that are not authzed_*, whose --schema is a SPICEDB_SCHEMA name. NAME is a
+// comma-separated list of values, each setting the variable that takes it:
+// `mongodb benchmark --schema=expanded,count`.
+var schemaVariants = map[string][]schemaVariant{
+	"mongodb": {
+		{env: "MONGO_GROUP_MODE", values: mongoauthz.GroupModes},
+		{env: "MONGO_LOOKUP_MODE", values: mongodb.LookupModes},
+	},
+	"elasticsearch": {
+		{env: "ES_LOOKUP_PAGING", values: esauthz.LookupPagings},
+	},
+}
+
+// setSchema applies --schema=name to module: the variables of its
+// schemaVariants, or SPICEDB_SCHEMA for every other module.
+func setSchema(module, name string) error {
+	variants, ok := schemaVariants[module]
+	if !ok {
+		os.Setenv("SPICEDB_SCHEMA", name)
+		return nil
+	}
+	for _, value := range strings.Split(name, ",") {
+		i := slices.IndexFunc(variants, func(v schemaVariant) bool { return slices.Contains(v.values, value) })
+		if i < 0 {
+			var all []string
+			for _, v := range variants {
+				all = append(all, v.values...)
+			}
+			return fmt.Errorf("--schema: %s has no variant %q (want %s)", module, value, strings.Join(all, ", "))
+		}
+		os.Setenv(variants[i].env, value)
+	}
+	return nil
+}
+
+// parseArgs parses the global flags, which may appear anywhere on the command
+// line, applies them as their env var equivalents (so they win over .env) and
+// returns the remaining positional args. On -h/--help it returns the
+// positional args seen so far with flag.ErrHelp, so help can be scoped to
+// them.
+func parseArgs(args []string) ([]string, error) {
+	var (
+		orgs, schema string
+		output       string
+		dataDir      string
+		iters, procs int
+		keep         int
+		force, tui   bool
+		printQueries bool
+	)
+	fs := flag.NewFlagSet("main", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&orgs, "orgs", "", "")
+	fs.StringVar(&schema, "schema", "", "")
+	fs.IntVar(&iters, "iters", 0, "")
+	fs.BoolVar(&force, "force", false, "")
+	fs.BoolVar(&tui, "tui", false, "")
+	fs.IntVar(&procs, "gomaxprocs", 0, "")
+	fs.StringVar(&output, "output", "", "")
+	fs.StringVar(&dataDir, "data-dir", "", "")
+	fs.IntVar(&keep, "keep-latest", 0, "")
+	fs.BoolVar(&printQueries, "print-queries", false, "")
+
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return rest, err
+			}
+			return rest, fmt.Errorf("flags: %w", err)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "orgs":
+			if _, perr := utils.ParseOrgRanges(orgs); perr != nil {
+				err = fmt.Errorf("--orgs: %w", perr)
+			}
+			os.Setenv("RLP_ORGS", orgs)
+		case "schema":
+			module := ""
+			if len(rest) > 0 {
+				module = rest[0]
+			}
+			if serr := setSchema(module, schema); serr != nil {
+				err = serr
+			}
+		case "iters":
+			if iters <= 0 {
+				err = fmt.Errorf("--iters: must be positive, got %d", iters)
+			}
+			for _, v := range iterVars {
+				os.Setenv(v, strconv.Itoa(iters))
+			}
+		case "force":
+			os.Setenv("DROP_FORCE", strconv.FormatBool(force))
+			os.Setenv("BENCH_DATASET_FORCE", strconv.FormatBool(force))
+		case "tui":
+			os.Setenv("BENCH_TUI", strconv.FormatBool(tui))
+		case "gomaxprocs":
+			if procs <= 0 {
+				err = fmt.Errorf("--gomaxprocs: must be positive, got %d", procs)
+			}
+			os.Setenv("BENCH_GOMAXPROCS", strconv.Itoa(procs))
+		case "output":
+			if output != "text" && output != "ndjson" {
+				err = fmt.Errorf("--output: want text or ndjson, got %q", output)
+			}
+			os.Setenv("BENCH_OUTPUT", output)
+		case "data-dir":
+			if dataDir == "" {
+				err = errors.New("--data-dir: must not be empty")
+			}
+			os.Setenv("RLP_DATA_DIR", dataDir)
+		case "keep-latest":
+			if keep < 0 {
+				err = fmt.Errorf("--keep-latest: must not be negative, got %d", keep)
+			}
+			os.Setenv("RLP_DATA_KEEP_LATEST", strconv.Itoa(keep))
+		case "print-queries":
+			os.Setenv("BENCH_PRINT_QUERIES", strconv.FormatBool(printQueries))
+		}
+	})
+	return rest, err
+}
+
 // dispatch picks the module from args[0] and forwards the rest to it.
 func dispatch(args []string) error {
 	if len(args) == 0 {
@@ -65,12 +292,39 @@ func dispatch(args []string) error {
 		return fmt.Errorf("unknown module: %s", moduleName)
 	}
 
+	if len(args) > 1 && args[1] == "benchmark" {
+		if os.Getenv("BENCH_PRINT_QUERIES") == "true" {
+			printQueries, ok := queryPrinters[moduleName]
+			if !ok {
+				return fmt.Errorf("%s: --print-queries is not supported", moduleName)
+			}
+			printQueries()
+			return nil
+		}
+		if os.Getenv("BENCH_TUI") == "true" {
+			stop := utils.StartDashboard(moduleName)
+			defer stop()
+		}
+		if os.Getenv("BENCH_OUTPUT") == "ndjson" {
+			stop := utils.StartNDJSONOutput(moduleName)
+			defer stop()
+		}
+		stopOracle := utils.StartOracle(moduleName)
+		defer stopOracle()
+		stopHDR := utils.StartHDR(moduleName)
+		defer stopHDR()
+		stopSlowest := utils.StartSlowest(moduleName)
+		defer stopSlowest()
+		defer utils.FlushShedding()
+		utils.ApplyRunnerLimits(moduleName)
+	}
+
 	return handler(args[1:])
 }
 
 func runCsv(args []string) error {
 	if len(args) == 0 {
-		return errors.New(`missing action for csv (expected: "generate")`)
+		return missingAction("csv")
 	}
 
 	action := args[0]
@@ -79,14 +333,20 @@ func runCsv(args []string) error {
 	case "generate":
 		csv.CsvCreateData()
 		return nil
+	case "targets":
+		csv.CsvGenerateTargets()
+		return nil
+	case "import-production":
+		csv.CsvImportProduction()
+		return nil
 	default:
-		return fmt.Errorf("unknown action for csv: %s", action)
+		return unknownAction("csv", action)
 	}
 }
 
 func runAuthzedCrdb(args []string) error {
 	if len(args) == 0 {
-		return errors.New(`missing action for authzed_crdb (expected: "drop|create-schema|load-data|benchmark")`)
+		return missingAction("authzed_crdb")
 	}
 
 	action := args[0]
@@ -98,10 +358,33 @@ func runAuthzedCrdb(args []string) error {
 		authzed_crdb.AuthzedCreateSchema()
 	case "load-data":
 		authzed_crdb.AuthzedCreateData()
+	case "plan-load":
+		utils.PlanLoad("authzed_crdb")
 	case "benchmark":
 		authzed_crdb.AuthzedBenchmarkReads()
+	case "serve":
+		infrastructure.UseBenchCredentials()
+		authzed_crdb.AuthzedServe()
+	case "replay-audit":
+		infrastructure.UseBenchCredentials()
+		authzed_crdb.AuthzedReplayAudit()
+	case "verify-fixture":
+		authzed_crdb.AuthzedVerifyFixture()
+	case "export-permissions":
+		authzed_crdb.AuthzedExportPermissions()
+	case "worker":
+		infrastructure.UseBenchCredentials()
+		authzed_crdb.AuthzedWorker()
+	case "analyze":
+		return runAnalyze("authzed_crdb", args[1:], authzed_crdb.AuthzedAnalyzeStats)
+	case "offboard":
+		authzed_crdb.AuthzedOffboard()
+	case "schema":
+		return runSchema("authzed_crdb", args[1:], authzed_crdb.AuthzedSchemaWrite, authzed_crdb.AuthzedSchemaRead, authzed_crdb.AuthzedSchemaDiff)
+	case "export":
+		authzed_crdb.AuthzedExport()
 	default:
-		return fmt.Errorf("unknown action for authzed_crdb: %s", action)
+		return unknownAction("authzed_crdb", action)
 	}
 
 	return nil
@@ -109,7 +392,7 @@ func runAuthzedCrdb(args []string) error {
 
 func runAuthzedPgdb(args []string) error {
 	if len(args) == 0 {
-		return errors.New(`missing action for authzed_pgdb (expected: "drop|create-schema|load-data|benchmark")`)
+		return missingAction("authzed_pgdb")
 	}
 
 	action := args[0]
@@ -121,10 +404,33 @@ func runAuthzedPgdb(args []string) error {
 		authzed_pgdb.AuthzedCreateSchema()
 	case "load-data":
 		authzed_pgdb.AuthzedCreateData()
+	case "plan-load":
+		utils.PlanLoad("authzed_pgdb")
 	case "benchmark":
 		authzed_pgdb.AuthzedBenchmarkReads()
+	case "serve":
+		infrastructure.UseBenchCredentials()
+		authzed_pgdb.AuthzedServe()
+	case "replay-audit":
+		infrastructure.UseBenchCredentials()
+		authzed_pgdb.AuthzedReplayAudit()
+	case "verify-fixture":
+		authzed_pgdb.AuthzedVerifyFixture()
+	case "export-permissions":
+		authzed_pgdb.AuthzedExportPermissions()
+	case "worker":
+		infrastructure.UseBenchCredentials()
+		authzed_pgdb.AuthzedWorker()
+	case "analyze":
+		return runAnalyze("authzed_pgdb", args[1:], authzed_pgdb.AuthzedAnalyzeStats)
+	case "offboard":
+		authzed_pgdb.AuthzedOffboard()
+	case "schema":
+		return runSchema("authzed_pgdb", args[1:], authzed_pgdb.AuthzedSchemaWrite, authzed_pgdb.AuthzedSchemaRead, authzed_pgdb.AuthzedSchemaDiff)
+	case "export":
+		authzed_pgdb.AuthzedExport()
 	default:
-		return fmt.Errorf("unknown action for authzed_pgdb: %s", action)
+		return unknownAction("authzed_pgdb", action)
 	}
 
 	return nil
@@ -132,7 +438,7 @@ func runAuthzedPgdb(args []string) error {
 
 func runClickhouse(args []string) error {
 	if len(args) == 0 {
-		return errors.New(`missing action for clickhouse (expected: "drop|create-schema|load-data|benchmark")`)
+		return missingAction("clickhouse")
 	}
 
 	action := args[0]
@@ -144,10 +450,36 @@ func runClickhouse(args []string) error {
 		clickhouse.ClickhouseCreateSchemas()
 	case "load-data":
 		clickhouse.ClickhouseCreateData()
+	case "plan-load":
+		utils.PlanLoad("clickhouse")
 	case "benchmark":
+		infrastructure.UseBenchCredentials()
 		clickhouse.ClickhouseBenchmarkReads()
+	case "serve":
+		infrastructure.UseBenchCredentials()
+		clickhouse.ClickhouseServe()
+	case "replay-audit":
+		infrastructure.UseBenchCredentials()
+		clickhouse.ClickhouseReplayAudit()
+	case "verify-fixture":
+		clickhouse.ClickhouseVerifyFixture()
+	case "export-permissions":
+		clickhouse.ClickhouseExportPermissions()
+	case "worker":
+		infrastructure.UseBenchCredentials()
+		clickhouse.ClickhouseWorker()
+	case "analyze":
+		return runAnalyze("clickhouse", args[1:], clickhouse.ClickhouseAnalyzeStats)
+	case "offboard":
+		clickhouse.ClickhouseOffboard()
+	case "sort-keys":
+		clickhouse.ClickhouseSortKeys()
+	case "group-churn":
+		clickhouse.ClickhouseGroupChurn()
+	case "bitmaps":
+		clickhouse.ClickhouseBitmaps()
 	default:
-		return fmt.Errorf("unknown action for clickhouse: %s", action)
+		return unknownAction("clickhouse", action)
 	}
 
 	return nil
@@ -155,7 +487,7 @@ func runClickhouse(args []string) error {
 
 func runCockroachdb(args []string) error {
 	if len(args) == 0 {
-		return errors.New(`missing action for cockroachdb (expected: "drop|create-schema|load-data|benchmark")`)
+		return missingAction("cockroachdb")
 	}
 
 	action := args[0]
@@ -168,10 +500,34 @@ func runCockroachdb(args []string) error {
 	case "load-data":
 		cockroachdb.CockroachdbCreateData()
 		cockroachdb.CockroachdbRefreshUserResourcePermissions()
+	case "plan-load":
+		utils.PlanLoad("cockroachdb")
 	case "benchmark":
+		infrastructure.UseBenchCredentials()
 		cockroachdb.CockroachdbBenchmarkReads()
+	case "serve":
+		infrastructure.UseBenchCredentials()
+		cockroachdb.CockroachdbServe()
+	case "replay-audit":
+		infrastructure.UseBenchCredentials()
+		cockroachdb.CockroachdbReplayAudit()
+	case "verify-fixture":
+		cockroachdb.CockroachdbVerifyFixture()
+	case "export-permissions":
+		cockroachdb.CockroachdbExportPermissions()
+	case "worker":
+		infrastructure.UseBenchCredentials()
+		cockroachdb.CockroachdbWorker()
+	case "analyze":
+		return runAnalyze("cockroachdb", args[1:], cockroachdb.CockroachdbAnalyzeStats)
+	case "offboard":
+		cockroachdb.CockroachdbOffboard()
+	case "group-closure":
+		cockroachdb.CockroachdbGroupClosure()
+	case "group-churn":
+		cockroachdb.CockroachdbGroupChurn()
 	default:
-		return fmt.Errorf("unknown action for cockroachdb: %s", action)
+		return unknownAction("cockroachdb", action)
 	}
 
 	return nil
@@ -179,7 +535,7 @@ func runCockroachdb(args []string) error {
 
 func runPostgres(args []string) error {
 	if len(args) == 0 {
-		return errors.New(`missing action for postgres (expected: "drop|create-schema|load-data|benchmark")`)
+		return missingAction("postgres")
 	}
 
 	action := args[0]
@@ -191,10 +547,38 @@ func runPostgres(args []string) error {
 		postgres.PostgresCreateSchemas()
 	case "load-data":
 		postgres.PostgresCreateData()
+	case "plan-load":
+		utils.PlanLoad("postgres")
 	case "benchmark":
+		infrastructure.UseBenchCredentials()
 		postgres.PostgresBenchmarkReads()
+	case "serve":
+		infrastructure.UseBenchCredentials()
+		postgres.PostgresServe()
+	case "replay-audit":
+		infrastructure.UseBenchCredentials()
+		postgres.PostgresReplayAudit()
+	case "verify-fixture":
+		postgres.PostgresVerifyFixture()
+	case "export-permissions":
+		postgres.PostgresExportPermissions()
+	case "worker":
+		infrastructure.UseBenchCredentials()
+		postgres.PostgresWorker()
+	case "analyze":
+		return runAnalyze("postgres", args[1:], postgres.PostgresAnalyzeStats)
+	case "offboard":
+		postgres.PostgresOffboard()
+	case "group-closure":
+		postgres.PostgresGroupClosure()
+	case "group-churn":
+		postgres.PostgresGroupChurn()
+	case "bitmaps":
+		postgres.PostgresBitmaps()
+	case "permission-refresh":
+		postgres.PostgresPermissionRefresh()
 	default:
-		return fmt.Errorf("unknown action for postgres: %s", action)
+		return unknownAction("postgres", action)
 	}
 
 	return nil
@@ -202,7 +586,7 @@ func runPostgres(args []string) error {
 
 func runMongodb(args []string) error {
 	if len(args) == 0 {
-		return errors.New(`missing action for mongodb (expected: "drop|create-schema|load-data|benchmark")`)
+		return missingAction("mongodb")
 	}
 
 	action := args[0]
@@ -214,10 +598,33 @@ func runMongodb(args []string) error {
 		mongodb.MongodbCreateSchemas()
 	case "load-data":
 		mongodb.MongodbCreateData()
+	case "plan-load":
+		utils.PlanLoad("mongodb")
 	case "benchmark":
+		infrastructure.UseBenchCredentials()
 		mongodb.MongodbBenchmarkReads()
+	case "serve":
+		infrastructure.UseBenchCredentials()
+		mongodb.MongodbServe()
+	case "replay-audit":
+		infrastructure.UseBenchCredentials()
+		mongodb.MongodbReplayAudit()
+	case "verify-fixture":
+		mongodb.MongodbVerifyFixture()
+	case "export-permissions":
+		mongodb.MongodbExportPermissions()
+	case "worker":
+		infrastructure.UseBenchCredentials()
+		mongodb.MongodbWorker()
+	case "analyze":
+		return runAnalyze("mongodb", args[1:], mongodb.MongodbAnalyzeStats)
+	case "offboard":
+		mongodb.MongodbOffboard()
+	case "group-resolution":
+		infrastructure.UseBenchCredentials()
+		mongodb.MongodbGroupResolution()
 	default:
-		return fmt.Errorf("unknown action for scylla: %s", action)
+		return unknownAction("mongodb", action)
 	}
 
 	return nil
@@ -225,7 +632,7 @@ func runMongodb(args []string) error {
 
 func runScylladb(args []string) error {
 	if len(args) == 0 {
-		return errors.New(`missing action for scylladb (expected: "drop|create-schema|load-data|benchmark")`)
+		return missingAction("scylladb")
 	}
 
 	action := args[0]
@@ -237,10 +644,34 @@ func runScylladb(args []string) error {
 		scylladb.ScylladbCreateSchemas()
 	case "load-data":
 		scylladb.ScylladbCreateData()
+	case "plan-load":
+		utils.PlanLoad("scylladb")
 	case "benchmark":
+		infrastructure.UseBenchCredentials()
 		scylladb.ScylladbBenchmarkReads()
+	case "serve":
+		infrastructure.UseBenchCredentials()
+		scylladb.ScylladbServe()
+	case "replay-audit":
+		infrastructure.UseBenchCredentials()
+		scylladb.ScylladbReplayAudit()
+	case "verify-fixture":
+		scylladb.ScylladbVerifyFixture()
+	case "export-permissions":
+		scylladb.ScylladbExportPermissions()
+	case "worker":
+		infrastructure.UseBenchCredentials()
+		scylladb.ScylladbWorker()
+	case "analyze":
+		return runAnalyze("scylladb", args[1:], scylladb.ScylladbAnalyzeStats)
+	case "offboard":
+		scylladb.ScylladbOffboard()
+	case "index-compare":
+		scylladb.ScylladbIndexCompare()
+	case "group-churn":
+		scylladb.ScylladbGroupChurn()
 	default:
-		return fmt.Errorf("unknown action for scylla: %s", action)
+		return unknownAction("scylladb", action)
 	}
 
 	return nil
@@ -248,7 +679,7 @@ func runScylladb(args []string) error {
 
 func runElasticsearch(args []string) error {
 	if len(args) == 0 {
-		return errors.New(`missing action for elasticsearch (expected: "drop|create-schema|load-data|benchmark")`)
+		return missingAction("elasticsearch")
 	}
 
 	action := args[0]
@@ -260,65 +691,336 @@ func runElasticsearch(args []string) error {
 		elasticsearch.ElasticsearchCreateSchemas()
 	case "load-data":
 		elasticsearch.ElasticsearchCreateData()
+	case "plan-load":
+		utils.PlanLoad("elasticsearch")
 	case "benchmark":
+		infrastructure.UseBenchCredentials()
 		elasticsearch.ElasticsearchBenchmarkReads()
+	case "serve":
+		infrastructure.UseBenchCredentials()
+		elasticsearch.ElasticsearchServe()
+	case "replay-audit":
+		infrastructure.UseBenchCredentials()
+		elasticsearch.ElasticsearchReplayAudit()
+	case "verify-fixture":
+		elasticsearch.ElasticsearchVerifyFixture()
+	case "export-permissions":
+		elasticsearch.ElasticsearchExportPermissions()
+	case "worker":
+		infrastructure.UseBenchCredentials()
+		elasticsearch.ElasticsearchWorker()
+	case "analyze":
+		return runAnalyze("elasticsearch", args[1:], elasticsearch.ElasticsearchAnalyzeStats)
+	case "offboard":
+		elasticsearch.ElasticsearchOffboard()
 	default:
-		return fmt.Errorf("unknown action for elasticsearch: %s", action)
+		return unknownAction("elasticsearch", action)
 	}
 
 	return nil
 }
 
-func usage() {
-	prog := os.Args[0]
-	fmt.Println("usage:")
-	fmt.Printf("  %s csv generate\n", prog)
-	fmt.Printf("  %s authzed_crdb drop\n", prog)
-	fmt.Printf("  %s authzed_crdb create-schema\n", prog)
-	fmt.Printf("  %s authzed_crdb load-data\n", prog)
-	fmt.Printf("  %s authzed_crdb benchmark\n", prog)
-}
-
-// loadEnvFile reads a simple KEY=VALUE env file and sets variables.
-// Lines starting with '#' are treated as comments; blank lines are skipped.
-// Values can be quoted with single or double quotes; surrounding quotes are trimmed.
-func loadEnvFile(path string) error {
-	f, err := os.Open(path)
-	if err != nil {
-		// If the file doesn't exist, return a descriptive error so caller can warn.
-		if os.IsNotExist(err) {
-			return fmt.Errorf("env file not found: %s", path)
+// runCapabilities prints which scenarios, schema variants, consistency modes
+// and write benchmarks each backend supports; "capabilities <module>..."
+// limits it to those modules.
+func runCapabilities(args []string) error {
+	var order []string
+	caps := map[string]utils.Capabilities{}
+	for _, c := range capabilities {
+		if len(args) > 0 && !slices.Contains(args, c.module) {
+			continue
 		}
-		return err
+		order = append(order, c.module)
+		caps[c.module] = c.caps()
+	}
+	for _, a := range args {
+		if _, ok := caps[a]; !ok {
+			return fmt.Errorf("unknown backend for capabilities: %s", a)
+		}
+	}
+	utils.PrintCapabilities(order, caps)
+	return nil
+}
+
+// runDiffPermissions compares two export-permissions snapshots and exits with
+// status 2 when their grants differ, so scripts can assert convergence.
+func runDiffPermissions(args []string) error {
+	if len(args) != 2 {
+		return errors.New("diff-permissions: want two snapshot files (before and after)")
 	}
-	defer f.Close()
+	if !utils.DiffPermissions(args[0], args[1]) {
+		os.Exit(2)
+	}
+	return nil
+}
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+// runCoordinator shards REPLAY_AUDIT_FILE across the workers that register
+// and aggregates their results.
+func runCoordinator(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("coordinator takes no arguments, got %q", strings.Join(args, " "))
+	}
+	utils.RunCoordinator()
+	return nil
+}
+
+// runProbe sends the same checks to the serve endpoints of two backends and
+// reports how often and for how long they disagree.
+func runProbe(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("probe takes two serve URLs, got %d arguments", len(args))
+	}
+	utils.RunDualReadProbe(args[0], args[1])
+	return nil
+}
+
+// runData handles "data list" and "data clean" for the datasets csv generate
+// archived under utils.DatasetsDir.
+func runData(args []string) error {
+	if len(args) == 0 {
+		return errors.New(`missing action for data (expected: "list" or "clean")`)
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("data %s takes no arguments, got %q", args[0], strings.Join(args[1:], " "))
+	}
+	switch args[0] {
+	case "list":
+		return utils.PrintDatasets()
+	case "clean":
+		return utils.CleanDatasets(utils.GetEnvInt("RLP_DATA_KEEP_LATEST", 2))
+	default:
+		return fmt.Errorf("unknown action for data: %s", args[0])
+	}
+}
+
+// runConfig handles "config list [module]" and "config show [module]".
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return errors.New(`missing action for config (expected: "list" or "show")`)
+	}
+	if args[0] != "list" && args[0] != "show" {
+		return fmt.Errorf("unknown action for config: %s", args[0])
+	}
+	var module string
+	if len(args) > 1 {
+		module = args[1]
+		if _, ok := commands[module]; !ok {
+			return fmt.Errorf("unknown module for config %s: %s", args[0], module)
 		}
-		// Support export KEY=VALUE lines
-		if strings.HasPrefix(line, "export ") {
-			line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+	}
+	if args[0] == "show" {
+		utils.ShowConfig(module, envSources)
+		return nil
+	}
+	utils.PrintConfig(module)
+	return nil
+}
+
+// runAnalyze handles "<module> analyze <target>" for any backend module.
+func runAnalyze(module string, args []string, stats func()) error {
+	if len(args) == 0 {
+		return fmt.Errorf(`missing target for %s analyze (expected: "stats")`, module)
+	}
+
+	switch args[0] {
+	case "stats":
+		stats()
+		return nil
+	default:
+		return fmt.Errorf("unknown analyze target for %s: %s", module, args[0])
+	}
+}
+
+// runSchema handles "<authzed module> schema <write|read|diff>".
+func runSchema(module string, args []string, write, read, diff func()) error {
+	if len(args) == 0 {
+		return fmt.Errorf(`missing target for %s schema (expected: "write|read|diff")`, module)
+	}
+
+	switch args[0] {
+	case "write":
+		write()
+	case "read":
+		read()
+	case "diff":
+		diff()
+	default:
+		return fmt.Errorf("unknown schema target for %s: %s", module, args[0])
+	}
+	return nil
+}
+
+// command documents one "<module> <action>" for help output.
+type command struct {
+	action string
+	args   string // what follows the action, if anything
+	help   string
+}
+
+// backendCommands are the actions every backend module supports.
+var backendCommands = []command{
+	{"drop", "", "drop the schema and data (refuses above DROP_FORCE_THRESHOLD rows without --force)"},
+	{"create-schema", "", "create tables, indexes and views (authzed_*: write the SPICEDB_SCHEMA variant)"},
+	{"plan-load", "", "estimate disk, load time and memory for the CSVs in RLP_DATA_DIR; fails when the target looks undersized"},
+	{"load-data", "", "load the CSVs in RLP_DATA_DIR (default data)"},
+	{"benchmark", "", "run the read benchmark scenarios (BENCH_* env vars, --iters, --tui)"},
+	{"serve", "", "answer /v1/check and /v1/lookup over HTTP on SERVE_ADDR"},
+	{"replay-audit", "", "replay the calls in REPLAY_AUDIT_FILE against this backend"},
+	{"verify-fixture", "", "compare answers with FIXTURE_EXPECT after loading testdata/fixture"},
+	{"export-permissions", "", "write every user's manage and view resources to EXPORT_PERMISSIONS_OUT for diff-permissions"},
+	{"worker", "", "replay a shard of the coordinator at BENCH_COORDINATOR and stream the results back"},
+	{"analyze", "stats", "log relation statistics of the loaded data"},
+	{"offboard", "", "delete the users of BENCH_OFFBOARD_USERS completely, timing the delete and checking nothing is granted after"},
+}
+
+// authzedCommands adds schema management and the relationship export to
+// backendCommands.
+var authzedCommands = append(slices.Clone(backendCommands),
+	command{"schema", "write|read|diff", "write, show or diff the SPICEDB_SCHEMA variant against SpiceDB"},
+	command{"export", "", "stream every relationship back to dataset CSVs in SPICEDB_EXPORT_DIR"})
+
+// clickhouseCommands adds the sort key experiments and the closure
+// maintenance benchmark to backendCommands.
+var clickhouseCommands = append(slices.Clone(backendCommands),
+	command{"sort-keys", "", "benchmark the check and lookup queries on copies of the ACL tables with other sort keys"},
+	command{"group-churn", "", "benchmark incremental closure maintenance per burst of group hierarchy changes"},
+	command{"bitmaps", "", "benchmark check and lookup on per-user groupBitmap permission sets against user_resource_permissions"})
+
+// sqlCommands adds the nested group resolution comparison and the closure
+// maintenance benchmark to backendCommands.
+var sqlCommands = append(slices.Clone(backendCommands),
+	command{"group-closure", "", "benchmark the materialized view, a recursive CTE and group_closure for nested groups"},
+	command{"group-churn", "", "benchmark incremental closure maintenance per burst of group hierarchy changes"})
+
+// postgresCommands adds the bitmap encoding comparison and the incremental
+// permission refresh to sqlCommands.
+var postgresCommands = append(slices.Clone(sqlCommands),
+	command{"bitmaps", "", "benchmark check and lookup on per-user bytea and roaring permission bitmaps against the view"},
+	command{"permission-refresh", "", "benchmark LISTEN/NOTIFY incremental permission refresh lag under group churn against a full view refresh"})
+
+// mongodbCommands adds the nested group resolution comparison to backendCommands.
+var mongodbCommands = append(slices.Clone(backendCommands),
+	command{"group-resolution", "", "benchmark $graphLookup against group_members_expanded for nested group lookups"})
+
+// scylladbCommands adds the secondary index comparison and the closure
+// maintenance benchmark to backendCommands.
+var scylladbCommands = append(slices.Clone(backendCommands),
+	command{"index-compare", "", "benchmark the duplicated ACL tables against global and local secondary indexes"},
+	command{"group-churn", "", "benchmark incremental closure maintenance per burst of group hierarchy changes"})
+
+// commands lists the actions of each module, for help output and the
+// "expected" part of action errors.
+var commands = map[string][]command{
+	"csv": {
+		{"generate", "", "generate the dataset CSVs into data"},
+		{"targets", "", "write HTTP load-test targets for serve into TARGETS_OUT_DIR"},
+		{"import-production", "", "convert the anonymized ACL export of RLP_IMPORT_MAPPING into the dataset CSVs"},
+	},
+	"authzed_crdb":  authzedCommands,
+	"authzed_pgdb":  authzedCommands,
+	"clickhouse":    clickhouseCommands,
+	"cockroachdb":   sqlCommands,
+	"postgres":      postgresCommands,
+	"mongodb":       mongodbCommands,
+	"scylladb":      scylladbCommands,
+	"elasticsearch": backendCommands,
+}
+
+func actionNames(module string) string {
+	var names []string
+	for _, c := range commands[module] {
+		names = append(names, c.action)
+	}
+	return strings.Join(names, "|")
+}
+
+func missingAction(module string) error {
+	return fmt.Errorf("missing action for %s (expected: %q)", module, actionNames(module))
+}
+
+func unknownAction(module, action string) error {
+	return fmt.Errorf("unknown action for %s: %s (expected: %q)", module, action, actionNames(module))
+}
+
+// help prints usage scoped to args: every module, one module's actions, or a
+// single action.
+func help(args []string) {
+	prog := os.Args[0]
+	var module, action string
+	if len(args) > 0 {
+		module = args[0]
+	}
+	if len(args) > 1 {
+		action = args[1]
+	}
+
+	cmds, ok := commands[module]
+	switch {
+	case module == "capabilities":
+		fmt.Println("usage:")
+		fmt.Printf("  %s capabilities [module...]\n", prog)
+		fmt.Println("\nprint the scenarios, schema variants, consistency modes and write benchmarks")
+		fmt.Println("each backend supports, limited to the given modules")
+	case module == "config":
+		fmt.Println("usage:")
+		fmt.Printf("  %s config list [module]\n", prog)
+		fmt.Printf("  %s config show [module]\n", prog)
+		fmt.Println("\nlist: print every BENCH_*/RLP_* variable with its type, default and the modules")
+		fmt.Println("reading it, limited to one module; unknown ones in the environment fail every")
+		fmt.Println("other command (RLP_ALLOW_UNKNOWN_ENV=true to run anyway)")
+		fmt.Println("show: print the effective value of each and the env file, flag or environment")
+		fmt.Println("it came from, with the module's .env.<module> layer applied")
+	case module == "diff-permissions":
+		fmt.Println("usage:")
+		fmt.Printf("  %s diff-permissions <before.csv> <after.csv>\n", prog)
+		fmt.Println("\nreport the grants added and removed between two export-permissions snapshots,")
+		fmt.Println("of two backends or of one backend at two times; exits 2 when they differ")
+	case module == "coordinator":
+		fmt.Println("usage:")
+		fmt.Printf("  %s coordinator\n", prog)
+		fmt.Println("\nshard the calls of REPLAY_AUDIT_FILE across COORDINATOR_WORKERS \"<module> worker\"")
+		fmt.Println("processes, start them together and aggregate the results they stream back")
+	case module == "probe":
+		fmt.Println("usage:")
+		fmt.Printf("  %s probe <serve-url-a> <serve-url-b>\n", prog)
+		fmt.Println("\nsend the same view checks to two \"<module> serve\" endpoints at once while")
+		fmt.Println("changes are applied to both, and log the disagreement rate over time and how")
+		fmt.Println("long disagreements last (PROBE_DURATION_SEC, PROBE_INTERVAL_MS, PROBE_WINDOW_SEC)")
+	case module == "data":
+		fmt.Println("usage:")
+		fmt.Printf("  %s data list\n", prog)
+		fmt.Printf("  %s data clean [--keep-latest=N]\n", prog)
+		fmt.Printf("\nlist the current dataset and those csv generate moved to %s, or delete\n", utils.DatasetsDir())
+		fmt.Println("all archived ones but the N newest (default 2); the current one is kept")
+	case !ok:
+		fmt.Println("usage:")
+		fmt.Printf("  %s <module> <action> [flags]\n", prog)
+		fmt.Println("\nmodules:")
+		fmt.Printf("  %-16s %s\n", "csv", actionNames("csv"))
+		for _, c := range capabilities {
+			fmt.Printf("  %-16s %s\n", c.module, actionNames(c.module))
 		}
-		// Split on first '=' only
-		eq := strings.IndexRune(line, '=')
-		if eq <= 0 {
-			continue
+		fmt.Printf("  %-16s [module...]\n", "capabilities")
+		fmt.Printf("  %-16s <before.csv> <after.csv>\n", "diff-permissions")
+		fmt.Printf("  %-16s list, show [module]\n", "config")
+		fmt.Printf("  %-16s\n", "coordinator")
+		fmt.Printf("  %-16s <serve-url-a> <serve-url-b>\n", "probe")
+		fmt.Printf("  %-16s list, clean\n", "data")
+		fmt.Printf("\nrun \"%s <module> --help\" for the actions of a module\n", prog)
+	default:
+		i := slices.IndexFunc(cmds, func(c command) bool { return c.action == action })
+		if i >= 0 {
+			cmds = cmds[i : i+1]
 		}
-		key := strings.TrimSpace(line[:eq])
-		val := strings.TrimSpace(line[eq+1:])
-		// Trim surrounding quotes
-		if len(val) >= 2 {
-			if (val[0] == '\'' && val[len(val)-1] == '\'') || (val[0] == '"' && val[len(val)-1] == '"') {
-				val = val[1 : len(val)-1]
-			}
+		fmt.Println("usage:")
+		for _, c := range cmds {
+			fmt.Printf("  %s %s %s\n", prog, module, strings.TrimSpace(c.action+" "+c.args))
+			fmt.Printf("      %s\n", c.help)
 		}
-		// Expand existing env references like ${VAR}
-		val = os.ExpandEnv(val)
-		_ = os.Setenv(key, val)
 	}
-	return scanner.Err()
+
+	fmt.Println("\nflags:")
+	for _, f := range flagUsage {
+		fmt.Printf("  %-14s %s\n", f.name, f.help)
+	}
 }
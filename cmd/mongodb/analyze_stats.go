@@ -0,0 +1,129 @@
+package mongodb
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// MongodbAnalyzeStats reports document counts, per-field ACL cardinality and the
+// same relation fan-out summaries printed by `csv generate`, computed from the
+// denormalized collections so drift between the CSV dataset and MongoDB is visible.
+func MongodbAnalyzeStats() {
+	ctx := context.Background()
+	_, db, cleanup, err := infrastructure.NewMongoFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[mongodb] failed to create mongo client: %v", err)
+	}
+	defer cleanup()
+
+	start := time.Now()
+	stats := utils.NewRelationStats()
+
+	for _, name := range []string{"organizations", "groups", "resources"} {
+		n, err := db.Collection(name).CountDocuments(ctx, bson.D{})
+		if err != nil {
+			log.Fatalf("[mongodb] stats: count %s failed: %v", name, err)
+		}
+		log.Printf("[mongodb] collection %s: %d documents", name, n)
+	}
+
+	scanCollection(ctx, db, "organizations", func(m bson.M) {
+		orgID := atoiField(m, "org_id")
+		for _, field := range []string{"admin_user_ids", "member_user_ids"} {
+			for _, userID := range idsOf(m, field) {
+				stats.OrgToUsers[orgID]++
+				stats.UserToOrgs[userID]++
+			}
+		}
+	})
+
+	scanCollection(ctx, db, "groups", func(m bson.M) {
+		groupID := atoiField(m, "group_id")
+		for _, userID := range idsOf(m, "direct_member_user_ids") {
+			stats.GroupToMembers[groupID]++
+			stats.UserToGroups[userID]++
+		}
+		for _, userID := range idsOf(m, "direct_manager_user_ids") {
+			stats.GroupToManagers[groupID]++
+			stats.UserToGroups[userID]++
+		}
+		stats.GroupToChildGroups[groupID] += len(idsOf(m, "member_group_ids")) + len(idsOf(m, "manager_group_ids"))
+		if stats.GroupToChildGroups[groupID] == 0 {
+			delete(stats.GroupToChildGroups, groupID)
+		}
+	})
+
+	// Per-field cardinality mirrors the resource_acl relations of the SQL backends.
+	aclFields := []string{"manager_user_ids", "viewer_user_ids", "manager_group_ids", "viewer_group_ids"}
+	edges := make(map[string]int, len(aclFields))
+	subjects := make(map[string]map[int]struct{}, len(aclFields))
+	resources := make(map[string]int, len(aclFields))
+	for _, f := range aclFields {
+		subjects[f] = make(map[int]struct{})
+	}
+	scanCollection(ctx, db, "resources", func(m bson.M) {
+		resID := atoiField(m, "resource_id")
+		for _, f := range aclFields {
+			ids := idsOf(m, f)
+			if len(ids) > 0 {
+				resources[f]++
+			}
+			for _, id := range ids {
+				edges[f]++
+				subjects[f][id] = struct{}{}
+				if f == "manager_user_ids" || f == "viewer_user_ids" {
+					stats.UserToResources[id]++
+					stats.ResourceToUsers[resID]++
+				}
+			}
+		}
+	})
+	for _, f := range aclFields {
+		log.Printf("[mongodb] acl resources.%s: %d edges, %d distinct subjects, %d distinct resources",
+			f, edges[f], len(subjects[f]), resources[f])
+	}
+
+	stats.Summarize("mongodb")
+
+	log.Printf("[mongodb] analyze stats DONE elapsed=%s", time.Since(start).Truncate(time.Millisecond))
+}
+
+// scanCollection streams every document of a collection into handle.
+func scanCollection(ctx context.Context, db *mongo.Database, name string, handle func(bson.M)) {
+	cur, err := db.Collection(name).Find(ctx, bson.D{})
+	if err != nil {
+		log.Fatalf("[mongodb] stats: scan %s failed: %v", name, err)
+	}
+	defer cur.Close(ctx)
+
+	if err := streamCursor(ctx, cur, handle); err != nil {
+		log.Fatalf("[mongodb] stats: scan %s failed: %v", name, err)
+	}
+}
+
+// idsOf returns the numeric ids stored as strings in an array field.
+func idsOf(m bson.M, field string) []int {
+	arr, _ := m[field].(bson.A)
+	ids := make([]int, 0, len(arr))
+	for _, v := range arr {
+		s, _ := v.(string)
+		if id, err := strconv.Atoi(s); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func atoiField(m bson.M, field string) int {
+	s, _ := m[field].(string)
+	id, _ := strconv.Atoi(s)
+	return id
+}
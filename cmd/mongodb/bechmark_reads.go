@@ -2,42 +2,84 @@ package mongodb
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"test-tls/ids"
 	"test-tls/infrastructure"
 	"test-tls/utils"
 )
 
 // Streaming-only benchmarks for MongoDB using denormalized collections defined
-// in create_schemas.go. No in-memory accumulation; all checks use cursors.
+// in create_schemas.go. No in-memory accumulation: check pairs are drawn with
+// $sample aggregations and streamed through cursors.
+// Read preference, read concern and maxStaleness come from readConsistencyFromEnv.
 func MongodbBenchmarkReads() {
 	client, db, cleanup, err := infrastructure.NewMongoFromEnv(context.Background())
 	if err != nil {
 		log.Fatalf("[mongodb] failed to create mongo client: %v", err)
 	}
 	defer cleanup()
+	utils.GuardDataset("mongodb", datasetStore{db})
+	stopAudit := utils.StartAudit("mongodb")
+	defer stopAudit()
+
+	consistency := readConsistencyFromEnv()
+	db = consistency.database(client, db.Name())
+	utils.LogConsistency("mongodb", consistency.String())
+	log.Printf("[mongodb] SCHEMA: groups=%s lookup=%s", groupModeFromEnv(), utils.GetEnvWithDefault("MONGO_LOOKUP_MODE", "stream"))
 
 	start := time.Now()
 	heavyManageUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
 	regularViewUser := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
 	elapsed := time.Since(start).Truncate(time.Millisecond)
-	log.Printf("[mongodb] Running in streaming-only mode (no precollection). elapsed=%s heavyManageUser=%q regularViewUser=%q",
+	log.Printf("[mongodb] Running in streaming-only mode (pairs sampled server-side). elapsed=%s heavyManageUser=%q regularViewUser=%q",
 		elapsed, heavyManageUser, regularViewUser)
 
-	runCheckManageDirectUser(db)
-	runCheckManageOrgAdmin(db)
-	runCheckViewViaGroupMember(db)
-	runLookupResourcesManageHeavyUser(db)
-	runLookupResourcesViewRegularUser(db)
+	stopCost := utils.StartCost("mongodb", costMeter(db))
+	utils.RunCachePhases("mongodb", flushCaches(db), func() {
+		runCheckManageDirectUser(db)
+		runCheckManageOrgAdmin(db)
+		runCheckViewViaGroupMember(db)
+		runCheckTimeBoundedDirectUser(db)
+		runLookupResourcesManageHeavyUser(db)
+		runLookupResourcesViewRegularUser(db)
+		runLookupResourcesMix(db)
+		runLookupResourcesPercentiles(db)
+		runViralFanIn(db)
+		runWorstCaseChecks(db)
+		runWorkload(db)
+		runMyGroups(db)
+		runAccessSummary(db)
+		runMiddlewareOverhead(db)
+	})
+	runFullExport(db)
+	stopCost()
 
 	log.Println("[mongodb] == Mongo read benchmarks DONE ==")
-	_ = client
+}
+
+// flushCaches clears the query plan cache of the collections the benchmark
+// reads, for the cold pass of BENCH_CACHE_COMPARE. The WiredTiger cache can
+// only be emptied by restarting mongod.
+func flushCaches(db *mongo.Database) utils.CacheFlush {
+	return func(ctx context.Context) error {
+		for _, coll := range []string{"resources", "groups", "organizations"} {
+			if err := db.RunCommand(ctx, bson.D{{Key: "planCacheClear", Value: coll}}).Err(); err != nil {
+				return fmt.Errorf("planCacheClear %s: %w", coll, err)
+			}
+		}
+		return nil
+	}
 }
 
 func streamCursor(ctx context.Context, cur *mongo.Cursor, handle func(bson.M)) error {
@@ -51,170 +93,174 @@ func streamCursor(ctx context.Context, cur *mongo.Cursor, handle func(bson.M)) e
 	return cur.Err()
 }
 
+// === Sampling ===
+
+// samplePairs runs pipeline on coll and streams the sampled documents to
+// handle. The pipelines pick their pairs server-side with $sample, so the
+// client holds one document at a time however large the dataset is.
+func samplePairs(ctx context.Context, coll *mongo.Collection, pipeline mongo.Pipeline, handle func(bson.M)) error {
+	cur, err := coll.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	return streamCursor(ctx, cur, handle)
+}
+
+// scopeMatch returns the $match filter for documents having field, restricted
+// to the org scope (RLP_ORGS) through org_id when one is set.
+func scopeMatch(field string) bson.D {
+	match := bson.D{{Key: field, Value: bson.D{{Key: "$exists", Value: true}}}}
+	if scope := utils.CurrentOrgScope(); scope != nil {
+		orgs := bson.A{}
+		for _, n := range scope.Orgs() {
+			orgs = append(orgs, ids.Format(ids.Org, n))
+		}
+		match = append(match, bson.E{Key: "org_id", Value: bson.D{{Key: "$in", Value: orgs}}})
+	}
+	return match
+}
+
+// randomElem is the aggregation expression for a random element of the array
+// at path.
+func randomElem(path string) bson.D {
+	return bson.D{{Key: "$arrayElemAt", Value: bson.A{path, bson.D{{Key: "$floor", Value: bson.D{{Key: "$multiply", Value: bson.A{
+		bson.D{{Key: "$rand", Value: bson.D{}}},
+		bson.D{{Key: "$size", Value: path}},
+	}}}}}}}}
+}
+
 // === Scenarios ===
 
-// Direct manager_user relationship checks: stream resources with manager_user_ids entries
+// Direct manager_user relationship checks: sample resources with manager_user_ids entries
 func runCheckManageDirectUser(db *mongo.Database) {
 	iters := utils.GetEnvInt("BENCH_CHECK_DIRECT_SUPER_ITER", 1000)
 	log.Printf("[mongodb] [check_manage_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("mongodb", "check_manage_direct_user", iters, 2*time.Second)
 
 	coll := db.Collection("resources")
 	ctx := context.Background()
 	done := 0
+	errs := utils.NewErrorTally()
 
-	// Stream resources having at least one manager_user_ids element
-	cur, err := coll.Find(ctx, bson.D{{Key: "manager_user_ids", Value: bson.D{{Key: "$exists", Value: true}}}}, options.Find().SetProjection(bson.D{{Key: "resource_id", Value: 1}, {Key: "manager_user_ids", Value: 1}}))
-	if err != nil {
-		log.Fatalf("[mongodb] [check_manage_direct_user] query failed: %v", err)
+	// Sample resources having at least one manager_user_ids element, with one of them
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: scopeMatch("manager_user_ids.0")}},
+		{{Key: "$sample", Value: bson.D{{Key: "size", Value: iters}}}},
+		{{Key: "$project", Value: bson.D{{Key: "resource_id", Value: 1}, {Key: "user_id", Value: randomElem("$manager_user_ids")}}}},
 	}
-	defer cur.Close(ctx)
-
-	_ = streamCursor(ctx, cur, func(m bson.M) {
-		if done >= iters {
-			return
-		}
+	err := samplePairs(ctx, coll, pipeline, func(m bson.M) {
 		resID, _ := m["resource_id"].(string)
-		users, _ := m["manager_user_ids"].(bson.A)
-		if len(users) == 0 {
-			return
-		}
-		userID, _ := users[0].(string)
+		userID, _ := m["user_id"].(string)
 
 		// Simulate CheckPermission: existence check for (resID, userID)
 		cctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		start := time.Now()
-		findErr := db.Collection("resources").FindOne(cctx, bson.D{{Key: "resource_id", Value: resID}, {Key: "manager_user_ids", Value: userID}}).Err()
+		findErr := coll.FindOne(cctx, bson.D{{Key: "resource_id", Value: resID}, {Key: "manager_user_ids", Value: userID}}).Err()
 		cancel()
+		utils.AuditCheck("check_manage_direct_user", resID, userID, "manage", findErr == nil, time.Since(start), findErr)
 		if findErr != nil {
-			// Not found implies permission false; keep streaming
+			// Not found implies permission false; count it and keep streaming
+			class := errs.Record(findErr)
+			log.Printf("[mongodb] [check_manage_direct_user] iter=%d check failed class=%s: %v", done, class, findErr)
+			done++
 			return
 		}
 		dur := time.Since(start)
-		if done%100 == 0 {
+		if utils.LogIteration(done, 100) {
 			log.Printf("[mongodb] [check_manage_direct_user] iter=%d resource=%s user=%s dur=%s", done, resID, userID, dur)
 		}
 		done++
 	})
+	if err != nil {
+		errs.Survive(err, iters, "[mongodb] [check_manage_direct_user] sample query failed: %v", err)
+	}
 
 	log.Printf("[mongodb] [check_manage_direct_user] DONE: iters=%d", iters)
+	log.Printf("[mongodb] [check_manage_direct_user] ERRORS: %s", errs.Summary(done))
 }
 
-// Manage via org admin: stream resources' org_id and pick an admin
+// Manage via org admin: sample resources and join an admin of their org
 func runCheckManageOrgAdmin(db *mongo.Database) {
 	iters := utils.GetEnvInt("BENCH_CHECK_ORGADMIN_ITER", 1000)
 	log.Printf("[mongodb] [check_manage_org_admin] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("mongodb", "check_manage_org_admin", iters, 2*time.Second)
 
 	rcoll := db.Collection("resources")
-	ocoll := db.Collection("organizations")
 	ctx := context.Background()
 	done := 0
-
-	cur, err := rcoll.Find(ctx, bson.D{}, options.Find().SetProjection(bson.D{{Key: "resource_id", Value: 1}, {Key: "org_id", Value: 1}}))
-	if err != nil {
-		log.Fatalf("[mongodb] [check_manage_org_admin] query failed: %v", err)
+	errs := utils.NewErrorTally()
+
+	// The $lookup runs per sampled resource only, never over all of them
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: scopeMatch("org_id")}},
+		{{Key: "$sample", Value: bson.D{{Key: "size", Value: iters}}}},
+		{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "organizations"}, {Key: "localField", Value: "org_id"}, {Key: "foreignField", Value: "org_id"}, {Key: "as", Value: "org"}}}},
+		{{Key: "$unwind", Value: "$org"}},
+		{{Key: "$project", Value: bson.D{{Key: "resource_id", Value: 1}, {Key: "org_id", Value: 1}, {Key: "admin_user_id", Value: bson.D{{Key: "$arrayElemAt", Value: bson.A{"$org.admin_user_ids", 0}}}}}}},
+		{{Key: "$match", Value: bson.D{{Key: "admin_user_id", Value: bson.D{{Key: "$exists", Value: true}}}}}},
 	}
-	defer cur.Close(ctx)
-
-	_ = streamCursor(ctx, cur, func(m bson.M) {
-		if done >= iters {
-			return
-		}
+	err := samplePairs(ctx, rcoll, pipeline, func(m bson.M) {
 		resID, _ := m["resource_id"].(string)
 		orgID, _ := m["org_id"].(string)
-
-		// Find an admin user for the org without caching
-		aCur, err := ocoll.Find(ctx, bson.D{{Key: "org_id", Value: orgID}, {Key: "admin_user_ids", Value: bson.D{{Key: "$exists", Value: true}}}}, options.Find().SetProjection(bson.D{{Key: "admin_user_ids", Value: 1}}))
-		if err != nil {
-			return
-		}
-		defer aCur.Close(ctx)
-		var adminUser string
-		for aCur.Next(ctx) {
-			var om bson.M
-			if err := aCur.Decode(&om); err != nil {
-				break
-			}
-			arr, _ := om["admin_user_ids"].(bson.A)
-			if len(arr) > 0 {
-				adminUser, _ = arr[0].(string)
-				break
-			}
-		}
-		if adminUser == "" {
-			return
-		}
+		adminUser, _ := m["admin_user_id"].(string)
 
 		// Simulate CheckPermission via org admin path: resource.org matches org where user is admin
 		cctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		start := time.Now()
-		err = rcoll.FindOne(cctx, bson.D{{Key: "resource_id", Value: resID}, {Key: "org_id", Value: orgID}}).Err()
+		err := rcoll.FindOne(cctx, bson.D{{Key: "resource_id", Value: resID}, {Key: "org_id", Value: orgID}}).Err()
 		cancel()
+		utils.AuditCheck("check_manage_org_admin", resID, adminUser, "manage", err == nil, time.Since(start), err)
 		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[mongodb] [check_manage_org_admin] iter=%d check failed class=%s: %v", done, class, err)
+			done++
 			return
 		}
 		dur := time.Since(start)
-		if done%100 == 0 {
+		if utils.LogIteration(done, 100) {
 			log.Printf("[mongodb] [check_manage_org_admin] iter=%d resource=%s org=%s admin=%s dur=%s", done, resID, orgID, adminUser, dur)
 		}
 		done++
 	})
+	if err != nil {
+		errs.Survive(err, iters, "[mongodb] [check_manage_org_admin] sample query failed: %v", err)
+	}
 
 	log.Printf("[mongodb] [check_manage_org_admin] DONE: iters=%d", iters)
+	log.Printf("[mongodb] [check_manage_org_admin] ERRORS: %s", errs.Summary(done))
 }
 
 // View via viewer_group and group membership
 func runCheckViewViaGroupMember(db *mongo.Database) {
 	iters := utils.GetEnvInt("BENCH_CHECK_VIEW_GROUP_ITER", 1000)
 	log.Printf("[mongodb] [check_view_via_group_member] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("mongodb", "check_view_via_group_member", iters, 2*time.Second)
 
 	rcoll := db.Collection("resources")
 	gcoll := db.Collection("groups")
 	ctx := context.Background()
 	done := 0
-
-	// Stream resources that reference some viewer_group_ids
-	cur, err := rcoll.Find(ctx, bson.D{{Key: "viewer_group_ids", Value: bson.D{{Key: "$exists", Value: true}}}}, options.Find().SetProjection(bson.D{{Key: "resource_id", Value: 1}, {Key: "viewer_group_ids", Value: 1}}))
-	if err != nil {
-		log.Fatalf("[mongodb] [check_view_via_group_member] query failed: %v", err)
+	errs := utils.NewErrorTally()
+
+	// Sample resources that reference some viewer_group_ids, pick one group
+	// and a direct member of it (falling back to a manager)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: scopeMatch("viewer_group_ids.0")}},
+		{{Key: "$sample", Value: bson.D{{Key: "size", Value: iters}}}},
+		{{Key: "$project", Value: bson.D{{Key: "resource_id", Value: 1}, {Key: "group_id", Value: randomElem("$viewer_group_ids")}}}},
+		{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "groups"}, {Key: "localField", Value: "group_id"}, {Key: "foreignField", Value: "group_id"}, {Key: "as", Value: "group"}}}},
+		{{Key: "$unwind", Value: "$group"}},
+		{{Key: "$project", Value: bson.D{{Key: "resource_id", Value: 1}, {Key: "group_id", Value: 1}, {Key: "user_id", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+			bson.D{{Key: "$arrayElemAt", Value: bson.A{"$group.direct_member_user_ids", 0}}},
+			bson.D{{Key: "$arrayElemAt", Value: bson.A{"$group.direct_manager_user_ids", 0}}},
+		}}}}}}},
+		{{Key: "$match", Value: bson.D{{Key: "user_id", Value: bson.D{{Key: "$ne", Value: nil}}}}}},
 	}
-	defer cur.Close(ctx)
-
-	_ = streamCursor(ctx, cur, func(m bson.M) {
-		if done >= iters {
-			return
-		}
+	err := samplePairs(ctx, rcoll, pipeline, func(m bson.M) {
 		resID, _ := m["resource_id"].(string)
-		groups, _ := m["viewer_group_ids"].(bson.A)
-		if len(groups) == 0 {
-			return
-		}
-		groupID, _ := groups[0].(string)
-
-		// Pick a direct member; fallback to manager
-		var pickedUser string
-		gCur, err := gcoll.Find(ctx, bson.D{{Key: "group_id", Value: groupID}}, options.Find().SetProjection(bson.D{{Key: "direct_member_user_ids", Value: 1}, {Key: "direct_manager_user_ids", Value: 1}}))
-		if err != nil {
-			return
-		}
-		defer gCur.Close(ctx)
-		for gCur.Next(ctx) {
-			var gm bson.M
-			if err := gCur.Decode(&gm); err != nil {
-				break
-			}
-			arr, _ := gm["direct_member_user_ids"].(bson.A)
-			if len(arr) > 0 {
-				pickedUser, _ = arr[0].(string)
-				break
-			}
-			arr2, _ := gm["direct_manager_user_ids"].(bson.A)
-			if pickedUser == "" && len(arr2) > 0 {
-				pickedUser, _ = arr2[0].(string)
-			}
-		}
-		if pickedUser == "" {
-			return
-		}
+		groupID, _ := m["group_id"].(string)
+		pickedUser, _ := m["user_id"].(string)
 
 		// Simulate CheckPermission: ensure resource has group and group contains user
 		cctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
@@ -222,6 +268,10 @@ func runCheckViewViaGroupMember(db *mongo.Database) {
 		// Check resource references the group
 		if err := rcoll.FindOne(cctx, bson.D{{Key: "resource_id", Value: resID}, {Key: "viewer_group_ids", Value: groupID}}).Err(); err != nil {
 			cancel()
+			utils.AuditCheck("check_view_via_group_member", resID, pickedUser, "view", false, time.Since(start), err)
+			class := errs.Record(err)
+			log.Printf("[mongodb] [check_view_via_group_member] iter=%d check failed class=%s: %v", done, class, err)
+			done++
 			return
 		}
 		// Check group membership
@@ -230,23 +280,123 @@ func runCheckViewViaGroupMember(db *mongo.Database) {
 			bson.D{{Key: "direct_manager_user_ids", Value: pickedUser}},
 		}}}).Err(); err != nil {
 			cancel()
+			utils.AuditCheck("check_view_via_group_member", resID, pickedUser, "view", false, time.Since(start), err)
+			class := errs.Record(err)
+			log.Printf("[mongodb] [check_view_via_group_member] iter=%d check failed class=%s: %v", done, class, err)
+			done++
 			return
 		}
 		cancel()
 		dur := time.Since(start)
-		if done%100 == 0 {
+		utils.AuditCheck("check_view_via_group_member", resID, pickedUser, "view", true, dur, nil)
+		if utils.LogIteration(done, 100) {
 			log.Printf("[mongodb] [check_view_via_group_member] iter=%d resource=%s group=%s user=%s dur=%s", done, resID, groupID, pickedUser, dur)
 		}
 		done++
 	})
+	if err != nil {
+		errs.Survive(err, iters, "[mongodb] [check_view_via_group_member] sample query failed: %v", err)
+	}
 
 	log.Printf("[mongodb] [check_view_via_group_member] DONE: iters=%d", iters)
+	log.Printf("[mongodb] [check_view_via_group_member] ERRORS: %s", errs.Summary(done))
+}
+
+// Time-bounded direct grants: sample grant windows and check each against the
+// current time with $elemMatch, so the expired (soft deleted) share of the
+// sample is denied.
+func runCheckTimeBoundedDirectUser(db *mongo.Database) {
+	iters := utils.GetEnvInt("BENCH_CHECK_TIME_BOUNDED_ITER", 1000)
+	log.Printf("[mongodb] [check_time_bounded_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("mongodb", "check_time_bounded_direct_user", iters, 2*time.Second)
+
+	coll := db.Collection("resources")
+	ctx := context.Background()
+	done, allowed := 0, 0
+	errs := utils.NewErrorTally()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: scopeMatch("user_grant_windows.0")}},
+		{{Key: "$project", Value: bson.D{{Key: "resource_id", Value: 1}, {Key: "grant", Value: "$user_grant_windows"}}}},
+		{{Key: "$unwind", Value: "$grant"}},
+		{{Key: "$sample", Value: bson.D{{Key: "size", Value: iters}}}},
+	}
+	err := samplePairs(ctx, coll, pipeline, func(m bson.M) {
+		resID, _ := m["resource_id"].(string)
+		grant, _ := m["grant"].(bson.M)
+		userID, _ := grant["user_id"].(string)
+		relation, _ := grant["relation"].(string)
+
+		cctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		start := time.Now()
+		ok, checkErr := checkTimeBounded(cctx, coll, resID, userID, relation, time.Now())
+		cancel()
+		utils.AuditCheck("check_time_bounded_direct_user", resID, userID, relation, ok, time.Since(start), checkErr)
+		if checkErr != nil {
+			class := errs.Record(checkErr)
+			log.Printf("[mongodb] [check_time_bounded_direct_user] iter=%d check failed class=%s: %v", done, class, checkErr)
+			done++
+			return
+		}
+		dur := time.Since(start)
+		if ok {
+			allowed++
+		}
+		if utils.LogIteration(done, 100) {
+			log.Printf("[mongodb] [check_time_bounded_direct_user] iter=%d resource=%s user=%s allowed=%t dur=%s", done, resID, userID, ok, dur)
+		}
+		done++
+	})
+	if err != nil {
+		errs.Survive(err, iters, "[mongodb] [check_time_bounded_direct_user] sample query failed: %v", err)
+	}
+
+	if done == 0 {
+		log.Printf("[mongodb] [check_time_bounded_direct_user] skipped: no time-bounded grants (generate with RLP_EXPIRING_GRANT_FRACTION)")
+	}
+	log.Printf("[mongodb] [check_time_bounded_direct_user] DONE: iters=%d allowed=%d denied=%d", done, allowed, done-allowed-errs.Total())
+	log.Printf("[mongodb] [check_time_bounded_direct_user] ERRORS: %s", errs.Summary(done))
+}
+
+// checkTimeBounded looks for a grant window of (userID, relation) on the
+// resource that covers now. A missing document means the grant is not held.
+func checkTimeBounded(ctx context.Context, coll *mongo.Collection, resID, userID, relation string, now time.Time) (bool, error) {
+	err := coll.FindOne(ctx, timeBoundedFilter(resID, userID, relation, now), options.FindOne().SetProjection(bson.D{{Key: "_id", Value: 1}})).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
 }
 
-// Lookup resources for manage for a heavy user
+// timeBoundedFilter is the resources filter of checkTimeBounded.
+func timeBoundedFilter(resID, userID, relation string, now time.Time) bson.D {
+	return bson.D{
+		{Key: "resource_id", Value: resID},
+		{Key: "user_grant_windows", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "relation", Value: relation},
+			{Key: "$and", Value: bson.A{
+				bson.D{{Key: "$or", Value: bson.A{
+					bson.D{{Key: "valid_from", Value: nil}},
+					bson.D{{Key: "valid_from", Value: bson.D{{Key: "$lte", Value: now}}}},
+				}}},
+				bson.D{{Key: "$or", Value: bson.A{
+					bson.D{{Key: "valid_until", Value: nil}},
+					bson.D{{Key: "valid_until", Value: bson.D{{Key: "$gt", Value: now}}}},
+				}}},
+			}},
+		}}}},
+	}
+}
+
+// Lookup resources for manage for a heavy user: BENCH_LOOKUPRES_MANAGE_USER,
+// else the user with the most direct manager_user grants in scope.
 func runLookupResourcesManageHeavyUser(db *mongo.Database) {
 	iters := utils.GetEnvInt("BENCH_LOOKUPRES_MANAGE_ITER", 10)
 	userID := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	if userID == "" {
+		userID = heaviestManager(db)
+	}
 	runLookupBench(db, "lookup_resources_manage_super", "manage", userID, iters, 60*time.Second)
 }
 
@@ -257,120 +407,176 @@ func runLookupResourcesViewRegularUser(db *mongo.Database) {
 	runLookupBench(db, "lookup_resources_view_regular", "view", userID, iters, 60*time.Second)
 }
 
-// runLookupBench streams matching resources for a user and counts them.
+// heaviestManager picks the user holding the most direct manager_user grants,
+// counted server-side with $group and reduced to the top one with $topN
+// (MongoDB 5.2+), so only a single document reaches the client.
+func heaviestManager(db *mongo.Database) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: scopeMatch("manager_user_ids.0")}},
+		{{Key: "$unwind", Value: "$manager_user_ids"}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$manager_user_ids"}, {Key: "grants", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: nil}, {Key: "top", Value: bson.D{{Key: "$topN", Value: bson.D{
+			{Key: "n", Value: 1},
+			{Key: "sortBy", Value: bson.D{{Key: "grants", Value: -1}, {Key: "_id", Value: 1}}},
+			{Key: "output", Value: bson.D{{Key: "user_id", Value: "$_id"}, {Key: "grants", Value: "$grants"}}},
+		}}}}}}},
+		{{Key: "$unwind", Value: "$top"}},
+	}
+	var userID string
+	err := samplePairs(ctx, db.Collection("resources"), pipeline, func(m bson.M) {
+		top, _ := m["top"].(bson.M)
+		userID, _ = top["user_id"].(string)
+		log.Printf("[mongodb] heavy manage user picked server-side: user=%s grants=%v", userID, top["grants"])
+	})
+	if err != nil {
+		log.Printf("[mongodb] heavy manage user selection failed: %v", err)
+	}
+	return userID
+}
+
+// LookupModes are the MONGO_LOOKUP_MODE values: what a lookup scenario times.
+//
+//	stream: every matching resource_id streamed to the client (default)
+//	count:  the matches counted server-side with $count, the work of a SQL COUNT
+var LookupModes = []string{"stream", "count"}
+
+// runLookupBench times one lookup per iteration for a user, in the
+// MONGO_LOOKUP_MODE of LookupModes. MONGO_GROUP_MODE picks how nested groups
+// are resolved (see mongoauthz.GroupModes).
 func runLookupBench(db *mongo.Database, name, permission, userID string, iters int, timeout time.Duration) {
 	if userID == "" {
 		log.Printf("[mongodb] [%s] skipped: no user specified", name)
 		return
 	}
-	log.Printf("[mongodb] [%s] iterations=%d user=%s", name, iters, userID)
-
-	rcoll := db.Collection("resources")
-	gcoll := db.Collection("groups")
-	ocoll := db.Collection("organizations")
+	mode := utils.GetEnvWithDefault("MONGO_LOOKUP_MODE", "stream")
+	if !slices.Contains(LookupModes, mode) {
+		log.Fatalf("[mongodb] MONGO_LOOKUP_MODE=%q: want one of %s", mode, strings.Join(LookupModes, ", "))
+	}
+	log.Printf("[mongodb] [%s] iterations=%d user=%s mode=%s groups=%s", name, iters, userID, mode, groupModeFromEnv())
+	utils.LogScenarioConfig("mongodb", name, iters, timeout, userID)
 
 	var total time.Duration
-	var lastCount int
+	var lastCount, ok int
+	errs := utils.NewErrorTally()
 
 	for i := range iters {
 		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		start := time.Now()
 
-		// Stream resources by combining direct and derived paths without precollecting:
-		// manage: direct user, org admin, manager group
-		// view: direct user, viewer group (member/manager), or manage
-		var filter bson.D
-		if permission == "manage" {
-			filter = bson.D{{Key: "$or", Value: bson.A{
-				bson.D{{Key: "manager_user_ids", Value: userID}},
-				// org admin path handled by streaming: match later inside loop
-				// manager group: resources referencing any group where user is manager
-			}}}
+		count := 0
+		var err error
+		if mode == "count" {
+			count, err = countResources(ctx, db, userID, permission)
 		} else {
-			filter = bson.D{{Key: "$or", Value: bson.A{
-				bson.D{{Key: "viewer_user_ids", Value: userID}},
-				// viewer_group handled by group membership streaming
-			}}}
+			err = lookupResources(ctx, db, userID, permission, func(string) { count++ })
 		}
-
-		cur, err := rcoll.Find(ctx, filter, options.Find().SetProjection(bson.D{{Key: "resource_id", Value: 1}, {Key: "org_id", Value: 1}, {Key: "manager_group_ids", Value: 1}, {Key: "viewer_group_ids", Value: 1}}))
+		cancel()
+		utils.AuditLookup(name, userID, permission, count, time.Since(start), err)
 		if err != nil {
-			cancel()
-			log.Fatalf("[mongodb] [%s] query failed: %v", name, err)
-		}
-
-		count := 0
-		for cur.Next(ctx) {
-			var m bson.M
-			if err := cur.Decode(&m); err != nil {
-				break
-			}
-			resID, _ := m["resource_id"].(string)
-			orgID, _ := m["org_id"].(string)
-
-			// Derived paths
-			match := false
-			if permission == "manage" {
-				// org admin path
-				if err := ocoll.FindOne(ctx, bson.D{{Key: "org_id", Value: orgID}, {Key: "admin_user_ids", Value: userID}}).Err(); err == nil {
-					match = true
-				}
-				// manager group path
-				if !match {
-					groups, _ := m["manager_group_ids"].(bson.A)
-					for _, g := range groups {
-						gid, _ := g.(string)
-						if gid == "" {
-							continue
-						}
-						if err := gcoll.FindOne(ctx, bson.D{{Key: "group_id", Value: gid}, {Key: "direct_manager_user_ids", Value: userID}}).Err(); err == nil {
-							match = true
-							break
-						}
-					}
-				}
-			} else { // view
-				// viewer_group path via member or manager
-				groups, _ := m["viewer_group_ids"].(bson.A)
-				for _, g := range groups {
-					gid, _ := g.(string)
-					if gid == "" {
-						continue
-					}
-					if err := gcoll.FindOne(ctx, bson.D{{Key: "group_id", Value: gid}, {Key: "$or", Value: bson.A{
-						bson.D{{Key: "direct_member_user_ids", Value: userID}},
-						bson.D{{Key: "direct_manager_user_ids", Value: userID}},
-					}}}).Err(); err == nil {
-						match = true
-						break
-					}
-				}
-				// manage implies view
-				if !match {
-					if err := rcoll.FindOne(ctx, bson.D{{Key: "resource_id", Value: resID}, {Key: "manager_user_ids", Value: userID}}).Err(); err == nil {
-						match = true
-					}
-				}
-				if !match {
-					if err := ocoll.FindOne(ctx, bson.D{{Key: "org_id", Value: orgID}, {Key: "admin_user_ids", Value: userID}}).Err(); err == nil {
-						match = true
-					}
-				}
-			}
-
-			if match {
-				count++
-			}
+			class := errs.Record(err)
+			log.Printf("[mongodb] [%s] iter=%d LookupResources failed class=%s: %v", name, i, class, err)
+			continue
 		}
-		cur.Close(ctx)
-		cancel()
 
 		dur := time.Since(start)
 		total += dur
 		lastCount = count
+		ok++
 		log.Printf("[mongodb] [%s] iter=%d resources=%d duration=%s", name, i, count, dur.Truncate(time.Millisecond))
 	}
 
-	avg := time.Duration(int64(total) / int64(iters))
+	avg := time.Duration(0)
+	if ok > 0 {
+		avg = time.Duration(int64(total) / int64(ok))
+	}
 	log.Printf("[mongodb] [%s] DONE: iters=%d lastCount=%d avg=%s total=%s", name, iters, lastCount, avg, total)
+	log.Printf("[mongodb] [%s] ERRORS: %s", name, errs.Summary(iters))
+}
+
+// runLookupResourcesMix benchmarks lookups for a mix of users stratified by
+// grant count, reporting latency against result-set size. The number of users
+// is set by BENCH_LOOKUPRES_MIX_USERS (default 0 = skip); see utils.RunLookupMix.
+func runLookupResourcesMix(db *mongo.Database) {
+	utils.RunLookupMix("mongodb", newChecker(db))
+}
+
+// runLookupResourcesPercentiles benchmarks lookups for the users at the p10,
+// p50, p90 and p99 of the grant counts (BENCH_LOOKUPRES_*_PCT_USERS); see
+// utils.RunPercentileLookups.
+func runLookupResourcesPercentiles(db *mongo.Database) {
+	utils.RunPercentileLookups("mongodb", newChecker(db))
+}
+
+// runViralFanIn benchmarks checks and lookups on the resources shared with many
+// users directly (RLP_VIRAL_RESOURCES); see utils.RunViralFanIn.
+func runViralFanIn(db *mongo.Database) {
+	utils.RunViralFanIn("mongodb", newChecker(db))
+}
+
+// runWorstCaseChecks benchmarks view checks on the pairs granted only through
+// nested groups (check_view_worst_case); see utils.RunWorstCaseChecks.
+func runWorstCaseChecks(db *mongo.Database) {
+	utils.RunWorstCaseChecks("mongodb", newChecker(db))
+}
+
+// runWorkload benchmarks the blended mix of checks and lookups named by
+// BENCH_WORKLOAD (default "" = skip); see utils.RunWorkload.
+func runWorkload(db *mongo.Database) {
+	utils.RunWorkload("mongodb", newChecker(db))
+}
+
+// runMyGroups benchmarks listing the direct and effective groups of sampled
+// users (BENCH_MY_GROUPS_ITER, default 100; 0 skips); see utils.RunMyGroups.
+func runMyGroups(db *mongo.Database) {
+	utils.RunMyGroups("mongodb", newChecker(db))
+}
+
+// runAccessSummary benchmarks counting the resources sampled users can view
+// and manage (BENCH_ACCESS_SUMMARY_ITER, default 100; 0 skips); see
+// utils.RunAccessSummary.
+func runAccessSummary(db *mongo.Database) {
+	utils.RunAccessSummary("mongodb", newChecker(db))
+}
+
+// runMiddlewareOverhead benchmarks a resource fetch with and without a view
+// check in front of it (BENCH_MIDDLEWARE_ITER, default 1000; 0 skips); see
+// utils.RunMiddlewareOverhead.
+func runMiddlewareOverhead(db *mongo.Database) {
+	utils.RunMiddlewareOverhead("mongodb", newChecker(db), func(ctx context.Context, resourceID string) error {
+		// The resource fields only, not its ACL arrays.
+		var doc bson.M
+		err := db.Collection("resources").FindOne(ctx, bson.D{{Key: "resource_id", Value: resourceID}},
+			options.FindOne().SetProjection(bson.D{{Key: "resource_id", Value: 1}, {Key: "org_id", Value: 1}, {Key: "created_at", Value: 1}, {Key: "updated_at", Value: 1}})).Decode(&doc)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return err
+	})
+}
+
+// runFullExport benchmarks streaming the ACL arrays of every resource document
+// (export_full_acl, BENCH_EXPORT_ITER), counting one row per array entry as
+// resource_acl would hold it; see utils.RunFullExport.
+func runFullExport(db *mongo.Database) {
+	aclFields := []string{"manager_user_ids", "viewer_user_ids", "manager_group_ids", "viewer_group_ids"}
+	projection := bson.D{{Key: "_id", Value: 0}, {Key: "resource_id", Value: 1}}
+	for _, f := range aclFields {
+		projection = append(projection, bson.E{Key: f, Value: 1})
+	}
+	utils.RunFullExport("mongodb", func(ctx context.Context) (int, error) {
+		cur, err := db.Collection("resources").Find(ctx, bson.D{}, options.Find().SetProjection(projection))
+		if err != nil {
+			return 0, err
+		}
+		defer cur.Close(ctx)
+		n := 0
+		err = streamCursor(ctx, cur, func(m bson.M) {
+			for _, f := range aclFields {
+				n += len(idsOf(m, f))
+			}
+		})
+		return n, err
+	})
 }
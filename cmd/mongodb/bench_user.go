@@ -0,0 +1,48 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// errCodeUserExists is returned by createUser when the user is already defined.
+const errCodeUserExists = 51003
+
+// ensureBenchUser provisions the read-only user the benchmark connects as
+// (see infrastructure.UseBenchCredentials). No-op unless MONGO_BENCH_USER is
+// set. The user lives in MONGO_AUTH_SOURCE with the built-in read role on the
+// benchmark database.
+func ensureBenchUser(ctx context.Context, client *mongo.Client, db *mongo.Database) {
+	user, password, ok := infrastructure.BenchCredentialsFromEnv("MONGO")
+	if !ok {
+		return
+	}
+	authDB := client.Database(utils.GetEnvWithDefault("MONGO_AUTH_SOURCE", "admin"))
+	roles := bson.A{bson.D{{Key: "role", Value: "read"}, {Key: "db", Value: db.Name()}}}
+
+	err := authDB.RunCommand(ctx, bson.D{
+		{Key: "createUser", Value: user},
+		{Key: "pwd", Value: password},
+		{Key: "roles", Value: roles},
+	}).Err()
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == errCodeUserExists {
+		err = authDB.RunCommand(ctx, bson.D{
+			{Key: "updateUser", Value: user},
+			{Key: "pwd", Value: password},
+			{Key: "roles", Value: roles},
+		}).Err()
+	}
+	if err != nil {
+		log.Fatalf("[mongodb] bench user: %v", err)
+	}
+
+	log.Printf("[mongodb] Read-only bench user %q ready", user)
+}
@@ -0,0 +1,191 @@
+package mongodb
+
+import (
+	"context"
+	"iter"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+
+	"test-tls/dataset"
+	"test-tls/utils"
+)
+
+// bulkLoader runs the BulkWrite batches of load-data with the knobs needed for
+// fair load-time comparisons against the other backends.
+//
+// Env vars:
+//
+//	MONGO_BULK_BATCH_SIZE   (default: 10000) write models per BulkWrite
+//	MONGO_BULK_ORDERED      (default: false) ordered BulkWrite stops at the first error
+//	MONGO_WRITE_CONCERN     (default: server default) "majority", "0", "1", "<n>" or a tag set name
+//	MONGO_WRITE_JOURNAL     (default: unset) "true"/"false" to force j on the write concern
+//	MONGO_BULK_TRANSACTION  (default: false) wrap every batch in a transaction (replica set only)
+type bulkLoader struct {
+	client       *mongo.Client
+	db           *mongo.Database
+	batchSize    int
+	ordered      bool
+	writeConcern *writeconcern.WriteConcern
+	transaction  bool
+
+	batches int
+	ops     int
+	rows    int
+	busy    time.Duration
+}
+
+func newBulkLoader(client *mongo.Client, db *mongo.Database) *bulkLoader {
+	l := &bulkLoader{
+		client:      client,
+		db:          db,
+		batchSize:   utils.GetEnvInt("MONGO_BULK_BATCH_SIZE", batchSize),
+		ordered:     utils.GetEnvWithDefault("MONGO_BULK_ORDERED", "false") == "true",
+		transaction: utils.GetEnvWithDefault("MONGO_BULK_TRANSACTION", "false") == "true",
+	}
+	if l.batchSize < 1 {
+		l.batchSize = batchSize
+	}
+	l.writeConcern = parseWriteConcern(utils.GetEnvWithDefault("MONGO_WRITE_CONCERN", ""), utils.GetEnvWithDefault("MONGO_WRITE_JOURNAL", ""))
+
+	wc := "server-default"
+	if l.writeConcern != nil {
+		wc = describeWriteConcern(l.writeConcern)
+		if l.transaction && !l.writeConcern.Acknowledged() {
+			log.Fatalf("[mongodb] MONGO_BULK_TRANSACTION requires an acknowledged write concern (got %s)", wc)
+		}
+	}
+	log.Printf("[mongodb] bulk loader: batch=%d ordered=%t writeConcern=%s transaction=%t", l.batchSize, l.ordered, wc, l.transaction)
+	return l
+}
+
+// collection returns a handle on name with the configured write concern applied.
+func (l *bulkLoader) collection(name string) *mongo.Collection {
+	opts := options.Collection()
+	if l.writeConcern != nil {
+		opts.SetWriteConcern(l.writeConcern)
+	}
+	return l.db.Collection(name, opts)
+}
+
+// exec sends one batch. Errors are logged, not fatal, since unordered upserts
+// may report duplicate-key races that do not lose data.
+func (l *bulkLoader) exec(coll *mongo.Collection, writes []mongo.WriteModel) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	opts := options.BulkWrite().SetOrdered(l.ordered)
+
+	start := time.Now()
+	var err error
+	if l.transaction {
+		err = l.execInTransaction(ctx, coll, writes, opts)
+	} else {
+		_, err = coll.BulkWrite(ctx, writes, opts)
+	}
+	l.busy += time.Since(start)
+	l.batches++
+	l.ops += len(writes)
+
+	if err != nil {
+		log.Printf("[mongodb] BulkWrite error (may be duplicates): %v", err)
+	}
+}
+
+func (l *bulkLoader) execInTransaction(ctx context.Context, coll *mongo.Collection, writes []mongo.WriteModel, opts *options.BulkWriteOptions) error {
+	sess, err := l.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(ctx)
+
+	_, err = sess.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return coll.BulkWrite(sc, writes, opts)
+	})
+	return err
+}
+
+// bulkUpsert writes one write model per record of rows into the collection
+// coll, l.batchSize models per BulkWrite, and logs the progress of table.
+func bulkUpsert[T any](l *bulkLoader, table, coll string, rows iter.Seq[T], model func(T) mongo.WriteModel) {
+	c := l.collection(coll)
+	progress := dataset.NewProgress("mongodb", table, &l.rows)
+	writes := make([]mongo.WriteModel, 0, l.batchSize)
+	for batch := range dataset.Batches(rows, l.batchSize) {
+		writes = writes[:0]
+		for _, row := range batch {
+			writes = append(writes, model(row))
+		}
+		l.exec(c, writes)
+		progress.Add(len(batch))
+	}
+	l.loaded(table, progress.Count(), progress.Start())
+}
+
+// loaded logs the per-file row count together with its throughput.
+func (l *bulkLoader) loaded(name string, rows int, stepStart time.Time) {
+	elapsed := time.Since(stepStart)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(rows) / elapsed.Seconds()
+	}
+	log.Printf("[mongodb] Loaded %s: %d rows elapsed=%s rows/sec=%.0f", name, rows, elapsed.Truncate(time.Millisecond), rate)
+}
+
+// summary logs the aggregate BulkWrite throughput of the whole import.
+func (l *bulkLoader) summary() {
+	rate := 0.0
+	if l.busy > 0 {
+		rate = float64(l.ops) / l.busy.Seconds()
+	}
+	log.Printf("[mongodb] BulkWrite totals: batches=%d ops=%d busy=%s ops/sec=%.0f",
+		l.batches, l.ops, l.busy.Truncate(time.Millisecond), rate)
+}
+
+func parseWriteConcern(w, journal string) *writeconcern.WriteConcern {
+	if w == "" && journal == "" {
+		return nil
+	}
+
+	wc := &writeconcern.WriteConcern{}
+	switch {
+	case w == "":
+	case strings.EqualFold(w, "majority"):
+		wc.W = "majority"
+	default:
+		if n, err := strconv.Atoi(w); err == nil {
+			wc.W = n
+		} else {
+			wc.W = w
+		}
+	}
+	if journal != "" {
+		j := journal == "true"
+		wc.Journal = &j
+	}
+	return wc
+}
+
+func describeWriteConcern(wc *writeconcern.WriteConcern) string {
+	var b strings.Builder
+	b.WriteString("w=")
+	if wc.W == nil {
+		b.WriteString("default")
+	} else {
+		switch v := wc.W.(type) {
+		case int:
+			b.WriteString(strconv.Itoa(v))
+		case string:
+			b.WriteString(v)
+		}
+	}
+	if wc.Journal != nil {
+		b.WriteString(" j=")
+		b.WriteString(strconv.FormatBool(*wc.Journal))
+	}
+	return b.String()
+}
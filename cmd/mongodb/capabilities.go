@@ -0,0 +1,41 @@
+package mongodb
+
+import "test-tls/utils"
+
+// Capabilities lists what the mongodb module supports, for the capabilities
+// command. Keep it in step with the benchmark scenarios in bechmark_reads.go.
+func Capabilities() utils.Capabilities {
+	return utils.Capabilities{
+		Scenarios: []string{
+			"check_manage_direct_user",
+			"check_manage_org_admin",
+			"check_view_via_group_member",
+			"check_time_bounded_direct_user",
+			"lookup_resources_manage_super",
+			"lookup_resources_view_regular",
+			"lookup_resources_*_mix (BENCH_LOOKUPRES_MIX_USERS)",
+			"lookup_resources_*_pct (BENCH_LOOKUPRES_*_PCT_USERS)",
+			"check_view_viral_direct_user (BENCH_VIRAL_RESOURCES)",
+			"lookup_resources_view_viral (BENCH_LOOKUPRES_VIRAL_USER)",
+			"check_view_worst_case (nested-group-only grants, BENCH_CHECK_WORST_ITER)",
+			"workload_* (blended request mix, BENCH_WORKLOAD)",
+			"list_my_groups_direct, list_my_groups_effective (groups of a user, BENCH_MY_GROUPS_ITER)",
+			"count_access_summary (view and manage counts of a user, BENCH_ACCESS_SUMMARY_ITER)",
+			"middleware_overhead (resource fetch with and without a view check, BENCH_MIDDLEWARE_ITER)",
+			"export_full_acl (full ACL stream, BENCH_EXPORT_ITER)",
+			"per-scenario cost from serverStatus (BENCH_COST)",
+		},
+		Schemas: []string{
+			"MONGO_GROUP_MODE=direct|graphlookup|expanded (nested groups in lookups, --schema)",
+			"MONGO_LOOKUP_MODE=stream|count (lookup scenarios, --schema)",
+		},
+		Consistency: []string{
+			"MONGO_READ_PREFERENCE=primary|primaryPreferred|secondary|secondaryPreferred|nearest",
+			"MONGO_READ_CONCERN=local|available|majority|linearizable|snapshot",
+			"MONGO_MAX_STALENESS_SEC=N (>= 90, non-primary reads)",
+		},
+		Writes: []string{
+			"user offboarding: $pull from the membership and ACL arrays (offboard, BENCH_OFFBOARD_USERS)",
+		},
+	}
+}
@@ -0,0 +1,63 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"test-tls/utils"
+)
+
+// costCommands are the read commands the benchmark issues, counted as its
+// queries in serverStatus metrics.commands.
+var costCommands = []string{"find", "aggregate", "count", "distinct", "getMore"}
+
+// costMeter reads serverStatus: the read commands run and the keys and
+// documents the query executor examined and returned, the server-wide totals
+// of explain's executionStats (totalKeysExamined, totalDocsExamined,
+// nReturned) without running explain on every query. Other clients of the
+// server count too.
+func costMeter(db *mongo.Database) utils.CostMeter {
+	return func(ctx context.Context) ([]utils.CostCounter, error) {
+		var status bson.M
+		if err := db.RunCommand(ctx, bson.D{{Key: "serverStatus", Value: 1}}).Decode(&status); err != nil {
+			return nil, fmt.Errorf("serverStatus: %w", err)
+		}
+		var commands float64
+		for _, name := range costCommands {
+			commands += bsonNumber(status, "metrics", "commands", name, "total")
+		}
+		return []utils.CostCounter{
+			{Name: "commands", Value: commands},
+			{Name: "keys_examined", Value: bsonNumber(status, "metrics", "queryExecutor", "scanned")},
+			{Name: "docs_examined", Value: bsonNumber(status, "metrics", "queryExecutor", "scannedObjects")},
+			{Name: "docs_returned", Value: bsonNumber(status, "metrics", "document", "returned")},
+		}, nil
+	}
+}
+
+// bsonNumber returns the number at path in m, 0 when it is missing.
+func bsonNumber(m bson.M, path ...string) float64 {
+	var v any = m
+	for _, key := range path {
+		switch doc := v.(type) {
+		case bson.M:
+			v = doc[key]
+		case bson.D:
+			v = doc.Map()[key]
+		default:
+			return 0
+		}
+	}
+	switch n := v.(type) {
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
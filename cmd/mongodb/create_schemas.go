@@ -8,6 +8,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 
+	mongoauthz "test-tls/authz/mongodb"
 	"test-tls/infrastructure"
 )
 
@@ -17,6 +18,7 @@ import (
 // - organizations: org-level admins/members
 // - users: cross-org user membership hints
 // - groups: direct members/managers and nested groups
+// - group_members_expanded: effective group members, nested groups included
 // - resources: org, direct ACLs (users/groups) for manage/view
 //
 // Note: Actual document shapes are populated by load_data; here we ensure
@@ -34,6 +36,9 @@ func MongodbCreateSchemas() {
 	// Common timeout for index creation.
 	idxTimeout := 30 * time.Second
 
+	// Collections that exist already are not the tool's to drop.
+	before := listCollections(parent, db)
+
 	// Helper: ensure collection exists (Mongo will create on first use; we touch it).
 	ensureColl := func(name string) *mongo.Collection {
 		c := db.Collection(name)
@@ -70,7 +75,14 @@ func MongodbCreateSchemas() {
 		{Name: "manager_group_ids_idx", Keys: bson.D{{Key: "manager_group_ids", Value: 1}}},
 	}, idxTimeout, "groups")
 
-	// resources: { resource_id, org_id, manager_user_ids[], viewer_user_ids[], manager_group_ids[], viewer_group_ids[] }
+	// group_members_expanded: { group_id, user_id, role } precomputed by load_data (MONGO_GROUP_MODE=expanded)
+	expanded := ensureColl(mongoauthz.ExpandedCollection)
+	CreateIndexesWithLog(parent, expanded, []MongoIndexSpec{
+		{Name: "group_user_role_unique", Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "role", Value: 1}}, Unique: true},
+		{Name: "user_role_group_idx", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "role", Value: 1}, {Key: "group_id", Value: 1}}},
+	}, idxTimeout, mongoauthz.ExpandedCollection)
+
+	// resources: { resource_id, org_id, manager_user_ids[], viewer_user_ids[], manager_group_ids[], viewer_group_ids[], user_grant_windows[] }
 	resources := ensureColl("resources")
 	CreateIndexesWithLog(parent, resources, []MongoIndexSpec{
 		{Name: "resource_id_unique", Keys: bson.D{{Key: "resource_id", Value: 1}}, Unique: true},
@@ -85,8 +97,13 @@ func MongodbCreateSchemas() {
 		{Name: "org_view_user_idx", Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "viewer_user_ids", Value: 1}}},
 		{Name: "org_manage_group_idx", Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "manager_group_ids", Value: 1}}},
 		{Name: "org_view_group_idx", Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "viewer_group_ids", Value: 1}}},
+		// Time-bounded direct grants: { user_id, relation, valid_from, valid_until }
+		{Name: "user_grant_windows_user_idx", Keys: bson.D{{Key: "user_grant_windows.user_id", Value: 1}}},
 	}, idxTimeout, "resources")
 
-	log.Printf("[mongodb] schema creation complete: organizations, users, groups, resources")
-	_ = client // referenced via cleanup; kept for future extension
+	recordCollections(parent, db, before)
+
+	log.Printf("[mongodb] schema creation complete: organizations, users, groups, %s, resources", mongoauthz.ExpandedCollection)
+
+	ensureBenchUser(parent, client, db)
 }
@@ -0,0 +1,65 @@
+package mongodb
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"test-tls/utils"
+)
+
+// listCollections returns the collections of the database.
+func listCollections(ctx context.Context, db *mongo.Database) []string {
+	lctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	names, err := db.ListCollectionNames(lctx, bson.D{})
+	if err != nil {
+		log.Fatalf("[mongodb] list collections: %v", err)
+	}
+	return names
+}
+
+// recordCollections records the collections created since before, the
+// listing taken ahead of create-schema, in rlp_objects, leaving out those it
+// found already there, so that drop removes what the tool created and
+// nothing else of the same name (utils.OwnedRecorded).
+func recordCollections(ctx context.Context, db *mongo.Database, before []string) {
+	for _, name := range utils.NewObjects(before, listCollections(ctx, db)) {
+		_, err := db.Collection("rlp_objects").UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: name}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "created_at", Value: time.Now()}}}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			log.Fatalf("[mongodb] record collection %s: %v", name, err)
+		}
+	}
+}
+
+// recordedObjects returns the collections recorded in rlp_objects, none when
+// found, the collections of the database, holds no such collection.
+func recordedObjects(ctx context.Context, db *mongo.Database, found []string) (map[string]bool, error) {
+	recorded := map[string]bool{}
+	if !slices.Contains(found, "rlp_objects") {
+		return recorded, nil
+	}
+	cur, err := db.Collection("rlp_objects").Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var doc struct {
+			Name string `bson:"_id"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		recorded[doc.Name] = true
+	}
+	return recorded, cur.Err()
+}
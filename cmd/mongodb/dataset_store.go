@@ -0,0 +1,34 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// datasetStore records the dataset load-data loaded in the one document of
+// the rlp_dataset collection, overwritten by every load (utils.GuardDataset).
+type datasetStore struct{ db *mongo.Database }
+
+func (s datasetStore) RecordDataset(ctx context.Context, id string) error {
+	_, err := s.db.Collection("rlp_dataset").UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: "dataset"}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "id", Value: id}, {Key: "loaded_at", Value: time.Now()}}}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+func (s datasetStore) LoadedDataset(ctx context.Context) (string, error) {
+	var doc struct {
+		ID string `bson:"id"`
+	}
+	err := s.db.Collection("rlp_dataset").FindOne(ctx, bson.D{{Key: "_id", Value: "dataset"}}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", nil
+	}
+	return doc.ID, err
+}
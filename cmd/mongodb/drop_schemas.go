@@ -5,11 +5,18 @@ import (
 	"log"
 	"time"
 
+	"go.mongodb.org/mongo-driver/mongo"
+
+	mongoauthz "test-tls/authz/mongodb"
 	"test-tls/infrastructure"
+	"test-tls/utils"
 )
 
 // MongodbDropSchemas drops collections created for benchmarks. Dropping
-// collections also drops their indexes. Follows child-first semantics.
+// collections also drops their indexes. Follows child-first semantics. Only
+// the collections create-schema recorded in rlp_objects are dropped: other
+// collections of the database, including ones that share a name with ours,
+// are listed and left in place (utils.OwnedRecorded).
 func MongodbDropSchemas() {
 	ctx := context.Background()
 	client, db, cleanup, err := infrastructure.NewMongoFromEnv(ctx)
@@ -25,11 +32,28 @@ func MongodbDropSchemas() {
 	// Drop order: child-like collections first for safety.
 	cols := []string{
 		"resources",
+		mongoauthz.ExpandedCollection,
 		"groups",
 		"organizations",
 		"users",
+		// The dataset load-data recorded (utils.GuardDataset)
+		"rlp_dataset",
+		// The record of what the tool created, once that is gone.
+		"rlp_objects",
+	}
+	found := listCollections(ctx, db)
+	recorded, err := recordedObjects(ctx, db, found)
+	if err != nil {
+		log.Fatalf("[mongodb] drop: list recorded collections: %v", err)
 	}
+	own := utils.OwnedRecorded("mongodb", found, recorded, cols)
+
+	utils.GuardDrop("mongodb", countCollections(ctx, db, utils.OwnedNames(own, cols)))
+
 	for _, c := range cols {
+		if !own[c] {
+			continue
+		}
 		dctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 		err := db.Collection(c).Drop(dctx)
 		cancel()
@@ -43,3 +67,16 @@ func MongodbDropSchemas() {
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 	log.Printf("[mongodb] MongoDB drop schemas DONE: elapsed=%s", elapsed)
 }
+
+// countCollections counts the documents of cols for the drop guard, from
+// collection metadata so it stays cheap on large collections.
+func countCollections(ctx context.Context, db *mongo.Database, cols []string) []utils.DropCount {
+	counts := make([]utils.DropCount, 0, len(cols))
+	for _, c := range cols {
+		cctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		n, err := db.Collection(c).EstimatedDocumentCount(cctx)
+		cancel()
+		counts = append(counts, utils.DropCount{Table: c, Rows: n, Missing: err != nil})
+	}
+	return counts
+}
@@ -0,0 +1,276 @@
+package mongodb
+
+import (
+	"context"
+	"iter"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	mongoauthz "test-tls/authz/mongodb"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// groupModeFromEnv returns MONGO_GROUP_MODE, one of mongoauthz.GroupModes
+// (default: direct).
+func groupModeFromEnv() string {
+	mode := utils.GetEnvWithDefault("MONGO_GROUP_MODE", "direct")
+	if !slices.Contains(mongoauthz.GroupModes, mode) {
+		log.Fatalf("[mongodb] MONGO_GROUP_MODE=%q: want one of %s", mode, strings.Join(mongoauthz.GroupModes, ", "))
+	}
+	return mode
+}
+
+// groupNode is a groups document as read back to expand the hierarchy.
+type groupNode struct {
+	GroupID       string   `bson:"group_id"`
+	Members       []string `bson:"direct_member_user_ids"`
+	Managers      []string `bson:"direct_manager_user_ids"`
+	MemberGroups  []string `bson:"member_group_ids"`
+	ManagerGroups []string `bson:"manager_group_ids"`
+}
+
+// expandedMember is one group_members_expanded document.
+type expandedMember struct {
+	group, user, role string
+}
+
+// loadGroupMembersExpanded precomputes group_members_expanded from the loaded
+// groups collection, with the closure rules of the Scylla loader: a group's
+// managers include the managers of its manager_group_ids children, its
+// members include its managers and the members of its member_group_ids
+// children.
+func loadGroupMembersExpanded(l *bulkLoader) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cur, err := l.db.Collection("groups").Find(ctx, bson.D{})
+	if err != nil {
+		log.Fatalf("[mongodb] %s: read groups: %v", mongoauthz.ExpandedCollection, err)
+	}
+	nodes := map[string]groupNode{}
+	for cur.Next(ctx) {
+		var g groupNode
+		if err := cur.Decode(&g); err != nil {
+			log.Fatalf("[mongodb] %s: decode group: %v", mongoauthz.ExpandedCollection, err)
+		}
+		nodes[g.GroupID] = g
+	}
+	if err := cur.Err(); err != nil {
+		log.Fatalf("[mongodb] %s: read groups: %v", mongoauthz.ExpandedCollection, err)
+	}
+	cur.Close(ctx)
+
+	managers := expandClosure(nodes, func(g groupNode) ([]string, []string) { return g.Managers, g.ManagerGroups }, nil)
+	members := expandClosure(nodes, func(g groupNode) ([]string, []string) { return g.Members, g.MemberGroups }, managers)
+
+	rows := func(yield func(expandedMember) bool) {
+		for _, role := range []struct {
+			name  string
+			users map[string]map[string]bool
+		}{{"manager", managers}, {"member", members}} {
+			for group, users := range role.users {
+				for user := range users {
+					if !yield(expandedMember{group, user, role.name}) {
+						return
+					}
+				}
+			}
+		}
+	}
+	bulkUpsert(l, mongoauthz.ExpandedCollection, mongoauthz.ExpandedCollection, iter.Seq[expandedMember](rows), func(m expandedMember) mongo.WriteModel {
+		doc := bson.D{{Key: "group_id", Value: m.group}, {Key: "user_id", Value: m.user}, {Key: "role", Value: m.role}}
+		return &mongo.UpdateOneModel{Filter: doc, Update: bson.D{{Key: "$setOnInsert", Value: doc}}, Upsert: boolPtr(true)}
+	})
+}
+
+// expandClosure returns the effective users of every group: its direct users
+// and those of its child groups, transitively, plus the users of base for the
+// same group. edges returns a group's direct users and child groups. Cycles
+// in the hierarchy are cut at the group being expanded.
+func expandClosure(nodes map[string]groupNode, edges func(groupNode) ([]string, []string), base map[string]map[string]bool) map[string]map[string]bool {
+	memo := map[string]map[string]bool{}
+	visiting := map[string]bool{}
+	var expand func(id string) map[string]bool
+	expand = func(id string) map[string]bool {
+		if users, ok := memo[id]; ok {
+			return users
+		}
+		users := map[string]bool{}
+		if visiting[id] {
+			return users
+		}
+		visiting[id] = true
+		direct, children := edges(nodes[id])
+		for _, u := range direct {
+			users[u] = true
+		}
+		for u := range base[id] {
+			users[u] = true
+		}
+		for _, child := range children {
+			for u := range expand(child) {
+				users[u] = true
+			}
+		}
+		visiting[id] = false
+		memo[id] = users
+		return users
+	}
+	for id := range nodes {
+		expand(id)
+	}
+	return memo
+}
+
+// groupQuery is one of the queries timed per group resolution mode by
+// MongodbGroupResolution.
+type groupQuery struct {
+	name       string
+	permission string
+	resolve    bool // time the group resolution alone, not the resource lookup
+}
+
+var groupQueries = []groupQuery{
+	{name: "resolve_manager_groups", permission: "manage", resolve: true},
+	{name: "resolve_member_groups", permission: "view", resolve: true},
+	{name: "lookup_manage", permission: "manage"},
+	{name: "lookup_view", permission: "view"},
+}
+
+// MongodbGroupResolution benchmarks the group resolution modes of the lookup
+// pipelines against each other (see mongoauthz.GroupModes): the groups a user is an
+// effective manager or member of, and the manage/view lookups built on them.
+// "direct" ignores nested groups and is the baseline cost; "graphlookup" and
+// "expanded" must agree on every row count, a difference is logged as a
+// mismatch. group_members_expanded is written by load-data.
+//
+// The user is BENCH_LOOKUPRES_VIEW_USER, or a direct member of a group nested
+// in another one through member_group_ids.
+//
+// Env vars:
+//
+//	MONGO_GROUP_VARIANTS  (default: all, comma-separated)
+//	MONGO_GROUP_ITER      (default: 10)
+func MongodbGroupResolution() {
+	client, db, cleanup, err := infrastructure.NewMongoFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[mongodb] failed to create mongo client: %v", err)
+	}
+	defer cleanup()
+
+	consistency := readConsistencyFromEnv()
+	db = consistency.database(client, db.Name())
+	utils.LogConsistency("mongodb", consistency.String())
+
+	modes := mongoauthz.GroupModes
+	if spec := utils.GetEnvWithDefault("MONGO_GROUP_VARIANTS", ""); spec != "" {
+		modes = nil
+		for m := range strings.SplitSeq(spec, ",") {
+			m = strings.TrimSpace(m)
+			if !slices.Contains(mongoauthz.GroupModes, m) {
+				log.Fatalf("[mongodb] MONGO_GROUP_VARIANTS: unknown variant %q (want %s)", m, strings.Join(mongoauthz.GroupModes, ", "))
+			}
+			modes = append(modes, m)
+		}
+	}
+	iters := utils.GetEnvInt("MONGO_GROUP_ITER", 10)
+
+	userID := utils.GetEnvWithDefault("BENCH_LOOKUPRES_VIEW_USER", "")
+	if userID == "" {
+		userID = nestedGroupMember(db)
+	}
+	if userID == "" {
+		log.Fatalf("[mongodb] group_resolution: no member of a nested group found; set BENCH_LOOKUPRES_VIEW_USER")
+	}
+	n, err := db.Collection(mongoauthz.ExpandedCollection).EstimatedDocumentCount(context.Background())
+	if err != nil || n == 0 {
+		log.Printf("[mongodb] [group_resolution] warning: %s is empty; run load-data to build it", mongoauthz.ExpandedCollection)
+	}
+	log.Printf("[mongodb] [group_resolution] variants=%s iters=%d user=%s expanded_docs=%d", strings.Join(modes, ","), iters, userID, n)
+
+	rows := map[string]int{} // query -> rows of the graphlookup variant
+	for _, mode := range modes {
+		for _, q := range groupQueries {
+			got := runGroupQuery(db, mode, q, userID, iters)
+			switch {
+			case mode == "graphlookup":
+				rows[q.name] = got
+			case mode == "expanded":
+				if want, ok := rows[q.name]; ok && got != want {
+					log.Printf("[mongodb] [group_resolution] variant=%s query=%s MISMATCH: rows=%d, graphlookup returned %d", mode, q.name, got, want)
+				}
+			}
+		}
+	}
+	log.Println("[mongodb] == MongoDB group resolution comparison DONE ==")
+}
+
+// runGroupQuery times q for userID under mode and logs its DONE line; it
+// returns the rows of the last successful iteration.
+func runGroupQuery(db *mongo.Database, mode string, q groupQuery, userID string, iters int) int {
+	durations := make([]time.Duration, 0, iters)
+	rows := 0
+	errs := utils.NewErrorTally()
+	for i := range iters {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		start := time.Now()
+		n, err := groupQueryRows(ctx, db, mode, q, userID)
+		dur := time.Since(start)
+		cancel()
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[mongodb] [group_resolution] variant=%s query=%s iter=%d failed class=%s: %v", mode, q.name, i, class, err)
+			continue
+		}
+		durations = append(durations, dur)
+		rows = n
+	}
+	log.Printf("[mongodb] [group_resolution] variant=%s query=%s DONE: iters=%d rows=%d %s", mode, q.name, len(durations), rows, utils.LatencySummary(durations))
+	log.Printf("[mongodb] [group_resolution] variant=%s query=%s ERRORS: %s", mode, q.name, errs.Summary(iters))
+	return rows
+}
+
+func groupQueryRows(ctx context.Context, db *mongo.Database, mode string, q groupQuery, userID string) (int, error) {
+	if !q.resolve {
+		filter, err := mongoauthz.LookupFilter(ctx, db, mode, userID, q.permission)
+		if err != nil {
+			return 0, err
+		}
+		n, err := db.Collection("resources").CountDocuments(ctx, filter)
+		return int(n), err
+	}
+	groups, err := mongoauthz.ManagerGroups(ctx, db, mode, userID)
+	if err == nil && q.permission == "view" {
+		groups, err = mongoauthz.MemberGroups(ctx, db, mode, userID, groups)
+	}
+	return len(groups), err
+}
+
+// nestedGroupMember returns a direct member of a group that another group
+// nests through member_group_ids, or "" when the hierarchy has none.
+func nestedGroupMember(db *mongo.Database) string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var parent groupNode
+	err := db.Collection("groups").FindOne(ctx, bson.D{{Key: "member_group_ids.0", Value: bson.D{{Key: "$exists", Value: true}}}}).Decode(&parent)
+	if err != nil {
+		return ""
+	}
+	var child groupNode
+	err = db.Collection("groups").FindOne(ctx,
+		bson.D{{Key: "group_id", Value: bson.D{{Key: "$in", Value: parent.MemberGroups}}}, {Key: "direct_member_user_ids.0", Value: bson.D{{Key: "$exists", Value: true}}}},
+		options.FindOne().SetProjection(bson.D{{Key: "group_id", Value: 1}, {Key: "direct_member_user_ids", Value: 1}}),
+	).Decode(&child)
+	if err != nil || len(child.Members) == 0 {
+		return ""
+	}
+	log.Printf("[mongodb] [group_resolution] user picked: %s (member of %s, nested in %s)", child.Members[0], child.GroupID, parent.GroupID)
+	return child.Members[0]
+}
@@ -2,36 +2,24 @@ package mongodb
 
 import (
 	"context"
-	"encoding/csv"
-	"io"
 	"log"
-	"os"
-	"path/filepath"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"test-tls/dataset"
 	"test-tls/infrastructure"
+	"test-tls/utils"
 )
 
 const (
-	dataDir   = "data"
 	batchSize = 10000
 )
 
-func openCSV(name string) (*csv.Reader, *os.File) {
-	full := filepath.Join(dataDir, name)
-	f, err := os.Open(full)
-	if err != nil {
-		log.Fatalf("[mongodb] open %s: %v", full, err)
-	}
-	return csv.NewReader(f), f
-}
-
 // MongodbCreateData ingests all CSVs into MongoDB using bulk upserts, mapping
-// to the denormalized schema in create_schemas.go.
+// to the denormalized schema in create_schemas.go. Batch size, ordering, write
+// concern and per-batch transactions are configured as documented on bulkLoader.
 func MongodbCreateData() {
 	client, db, cleanup, err := infrastructure.NewMongoFromEnv(context.Background())
 	if err != nil {
@@ -41,366 +29,105 @@ func MongodbCreateData() {
 	defer cleanup()
 
 	start := time.Now()
-	log.Printf("[mongodb] == Starting Mongo data import from CSV in %q ==", dataDir)
-
-	upsertOrgs(db, start)
-	upsertGroups(db, start)
-	upsertGroupMemberships(db, start)
-	upsertGroupHierarchy(db, start)
-	upsertResources(db, start)
-	upsertResourceACL(db, start)
-
-	elapsed := time.Since(start).Truncate(time.Millisecond)
-	log.Printf("[mongodb] Mongo data import DONE: elapsed=%s", elapsed)
-	_ = client
-}
-
-// organizations: accumulate admin/member arrays per org
-func upsertOrgs(db *mongo.Database, start time.Time) {
-	r, f := openCSV("org_memberships.csv")
-	defer f.Close()
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[mongodb] read org_memberships header: %v", err)
-	}
-
-	coll := db.Collection("organizations")
-	writes := make([]mongo.WriteModel, 0, batchSize)
-	count := 0
-
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[mongodb] read org_memberships row: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[mongodb] invalid org_memberships row: %#v", rec)
-		}
-
-		orgID := rec[0]
-		userID := rec[1]
-		role := rec[2]
-
-		update := bson.D{}
-		switch role {
-		case "admin":
-			update = bson.D{{Key: "$addToSet", Value: bson.D{{Key: "admin_user_ids", Value: userID}}}}
-		default:
-			update = bson.D{{Key: "$addToSet", Value: bson.D{{Key: "member_user_ids", Value: userID}}}}
-		}
-
-		// Ensure base doc with unique org_id
-		base := bson.D{{Key: "$setOnInsert", Value: bson.D{{Key: "org_id", Value: orgID}}}}
-		// Merge operations: add arrays + setOnInsert
-		merged := bson.D{}
-		for _, el := range update {
-			merged = append(merged, el)
-		}
-		for _, el := range base {
-			merged = append(merged, el)
-		}
-
-		writes = append(writes, &mongo.UpdateOneModel{
-			Filter: bson.D{{Key: "org_id", Value: orgID}},
-			Update: merged,
-			Upsert: boolPtr(true),
-		})
-		count++
-		if len(writes) >= batchSize {
-			bulkExec(coll, writes)
-			writes = writes[:0]
-		}
-		if count%10000 == 0 {
-			log.Printf("[mongodb] Loaded org_memberships progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-	if len(writes) > 0 {
-		bulkExec(coll, writes)
-	}
-	log.Printf("[mongodb] Loaded org_memberships: %d rows", count)
-}
-
-// groups.csv -> create group doc with org_id
-func upsertGroups(db *mongo.Database, start time.Time) {
-	r, f := openCSV("groups.csv")
-	defer f.Close()
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[mongodb] read groups header: %v", err)
-	}
-	coll := db.Collection("groups")
-	writes := make([]mongo.WriteModel, 0, batchSize)
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[mongodb] read groups row: %v", err)
-		}
-		if len(rec) < 2 {
-			log.Fatalf("[mongodb] invalid groups row: %#v", rec)
-		}
-		groupID := rec[0]
-		orgID := rec[1]
-		writes = append(writes, &mongo.UpdateOneModel{
-			Filter: bson.D{{Key: "group_id", Value: groupID}},
-			Update: bson.D{{Key: "$set", Value: bson.D{{Key: "group_id", Value: groupID}, {Key: "org_id", Value: orgID}}}},
-			Upsert: boolPtr(true),
-		})
-		count++
-		if len(writes) >= batchSize {
-			bulkExec(coll, writes)
-			writes = writes[:0]
-		}
-		if count%10000 == 0 {
-			log.Printf("[mongodb] Loaded groups progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-	if len(writes) > 0 {
-		bulkExec(coll, writes)
-	}
-	log.Printf("[mongodb] Loaded groups: %d rows", count)
-}
+	log.Printf("[mongodb] == Starting Mongo data import from CSV in %q ==", utils.DataDir())
+	utils.MarkDatasetLoading("mongodb", datasetStore{db})
 
-// group_memberships.csv -> add to direct_member_user_ids or direct_manager_user_ids
-func upsertGroupMemberships(db *mongo.Database, start time.Time) {
-	r, f := openCSV("group_memberships.csv")
-	defer f.Close()
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[mongodb] read group_memberships header: %v", err)
-	}
-	coll := db.Collection("groups")
-	writes := make([]mongo.WriteModel, 0, batchSize)
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[mongodb] read group_memberships row: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[mongodb] invalid group_memberships row: %#v", rec)
-		}
-		groupID := rec[0]
-		userID := rec[1]
-		role := rec[2]
+	l := newBulkLoader(client, db)
 
-		var field string
-		switch role {
-		case "direct_manager", "admin":
-			field = "direct_manager_user_ids"
-		default:
-			field = "direct_member_user_ids"
+	// organizations: accumulate admin/member arrays per org
+	bulkUpsert(l, "org_memberships", "organizations", dataset.OrgMemberships(), func(m dataset.OrgMembership) mongo.WriteModel {
+		field := "member_user_ids"
+		if m.Role == "admin" {
+			field = "admin_user_ids"
 		}
+		return addToSet("org_id", m.Org.Raw, bson.D{{Key: field, Value: m.User.Raw}})
+	})
 
-		writes = append(writes, &mongo.UpdateOneModel{
-			Filter: bson.D{{Key: "group_id", Value: groupID}},
-			Update: bson.D{
-				{Key: "$setOnInsert", Value: bson.D{{Key: "group_id", Value: groupID}}},
-				{Key: "$addToSet", Value: bson.D{{Key: field, Value: userID}}},
-			},
+	// groups.csv -> create group doc with org_id
+	bulkUpsert(l, "groups", "groups", dataset.Groups(), func(g dataset.Group) mongo.WriteModel {
+		return &mongo.UpdateOneModel{
+			Filter: bson.D{{Key: "group_id", Value: g.ID.Raw}},
+			Update: bson.D{{Key: "$set", Value: bson.D{{Key: "group_id", Value: g.ID.Raw}, {Key: "org_id", Value: g.Org.Raw}}}},
 			Upsert: boolPtr(true),
-		})
-		count++
-		if len(writes) >= batchSize {
-			bulkExec(coll, writes)
-			writes = writes[:0]
-		}
-		if count%10000 == 0 {
-			log.Printf("[mongodb] Loaded group_memberships progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
 		}
-	}
-	if len(writes) > 0 {
-		bulkExec(coll, writes)
-	}
-	log.Printf("[mongodb] Loaded group_memberships: %d rows", count)
-}
+	})
 
-// group_hierarchy.csv -> member_group_ids or manager_group_ids
-func upsertGroupHierarchy(db *mongo.Database, start time.Time) {
-	r, f := openCSV("group_hierarchy.csv")
-	defer f.Close()
-	if _, err := r.Read(); err != nil {
-		// File may not exist; mirror authzed logging
-		if os.IsNotExist(err) {
-			log.Printf("[mongodb] group_hierarchy.csv not found, skipping nested groups")
-			return
-		}
-		log.Fatalf("[mongodb] read group_hierarchy header: %v", err)
-	}
-	coll := db.Collection("groups")
-	writes := make([]mongo.WriteModel, 0, batchSize)
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[mongodb] read group_hierarchy row: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[mongodb] invalid group_hierarchy row: %#v", rec)
-		}
-		parent := rec[0]
-		child := rec[1]
-		relation := rec[2]
+	// group_memberships.csv -> add to direct_member_user_ids or direct_manager_user_ids
+	bulkUpsert(l, "group_memberships", "groups", dataset.GroupMemberships(), func(m dataset.GroupMembership) mongo.WriteModel {
+		return addToSet("group_id", m.Group.Raw, bson.D{{Key: m.Role + "_user_ids", Value: m.User.Raw}})
+	})
 
-		field := "member_group_ids"
-		if relation == "manager_group" {
-			field = "manager_group_ids"
-		}
+	// group_hierarchy.csv -> member_group_ids or manager_group_ids
+	bulkUpsert(l, "group_hierarchy", "groups", dataset.GroupHierarchy(), func(e dataset.GroupEdge) mongo.WriteModel {
+		return addToSet("group_id", e.Parent.Raw, bson.D{{Key: e.Relation + "_ids", Value: e.Child.Raw}})
+	})
 
-		writes = append(writes, &mongo.UpdateOneModel{
-			Filter: bson.D{{Key: "group_id", Value: parent}},
-			Update: bson.D{
-				{Key: "$setOnInsert", Value: bson.D{{Key: "group_id", Value: parent}}},
-				{Key: "$addToSet", Value: bson.D{{Key: field, Value: child}}},
-			},
-			Upsert: boolPtr(true),
-		})
-		count++
-		if len(writes) >= batchSize {
-			bulkExec(coll, writes)
-			writes = writes[:0]
-		}
-		if count%10000 == 0 {
-			log.Printf("[mongodb] Loaded group_hierarchy progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-	if len(writes) > 0 {
-		bulkExec(coll, writes)
-	}
-	log.Printf("[mongodb] Loaded group_hierarchy: %d rows", count)
-}
+	// group_members_expanded: effective members of every group, nested groups included
+	loadGroupMembersExpanded(l)
 
-// resources.csv -> create resource doc with org_id
-func upsertResources(db *mongo.Database, start time.Time) {
-	r, f := openCSV("resources.csv")
-	defer f.Close()
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[mongodb] read resources header: %v", err)
-	}
-	coll := db.Collection("resources")
-	writes := make([]mongo.WriteModel, 0, batchSize)
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[mongodb] read resources row: %v", err)
+	// resources.csv -> create resource doc with org_id (and its timestamps, when the CSV has them)
+	bulkUpsert(l, "resources", "resources", dataset.Resources(), func(r dataset.Resource) mongo.WriteModel {
+		set := bson.D{{Key: "resource_id", Value: r.ID.Raw}, {Key: "org_id", Value: r.Org.Raw}}
+		if r.CreatedAt != nil {
+			set = append(set, bson.E{Key: "created_at", Value: *r.CreatedAt})
 		}
-		if len(rec) < 2 {
-			log.Fatalf("[mongodb] invalid resources row: %#v", rec)
+		if r.UpdatedAt != nil {
+			set = append(set, bson.E{Key: "updated_at", Value: *r.UpdatedAt})
 		}
-		resID := rec[0]
-		orgID := rec[1]
-		writes = append(writes, &mongo.UpdateOneModel{
-			Filter: bson.D{{Key: "resource_id", Value: resID}},
-			Update: bson.D{{Key: "$set", Value: bson.D{{Key: "resource_id", Value: resID}, {Key: "org_id", Value: orgID}}}},
+		return &mongo.UpdateOneModel{
+			Filter: bson.D{{Key: "resource_id", Value: r.ID.Raw}},
+			Update: bson.D{{Key: "$set", Value: set}},
 			Upsert: boolPtr(true),
-		})
-		count++
-		if len(writes) >= batchSize {
-			bulkExec(coll, writes)
-			writes = writes[:0]
-		}
-		if count%10000 == 0 {
-			log.Printf("[mongodb] Loaded resources progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-	if len(writes) > 0 {
-		bulkExec(coll, writes)
-	}
-	log.Printf("[mongodb] Loaded resources: %d rows", count)
-}
-
-// resource_acl.csv -> add IDs to manager/viewer arrays (user/group specific)
-func upsertResourceACL(db *mongo.Database, start time.Time) {
-	r, f := openCSV("resource_acl.csv")
-	defer f.Close()
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[mongodb] read resource_acl header: %v", err)
-	}
-	coll := db.Collection("resources")
-	writes := make([]mongo.WriteModel, 0, batchSize)
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[mongodb] read resource_acl row: %v", err)
-		}
-		if len(rec) < 4 {
-			log.Fatalf("[mongodb] invalid resource_acl row: %#v", rec)
-		}
-		resID := rec[0]
-		subjectType := rec[1]
-		subjectID := rec[2]
-		relation := rec[3]
-
-		var field string
-		switch subjectType {
-		case "user":
-			switch relation {
-			case "manager_user", "manager":
-				field = "manager_user_ids"
-			case "viewer_user", "viewer":
-				field = "viewer_user_ids"
-			default:
-				log.Fatalf("[mongodb] unknown ACL relation for user: %q", relation)
-			}
-		case "group":
-			switch relation {
-			case "manager_group", "manager":
-				field = "manager_group_ids"
-			case "viewer_group", "viewer":
-				field = "viewer_group_ids"
-			default:
-				log.Fatalf("[mongodb] unknown ACL relation for group: %q", relation)
-			}
-		default:
-			log.Fatalf("[mongodb] unknown subject_type in resource_acl: %q", subjectType)
 		}
+	})
+
+	// resource_acl.csv -> add IDs to manager/viewer arrays (user/group specific).
+	// Only grants active now enter the arrays, so checks and lookups deny
+	// expired grants as SpiceDB's valid_window caveat does; a grant that
+	// expires later stays until the next load.
+	now := time.Now()
+	bulkUpsert(l, "resource_acl", "resources", dataset.ResourceACL(), func(a dataset.ACL) mongo.WriteModel {
+		var set bson.D
+		if a.Window.ActiveAt(now) {
+			set = append(set, bson.E{Key: a.Relation + "_ids", Value: a.Subject.Raw})
+		}
+		if a.Window.Bounded() {
+			// The window is kept alongside for the checks that evaluate it at
+			// query time (check_time_bounded_direct_user).
+			set = append(set, bson.E{Key: "user_grant_windows", Value: bson.D{
+				{Key: "user_id", Value: a.Subject.Raw},
+				{Key: "relation", Value: a.Relation},
+				{Key: "valid_from", Value: a.Window.From},
+				{Key: "valid_until", Value: a.Window.Until},
+			}})
+		}
+		if a.CreatedAt != nil {
+			// When the grant was made, for time-window filters over grants.
+			set = append(set, bson.E{Key: "grants_created", Value: bson.D{
+				{Key: "subject_id", Value: a.Subject.Raw},
+				{Key: "relation", Value: a.Relation},
+				{Key: "created_at", Value: *a.CreatedAt},
+			}})
+		}
+		return addToSet("resource_id", a.Resource.Raw, set)
+	})
+	l.summary()
+	utils.RecordDataset("mongodb", datasetStore{db})
 
-		writes = append(writes, &mongo.UpdateOneModel{
-			Filter: bson.D{{Key: "resource_id", Value: resID}},
-			Update: bson.D{
-				{Key: "$setOnInsert", Value: bson.D{{Key: "resource_id", Value: resID}}},
-				{Key: "$addToSet", Value: bson.D{{Key: field, Value: subjectID}}},
-			},
-			Upsert: boolPtr(true),
-		})
-		count++
-		if len(writes) >= batchSize {
-			bulkExec(coll, writes)
-			writes = writes[:0]
-		}
-		if count%10000 == 0 {
-			log.Printf("[mongodb] Loaded resource_acl progress: %d rows elapsed=%s", count, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-	if len(writes) > 0 {
-		bulkExec(coll, writes)
-	}
-	log.Printf("[mongodb] Loaded resource_acl: %d rows", count)
+	elapsed := time.Since(start).Truncate(time.Millisecond)
+	log.Printf("[mongodb] Mongo data import DONE: elapsed=%s", elapsed)
 }
 
-func bulkExec(coll *mongo.Collection, writes []mongo.WriteModel) {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-	opts := options.BulkWrite().SetOrdered(false)
-	if _, err := coll.BulkWrite(ctx, writes, opts); err != nil {
-		log.Printf("[mongodb] BulkWrite error (may be duplicates): %v", err)
+// addToSet upserts the document whose key is id, adding the values in set to
+// their array fields.
+func addToSet(key, id string, set bson.D) mongo.WriteModel {
+	return &mongo.UpdateOneModel{
+		Filter: bson.D{{Key: key, Value: id}},
+		Update: bson.D{
+			{Key: "$setOnInsert", Value: bson.D{{Key: key, Value: id}}},
+			{Key: "$addToSet", Value: set},
+		},
+		Upsert: boolPtr(true),
 	}
 }
 
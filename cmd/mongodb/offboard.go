@@ -0,0 +1,77 @@
+package mongodb
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	mongoauthz "test-tls/authz/mongodb"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// userRelations are the resource_acl relations of user subjects; their
+// entries in user_grant_windows and grants_created name the user.
+var userRelations = bson.A{"manager_user", "viewer_user"}
+
+// userOffboarder pulls a user out of every array naming it and deletes its
+// group_members_expanded and users documents (utils.UserOffboarder). Each
+// update is one indexed UpdateMany; the documents are not updated in one
+// transaction.
+type userOffboarder struct {
+	db *mongo.Database
+}
+
+func (o userOffboarder) Offboard(ctx context.Context, userID string) (int, error) {
+	pulls := []struct {
+		coll   string
+		fields []string
+		extra  bson.D // further $pull conditions
+	}{
+		{"organizations", []string{"admin_user_ids", "member_user_ids"}, nil},
+		{"groups", []string{"direct_member_user_ids", "direct_manager_user_ids"}, nil},
+		{"resources", []string{"manager_user_ids", "viewer_user_ids"}, bson.D{
+			{Key: "user_grant_windows", Value: bson.D{{Key: "user_id", Value: userID}, {Key: "relation", Value: bson.D{{Key: "$in", Value: userRelations}}}}},
+			{Key: "grants_created", Value: bson.D{{Key: "subject_id", Value: userID}, {Key: "relation", Value: bson.D{{Key: "$in", Value: userRelations}}}}},
+		}},
+	}
+	rows := 0
+	for _, p := range pulls {
+		var or bson.A
+		pull := bson.D{}
+		for _, f := range p.fields {
+			or = append(or, bson.D{{Key: f, Value: userID}})
+			pull = append(pull, bson.E{Key: f, Value: userID})
+		}
+		pull = append(pull, p.extra...)
+		res, err := o.db.Collection(p.coll).UpdateMany(ctx, bson.D{{Key: "$or", Value: or}}, bson.D{{Key: "$pull", Value: pull}})
+		if err != nil {
+			return rows, err
+		}
+		rows += int(res.ModifiedCount)
+	}
+	for _, coll := range []string{mongoauthz.ExpandedCollection, "users"} {
+		res, err := o.db.Collection(coll).DeleteMany(ctx, bson.D{{Key: "user_id", Value: userID}})
+		if err != nil {
+			return rows, err
+		}
+		rows += int(res.DeletedCount)
+	}
+	return rows, nil
+}
+
+// MongodbOffboard benchmarks deleting the users of BENCH_OFFBOARD_USERS and
+// checks they hold nothing afterwards; see utils.RunOffboarding. rows counts
+// the documents updated or deleted.
+func MongodbOffboard() {
+	_, db, cleanup, err := infrastructure.NewMongoFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[mongodb] offboard: connect failed: %v", err)
+	}
+	defer cleanup()
+
+	utils.RunOffboarding("mongodb", userOffboarder{db: db}, newChecker(db))
+	log.Println("[mongodb] == MongoDB user offboarding DONE ==")
+}
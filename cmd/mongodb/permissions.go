@@ -0,0 +1,115 @@
+package mongodb
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"test-tls/authz"
+	mongoauthz "test-tls/authz/mongodb"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// lookupResources streams the resources matching permission for userID, as
+// timed by the lookup_resources_* scenarios, with groups resolved per
+// MONGO_GROUP_MODE.
+func lookupResources(ctx context.Context, db *mongo.Database, userID, permission string, handle func(resID string)) error {
+	return mongoauthz.LookupResources(ctx, db, groupModeFromEnv(), userID, permission, handle)
+}
+
+// countResources is lookupResources counted server-side (see
+// mongoauthz.CountResources).
+func countResources(ctx context.Context, db *mongo.Database, userID, permission string) (int, error) {
+	return mongoauthz.CountResources(ctx, db, groupModeFromEnv(), userID, permission)
+}
+
+// newChecker is mongoauthz.NewChecker with the MONGO_GROUP_MODE of the
+// lookup_resources_* scenarios.
+func newChecker(db *mongo.Database) authz.Checker {
+	checker, err := mongoauthz.NewChecker(db, mongoauthz.Config{GroupMode: groupModeFromEnv()})
+	if err != nil {
+		log.Fatalf("[mongodb] %v", err)
+	}
+	return checker
+}
+
+// MongodbServe serves Check/Lookup over HTTP using the benchmark queries, with
+// the same read consistency settings as the benchmark.
+func MongodbServe() {
+	client, db, cleanup, err := infrastructure.NewMongoFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[mongodb] failed to create mongo client: %v", err)
+	}
+	defer cleanup()
+
+	consistency := readConsistencyFromEnv()
+	utils.LogConsistency("mongodb", consistency.String())
+
+	utils.Serve("mongodb", newChecker(consistency.database(client, db.Name())))
+}
+
+// MongodbReplayAudit re-runs a recorded audit file (REPLAY_AUDIT_FILE) through
+// the benchmark queries, with the same read consistency settings as the
+// benchmark.
+func MongodbReplayAudit() {
+	client, db, cleanup, err := infrastructure.NewMongoFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[mongodb] failed to create mongo client: %v", err)
+	}
+	defer cleanup()
+
+	consistency := readConsistencyFromEnv()
+	utils.LogConsistency("mongodb", consistency.String())
+
+	utils.ReplayAudit("mongodb", newChecker(consistency.database(client, db.Name())))
+}
+
+// MongodbVerifyFixture checks the benchmark queries against the known
+// answers of the fixture dataset (see utils.VerifyFixture), with the same read consistency settings as the
+// benchmark.
+func MongodbVerifyFixture() {
+	client, db, cleanup, err := infrastructure.NewMongoFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[mongodb] failed to create mongo client: %v", err)
+	}
+	defer cleanup()
+
+	consistency := readConsistencyFromEnv()
+	utils.LogConsistency("mongodb", consistency.String())
+
+	utils.VerifyFixture("mongodb", newChecker(consistency.database(client, db.Name())))
+}
+
+// MongodbExportPermissions writes a permission snapshot of the benchmark
+// queries for diff-permissions (see utils.ExportPermissions), with the same read
+// consistency settings as the benchmark.
+func MongodbExportPermissions() {
+	client, db, cleanup, err := infrastructure.NewMongoFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[mongodb] failed to create mongo client: %v", err)
+	}
+	defer cleanup()
+
+	consistency := readConsistencyFromEnv()
+	utils.LogConsistency("mongodb", consistency.String())
+
+	utils.ExportPermissions("mongodb", newChecker(consistency.database(client, db.Name())))
+}
+
+// MongodbWorker replays the shard a distributed coordinator hands out and
+// streams the results back (see utils.RunWorker), with the same read
+// consistency settings as the benchmark.
+func MongodbWorker() {
+	client, db, cleanup, err := infrastructure.NewMongoFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[mongodb] failed to create mongo client: %v", err)
+	}
+	defer cleanup()
+
+	consistency := readConsistencyFromEnv()
+	utils.LogConsistency("mongodb", consistency.String())
+
+	utils.RunWorker("mongodb", newChecker(consistency.database(client, db.Name())))
+}
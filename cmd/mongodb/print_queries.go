@@ -0,0 +1,64 @@
+package mongodb
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"test-tls/authz"
+	mongoauthz "test-tls/authz/mongodb"
+	"test-tls/utils"
+)
+
+// MongodbPrintQueries prints the mongosh commands of the read scenarios with
+// sampled parameters instead of running them (see utils.PrintQueries). The
+// lookups resolve the user's groups (MONGO_GROUP_MODE) and orgs first; their
+// results stand in the printed resources filter as placeholders.
+func MongodbPrintQueries() {
+	groupMode := groupModeFromEnv()
+	lookupMode := utils.GetEnvWithDefault("MONGO_LOOKUP_MODE", "stream")
+	utils.PrintQueries("mongodb", func(s utils.QuerySample) string {
+		switch s.Scenario {
+		case "check_manage_direct_user":
+			return "db.resources.findOne(" + extJSON(bson.D{{Key: "resource_id", Value: s.Resource}, {Key: "manager_user_ids", Value: s.User}}) + ")"
+		case "check_manage_org_admin":
+			return "db.resources.findOne(" + extJSON(bson.D{{Key: "resource_id", Value: s.Resource}, {Key: "org_id", Value: s.Org}}) + ")"
+		case "check_view_via_group_member":
+			member := bson.D{{Key: "group_id", Value: s.Group}, {Key: "$or", Value: bson.A{
+				bson.D{{Key: "direct_member_user_ids", Value: s.User}},
+				bson.D{{Key: "direct_manager_user_ids", Value: s.User}},
+			}}}
+			return "db.resources.findOne(" + extJSON(bson.D{{Key: "resource_id", Value: s.Resource}, {Key: "viewer_group_ids", Value: s.Group}}) + ")\n" +
+				"db.groups.findOne(" + extJSON(member) + ")"
+		case "check_time_bounded_direct_user":
+			return "db.resources.findOne(" + extJSON(timeBoundedFilter(s.Resource, s.User, s.Relation, time.Now())) + `, {"_id": 1})`
+		}
+
+		var b strings.Builder
+		b.WriteString("// groups the user manages")
+		if s.Permission != authz.Manage {
+			b.WriteString(" and is a member of")
+		}
+		b.WriteString(", resolved first with MONGO_GROUP_MODE=" + groupMode + "\n")
+		b.WriteString(`db.organizations.distinct("org_id", ` + extJSON(mongoauthz.OrgRolesFilter(s.User, s.Permission)) + ")\n")
+		filter := extJSON(mongoauthz.ResolvedFilter(s.User, s.Permission,
+			bson.A{"<manager groups>"}, bson.A{"<member groups>"}, bson.A{"<orgs>"}))
+		if lookupMode == "count" {
+			b.WriteString(`db.resources.aggregate([{"$match": ` + filter + `}, {"$count": "resources"}])`)
+		} else {
+			b.WriteString("db.resources.find(" + filter + `, {"resource_id": 1})`)
+		}
+		return b.String()
+	})
+}
+
+// extJSON renders v as relaxed extended JSON, as mongosh accepts it.
+func extJSON(v any) string {
+	b, err := bson.MarshalExtJSON(v, false, false)
+	if err != nil {
+		log.Fatalf("[mongodb] print-queries: %v", err)
+	}
+	return string(b)
+}
@@ -0,0 +1,78 @@
+package mongodb
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"test-tls/utils"
+)
+
+// readConsistency selects how strongly the benchmark reads are served, so Mongo
+// can be compared at both strong and relaxed consistency like the other engines.
+//
+// Env vars:
+//
+//	MONGO_READ_PREFERENCE   (default: "primary") primary|primaryPreferred|secondary|secondaryPreferred|nearest
+//	MONGO_READ_CONCERN      (default: server default) local|available|majority|linearizable|snapshot
+//	MONGO_MAX_STALENESS_SEC (default: 0 = unset) must be >= 90 and needs a non-primary read preference
+type readConsistency struct {
+	readPref     *readpref.ReadPref
+	readConcern  *readconcern.ReadConcern
+	maxStaleness time.Duration
+}
+
+func readConsistencyFromEnv() readConsistency {
+	mode, err := readpref.ModeFromString(utils.GetEnvWithDefault("MONGO_READ_PREFERENCE", "primary"))
+	if err != nil {
+		log.Fatalf("[mongodb] invalid MONGO_READ_PREFERENCE: %v", err)
+	}
+
+	var c readConsistency
+	var opts []readpref.Option
+	if sec := utils.GetEnvInt("MONGO_MAX_STALENESS_SEC", 0); sec > 0 {
+		c.maxStaleness = time.Duration(sec) * time.Second
+		opts = append(opts, readpref.WithMaxStaleness(c.maxStaleness))
+	}
+	if c.readPref, err = readpref.New(mode, opts...); err != nil {
+		log.Fatalf("[mongodb] invalid read preference %s (maxStaleness=%s): %v", mode, c.maxStaleness, err)
+	}
+
+	switch level := strings.ToLower(utils.GetEnvWithDefault("MONGO_READ_CONCERN", "")); level {
+	case "":
+	case "local", "available", "majority", "linearizable", "snapshot":
+		c.readConcern = &readconcern.ReadConcern{Level: level}
+	default:
+		log.Fatalf("[mongodb] invalid MONGO_READ_CONCERN %q", level)
+	}
+	return c
+}
+
+// database returns a handle on db that carries the configured read options, so
+// every collection derived from it inherits them.
+func (c readConsistency) database(client *mongo.Client, name string) *mongo.Database {
+	opts := options.Database().SetReadPreference(c.readPref)
+	if c.readConcern != nil {
+		opts.SetReadConcern(c.readConcern)
+	}
+	return client.Database(name, opts)
+}
+
+// String renders the settings as the key=value list logged in the benchmark
+// CONSISTENCY line.
+func (c readConsistency) String() string {
+	rc := "default"
+	if c.readConcern != nil {
+		rc = c.readConcern.Level
+	}
+	staleness := "unset"
+	if c.maxStaleness > 0 {
+		staleness = c.maxStaleness.String()
+	}
+	return "readPreference=" + c.readPref.Mode().String() + " readConcern=" + rc + " maxStaleness=" + staleness
+}
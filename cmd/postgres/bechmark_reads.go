@@ -3,10 +3,12 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log"
-	"os"
 	"time"
 
+	pgauthz "test-tls/authz/postgres"
+	"test-tls/ids"
 	"test-tls/infrastructure"
 	"test-tls/utils"
 )
@@ -14,30 +16,79 @@ import (
 // PostgresBenchmarkReads runs read benchmarks against the Postgres dataset.
 // It mirrors the behavior and logging of the Authzed streaming-only benchmarks
 // and performs all work in a streaming fashion (no in-memory collection).
+// With BENCH_PG_READ_TARGET=replica every read goes to the standby instead
+// (see openReadTarget).
 func PostgresBenchmarkReads() {
 	ctx := context.Background()
-	db, cleanup, err := infrastructure.NewPostgresFromEnv(ctx)
+	primary, cleanup, err := infrastructure.NewPostgresFromEnv(ctx)
 	if err != nil {
 		log.Fatalf("[postgres] failed to create postgres client: %v", err)
 	}
 	defer cleanup()
+	utils.GuardDataset("postgres", datasetStore{primary})
+	db, closeReads := openReadTarget(ctx, primary)
+	defer closeReads()
+	stopAudit := utils.StartAudit("postgres")
+	defer stopAudit()
 
 	start := time.Now()
-	heavyManageUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
-	regularViewUser := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	heavyManageUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
+	regularViewUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 	log.Printf("[postgres] Running in streaming-only mode (no precollection). elapsed=%s heavyManageUser=%q regularViewUser=%q",
 		elapsed, heavyManageUser, regularViewUser)
-
-	runCheckManageDirectUser(db)
-	runCheckManageOrgAdmin(db)
-	runCheckViewViaGroupMember(db)
-	runLookupResourcesManageHeavyUser(db)
-	runLookupResourcesViewRegularUser(db)
+	log.Printf("[postgres] SCHEMA: resource_acl=%s groups=%s", aclLayout(ctx, db), groupResolutionFromEnv())
+
+	snapshotStats := startStatementStats(db)
+	stopCost := utils.StartCost("postgres", costMeter(db))
+	reads := func() {
+		runCheckManageDirectUser(db)
+		runCheckManageOrgAdmin(db)
+		runCheckViewViaGroupMember(db)
+		runCheckTimeBoundedDirectUser(db)
+		runCheckBulkManageDirectUser(db)
+		runLookupResourcesManageHeavyUser(db)
+		runLookupResourcesViewRegularUser(db)
+		runLookupResourcesMix(db)
+		runLookupResourcesPercentiles(db)
+		runViralFanIn(db)
+		runWorstCaseChecks(db)
+		runListRecentViewable(db)
+		runCustomScenarios(db)
+		runWorkload(db)
+		runMyGroups(db)
+		runAccessSummary(db)
+		runMiddlewareOverhead(db)
+		runDerivedPermissions(db)
+		runDenials(db)
+	}
+	utils.RunMaintenancePhases("postgres", "vacuum_analyze", vacuumTables(primary), func() {
+		utils.RunCachePhases("postgres", nil, reads)
+	})
+	runFullExport(db)
+	stopCost()
+	snapshotStats()
+	runDriverOverhead(db)
+	runCheckBatching(db)
 
 	log.Println("[postgres] == Postgres read benchmarks DONE ==")
 }
 
+// vacuumTables vacuums and analyzes the ACL tables and the permission view
+// on the primary, the maintenance of BENCH_MAINTENANCE. DISABLE_PAGE_SKIPPING
+// makes every round scan the whole of each table, as an anti-wraparound
+// vacuum does, instead of only the pages changed since the last one.
+func vacuumTables(db *sql.DB) utils.Maintenance {
+	return func(ctx context.Context) error {
+		for _, table := range []string{"resource_acl", "group_memberships", "user_resource_permissions"} {
+			if _, err := db.ExecContext(ctx, "VACUUM (ANALYZE, DISABLE_PAGE_SKIPPING) "+table); err != nil {
+				return fmt.Errorf("vacuum %s: %w", table, err)
+			}
+		}
+		return nil
+	}
+}
+
 // runLookupBenchPG enumerates resources from the materialized view for a user
 // and counts them. It streams rows from the DB and does not retain results.
 func runLookupBenchPG(db *sql.DB, name, permission, userID string, iters int, timeout time.Duration) {
@@ -47,43 +98,45 @@ func runLookupBenchPG(db *sql.DB, name, permission, userID string, iters int, ti
 	}
 
 	log.Printf("[postgres] [%s] iterations=%d user=%s", name, iters, userID)
+	utils.LogScenarioConfig("postgres", name, iters, timeout, userID)
 	var total time.Duration
-	var lastCount int
+	var lastCount, ok int
+	errs := utils.NewErrorTally()
 
 	for i := range iters {
 		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		start := time.Now()
 
-		rows, err := db.QueryContext(ctx, `SELECT resource_id FROM user_resource_permissions WHERE user_id = $1 AND relation = $2`, userID, permission)
+		count, err := lookupCountPG(ctx, db, userID, permission)
+		cancel()
+		utils.AuditLookup(name, userID, permission, count, time.Since(start), err)
 		if err != nil {
-			cancel()
-			log.Fatalf("[postgres] [%s] LookupResources query failed: %v", name, err)
-		}
-
-		count := 0
-		for rows.Next() {
-			var resID int
-			if err := rows.Scan(&resID); err != nil {
-				rows.Close()
-				cancel()
-				log.Fatalf("[postgres] [%s] rows.Scan failed: %v", name, err)
-			}
-			count++
+			class := errs.Record(err)
+			log.Printf("[postgres] [%s] iter=%d LookupResources failed class=%s: %v", name, i, class, err)
+			continue
 		}
-		rows.Close()
-		cancel()
 
 		dur := time.Since(start)
 		total += dur
 		lastCount = count
+		ok++
 		log.Printf("[postgres] [%s] iter=%d resources=%d duration=%s", name, i, count, dur.Truncate(time.Millisecond))
 	}
 
 	avg := time.Duration(0)
-	if iters > 0 {
-		avg = time.Duration(int64(total) / int64(iters))
+	if ok > 0 {
+		avg = time.Duration(int64(total) / int64(ok))
 	}
 	log.Printf("[postgres] [%s] DONE: iters=%d lastCount=%d avg=%s total=%s", name, iters, lastCount, avg, total)
+	log.Printf("[postgres] [%s] ERRORS: %s", name, errs.Summary(iters))
+}
+
+// lookupCountPG streams the resources a user holds a relation on (see
+// lookupResourcesPG) and returns how many were read.
+func lookupCountPG(ctx context.Context, db *sql.DB, userID, permission string) (int, error) {
+	count := 0
+	err := lookupResourcesPG(ctx, db, userID, permission, func(int) { count++ })
+	return count, err
 }
 
 // runCheckManageDirectUser streams direct user->resource ACL rows and runs
@@ -91,10 +144,12 @@ func runLookupBenchPG(db *sql.DB, name, permission, userID string, iters int, ti
 func runCheckManageDirectUser(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_CHECK_DIRECT_SUPER_ITER", 1000)
 	log.Printf("[postgres] [check_manage_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("postgres", "check_manage_direct_user", iters, 2*time.Second)
 
-	lookupUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 	done := 0
+	errs := utils.NewErrorTally()
 
 	for done < iters {
 		if lookupUser != "" {
@@ -103,7 +158,8 @@ func runCheckManageDirectUser(db *sql.DB) {
 			rows, err := db.QueryContext(ctx, `SELECT resource_id FROM user_resource_permissions WHERE user_id = $1 AND relation = 'manager'`, lookupUser)
 			if err != nil {
 				cancel()
-				log.Fatalf("[postgres] [check_manage_direct_user] lookup query failed: %v", err)
+				errs.Survive(err, iters, "[postgres] [check_manage_direct_user] lookup query failed: %v", err)
+				continue
 			}
 
 			streamed := 0
@@ -115,23 +171,29 @@ func runCheckManageDirectUser(db *sql.DB) {
 				if err := rows.Scan(&resID); err != nil {
 					rows.Close()
 					cancel()
-					log.Fatalf("[postgres] [check_manage_direct_user] scan failed: %v", err)
+					errs.Survive(err, iters, "[postgres] [check_manage_direct_user] scan failed: %v", err)
+					break
 				}
 				streamed++
 
+				if !utils.InOrgScope(ids.Resource, resID) {
+					continue
+				}
+
 				// Existence check (emulates CheckPermission)
 				cstart := time.Now()
-				var exists bool
 				qctx, qcancel := context.WithTimeout(context.Background(), 2*time.Second)
-				err = db.QueryRowContext(qctx, `SELECT EXISTS(SELECT 1 FROM user_resource_permissions WHERE resource_id = $1 AND user_id = $2 AND relation = 'manager')`, resID, lookupUser).Scan(&exists)
+				granted, err := checkPermissionPG(qctx, db, resID, lookupUser, "manager")
 				qcancel()
+				utils.AuditCheck("check_manage_direct_user", resID, lookupUser, "manager", granted, time.Since(cstart), err)
 				if err != nil {
-					rows.Close()
-					cancel()
-					log.Fatalf("[postgres] [check_manage_direct_user] check query failed: %v", err)
+					class := errs.Record(err)
+					log.Printf("[postgres] [check_manage_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+					done++
+					continue
 				}
 				dur := time.Since(cstart)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[postgres] [check_manage_direct_user] lookup iter=%d resource=%d user=%s dur=%s", done, resID, lookupUser, dur)
 				}
 				done++
@@ -148,7 +210,8 @@ func runCheckManageDirectUser(db *sql.DB) {
 		// Relationship-driven: stream resource_acl rows for user subjects with manager relation
 		rows, err := db.QueryContext(context.Background(), `SELECT resource_id, subject_id FROM resource_acl WHERE subject_type = 'user' AND (relation = 'manager_user' OR relation = 'manager')`)
 		if err != nil {
-			log.Fatalf("[postgres] [check_manage_direct_user] resource_acl query failed: %v", err)
+			errs.Survive(err, iters, "[postgres] [check_manage_direct_user] resource_acl query failed: %v", err)
+			continue
 		}
 		for rows.Next() {
 			if done >= iters {
@@ -157,20 +220,27 @@ func runCheckManageDirectUser(db *sql.DB) {
 			var resID, userID int
 			if err := rows.Scan(&resID, &userID); err != nil {
 				rows.Close()
-				log.Fatalf("[postgres] [check_manage_direct_user] scan failed: %v", err)
+				errs.Survive(err, iters, "[postgres] [check_manage_direct_user] scan failed: %v", err)
+				break
+			}
+
+			if !utils.InOrgScope(ids.Resource, resID) {
+				continue
 			}
 
 			cstart := time.Now()
-			var exists bool
 			qctx, qcancel := context.WithTimeout(context.Background(), 2*time.Second)
-			err = db.QueryRowContext(qctx, `SELECT EXISTS(SELECT 1 FROM user_resource_permissions WHERE resource_id = $1 AND user_id = $2 AND relation = 'manager')`, resID, userID).Scan(&exists)
+			granted, err := checkPermissionPG(qctx, db, resID, userID, "manager")
 			qcancel()
+			utils.AuditCheck("check_manage_direct_user", resID, userID, "manager", granted, time.Since(cstart), err)
 			if err != nil {
-				rows.Close()
-				log.Fatalf("[postgres] [check_manage_direct_user] check query failed: %v", err)
+				class := errs.Record(err)
+				log.Printf("[postgres] [check_manage_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+				done++
+				continue
 			}
 			dur := time.Since(cstart)
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[postgres] [check_manage_direct_user] iter=%d resource=%d user=%d dur=%s", done, resID, userID, dur)
 			}
 			done++
@@ -178,6 +248,7 @@ func runCheckManageDirectUser(db *sql.DB) {
 		rows.Close()
 	}
 	log.Printf("[postgres] [check_manage_direct_user] DONE: iters=%d", iters)
+	log.Printf("[postgres] [check_manage_direct_user] ERRORS: %s", errs.Summary(done))
 }
 
 // runCheckManageOrgAdmin streams resources and for each resource finds an org admin
@@ -185,9 +256,11 @@ func runCheckManageDirectUser(db *sql.DB) {
 func runCheckManageOrgAdmin(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_CHECK_ORGADMIN_ITER", 1000)
 	log.Printf("[postgres] [check_manage_org_admin] streaming mode. iterations=%d", iters)
-	lookupUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	utils.LogScenarioConfig("postgres", "check_manage_org_admin", iters, 2*time.Second)
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 	done := 0
+	errs := utils.NewErrorTally()
 
 	for done < iters {
 		if lookupUser != "" {
@@ -195,7 +268,8 @@ func runCheckManageOrgAdmin(db *sql.DB) {
 			rows, err := db.QueryContext(ctx, `SELECT resource_id FROM user_resource_permissions WHERE user_id = $1 AND relation = 'manager'`, lookupUser)
 			if err != nil {
 				cancel()
-				log.Fatalf("[postgres] [check_manage_org_admin] lookup query failed: %v", err)
+				errs.Survive(err, iters, "[postgres] [check_manage_org_admin] lookup query failed: %v", err)
+				continue
 			}
 			streamed := 0
 			for rows.Next() {
@@ -206,22 +280,28 @@ func runCheckManageOrgAdmin(db *sql.DB) {
 				if err := rows.Scan(&resID); err != nil {
 					rows.Close()
 					cancel()
-					log.Fatalf("[postgres] [check_manage_org_admin] scan failed: %v", err)
+					errs.Survive(err, iters, "[postgres] [check_manage_org_admin] scan failed: %v", err)
+					break
 				}
 				streamed++
 
+				if !utils.InOrgScope(ids.Resource, resID) {
+					continue
+				}
+
 				cstart := time.Now()
-				var exists bool
 				qctx, qcancel := context.WithTimeout(context.Background(), 2*time.Second)
-				err = db.QueryRowContext(qctx, `SELECT EXISTS(SELECT 1 FROM user_resource_permissions WHERE resource_id = $1 AND user_id = $2 AND relation = 'manager')`, resID, lookupUser).Scan(&exists)
+				granted, err := checkPermissionPG(qctx, db, resID, lookupUser, "manager")
 				qcancel()
+				utils.AuditCheck("check_manage_org_admin", resID, lookupUser, "manager", granted, time.Since(cstart), err)
 				if err != nil {
-					rows.Close()
-					cancel()
-					log.Fatalf("[postgres] [check_manage_org_admin] check query failed: %v", err)
+					class := errs.Record(err)
+					log.Printf("[postgres] [check_manage_org_admin] iter=%d check failed class=%s: %v", done, class, err)
+					done++
+					continue
 				}
 				dur := time.Since(cstart)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[postgres] [check_manage_org_admin] lookup iter=%d resource=%d user=%s dur=%s", done, resID, lookupUser, dur)
 				}
 				done++
@@ -238,7 +318,8 @@ func runCheckManageOrgAdmin(db *sql.DB) {
 		// Stream resources table and find an admin for each org
 		rows, err := db.QueryContext(context.Background(), `SELECT resource_id, org_id FROM resources`)
 		if err != nil {
-			log.Fatalf("[postgres] [check_manage_org_admin] resources query failed: %v", err)
+			errs.Survive(err, iters, "[postgres] [check_manage_org_admin] resources query failed: %v", err)
+			continue
 		}
 		for rows.Next() {
 			if done >= iters {
@@ -247,7 +328,8 @@ func runCheckManageOrgAdmin(db *sql.DB) {
 			var resID, orgID int
 			if err := rows.Scan(&resID, &orgID); err != nil {
 				rows.Close()
-				log.Fatalf("[postgres] [check_manage_org_admin] scan failed: %v", err)
+				errs.Survive(err, iters, "[postgres] [check_manage_org_admin] scan failed: %v", err)
+				break
 			}
 
 			// Find any admin for this org (on-demand)
@@ -258,21 +340,29 @@ func runCheckManageOrgAdmin(db *sql.DB) {
 				continue
 			}
 			if err != nil {
-				rows.Close()
-				log.Fatalf("[postgres] [check_manage_org_admin] find admin failed: %v", err)
+				class := errs.Record(err)
+				log.Printf("[postgres] [check_manage_org_admin] iter=%d find admin failed class=%s: %v", done, class, err)
+				done++
+				continue
+			}
+
+			if !utils.InOrgScope(ids.Resource, resID) {
+				continue
 			}
 
 			cstart := time.Now()
-			var exists bool
 			qctx, qcancel := context.WithTimeout(context.Background(), 2*time.Second)
-			err = db.QueryRowContext(qctx, `SELECT EXISTS(SELECT 1 FROM user_resource_permissions WHERE resource_id = $1 AND user_id = $2 AND relation = 'manager')`, resID, adminUser).Scan(&exists)
+			granted, err := checkPermissionPG(qctx, db, resID, adminUser, "manager")
 			qcancel()
+			utils.AuditCheck("check_manage_org_admin", resID, adminUser, "manager", granted, time.Since(cstart), err)
 			if err != nil {
-				rows.Close()
-				log.Fatalf("[postgres] [check_manage_org_admin] check query failed: %v", err)
+				class := errs.Record(err)
+				log.Printf("[postgres] [check_manage_org_admin] iter=%d check failed class=%s: %v", done, class, err)
+				done++
+				continue
 			}
 			dur := time.Since(cstart)
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[postgres] [check_manage_org_admin] iter=%d resource=%d org=%d admin=%d dur=%s", done, resID, orgID, adminUser, dur)
 			}
 			done++
@@ -280,6 +370,7 @@ func runCheckManageOrgAdmin(db *sql.DB) {
 		rows.Close()
 	}
 	log.Printf("[postgres] [check_manage_org_admin] DONE: iters=%d", iters)
+	log.Printf("[postgres] [check_manage_org_admin] ERRORS: %s", errs.Summary(done))
 }
 
 // runCheckViewViaGroupMember streams viewer_group ACLs and checks permission for a
@@ -287,9 +378,11 @@ func runCheckManageOrgAdmin(db *sql.DB) {
 func runCheckViewViaGroupMember(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_CHECK_VIEW_GROUP_ITER", 1000)
 	log.Printf("[postgres] [check_view_via_group_member] streaming mode. iterations=%d", iters)
-	lookupUser := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	utils.LogScenarioConfig("postgres", "check_view_via_group_member", iters, 2*time.Second)
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 	done := 0
+	errs := utils.NewErrorTally()
 
 	for done < iters {
 		if lookupUser != "" {
@@ -297,7 +390,8 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 			rows, err := db.QueryContext(ctx, `SELECT resource_id FROM user_resource_permissions WHERE user_id = $1 AND relation = 'viewer'`, lookupUser)
 			if err != nil {
 				cancel()
-				log.Fatalf("[postgres] [check_view_via_group_member] lookup query failed: %v", err)
+				errs.Survive(err, iters, "[postgres] [check_view_via_group_member] lookup query failed: %v", err)
+				continue
 			}
 			streamed := 0
 			for rows.Next() {
@@ -308,22 +402,28 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 				if err := rows.Scan(&resID); err != nil {
 					rows.Close()
 					cancel()
-					log.Fatalf("[postgres] [check_view_via_group_member] scan failed: %v", err)
+					errs.Survive(err, iters, "[postgres] [check_view_via_group_member] scan failed: %v", err)
+					break
 				}
 				streamed++
 
+				if !utils.InOrgScope(ids.Resource, resID) {
+					continue
+				}
+
 				cstart := time.Now()
-				var exists bool
 				qctx, qcancel := context.WithTimeout(context.Background(), 2*time.Second)
-				err = db.QueryRowContext(qctx, `SELECT EXISTS(SELECT 1 FROM user_resource_permissions WHERE resource_id = $1 AND user_id = $2 AND relation = 'viewer')`, resID, lookupUser).Scan(&exists)
+				granted, err := checkPermissionPG(qctx, db, resID, lookupUser, "viewer")
 				qcancel()
+				utils.AuditCheck("check_view_via_group_member", resID, lookupUser, "viewer", granted, time.Since(cstart), err)
 				if err != nil {
-					rows.Close()
-					cancel()
-					log.Fatalf("[postgres] [check_view_via_group_member] check query failed: %v", err)
+					class := errs.Record(err)
+					log.Printf("[postgres] [check_view_via_group_member] iter=%d check failed class=%s: %v", done, class, err)
+					done++
+					continue
 				}
 				dur := time.Since(cstart)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[postgres] [check_view_via_group_member] lookup iter=%d resource=%d user=%s dur=%s", done, resID, lookupUser, dur)
 				}
 				done++
@@ -340,7 +440,8 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 		// Relationship-driven: stream resource_acl for viewer groups
 		rows, err := db.QueryContext(context.Background(), `SELECT resource_id, subject_id FROM resource_acl WHERE subject_type = 'group' AND (relation = 'viewer_group' OR relation = 'viewer')`)
 		if err != nil {
-			log.Fatalf("[postgres] [check_view_via_group_member] resource_acl query failed: %v", err)
+			errs.Survive(err, iters, "[postgres] [check_view_via_group_member] resource_acl query failed: %v", err)
+			continue
 		}
 		for rows.Next() {
 			if done >= iters {
@@ -349,7 +450,8 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 			var resID, groupID int
 			if err := rows.Scan(&resID, &groupID); err != nil {
 				rows.Close()
-				log.Fatalf("[postgres] [check_view_via_group_member] scan failed: %v", err)
+				errs.Survive(err, iters, "[postgres] [check_view_via_group_member] scan failed: %v", err)
+				break
 			}
 
 			// Find a direct member
@@ -363,21 +465,29 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 				}
 			}
 			if err != nil {
-				rows.Close()
-				log.Fatalf("[postgres] [check_view_via_group_member] find member failed: %v", err)
+				class := errs.Record(err)
+				log.Printf("[postgres] [check_view_via_group_member] iter=%d find member failed class=%s: %v", done, class, err)
+				done++
+				continue
+			}
+
+			if !utils.InOrgScope(ids.Resource, resID) {
+				continue
 			}
 
 			cstart := time.Now()
-			var exists bool
 			qctx, qcancel := context.WithTimeout(context.Background(), 2*time.Second)
-			err = db.QueryRowContext(qctx, `SELECT EXISTS(SELECT 1 FROM user_resource_permissions WHERE resource_id = $1 AND user_id = $2 AND relation = 'viewer')`, resID, pickedUser).Scan(&exists)
+			granted, err := checkPermissionPG(qctx, db, resID, pickedUser, "viewer")
 			qcancel()
+			utils.AuditCheck("check_view_via_group_member", resID, pickedUser, "viewer", granted, time.Since(cstart), err)
 			if err != nil {
-				rows.Close()
-				log.Fatalf("[postgres] [check_view_via_group_member] check query failed: %v", err)
+				class := errs.Record(err)
+				log.Printf("[postgres] [check_view_via_group_member] iter=%d check failed class=%s: %v", done, class, err)
+				done++
+				continue
 			}
 			dur := time.Since(cstart)
-			if done%100 == 0 {
+			if utils.LogIteration(done, 100) {
 				log.Printf("[postgres] [check_view_via_group_member] iter=%d resource=%d group=%d user=%d dur=%s", done, resID, groupID, pickedUser, dur)
 			}
 			done++
@@ -385,16 +495,263 @@ func runCheckViewViaGroupMember(db *sql.DB) {
 		rows.Close()
 	}
 	log.Printf("[postgres] [check_view_via_group_member] DONE: iters=%d", iters)
+	log.Printf("[postgres] [check_view_via_group_member] ERRORS: %s", errs.Summary(done))
+}
+
+// runCheckTimeBoundedDirectUser streams direct user grants carrying a validity
+// window and checks each against resource_acl at now(), so the expired (soft
+// deleted) share of the sample is denied.
+func runCheckTimeBoundedDirectUser(db *sql.DB) {
+	iters := utils.GetEnvInt("BENCH_CHECK_TIME_BOUNDED_ITER", 1000)
+	log.Printf("[postgres] [check_time_bounded_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("postgres", "check_time_bounded_direct_user", iters, 2*time.Second)
+	done, allowed := 0, 0
+	errs := utils.NewErrorTally()
+
+	for done < iters {
+		rows, err := db.QueryContext(context.Background(), `SELECT resource_id, subject_id, relation FROM resource_acl WHERE subject_type = 'user' AND valid_until IS NOT NULL`)
+		if err != nil {
+			errs.Survive(err, iters, "[postgres] [check_time_bounded_direct_user] resource_acl query failed: %v", err)
+			continue
+		}
+		streamed := 0
+		for rows.Next() {
+			if done >= iters {
+				break
+			}
+			var resID, userID int
+			var relation string
+			if err := rows.Scan(&resID, &userID, &relation); err != nil {
+				rows.Close()
+				errs.Survive(err, iters, "[postgres] [check_time_bounded_direct_user] scan failed: %v", err)
+				break
+			}
+			streamed++
+
+			if !utils.InOrgScope(ids.Resource, resID) {
+				continue
+			}
+
+			cstart := time.Now()
+			qctx, qcancel := context.WithTimeout(context.Background(), 2*time.Second)
+			ok, err := checkTimeBoundedPG(qctx, db, resID, userID, relation)
+			qcancel()
+			utils.AuditCheck("check_time_bounded_direct_user", resID, userID, relation, ok, time.Since(cstart), err)
+			if err != nil {
+				class := errs.Record(err)
+				log.Printf("[postgres] [check_time_bounded_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+				done++
+				continue
+			}
+			dur := time.Since(cstart)
+			if ok {
+				allowed++
+			}
+			if utils.LogIteration(done, 100) {
+				log.Printf("[postgres] [check_time_bounded_direct_user] iter=%d resource=%d user=%d allowed=%t dur=%s", done, resID, userID, ok, dur)
+			}
+			done++
+		}
+		rows.Close()
+		if streamed == 0 {
+			log.Printf("[postgres] [check_time_bounded_direct_user] skipped: no time-bounded grants (generate with RLP_EXPIRING_GRANT_FRACTION)")
+			break
+		}
+	}
+	log.Printf("[postgres] [check_time_bounded_direct_user] DONE: iters=%d allowed=%d denied=%d", done, allowed, done-allowed-errs.Total())
+	log.Printf("[postgres] [check_time_bounded_direct_user] ERRORS: %s", errs.Summary(done))
+}
+
+// timeBoundedCheckSQL is the query of checkTimeBoundedPG, with $1 the
+// resource, $2 the user and $3 the relation.
+const timeBoundedCheckSQL = `SELECT EXISTS(SELECT 1 FROM resource_acl
+		WHERE resource_id = $1 AND subject_type = 'user' AND subject_id = $2 AND relation = $3
+		AND (valid_from IS NULL OR valid_from <= now())
+		AND (valid_until IS NULL OR valid_until > now()))`
+
+// checkTimeBoundedPG checks a direct grant in resource_acl, honouring its
+// valid_from/valid_until window.
+func checkTimeBoundedPG(ctx context.Context, db *sql.DB, resourceID, userID int, relation string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, timeBoundedCheckSQL, resourceID, userID, relation).Scan(&exists)
+	return exists, err
+}
+
+// runCheckBulkManageDirectUser times batched checks: each iteration checks
+// BENCH_CHECK_BULK_SIZE (resource, user) pairs in one query, the way SpiceDB
+// answers CheckBulkPermissions, instead of one round trip per pair. Pairs come
+// from direct manager grants; every other pair is given the next grant's user,
+// so batches mix allowed and (mostly) denied answers.
+func runCheckBulkManageDirectUser(db *sql.DB) {
+	iters := utils.GetEnvInt("BENCH_CHECK_BULK_ITER", 100)
+	size := utils.GetEnvInt("BENCH_CHECK_BULK_SIZE", 100)
+	log.Printf("[postgres] [check_bulk_manage_direct_user] streaming mode. iterations=%d batch=%d", iters, size)
+	utils.LogScenarioConfig("postgres", "check_bulk_manage_direct_user", iters, 10*time.Second)
+	done, allowed := 0, 0
+	errs := utils.NewErrorTally()
+
+	batch := make([]pgauthz.Pair, 0, size)
+	runBatch := func() {
+		for i := 1; i < len(batch); i += 2 {
+			batch[i].UserID = batch[(i+1)%len(batch)].UserID
+		}
+		cstart := time.Now()
+		qctx, qcancel := context.WithTimeout(context.Background(), 10*time.Second)
+		granted, err := pgauthz.CheckBulk(qctx, db, batch, "manager")
+		qcancel()
+		dur := time.Since(cstart)
+		for i, p := range batch {
+			utils.AuditCheck("check_bulk_manage_direct_user", p.ResourceID, p.UserID, "manager", err == nil && granted[i], dur, err)
+		}
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[postgres] [check_bulk_manage_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+		} else {
+			n := 0
+			for _, ok := range granted {
+				if ok {
+					n++
+				}
+			}
+			allowed += n
+			if utils.LogIteration(done, 10) {
+				log.Printf("[postgres] [check_bulk_manage_direct_user] iter=%d pairs=%d allowed=%d dur=%s", done, len(batch), n, dur)
+			}
+		}
+		done++
+		batch = batch[:0]
+	}
+
+	for done < iters {
+		rows, err := db.QueryContext(context.Background(), `SELECT resource_id, subject_id FROM resource_acl WHERE subject_type = 'user' AND (relation = 'manager_user' OR relation = 'manager')`)
+		if err != nil {
+			errs.Survive(err, iters, "[postgres] [check_bulk_manage_direct_user] resource_acl query failed: %v", err)
+			continue
+		}
+		streamed := 0
+		for rows.Next() {
+			if done >= iters {
+				break
+			}
+			var p pgauthz.Pair
+			if err := rows.Scan(&p.ResourceID, &p.UserID); err != nil {
+				rows.Close()
+				errs.Survive(err, iters, "[postgres] [check_bulk_manage_direct_user] scan failed: %v", err)
+				break
+			}
+			streamed++
+
+			if !utils.InOrgScope(ids.Resource, p.ResourceID) {
+				continue
+			}
+
+			if batch = append(batch, p); len(batch) == size {
+				runBatch()
+			}
+		}
+		rows.Close()
+		if streamed == 0 {
+			log.Printf("[postgres] [check_bulk_manage_direct_user] skipped: no direct manager grants")
+			break
+		}
+	}
+	log.Printf("[postgres] [check_bulk_manage_direct_user] DONE: iters=%d pairs=%d allowed=%d", done, done*size, allowed)
+	log.Printf("[postgres] [check_bulk_manage_direct_user] ERRORS: %s", errs.Summary(done))
 }
 
 func runLookupResourcesManageHeavyUser(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_LOOKUPRES_MANAGE_ITER", 10)
-	userID := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	userID := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	runLookupBenchPG(db, "lookup_resources_manage_super", "manager", userID, iters, 60*time.Second)
 }
 
 func runLookupResourcesViewRegularUser(db *sql.DB) {
 	iters := utils.GetEnvInt("BENCH_LOOKUPRES_VIEW_ITER", 10)
-	userID := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	userID := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	runLookupBenchPG(db, "lookup_resources_view_regular", "viewer", userID, iters, 60*time.Second)
 }
+
+// runLookupResourcesMix benchmarks lookups for a mix of users stratified by
+// grant count, reporting latency against result-set size. The number of users
+// is set by BENCH_LOOKUPRES_MIX_USERS (default 0 = skip); see utils.RunLookupMix.
+func runLookupResourcesMix(db *sql.DB) {
+	utils.RunLookupMix("postgres", newChecker(db))
+}
+
+// runLookupResourcesPercentiles benchmarks lookups for the users at the p10,
+// p50, p90 and p99 of the grant counts (BENCH_LOOKUPRES_*_PCT_USERS); see
+// utils.RunPercentileLookups.
+func runLookupResourcesPercentiles(db *sql.DB) {
+	utils.RunPercentileLookups("postgres", newChecker(db))
+}
+
+// runViralFanIn benchmarks checks and lookups on the resources shared with many
+// users directly (RLP_VIRAL_RESOURCES); see utils.RunViralFanIn.
+func runViralFanIn(db *sql.DB) {
+	utils.RunViralFanIn("postgres", newChecker(db))
+}
+
+// runWorstCaseChecks benchmarks view checks on the pairs granted only through
+// nested groups (check_view_worst_case); see utils.RunWorstCaseChecks.
+func runWorstCaseChecks(db *sql.DB) {
+	utils.RunWorstCaseChecks("postgres", newChecker(db))
+}
+
+// runWorkload benchmarks the blended mix of checks and lookups named by
+// BENCH_WORKLOAD (default "" = skip); see utils.RunWorkload.
+func runWorkload(db *sql.DB) {
+	utils.RunWorkload("postgres", newChecker(db))
+}
+
+// runMyGroups benchmarks listing the direct and effective groups of sampled
+// users (BENCH_MY_GROUPS_ITER, default 100; 0 skips); see utils.RunMyGroups.
+func runMyGroups(db *sql.DB) {
+	utils.RunMyGroups("postgres", newChecker(db))
+}
+
+// runAccessSummary benchmarks counting the resources sampled users can view
+// and manage (BENCH_ACCESS_SUMMARY_ITER, default 100; 0 skips); see
+// utils.RunAccessSummary.
+func runAccessSummary(db *sql.DB) {
+	utils.RunAccessSummary("postgres", newChecker(db))
+}
+
+// runMiddlewareOverhead benchmarks a resource fetch with and without a view
+// check in front of it (BENCH_MIDDLEWARE_ITER, default 1000; 0 skips); see
+// utils.RunMiddlewareOverhead.
+func runMiddlewareOverhead(db *sql.DB) {
+	utils.RunMiddlewareOverhead("postgres", newChecker(db), func(ctx context.Context, resourceID string) error {
+		_, err := utils.CountSQLRows(ctx, db, `SELECT * FROM resources WHERE resource_id = $1`, resourceID)
+		return err
+	})
+}
+
+// runDerivedPermissions benchmarks checks and lookups of manage, view and
+// the permissions of BENCH_PERMISSION_RULES (skipped when unset); see
+// utils.RunDerivedPermissions.
+func runDerivedPermissions(db *sql.DB) {
+	utils.RunDerivedPermissions("postgres", newChecker(db))
+}
+
+// runDenials benchmarks checks and lookups of view with and without the
+// anti-join of resource_bans (BENCH_DENIALS, skipped when unset); see
+// utils.RunDenials.
+func runDenials(db *sql.DB) {
+	utils.RunDenials("postgres", newChecker(db))
+}
+
+// runFullExport benchmarks streaming every resource_acl row (export_full_acl,
+// BENCH_EXPORT_ITER); see utils.RunFullExport.
+func runFullExport(db *sql.DB) {
+	utils.RunFullExport("postgres", func(ctx context.Context) (int, error) {
+		return utils.CountSQLRows(ctx, db, `SELECT resource_id, subject_type, subject_id, relation, valid_from, valid_until FROM resource_acl`)
+	})
+}
+
+// runListRecentViewable benchmarks the authorized listing of the newest
+// resources a user can view; see utils.RunRecentListing.
+func runListRecentViewable(db *sql.DB) {
+	utils.RunRecentListing("postgres", func(ctx context.Context, userID string, limit int, after *utils.ListedResource) ([]utils.ListedResource, error) {
+		return listRecentViewablePG(ctx, db, userID, limit, after)
+	})
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// tenantTables are the tables carrying org_id that the optional RLS policy
+// scopes to the bench role's tenant.
+var tenantTables = []string{"organizations", "users", "groups", "org_memberships", "resources"}
+
+// ensureBenchUser provisions the read-only role the benchmark connects as
+// (see infrastructure.UseBenchCredentials). No-op unless PG_BENCH_USER is set.
+//
+// Env vars:
+//
+//	PG_BENCH_USER            (default: "" -> skip)
+//	PG_BENCH_PASSWORD        (default: "")
+//	PG_BENCH_RLS             (default: false) enable row level security on the org_id tables
+//	PG_BENCH_TENANT_ORG_ID   (default: "" -> role sees every org) pin the role to one org via RLS
+func ensureBenchUser(ctx context.Context, db *sql.DB) {
+	user, password, ok := infrastructure.BenchCredentialsFromEnv("PG")
+	if !ok {
+		return
+	}
+	role := pq.QuoteIdentifier(user)
+
+	var dbName string
+	if err := db.QueryRowContext(ctx, "SELECT current_database()").Scan(&dbName); err != nil {
+		log.Fatalf("[postgres] bench user: current_database failed: %v", err)
+	}
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)", user).Scan(&exists); err != nil {
+		log.Fatalf("[postgres] bench user: lookup %q failed: %v", user, err)
+	}
+	verb := "CREATE"
+	if exists {
+		verb = "ALTER"
+	}
+
+	schema := "public"
+	if ns := utils.Namespace(); ns != "" {
+		schema = pq.QuoteIdentifier(ns)
+	}
+	stmts := []string{
+		fmt.Sprintf("%s ROLE %s WITH LOGIN PASSWORD %s NOSUPERUSER NOCREATEDB NOCREATEROLE", verb, role, pq.QuoteLiteral(password)),
+		fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO %s", pq.QuoteIdentifier(dbName), role),
+		fmt.Sprintf("GRANT USAGE ON SCHEMA %s TO %s", schema, role),
+		fmt.Sprintf("GRANT SELECT ON ALL TABLES IN SCHEMA %s TO %s", schema, role),
+		fmt.Sprintf("ALTER DEFAULT PRIVILEGES IN SCHEMA %s GRANT SELECT ON TABLES TO %s", schema, role),
+		fmt.Sprintf("ALTER ROLE %s SET default_transaction_read_only = on", role),
+	}
+
+	rls := utils.GetEnvWithDefault("PG_BENCH_RLS", "false") == "true"
+	tenant := utils.GetEnvWithDefault("PG_BENCH_TENANT_ORG_ID", "")
+	if tenant != "" {
+		rls = true
+		stmts = append(stmts, fmt.Sprintf("ALTER ROLE %s SET rlp.tenant_org_id = %s", role, pq.QuoteLiteral(tenant)))
+	} else {
+		stmts = append(stmts, fmt.Sprintf("ALTER ROLE %s RESET rlp.tenant_org_id", role))
+	}
+	if rls {
+		// An unset rlp.tenant_org_id leaves every org visible, so the same role
+		// serves both the cross-tenant benchmarks and tenant-pinned runs.
+		for _, table := range tenantTables {
+			policy := pq.QuoteIdentifier("rlp_bench_tenant_" + table)
+			stmts = append(stmts,
+				fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", table),
+				fmt.Sprintf("DROP POLICY IF EXISTS %s ON %s", policy, table),
+				fmt.Sprintf(`CREATE POLICY %s ON %s FOR SELECT TO %s USING (
+	COALESCE(current_setting('rlp.tenant_org_id', true), '') = ''
+	OR org_id = current_setting('rlp.tenant_org_id', true)::int)`, policy, table, role),
+			)
+		}
+	}
+
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			log.Fatalf("[postgres] bench user: %v", err)
+		}
+	}
+
+	log.Printf("[postgres] Read-only bench user %q ready (rls=%t tenantOrgID=%q)", user, rls, tenant)
+}
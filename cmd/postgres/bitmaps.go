@@ -0,0 +1,379 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	pgauthz "test-tls/authz/postgres"
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// bitmapEncodings are the per-user permission set encodings of `postgres
+// bitmaps`, next to the normalized user_resource_permissions view they are
+// built from:
+//
+//	bytea    one bit per resource id, bit n of byte n/8 (get_bit order)
+//	roaring  roaringbitmap of the pg_roaringbitmap extension, skipped when
+//	         CREATE EXTENSION roaringbitmap fails
+var bitmapEncodings = []string{"bytea", "roaring"}
+
+// bitmapTable is the table of one encoding: a row per (user, relation) with
+// every resource the user holds the relation on.
+func bitmapTable(encoding string) string { return "user_permission_bitmaps_" + encoding }
+
+// bitmapQueries are the check (bitmap contains) and lookup (bitmap to array)
+// queries of an encoding, with $1 the resource, $2 the user and $3 the
+// relation for checks, and $1 the user and $2 the relation for lookups. The
+// bytea lookup returns the bitmap itself, decoded by the client.
+var bitmapQueries = map[string]struct{ check, lookup string }{
+	"bytea": {
+		check: `SELECT EXISTS(SELECT 1 FROM user_permission_bitmaps_bytea
+			WHERE user_id = $2 AND relation = $3
+			AND CASE WHEN $1 < length(resources) * 8 THEN get_bit(resources, $1) = 1 ELSE false END)`,
+		lookup: `SELECT resources FROM user_permission_bitmaps_bytea WHERE user_id = $1 AND relation = $2`,
+	},
+	"roaring": {
+		check: `SELECT EXISTS(SELECT 1 FROM user_permission_bitmaps_roaring
+			WHERE user_id = $2 AND relation = $3 AND rb_contains(resources, $1::int))`,
+		lookup: `SELECT unnest(rb_to_array(resources)) FROM user_permission_bitmaps_roaring WHERE user_id = $1 AND relation = $2`,
+	},
+}
+
+// PostgresBitmaps benchmarks precomputed permission sets stored as bitmaps
+// against the normalized user_resource_permissions view: for each of
+// bitmapEncodings the view is aggregated into a table with one bitmap per
+// user and relation, and check_manage, check_view, lookup_manage and
+// lookup_view are timed on the view ("normalized") and on every encoding.
+// Per variant and query it logs a "[bitmaps] ... DONE:" line with the latency
+// percentiles and the rows returned; rows that differ from normalized are
+// logged as a mismatch. The size of the view and of each table is logged
+// after the build. The tables are dropped afterwards unless
+// POSTGRES_BITMAP_KEEP is true (`postgres drop` removes kept ones).
+//
+// Check pairs are read from user_resource_permissions; the lookup users are
+// BENCH_LOOKUPRES_MANAGE_USER and BENCH_LOOKUPRES_VIEW_USER, or the user of
+// the first pair.
+//
+// Env vars:
+//
+//	POSTGRES_BITMAP_ENCODINGS    (default: all, comma-separated)
+//	POSTGRES_BITMAP_CHECK_ITER   (default: 1000)
+//	POSTGRES_BITMAP_LOOKUP_ITER  (default: 10)
+//	POSTGRES_BITMAP_KEEP         (default: false)
+func PostgresBitmaps() {
+	ctx := context.Background()
+	db, cleanup, err := infrastructure.NewPostgresFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[postgres] failed to create postgres client: %v", err)
+	}
+	defer cleanup()
+
+	encodings := bitmapEncodings
+	if spec := utils.GetEnvWithDefault("POSTGRES_BITMAP_ENCODINGS", ""); spec != "" {
+		encodings = nil
+		for e := range strings.SplitSeq(spec, ",") {
+			e = strings.TrimSpace(e)
+			if !slices.Contains(bitmapEncodings, e) {
+				log.Fatalf("[postgres] POSTGRES_BITMAP_ENCODINGS: unknown encoding %q (want %s)", e, strings.Join(bitmapEncodings, ", "))
+			}
+			encodings = append(encodings, e)
+		}
+	}
+	checkIters := utils.GetEnvInt("POSTGRES_BITMAP_CHECK_ITER", 1000)
+	lookupIters := utils.GetEnvInt("POSTGRES_BITMAP_LOOKUP_ITER", 10)
+	keep := utils.GetEnvWithDefault("POSTGRES_BITMAP_KEEP", "false") == "true"
+
+	pairs := map[string][][2]int{
+		"manager": bitmapPairs(ctx, db, "manager", checkIters),
+		"viewer":  bitmapPairs(ctx, db, "viewer", checkIters),
+	}
+	users := map[string]string{
+		"manager": ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER"),
+		"viewer":  ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER"),
+	}
+	for relation, user := range users {
+		if user == "" {
+			users[relation] = fmt.Sprint(pairs[relation][0][1])
+		}
+	}
+	log.Printf("[postgres] [bitmaps] encodings=%s check_iters=%d lookup_iters=%d manage_user=%s view_user=%s view_size=%s",
+		strings.Join(encodings, ","), checkIters, lookupIters, users["manager"], users["viewer"], relationSize(ctx, db, "user_resource_permissions"))
+
+	queries := []struct {
+		name, relation string
+		check          bool
+	}{
+		{"check_manage", "manager", true},
+		{"check_view", "viewer", true},
+		{"lookup_manage", "manager", false},
+		{"lookup_view", "viewer", false},
+	}
+	rows := map[string]int{} // query -> rows of normalized
+	for _, variant := range append([]string{"normalized"}, encodings...) {
+		if variant != "normalized" && !buildBitmaps(ctx, db, variant) {
+			continue
+		}
+		for _, q := range queries {
+			var n int
+			if q.check {
+				n = runBitmapQuery(variant, q.name, checkIters, true, func(ctx context.Context, i int) (int, error) {
+					p := pairs[q.relation][i%len(pairs[q.relation])]
+					granted, err := bitmapCheck(ctx, db, variant, p[0], p[1], q.relation)
+					if granted {
+						return 1, err
+					}
+					return 0, err
+				})
+			} else {
+				n = runBitmapQuery(variant, q.name, lookupIters, false, func(ctx context.Context, _ int) (int, error) {
+					count := 0
+					err := bitmapLookup(ctx, db, variant, users[q.relation], q.relation, func(int) { count++ })
+					return count, err
+				})
+			}
+			if variant == "normalized" {
+				rows[q.name] = n
+			} else if want := rows[q.name]; n != want {
+				log.Printf("[postgres] [bitmaps] variant=%s query=%s MISMATCH: rows=%d, normalized returned %d", variant, q.name, n, want)
+			}
+		}
+		if variant != "normalized" && !keep {
+			dropBitmaps(ctx, db, variant)
+		}
+	}
+	log.Println("[postgres] == Postgres bitmap encoding comparison DONE ==")
+}
+
+// bitmapPairs reads up to n (resource, user) pairs holding relation from
+// user_resource_permissions, for the check queries.
+func bitmapPairs(ctx context.Context, db *sql.DB, relation string, n int) [][2]int {
+	rs, err := db.QueryContext(ctx, `SELECT resource_id, user_id FROM user_resource_permissions WHERE relation = $1 LIMIT $2`, relation, n)
+	if err != nil {
+		log.Fatalf("[postgres] bitmaps: read %s pairs failed: %v", relation, err)
+	}
+	defer rs.Close()
+	var pairs [][2]int
+	for rs.Next() {
+		var p [2]int
+		if err := rs.Scan(&p[0], &p[1]); err != nil {
+			log.Fatalf("[postgres] bitmaps: scan %s pair failed: %v", relation, err)
+		}
+		pairs = append(pairs, p)
+	}
+	if len(pairs) == 0 {
+		log.Fatalf("[postgres] bitmaps: no %s rows in user_resource_permissions; run load-data first", relation)
+	}
+	return pairs
+}
+
+// buildBitmaps (re)creates the table of encoding from
+// user_resource_permissions and logs its build time and size. It returns
+// false when the encoding is not available on this server.
+func buildBitmaps(ctx context.Context, db *sql.DB, encoding string) bool {
+	start := time.Now()
+	dropBitmaps(ctx, db, encoding)
+	table := bitmapTable(encoding)
+
+	if encoding == "roaring" {
+		if err := execWithTimeout(ctx, db, `CREATE EXTENSION IF NOT EXISTS roaringbitmap`, 60*time.Second); err != nil {
+			log.Printf("[postgres] [bitmaps] variant=roaring SKIPPED: pg_roaringbitmap not available: %v", err)
+			return false
+		}
+	}
+	err := recordCreated(ctx, db, func() error {
+		switch encoding {
+		case "bytea":
+			return buildByteaBitmaps(ctx, db)
+		case "roaring":
+			return execWithTimeout(ctx, db, `CREATE TABLE user_permission_bitmaps_roaring AS
+				SELECT user_id, relation, rb_build_agg(resource_id::int) AS resources
+				FROM user_resource_permissions
+				GROUP BY user_id, relation`, 30*time.Minute)
+		}
+		return nil
+	})
+	if err == nil {
+		err = execWithTimeout(ctx, db, fmt.Sprintf(`ALTER TABLE %s ADD PRIMARY KEY (user_id, relation)`, table), 30*time.Minute)
+	}
+	if err == nil {
+		err = execWithTimeout(ctx, db, `ANALYZE `+table, 10*time.Minute)
+	}
+	if err != nil {
+		log.Fatalf("[postgres] bitmaps: build %s: %v", table, err)
+	}
+	log.Printf("[postgres] [bitmaps] variant=%s built in %s: %s=%s", encoding, time.Since(start).Truncate(time.Millisecond), table, relationSize(ctx, db, table))
+	return true
+}
+
+// buildByteaBitmaps fills user_permission_bitmaps_bytea: the view is read in
+// (user, relation) order and each set is encoded client side, since SQL has
+// no aggregate that sets bits of a bytea.
+func buildByteaBitmaps(ctx context.Context, db *sql.DB) error {
+	if err := execWithTimeout(ctx, db, `CREATE TABLE user_permission_bitmaps_bytea (
+		user_id BIGINT NOT NULL,
+		relation TEXT NOT NULL,
+		resources BYTEA NOT NULL
+	)`, 60*time.Second); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	insert, err := tx.PrepareContext(ctx, `INSERT INTO user_permission_bitmaps_bytea (user_id, relation, resources) VALUES ($1, $2, $3)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	rs, err := db.QueryContext(ctx, `SELECT user_id, relation, resource_id FROM user_resource_permissions ORDER BY user_id, relation`)
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+
+	var (
+		user     int64 = -1
+		relation string
+		bits     []byte
+	)
+	flush := func() error {
+		if user < 0 {
+			return nil
+		}
+		_, err := insert.ExecContext(ctx, user, relation, bits)
+		return err
+	}
+	for rs.Next() {
+		var u int64
+		var rel string
+		var res int
+		if err := rs.Scan(&u, &rel, &res); err != nil {
+			return err
+		}
+		if u != user || rel != relation {
+			if err := flush(); err != nil {
+				return err
+			}
+			user, relation, bits = u, rel, nil
+		}
+		if need := res/8 + 1; len(bits) < need {
+			bits = append(bits, make([]byte, need-len(bits))...)
+		}
+		bits[res/8] |= 1 << (res % 8)
+	}
+	if err := rs.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func dropBitmaps(ctx context.Context, db *sql.DB, encoding string) {
+	if err := execWithTimeout(ctx, db, `DROP TABLE IF EXISTS `+bitmapTable(encoding), 60*time.Second); err != nil {
+		log.Printf("[postgres] bitmaps: warning: drop %s failed: %v", bitmapTable(encoding), err)
+	}
+}
+
+// bitmapCheck answers a check on variant: the normalized view through
+// pgauthz.Check, or the encoding's contains query.
+func bitmapCheck(ctx context.Context, db *sql.DB, variant string, resourceID, userID int, relation string) (bool, error) {
+	if variant == "normalized" {
+		return pgauthz.Check(ctx, db, "view", resourceID, userID, relation)
+	}
+	var granted bool
+	err := db.QueryRowContext(ctx, bitmapQueries[variant].check, resourceID, userID, relation).Scan(&granted)
+	return granted, err
+}
+
+// bitmapLookup streams the resources of a lookup on variant: the normalized
+// view through pgauthz.LookupResources, or the encoding's bitmap expanded to
+// resource ids.
+func bitmapLookup(ctx context.Context, db *sql.DB, variant, userID, relation string, handle func(resID int)) error {
+	switch variant {
+	case "normalized":
+		return pgauthz.LookupResources(ctx, db, "view", userID, relation, handle)
+	case "bytea":
+		var bits []byte
+		err := db.QueryRowContext(ctx, bitmapQueries[variant].lookup, userID, relation).Scan(&bits)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for i, b := range bits {
+			for j := range 8 {
+				if b&(1<<j) != 0 {
+					handle(i*8 + j)
+				}
+			}
+		}
+		return nil
+	}
+
+	rs, err := db.QueryContext(ctx, bitmapQueries[variant].lookup, userID, relation)
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+	for rs.Next() {
+		var resID int
+		if err := rs.Scan(&resID); err != nil {
+			return err
+		}
+		handle(resID)
+	}
+	return rs.Err()
+}
+
+// runBitmapQuery times run iters times and logs the DONE line of variant and
+// query. It returns the rows of the last successful iteration, or for checks
+// the number of granted iterations.
+func runBitmapQuery(variant, query string, iters int, check bool, run func(ctx context.Context, i int) (int, error)) int {
+	durations := make([]time.Duration, 0, iters)
+	rows := 0
+	errs := utils.NewErrorTally()
+	for i := range iters {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		start := time.Now()
+		n, err := run(ctx, i)
+		dur := time.Since(start)
+		cancel()
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[postgres] [bitmaps] variant=%s query=%s iter=%d failed class=%s: %v", variant, query, i, class, err)
+			continue
+		}
+		durations = append(durations, dur)
+		if check {
+			rows += n
+		} else {
+			rows = n
+		}
+	}
+	log.Printf("[postgres] [bitmaps] variant=%s query=%s DONE: iters=%d rows=%d %s", variant, query, len(durations), rows, utils.LatencySummary(durations))
+	log.Printf("[postgres] [bitmaps] variant=%s query=%s ERRORS: %s", variant, query, errs.Summary(iters))
+	return rows
+}
+
+// relationSize returns the total size of a table or materialized view,
+// indexes included, or "?" if it cannot be read.
+func relationSize(ctx context.Context, db *sql.DB, name string) string {
+	var size string
+	if err := db.QueryRowContext(ctx, `SELECT pg_size_pretty(pg_total_relation_size($1::regclass))`, name).Scan(&size); err != nil {
+		return "?"
+	}
+	return size
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import "test-tls/utils"
+
+// Capabilities lists what the postgres module supports, for the capabilities
+// command. Keep it in step with the benchmark scenarios in bechmark_reads.go.
+func Capabilities() utils.Capabilities {
+	return utils.Capabilities{
+		Scenarios: []string{
+			"check_manage_direct_user",
+			"check_manage_org_admin",
+			"check_view_via_group_member",
+			"check_time_bounded_direct_user",
+			"check_bulk_manage_direct_user",
+			"lookup_resources_manage_super",
+			"lookup_resources_view_regular",
+			"lookup_resources_*_mix (BENCH_LOOKUPRES_MIX_USERS)",
+			"lookup_resources_*_pct (BENCH_LOOKUPRES_*_PCT_USERS)",
+			"check_view_viral_direct_user (BENCH_VIRAL_RESOURCES)",
+			"lookup_resources_view_viral (BENCH_LOOKUPRES_VIRAL_USER)",
+			"check_view_worst_case (nested-group-only grants, BENCH_CHECK_WORST_ITER)",
+			"workload_* (blended request mix, BENCH_WORKLOAD)",
+			"list_my_groups_direct, list_my_groups_effective (groups of a user, BENCH_MY_GROUPS_ITER)",
+			"count_access_summary (view and manage counts of a user, BENCH_ACCESS_SUMMARY_ITER)",
+			"middleware_overhead (resource fetch with and without a view check, BENCH_MIDDLEWARE_ITER)",
+			"permissions (checks and lookups of derived permissions such as edit, delete and share, BENCH_PERMISSION_RULES)",
+			"denials (view with and without the resource_bans anti-join, BENCH_DENIALS)",
+			"export_full_acl (full ACL stream, BENCH_EXPORT_ITER)",
+			"list_recent_viewable (authorized listing by created_at)",
+			"custom_* (BENCH_CUSTOM_SCENARIOS)",
+			"statement stats (BENCH_STATEMENT_STATS)",
+			"per-scenario cost from pg_stat_statements (BENCH_COST)",
+			"driver_overhead (BENCH_DRIVER_COMPARE)",
+			"check_batching (read-only tx and pgx batch vs one statement per check, BENCH_CHECK_BATCH_SIZE)",
+			"background maintenance: VACUUM (ANALYZE, DISABLE_PAGE_SKIPPING) on the primary (BENCH_MAINTENANCE)",
+		},
+		Schemas: []string{
+			"unpartitioned resource_acl (default)",
+			"hash-partitioned resource_acl (POSTGRES_ACL_PARTITIONS=N)",
+			"nested groups: user_resource_permissions view (default), recursive CTE or group_closure (POSTGRES_GROUP_RESOLUTION=view|cte|closure)",
+			"bitmaps: bytea or roaringbitmap permission sets per user (postgres bitmaps, POSTGRES_BITMAP_ENCODINGS)",
+		},
+		Consistency: []string{
+			"strong (single primary)",
+		},
+		Writes: []string{
+			"user offboarding: one transaction plus view refresh (offboard, BENCH_OFFBOARD_USERS)",
+			"incremental permission refresh: triggers, LISTEN/NOTIFY and a refresher (permission-refresh)",
+		},
+	}
+}
@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	pgauthz "test-tls/authz/postgres"
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// checkBatchVariants are the ways runCheckBatching sends a batch of checks:
+//
+//	statement     one autocommit statement per check, as the check scenarios do
+//	read_only_tx  every check of the batch in one BEGIN READ ONLY ... COMMIT
+//	pgx_batch     every check of the batch pipelined in one pgx batch
+var checkBatchVariants = []string{"statement", "read_only_tx", "pgx_batch"}
+
+// runCheckBatching times batches of BENCH_CHECK_BATCH_SIZE manage checks sent
+// each of the checkBatchVariants ways, when that size is set (default 0 =
+// skip). Applications usually check several resources per request, and one
+// round trip per statement overstates what those checks cost. Unlike
+// check_bulk_manage_direct_user, the checks stay separate statements of the
+// usual check query (POSTGRES_GROUP_RESOLUTION); only the round trips and
+// transactions are shared.
+//
+// The pairs are direct manager grants from resource_acl with every other
+// user swapped, so about half are denied, cycled through for
+// BENCH_CHECK_BATCH_ITER batches per variant. Per variant it logs a
+// "[check_batching] ... DONE:" line whose latencies are amortized per check
+// (batch time / batch size), and a BATCH line with the latency of whole
+// batches.
+//
+// Env vars:
+//
+//	BENCH_CHECK_BATCH_SIZE  (default: 0 = off)
+//	BENCH_CHECK_BATCH_ITER  (default: 100)
+func runCheckBatching(db *sql.DB) {
+	size := utils.GetEnvInt("BENCH_CHECK_BATCH_SIZE", 0)
+	if size <= 0 {
+		return
+	}
+	iters := utils.GetEnvInt("BENCH_CHECK_BATCH_ITER", 100)
+	pairs, err := checkBatchPairs(db, utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000))
+	if err != nil {
+		log.Fatalf("[postgres] [check_batching] pick check pairs: %v", err)
+	}
+	if len(pairs) == 0 {
+		log.Printf("[postgres] [check_batching] skipped: no direct manager grants")
+		return
+	}
+	pool, cleanup, err := infrastructure.NewPostgresPgxPoolFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[postgres] failed to create pgx pool: %v", err)
+	}
+	defer cleanup()
+	log.Printf("[postgres] [check_batching] batch=%d iterations=%d pairs=%d resolution=%s", size, iters, len(pairs), groupResolutionFromEnv())
+	utils.LogScenarioConfig("postgres", "check_batching", iters, 10*time.Second)
+
+	for _, variant := range checkBatchVariants {
+		var amortized, batches []time.Duration
+		allowed := 0
+		errs := utils.NewErrorTally()
+		for i := range iters {
+			batch := make([]pgauthz.Pair, size)
+			for j := range batch {
+				batch[j] = pairs[(i*size+j)%len(pairs)]
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			start := time.Now()
+			granted, err := checkBatch(ctx, db, pool, variant, batch)
+			dur := time.Since(start)
+			cancel()
+			for j, p := range batch {
+				utils.AuditCheck("check_batching_"+variant, p.ResourceID, p.UserID, "manager", err == nil && granted[j], dur/time.Duration(size), err)
+			}
+			if err != nil {
+				class := errs.Record(err)
+				log.Printf("[postgres] [check_batching] variant=%s iter=%d batch failed class=%s: %v", variant, i, class, err)
+				continue
+			}
+			for _, ok := range granted {
+				if ok {
+					allowed++
+				}
+			}
+			batches = append(batches, dur)
+			amortized = append(amortized, dur/time.Duration(size))
+		}
+		log.Printf("[postgres] [check_batching] variant=%s query=check_manage DONE: iters=%d rows=%d %s", variant, len(amortized), allowed, utils.LatencySummary(amortized))
+		log.Printf("[postgres] [check_batching] variant=%s BATCH: size=%d %s", variant, size, utils.LatencySummary(batches))
+		log.Printf("[postgres] [check_batching] variant=%s ERRORS: %s", variant, errs.Summary(iters))
+	}
+}
+
+// checkBatch runs the manage checks of batch the variant way and returns
+// which were granted.
+func checkBatch(ctx context.Context, db *sql.DB, pool *pgxpool.Pool, variant string, batch []pgauthz.Pair) ([]bool, error) {
+	mode := groupResolutionFromEnv()
+	granted := make([]bool, len(batch))
+	switch variant {
+	case "statement":
+		for i, p := range batch {
+			ok, err := checkPermissionPG(ctx, db, p.ResourceID, p.UserID, "manager")
+			if err != nil {
+				return nil, err
+			}
+			granted[i] = ok
+		}
+	case "read_only_tx":
+		tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+		for i, p := range batch {
+			query, args := pgauthz.CheckStatement(mode, p.ResourceID, p.UserID, "manager")
+			if err := tx.QueryRowContext(ctx, query, args...).Scan(&granted[i]); err != nil {
+				return nil, err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	case "pgx_batch":
+		b := &pgx.Batch{}
+		for _, p := range batch {
+			query, args := pgauthz.CheckStatement(mode, p.ResourceID, p.UserID, "manager")
+			b.Queue(query, args...)
+		}
+		br := pool.SendBatch(ctx, b)
+		defer br.Close()
+		for i := range batch {
+			if err := br.QueryRow().Scan(&granted[i]); err != nil {
+				return nil, err
+			}
+		}
+		if err := br.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return granted, nil
+}
+
+// checkBatchPairs returns up to limit direct manager grants in the org scope,
+// with the user of every other pair swapped for the next pair's user.
+func checkBatchPairs(db *sql.DB, limit int) ([]pgauthz.Pair, error) {
+	rows, err := db.QueryContext(context.Background(), `SELECT resource_id, subject_id FROM resource_acl WHERE subject_type = 'user' AND (relation = 'manager_user' OR relation = 'manager')`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var pairs []pgauthz.Pair
+	for rows.Next() && len(pairs) < limit {
+		var p pgauthz.Pair
+		if err := rows.Scan(&p.ResourceID, &p.UserID); err != nil {
+			return nil, err
+		}
+		if utils.InOrgScope(ids.Resource, p.ResourceID) {
+			pairs = append(pairs, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(pairs); i += 2 {
+		pairs[i].UserID = pairs[(i+1)%len(pairs)].UserID
+	}
+	return pairs, nil
+}
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"test-tls/utils"
+)
+
+// costMeter sums pg_stat_statements over the statements of the current
+// database: calls, rows, shared buffer hits and reads (blocks of 8kB) and
+// execution time. It needs the extension, like BENCH_STATEMENT_STATS, and
+// reads the server the benchmark reads from, which may be the replica.
+func costMeter(db *sql.DB) utils.CostMeter {
+	return func(ctx context.Context) ([]utils.CostCounter, error) {
+		var calls, rows, hit, read, execMs float64
+		err := db.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(calls), 0)::float8, COALESCE(SUM(rows), 0)::float8,
+			       COALESCE(SUM(shared_blks_hit), 0)::float8, COALESCE(SUM(shared_blks_read), 0)::float8,
+			       COALESCE(SUM(total_exec_time), 0)::float8
+			FROM pg_stat_statements
+			WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+			  AND query NOT LIKE '%pg_stat_statements%'`).Scan(&calls, &rows, &hit, &read, &execMs)
+		if err != nil {
+			return nil, err
+		}
+		return []utils.CostCounter{
+			{Name: "statements", Value: calls},
+			{Name: "rows", Value: rows},
+			{Name: "shared_blks_hit", Value: hit},
+			{Name: "shared_blks_read", Value: read},
+			{Name: "exec_ms", Value: execMs},
+		}, nil
+	}
+}
@@ -2,18 +2,25 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"log"
-	"os"
+	"regexp"
 	"time"
 
+	"github.com/lib/pq"
+
 	"test-tls/infrastructure"
+	"test-tls/migrate"
+	"test-tls/utils"
 )
 
-// default location schemas.sql relative ke root project.
-const defaultSchemasFile = "cmd/postgres/schemas.sql"
-
-// PostgresCreateSchemas mengeksekusi file schemas.sql ke database Postgres.
-// Path bisa dioverride via env POSTGRES_SCHEMAS_FILE.
+// PostgresCreateSchemas applies the migrations in migrations/ that the
+// database has not recorded yet, so rerunning it is a no-op. They are embedded
+// in the binary; POSTGRES_MIGRATIONS_DIR reads them from a directory instead.
+// POSTGRES_ACL_PARTITIONS=N (default 0 = unpartitioned) creates resource_acl
+// hash-partitioned by resource_id into N partitions instead. With
+// RLP_NAMESPACE everything goes to a schema of that name, created here.
 func PostgresCreateSchemas() {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
@@ -24,21 +31,89 @@ func PostgresCreateSchemas() {
 	}
 	defer cleanup()
 
-	path := os.Getenv("POSTGRES_SCHEMAS_FILE")
-	if path == "" {
-		path = defaultSchemasFile
+	log.Printf("[postgres] Creating schemas ...")
+	if ns := utils.Namespace(); ns != "" {
+		if _, err := db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+pq.QuoteIdentifier(ns)); err != nil {
+			log.Fatalf("[postgres] create_schemas: create schema %q: %v", ns, err)
+		}
+		log.Printf("[postgres] Using schema %q (RLP_NAMESPACE)", ns)
 	}
 
-	log.Printf("[postgres] Creating schemas using %s ...", path)
-
-	sqlBytes, err := os.ReadFile(path)
-	if err != nil {
-		log.Fatalf("[postgres] create_schemas: read %s failed: %v", path, err)
+	migrations := migrate.Load("postgres", migrationFiles, "POSTGRES_MIGRATIONS_DIR")
+	partitions := utils.GetEnvInt("POSTGRES_ACL_PARTITIONS", 0)
+	if partitions > 0 {
+		partitionResourceACL(migrations)
 	}
 
-	if _, err := db.ExecContext(ctx, string(sqlBytes)); err != nil {
-		log.Fatalf("[postgres] create_schemas: executing schemas.sql failed: %v", err)
+	migrate.Run(ctx, "postgres", migrationStore{db}, migrations)
+	if partitions > 0 {
+		createACLPartitions(ctx, db, partitions)
 	}
 
 	log.Println("[postgres] Schemas created successfully.")
+
+	ensureBenchUser(ctx, db)
+}
+
+var resourceACLTableRe = regexp.MustCompile(`(?s)(CREATE TABLE IF NOT EXISTS resource_acl \(.*?\n\))`)
+
+// partitionResourceACL rewrites the resource_acl CREATE TABLE in the
+// migrations into a table hash-partitioned by resource_id. resource_id leads
+// the primary key, so the key stays valid on the partitioned table, and the
+// indexes created on the parent further down are created on every partition.
+func partitionResourceACL(migrations []migrate.Migration) {
+	found := false
+	for i, m := range migrations {
+		if resourceACLTableRe.MatchString(m.Body) {
+			migrations[i].Body = resourceACLTableRe.ReplaceAllString(m.Body, "$1 PARTITION BY HASH (resource_id)")
+			found = true
+		}
+	}
+	if !found {
+		log.Fatalf("[postgres] create_schemas: POSTGRES_ACL_PARTITIONS set but no resource_acl CREATE TABLE found")
+	}
+}
+
+// createACLPartitions creates resource_acl_p0..p(n-1). A resource_acl left
+// over unpartitioned from an earlier create_schemas is kept (IF NOT EXISTS);
+// `postgres drop` first to switch layouts.
+func createACLPartitions(ctx context.Context, db *sql.DB, n int) {
+	if layout := aclLayout(ctx, db); layout == "unpartitioned" {
+		log.Fatalf("[postgres] create_schemas: resource_acl already exists unpartitioned; run `postgres drop` first")
+	}
+	for i := 0; i < n; i++ {
+		stmt := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS resource_acl_p%d PARTITION OF resource_acl FOR VALUES WITH (MODULUS %d, REMAINDER %d)`, i, n, i)
+		if err := recordCreated(ctx, db, func() error {
+			_, err := db.ExecContext(ctx, stmt)
+			return err
+		}); err != nil {
+			log.Fatalf("[postgres] create_schemas: create partition resource_acl_p%d failed: %v", i, err)
+		}
+	}
+	log.Printf("[postgres] resource_acl: %s", aclLayout(ctx, db))
+}
+
+// aclLayout describes how resource_acl is stored, e.g. "unpartitioned" or
+// "hash(resource_id) partitions=16". The benchmark logs it as the SCHEMA line
+// so partitioned and unpartitioned runs are reported apart.
+func aclLayout(ctx context.Context, db *sql.DB) string {
+	var strategy sql.NullString
+	var partitions int
+	err := db.QueryRowContext(ctx, `SELECT
+		(SELECT partstrat::text FROM pg_partitioned_table WHERE partrelid = 'resource_acl'::regclass),
+		(SELECT COUNT(*) FROM pg_inherits WHERE inhparent = 'resource_acl'::regclass)`).Scan(&strategy, &partitions)
+	if err != nil {
+		log.Fatalf("[postgres] resource_acl layout query failed: %v", err)
+	}
+	if !strategy.Valid {
+		return "unpartitioned"
+	}
+	switch strategy.String {
+	case "h":
+		return fmt.Sprintf("hash(resource_id) partitions=%d", partitions)
+	case "l":
+		return fmt.Sprintf("list partitions=%d", partitions)
+	default:
+		return fmt.Sprintf("range partitions=%d", partitions)
+	}
 }
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"test-tls/utils"
+)
+
+// execQuerier is the database or the transaction of a migration.
+type execQuerier interface {
+	utils.Querier
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// recordCreated runs create and records the tables, views and functions it
+// created in rlp_objects, leaving out those it found already there, so that
+// drop removes what the tool created and nothing else of the same name
+// (utils.OwnedRecorded).
+func recordCreated(ctx context.Context, q execQuerier, create func() error) error {
+	if _, err := q.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS rlp_objects (name TEXT PRIMARY KEY)`); err != nil {
+		return err
+	}
+	before, err := utils.ListNames(ctx, q, listObjectsSQL)
+	if err != nil {
+		return err
+	}
+	if err := create(); err != nil {
+		return err
+	}
+	after, err := utils.ListNames(ctx, q, listObjectsSQL)
+	if err != nil {
+		return err
+	}
+	for _, name := range utils.NewObjects(before, after) {
+		if _, err := q.ExecContext(ctx, `INSERT INTO rlp_objects (name) VALUES ($1) ON CONFLICT DO NOTHING`, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordedObjects returns the names recorded in rlp_objects, none when found,
+// the objects of the schema, holds no such table.
+func recordedObjects(ctx context.Context, db *sql.DB, found []string) (map[string]bool, error) {
+	recorded := map[string]bool{}
+	if !slices.Contains(found, "rlp_objects") {
+		return recorded, nil
+	}
+	names, err := utils.ListNames(ctx, db, `SELECT name FROM rlp_objects`)
+	for _, name := range names {
+		recorded[name] = true
+	}
+	return recorded, err
+}
@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"test-tls/utils"
+)
+
+// runCustomScenarios runs the BENCH_CUSTOM_SCENARIOS scenarios that have a
+// postgres query, counting the rows each iteration returns.
+func runCustomScenarios(db *sql.DB) {
+	utils.RunCustomScenarios("postgres", func(ctx context.Context, sc utils.CustomScenario, row utils.CustomRow) (int, error) {
+		args, err := sc.IntArgs(row)
+		if err != nil {
+			return 0, err
+		}
+		rows, err := db.QueryContext(ctx, sc.Queries["postgres"], args...)
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+		n := 0
+		for rows.Next() {
+			n++
+		}
+		return n, rows.Err()
+	})
+}
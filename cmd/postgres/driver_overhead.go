@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	pgauthz "test-tls/authz/postgres"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// runDriverOverhead compares database/sql + lib/pq against a native pgx pool
+// on a bare round trip and on the check query, when BENCH_DRIVER_COMPARE=true.
+// Both drivers check the same granted (resource, user) pair, so the difference
+// is client-side cost only.
+func runDriverOverhead(db *sql.DB) {
+	if utils.GetEnvWithDefault("BENCH_DRIVER_COMPARE", "false") != "true" {
+		return
+	}
+	pool, cleanup, err := infrastructure.NewPostgresPgxPoolFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[postgres] failed to create pgx pool: %v", err)
+	}
+	defer cleanup()
+
+	var resourceID, userID int
+	err = db.QueryRowContext(context.Background(), `SELECT resource_id, user_id FROM user_resource_permissions WHERE relation = 'manager' LIMIT 1`).Scan(&resourceID, &userID)
+	if err != nil {
+		log.Fatalf("[postgres] [driver_overhead] pick check pair: %v", err)
+	}
+	log.Printf("[postgres] [driver_overhead] check resource=%d user=%d relation=manager", resourceID, userID)
+
+	utils.CompareDrivers("postgres", []string{"select1", "check"}, []utils.DriverBench{
+		{Driver: "database-sql", Queries: map[string]utils.DriverCall{
+			"select1": func(ctx context.Context) error {
+				var one int
+				return db.QueryRowContext(ctx, `SELECT 1`).Scan(&one)
+			},
+			"check": func(ctx context.Context) error {
+				_, err := checkPermissionPG(ctx, db, resourceID, userID, "manager")
+				return err
+			},
+		}},
+		{Driver: "pgx", Queries: map[string]utils.DriverCall{
+			"select1": func(ctx context.Context) error {
+				var one int
+				return pool.QueryRow(ctx, `SELECT 1`).Scan(&one)
+			},
+			"check": func(ctx context.Context) error {
+				var exists bool
+				return pool.QueryRow(ctx, pgauthz.CheckSQL, resourceID, userID, "manager").Scan(&exists)
+			},
+		}},
+	})
+}
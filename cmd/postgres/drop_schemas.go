@@ -7,10 +7,13 @@ import (
 	"time"
 
 	"test-tls/infrastructure"
+	"test-tls/utils"
 )
 
 // PostgresDropSchemas drops all ACL-related tables used by postgres benchmarks.
-// It does NOT drop the database or the public schema, only the tables we created.
+// It does NOT drop the database or the public schema, only the tables we created
+// and recorded in rlp_objects: other objects of the schema, including ones that
+// share a name with ours, are listed and left in place (utils.OwnedRecorded).
 func PostgresDropSchemas() {
 	ctx := context.Background()
 
@@ -23,66 +26,90 @@ func PostgresDropSchemas() {
 	start := time.Now()
 	log.Printf("[postgres] == Starting Postgres drop schemas ==")
 
-	// Drop indexes explicitly (although dropping tables/views removes their indexes, this ensures clean state when partial objects exist).
-	indexDrops := []string{
-		`DROP INDEX IF EXISTS uq_user_resource_permissions`,
-		`DROP INDEX IF EXISTS idx_urp_user_rel_res`,
-		`DROP INDEX IF EXISTS idx_urp_org_user_rel`,
-		`DROP INDEX IF EXISTS idx_group_hierarchy_parent`,
-		`DROP INDEX IF EXISTS idx_group_hierarchy_child`,
-		`DROP INDEX IF EXISTS idx_resource_acl_res_rel_type_subject`,
-		`DROP INDEX IF EXISTS idx_resource_acl_by_subject`,
-		`DROP INDEX IF EXISTS idx_resource_acl_by_resource_subject`,
-		`DROP INDEX IF EXISTS idx_resources_org`,
-		`DROP INDEX IF EXISTS idx_group_memberships_user`,
-		`DROP INDEX IF EXISTS idx_org_memberships_user`,
-		`DROP INDEX IF EXISTS idx_users_org`,
+	found, err := utils.ListNames(ctx, db, listObjectsSQL)
+	if err != nil {
+		log.Fatalf("[postgres] drop: list objects: %v", err)
 	}
-
-	for _, stmt := range indexDrops {
-		if err := execWithTimeout(ctx, db, stmt, 30*time.Second); err != nil {
-			log.Printf("[postgres] warning: executing %q failed: %v", stmt, err)
-			continue
-		}
-		log.Printf("[postgres] Executed: %s", stmt)
+	recorded, err := recordedObjects(ctx, db, found)
+	if err != nil {
+		log.Fatalf("[postgres] drop: list recorded objects: %v", err)
 	}
+	own := utils.OwnedRecorded("postgres", found, recorded, ownedObjects)
+
+	utils.GuardDrop("postgres", utils.CountSQLTables(ctx, db, utils.OwnedNames(own, []string{"resource_acl", "resource_bans", "resources", "group_closure", "group_memberships", "group_hierarchy", "org_memberships", "groups", "users", "organizations"})))
 
-	// Drop materialized view and function.
-	objDrops := []string{
-		`DROP MATERIALIZED VIEW IF EXISTS user_resource_permissions`,
-		`DROP FUNCTION IF EXISTS refresh_user_resource_permissions()`,
+	// Materialized view and function first, then tables (children first).
+	// Indexes and the resource_acl partitions go with their tables. There is
+	// no CASCADE: a view of another application on one of these tables makes
+	// its drop fail rather than disappear with it.
+	drops := []struct{ name, stmt string }{
+		{"user_resource_permissions", `DROP MATERIALIZED VIEW IF EXISTS user_resource_permissions`},
+		{"refresh_user_resource_permissions", `DROP FUNCTION IF EXISTS refresh_user_resource_permissions()`},
+		{"group_closure", `DROP TABLE IF EXISTS group_closure`},
+		{"user_resource_permissions_live", `DROP TABLE IF EXISTS user_resource_permissions_live`},
+		{"permission_refresh_queue", `DROP TABLE IF EXISTS permission_refresh_queue`},
+		// Tables kept by `postgres bitmaps` (POSTGRES_BITMAP_KEEP=true)
+		{"user_permission_bitmaps_bytea", `DROP TABLE IF EXISTS user_permission_bitmaps_bytea`},
+		{"user_permission_bitmaps_roaring", `DROP TABLE IF EXISTS user_permission_bitmaps_roaring`},
+		{"resource_acl", `DROP TABLE IF EXISTS resource_acl`},
+		{"resource_bans", `DROP TABLE IF EXISTS resource_bans`},
+		{"resources", `DROP TABLE IF EXISTS resources`},
+		{"group_memberships", `DROP TABLE IF EXISTS group_memberships`},
+		{"group_hierarchy", `DROP TABLE IF EXISTS group_hierarchy`},
+		{"org_memberships", `DROP TABLE IF EXISTS org_memberships`},
+		{"groups", `DROP TABLE IF EXISTS groups`},
+		{"users", `DROP TABLE IF EXISTS users`},
+		{"organizations", `DROP TABLE IF EXISTS organizations`},
+		// Functions of the permission refresh, once the triggers went with their tables.
+		{"enqueue_permission_refresh", `DROP FUNCTION IF EXISTS enqueue_permission_refresh()`},
+		{"permission_refresh_resources", `DROP FUNCTION IF EXISTS permission_refresh_resources(TEXT[], INTEGER[])`},
+		{"resource_permissions", `DROP FUNCTION IF EXISTS resource_permissions(INTEGER[])`},
+		// Forget the applied migrations so create-schema rebuilds everything.
+		{"rlp_schema_migrations", `DROP TABLE IF EXISTS rlp_schema_migrations`},
+		{"rlp_dataset", `DROP TABLE IF EXISTS rlp_dataset`},
+		// The record of what the tool created, once that is gone.
+		{"rlp_objects", `DROP TABLE IF EXISTS rlp_objects`},
 	}
-	for _, stmt := range objDrops {
-		if err := execWithTimeout(ctx, db, stmt, 60*time.Second); err != nil {
-			log.Printf("[postgres] warning: executing %q failed: %v", stmt, err)
+	for _, d := range drops {
+		if !own[d.name] {
 			continue
 		}
-		log.Printf("[postgres] Executed: %s", stmt)
-	}
-
-	// Drop tables (children first), using CASCADE for safety.
-	tableDrops := []string{
-		`DROP TABLE IF EXISTS resource_acl CASCADE`,
-		`DROP TABLE IF EXISTS resources CASCADE`,
-		`DROP TABLE IF EXISTS group_memberships CASCADE`,
-		`DROP TABLE IF EXISTS group_hierarchy CASCADE`,
-		`DROP TABLE IF EXISTS org_memberships CASCADE`,
-		`DROP TABLE IF EXISTS groups CASCADE`,
-		`DROP TABLE IF EXISTS users CASCADE`,
-		`DROP TABLE IF EXISTS organizations CASCADE`,
-	}
-	for _, stmt := range tableDrops {
-		if err := execWithTimeout(ctx, db, stmt, 60*time.Second); err != nil {
-			log.Printf("[postgres] warning: executing %q failed: %v", stmt, err)
+		if err := execWithTimeout(ctx, db, d.stmt, 60*time.Second); err != nil {
+			log.Printf("[postgres] warning: executing %q failed: %v", d.stmt, err)
 			continue
 		}
-		log.Printf("[postgres] Executed: %s", stmt)
+		log.Printf("[postgres] Executed: %s", d.stmt)
 	}
 
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 	log.Printf("[postgres] Postgres drop schemas DONE: elapsed=%s", elapsed)
 }
 
+// ownedObjects are the tables, views and functions create-schema, load-data
+// and the experiments create; drop removes nothing else, and of these only
+// the ones recorded as created (recordCreated).
+var ownedObjects = []string{
+	"organizations", "users", "groups", "org_memberships", "group_memberships", "group_hierarchy",
+	"resources", "resource_acl", "resource_bans", "resource_acl_p*", "group_closure", "user_resource_permissions",
+	"refresh_user_resource_permissions", "rlp_schema_migrations", "rlp_dataset", "rlp_objects",
+	"user_resource_permissions_live", "permission_refresh_queue",
+	"enqueue_permission_refresh", "permission_refresh_resources", "resource_permissions",
+	"user_permission_bitmaps_bytea", "user_permission_bitmaps_roaring",
+}
+
+// listObjectsSQL lists the tables, views and functions of the current schema
+// (the namespace, or public), leaving out those of extensions.
+const listObjectsSQL = `
+SELECT c.relname FROM pg_catalog.pg_class c
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p', 'v', 'm')
+  AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_depend d WHERE d.objid = c.oid AND d.deptype = 'e')
+UNION ALL
+SELECT p.proname FROM pg_catalog.pg_proc p
+JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+WHERE n.nspname = current_schema()
+  AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_depend d WHERE d.objid = p.oid AND d.deptype = 'e')`
+
 func execWithTimeout(parent context.Context, db *sql.DB, stmt string, timeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
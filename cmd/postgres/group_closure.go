@@ -0,0 +1,328 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	pgauthz "test-tls/authz/postgres"
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// groupResolutionFromEnv returns POSTGRES_GROUP_RESOLUTION, one of
+// pgauthz.GroupResolutions (default view).
+func groupResolutionFromEnv() string {
+	mode := utils.GetEnvWithDefault("POSTGRES_GROUP_RESOLUTION", "view")
+	if !slices.Contains(pgauthz.GroupResolutions, mode) {
+		log.Fatalf("[postgres] POSTGRES_GROUP_RESOLUTION=%q: want one of %s", mode, strings.Join(pgauthz.GroupResolutions, ", "))
+	}
+	return mode
+}
+
+// rebuildGroupClosureSQL recomputes group_closure from groups and
+// group_hierarchy (see migrations/0002_group_closure.sql).
+const rebuildGroupClosureSQL = `WITH RECURSIVE
+	mgr(descendant, ancestor) AS (
+		SELECT group_id, group_id FROM groups
+		UNION
+		SELECT m.descendant, gh.parent_group_id FROM mgr m
+		JOIN group_hierarchy gh ON gh.child_group_id = m.ancestor AND gh.relation = 'manager_group'
+	),
+	mem(descendant, ancestor) AS (
+		SELECT group_id, group_id FROM groups
+		UNION
+		SELECT m.descendant, gh.parent_group_id FROM mem m
+		JOIN group_hierarchy gh ON gh.child_group_id = m.ancestor AND gh.relation = 'member_group'
+	)
+	INSERT INTO group_closure (descendant_group_id, member_role, relation, ancestor_group_id)
+	SELECT descendant, 'direct_manager', 'manager', ancestor FROM mgr
+	UNION
+	SELECT descendant, 'direct_member', 'member', ancestor FROM mem
+	UNION
+	SELECT g.descendant, 'direct_manager', 'member', m.ancestor FROM mgr g JOIN mem m ON m.descendant = g.ancestor`
+
+// addEdgeClosureSQL adds the closure rows of a new group_hierarchy edge from
+// parent $1 to child $2, per edge relation: every ancestor of the parent
+// paired with every descendant of the child, for the path shapes the edge
+// extends. Removing an edge cannot be done this way (another path may still
+// connect the pair); see rederiveGroupClosureSQL.
+var addEdgeClosureSQL = map[string]string{
+	"member_group": `INSERT INTO group_closure (descendant_group_id, member_role, relation, ancestor_group_id)
+		SELECT d.descendant_group_id, d.member_role, 'member', a.ancestor_group_id
+		FROM group_closure a
+		JOIN group_closure d ON d.ancestor_group_id = $2 AND d.relation = 'member'
+		WHERE a.descendant_group_id = $1 AND a.member_role = 'direct_member' AND a.relation = 'member'
+		ON CONFLICT DO NOTHING`,
+	"manager_group": `INSERT INTO group_closure (descendant_group_id, member_role, relation, ancestor_group_id)
+		SELECT d.descendant_group_id, 'direct_manager', a.relation, a.ancestor_group_id
+		FROM group_closure a
+		JOIN group_closure d ON d.ancestor_group_id = $2 AND d.member_role = 'direct_manager' AND d.relation = 'manager'
+		WHERE a.descendant_group_id = $1 AND a.member_role = 'direct_manager'
+		ON CONFLICT DO NOTHING`,
+}
+
+// rebuildGroupClosure replaces the contents of group_closure in one
+// transaction and returns the rows written.
+func rebuildGroupClosure(ctx context.Context, db *sql.DB) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM group_closure`); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, rebuildGroupClosureSQL)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return n, tx.Commit()
+}
+
+// loadGroupClosure builds group_closure after the import.
+func loadGroupClosure(db *sql.DB) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	n, err := rebuildGroupClosure(ctx, db)
+	if err != nil {
+		log.Fatalf("[postgres] group_closure: build failed: %v", err)
+	}
+	log.Printf("[postgres] group_closure: %d rows DONE in %s", n, time.Since(start).Truncate(time.Millisecond))
+}
+
+// groupEdge is one group_hierarchy row.
+type groupEdge struct {
+	parent, child int
+	relation      string
+}
+
+// editGroupEdge adds or removes edge in group_hierarchy and brings the
+// variant's derived data up to date: the view is refreshed, the closure is
+// updated incrementally (see groupClosureMaintainer), cte has nothing to
+// maintain. It returns the derived rows written.
+func editGroupEdge(ctx context.Context, db *sql.DB, variant string, edge groupEdge, add bool) (int64, error) {
+	if variant == "closure" {
+		n, err := groupClosureMaintainer{db: db}.Apply(ctx, []utils.GroupEdgeChange{
+			{Add: add, Parent: edge.parent, Child: edge.child, Relation: edge.relation},
+		})
+		return int64(n), err
+	}
+	stmt := `DELETE FROM group_hierarchy WHERE parent_group_id = $1 AND child_group_id = $2 AND relation = $3`
+	if add {
+		stmt = `INSERT INTO group_hierarchy (parent_group_id, child_group_id, relation) VALUES ($1, $2, $3)`
+	}
+	if _, err := db.ExecContext(ctx, stmt, edge.parent, edge.child, edge.relation); err != nil {
+		return 0, err
+	}
+	if variant == "view" {
+		_, err := db.ExecContext(ctx, `REFRESH MATERIALIZED VIEW user_resource_permissions`)
+		return 0, err
+	}
+	return 0, nil
+}
+
+// PostgresGroupClosure benchmarks the nested group resolutions of the check
+// and lookup queries against each other (see pgauthz.GroupResolutions): the
+// materialized view, a recursive CTE per query and the group_closure table.
+// Per variant it logs a "[group_closure] ... DONE:" line for check_manage,
+// check_view, lookup_manage and lookup_view; rows that differ from "view" are
+// logged as a mismatch. It then times the maintenance cost of a hierarchy
+// change: an existing edge is removed and added back (remove_edge, add_edge),
+// each followed by the variant's refresh or incremental update.
+//
+// Check pairs are read from user_resource_permissions; the lookup user is
+// BENCH_LOOKUPRES_VIEW_USER, or a direct member of a nested group.
+//
+// Env vars:
+//
+//	POSTGRES_GROUP_VARIANTS     (default: all, comma-separated)
+//	POSTGRES_GROUP_CHECK_ITER   (default: 1000)
+//	POSTGRES_GROUP_LOOKUP_ITER  (default: 10)
+//	POSTGRES_GROUP_MAINT_ITER   (default: 3)
+func PostgresGroupClosure() {
+	ctx := context.Background()
+	db, cleanup, err := infrastructure.NewPostgresFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[postgres] failed to create postgres client: %v", err)
+	}
+	defer cleanup()
+
+	variants := pgauthz.GroupResolutions
+	if spec := utils.GetEnvWithDefault("POSTGRES_GROUP_VARIANTS", ""); spec != "" {
+		variants = nil
+		for v := range strings.SplitSeq(spec, ",") {
+			v = strings.TrimSpace(v)
+			if !slices.Contains(pgauthz.GroupResolutions, v) {
+				log.Fatalf("[postgres] POSTGRES_GROUP_VARIANTS: unknown variant %q (want %s)", v, strings.Join(pgauthz.GroupResolutions, ", "))
+			}
+			variants = append(variants, v)
+		}
+	}
+	checkIters := utils.GetEnvInt("POSTGRES_GROUP_CHECK_ITER", 1000)
+	lookupIters := utils.GetEnvInt("POSTGRES_GROUP_LOOKUP_ITER", 10)
+	maintIters := utils.GetEnvInt("POSTGRES_GROUP_MAINT_ITER", 3)
+
+	pairs := map[string][][2]int{
+		"manager": groupClosurePairs(ctx, db, "manager", checkIters),
+		"viewer":  groupClosurePairs(ctx, db, "viewer", checkIters),
+	}
+	userID := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
+	if userID == "" {
+		err := db.QueryRowContext(ctx, `SELECT gm.user_id::text FROM group_hierarchy gh
+			JOIN group_memberships gm ON gm.group_id = gh.child_group_id
+			WHERE gh.relation = 'member_group' ORDER BY gm.user_id LIMIT 1`).Scan(&userID)
+		if err != nil {
+			log.Fatalf("[postgres] group_closure: no member of a nested group found; set BENCH_LOOKUPRES_VIEW_USER: %v", err)
+		}
+	}
+	var edge groupEdge
+	if err := db.QueryRowContext(ctx, `SELECT parent_group_id, child_group_id, relation FROM group_hierarchy
+		ORDER BY parent_group_id, child_group_id, relation LIMIT 1`).Scan(&edge.parent, &edge.child, &edge.relation); err != nil {
+		log.Fatalf("[postgres] group_closure: no group_hierarchy edge; run load-data first: %v", err)
+	}
+	log.Printf("[postgres] [group_closure] variants=%s check_iters=%d lookup_iters=%d maint_iters=%d user=%s edge=%d->%d(%s)",
+		strings.Join(variants, ","), checkIters, lookupIters, maintIters, userID, edge.parent, edge.child, edge.relation)
+
+	queries := []struct {
+		name, relation string
+		check          bool
+	}{
+		{"check_manage", "manager", true},
+		{"check_view", "viewer", true},
+		{"lookup_manage", "manager", false},
+		{"lookup_view", "viewer", false},
+	}
+	rows := map[string]int{} // query -> rows of the view variant
+	for _, v := range variants {
+		for _, q := range queries {
+			var n int
+			if q.check {
+				n = runGroupClosureQuery(v, q.name, checkIters, true, func(ctx context.Context, i int) (int, error) {
+					p := pairs[q.relation][i%len(pairs[q.relation])]
+					granted, err := pgauthz.Check(ctx, db, v, p[0], p[1], q.relation)
+					if granted {
+						return 1, err
+					}
+					return 0, err
+				})
+			} else {
+				n = runGroupClosureQuery(v, q.name, lookupIters, false, func(ctx context.Context, _ int) (int, error) {
+					count := 0
+					err := pgauthz.LookupResources(ctx, db, v, userID, q.relation, func(int) { count++ })
+					return count, err
+				})
+			}
+			if v == "view" {
+				rows[q.name] = n
+			} else if want, ok := rows[q.name]; ok && n != want {
+				log.Printf("[postgres] [group_closure] variant=%s query=%s MISMATCH: rows=%d, view returned %d", v, q.name, n, want)
+			}
+		}
+		runGroupEdgeMaintenance(db, v, edge, maintIters)
+	}
+	log.Println("[postgres] == Postgres group closure comparison DONE ==")
+}
+
+// groupClosurePairs reads up to n (resource, user) pairs holding relation
+// from user_resource_permissions, for the check queries.
+func groupClosurePairs(ctx context.Context, db *sql.DB, relation string, n int) [][2]int {
+	rs, err := db.QueryContext(ctx, `SELECT resource_id, user_id FROM user_resource_permissions WHERE relation = $1 LIMIT $2`, relation, n)
+	if err != nil {
+		log.Fatalf("[postgres] group_closure: read %s pairs failed: %v", relation, err)
+	}
+	defer rs.Close()
+	var pairs [][2]int
+	for rs.Next() {
+		var p [2]int
+		if err := rs.Scan(&p[0], &p[1]); err != nil {
+			log.Fatalf("[postgres] group_closure: scan %s pair failed: %v", relation, err)
+		}
+		pairs = append(pairs, p)
+	}
+	if len(pairs) == 0 {
+		log.Fatalf("[postgres] group_closure: no %s rows in user_resource_permissions; run load-data first", relation)
+	}
+	return pairs
+}
+
+// runGroupClosureQuery times run iters times and logs the DONE line of
+// variant and query. It returns the rows of the last successful iteration,
+// or for checks the number of granted iterations.
+func runGroupClosureQuery(variant, query string, iters int, check bool, run func(ctx context.Context, i int) (int, error)) int {
+	durations := make([]time.Duration, 0, iters)
+	rows := 0
+	errs := utils.NewErrorTally()
+	for i := range iters {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		start := time.Now()
+		n, err := run(ctx, i)
+		dur := time.Since(start)
+		cancel()
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[postgres] [group_closure] variant=%s query=%s iter=%d failed class=%s: %v", variant, query, i, class, err)
+			continue
+		}
+		durations = append(durations, dur)
+		if check {
+			rows += n
+		} else {
+			rows = n
+		}
+	}
+	log.Printf("[postgres] [group_closure] variant=%s query=%s DONE: iters=%d rows=%d %s", variant, query, len(durations), rows, utils.LatencySummary(durations))
+	log.Printf("[postgres] [group_closure] variant=%s query=%s ERRORS: %s", variant, query, errs.Summary(iters))
+	return rows
+}
+
+// runGroupEdgeMaintenance removes edge and adds it back iters times under
+// variant, timing each step with its maintenance as remove_edge and add_edge;
+// rows are the derived rows the last step wrote. The hierarchy ends as it
+// started, unless a step fails.
+func runGroupEdgeMaintenance(db *sql.DB, variant string, edge groupEdge, iters int) {
+	for _, step := range []struct {
+		name string
+		add  bool
+	}{{"remove_edge", false}, {"add_edge", true}} {
+		durations := make([]time.Duration, 0, iters)
+		rows := int64(0)
+		errs := utils.NewErrorTally()
+		for i := range iters {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			var n int64
+			var dur time.Duration
+			var err error
+			if step.add {
+				// untimed: remove the edge the timed step adds back
+				_, err = editGroupEdge(ctx, db, variant, edge, false)
+			}
+			if err == nil {
+				start := time.Now()
+				n, err = editGroupEdge(ctx, db, variant, edge, step.add)
+				dur = time.Since(start)
+			}
+			if err == nil && !step.add {
+				// untimed: restore the edge the timed step removed
+				_, err = editGroupEdge(ctx, db, variant, edge, true)
+			}
+			cancel()
+			if err != nil {
+				class := errs.Record(err)
+				log.Printf("[postgres] [group_closure] variant=%s query=%s iter=%d failed class=%s: %v", variant, step.name, i, class, err)
+				continue
+			}
+			durations = append(durations, dur)
+			rows = n
+		}
+		log.Printf("[postgres] [group_closure] variant=%s query=%s DONE: iters=%d rows=%d %s", variant, step.name, len(durations), rows, utils.LatencySummary(durations))
+		log.Printf("[postgres] [group_closure] variant=%s query=%s ERRORS: %s", variant, step.name, errs.Summary(iters))
+	}
+}
@@ -4,22 +4,19 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"encoding/csv"
-	"io"
+	"iter"
 	"log"
-	"os"
-	"path/filepath"
 	"time"
 
 	pq "github.com/lib/pq"
 
+	"test-tls/dataset"
 	"test-tls/infrastructure"
+	"test-tls/utils"
 )
 
-const dataDir = "data"
-
 // PostgresCreateData loads the deterministic relational ACL dataset generated by
-// cmd/csv/load_data.go into PostgreSQL tables defined in schemas.sql.
+// cmd/csv/load_data.go into PostgreSQL tables defined in migrations/.
 //
 // Tables and CSV files:
 //
@@ -28,8 +25,9 @@ const dataDir = "data"
 //	groups.csv:            group_id,org_id
 //	org_memberships.csv:   org_id,user_id,role
 //	group_memberships.csv: group_id,user_id,role
-//	resources.csv:         resource_id,org_id
-//	resource_acl.csv:      resource_id,subject_type,subject_id,relation
+//	resources.csv:         resource_id,org_id[,created_at,updated_at]
+//	resource_acl.csv:      resource_id,subject_type,subject_id,relation[,valid_from,valid_until,created_at]
+//	resource_bans.csv:     resource_id,user_id
 func PostgresCreateData() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
@@ -42,7 +40,8 @@ func PostgresCreateData() {
 	startAll := time.Now()
 	total := 0
 
-	log.Printf("[postgres] == Starting Postgres data import from CSV in %q ==", dataDir)
+	log.Printf("[postgres] == Starting Postgres data import from CSV in %q ==", utils.DataDir())
+	utils.MarkDatasetLoading("postgres", datasetStore{db})
 
 	loadOrganizations(db, &total)
 	loadUsers(db, &total)
@@ -52,599 +51,151 @@ func PostgresCreateData() {
 	loadGroupHierarchy(db, &total)
 	loadResources(db, &total)
 	loadResourceACL(db, &total)
+	loadResourceBans(db, &total)
 
 	// Refresh materialized view to precompute resolved user permissions
 	refreshUserResourcePermissions(db)
 
+	// Build the nested group closure (POSTGRES_GROUP_RESOLUTION=closure)
+	loadGroupClosure(db)
+	utils.RecordDataset("postgres", datasetStore{db})
+
 	elapsed := time.Since(startAll).Truncate(time.Millisecond)
 	log.Printf("[postgres] Postgres data import DONE: totalRows=%d elapsed=%s", total, elapsed)
 }
 
-// =========================
-// CSV helper
-// =========================
-
-func openCSV(name string) (*csv.Reader, *os.File) {
-	full := filepath.Join(dataDir, name)
-	f, err := os.Open(full)
-	if err != nil {
-		// keep message similar to authzed loader
-		if os.IsNotExist(err) {
-			log.Printf("[postgres] %s not found, skipping", full)
-			return nil, nil
-		}
-		log.Fatalf("[postgres] open %s: %v", full, err)
-	}
-	r := csv.NewReader(f)
-	return r, f
-}
-
-// =========================
-// Load functions per table
-// =========================
-
-func loadOrganizations(db *sql.DB, total *int) {
-	r, f := openCSV("organizations.csv")
-	if r == nil {
-		log.Printf("[postgres] organizations.csv not found, skipping")
-		return
-	}
-	defer f.Close()
-
-	start := time.Now()
-
-	// organizations.csv: org_id
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[postgres] organizations: read header failed: %v", err)
-	}
+// copyTable bulk-loads rows into table: COPY into a temp staging table
+// (stagingDDL, dropped on commit), then upsert from it in the same
+// transaction, so a reload updates or skips existing rows.
+func copyTable[T any](db *sql.DB, total *int, table, stagingDDL string, columns []string, rows iter.Seq[T], values func(T) []any, upsert string) {
+	progress := dataset.NewProgress("postgres", table, total)
 
 	tx, err := db.Begin()
 	if err != nil {
-		log.Fatalf("[postgres] organizations: begin tx failed: %v", err)
+		log.Fatalf("[postgres] %s: begin tx failed: %v", table, err)
 	}
 	defer tx.Rollback()
 
-	// Use temp table for bulk COPY then upsert
-	if _, err := tx.Exec(`CREATE TEMP TABLE staging_organizations (org_id INTEGER) ON COMMIT DROP`); err != nil {
-		log.Fatalf("[postgres] organizations: create staging table failed: %v", err)
+	if _, err := tx.Exec(stagingDDL); err != nil {
+		log.Fatalf("[postgres] %s: create staging table failed: %v", table, err)
 	}
-
-	stmt, err := tx.Prepare(pq.CopyIn("staging_organizations", "org_id"))
+	stmt, err := tx.Prepare(pq.CopyIn("staging_"+table, columns...))
 	if err != nil {
-		log.Fatalf("[postgres] organizations: prepare CopyIn failed: %v", err)
+		log.Fatalf("[postgres] %s: prepare CopyIn failed: %v", table, err)
 	}
 
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[postgres] organizations: read row failed: %v", err)
-		}
-		if len(rec) < 1 {
-			log.Fatalf("[postgres] organizations: invalid row: %#v", rec)
-		}
-
-		if _, err := stmt.Exec(rec[0]); err != nil {
-			log.Fatalf("[postgres] organizations: CopyIn exec failed: %v", err)
-		}
-		count++
-		if count%10000 == 0 {
-			log.Printf("[postgres] Loaded organizations progress: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
+	for row := range rows {
+		if _, err := stmt.Exec(values(row)...); err != nil {
+			log.Fatalf("[postgres] %s: CopyIn exec failed: %v", table, err)
 		}
+		progress.Add(1)
 	}
 
 	if _, err := stmt.Exec(); err != nil {
-		log.Fatalf("[postgres] organizations: final CopyIn exec failed: %v", err)
+		log.Fatalf("[postgres] %s: final CopyIn exec failed: %v", table, err)
 	}
 	if err := stmt.Close(); err != nil {
-		log.Fatalf("[postgres] organizations: close stmt failed: %v", err)
+		log.Fatalf("[postgres] %s: close stmt failed: %v", table, err)
 	}
-
-	// Upsert: insert new rows, update nothing on conflict (PK already exists)
-	if _, err := tx.Exec(`INSERT INTO organizations (org_id) SELECT org_id FROM staging_organizations ON CONFLICT (org_id) DO NOTHING`); err != nil {
-		log.Fatalf("[postgres] organizations: upsert failed: %v", err)
+	if _, err := tx.Exec(upsert); err != nil {
+		log.Fatalf("[postgres] %s: upsert failed: %v", table, err)
 	}
-
 	if err := tx.Commit(); err != nil {
-		log.Fatalf("[postgres] organizations: commit failed: %v", err)
+		log.Fatalf("[postgres] %s: commit failed: %v", table, err)
 	}
-
-	*total += count
-	log.Printf("[postgres] Loaded organizations: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
+	progress.Done()
 }
 
-func loadUsers(db *sql.DB, total *int) {
-	r, f := openCSV("users.csv")
-	if r == nil {
-		log.Printf("[postgres] users.csv not found, skipping")
-		return
-	}
-	defer f.Close()
-	start := time.Now()
-	// users.csv: user_id,org_id
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[postgres] users: read header failed: %v", err)
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		log.Fatalf("[postgres] users: begin tx failed: %v", err)
-	}
-	defer tx.Rollback()
-
-	if _, err := tx.Exec(`CREATE TEMP TABLE staging_users (user_id INTEGER, org_id INTEGER) ON COMMIT DROP`); err != nil {
-		log.Fatalf("[postgres] users: create staging table failed: %v", err)
-	}
-
-	stmt, err := tx.Prepare(pq.CopyIn("staging_users", "user_id", "org_id"))
-	if err != nil {
-		log.Fatalf("[postgres] users: prepare CopyIn failed: %v", err)
-	}
-
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[postgres] users: read row failed: %v", err)
-		}
-		if len(rec) < 2 {
-			log.Fatalf("[postgres] users: invalid row: %#v", rec)
-		}
-
-		if _, err := stmt.Exec(rec[0], rec[1]); err != nil {
-			log.Fatalf("[postgres] users: CopyIn exec failed: %v", err)
-		}
-		count++
-		if count%10000 == 0 {
-			log.Printf("[postgres] Loaded users progress: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-
-	if _, err := stmt.Exec(); err != nil {
-		log.Fatalf("[postgres] users: final CopyIn exec failed: %v", err)
-	}
-	if err := stmt.Close(); err != nil {
-		log.Fatalf("[postgres] users: close stmt failed: %v", err)
-	}
-
-	// Upsert: overwrite org_id if user already exists
-	if _, err := tx.Exec(`INSERT INTO users (user_id, org_id) SELECT user_id, org_id FROM staging_users ON CONFLICT (user_id) DO UPDATE SET org_id = EXCLUDED.org_id`); err != nil {
-		log.Fatalf("[postgres] users: upsert failed: %v", err)
-	}
+// =========================
+// Load functions per table
+// =========================
 
-	if err := tx.Commit(); err != nil {
-		log.Fatalf("[postgres] users: commit failed: %v", err)
-	}
+func loadOrganizations(db *sql.DB, total *int) {
+	copyTable(db, total, "organizations",
+		`CREATE TEMP TABLE staging_organizations (org_id INTEGER) ON COMMIT DROP`,
+		[]string{"org_id"},
+		dataset.Organizations(),
+		func(o dataset.Organization) []any { return []any{o.ID.N} },
+		// Upsert: insert new rows, update nothing on conflict (PK already exists)
+		`INSERT INTO organizations (org_id) SELECT org_id FROM staging_organizations ON CONFLICT (org_id) DO NOTHING`)
+}
 
-	*total += count
-	log.Printf("[postgres] Loaded users: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
+func loadUsers(db *sql.DB, total *int) {
+	copyTable(db, total, "users",
+		`CREATE TEMP TABLE staging_users (user_id INTEGER, org_id INTEGER) ON COMMIT DROP`,
+		[]string{"user_id", "org_id"},
+		dataset.Users(),
+		func(u dataset.User) []any { return []any{u.ID.N, u.Org.N} },
+		// Upsert: overwrite org_id if user already exists
+		`INSERT INTO users (user_id, org_id) SELECT user_id, org_id FROM staging_users ON CONFLICT (user_id) DO UPDATE SET org_id = EXCLUDED.org_id`)
 }
 
 func loadGroups(db *sql.DB, total *int) {
-	r, f := openCSV("groups.csv")
-	if r == nil {
-		log.Printf("[postgres] groups.csv not found, skipping")
-		return
-	}
-	defer f.Close()
-	start := time.Now()
-	// groups.csv: group_id,org_id
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[postgres] groups: read header failed: %v", err)
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		log.Fatalf("[postgres] groups: begin tx failed: %v", err)
-	}
-	defer tx.Rollback()
-
-	if _, err := tx.Exec(`CREATE TEMP TABLE staging_groups (group_id INTEGER, org_id INTEGER) ON COMMIT DROP`); err != nil {
-		log.Fatalf("[postgres] groups: create staging table failed: %v", err)
-	}
-
-	stmt, err := tx.Prepare(pq.CopyIn("staging_groups", "group_id", "org_id"))
-	if err != nil {
-		log.Fatalf("[postgres] groups: prepare CopyIn failed: %v", err)
-	}
-
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[postgres] groups: read row failed: %v", err)
-		}
-		if len(rec) < 2 {
-			log.Fatalf("[postgres] groups: invalid row: %#v", rec)
-		}
-
-		if _, err := stmt.Exec(rec[0], rec[1]); err != nil {
-			log.Fatalf("[postgres] groups: CopyIn exec failed: %v", err)
-		}
-		count++
-		if count%10000 == 0 {
-			log.Printf("[postgres] Loaded groups progress: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-
-	if _, err := stmt.Exec(); err != nil {
-		log.Fatalf("[postgres] groups: final CopyIn exec failed: %v", err)
-	}
-	if err := stmt.Close(); err != nil {
-		log.Fatalf("[postgres] groups: close stmt failed: %v", err)
-	}
-
-	// Upsert: overwrite org_id if group already exists
-	if _, err := tx.Exec(`INSERT INTO groups (group_id, org_id) SELECT group_id, org_id FROM staging_groups ON CONFLICT (group_id) DO UPDATE SET org_id = EXCLUDED.org_id`); err != nil {
-		log.Fatalf("[postgres] groups: upsert failed: %v", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		log.Fatalf("[postgres] groups: commit failed: %v", err)
-	}
-
-	*total += count
-	log.Printf("[postgres] Loaded groups -> org.member_group: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
+	copyTable(db, total, "groups",
+		`CREATE TEMP TABLE staging_groups (group_id INTEGER, org_id INTEGER) ON COMMIT DROP`,
+		[]string{"group_id", "org_id"},
+		dataset.Groups(),
+		func(g dataset.Group) []any { return []any{g.ID.N, g.Org.N} },
+		`INSERT INTO groups (group_id, org_id) SELECT group_id, org_id FROM staging_groups ON CONFLICT (group_id) DO UPDATE SET org_id = EXCLUDED.org_id`)
 }
 
 func loadOrgMemberships(db *sql.DB, total *int) {
-	r, f := openCSV("org_memberships.csv")
-	if r == nil {
-		log.Printf("[postgres] org_memberships.csv not found, skipping")
-		return
-	}
-	defer f.Close()
-	start := time.Now()
-	// org_memberships.csv: org_id,user_id,role
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[postgres] org_memberships: read header failed: %v", err)
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		log.Fatalf("[postgres] org_memberships: begin tx failed: %v", err)
-	}
-	defer tx.Rollback()
-
-	if _, err := tx.Exec(`CREATE TEMP TABLE staging_org_memberships (org_id INTEGER, user_id INTEGER, role TEXT) ON COMMIT DROP`); err != nil {
-		log.Fatalf("[postgres] org_memberships: create staging table failed: %v", err)
-	}
-
-	stmt, err := tx.Prepare(pq.CopyIn("staging_org_memberships", "org_id", "user_id", "role"))
-	if err != nil {
-		log.Fatalf("[postgres] org_memberships: prepare CopyIn failed: %v", err)
-	}
-
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[postgres] org_memberships: read row failed: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[postgres] org_memberships: invalid row: %#v", rec)
-		}
-
-		if _, err := stmt.Exec(rec[0], rec[1], rec[2]); err != nil {
-			log.Fatalf("[postgres] org_memberships: CopyIn exec failed: %v", err)
-		}
-		count++
-		if count%10000 == 0 {
-			log.Printf("[postgres] Loaded org_memberships progress: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-
-	if _, err := stmt.Exec(); err != nil {
-		log.Fatalf("[postgres] org_memberships: final CopyIn exec failed: %v", err)
-	}
-	if err := stmt.Close(); err != nil {
-		log.Fatalf("[postgres] org_memberships: close stmt failed: %v", err)
-	}
-
-	// Upsert: overwrite role if (org_id, user_id) already exists
-	if _, err := tx.Exec(`INSERT INTO org_memberships (org_id, user_id, role) SELECT org_id, user_id, role FROM staging_org_memberships ON CONFLICT (org_id, user_id) DO UPDATE SET role = EXCLUDED.role`); err != nil {
-		log.Fatalf("[postgres] org_memberships: upsert failed: %v", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		log.Fatalf("[postgres] org_memberships: commit failed: %v", err)
-	}
-
-	*total += count
-	log.Printf("[postgres] Loaded org_memberships: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
+	copyTable(db, total, "org_memberships",
+		`CREATE TEMP TABLE staging_org_memberships (org_id INTEGER, user_id INTEGER, role TEXT) ON COMMIT DROP`,
+		[]string{"org_id", "user_id", "role"},
+		dataset.OrgMemberships(),
+		func(m dataset.OrgMembership) []any { return []any{m.Org.N, m.User.N, m.Role} },
+		`INSERT INTO org_memberships (org_id, user_id, role) SELECT org_id, user_id, role FROM staging_org_memberships ON CONFLICT (org_id, user_id) DO UPDATE SET role = EXCLUDED.role`)
 }
 
 func loadGroupMemberships(db *sql.DB, total *int) {
-	r, f := openCSV("group_memberships.csv")
-	if r == nil {
-		log.Printf("[postgres] group_memberships.csv not found, skipping")
-		return
-	}
-	defer f.Close()
-	start := time.Now()
-	// group_memberships.csv: group_id,user_id,role
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[postgres] group_memberships: read header failed: %v", err)
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		log.Fatalf("[postgres] group_memberships: begin tx failed: %v", err)
-	}
-	defer tx.Rollback()
-
-	if _, err := tx.Exec(`CREATE TEMP TABLE staging_group_memberships (group_id INTEGER, user_id INTEGER, role TEXT) ON COMMIT DROP`); err != nil {
-		log.Fatalf("[postgres] group_memberships: create staging table failed: %v", err)
-	}
-
-	stmt, err := tx.Prepare(pq.CopyIn("staging_group_memberships", "group_id", "user_id", "role"))
-	if err != nil {
-		log.Fatalf("[postgres] group_memberships: prepare CopyIn failed: %v", err)
-	}
-
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[postgres] group_memberships: read row failed: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[postgres] group_memberships: invalid row: %#v", rec)
-		}
-
-		if _, err := stmt.Exec(rec[0], rec[1], rec[2]); err != nil {
-			log.Fatalf("[postgres] group_memberships: CopyIn exec failed: %v", err)
-		}
-		count++
-		if count%10000 == 0 {
-			log.Printf("[postgres] Loaded group_memberships progress: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-
-	if _, err := stmt.Exec(); err != nil {
-		log.Fatalf("[postgres] group_memberships: final CopyIn exec failed: %v", err)
-	}
-	if err := stmt.Close(); err != nil {
-		log.Fatalf("[postgres] group_memberships: close stmt failed: %v", err)
-	}
-
-	// Upsert: overwrite role if (group_id, user_id) already exists
-	if _, err := tx.Exec(`INSERT INTO group_memberships (group_id, user_id, role) SELECT group_id, user_id, role FROM staging_group_memberships ON CONFLICT (group_id, user_id) DO UPDATE SET role = EXCLUDED.role`); err != nil {
-		log.Fatalf("[postgres] group_memberships: upsert failed: %v", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		log.Fatalf("[postgres] group_memberships: commit failed: %v", err)
-	}
-
-	*total += count
-	log.Printf("[postgres] Loaded group_memberships: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
+	copyTable(db, total, "group_memberships",
+		`CREATE TEMP TABLE staging_group_memberships (group_id INTEGER, user_id INTEGER, role TEXT) ON COMMIT DROP`,
+		[]string{"group_id", "user_id", "role"},
+		dataset.GroupMemberships(),
+		func(m dataset.GroupMembership) []any { return []any{m.Group.N, m.User.N, m.Role} },
+		`INSERT INTO group_memberships (group_id, user_id, role) SELECT group_id, user_id, role FROM staging_group_memberships ON CONFLICT (group_id, user_id) DO UPDATE SET role = EXCLUDED.role`)
 }
 
-// loadGroupHierarchy loads parent-child group edges from CSV
 func loadGroupHierarchy(db *sql.DB, total *int) {
-	r, f := openCSV("group_hierarchy.csv")
-	if r == nil {
-		log.Printf("[postgres] group_hierarchy.csv not found, skipping nested groups")
-		return
-	}
-	defer f.Close()
-	start := time.Now()
-	// header: parent_group_id,child_group_id,relation
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[postgres] group_hierarchy: read header failed: %v", err)
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		log.Fatalf("[postgres] group_hierarchy: begin tx failed: %v", err)
-	}
-	defer tx.Rollback()
-
-	if _, err := tx.Exec(`CREATE TEMP TABLE staging_group_hierarchy (parent_group_id INTEGER, child_group_id INTEGER, relation TEXT) ON COMMIT DROP`); err != nil {
-		log.Fatalf("[postgres] group_hierarchy: create staging table failed: %v", err)
-	}
-
-	stmt, err := tx.Prepare(pq.CopyIn("staging_group_hierarchy", "parent_group_id", "child_group_id", "relation"))
-	if err != nil {
-		log.Fatalf("[postgres] group_hierarchy: prepare CopyIn failed: %v", err)
-	}
-
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[postgres] group_hierarchy: read row failed: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[postgres] group_hierarchy: invalid row: %#v", rec)
-		}
-
-		if _, err := stmt.Exec(rec[0], rec[1], rec[2]); err != nil {
-			log.Fatalf("[postgres] group_hierarchy: CopyIn exec failed: %v", err)
-		}
-		count++
-		if count%10000 == 0 {
-			log.Printf("[postgres] Loaded group_hierarchy progress: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-
-	if _, err := stmt.Exec(); err != nil {
-		log.Fatalf("[postgres] group_hierarchy: final CopyIn exec failed: %v", err)
-	}
-	if err := stmt.Close(); err != nil {
-		log.Fatalf("[postgres] group_hierarchy: close stmt failed: %v", err)
-	}
-
-	// Upsert: ignore duplicates (composite PK)
-	if _, err := tx.Exec(`INSERT INTO group_hierarchy (parent_group_id, child_group_id, relation) SELECT parent_group_id, child_group_id, relation FROM staging_group_hierarchy ON CONFLICT (parent_group_id, child_group_id, relation) DO NOTHING`); err != nil {
-		log.Fatalf("[postgres] group_hierarchy: upsert failed: %v", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		log.Fatalf("[postgres] group_hierarchy: commit failed: %v", err)
-	}
-
-	*total += count
-	log.Printf("[postgres] Loaded group_hierarchy: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
+	copyTable(db, total, "group_hierarchy",
+		`CREATE TEMP TABLE staging_group_hierarchy (parent_group_id INTEGER, child_group_id INTEGER, relation TEXT) ON COMMIT DROP`,
+		[]string{"parent_group_id", "child_group_id", "relation"},
+		dataset.GroupHierarchy(),
+		func(e dataset.GroupEdge) []any { return []any{e.Parent.N, e.Child.N, e.Relation} },
+		`INSERT INTO group_hierarchy (parent_group_id, child_group_id, relation) SELECT parent_group_id, child_group_id, relation FROM staging_group_hierarchy ON CONFLICT (parent_group_id, child_group_id, relation) DO NOTHING`)
 }
 
 func loadResources(db *sql.DB, total *int) {
-	r, f := openCSV("resources.csv")
-	if r == nil {
-		log.Printf("[postgres] resources.csv not found, skipping")
-		return
-	}
-	defer f.Close()
-	start := time.Now()
-	// resources.csv: resource_id,org_id
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[postgres] resources: read header failed: %v", err)
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		log.Fatalf("[postgres] resources: begin tx failed: %v", err)
-	}
-	defer tx.Rollback()
-
-	if _, err := tx.Exec(`CREATE TEMP TABLE staging_resources (resource_id INTEGER, org_id INTEGER) ON COMMIT DROP`); err != nil {
-		log.Fatalf("[postgres] resources: create staging table failed: %v", err)
-	}
-
-	stmt, err := tx.Prepare(pq.CopyIn("staging_resources", "resource_id", "org_id"))
-	if err != nil {
-		log.Fatalf("[postgres] resources: prepare CopyIn failed: %v", err)
-	}
-
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[postgres] resources: read row failed: %v", err)
-		}
-		if len(rec) < 2 {
-			log.Fatalf("[postgres] resources: invalid row: %#v", rec)
-		}
-
-		if _, err := stmt.Exec(rec[0], rec[1]); err != nil {
-			log.Fatalf("[postgres] resources: CopyIn exec failed: %v", err)
-		}
-		count++
-		if count%10000 == 0 {
-			log.Printf("[postgres] Loaded resources -> resource.org: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-
-	if _, err := stmt.Exec(); err != nil {
-		log.Fatalf("[postgres] resources: final CopyIn exec failed: %v", err)
-	}
-	if err := stmt.Close(); err != nil {
-		log.Fatalf("[postgres] resources: close stmt failed: %v", err)
-	}
-
-	// Upsert: overwrite org_id if resource already exists
-	if _, err := tx.Exec(`INSERT INTO resources (resource_id, org_id) SELECT resource_id, org_id FROM staging_resources ON CONFLICT (resource_id) DO UPDATE SET org_id = EXCLUDED.org_id`); err != nil {
-		log.Fatalf("[postgres] resources: upsert failed: %v", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		log.Fatalf("[postgres] resources: commit failed: %v", err)
-	}
-
-	*total += count
-	log.Printf("[postgres] Loaded resources -> resource.org: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
+	copyTable(db, total, "resources",
+		`CREATE TEMP TABLE staging_resources (resource_id INTEGER, org_id INTEGER, created_at TIMESTAMPTZ, updated_at TIMESTAMPTZ) ON COMMIT DROP`,
+		[]string{"resource_id", "org_id", "created_at", "updated_at"},
+		dataset.Resources(),
+		func(r dataset.Resource) []any { return []any{r.ID.N, r.Org.N, r.CreatedAt, r.UpdatedAt} },
+		`INSERT INTO resources (resource_id, org_id, created_at, updated_at) SELECT resource_id, org_id, created_at, updated_at FROM staging_resources ON CONFLICT (resource_id) DO UPDATE SET org_id = EXCLUDED.org_id, created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at`)
 }
 
 func loadResourceACL(db *sql.DB, total *int) {
-	r, f := openCSV("resource_acl.csv")
-	if r == nil {
-		log.Printf("[postgres] resource_acl.csv not found, skipping")
-		return
-	}
-	defer f.Close()
-	start := time.Now()
-	// resource_acl.csv: resource_id,subject_type,subject_id,relation
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[postgres] resource_acl: read header failed: %v", err)
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		log.Fatalf("[postgres] resource_acl: begin tx failed: %v", err)
-	}
-	defer tx.Rollback()
-
-	if _, err := tx.Exec(`CREATE TEMP TABLE staging_resource_acl (resource_id INTEGER, subject_type TEXT, subject_id INTEGER, relation TEXT) ON COMMIT DROP`); err != nil {
-		log.Fatalf("[postgres] resource_acl: create staging table failed: %v", err)
-	}
-
-	stmt, err := tx.Prepare(pq.CopyIn("staging_resource_acl", "resource_id", "subject_type", "subject_id", "relation"))
-	if err != nil {
-		log.Fatalf("[postgres] resource_acl: prepare CopyIn failed: %v", err)
-	}
-
-	count := 0
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[postgres] resource_acl: read row failed: %v", err)
-		}
-		if len(rec) < 4 {
-			log.Fatalf("[postgres] resource_acl: invalid row: %#v", rec)
-		}
-
-		if _, err := stmt.Exec(rec[0], rec[1], rec[2], rec[3]); err != nil {
-			log.Fatalf("[postgres] resource_acl: CopyIn exec failed: %v", err)
-		}
-		count++
-		if count%10000 == 0 {
-			log.Printf("[postgres] Loaded resource_acl progress: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
-		}
-	}
-
-	if _, err := stmt.Exec(); err != nil {
-		log.Fatalf("[postgres] resource_acl: final CopyIn exec failed: %v", err)
-	}
-	if err := stmt.Close(); err != nil {
-		log.Fatalf("[postgres] resource_acl: close stmt failed: %v", err)
-	}
-
-	// Upsert: ignore duplicates (composite PK)
-	if _, err := tx.Exec(`INSERT INTO resource_acl (resource_id, subject_type, subject_id, relation) SELECT resource_id, subject_type, subject_id, relation FROM staging_resource_acl ON CONFLICT (resource_id, subject_type, subject_id, relation) DO NOTHING`); err != nil {
-		log.Fatalf("[postgres] resource_acl: upsert failed: %v", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		log.Fatalf("[postgres] resource_acl: commit failed: %v", err)
-	}
+	copyTable(db, total, "resource_acl",
+		`CREATE TEMP TABLE staging_resource_acl (resource_id INTEGER, subject_type TEXT, subject_id INTEGER, relation TEXT, valid_from TIMESTAMPTZ, valid_until TIMESTAMPTZ, created_at TIMESTAMPTZ) ON COMMIT DROP`,
+		[]string{"resource_id", "subject_type", "subject_id", "relation", "valid_from", "valid_until", "created_at"},
+		dataset.ResourceACL(),
+		func(a dataset.ACL) []any {
+			return []any{a.Resource.N, a.SubjectType, a.Subject.N, a.Relation, a.Window.From, a.Window.Until, a.CreatedAt}
+		},
+		// Upsert: ignore duplicates (composite PK)
+		`INSERT INTO resource_acl (resource_id, subject_type, subject_id, relation, valid_from, valid_until, created_at) SELECT resource_id, subject_type, subject_id, relation, valid_from, valid_until, created_at FROM staging_resource_acl ON CONFLICT (resource_id, subject_type, subject_id, relation) DO NOTHING`)
+}
 
-	*total += count
-	log.Printf("[postgres] Loaded resource_acl: %d rows (cumulative=%d) elapsed=%s", count, *total, time.Since(start).Truncate(time.Millisecond))
+// loadResourceBans loads the denials; they stay out of
+// user_resource_permissions (see migrations/0008_resource_bans.sql).
+func loadResourceBans(db *sql.DB, total *int) {
+	copyTable(db, total, "resource_bans",
+		`CREATE TEMP TABLE staging_resource_bans (resource_id INTEGER, user_id INTEGER) ON COMMIT DROP`,
+		[]string{"resource_id", "user_id"},
+		dataset.ResourceBans(),
+		func(b dataset.Ban) []any { return []any{b.Resource.N, b.User.N} },
+		`INSERT INTO resource_bans (resource_id, user_id) SELECT resource_id, user_id FROM staging_resource_bans ON CONFLICT (resource_id, user_id) DO NOTHING`)
 }
 
 // refreshUserResourcePermissions calls the convenience function in the DB
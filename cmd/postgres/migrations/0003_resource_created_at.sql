@@ -0,0 +1,9 @@
+-- cmd/postgres/migrations/0003_resource_created_at.sql
+-- Creation time of each resource, for the authorized listing scenario
+-- (list_recent_viewable): the newest resources a user can view, keyset-
+-- paginated on (created_at, resource_id). NULL for data loaded from a
+-- resources.csv without the column.
+ALTER TABLE resources ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_resources_created
+    ON resources (created_at DESC, resource_id DESC);
@@ -0,0 +1,151 @@
+-- cmd/postgres/migrations/0007_permission_refresh.sql
+-- Incremental maintenance of the compiled permissions, the alternative to
+-- refreshing the whole user_resource_permissions view after every change.
+-- Triggers on the normalized tables enqueue what a change touched (a
+-- resource, a group or an org) in permission_refresh_queue and NOTIFY
+-- permission_refresh; `postgres permission-refresh` listens, expands the
+-- queued entries to the resources whose permissions they may change and
+-- rewrites those resources' rows of user_resource_permissions_live.
+-- The triggers are created disabled, so load-data does not queue every row;
+-- permission-refresh enables them while it runs. See permission_refresh.go.
+
+-- The incrementally maintained copy of user_resource_permissions.
+CREATE TABLE IF NOT EXISTS user_resource_permissions_live (
+    resource_id INTEGER NOT NULL,
+    org_id      INTEGER NOT NULL,
+    user_id     INTEGER NOT NULL,
+    relation    TEXT    NOT NULL,
+    PRIMARY KEY (resource_id, user_id, relation)
+);
+
+CREATE INDEX IF NOT EXISTS idx_urp_live_user_rel_res
+    ON user_resource_permissions_live (user_id, relation, resource_id);
+
+-- kind in {'resource','group','org'}; entity_id is the id of that kind.
+CREATE TABLE IF NOT EXISTS permission_refresh_queue (
+    id          BIGSERIAL   PRIMARY KEY,
+    kind        TEXT        NOT NULL,
+    entity_id   INTEGER     NOT NULL,
+    enqueued_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp()
+);
+
+-- Trigger function: TG_ARGV[0] is the kind and TG_ARGV[1] the column holding
+-- its id. Both the old and the new row are queued, so a row moved to another
+-- resource, group or org refreshes both. pg_notify folds the identical
+-- payloads of a transaction into one notification, sent at commit.
+CREATE OR REPLACE FUNCTION enqueue_permission_refresh()
+RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+  IF TG_OP <> 'INSERT' THEN
+    INSERT INTO permission_refresh_queue (kind, entity_id)
+    VALUES (TG_ARGV[0], (to_jsonb(OLD) ->> TG_ARGV[1])::int);
+  END IF;
+  IF TG_OP <> 'DELETE' THEN
+    INSERT INTO permission_refresh_queue (kind, entity_id)
+    VALUES (TG_ARGV[0], (to_jsonb(NEW) ->> TG_ARGV[1])::int);
+  END IF;
+  PERFORM pg_notify('permission_refresh', '');
+  RETURN NULL;
+END;
+$$;
+
+-- A membership change of a group affects the resources granted to the group
+-- and to every group above it; an edge change affects those of its parent.
+CREATE TRIGGER permission_refresh_resource_acl
+    AFTER INSERT OR UPDATE OR DELETE ON resource_acl
+    FOR EACH ROW EXECUTE FUNCTION enqueue_permission_refresh('resource', 'resource_id');
+CREATE TRIGGER permission_refresh_resources
+    AFTER INSERT OR UPDATE OR DELETE ON resources
+    FOR EACH ROW EXECUTE FUNCTION enqueue_permission_refresh('resource', 'resource_id');
+CREATE TRIGGER permission_refresh_group_memberships
+    AFTER INSERT OR UPDATE OR DELETE ON group_memberships
+    FOR EACH ROW EXECUTE FUNCTION enqueue_permission_refresh('group', 'group_id');
+CREATE TRIGGER permission_refresh_group_hierarchy
+    AFTER INSERT OR UPDATE OR DELETE ON group_hierarchy
+    FOR EACH ROW EXECUTE FUNCTION enqueue_permission_refresh('group', 'parent_group_id');
+CREATE TRIGGER permission_refresh_org_memberships
+    AFTER INSERT OR UPDATE OR DELETE ON org_memberships
+    FOR EACH ROW EXECUTE FUNCTION enqueue_permission_refresh('org', 'org_id');
+
+ALTER TABLE resource_acl DISABLE TRIGGER permission_refresh_resource_acl;
+ALTER TABLE resources DISABLE TRIGGER permission_refresh_resources;
+ALTER TABLE group_memberships DISABLE TRIGGER permission_refresh_group_memberships;
+ALTER TABLE group_hierarchy DISABLE TRIGGER permission_refresh_group_hierarchy;
+ALTER TABLE org_memberships DISABLE TRIGGER permission_refresh_org_memberships;
+
+-- The resources whose permissions the queued entries (kinds[i], ids[i]) may
+-- change: queued resources, the resources granted to a queued group or any
+-- group above it, and the resources of a queued org.
+CREATE OR REPLACE FUNCTION permission_refresh_resources(kinds TEXT[], ids INTEGER[])
+RETURNS TABLE (resource_id INTEGER) LANGUAGE sql STABLE AS $$
+WITH RECURSIVE
+changed AS (
+  SELECT k AS kind, i AS id FROM unnest(kinds, ids) AS c(k, i)
+),
+up(group_id) AS (
+  SELECT id FROM changed WHERE kind = 'group'
+  UNION
+  SELECT gh.parent_group_id FROM up JOIN group_hierarchy gh ON gh.child_group_id = up.group_id
+)
+SELECT id FROM changed WHERE kind = 'resource'
+UNION
+SELECT ra.resource_id FROM up
+JOIN resource_acl ra ON ra.subject_type = 'group' AND ra.subject_id = up.group_id
+UNION
+SELECT r.resource_id FROM changed c JOIN resources r ON c.kind = 'org' AND r.org_id = c.id
+$$;
+
+-- The user_resource_permissions rows of resources res, by the definition of
+-- 0006_org_permissions.sql, walking down from the groups granted on them
+-- instead of expanding every group. The walk state is the path shape so far,
+-- as in the group_closure rederive: 'm' member_group edges only (direct
+-- members and managers view), 'mm' member_group then manager_group edges
+-- (managers view), 'g' manager_group edges only (managers manage).
+CREATE OR REPLACE FUNCTION resource_permissions(res INTEGER[])
+RETURNS TABLE (resource_id INTEGER, org_id INTEGER, user_id INTEGER, relation TEXT)
+LANGUAGE sql STABLE AS $$
+WITH RECURSIVE
+grants AS (
+  SELECT ra.resource_id, ra.subject_type, ra.subject_id,
+    CASE WHEN ra.relation LIKE 'manager%' THEN 'manager' ELSE 'viewer' END AS relation
+  FROM resource_acl ra
+  WHERE ra.resource_id = ANY(res)
+    AND ((ra.subject_type = 'user' AND ra.relation IN ('manager_user', 'viewer_user', 'manager', 'viewer'))
+      OR (ra.subject_type = 'group' AND ra.relation IN ('manager_group', 'viewer_group', 'manager', 'viewer')))
+    AND (ra.valid_from IS NULL OR ra.valid_from <= now())
+    AND (ra.valid_until IS NULL OR ra.valid_until > now())
+),
+down(resource_id, relation, group_id, state) AS (
+  SELECT g.resource_id, g.relation, g.subject_id, CASE g.relation WHEN 'manager' THEN 'g' ELSE 'm' END
+  FROM grants g WHERE g.subject_type = 'group'
+  UNION
+  SELECT d.resource_id, d.relation, gh.child_group_id,
+    CASE WHEN d.state = 'm' AND gh.relation = 'member_group' THEN 'm' WHEN d.state = 'g' THEN 'g' ELSE 'mm' END
+  FROM down d
+  JOIN group_hierarchy gh ON gh.parent_group_id = d.group_id
+  WHERE d.state = 'm' OR gh.relation = 'manager_group'
+)
+SELECT g.resource_id, r.org_id, g.subject_id, g.relation
+FROM grants g JOIN resources r ON r.resource_id = g.resource_id
+WHERE g.subject_type = 'user'
+
+UNION
+
+SELECT d.resource_id, r.org_id, gm.user_id, d.relation
+FROM down d
+JOIN group_memberships gm ON gm.group_id = d.group_id
+  AND (gm.role = 'direct_manager' OR (d.state = 'm' AND gm.role = 'direct_member'))
+JOIN resources r ON r.resource_id = d.resource_id
+
+UNION
+
+SELECT r.resource_id, r.org_id, om.user_id, 'manager'
+FROM resources r JOIN org_memberships om ON om.org_id = r.org_id
+WHERE r.resource_id = ANY(res) AND om.role = 'admin'
+
+UNION
+
+SELECT r.resource_id, r.org_id, om.user_id, 'viewer'
+FROM resources r JOIN org_memberships om ON om.org_id = r.org_id
+WHERE r.resource_id = ANY(res) AND om.role IN ('admin', 'member')
+$$;
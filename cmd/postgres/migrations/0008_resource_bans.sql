@@ -0,0 +1,15 @@
+-- cmd/postgres/migrations/0008_resource_bans.sql
+-- Denials: a user banned from a resource loses view on it whatever grants
+-- it, the banned_user exclusion of the SpiceDB schema with BENCH_DENIALS=1.
+-- The bans stay out of user_resource_permissions: with BENCH_DENIALS=1 the
+-- check and lookup queries anti-join this table, so one load serves the
+-- queries with and without denials.
+CREATE TABLE IF NOT EXISTS resource_bans (
+    resource_id INTEGER NOT NULL REFERENCES resources(resource_id),
+    user_id     INTEGER NOT NULL REFERENCES users(user_id),
+    PRIMARY KEY (resource_id, user_id)
+);
+
+-- the bans of a user, for lookups
+CREATE INDEX IF NOT EXISTS idx_resource_bans_user
+    ON resource_bans (user_id, resource_id);
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// offboardSQL deletes a user ($1) with everything referencing it, in foreign
+// key order.
+var offboardSQL = []string{
+	`DELETE FROM group_memberships WHERE user_id = $1`,
+	`DELETE FROM org_memberships WHERE user_id = $1`,
+	`DELETE FROM resource_acl WHERE subject_type = 'user' AND subject_id = $1`,
+	`DELETE FROM resource_bans WHERE user_id = $1`,
+	`DELETE FROM users WHERE user_id = $1`,
+}
+
+// userOffboarder deletes users in one transaction and refreshes
+// user_resource_permissions after the commit (utils.UserOffboarder).
+type userOffboarder struct {
+	db *sql.DB
+}
+
+func (o userOffboarder) Offboard(ctx context.Context, userID string) (int, error) {
+	id, err := ids.Parse(ids.User, userID)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	rows := int64(0)
+	for _, stmt := range offboardSQL {
+		res, err := tx.ExecContext(ctx, stmt, id)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		rows += n
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	_, err = o.db.ExecContext(ctx, `REFRESH MATERIALIZED VIEW user_resource_permissions`)
+	return int(rows), err
+}
+
+// PostgresOffboard benchmarks deleting the users of BENCH_OFFBOARD_USERS and
+// checks they hold nothing afterwards; see utils.RunOffboarding.
+func PostgresOffboard() {
+	ctx := context.Background()
+	db, cleanup, err := infrastructure.NewPostgresFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[postgres] failed to create postgres client: %v", err)
+	}
+	defer cleanup()
+
+	utils.RunOffboarding("postgres", userOffboarder{db: db}, newChecker(db))
+	log.Println("[postgres] == Postgres user offboarding DONE ==")
+}
@@ -0,0 +1,307 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lib/pq"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// refreshTriggers are the triggers of migrations/0007_permission_refresh.sql,
+// per table; they are enabled only while permission-refresh runs.
+var refreshTriggers = []struct{ table, trigger string }{
+	{"resource_acl", "permission_refresh_resource_acl"},
+	{"resources", "permission_refresh_resources"},
+	{"group_memberships", "permission_refresh_group_memberships"},
+	{"group_hierarchy", "permission_refresh_group_hierarchy"},
+	{"org_memberships", "permission_refresh_org_memberships"},
+}
+
+// claimRefreshSQL takes up to $1 queued changes, oldest first; SKIP LOCKED
+// lets several refreshers share the queue.
+const claimRefreshSQL = `DELETE FROM permission_refresh_queue WHERE id IN (
+		SELECT id FROM permission_refresh_queue ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED)
+	RETURNING kind, entity_id, enqueued_at`
+
+// permissionRefresher applies the queued changes to
+// user_resource_permissions_live: per batch, the resources the changes may
+// affect lose their rows and get them back from resource_permissions(), in
+// the transaction that takes the batch off the queue. Its counters are read
+// after listen returns.
+type permissionRefresher struct {
+	db    *sql.DB
+	batch int
+
+	lags      []time.Duration // per change: enqueued to applied
+	applies   []time.Duration // per batch
+	resources int
+	rows      int
+	errs      *utils.ErrorTally
+	attempts  int // batches applied or failed
+}
+
+// listen waits for permission_refresh notifications on a connection of pool
+// and drains the queue after each, and every poll in case one was missed,
+// until ctx is done.
+func (r *permissionRefresher) listen(ctx context.Context, pool *pgxpool.Pool, poll time.Duration) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		log.Fatalf("[postgres] [permission_refresh] acquire listener connection: %v", err)
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, `LISTEN permission_refresh`); err != nil {
+		log.Fatalf("[postgres] [permission_refresh] LISTEN: %v", err)
+	}
+	for ctx.Err() == nil {
+		r.drain(ctx)
+		wctx, cancel := context.WithTimeout(ctx, poll)
+		err := conn.Conn().WaitForNotification(wctx)
+		cancel()
+		if err != nil && ctx.Err() == nil && !errors.Is(err, context.DeadlineExceeded) {
+			log.Printf("[postgres] [permission_refresh] wait for notification: %v", err)
+			return
+		}
+	}
+}
+
+// drain applies batches until the queue is empty.
+func (r *permissionRefresher) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := r.apply(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			r.attempts++
+			class := r.errs.Record(err)
+			log.Printf("[postgres] [permission_refresh] apply failed class=%s: %v", class, err)
+			return
+		}
+		if n == 0 {
+			return
+		}
+		r.attempts++
+	}
+}
+
+// apply takes one batch off the queue and applies it, returning the changes
+// it held.
+func (r *permissionRefresher) apply(ctx context.Context) (int, error) {
+	start := time.Now()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rs, err := tx.QueryContext(ctx, claimRefreshSQL, r.batch)
+	if err != nil {
+		return 0, err
+	}
+	var kinds []string
+	var ids []int64
+	var enqueued []time.Time
+	for rs.Next() {
+		var kind string
+		var id int64
+		var at time.Time
+		if err := rs.Scan(&kind, &id, &at); err != nil {
+			rs.Close()
+			return 0, err
+		}
+		kinds, ids, enqueued = append(kinds, kind), append(ids, id), append(enqueued, at)
+	}
+	rs.Close()
+	if err := rs.Err(); err != nil {
+		return 0, err
+	}
+	if len(kinds) == 0 {
+		return 0, nil
+	}
+
+	var resources []int64
+	rs, err = tx.QueryContext(ctx, `SELECT resource_id FROM permission_refresh_resources($1, $2)`, pq.Array(kinds), pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	for rs.Next() {
+		var id int64
+		if err := rs.Scan(&id); err != nil {
+			rs.Close()
+			return 0, err
+		}
+		resources = append(resources, id)
+	}
+	rs.Close()
+	if err := rs.Err(); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_resource_permissions_live WHERE resource_id = ANY($1)`, pq.Array(resources)); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `INSERT INTO user_resource_permissions_live
+		SELECT resource_id, org_id, user_id, relation FROM resource_permissions($1)`, pq.Array(resources))
+	if err != nil {
+		return 0, err
+	}
+	rows, _ := res.RowsAffected()
+	var applied time.Time
+	if err := tx.QueryRowContext(ctx, `SELECT clock_timestamp()`).Scan(&applied); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for _, at := range enqueued {
+		r.lags = append(r.lags, applied.Sub(at))
+	}
+	r.applies = append(r.applies, time.Since(start))
+	r.resources += len(resources)
+	r.rows += int(rows)
+	return len(kinds), nil
+}
+
+// setRefreshTriggers enables or disables refreshTriggers.
+func setRefreshTriggers(ctx context.Context, db *sql.DB, enable bool) error {
+	verb := "DISABLE"
+	if enable {
+		verb = "ENABLE"
+	}
+	for _, t := range refreshTriggers {
+		if _, err := db.ExecContext(ctx, `ALTER TABLE `+t.table+` `+verb+` TRIGGER `+t.trigger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PostgresPermissionRefresh benchmarks keeping the compiled permissions up
+// to date incrementally (migrations/0007_permission_refresh.sql) against
+// refreshing the whole user_resource_permissions view. It copies the view
+// into user_resource_permissions_live, enables the queueing triggers and
+// starts a refresher that LISTENs for permission_refresh and applies the
+// queue in batches, then runs the group churn workload (utils.RunGroupChurn;
+// data/group_churn.csv is replayed when it exists) and waits for the queue
+// to drain. It logs a "[permission_refresh] variant=incremental" line for
+// query=lag (per change, from its commit to the commit of the batch that
+// applied it) and query=apply (per batch), and a variant=full query=lag line
+// timing REFRESH MATERIALIZED VIEW, the lag of every change when the view is
+// refreshed after each. Last, the live table is compared with the refreshed
+// view: rows missing from it or extra in it are logged as a mismatch (grants
+// whose window opened or closed during the run differ too).
+//
+// The triggers are disabled again on exit; the queue is left as it is.
+//
+// Env vars:
+//
+//	POSTGRES_REFRESH_BATCH      (default: 1000) changes per refresh transaction
+//	POSTGRES_REFRESH_POLL_MS    (default: 1000) drain without a notification after
+//	POSTGRES_REFRESH_DRAIN_SEC  (default: 300) wait for the queue after the churn
+//	POSTGRES_REFRESH_FULL_ITER  (default: 3) full view refreshes timed
+func PostgresPermissionRefresh() {
+	const scenario = "permission_refresh"
+	ctx := context.Background()
+	db, cleanup, err := infrastructure.NewPostgresFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[postgres] failed to create postgres client: %v", err)
+	}
+	defer cleanup()
+	pool, poolCleanup, err := infrastructure.NewPostgresPgxPoolFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[postgres] failed to create postgres pgx pool: %v", err)
+	}
+	defer poolCleanup()
+
+	batch := utils.GetEnvInt("POSTGRES_REFRESH_BATCH", 1000)
+	poll := time.Duration(utils.GetEnvInt("POSTGRES_REFRESH_POLL_MS", 1000)) * time.Millisecond
+	drainWait := time.Duration(utils.GetEnvInt("POSTGRES_REFRESH_DRAIN_SEC", 300)) * time.Second
+	fullIters := utils.GetEnvInt("POSTGRES_REFRESH_FULL_ITER", 3)
+
+	start := time.Now()
+	for _, stmt := range []string{
+		`REFRESH MATERIALIZED VIEW user_resource_permissions`,
+		`TRUNCATE user_resource_permissions_live, permission_refresh_queue`,
+		`INSERT INTO user_resource_permissions_live
+			SELECT resource_id, org_id, user_id, relation FROM user_resource_permissions`,
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			log.Fatalf("[postgres] [%s] seed user_resource_permissions_live: %v (run create-schema for migration 0007)", scenario, err)
+		}
+	}
+	log.Printf("[postgres] [%s] seeded user_resource_permissions_live in %s: batch=%d poll=%s", scenario, time.Since(start).Truncate(time.Millisecond), batch, poll)
+
+	if err := setRefreshTriggers(ctx, db, true); err != nil {
+		log.Fatalf("[postgres] [%s] enable triggers: %v", scenario, err)
+	}
+	defer func() {
+		if err := setRefreshTriggers(context.Background(), db, false); err != nil {
+			log.Printf("[postgres] [%s] warning: disable triggers: %v", scenario, err)
+		}
+	}()
+
+	r := &permissionRefresher{db: db, batch: batch, errs: utils.NewErrorTally()}
+	rctx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		r.listen(rctx, pool, poll)
+		close(done)
+	}()
+
+	utils.RunGroupChurn("postgres", groupClosureMaintainer{db: db})
+
+	deadline := time.Now().Add(drainWait)
+	for {
+		var queued int
+		if err := db.QueryRowContext(ctx, `SELECT count(*) FROM permission_refresh_queue`).Scan(&queued); err != nil {
+			log.Fatalf("[postgres] [%s] count queue: %v", scenario, err)
+		}
+		if queued == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			log.Printf("[postgres] [%s] warning: %d changes still queued after %s", scenario, queued, drainWait)
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	stop()
+	<-done
+
+	log.Printf("[postgres] [%s] variant=incremental query=lag DONE: iters=%d rows=%d %s", scenario, len(r.lags), r.rows, utils.LatencySummary(r.lags))
+	log.Printf("[postgres] [%s] variant=incremental query=apply DONE: iters=%d rows=%d %s", scenario, len(r.applies), r.resources, utils.LatencySummary(r.applies))
+	log.Printf("[postgres] [%s] ERRORS: %s", scenario, r.errs.Summary(r.attempts))
+
+	var full []time.Duration
+	for range fullIters {
+		start := time.Now()
+		if _, err := db.ExecContext(ctx, `REFRESH MATERIALIZED VIEW user_resource_permissions`); err != nil {
+			log.Fatalf("[postgres] [%s] refresh user_resource_permissions: %v", scenario, err)
+		}
+		full = append(full, time.Since(start))
+	}
+	var viewRows, missing, extra int
+	err = db.QueryRowContext(ctx, `SELECT
+		(SELECT count(*) FROM user_resource_permissions),
+		(SELECT count(*) FROM (SELECT resource_id, org_id, user_id, relation FROM user_resource_permissions
+			EXCEPT SELECT resource_id, org_id, user_id, relation FROM user_resource_permissions_live) m),
+		(SELECT count(*) FROM (SELECT resource_id, org_id, user_id, relation FROM user_resource_permissions_live
+			EXCEPT SELECT resource_id, org_id, user_id, relation FROM user_resource_permissions) e)`).Scan(&viewRows, &missing, &extra)
+	if err != nil {
+		log.Fatalf("[postgres] [%s] compare with user_resource_permissions: %v", scenario, err)
+	}
+	log.Printf("[postgres] [%s] variant=full query=lag DONE: iters=%d rows=%d %s", scenario, len(full), viewRows, utils.LatencySummary(full))
+	if missing+extra > 0 {
+		log.Printf("[postgres] [%s] MISMATCH: user_resource_permissions_live missing=%d extra=%d of %d view rows", scenario, missing, extra, viewRows)
+	} else {
+		log.Printf("[postgres] [%s] user_resource_permissions_live matches the view: rows=%d", scenario, viewRows)
+	}
+	log.Println("[postgres] == Postgres permission refresh DONE ==")
+}
@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"test-tls/authz"
+	pgauthz "test-tls/authz/postgres"
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// querier is db, or db through prepared statements when
+// BENCH_PREPARED_STATEMENTS=true, for the check and lookup queries.
+func querier(db *sql.DB) pgauthz.Querier {
+	if utils.PreparedStatements() {
+		return utils.Prepared(db)
+	}
+	return db
+}
+
+// checkPermissionPG runs the check of POSTGRES_GROUP_RESOLUTION, as timed by
+// the check_* scenarios.
+func checkPermissionPG(ctx context.Context, db *sql.DB, resourceID, userID any, relation string) (bool, error) {
+	return pgauthz.Check(ctx, querier(db), groupResolutionFromEnv(), resourceID, userID, relation)
+}
+
+// lookupResourcesPG streams the resources a user holds a relation on, from
+// the materialized view unless POSTGRES_GROUP_RESOLUTION says otherwise, as
+// timed by the lookup_resources_* scenarios.
+func lookupResourcesPG(ctx context.Context, db *sql.DB, userID, relation string, handle func(resID int)) error {
+	return pgauthz.LookupResources(ctx, querier(db), groupResolutionFromEnv(), userID, relation, handle)
+}
+
+// listRecentViewableSQL is the authorized listing of list_recent_viewable:
+// the newest resources user $1 can view, keyset-paginated after
+// (created_at, resource_id) = ($3, $4) unless $3 is NULL.
+const listRecentViewableSQL = `SELECT r.resource_id, r.created_at
+	FROM user_resource_permissions p
+	JOIN resources r ON r.resource_id = p.resource_id
+	WHERE p.user_id = $1 AND p.relation = 'viewer' AND r.created_at IS NOT NULL
+	  AND ($3::timestamptz IS NULL OR (r.created_at, r.resource_id) < ($3::timestamptz, $4::int))
+	ORDER BY r.created_at DESC, r.resource_id DESC
+	LIMIT $2`
+
+// listRecentViewablePG returns a page of listRecentViewableSQL (see utils.ListPage).
+func listRecentViewablePG(ctx context.Context, db *sql.DB, userID string, limit int, after *utils.ListedResource) ([]utils.ListedResource, error) {
+	var afterAt *time.Time
+	afterID := 0
+	if after != nil {
+		n, err := ids.Parse(ids.Resource, after.ID)
+		if err != nil {
+			return nil, err
+		}
+		afterAt, afterID = &after.CreatedAt, n
+	}
+	rows, err := db.QueryContext(ctx, listRecentViewableSQL, userID, limit, afterAt, afterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var page []utils.ListedResource
+	for rows.Next() {
+		var resID int
+		var createdAt time.Time
+		if err := rows.Scan(&resID, &createdAt); err != nil {
+			return nil, err
+		}
+		page = append(page, utils.ListedResource{ID: ids.Format(ids.Resource, resID), CreatedAt: createdAt})
+	}
+	return page, rows.Err()
+}
+
+// newChecker returns the benchmark queries over db under
+// POSTGRES_GROUP_RESOLUTION, with the permissions of BENCH_PERMISSION_RULES,
+// the denials of BENCH_DENIALS and the ids of RLP_ID_FORMAT.
+func newChecker(db *sql.DB) authz.Checker {
+	checker, err := pgauthz.NewChecker(db, pgauthz.Config{GroupResolution: groupResolutionFromEnv(), Rules: utils.PermissionRulesFromEnv(), Denials: utils.DenialsFromEnv(), IDFormat: ids.CurrentFormat()})
+	if err != nil {
+		log.Fatalf("[postgres] %v", err)
+	}
+	return checker
+}
+
+// PostgresServe serves Check/Lookup over HTTP using the benchmark queries.
+func PostgresServe() {
+	db, cleanup, err := infrastructure.NewPostgresFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[postgres] failed to create postgres client: %v", err)
+	}
+	defer cleanup()
+
+	utils.Serve("postgres", newChecker(db))
+}
+
+// PostgresReplayAudit re-runs a recorded audit file (REPLAY_AUDIT_FILE) through
+// the benchmark queries.
+func PostgresReplayAudit() {
+	db, cleanup, err := infrastructure.NewPostgresFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[postgres] failed to create postgres client: %v", err)
+	}
+	defer cleanup()
+
+	utils.ReplayAudit("postgres", newChecker(db))
+}
+
+// PostgresVerifyFixture checks the benchmark queries against the known
+// answers of the fixture dataset (see utils.VerifyFixture).
+func PostgresVerifyFixture() {
+	db, cleanup, err := infrastructure.NewPostgresFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[postgres] failed to create postgres client: %v", err)
+	}
+	defer cleanup()
+
+	utils.VerifyFixture("postgres", newChecker(db))
+}
+
+// PostgresExportPermissions writes a permission snapshot of the benchmark
+// queries for diff-permissions (see utils.ExportPermissions).
+func PostgresExportPermissions() {
+	db, cleanup, err := infrastructure.NewPostgresFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[postgres] failed to create postgres client: %v", err)
+	}
+	defer cleanup()
+
+	utils.ExportPermissions("postgres", newChecker(db))
+}
+
+// PostgresWorker replays the shard a distributed coordinator hands out and
+// streams the results back (see utils.RunWorker).
+func PostgresWorker() {
+	db, cleanup, err := infrastructure.NewPostgresFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[postgres] failed to create postgres client: %v", err)
+	}
+	defer cleanup()
+
+	utils.RunWorker("postgres", newChecker(db))
+}
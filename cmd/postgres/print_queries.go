@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	pgauthz "test-tls/authz/postgres"
+	"test-tls/ids"
+	"test-tls/utils"
+)
+
+// PostgresPrintQueries prints the statements of the read scenarios under
+// POSTGRES_GROUP_RESOLUTION with sampled parameters instead of running them
+// (see utils.PrintQueries).
+func PostgresPrintQueries() {
+	mode := groupResolutionFromEnv()
+	utils.PrintQueries("postgres", func(s utils.QuerySample) string {
+		user := utils.SampleInt(ids.User, s.User)
+		var query string
+		var args []any
+		switch s.Scenario {
+		case "check_time_bounded_direct_user":
+			query, args = timeBoundedCheckSQL, []any{utils.SampleInt(ids.Resource, s.Resource), user, s.Relation}
+		case "lookup_resources_manage_super", "lookup_resources_view_regular":
+			query, args = pgauthz.LookupStatement(mode, user, pgauthz.Relations[s.Permission])
+		default:
+			query, args = pgauthz.CheckStatement(mode, utils.SampleInt(ids.Resource, s.Resource), user, pgauthz.Relations[s.Permission])
+		}
+		return utils.RenderSQL(query, args...)
+	})
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// openReadTarget returns the database the read benchmarks run against:
+// primary itself, or with BENCH_PG_READ_TARGET=replica the standby of
+// PG_REPLICA_HOST. For the standby it also starts the replica lag monitor;
+// the returned func stops it and closes the connection.
+func openReadTarget(ctx context.Context, primary *sql.DB) (*sql.DB, func()) {
+	target := utils.GetEnvWithDefault("BENCH_PG_READ_TARGET", "primary")
+	switch target {
+	case "primary":
+		return primary, func() {}
+	case "replica":
+	default:
+		log.Fatalf("[postgres] BENCH_PG_READ_TARGET=%q: want primary or replica", target)
+	}
+
+	replica, cleanup, err := infrastructure.NewPostgresReplicaFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[postgres] failed to connect to the read replica: %v", err)
+	}
+	var inRecovery bool
+	if err := replica.QueryRowContext(ctx, `SELECT pg_is_in_recovery()`).Scan(&inRecovery); err != nil {
+		log.Fatalf("[postgres] read replica: pg_is_in_recovery failed: %v", err)
+	}
+	if !inRecovery {
+		log.Printf("[postgres] WARN: PG_REPLICA_HOST is not in recovery; reads go to another primary")
+	}
+	// Reads from a standby may be stale, like relaxed consistency elsewhere.
+	utils.LogConsistency("postgres", fmt.Sprintf("reads=replica in_recovery=%t", inRecovery))
+
+	stop := startReplicaLag(primary, replica)
+	return replica, func() {
+		stop()
+		cleanup()
+	}
+}
+
+// replicaLag is one measurement of how far the standby is behind.
+type replicaLag struct {
+	bytes  int64         // WAL written on the primary but not yet replayed
+	replay time.Duration // now() minus the commit time of the last replayed transaction
+}
+
+// measureReplicaLag compares the primary's current WAL position with the
+// standby's replay position. The replay delay grows while the primary is idle
+// even though nothing is missing, so read it together with the byte lag.
+func measureReplicaLag(ctx context.Context, primary, replica *sql.DB) (replicaLag, error) {
+	var lsn string
+	if err := primary.QueryRowContext(ctx, `SELECT pg_current_wal_lsn()::text`).Scan(&lsn); err != nil {
+		return replicaLag{}, err
+	}
+	var lag replicaLag
+	var seconds sql.NullFloat64
+	err := replica.QueryRowContext(ctx, `SELECT
+			COALESCE(pg_wal_lsn_diff($1::pg_lsn, pg_last_wal_replay_lsn()), 0)::bigint,
+			EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())::float8`, lsn).Scan(&lag.bytes, &seconds)
+	if err != nil {
+		return replicaLag{}, err
+	}
+	lag.replay = time.Duration(seconds.Float64 * float64(time.Second))
+	return lag, nil
+}
+
+// startReplicaLag logs the replica lag when the benchmark starts and every
+// BENCH_PG_REPLICA_LAG_INTERVAL_SEC (default 10) while it runs. The returned
+// func takes a last measurement and logs the REPLICA_LAG summary: samples and
+// the max and last byte and replay lag.
+func startReplicaLag(primary, replica *sql.DB) func() {
+	interval := time.Duration(utils.GetEnvInt("BENCH_PG_REPLICA_LAG_INTERVAL_SEC", 10)) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	var mu sync.Mutex
+	var samples int
+	var last, worst replicaLag
+	sample := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		lag, err := measureReplicaLag(ctx, primary, replica)
+		cancel()
+		if err != nil {
+			log.Printf("[postgres] WARN: replica lag measurement failed: %v", err)
+			return
+		}
+		log.Printf("[postgres] replica lag: bytes=%d replay=%s", lag.bytes, lag.replay.Truncate(time.Millisecond))
+		mu.Lock()
+		defer mu.Unlock()
+		samples++
+		last = lag
+		worst.bytes = max(worst.bytes, lag.bytes)
+		worst.replay = max(worst.replay, lag.replay)
+	}
+
+	sample()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				sample()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+		sample()
+		mu.Lock()
+		defer mu.Unlock()
+		log.Printf("[postgres] REPLICA_LAG: samples=%d max_bytes=%d max_replay=%s last_bytes=%d last_replay=%s",
+			samples, worst.bytes, worst.replay.Truncate(time.Millisecond), last.bytes, last.replay.Truncate(time.Millisecond))
+	}
+}
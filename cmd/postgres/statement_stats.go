@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"test-tls/utils"
+)
+
+// startStatementStats resets pg_stat_statements before the benchmark, when
+// BENCH_STATEMENT_STATS=true, and returns the snapshot to take after it. The
+// snapshot logs one STATEMENT line per query (top BENCH_STATEMENT_STATS_TOP by
+// total time) with calls, mean/total execution time and shared buffer hits and
+// reads, which benchmark/parse_all.go reports next to the client latencies.
+//
+// Needs pg_stat_statements in shared_preload_libraries (docker compose sets
+// it) and a user allowed to call pg_stat_statements_reset(); a read-only bench
+// user usually is not, in which case capture is skipped with a warning.
+func startStatementStats(db *sql.DB) func() {
+	if utils.GetEnvWithDefault("BENCH_STATEMENT_STATS", "false") != "true" {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS pg_stat_statements SCHEMA public`); err != nil {
+		log.Printf("[postgres] WARN: pg_stat_statements unavailable, skipping statement stats: %v", err)
+		return func() {}
+	}
+	if _, err := db.ExecContext(ctx, `SELECT pg_stat_statements_reset()`); err != nil {
+		log.Printf("[postgres] WARN: pg_stat_statements_reset failed, skipping statement stats: %v", err)
+		return func() {}
+	}
+	log.Printf("[postgres] pg_stat_statements reset")
+	return snapshotStatementStats(db)
+}
+
+func snapshotStatementStats(db *sql.DB) func() {
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		rows, err := db.QueryContext(ctx, `SELECT query, calls, mean_exec_time, total_exec_time, shared_blks_hit, shared_blks_read
+			FROM pg_stat_statements
+			WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+			  AND query NOT LIKE '%pg_stat_statements%'
+			ORDER BY total_exec_time DESC
+			LIMIT $1`, utils.GetEnvInt("BENCH_STATEMENT_STATS_TOP", 10))
+		if err != nil {
+			log.Printf("[postgres] WARN: pg_stat_statements snapshot failed: %v", err)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var query string
+			var calls, hit, read int64
+			var mean, total float64
+			if err := rows.Scan(&query, &calls, &mean, &total, &hit, &read); err != nil {
+				log.Printf("[postgres] WARN: pg_stat_statements scan failed: %v", err)
+				return
+			}
+			log.Printf("[postgres] STATEMENT: calls=%d mean=%.3fms total=%.1fms io=shared_hit=%d,shared_read=%d query=%s",
+				calls, mean, total, hit, read, strings.Join(strings.Fields(query), " "))
+		}
+		if err := rows.Err(); err != nil {
+			log.Printf("[postgres] WARN: pg_stat_statements snapshot failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package scylladb
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// ScylladbAnalyzeStats reports row counts, per-relation ACL cardinality and the
+// same relation fan-out summaries printed by `csv generate`, computed from the
+// loaded tables so drift between the CSV dataset and ScyllaDB is visible.
+//
+// CQL has no GROUP BY on arbitrary columns, so every table is streamed once
+// with a full scan and aggregated client side.
+func ScylladbAnalyzeStats() {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	session, cleanup, err := infrastructure.NewScyllaFromEnv(ctx)
+	cancel()
+	if err != nil {
+		log.Fatalf("[scylladb] failed to create session: %v", err)
+	}
+	defer cleanup()
+
+	start := time.Now()
+	scanCtx := context.Background()
+	stats := utils.NewRelationStats()
+
+	for _, table := range []string{"organizations", "users", "groups", "resources", "group_members_expanded", "user_resource_perms_by_user"} {
+		var n int64
+		if err := session.Query(`SELECT COUNT(*) FROM ` + table).WithContext(scanCtx).Scan(&n); err != nil {
+			log.Fatalf("[scylladb] stats: count %s failed: %v", table, err)
+		}
+		log.Printf("[scylladb] table %s: %d rows", table, n)
+	}
+
+	rows := 0
+	err = streamQuery(scanCtx, session, `SELECT org_id, user_id FROM org_memberships`, nil, func(iter *gocql.Iter) error {
+		var orgID, userID int
+		for iter.Scan(&orgID, &userID) {
+			stats.OrgToUsers[orgID]++
+			stats.UserToOrgs[userID]++
+			rows++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("[scylladb] stats: scan org_memberships failed: %v", err)
+	}
+	log.Printf("[scylladb] table org_memberships: %d rows", rows)
+
+	rows = 0
+	err = streamQuery(scanCtx, session, `SELECT group_id, user_id, role FROM group_memberships`, nil, func(iter *gocql.Iter) error {
+		var groupID, userID int
+		var role string
+		for iter.Scan(&groupID, &userID, &role) {
+			if role == "direct_manager" || role == "manager" {
+				stats.GroupToManagers[groupID]++
+			} else {
+				stats.GroupToMembers[groupID]++
+			}
+			stats.UserToGroups[userID]++
+			rows++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("[scylladb] stats: scan group_memberships failed: %v", err)
+	}
+	log.Printf("[scylladb] table group_memberships: %d rows", rows)
+
+	rows = 0
+	err = streamQuery(scanCtx, session, `SELECT parent_group_id FROM group_hierarchy`, nil, func(iter *gocql.Iter) error {
+		var parentID int
+		for iter.Scan(&parentID) {
+			stats.GroupToChildGroups[parentID]++
+			rows++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("[scylladb] stats: scan group_hierarchy failed: %v", err)
+	}
+	log.Printf("[scylladb] table group_hierarchy: %d rows", rows)
+
+	// Per-relation cardinality: edges, distinct subjects and distinct resources.
+	type aclCard struct {
+		edges     int
+		subjects  map[int]struct{}
+		resources map[int]struct{}
+	}
+	cards := make(map[string]*aclCard)
+	rows = 0
+	err = streamQuery(scanCtx, session, `SELECT resource_id, relation, subject_type, subject_id FROM resource_acl_by_resource`, nil, func(iter *gocql.Iter) error {
+		var resID, subjectID int
+		var relation, subjectType string
+		for iter.Scan(&resID, &relation, &subjectType, &subjectID) {
+			key := subjectType + "/" + relation
+			c, ok := cards[key]
+			if !ok {
+				c = &aclCard{subjects: make(map[int]struct{}), resources: make(map[int]struct{})}
+				cards[key] = c
+			}
+			c.edges++
+			c.subjects[subjectID] = struct{}{}
+			c.resources[resID] = struct{}{}
+			if subjectType == "user" {
+				stats.UserToResources[subjectID]++
+				stats.ResourceToUsers[resID]++
+			}
+			rows++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("[scylladb] stats: scan resource_acl_by_resource failed: %v", err)
+	}
+	log.Printf("[scylladb] table resource_acl_by_resource: %d rows", rows)
+
+	keys := make([]string, 0, len(cards))
+	for k := range cards {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		c := cards[k]
+		log.Printf("[scylladb] acl %s: %d edges, %d distinct subjects, %d distinct resources",
+			k, c.edges, len(c.subjects), len(c.resources))
+	}
+
+	stats.Summarize("scylladb")
+
+	log.Printf("[scylladb] analyze stats DONE elapsed=%s", time.Since(start).Truncate(time.Millisecond))
+}
@@ -2,12 +2,18 @@ package scylladb
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
-	"os"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gocql/gocql"
 
+	"test-tls/ids"
 	"test-tls/infrastructure"
 	"test-tls/utils"
 )
@@ -29,25 +35,71 @@ func ScylladbBenchmarkReads() {
 		log.Fatalf("[scylladb] failed to create session: %v", err)
 	}
 	defer cleanup()
+	utils.GuardDataset("scylladb", datasetStore{session})
+	stopAudit := utils.StartAudit("scylladb")
+	defer stopAudit()
 
 	// Log startup summary including any env-overridden lookup users.
 	start := time.Now()
-	heavyManageUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
-	regularViewUser := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	heavyManageUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
+	regularViewUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 	log.Printf("[scylladb] Running in streaming-only mode (no precollection). elapsed=%s heavyManageUser=%q regularViewUser=%q",
 		elapsed, heavyManageUser, regularViewUser)
 
 	// Run individual benchmark scenarios
-	runCheckManageDirectUser(session)          // Test direct manager_user relationships in resource_acl
-	runCheckManageOrgAdmin(session)            // Test org->admin permission paths
-	runCheckViewViaGroupMember(session)        // Test permissions via viewer_group and group membership
-	runLookupResourcesManageHeavyUser(session) // Test resource lookup for users with many manage permissions
-	runLookupResourcesViewRegularUser(session) // Test resource lookup for users with regular view permissions
+	reads := func() {
+		runCheckManageDirectUser(session)          // Test direct manager_user relationships in resource_acl
+		runCheckManageOrgAdmin(session)            // Test org->admin permission paths
+		runCheckViewViaGroupMember(session)        // Test permissions via viewer_group and group membership
+		runCheckTimeBoundedDirectUser(session)     // Test direct grants filtered by valid_from/valid_until
+		runLookupResourcesManageHeavyUser(session) // Test resource lookup for users with many manage permissions
+		runLookupResourcesViewRegularUser(session) // Test resource lookup for users with regular view permissions
+		runLookupResourcesMix(session)             // Test resource lookup for a stratified mix of users (BENCH_LOOKUPRES_MIX_USERS)
+		runLookupResourcesPercentiles(session)     // Test resource lookup for the p10/p50/p90/p99 users by grant count (BENCH_LOOKUPRES_*_PCT_USERS)
+		runViralFanIn(session)                     // Test checks and lookups on resources shared with many users directly (BENCH_VIRAL_RESOURCES)
+		runWorstCaseChecks(session)                // Test view checks whose only grant is the deepest nested group path
+		runWorkload(session)                       // Run the blended request mix of BENCH_WORKLOAD
+		runMyGroups(session)                       // List the groups of sampled users (my-groups query)
+		runAccessSummary(session)                  // Count the resources sampled users can view and manage (dashboard summary)
+		runMiddlewareOverhead(session)             // Fetch a resource with and without a view check in front (authorization middleware)
+	}
+	utils.RunMaintenancePhases("scylladb", "major_compaction", compactKeyspace(), func() {
+		utils.RunCachePhases("scylladb", nil, reads)
+	})
+	runFullExport(session) // Stream every resource_acl_by_resource row (BENCH_EXPORT_ITER)
 
 	log.Println("[scylladb] == ScyllaDB read benchmarks DONE ==")
 }
 
+// compactKeyspace runs a major compaction of the benchmarked keyspace, the
+// maintenance of BENCH_MAINTENANCE. CQL cannot start one, so it goes through
+// the REST API of one node (what `nodetool compact` calls) at SCYLLA_API_URL,
+// by default port 10000 of the first SCYLLA_HOSTS host. The other nodes are
+// not compacted.
+func compactKeyspace() utils.Maintenance {
+	host, _, _ := strings.Cut(utils.GetEnvWithDefault("SCYLLA_HOSTS", utils.GetEnvWithDefault("SCYLLA_HOST", "localhost")), ",")
+	api := utils.GetEnvWithDefault("SCYLLA_API_URL", "http://"+strings.TrimSpace(host)+":10000")
+	keyspace := utils.Namespaced(utils.GetEnvWithDefault("SCYLLA_KEYSPACE", "rlp"))
+	endpoint := strings.TrimSuffix(api, "/") + "/storage_service/keyspace_compaction/" + url.PathEscape(keyspace)
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return fmt.Errorf("POST %s: %s: %s", endpoint, resp.Status, strings.TrimSpace(string(body)))
+		}
+		return nil
+	}
+}
+
 // streamQuery streams rows from a CQL query and invokes handle for each row.
 // This helper avoids collecting results into memory, making it suitable for
 // processing large datasets without memory overhead.
@@ -81,44 +133,41 @@ func runLookupBench(session *gocql.Session, name, permission, userID string, ite
 	}
 
 	log.Printf("[scylladb] [%s] iterations=%d user=%s", name, iters, userID)
+	utils.LogScenarioConfig("scylladb", name, iters, timeout, userID)
 
 	var total time.Duration
-	var lastCount int
+	var lastCount, ok int
+	errs := utils.NewErrorTally()
 
 	for i := range iters {
 		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		start := time.Now()
 
-		// Query resources where the user has the specified permission via resource_acl_by_subject
-		query := `SELECT resource_id FROM resource_acl_by_subject
-			WHERE subject_type = 'user' AND subject_id = ? AND relation = ?`
-
 		count := 0
-		err := streamQuery(ctx, session, query, []any{userID, permission}, func(iter *gocql.Iter) error {
-			for {
-				var resID int
-				if !iter.Scan(&resID) {
-					break
-				}
-				count++
-			}
-			return nil
-		})
+		err := lookupResourcesScylla(ctx, session, userID, permission, func(int) { count++ })
 		cancel()
+		utils.AuditLookup(name, userID, permission, count, time.Since(start), err)
 		if err != nil {
-			log.Fatalf("[scylladb] [%s] query failed: %v", name, err)
+			class := errs.Record(err)
+			log.Printf("[scylladb] [%s] iter=%d LookupResources failed class=%s: %v", name, i, class, err)
+			continue
 		}
 
 		dur := time.Since(start)
 		total += dur
 		lastCount = count
+		ok++
 
 		log.Printf("[scylladb] [%s] iter=%d resources=%d duration=%s", name, i, count, dur.Truncate(time.Millisecond))
 	}
 
-	avg := time.Duration(int64(total) / int64(iters))
+	avg := time.Duration(0)
+	if ok > 0 {
+		avg = time.Duration(int64(total) / int64(ok))
+	}
 	log.Printf("[scylladb] [%s] DONE: iters=%d lastCount=%d avg=%s total=%s",
 		name, iters, lastCount, avg, total)
+	log.Printf("[scylladb] [%s] ERRORS: %s", name, errs.Summary(iters))
 }
 
 // runCheckManageDirectUser benchmarks permission checks for "manage" permission
@@ -129,9 +178,11 @@ func runCheckManageDirectUser(session *gocql.Session) {
 	iters := utils.GetEnvInt("BENCH_CHECK_DIRECT_SUPER_ITER", 1000)
 
 	log.Printf("[scylladb] [check_manage_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("scylladb", "check_manage_direct_user", iters, 2*time.Second)
 	done := 0
+	errs := utils.NewErrorTally()
 	// Hybrid behavior: if BENCH_LOOKUPRES_MANAGE_USER is set, prefer lookup for that user
-	lookupUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 
 	for done < iters {
@@ -153,19 +204,24 @@ func runCheckManageDirectUser(session *gocql.Session) {
 					}
 					streamed++
 
+					if !utils.InOrgScope(ids.Resource, resID) {
+						continue
+					}
+
 					// Check permission existence for returned resource
 					cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 					start := time.Now()
-					checkQuery := `SELECT COUNT(*) FROM resource_acl_by_resource
-						WHERE resource_id = ? AND relation = 'manager_user' AND subject_type = 'user' AND subject_id = ?`
-					var exists int
-					err := session.Query(checkQuery, resID, lookupUser).WithContext(cctx).Scan(&exists)
+					granted, err := checkPermissionScylla(cctx, session, resID, lookupUser, "manager_user")
 					ccancel()
+					utils.AuditCheck("check_manage_direct_user", resID, lookupUser, "manager_user", granted, time.Since(start), err)
 					if err != nil {
-						log.Fatalf("[scylladb] [check_manage_direct_user] permission check failed: %v", err)
+						class := errs.Record(err)
+						log.Printf("[scylladb] [check_manage_direct_user] iter=%d check failed class=%s: %v", done, class, err)
+						done++
+						continue
 					}
 					dur := time.Since(start)
-					if done%100 == 0 {
+					if utils.LogIteration(done, 100) {
 						log.Printf("[scylladb] [check_manage_direct_user] lookup iter=%d resource=%d user=%s dur=%s", done, resID, lookupUser, dur)
 					}
 					done++
@@ -174,7 +230,8 @@ func runCheckManageDirectUser(session *gocql.Session) {
 			})
 			cancel()
 			if err != nil {
-				log.Fatalf("[scylladb] [check_manage_direct_user] query failed: %v", err)
+				errs.Survive(err, iters, "[scylladb] [check_manage_direct_user] query failed: %v", err)
+				continue
 			}
 			if streamed == 0 {
 				log.Printf("[scylladb] [check_manage_direct_user] lookup-mode: no resources returned for user=%s", lookupUser)
@@ -198,19 +255,24 @@ func runCheckManageDirectUser(session *gocql.Session) {
 					break
 				}
 
+				if !utils.InOrgScope(ids.Resource, resID) {
+					continue
+				}
+
 				cctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 				start := time.Now()
-				checkQuery := `SELECT COUNT(*) FROM resource_acl_by_resource
-					WHERE resource_id = ? AND relation = 'manager_user' AND subject_type = 'user' AND subject_id = ?`
-				var exists int
-				queryErr := session.Query(checkQuery, resID, userID).WithContext(cctx).Scan(&exists)
+				granted, queryErr := checkPermissionScylla(cctx, session, resID, userID, "manager_user")
 				cancel()
+				utils.AuditCheck("check_manage_direct_user", resID, userID, "manager_user", granted, time.Since(start), queryErr)
 				if queryErr != nil {
-					log.Fatalf("[scylladb] [check_manage_direct_user] permission check failed: %v", queryErr)
+					class := errs.Record(queryErr)
+					log.Printf("[scylladb] [check_manage_direct_user] iter=%d check failed class=%s: %v", done, class, queryErr)
+					done++
+					continue
 				}
 				dur := time.Since(start)
 				// Log every 100th iteration to avoid excessive output
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[scylladb] [check_manage_direct_user] iter=%d resource=%d user=%d dur=%s", done, resID, userID, dur)
 				}
 				done++
@@ -218,10 +280,11 @@ func runCheckManageDirectUser(session *gocql.Session) {
 			return nil
 		})
 		if err != nil {
-			log.Fatalf("[scylladb] [check_manage_direct_user] streaming failed: %v", err)
+			errs.Survive(err, iters, "[scylladb] [check_manage_direct_user] streaming failed: %v", err)
 		}
 	}
 	log.Printf("[scylladb] [check_manage_direct_user] DONE: iters=%d", iters)
+	log.Printf("[scylladb] [check_manage_direct_user] ERRORS: %s", errs.Summary(done))
 }
 
 // runCheckManageOrgAdmin benchmarks permission checks for "manage" permission
@@ -232,9 +295,11 @@ func runCheckManageOrgAdmin(session *gocql.Session) {
 	iters := utils.GetEnvInt("BENCH_CHECK_ORGADMIN_ITER", 1000)
 
 	log.Printf("[scylladb] [check_manage_org_admin] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("scylladb", "check_manage_org_admin", iters, 2*time.Second)
 	done := 0
+	errs := utils.NewErrorTally()
 	// Hybrid behavior: if BENCH_LOOKUPRES_MANAGE_USER is set, prefer lookup for that user
-	lookupUser := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 
 	for done < iters {
@@ -256,19 +321,24 @@ func runCheckManageOrgAdmin(session *gocql.Session) {
 					}
 					streamed++
 
+					if !utils.InOrgScope(ids.Resource, resID) {
+						continue
+					}
+
 					// Check permission existence
 					cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 					start := time.Now()
-					checkQuery := `SELECT COUNT(*) FROM resource_acl_by_resource
-						WHERE resource_id = ? AND relation = 'manager_user' AND subject_type = 'user' AND subject_id = ?`
-					var exists int
-					err := session.Query(checkQuery, resID, lookupUser).WithContext(cctx).Scan(&exists)
+					granted, err := checkPermissionScylla(cctx, session, resID, lookupUser, "manager_user")
 					ccancel()
+					utils.AuditCheck("check_manage_org_admin", resID, lookupUser, "manager_user", granted, time.Since(start), err)
 					if err != nil {
-						log.Fatalf("[scylladb] [check_manage_org_admin] permission check failed: %v", err)
+						class := errs.Record(err)
+						log.Printf("[scylladb] [check_manage_org_admin] iter=%d check failed class=%s: %v", done, class, err)
+						done++
+						continue
 					}
 					dur := time.Since(start)
-					if done%100 == 0 {
+					if utils.LogIteration(done, 100) {
 						log.Printf("[scylladb] [check_manage_org_admin] lookup iter=%d resource=%d user=%s dur=%s", done, resID, lookupUser, dur)
 					}
 					done++
@@ -277,7 +347,8 @@ func runCheckManageOrgAdmin(session *gocql.Session) {
 			})
 			cancel()
 			if err != nil {
-				log.Fatalf("[scylladb] [check_manage_org_admin] query failed: %v", err)
+				errs.Survive(err, iters, "[scylladb] [check_manage_org_admin] query failed: %v", err)
+				continue
 			}
 			if streamed == 0 {
 				log.Printf("[scylladb] [check_manage_org_admin] lookup-mode: no resources returned for user=%s", lookupUser)
@@ -301,18 +372,23 @@ func runCheckManageOrgAdmin(session *gocql.Session) {
 					break
 				}
 
+				if !utils.InOrgScope(ids.Resource, resID) {
+					continue
+				}
+
 				cctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 				start := time.Now()
-				checkQuery := `SELECT COUNT(*) FROM resource_acl_by_resource
-					WHERE resource_id = ? AND relation = 'manager_user' AND subject_type = 'user' AND subject_id = ?`
-				var exists int
-				queryErr := session.Query(checkQuery, resID, userID).WithContext(cctx).Scan(&exists)
+				granted, queryErr := checkPermissionScylla(cctx, session, resID, userID, "manager_user")
 				cancel()
+				utils.AuditCheck("check_manage_org_admin", resID, userID, "manager_user", granted, time.Since(start), queryErr)
 				if queryErr != nil {
-					log.Fatalf("[scylladb] [check_manage_org_admin] permission check failed: %v", queryErr)
+					class := errs.Record(queryErr)
+					log.Printf("[scylladb] [check_manage_org_admin] iter=%d check failed class=%s: %v", done, class, queryErr)
+					done++
+					continue
 				}
 				dur := time.Since(start)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[scylladb] [check_manage_org_admin] iter=%d resource=%d user=%d dur=%s", done, resID, userID, dur)
 				}
 				done++
@@ -320,10 +396,11 @@ func runCheckManageOrgAdmin(session *gocql.Session) {
 			return nil
 		})
 		if err != nil {
-			log.Fatalf("[scylladb] [check_manage_org_admin] streaming failed: %v", err)
+			errs.Survive(err, iters, "[scylladb] [check_manage_org_admin] streaming failed: %v", err)
 		}
 	}
 	log.Printf("[scylladb] [check_manage_org_admin] DONE: iters=%d", iters)
+	log.Printf("[scylladb] [check_manage_org_admin] ERRORS: %s", errs.Summary(done))
 }
 
 // runCheckViewViaGroupMember benchmarks permission checks for "view" permission
@@ -334,9 +411,11 @@ func runCheckViewViaGroupMember(session *gocql.Session) {
 	iters := utils.GetEnvInt("BENCH_CHECK_VIEW_GROUP_ITER", 1000)
 
 	log.Printf("[scylladb] [check_view_via_group_member] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("scylladb", "check_view_via_group_member", iters, 2*time.Second)
 	done := 0
+	errs := utils.NewErrorTally()
 	// Hybrid behavior: if BENCH_LOOKUPRES_VIEW_USER is set, prefer lookup for that user
-	lookupUser := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	sampleLimit := utils.GetEnvInt("BENCH_LOOKUP_SAMPLE_LIMIT", 1000)
 
 	for done < iters {
@@ -358,19 +437,24 @@ func runCheckViewViaGroupMember(session *gocql.Session) {
 					}
 					streamed++
 
+					if !utils.InOrgScope(ids.Resource, resID) {
+						continue
+					}
+
 					// Check permission existence
 					cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
 					start := time.Now()
-					checkQuery := `SELECT COUNT(*) FROM resource_acl_by_resource
-						WHERE resource_id = ? AND relation = 'viewer_user' AND subject_type = 'user' AND subject_id = ?`
-					var exists int
-					err := session.Query(checkQuery, resID, lookupUser).WithContext(cctx).Scan(&exists)
+					granted, err := checkPermissionScylla(cctx, session, resID, lookupUser, "viewer_user")
 					ccancel()
+					utils.AuditCheck("check_view_via_group_member", resID, lookupUser, "viewer_user", granted, time.Since(start), err)
 					if err != nil {
-						log.Fatalf("[scylladb] [check_view_via_group_member] permission check failed: %v", err)
+						class := errs.Record(err)
+						log.Printf("[scylladb] [check_view_via_group_member] iter=%d check failed class=%s: %v", done, class, err)
+						done++
+						continue
 					}
 					dur := time.Since(start)
-					if done%100 == 0 {
+					if utils.LogIteration(done, 100) {
 						log.Printf("[scylladb] [check_view_via_group_member] lookup iter=%d resource=%d user=%s dur=%s", done, resID, lookupUser, dur)
 					}
 					done++
@@ -379,7 +463,8 @@ func runCheckViewViaGroupMember(session *gocql.Session) {
 			})
 			cancel()
 			if err != nil {
-				log.Fatalf("[scylladb] [check_view_via_group_member] query failed: %v", err)
+				errs.Survive(err, iters, "[scylladb] [check_view_via_group_member] query failed: %v", err)
+				continue
 			}
 			if streamed == 0 {
 				log.Printf("[scylladb] [check_view_via_group_member] lookup-mode: no resources returned for user=%s", lookupUser)
@@ -414,21 +499,29 @@ func runCheckViewViaGroupMember(session *gocql.Session) {
 					continue
 				}
 				if err != nil {
-					log.Fatalf("[scylladb] [check_view_via_group_member] find member failed: %v", err)
+					class := errs.Record(err)
+					log.Printf("[scylladb] [check_view_via_group_member] iter=%d find member failed class=%s: %v", done, class, err)
+					done++
+					continue
+				}
+
+				if !utils.InOrgScope(ids.Resource, resID) {
+					continue
 				}
 
 				cctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 				start := time.Now()
-				checkQuery := `SELECT COUNT(*) FROM resource_acl_by_resource
-					WHERE resource_id = ? AND relation = 'viewer_user' AND subject_type = 'user' AND subject_id = ?`
-				var exists int
-				queryErr := session.Query(checkQuery, resID, pickedUser).WithContext(cctx).Scan(&exists)
+				granted, queryErr := checkPermissionScylla(cctx, session, resID, pickedUser, "viewer_user")
 				cancel()
+				utils.AuditCheck("check_view_via_group_member", resID, pickedUser, "viewer_user", granted, time.Since(start), queryErr)
 				if queryErr != nil {
-					log.Fatalf("[scylladb] [check_view_via_group_member] permission check failed: %v", queryErr)
+					class := errs.Record(queryErr)
+					log.Printf("[scylladb] [check_view_via_group_member] iter=%d check failed class=%s: %v", done, class, queryErr)
+					done++
+					continue
 				}
 				dur := time.Since(start)
-				if done%100 == 0 {
+				if utils.LogIteration(done, 100) {
 					log.Printf("[scylladb] [check_view_via_group_member] iter=%d resource=%d group=%d user=%d dur=%s", done, resID, groupID, pickedUser, dur)
 				}
 				done++
@@ -436,10 +529,79 @@ func runCheckViewViaGroupMember(session *gocql.Session) {
 			return nil
 		})
 		if err != nil {
-			log.Fatalf("[scylladb] [check_view_via_group_member] streaming failed: %v", err)
+			errs.Survive(err, iters, "[scylladb] [check_view_via_group_member] streaming failed: %v", err)
 		}
 	}
 	log.Printf("[scylladb] [check_view_via_group_member] DONE: iters=%d", iters)
+	log.Printf("[scylladb] [check_view_via_group_member] ERRORS: %s", errs.Summary(done))
+}
+
+// runCheckTimeBoundedDirectUser scans resource_acl_by_resource for direct user
+// grants carrying a validity window and checks each one, evaluating the window
+// client-side since CQL cannot compare against the current time. The expired
+// (soft deleted) share of the sample is denied.
+// Iterations are controlled by BENCH_CHECK_TIME_BOUNDED_ITER env variable (default: 1000).
+func runCheckTimeBoundedDirectUser(session *gocql.Session) {
+	iters := utils.GetEnvInt("BENCH_CHECK_TIME_BOUNDED_ITER", 1000)
+
+	log.Printf("[scylladb] [check_time_bounded_direct_user] streaming mode. iterations=%d", iters)
+	utils.LogScenarioConfig("scylladb", "check_time_bounded_direct_user", iters, 2*time.Second)
+	done, allowed := 0, 0
+	errs := utils.NewErrorTally()
+
+	query := `SELECT resource_id, relation, subject_type, subject_id, valid_until FROM resource_acl_by_resource`
+	err := streamQuery(context.Background(), session, query, nil, func(iter *gocql.Iter) error {
+		for done < iters {
+			var resID, userID int
+			var relation, subjectType string
+			var validUntil time.Time
+			if !iter.Scan(&resID, &relation, &subjectType, &userID, &validUntil) {
+				break
+			}
+			if subjectType != "user" || validUntil.IsZero() {
+				continue
+			}
+
+			if !utils.InOrgScope(ids.Resource, resID) {
+				continue
+			}
+
+			cctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			start := time.Now()
+			ok, queryErr := checkTimeBoundedScylla(cctx, session, resID, userID, relation)
+			cancel()
+			utils.AuditCheck("check_time_bounded_direct_user", resID, userID, relation, ok, time.Since(start), queryErr)
+			if queryErr != nil {
+				class := errs.Record(queryErr)
+				log.Printf("[scylladb] [check_time_bounded_direct_user] iter=%d check failed class=%s: %v", done, class, queryErr)
+				done++
+				continue
+			}
+			dur := time.Since(start)
+			if ok {
+				allowed++
+			}
+			if utils.LogIteration(done, 100) {
+				log.Printf("[scylladb] [check_time_bounded_direct_user] iter=%d resource=%d user=%d allowed=%t dur=%s", done, resID, userID, ok, dur)
+			}
+			done++
+		}
+		return nil
+	})
+	if err != nil {
+		errs.Survive(err, iters, "[scylladb] [check_time_bounded_direct_user] streaming failed: %v", err)
+	}
+	if done == 0 {
+		log.Printf("[scylladb] [check_time_bounded_direct_user] skipped: no time-bounded grants (generate with RLP_EXPIRING_GRANT_FRACTION)")
+	}
+	log.Printf("[scylladb] [check_time_bounded_direct_user] DONE: iters=%d allowed=%d denied=%d", done, allowed, done-allowed-errs.Total())
+	log.Printf("[scylladb] [check_time_bounded_direct_user] ERRORS: %s", errs.Summary(done))
+}
+
+// checkTimeBoundedScylla checks a direct grant of resource_acl_by_resource;
+// checkPermissionScylla already honours its valid_from/valid_until window.
+func checkTimeBoundedScylla(ctx context.Context, session *gocql.Session, resourceID, userID int, relation string) (bool, error) {
+	return checkPermissionScylla(ctx, session, resourceID, userID, relation)
 }
 
 // runLookupResourcesManageHeavyUser benchmarks resource lookup for "manage" permission
@@ -449,7 +611,7 @@ func runCheckViewViaGroupMember(session *gocql.Session) {
 // Iterations are controlled by BENCH_LOOKUPRES_MANAGE_ITER env variable (default: 10).
 func runLookupResourcesManageHeavyUser(session *gocql.Session) {
 	iters := utils.GetEnvInt("BENCH_LOOKUPRES_MANAGE_ITER", 10)
-	userID := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER")
+	userID := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
 	runLookupBench(session, "lookup_resources_manage_super", "manager_user", userID, iters, 60*time.Second)
 }
 
@@ -460,6 +622,87 @@ func runLookupResourcesManageHeavyUser(session *gocql.Session) {
 // Iterations are controlled by BENCH_LOOKUPRES_VIEW_ITER env variable (default: 10).
 func runLookupResourcesViewRegularUser(session *gocql.Session) {
 	iters := utils.GetEnvInt("BENCH_LOOKUPRES_VIEW_ITER", 10)
-	userID := os.Getenv("BENCH_LOOKUPRES_VIEW_USER")
+	userID := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
 	runLookupBench(session, "lookup_resources_view_regular", "viewer_user", userID, iters, 60*time.Second)
 }
+
+// runLookupResourcesMix benchmarks lookups for a mix of users stratified by
+// grant count, reporting latency against result-set size. The number of users
+// is set by BENCH_LOOKUPRES_MIX_USERS (default 0 = skip); see utils.RunLookupMix.
+func runLookupResourcesMix(session *gocql.Session) {
+	utils.RunLookupMix("scylladb", newChecker(session))
+}
+
+// runLookupResourcesPercentiles benchmarks lookups for the users at the p10,
+// p50, p90 and p99 of the grant counts (BENCH_LOOKUPRES_*_PCT_USERS); see
+// utils.RunPercentileLookups.
+func runLookupResourcesPercentiles(session *gocql.Session) {
+	utils.RunPercentileLookups("scylladb", newChecker(session))
+}
+
+// runViralFanIn benchmarks checks and lookups on the resources shared with many
+// users directly (RLP_VIRAL_RESOURCES); see utils.RunViralFanIn.
+func runViralFanIn(session *gocql.Session) {
+	utils.RunViralFanIn("scylladb", newChecker(session))
+}
+
+// runWorstCaseChecks benchmarks view checks on the pairs granted only through
+// nested groups (check_view_worst_case); see utils.RunWorstCaseChecks.
+func runWorstCaseChecks(session *gocql.Session) {
+	utils.RunWorstCaseChecks("scylladb", newChecker(session))
+}
+
+// runWorkload benchmarks the blended mix of checks and lookups named by
+// BENCH_WORKLOAD (default "" = skip); see utils.RunWorkload.
+func runWorkload(session *gocql.Session) {
+	utils.RunWorkload("scylladb", newChecker(session))
+}
+
+// runMyGroups benchmarks listing the direct and effective groups of sampled
+// users (BENCH_MY_GROUPS_ITER, default 100; 0 skips); see utils.RunMyGroups.
+func runMyGroups(session *gocql.Session) {
+	utils.RunMyGroups("scylladb", newChecker(session))
+}
+
+// runAccessSummary benchmarks counting the resources sampled users can view
+// and manage (BENCH_ACCESS_SUMMARY_ITER, default 100; 0 skips); see
+// utils.RunAccessSummary.
+func runAccessSummary(session *gocql.Session) {
+	utils.RunAccessSummary("scylladb", newChecker(session))
+}
+
+// runMiddlewareOverhead benchmarks a resource fetch with and without a view
+// check in front of it (BENCH_MIDDLEWARE_ITER, default 1000; 0 skips); see
+// utils.RunMiddlewareOverhead.
+func runMiddlewareOverhead(session *gocql.Session) {
+	utils.RunMiddlewareOverhead("scylladb", newChecker(session), func(ctx context.Context, resourceID string) error {
+		id, err := ids.Parse(ids.Resource, resourceID)
+		if err != nil {
+			return err
+		}
+		row := map[string]any{}
+		err = session.Query(`SELECT * FROM resources WHERE resource_id = ?`, id).WithContext(ctx).MapScan(row)
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// runFullExport benchmarks streaming every row of resource_acl_by_resource
+// (export_full_acl, BENCH_EXPORT_ITER); see utils.RunFullExport.
+func runFullExport(session *gocql.Session) {
+	utils.RunFullExport("scylladb", func(ctx context.Context) (int, error) {
+		n := 0
+		err := streamQuery(ctx, session, `SELECT resource_id, relation, subject_type, subject_id, valid_from, valid_until FROM resource_acl_by_resource`, nil, func(iter *gocql.Iter) error {
+			var resID, subjectID int
+			var relation, subjectType string
+			var from, until time.Time
+			for iter.Scan(&resID, &relation, &subjectType, &subjectID, &from, &until) {
+				n++
+			}
+			return nil
+		})
+		return n, err
+	})
+}
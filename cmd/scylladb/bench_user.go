@@ -0,0 +1,43 @@
+package scylladb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// ensureBenchUser provisions the read-only role the benchmark connects as
+// (see infrastructure.UseBenchCredentials). No-op unless SCYLLA_BENCH_USER is
+// set. Requires PasswordAuthenticator and CassandraAuthorizer on the cluster.
+func ensureBenchUser(ctx context.Context, session *gocql.Session) {
+	user, password, ok := infrastructure.BenchCredentialsFromEnv("SCYLLA")
+	if !ok {
+		return
+	}
+	role := `"` + strings.ReplaceAll(user, `"`, `""`) + `"`
+	secret := "'" + strings.ReplaceAll(password, "'", "''") + "'"
+	keyspace := utils.Namespaced(utils.GetEnvWithDefault("SCYLLA_KEYSPACE", "rlp"))
+
+	stmts := []string{
+		fmt.Sprintf("CREATE ROLE IF NOT EXISTS %s WITH PASSWORD = %s AND LOGIN = true", role, secret),
+		fmt.Sprintf("ALTER ROLE %s WITH PASSWORD = %s AND LOGIN = true", role, secret),
+		fmt.Sprintf("GRANT SELECT ON KEYSPACE %s TO %s", keyspace, role),
+	}
+	for _, stmt := range stmts {
+		cctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := session.Query(stmt).WithContext(cctx).Exec()
+		cancel()
+		if err != nil {
+			log.Fatalf("[scylladb] bench user: %v", err)
+		}
+	}
+
+	log.Printf("[scylladb] Read-only bench user %q ready", user)
+}
@@ -2,12 +2,15 @@ package scylladb
 
 import (
 	"context"
+	"embed"
+	"fmt"
 	"log"
-	"os"
-	"strings"
 	"time"
 
+	"github.com/gocql/gocql"
+
 	"test-tls/infrastructure"
+	"test-tls/migrate"
 )
 
 // ScylladbCreateSchemas creates the CQL tables used for the ScyllaDB benchmarks.
@@ -51,6 +54,10 @@ import (
 //	user_resource_perms_by_resource(resource_id, user_id, can_manage, can_view)
 //	  - Same closure but partitioned by resource.
 //	  - Optimized for check operations (RLS check).
+//
+// The tables come from the migrations in migrations/; only those the keyspace
+// has not recorded yet are applied, so rerunning it is a no-op.
+// SCYLLA_MIGRATIONS_DIR reads them from a directory instead of the binary.
 func ScylladbCreateSchemas() {
 	ctx := context.Background()
 
@@ -62,49 +69,53 @@ func ScylladbCreateSchemas() {
 
 	log.Printf("[scylladb] == Creating ScyllaDB schemas ==")
 
-	// Read schemas from schemas.cql file
-	schemaFile := "cmd/scylladb/schemas.cql"
-	content, err := os.ReadFile(schemaFile)
-	if err != nil {
-		log.Fatalf("[scylladb] read schemas.cql failed: %v", err)
-	}
+	migrations := migrate.Load("scylladb", migrationFiles, "SCYLLA_MIGRATIONS_DIR")
+	migrate.Run(ctx, "scylladb", migrationStore{session}, migrations)
 
-	// Parse CQL statements (split by semicolon and filter out comments)
-	lines := strings.Split(string(content), "\n")
-	var currentStatement strings.Builder
-	queries := []string{}
+	log.Printf("[scylladb] ScyllaDB schemas created successfully.")
 
-	for _, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		// Skip empty lines and comment lines
-		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "--") {
-			continue
-		}
+	ensureBenchUser(ctx, session)
+}
 
-		// Append line to current statement
-		currentStatement.WriteString(line)
-		currentStatement.WriteString("\n")
+//go:embed migrations/*.cql
+var migrationFiles embed.FS
 
-		// If line ends with semicolon, we have a complete statement
-		if strings.HasSuffix(trimmedLine, ";") {
-			stmt := strings.TrimSpace(currentStatement.String())
-			stmt = strings.TrimSuffix(stmt, ";")
-			stmt = strings.TrimSpace(stmt)
-			if stmt != "" {
-				queries = append(queries, stmt)
-			}
-			currentStatement.Reset()
-		}
+// migrationStore records applied migrations in rlp_schema_migrations. Statements
+// run one by one with a 30s timeout each, and the migration is recorded after
+// the last one succeeds.
+type migrationStore struct{ session *gocql.Session }
+
+func (s migrationStore) Applied(ctx context.Context) (map[int]bool, error) {
+	if err := s.session.Query(`CREATE TABLE IF NOT EXISTS rlp_schema_migrations (
+		version    int PRIMARY KEY,
+		name       text,
+		applied_at timestamp
+	)`).WithContext(ctx).Exec(); err != nil {
+		return nil, err
 	}
+	iter := s.session.Query(`SELECT version FROM rlp_schema_migrations`).WithContext(ctx).Iter()
+	applied := make(map[int]bool)
+	var v int
+	for iter.Scan(&v) {
+		applied[v] = true
+	}
+	return applied, iter.Close()
+}
 
-	for _, q := range queries {
-		ctxTimeout, cancel := context.WithTimeout(ctx, 30*time.Second)
-		if err := session.Query(q).WithContext(ctxTimeout).Exec(); err != nil {
+func (s migrationStore) Apply(ctx context.Context, m migrate.Migration) error {
+	if err := recordCreated(ctx, s.session, func() error {
+		for _, stmt := range m.Statements() {
+			stmtCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			err := s.session.Query(stmt).WithContext(stmtCtx).Exec()
 			cancel()
-			log.Fatalf("[scylladb] create_schemas: exec failed for query: %v\n%s", err, q)
+			if err != nil {
+				return fmt.Errorf("exec failed for query: %w\n%s", err, stmt)
+			}
 		}
-		cancel()
+		return nil
+	}); err != nil {
+		return err
 	}
-
-	log.Printf("[scylladb] ScyllaDB schemas created successfully.")
+	return s.session.Query(`INSERT INTO rlp_schema_migrations (version, name, applied_at) VALUES (?, ?, toTimestamp(now()))`,
+		m.Version, m.Name).WithContext(ctx).Exec()
 }
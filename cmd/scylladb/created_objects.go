@@ -0,0 +1,43 @@
+package scylladb
+
+import (
+	"context"
+	"slices"
+
+	"github.com/gocql/gocql"
+
+	"test-tls/utils"
+)
+
+// recordCreated runs create and records the tables it created in
+// rlp_objects, leaving out those it found already there, so that drop
+// removes what the tool created and nothing else of the same name
+// (utils.OwnedRecorded). A create that fails part way still records the
+// tables it made, which its IF NOT EXISTS rerun would find in place.
+func recordCreated(ctx context.Context, session *gocql.Session, create func() error) error {
+	if err := session.Query(`CREATE TABLE IF NOT EXISTS rlp_objects (name text PRIMARY KEY)`).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	before := listTables(ctx, session)
+	createErr := create()
+	for _, name := range utils.NewObjects(before, listTables(ctx, session)) {
+		if err := session.Query(`INSERT INTO rlp_objects (name) VALUES (?)`, name).WithContext(ctx).Exec(); err != nil {
+			return err
+		}
+	}
+	return createErr
+}
+
+// recordedObjects returns the names recorded in rlp_objects, none when found,
+// the tables of the keyspace, holds no such table.
+func recordedObjects(ctx context.Context, session *gocql.Session, found []string) (map[string]bool, error) {
+	recorded := map[string]bool{}
+	if !slices.Contains(found, "rlp_objects") {
+		return recorded, nil
+	}
+	iter := session.Query(`SELECT name FROM rlp_objects`).WithContext(ctx).Iter()
+	for name := ""; iter.Scan(&name); {
+		recorded[name] = true
+	}
+	return recorded, iter.Close()
+}
@@ -0,0 +1,33 @@
+package scylladb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// datasetStore records the dataset load-data loaded in the one row of
+// rlp_dataset, overwritten by every load (utils.GuardDataset).
+type datasetStore struct{ session *gocql.Session }
+
+func (s datasetStore) RecordDataset(ctx context.Context, id string) error {
+	if err := s.session.Query(`CREATE TABLE IF NOT EXISTS rlp_dataset (
+		name      text PRIMARY KEY,
+		id        text,
+		loaded_at timestamp
+	)`).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	return s.session.Query(`INSERT INTO rlp_dataset (name, id, loaded_at) VALUES ('dataset', ?, ?)`, id, time.Now()).WithContext(ctx).Exec()
+}
+
+func (s datasetStore) LoadedDataset(ctx context.Context) (string, error) {
+	var id string
+	err := s.session.Query(`SELECT id FROM rlp_dataset WHERE name = 'dataset'`).WithContext(ctx).Scan(&id)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return "", nil
+	}
+	return id, err
+}
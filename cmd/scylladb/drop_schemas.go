@@ -2,16 +2,22 @@ package scylladb
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
+	"github.com/gocql/gocql"
+
 	"test-tls/infrastructure"
+	"test-tls/utils"
 )
 
 // ScylladbDropSchemas drops all tables used by the scylladb benchmarks.
 //
 // It does NOT drop the keyspace itself, only the benchmark tables created in
-// ScylladbCreateSchemas so the command is safe to re-run.
+// ScylladbCreateSchemas and recorded in rlp_objects, so the command is safe to
+// re-run. Other tables of the keyspace, including ones that share a name with
+// ours, are listed and left in place (utils.OwnedRecorded).
 func ScylladbDropSchemas() {
 	ctx := context.Background()
 
@@ -31,6 +37,8 @@ func ScylladbDropSchemas() {
 		"groups",
 		"org_memberships",
 		"group_memberships",
+		"group_hierarchy",
+		"group_members_expanded",
 		"resources",
 		"resource_acl_by_resource",
 		"resource_acl_by_subject",
@@ -38,7 +46,25 @@ func ScylladbDropSchemas() {
 		"user_resource_perms_by_resource",
 	}
 
+	owned := append([]string{"rlp_schema_migrations", "rlp_dataset", "rlp_objects"}, tables...)
+	for _, v := range indexVariants {
+		for _, c := range v.copies {
+			owned = append(owned, c.dst)
+		}
+	}
+	found := listTables(ctx, session)
+	recorded, err := recordedObjects(ctx, session, found)
+	if err != nil {
+		log.Fatalf("[scylladb] drop: list recorded tables: %v", err)
+	}
+	own := utils.OwnedRecorded("scylladb", found, recorded, owned)
+
+	utils.GuardDrop("scylladb", countTables(ctx, session, utils.OwnedNames(own, tables)))
+
 	for _, tbl := range tables {
+		if !own[tbl] {
+			continue
+		}
 		cql := "DROP TABLE IF EXISTS " + tbl
 
 		dropCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
@@ -51,6 +77,69 @@ func ScylladbDropSchemas() {
 		log.Printf("[scylladb] Dropped table: %s", tbl)
 	}
 
+	// Copies kept by `scylladb index-compare` (SCYLLA_INDEX_KEEP=true);
+	// dropping the base table drops its indexes.
+	for _, v := range indexVariants {
+		for _, c := range v.copies {
+			if !own[c.dst] {
+				continue
+			}
+			if err := session.Query("DROP TABLE IF EXISTS " + c.dst).WithContext(ctx).Exec(); err != nil {
+				log.Printf("[scylladb] warning: DropTable %s failed: %v", c.dst, err)
+				continue
+			}
+			log.Printf("[scylladb] Dropped table: %s", c.dst)
+		}
+	}
+
+	// Forget the applied migrations so create-schema rebuilds everything, then
+	// the dataset load-data recorded and the record of what the tool created.
+	for _, tbl := range []string{"rlp_schema_migrations", "rlp_dataset", "rlp_objects"} {
+		if !own[tbl] {
+			continue
+		}
+		if err := session.Query("DROP TABLE IF EXISTS " + tbl).WithContext(ctx).Exec(); err != nil {
+			log.Fatalf("[scylladb] DropTable %s failed: %v", tbl, err)
+		}
+		log.Printf("[scylladb] Dropped table: %s", tbl)
+	}
+
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 	log.Printf("[scylladb] ScyllaDB drop schemas DONE: elapsed=%s", elapsed)
 }
+
+// listTables returns the tables of the session's keyspace.
+func listTables(ctx context.Context, session *gocql.Session) []string {
+	qctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	iter := session.Query(`SELECT table_name FROM system_schema.tables WHERE keyspace_name = ?`,
+		utils.Namespaced(utils.GetEnvWithDefault("SCYLLA_KEYSPACE", "rlp"))).WithContext(qctx).Iter()
+	var tables []string
+	for name := ""; iter.Scan(&name); {
+		tables = append(tables, name)
+	}
+	if err := iter.Close(); err != nil {
+		log.Fatalf("[scylladb] list tables: %v", err)
+	}
+	return tables
+}
+
+// countTables counts the rows of tables for the drop guard. COUNT(*) is a
+// full scan in ScyllaDB, so each table is only read up to utils.DropCountCap
+// rows.
+func countTables(ctx context.Context, session *gocql.Session, tables []string) []utils.DropCount {
+	limit := utils.DropCountCap()
+	counts := make([]utils.DropCount, 0, len(tables))
+	for _, tbl := range tables {
+		qctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+		iter := session.Query(fmt.Sprintf("SELECT * FROM %s LIMIT %d", tbl, limit)).WithContext(qctx).Iter()
+		var n int64
+		for row := map[string]any{}; iter.MapScan(row); row = map[string]any{} {
+			n++
+		}
+		err := iter.Close()
+		cancel()
+		counts = append(counts, utils.DropCount{Table: tbl, Rows: n, AtLeast: n >= limit, Missing: err != nil})
+	}
+	return counts
+}
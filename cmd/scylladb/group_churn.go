@@ -0,0 +1,166 @@
+package scylladb
+
+import (
+	"context"
+	"log"
+
+	"github.com/gocql/gocql"
+
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// expandedMaintainer keeps group_members_expanded up to date with
+// group_hierarchy changes (utils.GroupMaintainer). It holds group_hierarchy
+// and the direct group memberships in memory, read once at start: hierarchy
+// changes leave the memberships as they are. After a burst it recomputes the
+// partitions of every group above a changed edge, with the rules of
+// buildGroupMembersExpanded, deleting each partition before rewriting it.
+type expandedMaintainer struct {
+	session *gocql.Session
+	graph   *utils.GroupGraph
+	direct  map[int]intSet // group -> users, managers included
+}
+
+func newExpandedMaintainer(ctx context.Context, session *gocql.Session) *expandedMaintainer {
+	m := &expandedMaintainer{session: session, graph: utils.NewGroupGraph(), direct: map[int]intSet{}}
+
+	it := session.Query(`SELECT parent_group_id, child_group_id, relation FROM group_hierarchy`).WithContext(ctx).Iter()
+	c := utils.GroupEdgeChange{Add: true}
+	for it.Scan(&c.Parent, &c.Child, &c.Relation) {
+		m.graph.Apply(c)
+	}
+	if err := it.Close(); err != nil {
+		log.Fatalf("[scylladb] group_churn: read group_hierarchy: %v", err)
+	}
+
+	it = session.Query(`SELECT group_id, user_id FROM group_memberships`).WithContext(ctx).Iter()
+	var group, user int
+	for it.Scan(&group, &user) {
+		addTo(m.direct, group, user)
+	}
+	if err := it.Close(); err != nil {
+		log.Fatalf("[scylladb] group_churn: read group_memberships: %v", err)
+	}
+	return m
+}
+
+// Edges returns the first n edges in token order.
+func (m *expandedMaintainer) Edges(ctx context.Context, n int) ([]utils.GroupEdgeChange, error) {
+	it := m.session.Query(`SELECT parent_group_id, child_group_id, relation FROM group_hierarchy LIMIT ?`, n).WithContext(ctx).Iter()
+	var edges []utils.GroupEdgeChange
+	e := utils.GroupEdgeChange{Add: true}
+	for it.Scan(&e.Parent, &e.Child, &e.Relation) {
+		edges = append(edges, e)
+	}
+	return edges, it.Close()
+}
+
+// Apply writes the edges of burst to group_hierarchy and then rewrites the
+// group_members_expanded partitions of the groups above them once. It
+// returns the rows inserted.
+func (m *expandedMaintainer) Apply(ctx context.Context, burst []utils.GroupEdgeChange) (int, error) {
+	var parents []int
+	for _, c := range burst {
+		if _, ok := m.graph.Children[c.Parent][c.Child]; ok == c.Add {
+			continue // nothing to change
+		}
+		stmt := `DELETE FROM group_hierarchy WHERE parent_group_id = ? AND child_group_id = ? AND relation = ?`
+		if c.Add {
+			stmt = `INSERT INTO group_hierarchy (parent_group_id, child_group_id, relation) VALUES (?, ?, ?)`
+		}
+		if err := m.session.Query(stmt, c.Parent, c.Child, c.Relation).WithContext(ctx).Exec(); err != nil {
+			return 0, err
+		}
+		m.graph.Apply(c)
+		parents = append(parents, c.Parent)
+	}
+	if len(parents) == 0 {
+		return 0, nil
+	}
+
+	managers := m.expander("manager_group", nil)
+	members := m.expander("member_group", managers)
+	rows := 0
+	for _, g := range m.graph.Ancestors(parents...) {
+		if err := m.session.Query(`DELETE FROM group_members_expanded WHERE group_id = ?`, g).WithContext(ctx).Exec(); err != nil {
+			return 0, err
+		}
+		batch := m.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+		for _, set := range []struct {
+			role  string
+			users intSet
+		}{{"manager", managers(g)}, {"member", members(g)}} {
+			for u := range set.users {
+				batch.Query(`INSERT INTO group_members_expanded (group_id, user_id, role) VALUES (?, ?, ?)`, g, u, set.role)
+				rows++
+				if len(batch.Entries) >= insertBatchSize {
+					if err := m.session.ExecuteBatch(batch); err != nil {
+						return 0, err
+					}
+					batch = m.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+				}
+			}
+		}
+		if len(batch.Entries) > 0 {
+			if err := m.session.ExecuteBatch(batch); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return rows, nil
+}
+
+// expander returns the effective users of a group through edges of relation:
+// its direct members, those of base (the managers, for members) and the
+// expansion of its relation children. Results are memoized for one burst; a
+// cycle is cut where it closes.
+func (m *expandedMaintainer) expander(relation string, base func(int) intSet) func(int) intSet {
+	memo := map[int]intSet{}
+	visiting := map[int]bool{}
+	var expand func(group int) intSet
+	expand = func(group int) intSet {
+		if users, ok := memo[group]; ok {
+			return users
+		}
+		users := intSet{}
+		if visiting[group] {
+			return users
+		}
+		visiting[group] = true
+		for u := range m.direct[group] {
+			users.add(u)
+		}
+		for child, rel := range m.graph.Children[group] {
+			if rel == relation {
+				for u := range expand(child) {
+					users.add(u)
+				}
+			}
+		}
+		if base != nil {
+			for u := range base(group) {
+				users.add(u)
+			}
+		}
+		visiting[group] = false
+		memo[group] = users
+		return users
+	}
+	return expand
+}
+
+// ScylladbGroupChurn benchmarks the incremental maintenance of
+// group_members_expanded per burst of group hierarchy changes; see
+// utils.RunGroupChurn.
+func ScylladbGroupChurn() {
+	ctx := context.Background()
+	session, cleanup, err := infrastructure.NewScyllaFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[scylladb] failed to create scylla session: %v", err)
+	}
+	defer cleanup()
+
+	utils.RunGroupChurn("scylladb", newExpandedMaintainer(ctx, session))
+	log.Println("[scylladb] == ScyllaDB group churn DONE ==")
+}
@@ -0,0 +1,384 @@
+package scylladb
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// indexVariant is one way of modeling the reverse access paths of the ACL and
+// permission tables for `scylladb index-compare`. queries maps an
+// indexQuery name to its CQL; a query without an entry does not apply to the
+// variant. setup creates the variant's tables and indexes, and copies lists
+// the live tables to copy into them.
+type indexVariant struct {
+	name    string
+	setup   []string
+	copies  []tableCopy
+	tables  []string // tables and index views whose size is logged
+	queries map[string]string
+}
+
+// tableCopy copies the live table src into dst: resource_acl_by_resource
+// rows when acl is set, else user_resource_perms_by_user rows.
+type tableCopy struct {
+	src, dst string
+	acl      bool
+}
+
+// indexVariants are the modeling approaches compared by `scylladb
+// index-compare`. "tables" is the schema of migrations/0001_init.cql: every
+// relation written twice, once per access path. The index variants keep one
+// table and serve the other path from a secondary index: a global index
+// (a separate view partitioned by the indexed column) or a local index
+// (indexed within the base table's partition).
+var indexVariants = []indexVariant{
+	{
+		name:   "tables",
+		tables: []string{"resource_acl_by_resource", "resource_acl_by_subject", "user_resource_perms_by_user", "user_resource_perms_by_resource"},
+		queries: map[string]string{
+			"check_acl":                `SELECT COUNT(*) FROM resource_acl_by_resource WHERE resource_id = ? AND relation = 'manager_user' AND subject_type = 'user' AND subject_id = ?`,
+			"lookup_acl_by_subject":    `SELECT resource_id FROM resource_acl_by_subject WHERE subject_type = 'user' AND subject_id = ? AND relation = 'manager_user'`,
+			"lookup_perms_by_resource": `SELECT user_id FROM user_resource_perms_by_resource WHERE resource_id = ?`,
+			"lookup_perms_manage":      `SELECT resource_id FROM user_resource_perms_by_user WHERE user_id = ? AND can_manage = true ALLOW FILTERING`,
+		},
+	},
+	{
+		name: "global_index",
+		setup: []string{
+			`CREATE TABLE resource_acl_gsi (
+				resource_id int, relation text, subject_type text, subject_id int,
+				valid_from timestamp, valid_until timestamp,
+				PRIMARY KEY ((resource_id), relation, subject_type, subject_id))`,
+			`CREATE INDEX resource_acl_gsi_subject ON resource_acl_gsi (subject_id)`,
+			`CREATE TABLE user_resource_perms_gsi (
+				user_id int, resource_id int, can_manage boolean, can_view boolean,
+				PRIMARY KEY ((user_id), resource_id))`,
+			`CREATE INDEX user_resource_perms_gsi_resource ON user_resource_perms_gsi (resource_id)`,
+		},
+		copies: []tableCopy{
+			{src: "resource_acl_by_resource", dst: "resource_acl_gsi", acl: true},
+			{src: "user_resource_perms_by_user", dst: "user_resource_perms_gsi"},
+		},
+		tables: []string{"resource_acl_gsi", "resource_acl_gsi_subject_index", "user_resource_perms_gsi", "user_resource_perms_gsi_resource_index"},
+		queries: map[string]string{
+			"check_acl":                `SELECT COUNT(*) FROM resource_acl_gsi WHERE resource_id = ? AND relation = 'manager_user' AND subject_type = 'user' AND subject_id = ?`,
+			"lookup_acl_by_subject":    `SELECT resource_id FROM resource_acl_gsi WHERE subject_id = ? AND subject_type = 'user' AND relation = 'manager_user' ALLOW FILTERING`,
+			"lookup_perms_by_resource": `SELECT user_id FROM user_resource_perms_gsi WHERE resource_id = ?`,
+			"lookup_perms_manage":      `SELECT resource_id FROM user_resource_perms_gsi WHERE user_id = ? AND can_manage = true ALLOW FILTERING`,
+		},
+	},
+	{
+		// A local index needs the base partition key in the query, so it only
+		// serves lookups within one partition: here the resources a user can
+		// manage, without reading the user's whole partition.
+		name: "local_index",
+		setup: []string{
+			`CREATE TABLE user_resource_perms_lsi (
+				user_id int, resource_id int, can_manage boolean, can_view boolean,
+				PRIMARY KEY ((user_id), resource_id))`,
+			`CREATE INDEX user_resource_perms_lsi_manage ON user_resource_perms_lsi ((user_id), can_manage)`,
+		},
+		copies: []tableCopy{{src: "user_resource_perms_by_user", dst: "user_resource_perms_lsi"}},
+		tables: []string{"user_resource_perms_lsi", "user_resource_perms_lsi_manage_index"},
+		queries: map[string]string{
+			"lookup_perms_manage": `SELECT resource_id FROM user_resource_perms_lsi WHERE user_id = ? AND can_manage = true`,
+		},
+	},
+}
+
+// indexQuery is one of the queries timed on every variant. Its arguments are
+// a (resource, user) pair for checks, else a user or a resource.
+type indexQuery struct {
+	name string
+	arg  string // "pair", "user" or "resource"
+}
+
+var indexQueries = []indexQuery{
+	{name: "check_acl", arg: "pair"},
+	{name: "lookup_acl_by_subject", arg: "user"},
+	{name: "lookup_perms_by_resource", arg: "resource"},
+	{name: "lookup_perms_manage", arg: "user"},
+}
+
+// ScylladbIndexCompare benchmarks the reverse-path queries of the ACL and
+// permission tables against each modeling approach: the duplicated
+// *_by_resource/*_by_subject/*_by_user tables the loader writes, a single
+// table with a global secondary index, and a single table with a local
+// secondary index. The index variants are built by copying the loaded tables
+// into new ones with their indexes already in place.
+//
+// Per variant it logs the estimated size of every table and index view
+// (system.size_estimates, refreshed by Scylla in the background, so fresh
+// copies may read low), then a "[index_compare] ... DONE:" line per query with
+// the latency percentiles and the rows returned; rows that differ from the
+// "tables" variant are logged as a mismatch. The copies are dropped afterwards
+// unless SCYLLA_INDEX_KEEP is true (`scylladb drop` removes kept copies).
+//
+// Check pairs are direct manager_user grants read from the start of
+// resource_acl_by_resource; the lookup user is BENCH_LOOKUPRES_MANAGE_USER, or
+// the user of the first pair.
+//
+// Env vars:
+//
+//	SCYLLA_INDEX_VARIANTS      (default: all, comma-separated)
+//	SCYLLA_INDEX_CHECK_ITER    (default: 1000)
+//	SCYLLA_INDEX_LOOKUP_ITER   (default: 10)
+//	SCYLLA_INDEX_KEEP          (default: false)
+func ScylladbIndexCompare() {
+	ctx := context.Background()
+	session, cleanup, err := infrastructure.NewScyllaFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[scylladb] failed to create scylla session: %v", err)
+	}
+	defer cleanup()
+
+	variants := selectIndexVariants(utils.GetEnvWithDefault("SCYLLA_INDEX_VARIANTS", ""))
+	checkIters := utils.GetEnvInt("SCYLLA_INDEX_CHECK_ITER", 1000)
+	lookupIters := utils.GetEnvInt("SCYLLA_INDEX_LOOKUP_ITER", 10)
+	keep := utils.GetEnvWithDefault("SCYLLA_INDEX_KEEP", "false") == "true"
+
+	pairs := indexComparePairs(ctx, session, checkIters)
+	if len(pairs) == 0 {
+		log.Fatalf("[scylladb] index_compare: no direct manager_user grants in resource_acl_by_resource; run load-data first")
+	}
+	lookupUser := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_MANAGE_USER")
+	if lookupUser == "" {
+		lookupUser = fmt.Sprint(pairs[0][1])
+	}
+	log.Printf("[scylladb] [index_compare] variants=%d pairs=%d check_iters=%d lookup_iters=%d lookup_user=%s",
+		len(variants), len(pairs), checkIters, lookupIters, lookupUser)
+
+	rows := map[string]int{} // query -> rows of the tables variant
+	for _, v := range variants {
+		buildIndexVariant(ctx, session, v)
+		for _, t := range v.tables {
+			log.Printf("[scylladb] [index_compare] variant=%s table=%s size=%s", v.name, t, estimatedSize(ctx, session, t))
+		}
+		for _, q := range indexQueries {
+			cql, ok := v.queries[q.name]
+			if !ok {
+				continue
+			}
+			iters := lookupIters
+			if q.arg == "pair" {
+				iters = checkIters
+			}
+			n := runIndexQuery(session, v.name, q, cql, pairs, lookupUser, iters)
+			if v.name == "tables" {
+				rows[q.name] = n
+			} else if first, ok := rows[q.name]; ok && n != first {
+				log.Printf("[scylladb] [index_compare] variant=%s query=%s MISMATCH: rows=%d, tables returned %d", v.name, q.name, n, first)
+			}
+		}
+		if !keep {
+			dropIndexVariant(ctx, session, v)
+		}
+	}
+	log.Println("[scylladb] == ScyllaDB index comparison DONE ==")
+}
+
+// selectIndexVariants returns the variants named in spec, or all of them for
+// an empty spec.
+func selectIndexVariants(spec string) []indexVariant {
+	if spec == "" {
+		return indexVariants
+	}
+	var out []indexVariant
+	for name := range strings.SplitSeq(spec, ",") {
+		name = strings.TrimSpace(name)
+		i := slices.IndexFunc(indexVariants, func(v indexVariant) bool { return v.name == name })
+		if i < 0 {
+			log.Fatalf("[scylladb] index_compare: unknown variant %q in SCYLLA_INDEX_VARIANTS", name)
+		}
+		out = append(out, indexVariants[i])
+	}
+	return out
+}
+
+// indexComparePairs returns up to n (resource, user) pairs of direct
+// manager_user grants, in token order of resource_acl_by_resource.
+func indexComparePairs(ctx context.Context, session *gocql.Session, n int) [][2]int {
+	var pairs [][2]int
+	qctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	err := streamQuery(qctx, session, `SELECT resource_id, relation, subject_type, subject_id FROM resource_acl_by_resource`, nil, func(it *gocql.Iter) error {
+		var resID, subjectID int
+		var relation, subjectType string
+		for len(pairs) < n && it.Scan(&resID, &relation, &subjectType, &subjectID) {
+			if relation == "manager_user" && subjectType == "user" {
+				pairs = append(pairs, [2]int{resID, subjectID})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("[scylladb] index_compare: sample check pairs: %v", err)
+	}
+	return pairs
+}
+
+// buildIndexVariant (re)creates the variant's tables and indexes and copies
+// the live tables into them.
+func buildIndexVariant(ctx context.Context, session *gocql.Session, v indexVariant) {
+	if len(v.setup) == 0 {
+		return
+	}
+	start := time.Now()
+	dropIndexVariant(ctx, session, v)
+	if err := recordCreated(ctx, session, func() error {
+		for _, cql := range v.setup {
+			qctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+			err := session.Query(cql).WithContext(qctx).Exec()
+			cancel()
+			if err != nil {
+				return fmt.Errorf("executing %q: %w", cql, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		log.Fatalf("[scylladb] index_compare: variant %s: %v", v.name, err)
+	}
+	for _, c := range v.copies {
+		copyTable(ctx, session, c)
+	}
+	log.Printf("[scylladb] [index_compare] variant=%s built in %s", v.name, time.Since(start).Truncate(time.Millisecond))
+}
+
+// copyTable copies every row of src, a resource_acl_by_resource (acl) or
+// user_resource_perms_by_user table, into dst, which has the same columns,
+// through the loader's unlogged batches. Index views of dst are updated as
+// the rows are written.
+func copyTable(ctx context.Context, session *gocql.Session, c tableCopy) {
+	cols := "user_id, resource_id, can_manage, can_view"
+	if c.acl {
+		cols = "resource_id, relation, subject_type, subject_id, valid_from, valid_until"
+	}
+	n := strings.Count(cols, ",") + 1
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", c.dst, cols, strings.TrimSuffix(strings.Repeat("?, ", n), ", "))
+
+	rows := func(yield func([]any) bool) {
+		it := session.Query(fmt.Sprintf("SELECT %s FROM %s", cols, c.src)).WithContext(ctx).Iter()
+		for {
+			var row []any
+			if c.acl {
+				var resID, subjectID int
+				var relation, subjectType string
+				var from, until *time.Time
+				if !it.Scan(&resID, &relation, &subjectType, &subjectID, &from, &until) {
+					break
+				}
+				row = []any{resID, relation, subjectType, subjectID, from, until}
+			} else {
+				var userID, resID int
+				var canManage, canView bool
+				if !it.Scan(&userID, &resID, &canManage, &canView) {
+					break
+				}
+				row = []any{userID, resID, canManage, canView}
+			}
+			if !yield(row) {
+				it.Close()
+				return
+			}
+		}
+		if err := it.Close(); err != nil {
+			log.Fatalf("[scylladb] index_compare: read %s: %v", c.src, err)
+		}
+	}
+	total := 0
+	insertRows(session, &total, c.dst, iter.Seq[[]any](rows), func(b *gocql.Batch, row []any) {
+		b.Query(insert, row...)
+	})
+}
+
+func dropIndexVariant(ctx context.Context, session *gocql.Session, v indexVariant) {
+	for _, c := range v.copies {
+		qctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+		// Dropping the base table drops its indexes.
+		if err := session.Query("DROP TABLE IF EXISTS " + c.dst).WithContext(qctx).Exec(); err != nil {
+			log.Printf("[scylladb] index_compare: warning: drop %s failed: %v", c.dst, err)
+		}
+		cancel()
+	}
+}
+
+// estimatedSize returns the size of table from system.size_estimates, the
+// mean partition size times the partition count summed over the token
+// ranges, or "?" if it cannot be read.
+func estimatedSize(ctx context.Context, session *gocql.Session, table string) string {
+	it := session.Query(`SELECT mean_partition_size, partitions_count FROM system.size_estimates WHERE keyspace_name = ? AND table_name = ?`,
+		utils.Namespaced(utils.GetEnvWithDefault("SCYLLA_KEYSPACE", "rlp")), table).WithContext(ctx).Iter()
+	var mean, count, total int64
+	for it.Scan(&mean, &count) {
+		total += mean * count
+	}
+	if err := it.Close(); err != nil {
+		return "?"
+	}
+	return fmt.Sprintf("%.1fMiB", float64(total)/(1<<20))
+}
+
+// runIndexQuery times cql iters times and returns the rows of the last
+// successful iteration (for checks, how many found a grant). Pairs and
+// resources are taken round robin.
+func runIndexQuery(session *gocql.Session, variant string, q indexQuery, cql string, pairs [][2]int, userID string, iters int) int {
+	durations := make([]time.Duration, 0, iters)
+	rows, found := 0, 0
+	errs := utils.NewErrorTally()
+	for i := range iters {
+		p := pairs[i%len(pairs)]
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		start := time.Now()
+		var err error
+		n := 0
+		switch q.arg {
+		case "pair":
+			err = session.Query(cql, p[0], p[1]).WithContext(ctx).Scan(&n)
+		case "user":
+			err = countRows(ctx, session, cql, userID, &n)
+		case "resource":
+			err = countRows(ctx, session, cql, p[0], &n)
+		}
+		dur := time.Since(start)
+		cancel()
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[scylladb] [index_compare] variant=%s query=%s iter=%d failed class=%s: %v", variant, q.name, i, class, err)
+			continue
+		}
+		durations = append(durations, dur)
+		if q.arg == "pair" {
+			if n > 0 {
+				found++
+			}
+			rows = found
+		} else {
+			rows = n
+		}
+	}
+	log.Printf("[scylladb] [index_compare] variant=%s query=%s DONE: iters=%d rows=%d %s", variant, q.name, len(durations), rows, utils.LatencySummary(durations))
+	log.Printf("[scylladb] [index_compare] variant=%s query=%s ERRORS: %s", variant, q.name, errs.Summary(iters))
+	return rows
+}
+
+// countRows streams cql for arg and stores the number of rows in n.
+func countRows(ctx context.Context, session *gocql.Session, cql string, arg any, n *int) error {
+	return streamQuery(ctx, session, cql, []any{arg}, func(it *gocql.Iter) error {
+		var id int
+		for it.Scan(&id) {
+			*n++
+		}
+		return nil
+	})
+}
@@ -2,25 +2,19 @@ package scylladb
 
 import (
 	"context"
-	"encoding/csv"
-	"io"
+	"iter"
 	"log"
-	"os"
-	"path/filepath"
 	"runtime"
 	"sort"
-	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gocql/gocql"
 
+	"test-tls/dataset"
 	"test-tls/infrastructure"
-)
-
-const (
-	dataDir = "data"
+	"test-tls/utils"
 )
 
 const (
@@ -54,8 +48,8 @@ func (s intSet) has(v int) bool {
 //	org_memberships.csv:    org_id,user_id,role        // role in {member,admin}
 //	group_memberships.csv:  group_id,user_id,role      // role in {direct_member,direct_manager}
 //	group_hierarchy.csv:    parent_group_id,child_group_id,relation  // relation in {member_group,manager_group}
-//	resources.csv:          resource_id,org_id
-//	resource_acl.csv:       resource_id,subject_type,subject_id,relation
+//	resources.csv:          resource_id,org_id[,created_at,updated_at]
+//	resource_acl.csv:       resource_id,subject_type,subject_id,relation[,valid_from,valid_until,created_at]
 //	                        // relation: manager_user/viewer_user for users, manager_group/viewer_group for groups
 //
 // Permission semantics compiled with nested group expansion:
@@ -82,18 +76,20 @@ func ScylladbCreateData() {
 
 	start := time.Now()
 	log.Printf("[scylladb] == Loading CSV data into ScyllaDB ==")
+	utils.MarkDatasetLoading("scylladb", datasetStore{session})
 
 	clearTables(session)
 
-	loadOrganizations(ctx, session)
-	loadUsers(ctx, session)
-	loadGroups(ctx, session)
+	total := 0
+	loadOrganizations(ctx, session, &total)
+	loadUsers(ctx, session, &total)
+	loadGroups(ctx, session, &total)
 
-	orgAdmins, orgMembers := loadOrgMemberships(ctx, session)
-	groupMembers := loadGroupMemberships(ctx, session)
-	groupHierarchy := loadGroupHierarchy(ctx, session)
-	resourceOrg := loadResources(ctx, session)
-	directUserManagers, directUserViewers, groupManagers, groupViewers := loadResourceACL(ctx, session)
+	orgAdmins, orgMembers := loadOrgMemberships(ctx, session, &total)
+	groupMembers := loadGroupMemberships(ctx, session, &total)
+	groupHierarchy := loadGroupHierarchy(ctx, session, &total)
+	resourceOrg := loadResources(ctx, session, &total)
+	directUserManagers, directUserViewers, groupManagers, groupViewers := loadResourceACL(ctx, session, &total)
 
 	// Precompute group membership expansion for fast lookups
 	buildGroupMembersExpanded(ctx, session, groupMembers, groupHierarchy)
@@ -111,6 +107,7 @@ func ScylladbCreateData() {
 		groupManagers,
 		groupViewers,
 	)
+	utils.RecordDataset("scylladb", datasetStore{session})
 
 	elapsed := time.Since(start).Truncate(time.Millisecond)
 	log.Printf("[scylladb] ScyllaDB data load DONE: elapsed=%s", elapsed)
@@ -145,27 +142,9 @@ func clearTables(session *gocql.Session) {
 }
 
 // =========================
-// CSV helpers
+// Batch helpers
 // =========================
 
-func openCSV(name string) (*csv.Reader, *os.File) {
-	full := filepath.Join(dataDir, name)
-	f, err := os.Open(full)
-	if err != nil {
-		log.Fatalf("[scylladb] open %s: %v", full, err)
-	}
-	r := csv.NewReader(f)
-	return r, f
-}
-
-func mustAtoi(s, field string) int {
-	v, err := strconv.Atoi(s)
-	if err != nil {
-		log.Fatalf("[scylladb] parse int for %s %q failed: %v", field, s, err)
-	}
-	return v
-}
-
 // execBatch executes the provided batch (if not empty) and returns a fresh batch.
 func execBatch(session *gocql.Session, b *gocql.Batch, name string) *gocql.Batch {
 	if b == nil || len(b.Entries) == 0 {
@@ -177,135 +156,59 @@ func execBatch(session *gocql.Session, b *gocql.Batch, name string) *gocql.Batch
 	return session.NewBatch(gocql.UnloggedBatch)
 }
 
-// =========================
-// Load organizations
-// =========================
-
-func loadOrganizations(ctx context.Context, session *gocql.Session) {
-	r, f := openCSV("organizations.csv")
-	defer f.Close()
-
-	// header: org_id
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[scylladb] read organizations header: %v", err)
-	}
-
-	count := 0
-	batch := session.NewBatch(gocql.UnloggedBatch)
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[scylladb] organizations: read row failed: %v", err)
-		}
-		if len(rec) < 1 {
-			log.Fatalf("[scylladb] organizations: invalid row: %#v", rec)
-		}
-
-		orgID := mustAtoi(rec[0], "organizations.org_id")
-
-		batch.Query("INSERT INTO organizations (org_id) VALUES (?)", orgID)
-		count++
-
-		if len(batch.Entries) >= insertBatchSize {
-			batch = execBatch(session, batch, "organizations")
+// insertRows writes every record of rows into table through unlogged batches
+// of insertBatchSize records; add queues the statements for one record.
+func insertRows[T any](session *gocql.Session, total *int, table string, rows iter.Seq[T], add func(*gocql.Batch, T)) {
+	progress := dataset.NewProgress("scylladb", table, total)
+	for records := range dataset.Batches(rows, insertBatchSize) {
+		batch := session.NewBatch(gocql.UnloggedBatch)
+		for _, rec := range records {
+			add(batch, rec)
 		}
+		execBatch(session, batch, table)
+		progress.Add(len(records))
 	}
+	progress.Done()
+}
 
-	if len(batch.Entries) > 0 {
-		_ = execBatch(session, batch, "organizations")
+// addTo adds v to the set at m[k].
+func addTo(m map[int]intSet, k, v int) {
+	s, ok := m[k]
+	if !ok {
+		s = make(intSet)
+		m[k] = s
 	}
-	log.Printf("[scylladb] organizations: inserted %d rows", count)
+	s.add(v)
 }
 
 // =========================
-// Load users
+// Load organizations
 // =========================
 
-func loadUsers(ctx context.Context, session *gocql.Session) {
-	r, f := openCSV("users.csv")
-	defer f.Close()
-
-	// header: user_id,org_id
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[scylladb] read users header: %v", err)
-	}
-
-	count := 0
-	batch := session.NewBatch(gocql.UnloggedBatch)
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[scylladb] users: read row failed: %v", err)
-		}
-		if len(rec) < 2 {
-			log.Fatalf("[scylladb] users: invalid row: %#v", rec)
-		}
-
-		userID := mustAtoi(rec[0], "users.user_id")
-		orgID := mustAtoi(rec[1], "users.org_id")
-
-		batch.Query("INSERT INTO users (user_id, org_id) VALUES (?, ?)", userID, orgID)
-		count++
+func loadOrganizations(ctx context.Context, session *gocql.Session, total *int) {
+	insertRows(session, total, "organizations", dataset.Organizations(), func(b *gocql.Batch, o dataset.Organization) {
+		b.Query("INSERT INTO organizations (org_id) VALUES (?)", o.ID.N)
+	})
+}
 
-		if len(batch.Entries) >= insertBatchSize {
-			batch = execBatch(session, batch, "users")
-		}
-	}
+// =========================
+// Load users
+// =========================
 
-	if len(batch.Entries) > 0 {
-		_ = execBatch(session, batch, "users")
-	}
-	log.Printf("[scylladb] users: inserted %d rows", count)
+func loadUsers(ctx context.Context, session *gocql.Session, total *int) {
+	insertRows(session, total, "users", dataset.Users(), func(b *gocql.Batch, u dataset.User) {
+		b.Query("INSERT INTO users (user_id, org_id) VALUES (?, ?)", u.ID.N, u.Org.N)
+	})
 }
 
 // =========================
 // Load groups
 // =========================
 
-func loadGroups(ctx context.Context, session *gocql.Session) {
-	r, f := openCSV("groups.csv")
-	defer f.Close()
-
-	// header: group_id,org_id
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[scylladb] read groups header: %v", err)
-	}
-
-	count := 0
-	batch := session.NewBatch(gocql.UnloggedBatch)
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[scylladb] groups: read row failed: %v", err)
-		}
-		if len(rec) < 2 {
-			log.Fatalf("[scylladb] groups: invalid row: %#v", rec)
-		}
-
-		groupID := mustAtoi(rec[0], "groups.group_id")
-		orgID := mustAtoi(rec[1], "groups.org_id")
-
-		batch.Query("INSERT INTO groups (group_id, org_id) VALUES (?, ?)", groupID, orgID)
-		count++
-
-		if len(batch.Entries) >= insertBatchSize {
-			batch = execBatch(session, batch, "groups")
-		}
-	}
-
-	if len(batch.Entries) > 0 {
-		_ = execBatch(session, batch, "groups")
-	}
-	log.Printf("[scylladb] groups: inserted %d rows", count)
+func loadGroups(ctx context.Context, session *gocql.Session, total *int) {
+	insertRows(session, total, "groups", dataset.Groups(), func(b *gocql.Batch, g dataset.Group) {
+		b.Query("INSERT INTO groups (group_id, org_id) VALUES (?, ?)", g.ID.N, g.Org.N)
+	})
 }
 
 // =========================
@@ -318,65 +221,17 @@ func loadGroups(ctx context.Context, session *gocql.Session) {
 //
 //	orgAdmins[orgID]  -> set of userID
 //	orgMembers[orgID] -> set of userID
-func loadOrgMemberships(ctx context.Context, session *gocql.Session) (map[int]intSet, map[int]intSet) {
-	r, f := openCSV("org_memberships.csv")
-	defer f.Close()
-
-	// header: org_id,user_id,role
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[scylladb] read org_memberships header: %v", err)
-	}
-
+func loadOrgMemberships(ctx context.Context, session *gocql.Session, total *int) (map[int]intSet, map[int]intSet) {
 	orgAdmins := make(map[int]intSet)
 	orgMembers := make(map[int]intSet)
-
-	count := 0
-	batch := session.NewBatch(gocql.UnloggedBatch)
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[scylladb] org_memberships: read row failed: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[scylladb] org_memberships: invalid row: %#v", rec)
-		}
-
-		orgID := mustAtoi(rec[0], "org_memberships.org_id")
-		userID := mustAtoi(rec[1], "org_memberships.user_id")
-		role := rec[2]
-
-		batch.Query("INSERT INTO org_memberships (org_id, user_id, role) VALUES (?, ?, ?)", orgID, userID, role)
-		count++
-		if len(batch.Entries) >= insertBatchSize {
-			batch = execBatch(session, batch, "org_memberships")
-		}
-
-		switch role {
-		case "admin":
-			s, ok := orgAdmins[orgID]
-			if !ok {
-				s = make(intSet)
-				orgAdmins[orgID] = s
-			}
-			s.add(userID)
-		case "member":
-			s, ok := orgMembers[orgID]
-			if !ok {
-				s = make(intSet)
-				orgMembers[orgID] = s
-			}
-			s.add(userID)
-		default:
-			log.Fatalf("[scylladb] org_memberships: unknown role %q", role)
-		}
-	}
-	if len(batch.Entries) > 0 {
-		_ = execBatch(session, batch, "org_memberships")
-	}
-	log.Printf("[scylladb] org_memberships: inserted %d rows", count)
+	insertRows(session, total, "org_memberships", dataset.OrgMemberships(), func(b *gocql.Batch, m dataset.OrgMembership) {
+		b.Query("INSERT INTO org_memberships (org_id, user_id, role) VALUES (?, ?, ?)", m.Org.N, m.User.N, m.Role)
+		if m.Role == "admin" {
+			addTo(orgAdmins, m.Org.N, m.User.N)
+		} else {
+			addTo(orgMembers, m.Org.N, m.User.N)
+		}
+	})
 	return orgAdmins, orgMembers
 }
 
@@ -389,55 +244,13 @@ func loadOrgMemberships(ctx context.Context, session *gocql.Session) (map[int]in
 // Builds:
 //
 //	groupMembers[groupID] -> set of userID
-func loadGroupMemberships(ctx context.Context, session *gocql.Session) map[int]intSet {
-	r, f := openCSV("group_memberships.csv")
-	defer f.Close()
-
-	// header: group_id,user_id,role
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[scylladb] read group_memberships header: %v", err)
-	}
-
+func loadGroupMemberships(ctx context.Context, session *gocql.Session, total *int) map[int]intSet {
 	groupMembers := make(map[int]intSet)
-
-	count := 0
-	batch := session.NewBatch(gocql.UnloggedBatch)
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[scylladb] group_memberships: read row failed: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[scylladb] group_memberships: invalid row: %#v", rec)
-		}
-
-		groupID := mustAtoi(rec[0], "group_memberships.group_id")
-		userID := mustAtoi(rec[1], "group_memberships.user_id")
-		role := rec[2]
-
-		batch.Query("INSERT INTO group_memberships (user_id, group_id, role) VALUES (?, ?, ?)", userID, groupID, role)
-		count++
-		if len(batch.Entries) >= insertBatchSize {
-			batch = execBatch(session, batch, "group_memberships")
-		}
-
-		// role is currently always "member"
-		s, ok := groupMembers[groupID]
-		if !ok {
-			s = make(intSet)
-			groupMembers[groupID] = s
-		}
-		s.add(userID)
-	}
-
-	if len(batch.Entries) > 0 {
-		_ = execBatch(session, batch, "group_memberships")
-	}
-
-	log.Printf("[scylladb] group_memberships: inserted %d rows", count)
+	insertRows(session, total, "group_memberships", dataset.GroupMemberships(), func(b *gocql.Batch, m dataset.GroupMembership) {
+		b.Query("INSERT INTO group_memberships (user_id, group_id, role) VALUES (?, ?, ?)", m.User.N, m.Group.N, m.Role)
+		// managers count as members of the group
+		addTo(groupMembers, m.Group.N, m.User.N)
+	})
 	return groupMembers
 }
 
@@ -450,66 +263,19 @@ func loadGroupMemberships(ctx context.Context, session *gocql.Session) map[int]i
 // Builds:
 //
 //	groupHierarchy[parentID] -> map of (childID, relation)
-func loadGroupHierarchy(ctx context.Context, session *gocql.Session) map[int]map[int]string {
-	full := filepath.Join(dataDir, "group_hierarchy.csv")
-	f, err := os.Open(full)
-	if err != nil {
-		if os.IsNotExist(err) {
-			log.Printf("[scylladb] group_hierarchy.csv not found, skipping nested groups")
-			return make(map[int]map[int]string)
-		}
-		log.Fatalf("[scylladb] open group_hierarchy.csv: %v", err)
-	}
-	defer f.Close()
-	r := csv.NewReader(f)
-
-	// header: parent_group_id,child_group_id,relation
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[scylladb] read group_hierarchy header: %v", err)
-	}
-
+func loadGroupHierarchy(ctx context.Context, session *gocql.Session, total *int) map[int]map[int]string {
 	groupHierarchy := make(map[int]map[int]string)
-	count := 0
-	batch := session.NewBatch(gocql.UnloggedBatch)
-
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[scylladb] group_hierarchy: read row failed: %v", err)
-		}
-		if len(rec) < 3 {
-			log.Fatalf("[scylladb] group_hierarchy: invalid row: %#v", rec)
-		}
-
-		parentID := mustAtoi(rec[0], "group_hierarchy.parent_group_id")
-		childID := mustAtoi(rec[1], "group_hierarchy.child_group_id")
-		relation := rec[2]
-
-		batch.Query(
+	insertRows(session, total, "group_hierarchy", dataset.GroupHierarchy(), func(b *gocql.Batch, e dataset.GroupEdge) {
+		b.Query(
 			"INSERT INTO group_hierarchy (parent_group_id, child_group_id, relation) VALUES (?, ?, ?)",
-			parentID, childID, relation,
+			e.Parent.N, e.Child.N, e.Relation,
 		)
-		count++
-
-		if len(batch.Entries) >= insertBatchSize {
-			batch = execBatch(session, batch, "group_hierarchy")
-		}
-
 		// Build in-memory hierarchy map
-		if _, ok := groupHierarchy[parentID]; !ok {
-			groupHierarchy[parentID] = make(map[int]string)
+		if _, ok := groupHierarchy[e.Parent.N]; !ok {
+			groupHierarchy[e.Parent.N] = make(map[int]string)
 		}
-		groupHierarchy[parentID][childID] = relation
-	}
-
-	if len(batch.Entries) > 0 {
-		_ = execBatch(session, batch, "group_hierarchy")
-	}
-
-	log.Printf("[scylladb] group_hierarchy: inserted %d rows", count)
+		groupHierarchy[e.Parent.N][e.Child.N] = e.Relation
+	})
 	return groupHierarchy
 }
 
@@ -658,54 +424,17 @@ func buildGroupMembersExpanded(
 // Load resources
 // =========================
 //
-// resources.csv: resource_id,org_id
+// resources.csv: resource_id,org_id[,created_at,updated_at]
 //
 // Builds:
 //
 //	resourceOrg[resourceID] -> orgID
-func loadResources(ctx context.Context, session *gocql.Session) map[int]int {
-	r, f := openCSV("resources.csv")
-	defer f.Close()
-
-	// header: resource_id,org_id
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[scylladb] read resources header: %v", err)
-	}
-
+func loadResources(ctx context.Context, session *gocql.Session, total *int) map[int]int {
 	resourceOrg := make(map[int]int)
-	count := 0
-	batch := session.NewBatch(gocql.UnloggedBatch)
-
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[scylladb] resources: read row failed: %v", err)
-		}
-		if len(rec) < 2 {
-			log.Fatalf("[scylladb] resources: invalid row: %#v", rec)
-		}
-
-		resID := mustAtoi(rec[0], "resources.resource_id")
-		orgID := mustAtoi(rec[1], "resources.org_id")
-
-		batch.Query("INSERT INTO resources (resource_id, org_id) VALUES (?, ?)", resID, orgID)
-		count++
-
-		if len(batch.Entries) >= insertBatchSize {
-			batch = execBatch(session, batch, "resources")
-		}
-
-		resourceOrg[resID] = orgID
-	}
-
-	if len(batch.Entries) > 0 {
-		_ = execBatch(session, batch, "resources")
-	}
-
-	log.Printf("[scylladb] resources: inserted %d rows", count)
+	insertRows(session, total, "resources", dataset.Resources(), func(b *gocql.Batch, r dataset.Resource) {
+		b.Query("INSERT INTO resources (resource_id, org_id, created_at, updated_at) VALUES (?, ?, ?, ?)", r.ID.N, r.Org.N, r.CreatedAt, r.UpdatedAt)
+		resourceOrg[r.ID.N] = r.Org.N
+	})
 	return resourceOrg
 }
 
@@ -713,14 +442,15 @@ func loadResources(ctx context.Context, session *gocql.Session) map[int]int {
 // Load resource_acl
 // =========================
 //
-// resource_acl.csv: resource_id,subject_type,subject_id,relation
+// resource_acl.csv: resource_id,subject_type,subject_id,relation[,valid_from,valid_until,created_at]
 //
 // Writes into:
 //
 //	resource_acl_by_resource
-//	resource_acl_by_subject
+//	resource_acl_by_subject (grants active now only)
 //
-// Builds:
+// Builds, from the grants active now (a grant expiring later stays until the
+// next load, as in the materialized views of the SQL backends):
 //
 //	directUserManagers[resID] -> set of userID
 //	directUserViewers[resID]  -> set of userID
@@ -729,115 +459,30 @@ func loadResources(ctx context.Context, session *gocql.Session) map[int]int {
 func loadResourceACL(
 	ctx context.Context,
 	session *gocql.Session,
+	total *int,
 ) (map[int]intSet, map[int]intSet, map[int]intSet, map[int]intSet) {
-	r, f := openCSV("resource_acl.csv")
-	defer f.Close()
-
-	// header: resource_id,subject_type,subject_id,relation
-	if _, err := r.Read(); err != nil {
-		log.Fatalf("[scylladb] read resource_acl header: %v", err)
-	}
-
-	directUserManagers := make(map[int]intSet)
-	directUserViewers := make(map[int]intSet)
-	groupManagers := make(map[int]intSet)
-	groupViewers := make(map[int]intSet)
-
-	count := 0
-	batchByResource := session.NewBatch(gocql.UnloggedBatch)
-	batchBySubject := session.NewBatch(gocql.UnloggedBatch)
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("[scylladb] resource_acl: read row failed: %v", err)
-		}
-		if len(rec) < 4 {
-			log.Fatalf("[scylladb] resource_acl: invalid row: %#v", rec)
-		}
-
-		resID := mustAtoi(rec[0], "resource_acl.resource_id")
-		subjectType := rec[1]
-		subjectID := mustAtoi(rec[2], "resource_acl.subject_id")
-		relation := rec[3]
-
-		// Insert into resource_acl_by_resource (batched)
-		batchByResource.Query(
-			"INSERT INTO resource_acl_by_resource (resource_id, relation, subject_type, subject_id) VALUES (?, ?, ?, ?)",
-			resID, relation, subjectType, subjectID,
+	now := time.Now()
+	grants := map[string]map[int]intSet{
+		"manager_user":  {},
+		"viewer_user":   {},
+		"manager_group": {},
+		"viewer_group":  {},
+	}
+	insertRows(session, total, "resource_acl", dataset.ResourceACL(), func(b *gocql.Batch, a dataset.ACL) {
+		b.Query(
+			"INSERT INTO resource_acl_by_resource (resource_id, relation, subject_type, subject_id, valid_from, valid_until, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			a.Resource.N, a.Relation, a.SubjectType, a.Subject.N, a.Window.From, a.Window.Until, a.CreatedAt,
 		)
-		// Insert into resource_acl_by_subject (batched)
-		batchBySubject.Query(
+		if !a.Window.ActiveAt(now) {
+			return
+		}
+		b.Query(
 			"INSERT INTO resource_acl_by_subject (subject_type, subject_id, relation, resource_id) VALUES (?, ?, ?, ?)",
-			subjectType, subjectID, relation, resID,
+			a.SubjectType, a.Subject.N, a.Relation, a.Resource.N,
 		)
-
-		count++
-
-		if len(batchByResource.Entries) >= insertBatchSize {
-			batchByResource = execBatch(session, batchByResource, "resource_acl_by_resource")
-		}
-		if len(batchBySubject.Entries) >= insertBatchSize {
-			batchBySubject = execBatch(session, batchBySubject, "resource_acl_by_subject")
-		}
-
-		switch subjectType {
-		case "user":
-			switch relation {
-			case "manager_user", "manager":
-				s, ok := directUserManagers[resID]
-				if !ok {
-					s = make(intSet)
-					directUserManagers[resID] = s
-				}
-				s.add(subjectID)
-			case "viewer_user", "viewer":
-				s, ok := directUserViewers[resID]
-				if !ok {
-					s = make(intSet)
-					directUserViewers[resID] = s
-				}
-				s.add(subjectID)
-			default:
-				log.Fatalf("[scylladb] resource_acl: unknown relation for user: %q", relation)
-			}
-
-		case "group":
-			switch relation {
-			case "manager_group", "manager":
-				s, ok := groupManagers[resID]
-				if !ok {
-					s = make(intSet)
-					groupManagers[resID] = s
-				}
-				s.add(subjectID)
-			case "viewer_group", "viewer":
-				s, ok := groupViewers[resID]
-				if !ok {
-					s = make(intSet)
-					groupViewers[resID] = s
-				}
-				s.add(subjectID)
-			default:
-				log.Fatalf("[scylladb] resource_acl: unknown relation for group: %q", relation)
-			}
-
-		default:
-			log.Fatalf("[scylladb] resource_acl: unknown subject_type: %q", subjectType)
-		}
-	}
-
-	if len(batchByResource.Entries) > 0 {
-		_ = execBatch(session, batchByResource, "resource_acl_by_resource")
-	}
-	if len(batchBySubject.Entries) > 0 {
-		_ = execBatch(session, batchBySubject, "resource_acl_by_subject")
-	}
-
-	log.Printf("[scylladb] resource_acl: inserted %d rows", count)
-	return directUserManagers, directUserViewers, groupManagers, groupViewers
+		addTo(grants[a.Relation], a.Resource.N, a.Subject.N)
+	})
+	return grants["manager_user"], grants["viewer_user"], grants["manager_group"], grants["viewer_group"]
 }
 
 // =========================
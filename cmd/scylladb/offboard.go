@@ -0,0 +1,107 @@
+package scylladb
+
+import (
+	"context"
+	"log"
+
+	"github.com/gocql/gocql"
+
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// userOffboarder deletes users row by row across the denormalized tables
+// (utils.UserOffboarder). Partitions keyed by the user are deleted whole;
+// rows in partitions keyed otherwise are found through the user-keyed copy
+// (resource_acl_by_subject, user_resource_perms_by_user), the user_id index
+// of group_members_expanded, or ALLOW FILTERING for org_memberships.
+type userOffboarder struct {
+	session *gocql.Session
+}
+
+func (o userOffboarder) Offboard(ctx context.Context, userID string) (int, error) {
+	id, err := ids.Parse(ids.User, userID)
+	if err != nil {
+		return 0, err
+	}
+	rows := 0
+	exec := func(stmt string, args ...any) error {
+		rows++
+		return o.session.Query(stmt, args...).WithContext(ctx).Exec()
+	}
+
+	var key int
+	var role, relation string
+	it := o.session.Query(`SELECT org_id, role FROM org_memberships WHERE user_id = ? ALLOW FILTERING`, id).WithContext(ctx).Iter()
+	for it.Scan(&key, &role) {
+		if err := exec(`DELETE FROM org_memberships WHERE org_id = ? AND user_id = ? AND role = ?`, key, id, role); err != nil {
+			it.Close()
+			return rows, err
+		}
+	}
+	if err := it.Close(); err != nil {
+		return rows, err
+	}
+
+	it = o.session.Query(`SELECT group_id, role FROM group_members_expanded WHERE user_id = ?`, id).WithContext(ctx).Iter()
+	for it.Scan(&key, &role) {
+		if err := exec(`DELETE FROM group_members_expanded WHERE group_id = ? AND user_id = ? AND role = ?`, key, id, role); err != nil {
+			it.Close()
+			return rows, err
+		}
+	}
+	if err := it.Close(); err != nil {
+		return rows, err
+	}
+
+	it = o.session.Query(`SELECT relation, resource_id FROM resource_acl_by_subject WHERE subject_type = 'user' AND subject_id = ?`, id).WithContext(ctx).Iter()
+	for it.Scan(&relation, &key) {
+		if err := exec(`DELETE FROM resource_acl_by_resource WHERE resource_id = ? AND relation = ? AND subject_type = 'user' AND subject_id = ?`, key, relation, id); err != nil {
+			it.Close()
+			return rows, err
+		}
+	}
+	if err := it.Close(); err != nil {
+		return rows, err
+	}
+
+	it = o.session.Query(`SELECT resource_id FROM user_resource_perms_by_user WHERE user_id = ?`, id).WithContext(ctx).Iter()
+	for it.Scan(&key) {
+		if err := exec(`DELETE FROM user_resource_perms_by_resource WHERE resource_id = ? AND user_id = ?`, key, id); err != nil {
+			it.Close()
+			return rows, err
+		}
+	}
+	if err := it.Close(); err != nil {
+		return rows, err
+	}
+
+	// the user-keyed partitions last, since the deletes above read them
+	for _, stmt := range []string{
+		`DELETE FROM resource_acl_by_subject WHERE subject_type = 'user' AND subject_id = ?`,
+		`DELETE FROM user_resource_perms_by_user WHERE user_id = ?`,
+		`DELETE FROM group_memberships WHERE user_id = ?`,
+		`DELETE FROM users WHERE user_id = ?`,
+	} {
+		if err := exec(stmt, id); err != nil {
+			return rows, err
+		}
+	}
+	return rows, nil
+}
+
+// ScylladbOffboard benchmarks deleting the users of BENCH_OFFBOARD_USERS and
+// checks they hold nothing afterwards; see utils.RunOffboarding. rows counts
+// the DELETE statements issued.
+func ScylladbOffboard() {
+	ctx := context.Background()
+	session, cleanup, err := infrastructure.NewScyllaFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[scylladb] offboard: connect failed: %v", err)
+	}
+	defer cleanup()
+
+	utils.RunOffboarding("scylladb", userOffboarder{session: session}, newChecker(session))
+	log.Println("[scylladb] == ScyllaDB user offboarding DONE ==")
+}
@@ -0,0 +1,94 @@
+package scylladb
+
+import (
+	"context"
+	"log"
+
+	"github.com/gocql/gocql"
+
+	"test-tls/authz"
+	scyllaauthz "test-tls/authz/scylladb"
+	"test-tls/ids"
+	"test-tls/infrastructure"
+	"test-tls/utils"
+)
+
+// checkPermissionScylla is the check timed by the check_* scenarios.
+func checkPermissionScylla(ctx context.Context, session *gocql.Session, resourceID, userID any, relation string) (bool, error) {
+	return scyllaauthz.Check(ctx, session, resourceID, userID, relation)
+}
+
+// lookupResourcesScylla is the lookup timed by the lookup_resources_*
+// scenarios.
+func lookupResourcesScylla(ctx context.Context, session *gocql.Session, userID, relation string, handle func(resID int)) error {
+	return scyllaauthz.LookupResources(ctx, session, userID, relation, handle)
+}
+
+// newChecker returns the effective permission queries over session with the
+// ids of RLP_ID_FORMAT.
+func newChecker(session *gocql.Session) authz.Checker {
+	checker, err := scyllaauthz.NewChecker(session, scyllaauthz.Config{IDFormat: ids.CurrentFormat()})
+	if err != nil {
+		log.Fatalf("[scylladb] %v", err)
+	}
+	return checker
+}
+
+// ScylladbServe serves Check/Lookup over HTTP using the benchmark queries.
+func ScylladbServe() {
+	session, cleanup, err := infrastructure.NewScyllaFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[scylladb] failed to create session: %v", err)
+	}
+	defer cleanup()
+
+	utils.Serve("scylladb", newChecker(session))
+}
+
+// ScylladbReplayAudit re-runs a recorded audit file (REPLAY_AUDIT_FILE) through
+// the benchmark queries.
+func ScylladbReplayAudit() {
+	session, cleanup, err := infrastructure.NewScyllaFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[scylladb] failed to create session: %v", err)
+	}
+	defer cleanup()
+
+	utils.ReplayAudit("scylladb", newChecker(session))
+}
+
+// ScylladbVerifyFixture checks the benchmark queries against the known
+// answers of the fixture dataset (see utils.VerifyFixture).
+func ScylladbVerifyFixture() {
+	session, cleanup, err := infrastructure.NewScyllaFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[scylladb] failed to create session: %v", err)
+	}
+	defer cleanup()
+
+	utils.VerifyFixture("scylladb", newChecker(session))
+}
+
+// ScylladbExportPermissions writes a permission snapshot of the benchmark
+// queries for diff-permissions (see utils.ExportPermissions).
+func ScylladbExportPermissions() {
+	session, cleanup, err := infrastructure.NewScyllaFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[scylladb] failed to create session: %v", err)
+	}
+	defer cleanup()
+
+	utils.ExportPermissions("scylladb", newChecker(session))
+}
+
+// ScylladbWorker replays the shard a distributed coordinator hands out and
+// streams the results back (see utils.RunWorker).
+func ScylladbWorker() {
+	session, cleanup, err := infrastructure.NewScyllaFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("[scylladb] failed to create session: %v", err)
+	}
+	defer cleanup()
+
+	utils.RunWorker("scylladb", newChecker(session))
+}
@@ -0,0 +1,24 @@
+package scylladb
+
+import (
+	scyllaauthz "test-tls/authz/scylladb"
+	"test-tls/ids"
+	"test-tls/utils"
+)
+
+// ScylladbPrintQueries prints the CQL of the read scenarios with sampled
+// parameters instead of running it (see utils.PrintQueries). The check_*
+// scenarios read the direct grant of the pair, so the org admin and group
+// samples come back denied here unless the pair also has one.
+func ScylladbPrintQueries() {
+	utils.PrintQueries("scylladb", func(s utils.QuerySample) string {
+		user := utils.SampleInt(ids.User, s.User)
+		switch s.Scenario {
+		case "check_time_bounded_direct_user":
+			return utils.RenderSQL(scyllaauthz.CheckCQL, utils.SampleInt(ids.Resource, s.Resource), s.Relation, user)
+		case "lookup_resources_manage_super", "lookup_resources_view_regular":
+			return utils.RenderSQL(scyllaauthz.LookupCQL, user, scyllaauthz.Relations[s.Permission])
+		}
+		return utils.RenderSQL(scyllaauthz.CheckCQL, utils.SampleInt(ids.Resource, s.Resource), scyllaauthz.Relations[s.Permission], user)
+	})
+}
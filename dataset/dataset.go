@@ -0,0 +1,373 @@
+// Package dataset reads the generated CSVs in utils.DataDir() as typed
+// records, so every backend loader parses the files the same way.
+//
+// Each table has an iterator (Organizations, Users, ..., ResourceBans) that
+// opens the file, skips the header, applies the org scope (RLP_ORGS, see
+// utils.ScopeCSV), parses ids with package ids and normalizes the legacy
+// role and relation spellings. A missing file is logged and yields nothing;
+// a malformed row is fatal, with the file and row in the message. Batches
+// groups any of them for bulk writes, and Progress logs the rows written in
+// the format every loader shares.
+package dataset
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"iter"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"test-tls/ids"
+	"test-tls/utils"
+)
+
+// ID is an entity id as written in the CSV, together with the int behind it.
+// Integer-column backends store N; string backends (SpiceDB, MongoDB) store
+// Raw, so ids survive a change of RLP_ID_FORMAT unchanged.
+type ID struct {
+	Raw string
+	N   int
+}
+
+func (id ID) String() string { return id.Raw }
+
+// Organization is a row of organizations.csv: org_id.
+type Organization struct {
+	ID ID
+}
+
+// User is a row of users.csv: user_id,org_id.
+type User struct {
+	ID, Org ID
+}
+
+// Group is a row of groups.csv: group_id,org_id.
+type Group struct {
+	ID, Org ID
+}
+
+// OrgMembership is a row of org_memberships.csv: org_id,user_id,role. Role is
+// "admin" or "member"; any other value reads as "member".
+type OrgMembership struct {
+	Org, User ID
+	Role      string
+}
+
+// GroupMembership is a row of group_memberships.csv: group_id,user_id,role.
+// Role is "direct_manager" or "direct_member"; the legacy "admin" and
+// "manager" read as "direct_manager" and any other value as "direct_member".
+type GroupMembership struct {
+	Group, User ID
+	Role        string
+}
+
+// GroupEdge is a row of group_hierarchy.csv:
+// parent_group_id,child_group_id,relation. Relation is "member_group" (child
+// members are parent members) or "manager_group" (child managers are parent
+// managers).
+type GroupEdge struct {
+	Parent, Child ID
+	Relation      string
+}
+
+// Resource is a row of resources.csv:
+// resource_id,org_id[,created_at,updated_at]. The timestamps are nil for
+// files written before the columns existed.
+type Resource struct {
+	ID, Org              ID
+	CreatedAt, UpdatedAt *time.Time
+}
+
+// ACL is a row of resource_acl.csv:
+// resource_id,subject_type,subject_id,relation[,valid_from,valid_until[,created_at]].
+// SubjectType is "user" or "group" and Relation one of "manager_user",
+// "viewer_user", "manager_group", "viewer_group"; the legacy "manager" and
+// "viewer" read as the form matching SubjectType. CreatedAt, when the grant
+// was made, is nil for files written before the column existed.
+type ACL struct {
+	Resource    ID
+	SubjectType string
+	Subject     ID
+	Relation    string
+	Window      utils.GrantWindow
+	CreatedAt   *time.Time
+}
+
+// Ban is a row of resource_bans.csv: resource_id,user_id, a user denied view
+// on the resource whatever grants it (authz.BannedUser). It has rows only
+// when generated with RLP_BANNED_RESOURCE_FRACTION, and only the backends
+// that enforce denials load it.
+type Ban struct {
+	Resource, User ID
+}
+
+// Organizations iterates organizations.csv.
+func Organizations() iter.Seq[Organization] {
+	return rows("organizations.csv", 1, func(rec []string) (Organization, error) {
+		org, err := parseID(ids.Org, rec[0])
+		return Organization{ID: org}, err
+	})
+}
+
+// Users iterates users.csv.
+func Users() iter.Seq[User] {
+	return rows("users.csv", 2, func(rec []string) (u User, err error) {
+		if u.ID, err = parseID(ids.User, rec[0]); err != nil {
+			return u, err
+		}
+		u.Org, err = parseID(ids.Org, rec[1])
+		return u, err
+	})
+}
+
+// Groups iterates groups.csv.
+func Groups() iter.Seq[Group] {
+	return rows("groups.csv", 2, func(rec []string) (g Group, err error) {
+		if g.ID, err = parseID(ids.Group, rec[0]); err != nil {
+			return g, err
+		}
+		g.Org, err = parseID(ids.Org, rec[1])
+		return g, err
+	})
+}
+
+// OrgMemberships iterates org_memberships.csv.
+func OrgMemberships() iter.Seq[OrgMembership] {
+	return rows("org_memberships.csv", 3, func(rec []string) (m OrgMembership, err error) {
+		if m.Org, err = parseID(ids.Org, rec[0]); err != nil {
+			return m, err
+		}
+		if m.User, err = parseID(ids.User, rec[1]); err != nil {
+			return m, err
+		}
+		m.Role = "member"
+		if rec[2] == "admin" {
+			m.Role = "admin"
+		}
+		return m, nil
+	})
+}
+
+// GroupMemberships iterates group_memberships.csv.
+func GroupMemberships() iter.Seq[GroupMembership] {
+	return rows("group_memberships.csv", 3, func(rec []string) (m GroupMembership, err error) {
+		if m.Group, err = parseID(ids.Group, rec[0]); err != nil {
+			return m, err
+		}
+		if m.User, err = parseID(ids.User, rec[1]); err != nil {
+			return m, err
+		}
+		m.Role = "direct_member"
+		switch rec[2] {
+		case "direct_manager", "admin", "manager":
+			m.Role = "direct_manager"
+		}
+		return m, nil
+	})
+}
+
+// GroupHierarchy iterates group_hierarchy.csv.
+func GroupHierarchy() iter.Seq[GroupEdge] {
+	return rows("group_hierarchy.csv", 3, func(rec []string) (e GroupEdge, err error) {
+		if e.Parent, err = parseID(ids.Group, rec[0]); err != nil {
+			return e, err
+		}
+		if e.Child, err = parseID(ids.Group, rec[1]); err != nil {
+			return e, err
+		}
+		switch rec[2] {
+		case "member_group", "manager_group":
+			e.Relation = rec[2]
+		default:
+			return e, fmt.Errorf("unknown group hierarchy relation %q", rec[2])
+		}
+		return e, nil
+	})
+}
+
+// Resources iterates resources.csv.
+func Resources() iter.Seq[Resource] {
+	return rows("resources.csv", 2, func(rec []string) (r Resource, err error) {
+		if r.ID, err = parseID(ids.Resource, rec[0]); err != nil {
+			return r, err
+		}
+		if r.Org, err = parseID(ids.Org, rec[1]); err != nil {
+			return r, err
+		}
+		if r.CreatedAt, err = optionalTime(rec, 2); err != nil {
+			return r, fmt.Errorf("created_at: %w", err)
+		}
+		if r.UpdatedAt, err = optionalTime(rec, 3); err != nil {
+			return r, fmt.Errorf("updated_at: %w", err)
+		}
+		return r, nil
+	})
+}
+
+// ResourceACL iterates resource_acl.csv.
+func ResourceACL() iter.Seq[ACL] {
+	return rows("resource_acl.csv", 4, func(rec []string) (a ACL, err error) {
+		if a.Resource, err = parseID(ids.Resource, rec[0]); err != nil {
+			return a, err
+		}
+		a.SubjectType = rec[1]
+		if a.SubjectType != "user" && a.SubjectType != "group" {
+			return a, fmt.Errorf("unknown subject_type %q", rec[1])
+		}
+		if a.Subject, err = parseID(ids.SubjectKind(a.SubjectType), rec[2]); err != nil {
+			return a, err
+		}
+		switch rec[3] {
+		case "manager_user", "viewer_user", "manager_group", "viewer_group":
+			a.Relation = rec[3]
+		case "manager", "viewer":
+			a.Relation = rec[3] + "_" + a.SubjectType
+		default:
+			return a, fmt.Errorf("unknown relation %q", rec[3])
+		}
+		if !strings.HasSuffix(a.Relation, "_"+a.SubjectType) {
+			return a, fmt.Errorf("relation %q does not match subject_type %q", a.Relation, a.SubjectType)
+		}
+		if a.Window, err = utils.ParseGrantWindow(rec); err != nil {
+			return a, err
+		}
+		if a.CreatedAt, err = optionalTime(rec, 6); err != nil {
+			return a, fmt.Errorf("created_at: %w", err)
+		}
+		return a, nil
+	})
+}
+
+// ResourceBans iterates resource_bans.csv.
+func ResourceBans() iter.Seq[Ban] {
+	return rows("resource_bans.csv", 2, func(rec []string) (b Ban, err error) {
+		if b.Resource, err = parseID(ids.Resource, rec[0]); err != nil {
+			return b, err
+		}
+		b.User, err = parseID(ids.User, rec[1])
+		return b, err
+	})
+}
+
+// Batches groups seq into slices of up to size records, for bulk writes. The
+// slice is reused between batches, so the caller must not keep it.
+func Batches[T any](seq iter.Seq[T], size int) iter.Seq[[]T] {
+	return func(yield func([]T) bool) {
+		batch := make([]T, 0, size)
+		for v := range seq {
+			batch = append(batch, v)
+			if len(batch) < size {
+				continue
+			}
+			if !yield(batch) {
+				return
+			}
+			batch = batch[:0]
+		}
+		if len(batch) > 0 {
+			yield(batch)
+		}
+	}
+}
+
+// optionalTime parses column i of rec as RFC 3339, nil when the column is
+// missing or empty.
+func optionalTime(rec []string, i int) (*time.Time, error) {
+	if len(rec) <= i || rec[i] == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, rec[i])
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func parseID(kind ids.Kind, s string) (ID, error) {
+	n, err := ids.Parse(kind, s)
+	return ID{Raw: s, N: n}, err
+}
+
+// rows opens name in the data dir and yields every record after the header
+// through parse. Records shorter than minCols and parse errors are fatal; the
+// message counts rows after the header (of the scoped file under RLP_ORGS).
+func rows[T any](name string, minCols int, parse func(rec []string) (T, error)) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		path := filepath.Join(utils.DataDir(), name)
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			log.Printf("[dataset] %s not found, skipping", path)
+			return
+		}
+		if err != nil {
+			log.Fatalf("[dataset] open %s: %v", path, err)
+		}
+		defer f.Close()
+
+		r := utils.ScopeCSV(name, csv.NewReader(f))
+		if _, err := r.Read(); err != nil {
+			if err == io.EOF {
+				return
+			}
+			log.Fatalf("[dataset] %s: read header: %v", path, err)
+		}
+		for row := 1; ; row++ {
+			rec, err := r.Read()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				log.Fatalf("[dataset] %s row %d: %v", path, row, err)
+			}
+			if len(rec) < minCols {
+				log.Fatalf("[dataset] %s row %d: want at least %d columns, got %#v", path, row, minCols, rec)
+			}
+			v, err := parse(rec)
+			if err != nil {
+				log.Fatalf("[dataset] %s row %d: %v", path, row, err)
+			}
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Progress counts the rows one loader writes for a table, adding them to the
+// run's cumulative total, and logs every 10000 rows and once at the end.
+type Progress struct {
+	engine, table string
+	total         *int
+	count         int
+	start         time.Time
+}
+
+// NewProgress starts counting rows of table for engine into total.
+func NewProgress(engine, table string, total *int) *Progress {
+	return &Progress{engine: engine, table: table, total: total, start: time.Now()}
+}
+
+// Add records n more rows.
+func (p *Progress) Add(n int) {
+	before := p.count / 10000
+	p.count += n
+	*p.total += n
+	if p.count/10000 > before {
+		log.Printf("[%s] Loaded %s progress: %d rows (cumulative=%d) elapsed=%s", p.engine, p.table, p.count, *p.total, time.Since(p.start).Truncate(time.Millisecond))
+	}
+}
+
+// Count is the number of rows recorded so far.
+func (p *Progress) Count() int { return p.count }
+
+// Start is when counting began.
+func (p *Progress) Start() time.Time { return p.start }
+
+// Done logs the table's final count.
+func (p *Progress) Done() {
+	log.Printf("[%s] Loaded %s: %d rows (cumulative=%d) elapsed=%s", p.engine, p.table, p.count, *p.total, time.Since(p.start).Truncate(time.Millisecond))
+}
@@ -0,0 +1,105 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/authzed/grpcutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// authzedDialOptions returns the TLS and bearer token options shared by the
+// authzed_* clients. caCertPath is a PEM bundle of the CAs to trust, or
+// "system" for the system roots of a managed instance with a public
+// certificate; serverName overrides the name the certificate is checked
+// against. With tokenFile set, the token is read from that file and re-read
+// every reload instead of taken from token, so a rotated token takes over in
+// the middle of a long run.
+func authzedDialOptions(caCertPath, serverName, token, tokenFile string, reload time.Duration) ([]grpc.DialOption, error) {
+	var rootCAs *x509.CertPool
+	if caCertPath == "system" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("load system CA certs: %w", err)
+		}
+		rootCAs = pool
+	} else {
+		caPEM, err := os.ReadFile(caCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("read CA cert %q: %w", caCertPath, err)
+		}
+		rootCAs = x509.NewCertPool()
+		if ok := rootCAs.AppendCertsFromPEM(caPEM); !ok {
+			return nil, fmt.Errorf("failed to append CA certs")
+		}
+	}
+	tlsConfig := &tls.Config{RootCAs: rootCAs, ServerName: serverName}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+
+	if tokenFile == "" {
+		return append(opts, grpcutil.WithBearerToken(token)), nil
+	}
+	t := &fileToken{path: tokenFile, reload: reload}
+	if _, err := t.current(); err != nil {
+		return nil, err
+	}
+	return append(opts, grpc.WithPerRPCCredentials(t)), nil
+}
+
+// fileToken is a bearer token read from a file. It is re-read on the first
+// request after reload has passed; a failed re-read keeps the last token, so
+// a rotation that replaces the file non-atomically does not fail requests.
+type fileToken struct {
+	path   string
+	reload time.Duration
+
+	mu     sync.Mutex
+	token  string
+	loaded time.Time
+}
+
+func (t *fileToken) current() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Since(t.loaded) < t.reload {
+		return t.token, nil
+	}
+	b, err := os.ReadFile(t.path)
+	token := strings.TrimSpace(string(b))
+	if err == nil && token == "" {
+		err = fmt.Errorf("empty")
+	}
+	if err != nil {
+		if t.token == "" {
+			return "", fmt.Errorf("read SpiceDB token file %q: %w", t.path, err)
+		}
+		log.Printf("[authzed] re-read token file %q failed, keeping the current token: %v", t.path, err)
+		t.loaded = time.Now()
+		return t.token, nil
+	}
+	if t.token != "" && token != t.token {
+		log.Printf("[authzed] token file %q changed, using the new token", t.path)
+	}
+	t.token, t.loaded = token, time.Now()
+	return t.token, nil
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials.
+func (t *fileToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	token, err := t.current()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials.
+func (t *fileToken) RequireTransportSecurity() bool { return true }
@@ -2,28 +2,26 @@ package infrastructure
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"fmt"
-	"os"
 	"test-tls/utils"
 	"time"
 
 	authzed "github.com/authzed/authzed-go/v1"
-	"github.com/authzed/grpcutil"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
 )
 
 // AuthzedConfig holds connection/config options for the SpiceDB client.
 type AuthzedCrdbConfig struct {
-	Endpoint   string        // e.g. "localhost:50051"
-	Token      string        // preshared key / bearer token
-	CACertPath string        // path to CA/server cert (PEM)
-	Timeout    time.Duration // per-request timeout
+	Endpoint    string        // e.g. "localhost:50051"
+	Token       string        // preshared key / bearer token
+	TokenFile   string        // file holding the token, re-read every TokenReload; overrides Token
+	TokenReload time.Duration // how often TokenFile is re-read
+	CACertPath  string        // path to CA/server cert (PEM), or "system" for the system roots
+	ServerName  string        // name the server cert is checked against, if not the endpoint host
+	Timeout     time.Duration // per-request timeout
 }
 
-// NewAuthzedCrdbClient creates a SpiceDB/Authzed client with TLS + bearer token auth.
+// NewAuthzedCrdbClient creates a SpiceDB/Authzed client with TLS + bearer token auth,
+// the token optionally reloaded from a file (see authzedDialOptions).
 // It returns (client, ctxWithTimeout, cancel, error).
 func NewAuthzedCrdbClient(ctx context.Context, cfg AuthzedCrdbConfig) (*authzed.Client, context.Context, context.CancelFunc, error) {
 	if cfg.Endpoint == "" {
@@ -35,29 +33,15 @@ func NewAuthzedCrdbClient(ctx context.Context, cfg AuthzedCrdbConfig) (*authzed.
 	if cfg.Timeout == 0 {
 		cfg.Timeout = 10 * time.Second
 	}
-
-	// Load CA cert
-	caPEM, err := os.ReadFile(cfg.CACertPath)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("read CA cert %q: %w", cfg.CACertPath, err)
-	}
-
-	rootCAs := x509.NewCertPool()
-	if ok := rootCAs.AppendCertsFromPEM(caPEM); !ok {
-		return nil, nil, nil, fmt.Errorf("failed to append CA certs")
+	if cfg.TokenReload == 0 {
+		cfg.TokenReload = time.Minute
 	}
 
-	tlsConfig := &tls.Config{
-		RootCAs: rootCAs,
-		// Set this if your cert CN/SAN is not "localhost":
-		// ServerName: "spicedb.local",
+	opts, err := authzedDialOptions(cfg.CACertPath, cfg.ServerName, cfg.Token, cfg.TokenFile, cfg.TokenReload)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-
-	client, err := authzed.NewClient(
-		cfg.Endpoint,
-		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
-		grpcutil.WithBearerToken(cfg.Token),
-	)
+	client, err := authzed.NewClient(cfg.Endpoint, opts...)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("create authzed client: %w", err)
 	}
@@ -68,11 +52,19 @@ func NewAuthzedCrdbClient(ctx context.Context, cfg AuthzedCrdbConfig) (*authzed.
 
 // NewAuthzedClientFromEnv builds config from env vars with sane defaults.
 func NewAuthzedCrdbClientFromEnv(ctx context.Context) (*authzed.Client, context.Context, context.CancelFunc, error) {
+	if ns := utils.Namespace(); ns != "" {
+		// Definitions would need a "<namespace>/" prefix in every schema
+		// variant and object type; one datastore per SpiceDB instance instead.
+		return nil, nil, nil, fmt.Errorf("RLP_NAMESPACE=%q is not supported by SpiceDB; point SPICEDB_ENDPOINT at another instance", ns)
+	}
 	cfg := AuthzedCrdbConfig{
-		Endpoint:   utils.Getenv("SPICEDB_ENDPOINT", "localhost:50051"),
-		Token:      utils.Getenv("SPICEDB_TOKEN", "spicdbgrpcpwd123"),
-		CACertPath: utils.Getenv("SPICEDB_CA_CERT", "docker/spicedb/cert.pem"),
-		Timeout:    10 * time.Second,
+		Endpoint:    utils.Getenv("SPICEDB_ENDPOINT", "localhost:50051"),
+		Token:       utils.Getenv("SPICEDB_TOKEN", "spicdbgrpcpwd123"),
+		TokenFile:   utils.Getenv("SPICEDB_TOKEN_FILE", ""),
+		TokenReload: time.Duration(utils.GetEnvInt("SPICEDB_TOKEN_RELOAD_SEC", 60)) * time.Second,
+		CACertPath:  utils.Getenv("SPICEDB_CA_CERT", "docker/spicedb/cert.pem"),
+		ServerName:  utils.Getenv("SPICEDB_TLS_SERVER_NAME", ""),
+		Timeout:     10 * time.Second,
 	}
 	return NewAuthzedCrdbClient(ctx, cfg)
 }
@@ -0,0 +1,224 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	authzed "github.com/authzed/authzed-go/v1"
+
+	"test-tls/ids"
+	"test-tls/utils"
+)
+
+// exportPage is the number of relationships per ReadRelationships request of
+// ExportRelationships; each page resumes after the cursor of the last, so no
+// single stream has to outlive a long export.
+const exportPage = 10000
+
+// exportHeaders are the dataset CSVs ExportRelationships writes, with the
+// header `csv generate` gives them.
+var exportHeaders = map[string][]string{
+	"organizations.csv":     {"org_id"},
+	"users.csv":             {"user_id", "primary_org_id"},
+	"groups.csv":            {"group_id", "org_id"},
+	"org_memberships.csv":   {"org_id", "user_id", "role"},
+	"group_memberships.csv": {"group_id", "user_id", "role"},
+	"group_hierarchy.csv":   {"parent_group_id", "child_group_id", "relation"},
+	"resources.csv":         {"resource_id", "org_id", "created_at", "updated_at"},
+	"resource_acl.csv":      {"resource_id", "subject_type", "subject_id", "relation", "valid_from", "valid_until", "created_at"},
+	"resource_bans.csv":     {"resource_id", "user_id"},
+}
+
+// ExportRelationships streams every relationship of the benchmark schema from
+// SpiceDB at consistency and writes them to dir as the CSVs of `csv generate`,
+// the inverse of the authzed_* load-data mapping: organization admin_user and
+// member_user become org_memberships, organization member_group groups,
+// usergroup direct_*_user group_memberships, usergroup member_group and
+// manager_group group_hierarchy, resource org resources, resource banned_user
+// resource_bans, and the other resource relations resource_acl, with the
+// valid_window caveat context as valid_from/valid_until. It returns the rows
+// written per file.
+//
+// SpiceDB keeps no timestamps and no primary org: created_at and updated_at
+// are left empty, organizations.csv lists every org a relationship mentions,
+// and users.csv lists every user a relationship mentions with the lowest org
+// it is a member or admin of (empty for none) as its primary org.
+// Relationships outside that mapping are counted and logged, not written.
+// Rows come in SpiceDB's order, so sort both sides before diffing them
+// against the source dataset.
+func ExportRelationships(ctx context.Context, client *authzed.Client, consistency *v1.Consistency, dir string) (map[string]int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	writers, rows := map[string]*csv.Writer{}, map[string]int{}
+	for name, header := range exportHeaders {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		w := csv.NewWriter(f)
+		defer w.Flush()
+		if err := w.Write(header); err != nil {
+			return nil, err
+		}
+		writers[name] = w
+		rows[name] = 0
+	}
+	write := func(name string, rec ...string) error {
+		rows[name]++
+		return writers[name].Write(rec)
+	}
+
+	orgs, users := map[string]bool{}, map[string]bool{}
+	primaryOrg := map[string]string{} // user -> lowest org of its memberships
+	skipped := map[string]int{}       // type#relation@subject -> relationships
+	for _, resourceType := range []string{"organization", "usergroup", "resource"} {
+		err := readAllRelationships(ctx, client, consistency, resourceType, func(r *v1.Relationship) error {
+			id, relation := r.GetResource().GetObjectId(), r.GetRelation()
+			subject := r.GetSubject().GetObject()
+			subjectType, subjectID := subject.GetObjectType(), subject.GetObjectId()
+			if subjectType == "user" {
+				users[subjectID] = true
+			}
+			switch {
+			case resourceType == "organization" && subjectType == "user" && (relation == "admin_user" || relation == "member_user"):
+				orgs[id] = true
+				if cur, ok := primaryOrg[subjectID]; !ok || exportIDLess(ids.Org, id, cur) {
+					primaryOrg[subjectID] = id
+				}
+				return write("org_memberships.csv", id, subjectID, strings.TrimSuffix(relation, "_user"))
+			case resourceType == "organization" && subjectType == "usergroup" && relation == "member_group":
+				orgs[id] = true
+				return write("groups.csv", subjectID, id)
+			case resourceType == "usergroup" && subjectType == "user" && (relation == "direct_member_user" || relation == "direct_manager_user"):
+				return write("group_memberships.csv", id, subjectID, strings.TrimSuffix(relation, "_user"))
+			case resourceType == "usergroup" && subjectType == "usergroup" && (relation == "member_group" || relation == "manager_group"):
+				return write("group_hierarchy.csv", id, subjectID, relation)
+			case resourceType == "resource" && subjectType == "organization" && relation == "org":
+				orgs[subjectID] = true
+				return write("resources.csv", id, subjectID, "", "")
+			case resourceType == "resource" && subjectType == "user" && (relation == "manager_user" || relation == "viewer_user"):
+				from, until := exportGrantWindow(r.GetOptionalCaveat())
+				return write("resource_acl.csv", id, "user", subjectID, relation, from, until, "")
+			case resourceType == "resource" && subjectType == "usergroup" && (relation == "manager_group" || relation == "viewer_group"):
+				return write("resource_acl.csv", id, "group", subjectID, relation, "", "", "")
+			case resourceType == "resource" && subjectType == "user" && relation == "banned_user":
+				return write("resource_bans.csv", id, subjectID)
+			}
+			skipped[fmt.Sprintf("%s#%s@%s", resourceType, relation, subjectType)]++
+			return nil
+		})
+		if err != nil {
+			return rows, fmt.Errorf("read %s relationships: %w", resourceType, err)
+		}
+	}
+
+	for _, org := range exportSorted(ids.Org, orgs) {
+		if err := write("organizations.csv", org); err != nil {
+			return rows, err
+		}
+	}
+	for _, u := range exportSorted(ids.User, users) {
+		if err := write("users.csv", u, primaryOrg[u]); err != nil {
+			return rows, err
+		}
+	}
+	for rel, n := range skipped {
+		log.Printf("[export] skipped %d relationships %s: not part of the dataset CSVs", n, rel)
+	}
+	for name, w := range writers {
+		if w.Flush(); w.Error() != nil {
+			return rows, fmt.Errorf("write %s: %w", name, w.Error())
+		}
+	}
+	return rows, nil
+}
+
+// readAllRelationships calls handle for every relationship of resourceType,
+// exportPage at a time.
+func readAllRelationships(ctx context.Context, client *authzed.Client, consistency *v1.Consistency, resourceType string, handle func(*v1.Relationship) error) error {
+	var cursor *v1.Cursor
+	for {
+		pctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+		stream, err := client.ReadRelationships(pctx, &v1.ReadRelationshipsRequest{
+			Consistency:        consistency,
+			RelationshipFilter: &v1.RelationshipFilter{ResourceType: resourceType},
+			OptionalLimit:      exportPage,
+			OptionalCursor:     cursor,
+		})
+		if err != nil {
+			cancel()
+			return err
+		}
+		got := 0
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				cancel()
+				return err
+			}
+			cursor = resp.GetAfterResultCursor()
+			got++
+			if err := handle(resp.GetRelationship()); err != nil {
+				cancel()
+				return err
+			}
+		}
+		cancel()
+		if got < exportPage {
+			return nil
+		}
+	}
+}
+
+// exportGrantWindow returns valid_from and valid_until of a valid_window
+// caveat as resource_acl.csv writes them, with the open bounds load-data
+// fills in (utils.GrantWindowMin/Max) back to empty.
+func exportGrantWindow(c *v1.ContextualizedCaveat) (string, string) {
+	if c.GetCaveatName() != "valid_window" {
+		return "", ""
+	}
+	fields := c.GetContext().GetFields()
+	bound := func(key string, open time.Time) string {
+		s := fields[key].GetStringValue()
+		if t, err := time.Parse(time.RFC3339, s); err == nil && t.Equal(open) {
+			return ""
+		}
+		return s
+	}
+	return bound("valid_from", utils.GrantWindowMin), bound("valid_until", utils.GrantWindowMax)
+}
+
+// exportIDLess orders ids of kind numerically where they parse, as written
+// otherwise.
+func exportIDLess(kind ids.Kind, a, b string) bool {
+	na, errA := ids.Parse(kind, a)
+	nb, errB := ids.Parse(kind, b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return na < nb
+}
+
+// exportSorted returns the keys of set ordered by exportIDLess.
+func exportSorted(kind ids.Kind, set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for id := range set {
+		out = append(out, id)
+	}
+	sort.Slice(out, func(i, j int) bool { return exportIDLess(kind, out[i], out[j]) })
+	return out
+}
@@ -0,0 +1,55 @@
+package infrastructure
+
+import (
+	"context"
+	"io"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	authzed "github.com/authzed/authzed-go/v1"
+
+	"test-tls/utils"
+)
+
+// ReadRelationshipsPaged streams the relationships matching f from SpiceDB at
+// consistency and returns how many arrived. With page > 0 each request is
+// limited to page tuples and the next one resumes after the cursor of the
+// last; it is shared by the authzed_* modules' read_relationships_* scenarios.
+func ReadRelationshipsPaged(ctx context.Context, client *authzed.Client, consistency *v1.Consistency, f utils.RelsFilter, page int) (int, error) {
+	filter := &v1.RelationshipFilter{
+		ResourceType:     f.ResourceType,
+		OptionalRelation: f.Relation,
+	}
+	if f.SubjectType != "" {
+		filter.OptionalSubjectFilter = &v1.SubjectFilter{SubjectType: f.SubjectType, OptionalSubjectId: f.SubjectID}
+	}
+
+	var cursor *v1.Cursor
+	count := 0
+	for {
+		stream, err := client.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
+			Consistency:        consistency,
+			RelationshipFilter: filter,
+			OptionalLimit:      uint32(page),
+			OptionalCursor:     cursor,
+		})
+		if err != nil {
+			return count, err
+		}
+		got := 0
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return count, err
+			}
+			cursor = resp.GetAfterResultCursor()
+			got++
+		}
+		count += got
+		if page <= 0 || got < page {
+			return count, nil
+		}
+	}
+}
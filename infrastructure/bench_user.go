@@ -0,0 +1,45 @@
+package infrastructure
+
+import (
+	"log"
+
+	"test-tls/utils"
+)
+
+// useBenchCredentials switches the *FromEnv constructors to the read-only
+// benchmark user; see UseBenchCredentials.
+var useBenchCredentials bool
+
+// UseBenchCredentials makes every subsequent *FromEnv constructor connect as
+// the read-only benchmark user provisioned by create-schema, for backends that
+// have one configured. Backends without a bench user keep the regular user.
+//
+// Env vars (PREFIX is PG, CRDB, CH, SCYLLA, MONGO or ELASTICSEARCH):
+//
+//	<PREFIX>_BENCH_USER      (default: "" -> no read-only user)
+//	<PREFIX>_BENCH_PASSWORD  (default: "")
+func UseBenchCredentials() {
+	useBenchCredentials = true
+}
+
+// BenchCredentialsFromEnv returns the read-only benchmark user configured for
+// the backend with the given env prefix. ok is false when none is configured.
+func BenchCredentialsFromEnv(prefix string) (user, password string, ok bool) {
+	user = utils.GetEnvWithDefault(prefix+"_BENCH_USER", "")
+	if user == "" {
+		return "", "", false
+	}
+	return user, utils.GetEnvWithDefault(prefix+"_BENCH_PASSWORD", ""), true
+}
+
+// benchCredentials is BenchCredentialsFromEnv gated on UseBenchCredentials.
+func benchCredentials(prefix string) (user, password string, ok bool) {
+	if !useBenchCredentials {
+		return "", "", false
+	}
+	user, password, ok = BenchCredentialsFromEnv(prefix)
+	if ok {
+		log.Printf("[%s] connecting as read-only bench user %q", prefix, user)
+	}
+	return user, password, ok
+}
@@ -11,6 +11,7 @@ import (
 	"test-tls/utils"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/lib/pq"
 )
 
@@ -22,6 +23,7 @@ type CockroachConfig struct {
 	User            string
 	Password        string
 	Database        string
+	Schema          string // first schema of search_path, before public; "" leaves the server default
 	SSLMode         string
 	MaxOpenConns    int
 	MaxIdleConns    int
@@ -39,11 +41,13 @@ type CockroachConfig struct {
 //	CRDB_USER                  (default: "root")
 //	CRDB_PASSWORD              (default: "")
 //	CRDB_DATABASE              (default: "rlp")
+//	RLP_NAMESPACE              (default: "" -> public) schema to work in, via search_path (then public)
 //	CRDB_SSLMODE               (default: "disable")      // for --insecure, keep "disable"
 //	CRDB_MAX_OPEN_CONNS        (default: 0 -> driver default)
 //	CRDB_MAX_IDLE_CONNS        (default: 0 -> driver default)
 //	CRDB_CONN_MAX_LIFETIME_SEC (default: 0 -> no limit)
 //	CRDB_CONNECT_TIMEOUT_SEC   (default: 5)
+//	CRDB_BENCH_USER / CRDB_BENCH_PASSWORD (used instead of the above after UseBenchCredentials)
 //
 // Usage:
 //
@@ -98,6 +102,27 @@ func NewCockroachDBFromEnv(parentCtx context.Context) (*sql.DB, func(), error) {
 	return db, cleanup, nil
 }
 
+// NewCockroachDBPgxPoolFromEnv creates a native pgx pool from the same env
+// vars as NewCockroachDBFromEnv, for comparing database/sql + lib/pq against
+// pgx (see utils.CompareDrivers).
+func NewCockroachDBPgxPoolFromEnv(parentCtx context.Context) (*pgxpool.Pool, func(), error) {
+	cfg, err := loadCockroachConfigFromEnv()
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	dsn, err := buildCockroachDSN(cfg)
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	pool, err := newPgxPool(parentCtx, dsn, cfg.MaxOpenConns, cfg.ConnMaxLifetime, cfg.ConnectTimeout)
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("cockroach pgx: %w", err)
+	}
+	return pool, pool.Close, nil
+}
+
 func loadCockroachConfigFromEnv() (CockroachConfig, error) {
 	host := utils.GetEnvWithDefault("CRDB_HOST", "localhost")
 	port := utils.MustEnvIntWithDefault("CRDB_PORT", 26257)
@@ -106,6 +131,9 @@ func loadCockroachConfigFromEnv() (CockroachConfig, error) {
 	// user=root, password="", db=rlp, sslmode=disable (for --insecure)
 	user := utils.GetEnvWithDefault("CRDB_USER", "root")
 	password := utils.GetEnvWithDefault("CRDB_PASSWORD", "cockroachdbpwd123")
+	if benchUser, benchPassword, ok := benchCredentials("CRDB"); ok {
+		user, password = benchUser, benchPassword
+	}
 	dbname := utils.GetEnvWithDefault("CRDB_DATABASE", "rlp")
 	sslmode := utils.GetEnvWithDefault("CRDB_SSLMODE", "disable")
 
@@ -120,6 +148,7 @@ func loadCockroachConfigFromEnv() (CockroachConfig, error) {
 		User:            user,
 		Password:        password,
 		Database:        dbname,
+		Schema:          utils.Namespace(),
 		SSLMode:         sslmode,
 		MaxOpenConns:    maxOpen,
 		MaxIdleConns:    maxIdle,
@@ -140,6 +169,13 @@ func buildCockroachDSN(cfg CockroachConfig) (string, error) {
 	if cfg.SSLMode != "" {
 		q.Set("sslmode", cfg.SSLMode)
 	}
+	if cfg.Schema != "" {
+		// Sent as a startup parameter, so every pooled connection resolves
+		// the unqualified table names in the namespace's schema first.
+		// public stays on the path for the extensions installed there
+		// (pg_stat_statements of BENCH_STATEMENT_STATS and BENCH_COST).
+		q.Set("search_path", cfg.Schema+",public")
+	}
 	// You can optionally set connect_timeout here, but since we use PingContext with timeout,
 	// it's not strictly necessary. Uncomment if you want server-side enforcement as well:
 	// q.Set("connect_timeout", strconv.Itoa(int(cfg.ConnectTimeout.Seconds())))
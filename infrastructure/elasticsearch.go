@@ -40,6 +40,7 @@ type ElasticsearchConfig struct {
 //	ELASTICSEARCH_CLOUD_ID           (optional; for Elastic Cloud)
 //	ELASTICSEARCH_TIMEOUT_SEC        (per-request timeout hint; default: 5)
 //	ELASTICSEARCH_INSECURE_SKIP_TLS  (true/false; default: false)
+//	ELASTICSEARCH_BENCH_USER / ELASTICSEARCH_BENCH_PASSWORD (used instead of the above after UseBenchCredentials)
 func NewElasticsearchFromEnv(parentCtx context.Context) (*elasticsearch.Client, func(), error) {
 	cfg := loadElasticsearchConfigFromEnv()
 
@@ -125,6 +126,10 @@ func loadElasticsearchConfigFromEnv() ElasticsearchConfig {
 	password := utils.GetEnvWithDefault("ELASTICSEARCH_PASSWORD", "elasticsearchpwd123")
 
 	apiKey := utils.GetEnvWithDefault("ELASTICSEARCH_API_KEY", "")
+	if benchUser, benchPassword, ok := benchCredentials("ELASTICSEARCH"); ok {
+		// An API key would take precedence over basic auth, so drop it.
+		username, password, apiKey = benchUser, benchPassword, ""
+	}
 	cloudID := utils.GetEnvWithDefault("ELASTICSEARCH_CLOUD_ID", "")
 
 	timeoutSec := utils.MustEnvIntWithDefault("ELASTICSEARCH_TIMEOUT_SEC", 5)
@@ -32,9 +32,10 @@ type MongoConfig struct {
 //	MONGO_PORT                (default: "27017")
 //	MONGO_USER                (default: "root")
 //	MONGO_PASSWORD            (default: "mongodbpwd123")
-//	MONGO_DATABASE            (default: "rlp")
+//	MONGO_DATABASE            (default: "rlp"; "<RLP_NAMESPACE>_rlp" with a namespace)
 //	MONGO_AUTH_SOURCE         (default: "admin")
 //	MONGO_CONNECT_TIMEOUT_SEC (default: 5)
+//	MONGO_BENCH_USER / MONGO_BENCH_PASSWORD (used instead of the above after UseBenchCredentials)
 //
 // Usage:
 //
@@ -48,6 +49,13 @@ func NewMongoFromEnv(parentCtx context.Context) (*mongo.Client, *mongo.Database,
 	}
 
 	clientOpts := options.Client().ApplyURI(cfg.URI)
+	if user, password, ok := benchCredentials("MONGO"); ok {
+		clientOpts.SetAuth(options.Credential{
+			Username:   user,
+			Password:   password,
+			AuthSource: utils.GetEnvWithDefault("MONGO_AUTH_SOURCE", "admin"),
+		})
+	}
 
 	// Apply selection / connect timeouts if provided.
 	if cfg.ConnectTimeout > 0 {
@@ -86,7 +94,7 @@ func NewMongoFromEnv(parentCtx context.Context) (*mongo.Client, *mongo.Database,
 func loadMongoConfigFromEnv() (MongoConfig, error) {
 	// If MONGO_URI is set, we trust it completely.
 	if uri := utils.GetEnvWithDefault("MONGO_URI", ""); uri != "" {
-		dbName := utils.GetEnvWithDefault("MONGO_DATABASE", "rlp")
+		dbName := utils.Namespaced(utils.GetEnvWithDefault("MONGO_DATABASE", "rlp"))
 		connectTimeoutSec := utils.MustEnvIntWithDefault("MONGO_CONNECT_TIMEOUT_SEC", 5)
 
 		return MongoConfig{
@@ -101,7 +109,7 @@ func loadMongoConfigFromEnv() (MongoConfig, error) {
 	port := utils.MustEnvIntWithDefault("MONGO_PORT", 27017)
 	user := utils.GetEnvWithDefault("MONGO_USER", "root")
 	password := utils.GetEnvWithDefault("MONGO_PASSWORD", "mongodbpwd123")
-	dbName := utils.GetEnvWithDefault("MONGO_DATABASE", "rlp")
+	dbName := utils.Namespaced(utils.GetEnvWithDefault("MONGO_DATABASE", "rlp"))
 	authSource := utils.GetEnvWithDefault("MONGO_AUTH_SOURCE", "admin")
 	connectTimeoutSec := utils.MustEnvIntWithDefault("MONGO_CONNECT_TIMEOUT_SEC", 5)
 
@@ -11,6 +11,7 @@ import (
 	"test-tls/utils"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/lib/pq"
 )
 
@@ -21,6 +22,7 @@ type PostgresConfig struct {
 	User            string
 	Password        string
 	Database        string
+	Schema          string // first schema of search_path, before public; "" leaves the server default
 	SSLMode         string
 	MaxOpenConns    int
 	MaxIdleConns    int
@@ -38,11 +40,14 @@ type PostgresConfig struct {
 //	PG_USER                  (default: "postgres")
 //	PG_PASSWORD              (default: "postgrespwd123")
 //	PG_DATABASE              (default: "rlp")
+//	RLP_NAMESPACE            (default: "" -> public) schema to work in, via search_path (then public)
 //	PG_SSLMODE               (default: "disable")
 //	PG_MAX_OPEN_CONNS        (default: 0 -> driver default)
 //	PG_MAX_IDLE_CONNS        (default: 0 -> driver default)
 //	PG_CONN_MAX_LIFETIME_SEC (default: 0 -> no limit)
 //	PG_CONNECT_TIMEOUT_SEC   (default: 5)
+//	PG_BENCH_USER / PG_BENCH_PASSWORD (used instead of the above after UseBenchCredentials)
+//	PG_REPLICA_HOST / PG_REPLICA_PORT (read-only standby; see NewPostgresReplicaFromEnv)
 //
 // Usage:
 //
@@ -54,7 +59,28 @@ func NewPostgresFromEnv(parentCtx context.Context) (*sql.DB, func(), error) {
 	if err != nil {
 		return nil, func() {}, err
 	}
+	return openPostgres(parentCtx, cfg)
+}
 
+// NewPostgresReplicaFromEnv connects to the read-only standby at
+// PG_REPLICA_HOST:PG_REPLICA_PORT (port default: PG_PORT) with the same
+// database, credentials and pool settings as NewPostgresFromEnv, for
+// offloading the benchmark reads. PG_REPLICA_HOST is required.
+func NewPostgresReplicaFromEnv(parentCtx context.Context) (*sql.DB, func(), error) {
+	cfg, err := loadPostgresConfigFromEnv()
+	if err != nil {
+		return nil, func() {}, err
+	}
+	cfg.Host = utils.GetEnvWithDefault("PG_REPLICA_HOST", "")
+	if cfg.Host == "" {
+		return nil, func() {}, fmt.Errorf("PG_REPLICA_HOST is not set")
+	}
+	cfg.Port = utils.MustEnvIntWithDefault("PG_REPLICA_PORT", cfg.Port)
+	return openPostgres(parentCtx, cfg)
+}
+
+// openPostgres opens and pings a *sql.DB for cfg.
+func openPostgres(parentCtx context.Context, cfg PostgresConfig) (*sql.DB, func(), error) {
 	dsn, err := buildPostgresDSN(cfg)
 	if err != nil {
 		return nil, func() {}, err
@@ -94,6 +120,57 @@ func NewPostgresFromEnv(parentCtx context.Context) (*sql.DB, func(), error) {
 	return db, cleanup, nil
 }
 
+// NewPostgresPgxPoolFromEnv creates a native pgx pool from the same env vars
+// as NewPostgresFromEnv, for comparing database/sql + lib/pq against pgx (see
+// utils.CompareDrivers). PG_MAX_OPEN_CONNS maps to the pool's MaxConns; pgx
+// has no idle connection limit.
+func NewPostgresPgxPoolFromEnv(parentCtx context.Context) (*pgxpool.Pool, func(), error) {
+	cfg, err := loadPostgresConfigFromEnv()
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	dsn, err := buildPostgresDSN(cfg)
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	pool, err := newPgxPool(parentCtx, dsn, cfg.MaxOpenConns, cfg.ConnMaxLifetime, cfg.ConnectTimeout)
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("postgres pgx: %w", err)
+	}
+	return pool, pool.Close, nil
+}
+
+// newPgxPool opens and pings a pgx pool; shared by the Postgres and
+// CockroachDB constructors.
+func newPgxPool(parentCtx context.Context, dsn string, maxConns int, maxLifetime, connectTimeout time.Duration) (*pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if maxConns > 0 {
+		poolCfg.MaxConns = int32(maxConns)
+	}
+	if maxLifetime > 0 {
+		poolCfg.MaxConnLifetime = maxLifetime
+	}
+	poolCfg.ConnConfig.ConnectTimeout = connectTimeout
+
+	ctx, cancel := context.WithTimeout(parentCtx, connectTimeout)
+	defer cancel()
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("ping failed: %w", err)
+	}
+	return pool, nil
+}
+
 func loadPostgresConfigFromEnv() (PostgresConfig, error) {
 	host := utils.GetEnvWithDefault("PG_HOST", "localhost")
 	port := utils.MustEnvIntWithDefault("PG_PORT", 5432)
@@ -104,6 +181,9 @@ func loadPostgresConfigFromEnv() (PostgresConfig, error) {
 	// POSTGRES_DB=postgresdb
 	user := utils.GetEnvWithDefault("PG_USER", "root")
 	password := utils.GetEnvWithDefault("PG_PASSWORD", "postgrespwd123")
+	if benchUser, benchPassword, ok := benchCredentials("PG"); ok {
+		user, password = benchUser, benchPassword
+	}
 	dbname := utils.GetEnvWithDefault("PG_DATABASE", "rlp")
 	sslmode := utils.GetEnvWithDefault("PG_SSLMODE", "disable")
 
@@ -118,6 +198,7 @@ func loadPostgresConfigFromEnv() (PostgresConfig, error) {
 		User:            user,
 		Password:        password,
 		Database:        dbname,
+		Schema:          utils.Namespace(),
 		SSLMode:         sslmode,
 		MaxOpenConns:    maxOpen,
 		MaxIdleConns:    maxIdle,
@@ -138,6 +219,13 @@ func buildPostgresDSN(cfg PostgresConfig) (string, error) {
 	if cfg.SSLMode != "" {
 		q.Set("sslmode", cfg.SSLMode)
 	}
+	if cfg.Schema != "" {
+		// Sent as a startup parameter, so every pooled connection resolves
+		// the unqualified table names in the namespace's schema first.
+		// public stays on the path for the extensions installed there
+		// (pg_stat_statements of BENCH_STATEMENT_STATS and BENCH_COST).
+		q.Set("search_path", cfg.Schema+",public")
+	}
 	u.RawQuery = q.Encode()
 
 	return u.String(), nil
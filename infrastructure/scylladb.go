@@ -19,6 +19,7 @@ type ScyllaConfig struct {
 	Keyspace       string
 	Username       string
 	Password       string
+	ReadOnly       bool // connected as the bench user; skips keyspace creation
 	Consistency    gocql.Consistency
 	ConnectTimeout time.Duration
 	Timeout        time.Duration
@@ -33,12 +34,13 @@ type ScyllaConfig struct {
 //	SCYLLA_HOSTS                 (comma-separated, default: "localhost")
 //	SCYLLA_HOST                  (fallback if SCYLLA_HOSTS not set)
 //	SCYLLA_PORT                  (default: 9042)
-//	SCYLLA_KEYSPACE              (default: "rlp")
+//	SCYLLA_KEYSPACE              (default: "rlp"; "<RLP_NAMESPACE>_rlp" with a namespace)
 //	SCYLLA_USER                  (optional; default: "")
 //	SCYLLA_PASSWORD              (optional; default: "")
 //	SCYLLA_CONSISTENCY           (ONE|LOCAL_ONE|QUORUM|LOCAL_QUORUM|ALL; default: LOCAL_QUORUM)
 //	SCYLLA_TIMEOUT_SEC           (per-query timeout; default: 5)
 //	SCYLLA_CONNECT_TIMEOUT_SEC   (connect timeout; default: SCYLLA_TIMEOUT_SEC)
+//	SCYLLA_BENCH_USER / SCYLLA_BENCH_PASSWORD (used instead of the above after UseBenchCredentials)
 func NewScyllaFromEnv(parentCtx context.Context) (*gocql.Session, func(), error) {
 	cfg := loadScyllaConfigFromEnv()
 
@@ -49,30 +51,33 @@ func NewScyllaFromEnv(parentCtx context.Context) (*gocql.Session, func(), error)
 	log.Printf("[scylladb] Using hosts=%v port=%d keyspace=%q consistency=%v",
 		cfg.Hosts, cfg.Port, cfg.Keyspace, cfg.Consistency)
 
-	// Phase 1: connect without keyspace to create it if needed.
-	adminCluster := gocql.NewCluster(cfg.Hosts...)
-	adminCluster.Port = cfg.Port
-	adminCluster.Timeout = cfg.Timeout
-	adminCluster.ConnectTimeout = cfg.ConnectTimeout
-	adminCluster.Consistency = cfg.Consistency
-
-	if cfg.Username != "" {
-		adminCluster.Authenticator = gocql.PasswordAuthenticator{
-			Username: cfg.Username,
-			Password: cfg.Password,
+	// Phase 1: connect without keyspace to create it if needed. Skipped for the
+	// read-only bench user, which lacks CREATE and only runs after create-schema.
+	if !cfg.ReadOnly {
+		adminCluster := gocql.NewCluster(cfg.Hosts...)
+		adminCluster.Port = cfg.Port
+		adminCluster.Timeout = cfg.Timeout
+		adminCluster.ConnectTimeout = cfg.ConnectTimeout
+		adminCluster.Consistency = cfg.Consistency
+
+		if cfg.Username != "" {
+			adminCluster.Authenticator = gocql.PasswordAuthenticator{
+				Username: cfg.Username,
+				Password: cfg.Password,
+			}
 		}
-	}
 
-	adminSession, err := adminCluster.CreateSession()
-	if err != nil {
-		return nil, func() {}, fmt.Errorf("scylladb: create admin session: %w", err)
-	}
+		adminSession, err := adminCluster.CreateSession()
+		if err != nil {
+			return nil, func() {}, fmt.Errorf("scylladb: create admin session: %w", err)
+		}
 
-	if err := ensureKeyspace(parentCtx, adminSession, cfg); err != nil {
+		if err := ensureKeyspace(parentCtx, adminSession, cfg); err != nil {
+			adminSession.Close()
+			return nil, func() {}, err
+		}
 		adminSession.Close()
-		return nil, func() {}, err
 	}
-	adminSession.Close()
 
 	// Phase 2: connect to the target keyspace.
 	cluster := gocql.NewCluster(cfg.Hosts...)
@@ -154,9 +159,13 @@ func loadScyllaConfigFromEnv() ScyllaConfig {
 	}
 
 	port := utils.MustEnvIntWithDefault("SCYLLA_PORT", 9042)
-	keyspace := utils.GetEnvWithDefault("SCYLLA_KEYSPACE", "rlp")
+	keyspace := utils.Namespaced(utils.GetEnvWithDefault("SCYLLA_KEYSPACE", "rlp"))
 	user := utils.GetEnvWithDefault("SCYLLA_USER", "")
 	password := utils.GetEnvWithDefault("SCYLLA_PASSWORD", "")
+	benchUser, benchPassword, readOnly := benchCredentials("SCYLLA")
+	if readOnly {
+		user, password = benchUser, benchPassword
+	}
 
 	timeoutSec := utils.MustEnvIntWithDefault("SCYLLA_TIMEOUT_SEC", 5)
 	connectTimeoutSec := utils.MustEnvIntWithDefault("SCYLLA_CONNECT_TIMEOUT_SEC", timeoutSec)
@@ -170,6 +179,7 @@ func loadScyllaConfigFromEnv() ScyllaConfig {
 		Keyspace:       keyspace,
 		Username:       user,
 		Password:       password,
+		ReadOnly:       readOnly,
 		Consistency:    consistency,
 		ConnectTimeout: time.Duration(connectTimeoutSec) * time.Second,
 		Timeout:        time.Duration(timeoutSec) * time.Second,
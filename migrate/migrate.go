@@ -0,0 +1,129 @@
+// Package migrate applies a backend's schema as numbered migration files and
+// records the applied versions in the backend itself, so create-schema only
+// runs the files that are new since the last run.
+//
+// Migrations are named <version>_<name>.<ext> (e.g. 0001_init.sql), are
+// embedded in the backend's package under migrations/, and apply in version
+// order. Every backend implements Store for its own tracking table
+// (rlp_schema_migrations); `drop` removes that table along with the schema.
+package migrate
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration is one versioned schema file.
+type Migration struct {
+	Version int
+	Name    string // file name, e.g. 0001_init.sql
+	Body    string
+}
+
+// Statements splits Body into the statements it contains, for drivers that
+// run one statement per call. A statement ends with ';' at the end of a line;
+// lines starting with "--" are dropped.
+func (m Migration) Statements() []string {
+	var stmts []string
+	var cur strings.Builder
+	for _, line := range strings.Split(m.Body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		cur.WriteString(line)
+		cur.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			if stmt := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(cur.String()), ";")); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			cur.Reset()
+		}
+	}
+	if stmt := strings.TrimSpace(cur.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+// Store is a backend's record of applied migrations.
+type Store interface {
+	// Applied creates the tracking table if needed and returns the versions
+	// recorded in it.
+	Applied(ctx context.Context) (map[int]bool, error)
+	// Apply runs m and records its version.
+	Apply(ctx context.Context, m Migration) error
+}
+
+// Load reads the migrations of engine from the migrations/ directory of
+// embedded, or from the directory in the env var dirEnv when it is set, so a
+// schema can be tried out without rebuilding. Files that do not start with a
+// version number are ignored; two files with the same version are fatal.
+func Load(engine string, embedded fs.FS, dirEnv string) []Migration {
+	fsys, dir := embedded, "migrations"
+	if d := os.Getenv(dirEnv); d != "" {
+		fsys, dir = os.DirFS(d), "."
+		log.Printf("[%s] migrations from %s=%s", engine, dirEnv, d)
+	}
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		log.Fatalf("[%s] read migrations: %v", engine, err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		prefix, _, ok := strings.Cut(e.Name(), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			continue
+		}
+		if other, dup := seen[version]; dup {
+			log.Fatalf("[%s] migrations %s and %s share version %d", engine, other, e.Name(), version)
+		}
+		seen[version] = e.Name()
+		body, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			log.Fatalf("[%s] read migration %s: %v", engine, e.Name(), err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: e.Name(), Body: string(body)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations
+}
+
+// Run applies the migrations not yet recorded in store, in order, and returns
+// how many it applied. Any failure is fatal; the migrations applied before it
+// stay recorded, so a fixed file can simply be rerun.
+func Run(ctx context.Context, engine string, store Store, migrations []Migration) int {
+	applied, err := store.Applied(ctx)
+	if err != nil {
+		log.Fatalf("[%s] read applied migrations: %v", engine, err)
+	}
+	n := 0
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		start := time.Now()
+		if err := store.Apply(ctx, m); err != nil {
+			log.Fatalf("[%s] migration %s failed: %v", engine, m.Name, err)
+		}
+		log.Printf("[%s] migration %s applied in %s", engine, m.Name, time.Since(start).Truncate(time.Millisecond))
+		n++
+	}
+	if n == 0 {
+		log.Printf("[%s] schema up to date (%d migrations applied)", engine, len(applied))
+	}
+	return n
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"test-tls/authz"
+)
+
+// RunAccessSummary runs count_access_summary, the "your access" panel of a
+// dashboard: how many resources a user can view and how many it can manage,
+// counted in one request where the backend allows it (see the CountAccess of
+// each authz package) instead of listing them. The backend must be an
+// authz.AccessCounter; the scenario is skipped otherwise.
+//
+// The users are the lookup user mix of the view permission
+// (LookupUserMix), BENCH_ACCESS_SUMMARY_USERS of them from light through
+// heavy, cycled through by the iterations. Each iteration line has both
+// counts, and the DONE line the latency of the summaries.
+//
+// Env vars:
+//
+//	BENCH_ACCESS_SUMMARY_ITER   (default: 100; 0 skips the scenario)
+//	BENCH_ACCESS_SUMMARY_USERS  (default: 10)
+func RunAccessSummary(engine string, backend PermissionBackend) {
+	const scenario = "count_access_summary"
+	iters := GetEnvInt("BENCH_ACCESS_SUMMARY_ITER", 100)
+	if iters <= 0 {
+		return
+	}
+	counter, ok := backend.(authz.AccessCounter)
+	if !ok {
+		log.Printf("[%s] [%s] skipped: backend cannot count the access of a user", engine, scenario)
+		return
+	}
+	var users []string
+	for _, u := range LookupUserMix(authz.View, GetEnvInt("BENCH_ACCESS_SUMMARY_USERS", 10)) {
+		users = append(users, u.ID)
+	}
+	if len(users) == 0 {
+		log.Printf("[%s] [%s] skipped: no users with view grants in %s", engine, scenario, DataDir())
+		return
+	}
+	timeout := 60 * time.Second
+	log.Printf("[%s] [%s] streaming mode. iterations=%d users=%d", engine, scenario, iters, len(users))
+	LogScenarioConfig(engine, scenario, iters, timeout)
+
+	var durs []time.Duration
+	errs := NewErrorTally()
+	for i := range iters {
+		user := users[i%len(users)]
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		start := time.Now()
+		view, manage, err := counter.CountAccess(ctx, user)
+		dur := time.Since(start)
+		cancel()
+		AuditLookup(scenario, user, authz.View, view, dur, err)
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[%s] [%s] iter=%d user=%s failed class=%s: %v", engine, scenario, i, user, class, err)
+			continue
+		}
+		durs = append(durs, dur)
+		log.Printf("[%s] [%s] iter=%d user=%s view=%d manage=%d dur=%s", engine, scenario, i, user, view, manage, dur)
+	}
+
+	log.Printf("[%s] [%s] DONE: iters=%d %s", engine, scenario, len(durs), LatencySummary(durs))
+	log.Printf("[%s] [%s] ERRORS: %s", engine, scenario, errs.Summary(iters))
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"test-tls/ids"
+)
+
+// Audit record kinds. Files written before lookups were recorded have no kind;
+// those records are checks.
+const (
+	AuditCheckKind  = "check"
+	AuditLookupKind = "lookup"
+)
+
+// AuditRecord is one check or lookup performed during a benchmark, as written
+// to the audit file. Ids are in the current RLP_ID_FORMAT and permission is the
+// serve API one (manage/view) whatever relation the backend queried, so a file
+// recorded against one backend replays as-is against any other.
+type AuditRecord struct {
+	TS         time.Time `json:"ts"`
+	Backend    string    `json:"backend"`
+	Kind       string    `json:"kind,omitempty"`
+	Scenario   string    `json:"scenario"`
+	ResourceID string    `json:"resource_id,omitempty"`
+	UserID     string    `json:"user_id"`
+	Permission string    `json:"permission"`
+	Allowed    bool      `json:"allowed"`
+	Count      int       `json:"count,omitempty"`
+	LatencyUS  int64     `json:"latency_us"`
+	Error      string    `json:"error,omitempty"`
+	ErrorClass string    `json:"error_class,omitempty"`
+}
+
+// IsLookup reports whether the record is a lookup rather than a check.
+func (r AuditRecord) IsLookup() bool { return r.Kind == AuditLookupKind }
+
+// auditSink is the gzip NDJSON writer behind AuditCheck and AuditLookup.
+type auditSink struct {
+	mu      sync.Mutex
+	backend string
+	path    string
+	f       *os.File
+	zw      *gzip.Writer
+	enc     *json.Encoder
+	records int
+}
+
+// audit is the sink of the running benchmark, nil when auditing is off.
+var audit *auditSink
+
+// StartAudit opens the audit sink for a benchmark run when BENCH_AUDIT_DIR is
+// set, writing <dir>/<backend>-<UTC timestamp>.ndjson.gz. The returned func
+// flushes and closes the file; it is a no-op when auditing is off.
+func StartAudit(backend string) func() {
+	dir := os.Getenv("BENCH_AUDIT_DIR")
+	if dir == "" {
+		return func() {}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Fatalf("[%s] failed to create audit dir %q: %v", backend, dir, err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.ndjson.gz", backend, time.Now().UTC().Format("20060102T150405Z")))
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("[%s] failed to create audit file %q: %v", backend, path, err)
+	}
+	zw := gzip.NewWriter(f)
+	audit = &auditSink{backend: backend, path: path, f: f, zw: zw, enc: json.NewEncoder(zw)}
+	log.Printf("[%s] auditing checks and lookups to %s", backend, path)
+
+	return func() {
+		s := audit
+		audit = nil
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := s.zw.Close(); err != nil {
+			log.Printf("[%s] audit flush failed: %v", s.backend, err)
+		}
+		if err := s.f.Close(); err != nil {
+			log.Printf("[%s] audit close failed: %v", s.backend, err)
+		}
+		log.Printf("[%s] audit DONE: records=%d file=%s", s.backend, s.records, s.path)
+	}
+}
+
+// AuditCheck records one check of scenario when auditing, NDJSON output (see
+// StartNDJSONOutput), histograms (see StartHDR) or the slowest calls (see
+// StartSlowest) are on, and queues it for the oracle when verification is on
+// (see StartOracle). resourceID and userID may be ints or ids in any format;
+// relation may be a backend relation (manager_user, viewer, ...) or a
+// permission. err is the check's error, if any.
+func AuditCheck(scenario string, resourceID, userID any, relation string, allowed bool, latency time.Duration, err error) {
+	if audit == nil && ndjson == nil && hdr == nil && oracle == nil && slowest == nil {
+		return
+	}
+	rec := AuditRecord{
+		Kind:       AuditCheckKind,
+		Scenario:   scenario,
+		ResourceID: auditID(ids.Resource, resourceID),
+		UserID:     auditID(ids.User, userID),
+		Permission: auditPermission(relation),
+		Allowed:    allowed && err == nil,
+	}
+	if s := oracle; s != nil && err == nil {
+		s.verify(rec)
+	}
+	recordCall(rec, latency, err)
+}
+
+// AuditLookup records one lookup of scenario that matched count resources
+// when auditing, NDJSON output, histograms or the slowest calls are on.
+// Arguments follow AuditCheck.
+func AuditLookup(scenario string, userID any, relation string, count int, latency time.Duration, err error) {
+	if audit == nil && ndjson == nil && hdr == nil && slowest == nil {
+		return
+	}
+	recordCall(AuditRecord{
+		Kind:       AuditLookupKind,
+		Scenario:   scenario,
+		UserID:     auditID(ids.User, userID),
+		Permission: auditPermission(relation),
+		Count:      count,
+	}, latency, err)
+}
+
+// recordCall completes rec and hands it to the audit, NDJSON, histogram and
+// slowest call sinks that are on; histograms only take the calls that
+// succeeded.
+func recordCall(rec AuditRecord, latency time.Duration, err error) {
+	rec.TS = time.Now().UTC()
+	rec.LatencyUS = latency.Microseconds()
+	if err != nil {
+		rec.Error = err.Error()
+		rec.ErrorClass = ClassifyError(err)
+	}
+	if s := audit; s != nil {
+		rec.Backend = s.backend
+		s.write(rec)
+	}
+	if s := ndjson; s != nil {
+		rec.Backend = s.backend
+		s.iteration(rec)
+	}
+	if s := hdr; s != nil && err == nil {
+		s.record(rec.Scenario, latency)
+	}
+	if s := slowest; s != nil {
+		s.record(rec)
+	}
+}
+
+func (s *auditSink) write(rec AuditRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(rec); err != nil {
+		log.Fatalf("[%s] audit write failed: %v", s.backend, err)
+	}
+	s.records++
+}
+
+// auditID renders an int or external id in the current id format, keeping
+// values that do not parse as they are.
+func auditID(kind ids.Kind, v any) string {
+	s := fmt.Sprint(v)
+	if n, err := ids.Parse(kind, s); err == nil {
+		return ids.Format(kind, n)
+	}
+	return s
+}
+
+// auditPermission maps backend relations onto the serve API permissions.
+func auditPermission(relation string) string {
+	switch {
+	case strings.HasPrefix(relation, "manage"):
+		return "manage"
+	case strings.HasPrefix(relation, "view"):
+		return "view"
+	}
+	return relation
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// CacheFlush empties a backend's server-side caches ahead of the cold pass of
+// RunCachePhases.
+type CacheFlush func(ctx context.Context) error
+
+// RunCachePhases runs a backend's read scenarios. By default run is called
+// once. With BENCH_CACHE_COMPARE=1 it is called twice: a cold pass right after
+// connecting, then a warm pass after BENCH_CACHE_WARM_SEC seconds, so every
+// scenario is measured on cold and on warm caches. Each pass starts with a
+// "CACHE: phase=cold|warm" line; parse_all reports the two passes side by side.
+//
+// With BENCH_CACHE_FLUSH=1 flush is called before the cold pass. Backends that
+// cannot drop their caches over the wire pass nil and get a hint to restart
+// the server instead. A failed flush is logged and the cold pass still runs.
+//
+// Env vars:
+//
+//	BENCH_CACHE_COMPARE   (default: 0)
+//	BENCH_CACHE_WARM_SEC  (default: 30)
+//	BENCH_CACHE_FLUSH     (default: 0)
+func RunCachePhases(engine string, flush CacheFlush, run func()) {
+	if GetEnvInt("BENCH_CACHE_COMPARE", 0) == 0 {
+		run()
+		return
+	}
+
+	flushed := "off"
+	if GetEnvInt("BENCH_CACHE_FLUSH", 0) != 0 {
+		flushed = flushCaches(engine, flush)
+	}
+	log.Printf("[%s] CACHE: phase=cold flush=%s", engine, flushed)
+	run()
+
+	warm := time.Duration(GetEnvInt("BENCH_CACHE_WARM_SEC", 30)) * time.Second
+	log.Printf("[%s] CACHE: waiting %s before the warm pass", engine, warm)
+	time.Sleep(warm)
+	log.Printf("[%s] CACHE: phase=warm", engine)
+	run()
+}
+
+// flushCaches calls flush and returns how it went, for the cold phase line:
+// ok, failed or unsupported.
+func flushCaches(engine string, flush CacheFlush) string {
+	if flush == nil {
+		log.Printf("[%s] CACHE: no server-side cache flush; restart the server (and drop the OS page cache) before the run for a truly cold pass", engine)
+		return "unsupported"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := flush(ctx); err != nil {
+		log.Printf("[%s] CACHE: cache flush failed, the cold pass runs on whatever is cached: %v", engine, err)
+		return "failed"
+	}
+	log.Printf("[%s] CACHE: server-side caches flushed in %s", engine, time.Since(start).Truncate(time.Millisecond))
+	return "ok"
+}
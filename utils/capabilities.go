@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Capabilities is what one backend module supports, as declared next to its
+// benchmark code and printed by `capabilities`.
+type Capabilities struct {
+	// Scenarios are the scenarios `benchmark` runs, including optional steps
+	// with the env var that enables them.
+	Scenarios []string
+	// Schemas are the selectable schema variants or table layouts.
+	Schemas []string
+	// Consistency are the read consistency modes and the env vars that pick them.
+	Consistency []string
+	// Writes are the write benchmarks.
+	Writes []string
+}
+
+// PrintCapabilities prints a scenario x backend matrix followed by each
+// backend's schema, consistency and write options, for the backends in order.
+func PrintCapabilities(order []string, caps map[string]Capabilities) {
+	var scenarios []string
+	for _, b := range order {
+		for _, s := range caps[b].Scenarios {
+			if !slices.Contains(scenarios, s) {
+				scenarios = append(scenarios, s)
+			}
+		}
+	}
+
+	fmt.Println("## Scenarios")
+	fmt.Printf("| Scenario | %s |\n", strings.Join(order, " | "))
+	fmt.Printf("|----------|%s\n", strings.Repeat("---|", len(order)))
+	for _, s := range scenarios {
+		row := make([]string, len(order))
+		for i, b := range order {
+			row[i] = "-"
+			if slices.Contains(caps[b].Scenarios, s) {
+				row[i] = "yes"
+			}
+		}
+		fmt.Printf("| %s | %s |\n", s, strings.Join(row, " | "))
+	}
+
+	fmt.Println("\n## Options")
+	fmt.Println("| Backend | Schema variants | Consistency | Write benchmarks |")
+	fmt.Println("|---------|-----------------|-------------|------------------|")
+	for _, b := range order {
+		c := caps[b]
+		fmt.Printf("| %s | %s | %s | %s |\n", b, capabilityList(c.Schemas), capabilityList(c.Consistency), capabilityList(c.Writes))
+	}
+}
+
+func capabilityList(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	return strings.Join(items, "<br>")
+}
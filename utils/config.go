@@ -0,0 +1,320 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ConfigVar is one BENCH_* or RLP_* env var the project reads.
+type ConfigVar struct {
+	Name    string
+	Type    string // int, float, bool, duration list, string, ...
+	Default string // "" when unset means off or none
+	Modules string // the modules or scripts that read it
+	Help    string
+}
+
+// Module groups of ConfigVar.Modules.
+const (
+	allBackends = "all backends"
+	sqlBackends = "postgres, cockroachdb"
+	spicedb     = "authzed_*"
+	churn       = "postgres, cockroachdb, clickhouse, scylladb"
+)
+
+// ConfigVars is the registry of the BENCH_* and RLP_* env vars, listed by
+// `config list` and checked by ValidateEnv. Add a variable here when adding
+// code that reads one, or every run with it set fails validation.
+var ConfigVars = []ConfigVar{
+	// dataset (csv generate)
+	{"RLP_NUM_ORGS", "int", "16", "csv", "organizations to generate"},
+	{"RLP_USERS_PER_ORG", "int", "200", "csv", "users per organization"},
+	{"RLP_GROUPS_PER_ORG", "int", "20", "csv", "groups per organization"},
+	{"RLP_RESOURCES_PER_ORG", "int", "2000", "csv", "resources per organization"},
+	{"RLP_GROUPS_PER_USER", "int", "3", "csv", "group memberships per user"},
+	{"RLP_ADMINS_PER_ORG", "int", "10", "csv", "org admins per organization"},
+	{"RLP_MANAGER_USERS_PER_RESOURCE", "int", "2", "csv", "manager_user grants per resource"},
+	{"RLP_MANAGER_GROUPS_PER_RESOURCE", "int", "1", "csv", "manager_group grants per resource"},
+	{"RLP_VIEWER_USERS_PER_RESOURCE", "int", "10", "csv", "viewer_user grants per resource"},
+	{"RLP_VIEWER_GROUPS_PER_RESOURCE", "int", "3", "csv", "viewer_group grants per resource"},
+	{"RLP_AVG_ORGS_PER_USER", "int", "2", "csv", "average organizations per user"},
+	{"RLP_RANDOM_SEED", "int", "", "csv", "fixed random seed; time-based when unset"},
+	{"RLP_EXPIRING_GRANT_FRACTION", "float", "0", "csv", "fraction of direct user grants with a validity window"},
+	{"RLP_EXPIRED_GRANT_SHARE", "float", "0.5", "csv", "share of those windows already expired"},
+	{"RLP_VIRAL_RESOURCES", "int", "0", "csv", "resources shared directly with many users"},
+	{"RLP_VIRAL_USER_FRACTION", "float", "0.5", "csv", "share of all users given viewer_user on each viral resource"},
+	{"RLP_BANNED_RESOURCE_FRACTION", "float", "0", "csv", "fraction of resources with banned_user denials (resource_bans.csv)"},
+	{"RLP_BANNED_USERS_PER_RESOURCE", "int", "1", "csv", "direct viewers banned on each of them"},
+	{"RLP_HISTORY_DAYS", "int", "365", "csv", "days before generation that created_at/updated_at span"},
+	{"RLP_PLAN_DISK_GB", "int", "0", "plan-load", "free disk of the target; 0 skips the check"},
+	{"RLP_PLAN_MEMORY_GB", "int", "0", "plan-load", "memory of the target; 0 skips the check"},
+	{"RLP_PLAN_MAX_LOAD_MIN", "int", "120", "plan-load", "longest acceptable load; 0 skips the check"},
+	{"RLP_PLAN_ROWS_PER_SEC", "int", "0", "plan-load", "measured load throughput instead of the calibration"},
+	{"RLP_WRITE_BENCH_USERS", "list", "", "csv", "where to save the picked bench users: manifest, env or both"},
+	{"RLP_ID_FORMAT", "string", "int", "csv, " + allBackends, "id format of the dataset: int, prefixed or uuid"},
+	{"RLP_DATA_DIR", "path", "data", "csv, data, " + allBackends, "directory of the dataset CSVs (--data-dir)"},
+	{"RLP_NAMESPACE", "string", "", "postgres, cockroachdb, clickhouse, scylladb, mongodb, elasticsearch", "schema (SQL) or name prefix the backend's tables live under"},
+	{"RLP_DATA_ARCHIVE", "bool", "true", "csv", "move the dataset in data/ to data/datasets/<id> before generating"},
+	{"RLP_IMPORT_MAPPING", "path", "", "csv", "column mapping of csv import-production (JSON)"},
+	{"RLP_IMPORT_DIR", "path", "", "csv", "directory of the export files; the mapping file's when unset"},
+	{"RLP_DATA_KEEP_LATEST", "int", "2", "data", "archived datasets data clean keeps (--keep-latest)"},
+	{"RLP_ORGS", "org ranges", "", "csv, " + allBackends, "restrict load-data, benchmark and targets to these orgs (--orgs)"},
+	{"BENCH_DATASET_FORCE", "bool", "false", "postgres, cockroachdb, clickhouse, scylladb, mongodb, elasticsearch", "benchmark even when the loaded dataset is not RLP_DATA_DIR's (--force)"},
+	{"RLP_ALLOW_UNKNOWN_ENV", "bool", "false", "main", "run even when unknown BENCH_*/RLP_* variables are set"},
+
+	// benchmark users
+	{"BENCH_LOOKUPRES_MANAGE_USER", "user id", "", "csv, " + allBackends, "heavy manage user of the lookup and check scenarios"},
+	{"BENCH_LOOKUPRES_VIEW_USER", "user id", "", "csv, " + allBackends, "regular view user of the lookup and check scenarios"},
+	{"BENCH_LOOKUPRES_VIRAL_USER", "user id", "", allBackends, "user of lookup_resources_view_viral"},
+	{"BENCH_LOOKUPRES_MANAGE_PCT_USERS", "percentile list", "", "csv, " + allBackends, "p<N>:<user> users of lookup_resources_manage_pct"},
+	{"BENCH_LOOKUPRES_VIEW_PCT_USERS", "percentile list", "", "csv, " + allBackends, "p<N>:<user> users of lookup_resources_view_pct"},
+	{"BENCH_VIRAL_RESOURCES", "id list", "", allBackends, "viral resources of check_view_viral_direct_user"},
+
+	// read scenarios
+	{"BENCH_CHECK_DIRECT_SUPER_ITER", "int", "1000", "csv, " + allBackends, "check_manage_direct_user iterations (--iters)"},
+	{"BENCH_CHECK_ORGADMIN_ITER", "int", "1000", "csv, " + allBackends, "check_manage_org_admin iterations (--iters)"},
+	{"BENCH_CHECK_VIEW_GROUP_ITER", "int", "1000", "csv, " + allBackends, "check_view_via_group_member iterations (--iters)"},
+	{"BENCH_CHECK_TIME_BOUNDED_ITER", "int", "1000", allBackends, "check_time_bounded_direct_user iterations (--iters)"},
+	{"BENCH_CHECK_BULK_ITER", "int", "100", spicedb + ", " + sqlBackends, "check_bulk_manage_direct_user iterations (--iters)"},
+	{"BENCH_CHECK_BULK_SIZE", "int", "100", spicedb + ", " + sqlBackends, "checks per bulk request"},
+	{"BENCH_CHECK_VIRAL_ITER", "int", "1000", allBackends, "check_view_viral_direct_user iterations (--iters)"},
+	{"BENCH_CHECK_WORST_ITER", "int", "1000", allBackends, "check_view_worst_case iterations (--iters); 0 skips it"},
+	{"BENCH_EXPORT_ITER", "int", "0", allBackends, "export_full_acl iterations; 0 skips it"},
+	{"BENCH_EXPORT_TIMEOUT_SEC", "int", "600", allBackends, "timeout of one export_full_acl stream"},
+	{"BENCH_WORKLOAD", "string", "", allBackends, "blended workload, name:op=weight,...; empty skips it"},
+	{"BENCH_WORKLOAD_ITER", "int", "1000", allBackends, "calls of the blended workload"},
+	{"BENCH_WORKLOAD_SEED", "int", "1", allBackends, "seed of the workload's operation draws"},
+	{"BENCH_WORKLOAD_PAGE", "int", "100", allBackends, "resources of a *_page lookup of the workload"},
+	{"BENCH_WORKLOAD_PAIRS", "int", "1000", allBackends, "direct grants the workload's checks cycle through"},
+	{"BENCH_ACCESS_FREQUENCY_FILE", "path", "", allBackends, "resource_id,weight CSV the workload and middleware pairs are drawn by"},
+	{"BENCH_ACCESS_FREQUENCY_DEFAULT", "float", "0", allBackends, "weight of resources the access frequency file does not list"},
+	{"BENCH_ACCESS_FREQUENCY_SEED", "int", "1", allBackends, "seed of the access frequency draws"},
+	{"BENCH_MY_GROUPS_ITER", "int", "100", spicedb + ", " + sqlBackends + ", mongodb, scylladb", "list_my_groups_* iterations; 0 skips them"},
+	{"BENCH_MY_GROUPS_USERS", "int", "10", spicedb + ", " + sqlBackends + ", mongodb, scylladb", "users list_my_groups_* spreads over light to heavy members"},
+	{"BENCH_ACCESS_SUMMARY_ITER", "int", "100", allBackends, "count_access_summary iterations; 0 skips it"},
+	{"BENCH_ACCESS_SUMMARY_USERS", "int", "10", allBackends, "users of the view lookup mix count_access_summary cycles through"},
+	{"BENCH_MIDDLEWARE_ITER", "int", "1000", "postgres, cockroachdb, clickhouse, scylladb, mongodb, elasticsearch", "middleware_overhead iterations; 0 skips it"},
+	{"BENCH_MIDDLEWARE_PAIRS", "int", "1000", "postgres, cockroachdb, clickhouse, scylladb, mongodb, elasticsearch", "direct grants middleware_overhead cycles through"},
+	{"BENCH_PERMISSION_RULES", "string", "", spicedb + ", " + sqlBackends, "derived permissions, standard or permission=term+term,...; empty skips them"},
+	{"BENCH_PERMISSIONS_CHECK_ITER", "int", "1000", spicedb + ", " + sqlBackends, "checks per permission of the permissions experiment"},
+	{"BENCH_PERMISSIONS_LOOKUP_ITER", "int", "10", spicedb + ", " + sqlBackends, "lookups per permission of the permissions experiment"},
+	{"BENCH_PERMISSIONS_PAIRS", "int", "1000", spicedb + ", " + sqlBackends, "direct grants the permissions checks cycle through"},
+	{"BENCH_PERMISSIONS_USERS", "int", "5", spicedb + ", " + sqlBackends, "lookup users of the permissions experiment"},
+	{"BENCH_DENIALS", "int", "0", spicedb + ", " + sqlBackends, "1 enforces the banned_user denials of resource_bans.csv"},
+	{"BENCH_DENIALS_CHECK_ITER", "int", "1000", spicedb + ", " + sqlBackends, "checks per variant of the denials experiment"},
+	{"BENCH_DENIALS_LOOKUP_ITER", "int", "10", spicedb + ", " + sqlBackends, "lookups per variant of the denials experiment"},
+	{"BENCH_DENIALS_PAIRS", "int", "500", spicedb + ", " + sqlBackends, "bans, and as many direct grants, the denials checks cycle through"},
+	{"BENCH_DENIALS_USERS", "int", "5", spicedb + ", " + sqlBackends, "most-banned users the denials lookups cycle through"},
+	{"BENCH_LOOKUPRES_MANAGE_ITER", "int", "10", "csv, " + allBackends, "lookup_resources_manage_super iterations (--iters)"},
+	{"BENCH_LOOKUPRES_VIEW_ITER", "int", "10", "csv, " + allBackends, "lookup_resources_view_regular iterations (--iters)"},
+	{"BENCH_LOOKUPRES_VIRAL_ITER", "int", "10", allBackends, "lookup_resources_view_viral iterations (--iters)"},
+	{"BENCH_LOOKUPRES_MIX_USERS", "int", "0", allBackends, "users per permission of the lookup mix; 0 skips it"},
+	{"BENCH_LOOKUPRES_MIX_ITER", "int", "3", allBackends, "lookups per mix user (--iters)"},
+	{"BENCH_LOOKUPRES_PCT_ITER", "int", "3", allBackends, "lookups per percentile user (--iters)"},
+	{"BENCH_LIST_RECENT_ITER", "int", "100", sqlBackends + ", clickhouse", "list_recent_viewable iterations; 0 skips it"},
+	{"BENCH_LIST_RECENT_LIMIT", "int", "50", sqlBackends + ", clickhouse", "rows per list_recent_viewable page"},
+	{"BENCH_LIST_RECENT_PAGES", "int", "2", sqlBackends + ", clickhouse", "pages per list_recent_viewable iteration"},
+	{"BENCH_LOOKUP_SAMPLE_LIMIT", "int", "1000", "all backends but mongodb", "pairs sampled for the check scenarios"},
+	{"BENCH_CUSTOM_SCENARIOS", "path", "", spicedb + ", " + sqlBackends + ", clickhouse", "custom_* scenario file"},
+	{"BENCH_TIMEOUT_BUDGET", "float", "0.05", allBackends, "share of iterations that may time out before a scenario fails"},
+	{"BENCH_STREAM_TIMING", "bool", "true", spicedb, "record time to first result and gaps of lookup streams"},
+	{"BENCH_STREAM_GAP_BUCKETS", "duration list", "10µs,100µs,1ms,10ms,100ms", spicedb, "upper bounds of the stream gap histogram"},
+	{"BENCH_READ_RELS_ITER", "int", "0", spicedb, "read_relationships_* iterations; 0 skips them"},
+	{"BENCH_READ_RELS_PAGE", "int", "0", spicedb, "tuples per ReadRelationships page; 0 reads one stream"},
+
+	// run setup and reporting
+	{"BENCH_CACHE_COMPARE", "int", "0", allBackends, "run every scenario on cold and on warm caches"},
+	{"BENCH_CACHE_WARM_SEC", "int", "30", allBackends, "seconds between the cold and the warm pass"},
+	{"BENCH_CACHE_FLUSH", "int", "0", allBackends, "flush the backend caches before the cold pass"},
+	{"BENCH_MAINTENANCE", "int", "0", churn, "run every scenario quiet and under background maintenance"},
+	{"BENCH_MAINTENANCE_PAUSE_MS", "int", "0", churn, "pause between maintenance rounds"},
+	{"BENCH_MAINTENANCE_ROUND_TIMEOUT_SEC", "int", "600", churn, "timeout of one maintenance round"},
+	{"BENCH_GOMAXPROCS", "int", "0", allBackends, "cap the CPUs of the benchmark client (--gomaxprocs)"},
+	{"BENCH_LOG_EVERY", "int", "0", allBackends, "log every Nth iteration of each scenario; 0 keeps the scenario's own count"},
+	{"BENCH_LOG_INTERVAL", "duration", "", allBackends, "log one iteration per interval (e.g. 5s) instead of a count"},
+	{"BENCH_AUDIT_DIR", "path", "", allBackends, "write every timed call to an audit file in this directory"},
+	{"BENCH_HDR_DIR", "path", "", allBackends, "write a .hgrm latency histogram per scenario to this directory"},
+	{"BENCH_SLOWEST_N", "int", "10", allBackends, "slowest calls per scenario logged as SLOW lines; 0 turns it off"},
+	{"BENCH_ORACLE_URL", "url", "", allBackends, "serve endpoint every timed check is verified against"},
+	{"BENCH_ORACLE_WORKERS", "int", "4", allBackends, "concurrent oracle checks"},
+	{"BENCH_ORACLE_QUEUE", "int", "10000", allBackends, "checks waiting for the oracle before new ones are skipped"},
+	{"BENCH_ORACLE_TIMEOUT_SEC", "int", "2", allBackends, "timeout of one oracle check"},
+	{"BENCH_TUI", "bool", "false", "main", "live dashboard instead of the log (--tui)"},
+	{"BENCH_OUTPUT", "string", "text", "main", "ndjson streams benchmark results to stdout (--output)"},
+	{"BENCH_PRINT_QUERIES", "bool", "false", "main", "benchmark prints the queries of the read scenarios instead of running them (--print-queries)"},
+	{"BENCH_TUI_LOG", "path", "benchmark/3-3-benchmark.log", "main", "log the dashboard follows"},
+	{"BENCH_TUI_REFRESH_MS", "int", "500", "main", "dashboard refresh interval"},
+	{"BENCH_PG_READ_TARGET", "string", "primary", "postgres", "replica runs the reads against PG_REPLICA_HOST"},
+	{"BENCH_PG_REPLICA_LAG_INTERVAL_SEC", "int", "10", "postgres", "replica lag sampling interval"},
+	{"BENCH_COST", "bool", "false", "postgres, cockroachdb, clickhouse, mongodb, elasticsearch", "log the server-side cost of each scenario as COST lines"},
+	{"BENCH_STATEMENT_STATS", "bool", "false", sqlBackends, "log server-side statement stats after the run"},
+	{"BENCH_STATEMENT_STATS_TOP", "int", "10", sqlBackends, "statements to log"},
+	{"BENCH_DRIVER_COMPARE", "bool", "false", sqlBackends + ", clickhouse", "time the check query through each driver"},
+	{"BENCH_DRIVER_COMPARE_ITER", "int", "1000", sqlBackends + ", clickhouse", "driver_overhead iterations"},
+	{"BENCH_DRIVER_COMPARE_TIMEOUT_MS", "int", "2000", sqlBackends + ", clickhouse", "driver_overhead per-query timeout"},
+	{"BENCH_PREPARED_STATEMENTS", "bool", "false", sqlBackends + ", clickhouse", "prepare the check and lookup queries (server-side parameters on ClickHouse)"},
+	{"BENCH_CHECK_BATCH_SIZE", "int", "0", "postgres", "checks per batch of check_batching; 0 skips it"},
+	{"BENCH_CHECK_BATCH_ITER", "int", "100", "postgres", "batches per check_batching variant (--iters)"},
+	{"BENCH_COORDINATOR", "url", "", allBackends, "coordinator the worker action registers with"},
+
+	// writes
+	{"BENCH_WRITE_ITER", "int", "0", spicedb, "write_grant/write_revoke iterations; 0 skips them"},
+	{"BENCH_WRITE_USER", "user id", "rlp_write_bench", spicedb, "subject of the write scenarios"},
+	{"BENCH_WRITE_RESOURCES", "int", "100", spicedb, "resources the write scenarios cycle through"},
+	{"BENCH_WATCH_DRAIN", "int", "5", spicedb, "seconds to wait for Watch changes after the writes"},
+	{"BENCH_ESCALATION_CYCLES", "int", "0", spicedb, "org_admin_escalation cycles; 0 skips it"},
+	{"BENCH_ESCALATION_USER", "user id", "$BENCH_LOOKUPRES_VIEW_USER", spicedb, "user toggled by org_admin_escalation"},
+	{"BENCH_GROUP_CHURN_BURSTS", "int list", "1,10,100", churn, "group-churn burst sizes"},
+	{"BENCH_GROUP_CHURN_ITER", "int", "5", churn, "group-churn bursts per size"},
+	{"BENCH_OFFBOARD_USERS", "user id list", "", allBackends, "users deleted by offboard (required; destructive)"},
+	{"BENCH_OFFBOARD_CHECKS", "int", "10", allBackends, "resources held before offboard checked per permission after it"},
+
+	// scripts
+	{"BENCH_RUNS", "int", "3", "benchmark/3-benchmark.sh", "benchmark runs per engine"},
+	{"BENCH_CPUSET", "cpu list", "", "benchmark/3-benchmark.sh", "pin the benchmark client with taskset"},
+}
+
+// configPrefixes are the prefixes ValidateEnv checks.
+var configPrefixes = []string{"BENCH_", "RLP_"}
+
+// ValidateEnv returns an error naming every BENCH_* or RLP_* variable in the
+// environment that is not in ConfigVars, with the closest known name, so a
+// typo like BENCH_LOOKUPERS_VIEW_USER fails the run instead of silently
+// falling back to a default. RLP_ALLOW_UNKNOWN_ENV=true turns it off.
+func ValidateEnv() error {
+	if os.Getenv("RLP_ALLOW_UNKNOWN_ENV") == "true" {
+		return nil
+	}
+	known := make([]string, len(ConfigVars))
+	for i, v := range ConfigVars {
+		known[i] = v.Name
+	}
+	var unknown []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if !slices.ContainsFunc(configPrefixes, func(p string) bool { return strings.HasPrefix(name, p) }) || slices.Contains(known, name) {
+			continue
+		}
+		msg := name
+		if s := closestName(name, known); s != "" {
+			msg += " (did you mean " + s + "?)"
+		}
+		unknown = append(unknown, msg)
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	slices.Sort(unknown)
+	return fmt.Errorf("unknown env vars: %s; see `config list`, or set RLP_ALLOW_UNKNOWN_ENV=true", strings.Join(unknown, ", "))
+}
+
+// closestName returns the name of known nearest to name by edit distance,
+// "" when none is within a quarter of its length.
+func closestName(name string, known []string) string {
+	best, bestDist := "", len(name)/4+1
+	for _, k := range known {
+		if d := editDistance(name, k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance of a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// PrintConfig prints ConfigVars as a table, limited to the variables whose
+// Modules mention module (or every backend) when module is not empty.
+func PrintConfig(module string) {
+	fmt.Println("| Variable | Type | Default | Modules | Description |")
+	fmt.Println("|----------|------|---------|---------|-------------|")
+	for _, v := range ConfigVars {
+		if module != "" && !configVarFor(v, module) {
+			continue
+		}
+		def := v.Default
+		if def == "" {
+			def = "-"
+		}
+		fmt.Printf("| %s | %s | %s | %s | %s |\n", v.Name, v.Type, def, v.Modules, v.Help)
+	}
+}
+
+// configVarFor reports whether v is read by module.
+func configVarFor(v ConfigVar, module string) bool {
+	if strings.Contains(v.Modules, allBackends) && module != "csv" {
+		return !strings.Contains(v.Modules, "but "+module)
+	}
+	for m := range strings.SplitSeq(v.Modules, ", ") {
+		if m == module || (m == spicedb && strings.HasPrefix(module, "authzed_")) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShowConfig prints the effective configuration of module as a table: every
+// registered variable the module reads, with its value and where it came
+// from, followed by any other variable sources names (the env files' own
+// settings: connections, credentials). sources maps a variable to the env
+// file that set it, or "flag"; variables it does not name come from the
+// environment, or are unset and shown with their default. Values of names
+// containing PASSWORD, TOKEN, SECRET or KEY are masked.
+func ShowConfig(module string, sources map[string]string) {
+	fmt.Println("| Variable | Value | Source |")
+	fmt.Println("|----------|-------|--------|")
+	shown := map[string]bool{}
+	show := func(name, def string) {
+		shown[name] = true
+		val, set := os.LookupEnv(name)
+		source := sources[name]
+		switch {
+		case !set:
+			val, source = def, "default"
+			if val == "" {
+				val = "-"
+			}
+		case source == "":
+			source = "environment"
+		}
+		if set && val != "" && containsAny(name, configEnvSecrets...) {
+			val = "****"
+		}
+		fmt.Printf("| %s | %s | %s |\n", name, val, source)
+	}
+	for _, v := range ConfigVars {
+		if module == "" || configVarFor(v, module) {
+			show(v.Name, v.Default)
+		}
+	}
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		if !shown[name] {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		show(name, "")
+	}
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CostCounter is one cumulative server-side counter a CostMeter reads, e.g.
+// rows or bytes read since the server started or since the benchmark began.
+type CostCounter struct {
+	Name  string
+	Value float64
+}
+
+// CostMeter snapshots a backend's cumulative cost counters. The first counter
+// is the number of queries (or statements, or searches) they cover, which the
+// others are divided by in the COST line. Meters read what the backend already
+// exposes: ClickHouse system.query_log, CockroachDB statement statistics,
+// pg_stat_statements, MongoDB serverStatus and Elasticsearch index stats.
+type CostMeter func(ctx context.Context) ([]CostCounter, error)
+
+// costScenario is the scenario whose cost is being accumulated for an engine.
+type costScenario struct {
+	meter    CostMeter
+	scenario string
+	baseline []CostCounter
+}
+
+var (
+	costMu    sync.Mutex
+	costState = map[string]*costScenario{}
+)
+
+// StartCost enables per-scenario cost accounting for engine when
+// BENCH_COST=true. From then on every LogScenarioConfig call closes the
+// previous scenario of the engine, logging the counters' growth since its
+// CONFIG line as
+//
+//	[engine] [scenario] COST: queries=N <counter>=<total>/<per query> ...
+//
+// and starts the next one. The returned func closes the last scenario. A meter
+// that fails (missing privilege or extension) logs a warning and turns
+// accounting off for the run.
+func StartCost(engine string, meter CostMeter) func() {
+	if GetEnvWithDefault("BENCH_COST", "false") != "true" {
+		return func() {}
+	}
+	costMu.Lock()
+	costState[engine] = &costScenario{meter: meter}
+	costMu.Unlock()
+	log.Printf("[%s] Cost accounting on (BENCH_COST)", engine)
+	return func() {
+		costMu.Lock()
+		defer costMu.Unlock()
+		if s := costState[engine]; s != nil {
+			closeCostScenario(engine, s)
+		}
+		delete(costState, engine)
+	}
+}
+
+// nextCostScenario closes the engine's current scenario, if any, and starts
+// accounting for scenario. Called by LogScenarioConfig.
+func nextCostScenario(engine, scenario string) {
+	costMu.Lock()
+	defer costMu.Unlock()
+	s := costState[engine]
+	if s == nil {
+		return
+	}
+	closeCostScenario(engine, s)
+	if costState[engine] == nil {
+		return
+	}
+	baseline, err := snapshotCost(s.meter)
+	if err != nil {
+		log.Printf("[%s] WARN: cost snapshot failed, cost accounting off: %v", engine, err)
+		delete(costState, engine)
+		return
+	}
+	s.scenario, s.baseline = scenario, baseline
+}
+
+// closeCostScenario logs the COST line of the current scenario of s.
+func closeCostScenario(engine string, s *costScenario) {
+	if s.scenario == "" {
+		return
+	}
+	scenario := s.scenario
+	s.scenario = ""
+	now, err := snapshotCost(s.meter)
+	if err != nil {
+		log.Printf("[%s] WARN: cost snapshot failed, cost accounting off: %v", engine, err)
+		delete(costState, engine)
+		return
+	}
+	log.Printf("[%s] [%s] COST: %s", engine, scenario, formatCost(s.baseline, now))
+}
+
+func snapshotCost(meter CostMeter) ([]CostCounter, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return meter(ctx)
+}
+
+// formatCost formats the growth of each counter from before to after: the
+// first as queries=N, the others as total/per query.
+func formatCost(before, after []CostCounter) string {
+	prev := map[string]float64{}
+	for _, c := range before {
+		prev[c.Name] = c.Value
+	}
+	if len(after) == 0 {
+		return "queries=0"
+	}
+	// Counters are never reset mid-run by the benchmark; clamp in case the
+	// server was restarted or its statistics were reset under us.
+	queries := max(after[0].Value-prev[after[0].Name], 0)
+	parts := []string{fmt.Sprintf("%s=%.0f", after[0].Name, queries)}
+	for _, c := range after[1:] {
+		delta := max(c.Value-prev[c.Name], 0)
+		per := 0.0
+		if queries > 0 {
+			per = delta / queries
+		}
+		parts = append(parts, fmt.Sprintf("%s=%.0f/%.1f", c.Name, delta, per))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,290 @@
+package utils
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"test-tls/ids"
+)
+
+// CustomScenario is a user-defined benchmark scenario from the YAML file named
+// by BENCH_CUSTOM_SCENARIOS. Each iteration takes the next row of its
+// parameter source and runs the backend's query (SQL backends) or permission
+// check (SpiceDB) with it. A backend the scenario has no query for skips it.
+//
+//	scenarios:
+//	  - name: view_org_admin            # logged as custom_view_org_admin
+//	    iterations: 500                 # default 1000
+//	    timeout_ms: 2000                # default 2000
+//	    log_every: 10                   # default 10
+//	    params:
+//	      csv: org_memberships.csv      # under data/, cycled when exhausted
+//	      where: {role: admin}          # only rows with these column values
+//	      columns: [org_id, user_id]    # bound in order as $1, $2 (? on ClickHouse)
+//	    queries:
+//	      postgres: SELECT resource_id FROM user_resource_permissions WHERE org_id = $1 AND user_id = $2
+//	    spicedb:                        # authzed_crdb and authzed_pgdb
+//	      resource: organization:{org_id}
+//	      permission: admin
+//	      subject: user:{user_id}
+type CustomScenario struct {
+	Name       string            `yaml:"name"`
+	Iterations int               `yaml:"iterations"`
+	TimeoutMs  int               `yaml:"timeout_ms"`
+	LogEvery   int               `yaml:"log_every"`
+	Params     CustomParams      `yaml:"params"`
+	Queries    map[string]string `yaml:"queries"`
+	SpiceDB    *CustomCheck      `yaml:"spicedb"`
+}
+
+// CustomParams is where a custom scenario takes its parameters from.
+type CustomParams struct {
+	CSV     string            `yaml:"csv"`
+	Where   map[string]string `yaml:"where"`
+	Columns []string          `yaml:"columns"`
+}
+
+// CustomCheck is a SpiceDB permission check; {column} in Resource and Subject
+// is replaced by the row's value. Subject may carry a relation
+// (group:{group_id}#member).
+type CustomCheck struct {
+	Resource   string `yaml:"resource"`
+	Permission string `yaml:"permission"`
+	Subject    string `yaml:"subject"`
+}
+
+// CustomRow is one parameter row of a custom scenario, keyed by CSV column.
+type CustomRow map[string]string
+
+// CustomExec runs one iteration of sc with row and returns how many rows
+// (SQL) or allowed checks (SpiceDB) it produced.
+type CustomExec func(ctx context.Context, sc CustomScenario, row CustomRow) (int, error)
+
+var customNameRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// LoadCustomScenarios reads the scenarios in BENCH_CUSTOM_SCENARIOS; unset
+// means none.
+func LoadCustomScenarios() []CustomScenario {
+	path := GetEnvWithDefault("BENCH_CUSTOM_SCENARIOS", "")
+	if path == "" {
+		return nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("[custom] read %s: %v", path, err)
+	}
+	var file struct {
+		Scenarios []CustomScenario `yaml:"scenarios"`
+	}
+	if err := yaml.Unmarshal(b, &file); err != nil {
+		log.Fatalf("[custom] parse %s: %v", path, err)
+	}
+	for i := range file.Scenarios {
+		sc := &file.Scenarios[i]
+		if !customNameRe.MatchString(sc.Name) {
+			log.Fatalf("[custom] %s: scenario name %q must match [a-z0-9_]+", path, sc.Name)
+		}
+		if sc.Params.CSV == "" {
+			log.Fatalf("[custom] %s: scenario %s has no params.csv", path, sc.Name)
+		}
+		if sc.Iterations <= 0 {
+			sc.Iterations = 1000
+		}
+		if sc.TimeoutMs <= 0 {
+			sc.TimeoutMs = 2000
+		}
+		if sc.LogEvery <= 0 {
+			sc.LogEvery = 10
+		}
+	}
+	return file.Scenarios
+}
+
+// RunCustomScenarios runs every custom scenario that supports engine,
+// logging it like a builtin check_* scenario under the name custom_<name> so
+// benchmark/parse_all.go reports it with the rest. SpiceDB scenarios run on
+// any authzed_* engine.
+func RunCustomScenarios(engine string, exec CustomExec) {
+	for _, sc := range LoadCustomScenarios() {
+		name := "custom_" + sc.Name
+		if !sc.Supports(engine) {
+			log.Printf("[%s] [%s] skipped: no query for %s", engine, name, engine)
+			continue
+		}
+		params := openCustomParams(sc.Params)
+		timeout := time.Duration(sc.TimeoutMs) * time.Millisecond
+		log.Printf("[%s] [%s] streaming mode. iterations=%d", engine, name, sc.Iterations)
+		LogScenarioConfig(engine, name, sc.Iterations, timeout)
+		errs := NewErrorTally()
+		for i := 0; i < sc.Iterations; i++ {
+			row := params.next()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			start := time.Now()
+			n, err := exec(ctx, sc, row)
+			dur := time.Since(start)
+			cancel()
+			if err != nil {
+				class := errs.Record(err)
+				log.Printf("[%s] [%s] iter=%d failed class=%s: %v", engine, name, i, class, err)
+				continue
+			}
+			if LogIteration(i, sc.LogEvery) {
+				log.Printf("[%s] [%s] iter=%d params=%v rows=%d dur=%s", engine, name, i, sc.Args(row), n, dur)
+			}
+		}
+		params.close()
+		log.Printf("[%s] [%s] DONE: iters=%d", engine, name, sc.Iterations)
+		log.Printf("[%s] [%s] ERRORS: %s", engine, name, errs.Summary(sc.Iterations))
+	}
+}
+
+// Supports reports whether sc has a query (or, for authzed_*, a check) for
+// engine.
+func (sc CustomScenario) Supports(engine string) bool {
+	if strings.HasPrefix(engine, "authzed_") {
+		return sc.SpiceDB != nil
+	}
+	return sc.Queries[engine] != ""
+}
+
+// Args returns row's values of the parameter columns, in order, as written
+// in the CSV (external ids).
+func (sc CustomScenario) Args(row CustomRow) []any {
+	args := make([]any, len(sc.Params.Columns))
+	for i, c := range sc.Params.Columns {
+		args[i] = row[c]
+	}
+	return args
+}
+
+// IntArgs is Args for backends with integer id columns: <kind>_id columns
+// (and subject_id, by the row's subject_type) are converted with ids.Parse,
+// other columns are passed through.
+func (sc CustomScenario) IntArgs(row CustomRow) ([]any, error) {
+	args := sc.Args(row)
+	for i, c := range sc.Params.Columns {
+		kind, ok := customIDKind(c, row)
+		if !ok {
+			continue
+		}
+		n, err := ids.Parse(kind, row[c])
+		if err != nil {
+			return nil, fmt.Errorf("param %s: %w", c, err)
+		}
+		args[i] = n
+	}
+	return args, nil
+}
+
+func customIDKind(column string, row CustomRow) (ids.Kind, bool) {
+	switch column {
+	case "org_id":
+		return ids.Org, true
+	case "user_id":
+		return ids.User, true
+	case "group_id", "parent_group_id", "child_group_id":
+		return ids.Group, true
+	case "resource_id":
+		return ids.Resource, true
+	case "subject_id":
+		return ids.SubjectKind(row["subject_type"]), true
+	}
+	return "", false
+}
+
+var customPlaceholderRe = regexp.MustCompile(`\{([a-z0-9_]+)\}`)
+
+// Expand fills the {column} placeholders of a CustomCheck field from row.
+func (row CustomRow) Expand(template string) string {
+	return customPlaceholderRe.ReplaceAllStringFunc(template, func(m string) string {
+		return row[m[1:len(m)-1]]
+	})
+}
+
+// customParams cycles through the rows of a data/ CSV that match Where.
+type customParams struct {
+	src     CustomParams
+	f       *os.File
+	r       *csv.Reader
+	header  []string
+	matched int
+}
+
+func openCustomParams(src CustomParams) *customParams {
+	p := &customParams{src: src}
+	p.reopen()
+	return p
+}
+
+func (p *customParams) reopen() {
+	if p.f != nil {
+		p.f.Close()
+	}
+	path := filepath.Join(DataDir(), p.src.CSV)
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("[custom] open %s: %v", path, err)
+	}
+	p.f = f
+	p.r = ScopeCSV(p.src.CSV, csv.NewReader(f))
+	if p.header, err = p.r.Read(); err != nil {
+		log.Fatalf("[custom] %s: read header: %v", path, err)
+	}
+	for _, c := range p.src.Columns {
+		if !slices.Contains(p.header, c) {
+			log.Fatalf("[custom] %s has no column %q", path, c)
+		}
+	}
+}
+
+func (p *customParams) next() CustomRow {
+	for {
+		rec, err := p.r.Read()
+		if err == io.EOF {
+			if p.matched == 0 {
+				log.Fatalf("[custom] %s: no rows match %v", p.src.CSV, p.src.Where)
+			}
+			p.reopen()
+			continue
+		}
+		if err != nil {
+			log.Fatalf("[custom] %s: read row: %v", p.src.CSV, err)
+		}
+		row := make(CustomRow, len(p.header))
+		for i, c := range p.header {
+			if i < len(rec) {
+				row[c] = rec[i]
+			}
+		}
+		if p.matches(row) {
+			p.matched++
+			return row
+		}
+	}
+}
+
+func (p *customParams) matches(row CustomRow) bool {
+	for c, v := range p.src.Where {
+		if row[c] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *customParams) close() {
+	if p.f != nil {
+		p.f.Close()
+	}
+}
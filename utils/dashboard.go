@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log lines the dashboard understands. They are the same lines parse_all.go
+// reads, so the dashboard needs no hooks in the scenarios themselves.
+var (
+	reDashStart  = regexp.MustCompile(`\] \[([a-z0-9_]+)\] (?:streaming mode\. )?iterations=(\d+)`)
+	reDashSample = regexp.MustCompile(`\] \[([a-z0-9_]+)\] (?:lookup )?iter=(\d+) .*?(?:dur|duration)=(\S+)$`)
+	reDashFailed = regexp.MustCompile(`\] \[([a-z0-9_]+)\] iter=(\d+) .*failed class=`)
+	reDashDone   = regexp.MustCompile(`\] \[([a-z0-9_]+)\] DONE:`)
+)
+
+// dashWindow is how many recent samples the rolling percentiles cover.
+const dashWindow = 200
+
+type dashScenario struct {
+	name    string
+	total   int
+	iter    int
+	errors  int
+	started time.Time
+	ended   time.Time
+	samples []time.Duration // ring of the last dashWindow samples
+	next    int
+}
+
+func (s *dashScenario) add(d time.Duration) {
+	if len(s.samples) < dashWindow {
+		s.samples = append(s.samples, d)
+		return
+	}
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % dashWindow
+}
+
+func (s *dashScenario) percentiles() (p50, p99 time.Duration) {
+	if len(s.samples) == 0 {
+		return 0, 0
+	}
+	sorted := append([]time.Duration(nil), s.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	at := func(q float64) time.Duration { return sorted[int(q*float64(len(sorted)-1))] }
+	return at(0.50), at(0.99)
+}
+
+// dashboard is an io.Writer for the log package: it keeps per-scenario state
+// from the lines it is given and redraws the terminal on a ticker.
+type dashboard struct {
+	mu        sync.Mutex
+	engine    string
+	out       io.Writer
+	started   time.Time
+	scenarios []*dashScenario
+	byName    map[string]*dashScenario
+	recent    []string // last few lines that are not samples (warnings, errors)
+	partial   []byte
+}
+
+func (d *dashboard) scenario(name string) *dashScenario {
+	s, ok := d.byName[name]
+	if !ok {
+		s = &dashScenario{name: name, started: time.Now()}
+		d.byName[name] = s
+		d.scenarios = append(d.scenarios, s)
+	}
+	return s
+}
+
+func (d *dashboard) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.partial = append(d.partial, p...)
+	for {
+		i := bytes.IndexByte(d.partial, '\n')
+		if i < 0 {
+			break
+		}
+		d.observe(string(d.partial[:i]))
+		d.partial = d.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (d *dashboard) observe(line string) {
+	if m := reDashSample.FindStringSubmatch(line); m != nil {
+		s := d.scenario(m[1])
+		if n, err := strconv.Atoi(m[2]); err == nil && n > s.iter {
+			s.iter = n
+		}
+		if dur, err := time.ParseDuration(m[3]); err == nil {
+			s.add(dur)
+		}
+		return
+	}
+	if m := reDashStart.FindStringSubmatch(line); m != nil {
+		s := d.scenario(m[1])
+		s.total, _ = strconv.Atoi(m[2])
+		return
+	}
+	if m := reDashFailed.FindStringSubmatch(line); m != nil {
+		d.scenario(m[1]).errors++
+	} else if m := reDashDone.FindStringSubmatch(line); m != nil {
+		s := d.scenario(m[1])
+		s.ended = time.Now()
+		if s.total > s.iter {
+			s.iter = s.total
+		}
+		return
+	}
+	d.recent = append(d.recent, line)
+	if len(d.recent) > 5 {
+		d.recent = d.recent[len(d.recent)-5:]
+	}
+}
+
+func (d *dashboard) render() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	fmt.Fprintf(&b, "[%s] benchmark  elapsed %s\n\n", d.engine, time.Since(d.started).Truncate(time.Second))
+	fmt.Fprintf(&b, "%-40s %-22s %10s %10s %9s %7s\n", "scenario", "progress", "p50", "p99", "qps", "errors")
+	for _, s := range d.scenarios {
+		end := time.Now()
+		status := ""
+		if !s.ended.IsZero() {
+			end = s.ended
+			status = "  done"
+		}
+		qps := 0.0
+		if secs := end.Sub(s.started).Seconds(); secs > 0 {
+			qps = float64(s.iter) / secs
+		}
+		p50, p99 := s.percentiles()
+		fmt.Fprintf(&b, "%-40s %-22s %10s %10s %9.1f %7d%s\n",
+			s.name, dashProgress(s.iter, s.total), dashDur(p50), dashDur(p99), qps, s.errors, status)
+	}
+	if len(d.recent) > 0 {
+		b.WriteString("\n")
+		for _, line := range d.recent {
+			b.WriteString(line + "\n")
+		}
+	}
+	io.WriteString(d.out, b.String())
+}
+
+func dashProgress(iter, total int) string {
+	if total <= 0 {
+		return strconv.Itoa(iter)
+	}
+	const width = 10
+	filled := min(iter*width/total, width)
+	return fmt.Sprintf("%s%s %3d%%", strings.Repeat("#", filled), strings.Repeat(".", width-filled), min(iter*100/total, 100))
+}
+
+func dashDur(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2fms", float64(d.Microseconds())/1000)
+}
+
+// StartDashboard replaces the scrolling benchmark log on the terminal with a
+// live table per scenario: progress, rolling p50/p99 over the last samples,
+// QPS and error count, redrawn every BENCH_TUI_REFRESH_MS (default 500). The
+// full log still goes to BENCH_TUI_LOG (default benchmark/3-3-benchmark.log,
+// appended), so parse_all.go works on the run afterwards. The returned func
+// draws the final frame and restores the log output.
+//
+// The table is built from the log lines only; check scenarios log every 100th
+// iteration, so their progress and percentiles move in steps of 100.
+func StartDashboard(engine string) func() {
+	path := GetEnvWithDefault("BENCH_TUI_LOG", "benchmark/3-3-benchmark.log")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Fatalf("[%s] [tui] open %s: %v", engine, path, err)
+	}
+
+	d := &dashboard{engine: engine, out: os.Stderr, started: time.Now(), byName: map[string]*dashScenario{}}
+	log.SetOutput(io.MultiWriter(f, d))
+
+	refresh := time.Duration(GetEnvInt("BENCH_TUI_REFRESH_MS", 500)) * time.Millisecond
+	ticker := time.NewTicker(refresh)
+	stopped := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				d.render()
+			case <-stopped:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(stopped)
+		d.render()
+		log.SetOutput(os.Stderr)
+		f.Close()
+		fmt.Fprintf(os.Stderr, "\nfull log appended to %s\n", path)
+	}
+}
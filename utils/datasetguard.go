@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// DatasetStore is where a backend records which dataset load-data loaded into
+// it: the id `data list` shows, the hash of the dataset's manifest (see
+// datasetID). The SQL backends and ScyllaDB keep it in an rlp_dataset table,
+// MongoDB in an rlp_dataset collection and Elasticsearch in the _meta of its
+// index.
+type DatasetStore interface {
+	RecordDataset(ctx context.Context, id string) error
+	// LoadedDataset returns the recorded id, "" when none was recorded.
+	LoadedDataset(ctx context.Context) (string, error)
+}
+
+// CurrentDatasetID returns the id of the dataset in RLP_DATA_DIR, "" when it
+// has no CSVs.
+func CurrentDatasetID() (string, error) {
+	m, ok, err := readDatasetManifest(DataDir())
+	if err != nil || !ok {
+		return "", err
+	}
+	id, _, err := datasetID(m)
+	return id, err
+}
+
+// MarkDatasetLoading is called by load-data before it writes any row: until
+// RecordDataset, store holds "loading:<id>", so a load that fails halfway
+// leaves a dataset GuardDataset refuses instead of the previous one.
+func MarkDatasetLoading(engine string, store DatasetStore) {
+	recordDataset(engine, store, "loading:")
+}
+
+// RecordDataset is called by load-data once every row is in: it records the
+// id of the dataset in RLP_DATA_DIR in store, for GuardDataset to check.
+func RecordDataset(engine string, store DatasetStore) {
+	recordDataset(engine, store, "")
+}
+
+func recordDataset(engine string, store DatasetStore, prefix string) {
+	id, err := CurrentDatasetID()
+	if err != nil {
+		log.Fatalf("[%s] dataset id of %s: %v", engine, DataDir(), err)
+	}
+	if id == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := store.RecordDataset(ctx, prefix+id); err != nil {
+		log.Fatalf("[%s] record dataset %s: %v", engine, prefix+id, err)
+	}
+	log.Printf("[%s] DATASET: recorded id=%s dir=%s", engine, prefix+id, DataDir())
+}
+
+// GuardDataset is called by every benchmark before its first scenario. It
+// compares the dataset recorded in store by the last load-data with the one
+// in RLP_DATA_DIR, which the benchmark picks its users, pairs and expected
+// results from, and refuses to run when they differ (or nothing was
+// recorded) unless BENCH_DATASET_FORCE=true (--force on the command line).
+// Without CSVs in RLP_DATA_DIR there is nothing to compare and it only warns.
+func GuardDataset(engine string, store DatasetStore) {
+	expected, err := CurrentDatasetID()
+	if err != nil {
+		log.Fatalf("[%s] dataset id of %s: %v", engine, DataDir(), err)
+	}
+	if expected == "" {
+		log.Printf("[%s] WARN: no dataset in %s; the loaded data is not verified", engine, DataDir())
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	loaded, err := store.LoadedDataset(ctx)
+	if err != nil {
+		log.Printf("[%s] WARN: read recorded dataset: %v", engine, err)
+	}
+	if loaded == "" {
+		loaded = "none"
+	}
+	log.Printf("[%s] DATASET: expected=%s loaded=%s dir=%s", engine, expected, loaded, DataDir())
+	if loaded == expected {
+		return
+	}
+	if GetEnvWithDefault("BENCH_DATASET_FORCE", "false") == "true" {
+		log.Printf("[%s] WARN: benchmarking dataset %s against %s (BENCH_DATASET_FORCE=true)", engine, loaded, expected)
+		return
+	}
+	log.Fatalf("[%s] refusing to benchmark: loaded dataset %s is not %s in %s; run load-data or rerun with --force", engine, loaded, expected, DataDir())
+}
@@ -0,0 +1,255 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DatasetsDir holds the datasets `csv generate` replaced in RLP_DATA_DIR, one
+// directory per dataset named after its id (see datasetID), each with its
+// CSVs and manifest.json. Point RLP_DATA_DIR at one to load it again. It does
+// not follow RLP_DATA_DIR, so every data directory archives into one place.
+func DatasetsDir() string {
+	return filepath.Join("data", "datasets")
+}
+
+// InDatasetsDir reports whether dir is, or lies inside, DatasetsDir.
+func InDatasetsDir(dir string) bool {
+	root, err1 := filepath.Abs(DatasetsDir())
+	abs, err2 := filepath.Abs(dir)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, abs)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// SameDir reports whether a and b name the same directory, a relative and
+// an absolute path to it included.
+func SameDir(a, b string) bool {
+	absA, err1 := filepath.Abs(a)
+	absB, err2 := filepath.Abs(b)
+	return err1 == nil && err2 == nil && absA == absB
+}
+
+// StoredDataset is one dataset `data list` reports: the current one in
+// RLP_DATA_DIR or an archived one in DatasetsDir.
+type StoredDataset struct {
+	ID       string
+	Path     string
+	Current  bool
+	Manifest DatasetManifest
+	Size     int64 // bytes of its files
+}
+
+// datasetID is the first 12 hex digits of the SHA-256 of the manifest.
+func datasetID(m DatasetManifest) (string, []byte, error) {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", nil, err
+	}
+	b = append(b, '\n')
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:12], b, nil
+}
+
+// datasetFiles returns the CSVs of dir that load-data reads, and its
+// manifest.json when there is one.
+func datasetFiles(dir string) []string {
+	var files []string
+	for _, name := range append(append([]string(nil), planCSVs...), "manifest") {
+		ext := ".csv"
+		if name == "manifest" {
+			ext = ".json"
+		}
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// readDatasetManifest reads the manifest of the dataset in dir. Datasets
+// generated without RLP_WRITE_BENCH_USERS=manifest have none; for those it
+// builds one from the CSVs: their row counts and the newest modification time
+// as generated_at. ok is false when dir has no CSVs.
+func readDatasetManifest(dir string) (m DatasetManifest, ok bool, err error) {
+	files := datasetFiles(dir)
+	if len(files) == 0 || (len(files) == 1 && filepath.Base(files[0]) == "manifest.json") {
+		return m, false, nil
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "manifest.json")); err == nil {
+		if err := json.Unmarshal(b, &m); err != nil {
+			return m, true, fmt.Errorf("parse %s: %w", filepath.Join(dir, "manifest.json"), err)
+		}
+		if len(m.Rows) > 0 {
+			return m, true, nil
+		}
+	}
+
+	rows, _, err := datasetRows(dir)
+	if err != nil {
+		return m, true, err
+	}
+	m.DatasetRows = rows
+	if m.GeneratedAt.IsZero() {
+		for _, f := range files {
+			if info, err := os.Stat(f); err == nil && info.ModTime().After(m.GeneratedAt) {
+				m.GeneratedAt = info.ModTime().UTC().Truncate(time.Second)
+			}
+		}
+	}
+	return m, true, nil
+}
+
+// ArchiveDataset moves the dataset in dir (its CSVs and manifest.json) to
+// DatasetsDir()/<id>, so that `csv generate` does not overwrite it. A dataset
+// without a manifest gets one built from its CSVs. It returns the id, or ""
+// when dir has no dataset.
+func ArchiveDataset(dir string) (string, error) {
+	m, ok, err := readDatasetManifest(dir)
+	if err != nil || !ok {
+		return "", err
+	}
+	id, manifest, err := datasetID(m)
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(DatasetsDir(), id)
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return "", err
+	}
+	for _, f := range datasetFiles(dir) {
+		if err := os.Rename(f, filepath.Join(dest, filepath.Base(f))); err != nil {
+			return "", err
+		}
+	}
+	// The manifest is rewritten as hashed, so the id can be checked again.
+	if err := os.WriteFile(filepath.Join(dest, "manifest.json"), manifest, 0o644); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// ListDatasets returns the current dataset in RLP_DATA_DIR (when it has
+// CSVs) followed by the archived ones, newest first.
+func ListDatasets() ([]StoredDataset, error) {
+	var list []StoredDataset
+	add := func(dir string, current bool) error {
+		m, ok, err := readDatasetManifest(dir)
+		if err != nil || !ok {
+			return err
+		}
+		id, _, err := datasetID(m)
+		if err != nil {
+			return err
+		}
+		if !current && filepath.Base(dir) != id {
+			id = filepath.Base(dir) // renamed or edited; keep the name it is stored under
+		}
+		var size int64
+		for _, f := range datasetFiles(dir) {
+			if info, err := os.Stat(f); err == nil {
+				size += info.Size()
+			}
+		}
+		list = append(list, StoredDataset{ID: id, Path: dir, Current: current, Manifest: m, Size: size})
+		return nil
+	}
+
+	if err := add(DataDir(), true); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(DatasetsDir())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, e := range entries {
+		dir := filepath.Join(DatasetsDir(), e.Name())
+		if !e.IsDir() || SameDir(dir, DataDir()) {
+			continue // the current dataset was loaded from its archive
+		}
+		if err := add(dir, false); err != nil {
+			return nil, err
+		}
+	}
+
+	archived := list
+	if len(list) > 0 && list[0].Current {
+		archived = list[1:]
+	}
+	sort.SliceStable(archived, func(i, j int) bool {
+		return archived[i].Manifest.GeneratedAt.After(archived[j].Manifest.GeneratedAt)
+	})
+	return list, nil
+}
+
+// PrintDatasets prints ListDatasets as a markdown table, like `config list`.
+func PrintDatasets() error {
+	list, err := ListDatasets()
+	if err != nil {
+		return err
+	}
+	fmt.Println("| ID | Generated | Seed | ID format | Rows | Size | Path |")
+	fmt.Println("|----|-----------|------|-----------|------|------|------|")
+	for _, d := range list {
+		id := d.ID
+		if d.Current {
+			id += " (current)"
+		}
+		seed, format := "-", "-"
+		if d.Manifest.Seed != 0 {
+			seed = fmt.Sprint(d.Manifest.Seed)
+		}
+		if d.Manifest.IDFormat != "" {
+			format = d.Manifest.IDFormat
+		}
+		fmt.Printf("| %s | %s | %s | %s | %d | %s | %s |\n", id, d.Manifest.GeneratedAt.Format(time.RFC3339),
+			seed, format, d.Manifest.total(), formatSize(float64(d.Size)), d.Path)
+	}
+	return nil
+}
+
+// CleanDatasets deletes the archived datasets but the keep newest. The
+// current dataset in RLP_DATA_DIR is never deleted.
+func CleanDatasets(keep int) error {
+	if keep < 0 {
+		return fmt.Errorf("keep-latest must not be negative, got %d", keep)
+	}
+	list, err := ListDatasets()
+	if err != nil {
+		return err
+	}
+	var archived []StoredDataset
+	for _, d := range list {
+		if !d.Current {
+			archived = append(archived, d)
+		}
+	}
+	if len(archived) <= keep {
+		log.Printf("[data] %d archived datasets, keeping all (keep-latest=%d)", len(archived), keep)
+		return nil
+	}
+
+	var freed int64
+	for _, d := range archived[keep:] {
+		if err := os.RemoveAll(d.Path); err != nil {
+			return err
+		}
+		freed += d.Size
+		log.Printf("[data] removed %s (generated %s, %s)", d.ID, d.Manifest.GeneratedAt.Format(time.RFC3339), formatSize(float64(d.Size)))
+	}
+	log.Printf("[data] removed %d archived datasets, kept %d, freed %s", len(archived)-keep, keep, formatSize(float64(freed)))
+	return nil
+}
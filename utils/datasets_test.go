@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestListDatasetsAbsoluteDataDir loads a dataset from its archive through an
+// absolute RLP_DATA_DIR: it is listed once, as the current dataset, so
+// `data clean` never takes it for an archived one.
+func TestListDatasetsAbsoluteDataDir(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := filepath.Join(DatasetsDir(), "archived")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range map[string]string{
+		"organizations.csv": "org_id\n1\n",
+		"users.csv":         "user_id\n1\n2\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RLP_DATA_DIR", abs)
+
+	list, err := ListDatasets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !list[0].Current {
+		t.Fatalf("ListDatasets() = %+v, want the one current dataset", list)
+	}
+}
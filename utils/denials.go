@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"log"
+	"slices"
+
+	"test-tls/authz"
+)
+
+// DenialsFromEnv reports whether BENCH_DENIALS=1 enforces the banned_user
+// denials of data/resource_bans.csv (see authz.BannedUser): create-schema
+// adds the exclusion to the SpiceDB schema and load-data the banned_user
+// relationships, and the SQL checkers anti-join resource_bans.
+func DenialsFromEnv() bool {
+	return GetEnvInt("BENCH_DENIALS", 0) != 0
+}
+
+// RunDenials runs the denials experiment: check and lookup latency of View
+// with denials against ViewGranted, the same grants without them, so the cost
+// of the exclusion shows on its own. It is skipped unless DenialsFromEnv.
+//
+// The check pairs alternate BENCH_DENIALS_PAIRS bans of
+// data/resource_bans.csv with as many direct user grants of
+// data/resource_acl.csv (see workloadPairs), so View denies about half of
+// what ViewGranted allows; the rows of a check are the allowed ones. The
+// lookup users are the users banned most often, and the rows of a lookup the
+// resources of the last one. Per variant and query it logs a "[denials]
+// variant=view_granted|view query=check|lookup DONE:" line, which parse_all
+// compares against view_granted.
+//
+// Env vars:
+//
+//	BENCH_DENIALS_CHECK_ITER   (default: 1000)
+//	BENCH_DENIALS_LOOKUP_ITER  (default: 10)
+//	BENCH_DENIALS_PAIRS        (default: 500)
+//	BENCH_DENIALS_USERS        (default: 5)
+func RunDenials(engine string, backend PermissionBackend) {
+	const scenario = "denials"
+	if !DenialsFromEnv() {
+		return
+	}
+	n := GetEnvInt("BENCH_DENIALS_PAIRS", 500)
+	banned, users := bannedPairs(n, GetEnvInt("BENCH_DENIALS_USERS", 5))
+	if len(banned) == 0 {
+		log.Printf("[%s] [%s] skipped: no bans in data/resource_bans.csv (generate with RLP_BANNED_RESOURCE_FRACTION)", engine, scenario)
+		return
+	}
+	granted := workloadPairs(authz.View, n)
+	var pairs [][2]string
+	for i := range max(len(banned), len(granted)) {
+		if i < len(banned) {
+			pairs = append(pairs, banned[i])
+		}
+		if i < len(granted) {
+			pairs = append(pairs, granted[i])
+		}
+	}
+	log.Printf("[%s] [%s] pairs=%d banned=%d users=%d", engine, scenario, len(pairs), len(banned), len(users))
+	runPermissionQueries(engine, scenario, backend, []string{authz.ViewGranted, authz.View}, pairs, users,
+		GetEnvInt("BENCH_DENIALS_CHECK_ITER", 1000), GetEnvInt("BENCH_DENIALS_LOOKUP_ITER", 10))
+}
+
+// bannedPairs returns up to n (resource, user) pairs of
+// data/resource_bans.csv, in file order, and the k users with the most bans
+// (in file order on ties).
+func bannedPairs(n, k int) ([][2]string, []string) {
+	var pairs [][2]string
+	var users []string
+	bans := map[string]int{}
+	eachDataRow("resource_bans.csv", func(rec []string) {
+		// resource_id,user_id
+		if len(pairs) < n {
+			pairs = append(pairs, [2]string{rec[0], rec[1]})
+		}
+		if bans[rec[1]] == 0 {
+			users = append(users, rec[1])
+		}
+		bans[rec[1]]++
+	})
+	slices.SortStableFunc(users, func(a, b string) int { return bans[b] - bans[a] })
+	return pairs, users[:min(k, len(users))]
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"test-tls/authz"
+)
+
+// PermissionRulesFromEnv returns the rules of BENCH_PERMISSION_RULES, the
+// permissions derived beyond manage and view (see authz.ParseRules): ""
+// (default) for none, "standard" for authz.StandardRules (edit, delete and
+// share), or rules of the form permission=term+term,... The SpiceDB schema,
+// the SQL checkers and RunDerivedPermissions all read them from here.
+func PermissionRulesFromEnv() []authz.Rule {
+	spec := strings.TrimSpace(GetEnvWithDefault("BENCH_PERMISSION_RULES", ""))
+	if spec == "standard" {
+		spec = authz.StandardRules
+	}
+	rules, err := authz.ParseRules(spec)
+	if err != nil {
+		log.Fatalf("BENCH_PERMISSION_RULES: %v", err)
+	}
+	return rules
+}
+
+// RunDerivedPermissions runs the permissions experiment: check and lookup
+// latency of manage, view and every permission of BENCH_PERMISSION_RULES, so
+// the cost of a model with more permissions shows next to that of the two
+// base ones. It is skipped when no rules are set.
+//
+// The check pairs are BENCH_PERMISSIONS_PAIRS direct user grants of
+// data/resource_acl.csv (see workloadPairs), the same for every permission,
+// so each is allowed on some and denied on others; the rows of a check are
+// the allowed ones. The lookup users are the view user mix (LookupUserMix),
+// light through heavy, and the rows of a lookup the resources of the last
+// one. Per permission and query it logs a "[permissions] variant=<permission>
+// query=check|lookup DONE:" line, which parse_all compares against manage.
+//
+// Env vars:
+//
+//	BENCH_PERMISSIONS_CHECK_ITER   (default: 1000)
+//	BENCH_PERMISSIONS_LOOKUP_ITER  (default: 10)
+//	BENCH_PERMISSIONS_PAIRS        (default: 1000)
+//	BENCH_PERMISSIONS_USERS        (default: 5)
+func RunDerivedPermissions(engine string, backend PermissionBackend) {
+	const scenario = "permissions"
+	rules := PermissionRulesFromEnv()
+	if len(rules) == 0 {
+		return
+	}
+	permissions := []string{authz.Manage, authz.View}
+	for _, r := range rules {
+		permissions = append(permissions, r.Permission)
+	}
+	checkIters := GetEnvInt("BENCH_PERMISSIONS_CHECK_ITER", 1000)
+	lookupIters := GetEnvInt("BENCH_PERMISSIONS_LOOKUP_ITER", 10)
+	pairs := workloadPairs(authz.View, GetEnvInt("BENCH_PERMISSIONS_PAIRS", 1000))
+	var users []string
+	for _, u := range LookupUserMix(authz.View, GetEnvInt("BENCH_PERMISSIONS_USERS", 5)) {
+		users = append(users, u.ID)
+	}
+	log.Printf("[%s] [%s] permissions=%s rules=%q pairs=%d users=%d", engine, scenario, strings.Join(permissions, ","), GetEnvWithDefault("BENCH_PERMISSION_RULES", ""), len(pairs), len(users))
+	runPermissionQueries(engine, scenario, backend, permissions, pairs, users, checkIters, lookupIters)
+}
+
+// runPermissionQueries checks every permission on pairs checkIters times and
+// looks it up for users lookupIters times, logging a "[<scenario>]
+// variant=<permission> query=check|lookup DONE:" line per permission and
+// query. The rows are the allowed checks and the resources of the last
+// lookup.
+func runPermissionQueries(engine, scenario string, backend PermissionBackend, permissions []string, pairs [][2]string, users []string, checkIters, lookupIters int) {
+	for _, permission := range permissions {
+		if len(pairs) > 0 && checkIters > 0 {
+			runPermissionQuery(engine, scenario, permission, "check", checkIters, 2*time.Second, true, func(ctx context.Context, i int) (int, error) {
+				p := pairs[i%len(pairs)]
+				start := time.Now()
+				ok, err := backend.Check(ctx, p[0], p[1], permission)
+				AuditCheck(scenario, p[0], p[1], permission, ok, time.Since(start), err)
+				if ok {
+					return 1, err
+				}
+				return 0, err
+			})
+		}
+		if len(users) > 0 && lookupIters > 0 {
+			runPermissionQuery(engine, scenario, permission, "lookup", lookupIters, 60*time.Second, false, func(ctx context.Context, i int) (int, error) {
+				user := users[i%len(users)]
+				count := 0
+				start := time.Now()
+				err := backend.LookupResources(ctx, user, permission, func(string) { count++ })
+				AuditLookup(scenario, user, permission, count, time.Since(start), err)
+				return count, err
+			})
+		}
+	}
+}
+
+// runPermissionQuery times run iters times and logs the DONE line of
+// permission and query. The rows are the sum over the iterations for checks
+// and those of the last successful iteration for lookups.
+func runPermissionQuery(engine, scenario, permission, query string, iters int, timeout time.Duration, check bool, run func(ctx context.Context, i int) (int, error)) {
+	durations := make([]time.Duration, 0, iters)
+	rows := 0
+	errs := NewErrorTally()
+	for i := range iters {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		start := time.Now()
+		n, err := run(ctx, i)
+		dur := time.Since(start)
+		cancel()
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[%s] [%s] variant=%s query=%s iter=%d failed class=%s: %v", engine, scenario, permission, query, i, class, err)
+			continue
+		}
+		durations = append(durations, dur)
+		if check {
+			rows += n
+		} else {
+			rows = n
+		}
+	}
+	log.Printf("[%s] [%s] variant=%s query=%s DONE: iters=%d rows=%d %s", engine, scenario, permission, query, len(durations), rows, LatencySummary(durations))
+}
@@ -0,0 +1,475 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// workerRegistration is the /register exchange between a worker and the
+// coordinator.
+type workerRegistration struct {
+	Backend string `json:"backend"`
+	Host    string `json:"host,omitempty"`
+	Worker  int    `json:"worker"`
+	Workers int    `json:"workers"`
+}
+
+// workerResult is one replayed call as a worker reports it to /results.
+type workerResult struct {
+	Scenario   string `json:"scenario"`
+	Kind       string `json:"kind"`
+	DurUS      int64  `json:"dur_us"`
+	ErrorClass string `json:"error_class,omitempty"`
+	Mismatch   bool   `json:"mismatch,omitempty"`
+}
+
+// RunCoordinator splits the calls of an audit file (see StartAudit) into one
+// shard per worker and aggregates the results the workers stream back, so
+// several client hosts can drive one clustered backend harder than a single
+// benchmark process can. Workers ("<module> worker" with BENCH_COORDINATOR)
+// register first; every worker gets its shard once COORDINATOR_WORKERS have
+// registered, so all shards start together. When every worker has finished
+// it logs replay-style DONE and ERRORS lines per scenario, the per-worker
+// totals and the combined throughput, then exits.
+//
+// Endpoints:
+//
+//	POST /register           {"backend":"postgres","host":"..."} -> {"worker":0,"workers":4,...}
+//	GET  /shard?worker=0     NDJSON audit records of the shard, after all workers registered
+//	POST /results?worker=0   NDJSON results; &done=true with the last batch
+//
+// Env vars:
+//
+//	REPLAY_AUDIT_FILE    (required) workload, an audit file written by a benchmark
+//	COORDINATOR_ADDR     (default: ":7070")
+//	COORDINATOR_WORKERS  (default: 2) workers to wait for
+func RunCoordinator() {
+	path := os.Getenv("REPLAY_AUDIT_FILE")
+	if path == "" {
+		log.Fatalf("[coordinator] REPLAY_AUDIT_FILE is required")
+	}
+	records, err := readAuditRecords(path)
+	if err != nil {
+		log.Fatalf("[coordinator] %s: %v", path, err)
+	}
+	n := GetEnvInt("COORDINATOR_WORKERS", 2)
+	if n < 1 {
+		log.Fatalf("[coordinator] COORDINATOR_WORKERS must be at least 1, got %d", n)
+	}
+
+	c := &coordinator{
+		workers:  n,
+		shards:   make([][]AuditRecord, n),
+		hosts:    make([]string, n),
+		done:     make([]bool, n),
+		calls:    make([]int, n),
+		ready:    make(chan struct{}),
+		finished: make(chan struct{}),
+		stats:    make(map[string]*replayStats),
+	}
+	for i, rec := range records {
+		c.shards[i%n] = append(c.shards[i%n], rec)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/register", c.handleRegister)
+	mux.HandleFunc("/shard", c.handleShard)
+	mux.HandleFunc("/results", c.handleResults)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	addr := GetEnvWithDefault("COORDINATOR_ADDR", ":7070")
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c.finished:
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("[coordinator] == Sharding %s: records=%d workers=%d, listening on %s ==", path, len(records), n, addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("[coordinator] listen failed: %v", err)
+	}
+	c.report()
+}
+
+type coordinator struct {
+	workers  int
+	shards   [][]AuditRecord
+	ready    chan struct{} // closed once every worker registered
+	finished chan struct{} // closed once every worker sent done
+
+	mu         sync.Mutex
+	backend    string
+	hosts      []string
+	registered int
+	done       []bool
+	finishedN  int
+	calls      []int
+	start      time.Time
+	order      []string
+	stats      map[string]*replayStats
+}
+
+func (c *coordinator) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	var reg workerRegistration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil || reg.Backend == "" {
+		http.Error(w, "want {\"backend\":...}", http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.registered == c.workers:
+		http.Error(w, fmt.Sprintf("all %d workers already registered", c.workers), http.StatusConflict)
+		return
+	case c.backend != "" && reg.Backend != c.backend:
+		http.Error(w, fmt.Sprintf("backend %s, the run is against %s", reg.Backend, c.backend), http.StatusConflict)
+		return
+	}
+	c.backend = reg.Backend
+	reg.Worker, reg.Workers = c.registered, c.workers
+	c.hosts[reg.Worker] = reg.Host
+	c.registered++
+	log.Printf("[coordinator] worker %d/%d registered: backend=%s host=%s shard=%d", c.registered, c.workers, reg.Backend, reg.Host, len(c.shards[reg.Worker]))
+	if c.registered == c.workers {
+		c.start = time.Now()
+		close(c.ready)
+		log.Printf("[coordinator] all workers registered, starting")
+	}
+	_ = json.NewEncoder(w).Encode(reg)
+}
+
+func (c *coordinator) handleShard(w http.ResponseWriter, r *http.Request) {
+	worker, ok := c.workerParam(w, r)
+	if !ok {
+		return
+	}
+	select {
+	case <-c.ready:
+	case <-r.Context().Done():
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, rec := range c.shards[worker] {
+		if err := enc.Encode(rec); err != nil {
+			log.Printf("[coordinator] worker %d: send shard: %v", worker, err)
+			return
+		}
+	}
+	_ = bw.Flush()
+}
+
+func (c *coordinator) handleResults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	worker, ok := c.workerParam(w, r)
+	if !ok {
+		return
+	}
+
+	var batch []workerResult
+	dec := json.NewDecoder(r.Body)
+	for {
+		var res workerResult
+		if err := dec.Decode(&res); err == io.EOF {
+			break
+		} else if err != nil {
+			http.Error(w, "bad result: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		batch = append(batch, res)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done[worker] {
+		http.Error(w, fmt.Sprintf("worker %d already done", worker), http.StatusConflict)
+		return
+	}
+	for _, res := range batch {
+		st := c.stats[res.Scenario]
+		if st == nil {
+			st = &replayStats{errs: NewErrorTally()}
+			c.stats[res.Scenario] = st
+			c.order = append(c.order, res.Scenario)
+		}
+		st.records++
+		if res.ErrorClass != "" {
+			st.errs.RecordClass(res.ErrorClass)
+			continue
+		}
+		st.durations = append(st.durations, time.Duration(res.DurUS)*time.Microsecond)
+		if res.Mismatch {
+			st.mismatches++
+		}
+	}
+	c.calls[worker] += len(batch)
+	log.Printf("[coordinator] worker %d: +%d results (%d/%d)", worker, len(batch), c.calls[worker], len(c.shards[worker]))
+
+	if r.URL.Query().Get("done") == "true" {
+		c.done[worker] = true
+		c.finishedN++
+		log.Printf("[coordinator] worker %d done (%d/%d)", worker, c.finishedN, c.workers)
+		if c.finishedN == c.workers {
+			close(c.finished)
+		}
+	}
+}
+
+// workerParam parses ?worker= and answers 400 unless it names a registered
+// worker.
+func (c *coordinator) workerParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	worker, err := strconv.Atoi(r.URL.Query().Get("worker"))
+	c.mu.Lock()
+	registered := c.registered
+	c.mu.Unlock()
+	if err != nil || worker < 0 || worker >= registered {
+		http.Error(w, "unknown worker", http.StatusBadRequest)
+		return 0, false
+	}
+	return worker, true
+}
+
+// report logs the aggregated results, per scenario and per worker.
+func (c *coordinator) report() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.start.IsZero() {
+		log.Printf("[coordinator] stopped before all workers registered (%d/%d)", c.registered, c.workers)
+		return
+	}
+	elapsed := time.Since(c.start)
+	total := 0
+	for _, scenario := range c.order {
+		st := c.stats[scenario]
+		total += st.records
+		log.Printf("[%s] [distributed] [%s] DONE: records=%d mismatches=%d %s", c.backend, scenario, st.records, st.mismatches, latencyStatsOf(st.durations))
+		log.Printf("[%s] [distributed] [%s] ERRORS: %s", c.backend, scenario, st.errs.Summary(st.records))
+	}
+	for i := range c.workers {
+		log.Printf("[%s] [distributed] worker=%d host=%s calls=%d/%d done=%t", c.backend, i, c.hosts[i], c.calls[i], len(c.shards[i]), c.done[i])
+	}
+	log.Printf("[%s] [distributed] == DONE: workers=%d calls=%d elapsed=%s throughput=%.1f/s ==",
+		c.backend, c.workers, total, elapsed.Truncate(time.Millisecond), float64(total)/elapsed.Seconds())
+}
+
+// RunWorker registers with the coordinator at BENCH_COORDINATOR, replays the
+// shard it is given against backend with WORKER_CONCURRENCY parallel callers
+// and streams the results back in batches of WORKER_BATCH (see
+// RunCoordinator). Answers are compared with the recording as in
+// ReplayAudit, with its REPLAY_*_TIMEOUT_SEC timeouts.
+//
+// Env vars:
+//
+//	BENCH_COORDINATOR   (required) coordinator URL, e.g. http://10.0.0.5:7070
+//	WORKER_CONCURRENCY  (default: 8)
+//	WORKER_BATCH        (default: 1000) results per /results request
+func RunWorker(engine string, backend PermissionBackend) {
+	base := os.Getenv("BENCH_COORDINATOR")
+	if base == "" {
+		log.Fatalf("[%s] [worker] BENCH_COORDINATOR is required", engine)
+	}
+	concurrency := max(GetEnvInt("WORKER_CONCURRENCY", 8), 1)
+	batchSize := max(GetEnvInt("WORKER_BATCH", 1000), 1)
+	checkTimeout := time.Duration(GetEnvInt("REPLAY_CHECK_TIMEOUT_SEC", 2)) * time.Second
+	lookupTimeout := time.Duration(GetEnvInt("REPLAY_LOOKUP_TIMEOUT_SEC", 60)) * time.Second
+
+	host, _ := os.Hostname()
+	body, _ := json.Marshal(workerRegistration{Backend: engine, Host: host})
+	resp, err := http.Post(base+"/register", "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Fatalf("[%s] [worker] register with %s: %v", engine, base, err)
+	}
+	var reg workerRegistration
+	err = decodeCoordinatorResponse(resp, &reg)
+	if err != nil {
+		log.Fatalf("[%s] [worker] register with %s: %v", engine, base, err)
+	}
+	log.Printf("[%s] [worker] registered as worker %d/%d, waiting for the others", engine, reg.Worker+1, reg.Workers)
+
+	// The coordinator answers /shard once every worker registered.
+	resp, err = http.Get(fmt.Sprintf("%s/shard?worker=%d", base, reg.Worker))
+	if err != nil {
+		log.Fatalf("[%s] [worker] fetch shard: %v", engine, err)
+	}
+	var shard []AuditRecord
+	err = decodeCoordinatorResponse(resp, &shard)
+	if err != nil {
+		log.Fatalf("[%s] [worker] fetch shard: %v", engine, err)
+	}
+	log.Printf("[%s] [worker] == Replaying shard of %d calls (concurrency=%d) ==", engine, len(shard), concurrency)
+
+	results := make(chan workerResult, batchSize)
+	jobs := make(chan AuditRecord)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rec := range jobs {
+				dur, mismatch, err := replayCall(backend, rec, checkTimeout, lookupTimeout)
+				results <- workerResult{
+					Scenario:   rec.Scenario,
+					Kind:       recordKind(rec),
+					DurUS:      dur.Microseconds(),
+					ErrorClass: ClassifyError(err),
+					Mismatch:   mismatch,
+				}
+			}
+		}()
+	}
+	go func() {
+		for _, rec := range shard {
+			jobs <- rec
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	start := time.Now()
+	errs := NewErrorTally()
+	var batch []workerResult
+	sent := 0
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for open := true; open; {
+		select {
+		case res, ok := <-results:
+			if !ok {
+				open = false
+				break
+			}
+			if res.ErrorClass != "" {
+				errs.RecordClass(res.ErrorClass)
+			}
+			batch = append(batch, res)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if open {
+			sendResults(engine, base, reg.Worker, batch, false)
+			sent += len(batch)
+			batch = batch[:0]
+		}
+	}
+	sendResults(engine, base, reg.Worker, batch, true)
+	sent += len(batch)
+
+	elapsed := time.Since(start)
+	log.Printf("[%s] [worker] DONE: worker=%d calls=%d elapsed=%s throughput=%.1f/s", engine, reg.Worker, sent, elapsed.Truncate(time.Millisecond), float64(sent)/elapsed.Seconds())
+	log.Printf("[%s] [worker] ERRORS: %s", engine, errs.Summary(sent))
+}
+
+// sendResults posts batch to the coordinator as NDJSON, marking the worker
+// done with the last one.
+func sendResults(engine, base string, worker int, batch []workerResult, done bool) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, res := range batch {
+		_ = enc.Encode(res)
+	}
+	url := fmt.Sprintf("%s/results?worker=%d", base, worker)
+	if done {
+		url += "&done=true"
+	}
+	resp, err := http.Post(url, "application/x-ndjson", &buf)
+	if err == nil {
+		err = decodeCoordinatorResponse(resp, nil)
+	}
+	if err != nil {
+		log.Fatalf("[%s] [worker] send %d results: %v", engine, len(batch), err)
+	}
+}
+
+// decodeCoordinatorResponse closes resp after decoding its JSON or NDJSON
+// body into v (a pointer to a slice collects every NDJSON value), or turns a
+// non-200 status into an error.
+func decodeCoordinatorResponse(resp *http.Response, v any) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case *[]AuditRecord:
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var rec AuditRecord
+			if err := dec.Decode(&rec); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			*v = append(*v, rec)
+		}
+	default:
+		return json.NewDecoder(resp.Body).Decode(v)
+	}
+}
+
+// readAuditRecords reads every record of a gzipped NDJSON audit file.
+func readAuditRecords(path string) ([]AuditRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+	defer zr.Close()
+
+	var records []AuditRecord
+	dec := json.NewDecoder(zr)
+	for {
+		var rec AuditRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("read failed: %w", err)
+		}
+		records = append(records, rec)
+	}
+}
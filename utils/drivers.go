@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DriverCall is one benchmark query issued through a particular client driver.
+type DriverCall func(ctx context.Context) error
+
+// DriverBench is the set of queries, keyed by name, issued through one driver.
+type DriverBench struct {
+	Driver  string
+	Queries map[string]DriverCall
+}
+
+// CompareDrivers times the same queries through several client drivers of one
+// backend (e.g. database/sql vs pgx), so driver overhead can be told apart
+// from engine latency. For every query the drivers are called round robin,
+// iters times each, so caching and server drift hit them alike. Each driver
+// gets a DONE line with its latency summary; drivers after the first also
+// report their overhead against it (negative = faster).
+//
+// Env vars:
+//
+//	BENCH_DRIVER_COMPARE_ITER         (default: 1000)
+//	BENCH_DRIVER_COMPARE_TIMEOUT_MS   (default: 2000)
+func CompareDrivers(engine string, queries []string, benches []DriverBench) {
+	iters := GetEnvInt("BENCH_DRIVER_COMPARE_ITER", 1000)
+	timeout := time.Duration(GetEnvInt("BENCH_DRIVER_COMPARE_TIMEOUT_MS", 2000)) * time.Millisecond
+
+	log.Printf("[%s] [driver_overhead] iterations=%d drivers=%d queries=%v", engine, iters, len(benches), queries)
+	LogScenarioConfig(engine, "driver_overhead", iters, timeout)
+	for _, query := range queries {
+		durations := make([][]time.Duration, len(benches))
+		errs := make([]*ErrorTally, len(benches))
+		for d := range benches {
+			errs[d] = NewErrorTally()
+		}
+
+		for i := 0; i < iters; i++ {
+			for d, b := range benches {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				start := time.Now()
+				err := b.Queries[query](ctx)
+				dur := time.Since(start)
+				cancel()
+				if err != nil {
+					class := errs[d].Record(err)
+					log.Printf("[%s] [driver_overhead] query=%s driver=%s iter=%d failed class=%s: %v", engine, query, b.Driver, i, class, err)
+					continue
+				}
+				durations[d] = append(durations[d], dur)
+			}
+		}
+
+		base := latencyStatsOf(durations[0])
+		for d, b := range benches {
+			st := latencyStatsOf(durations[d])
+			overhead := ""
+			if d > 0 {
+				overhead = fmt.Sprintf(" overhead_avg=%s overhead_p50=%s overhead_p99=%s (vs %s)",
+					st.avg-base.avg, st.p50-base.p50, st.p99-base.p99, benches[0].Driver)
+			}
+			log.Printf("[%s] [driver_overhead] query=%s driver=%s DONE: iters=%d %s%s", engine, query, b.Driver, len(durations[d]), st, overhead)
+			log.Printf("[%s] [driver_overhead] query=%s driver=%s ERRORS: %s", engine, query, b.Driver, errs[d].Summary(iters))
+		}
+	}
+}
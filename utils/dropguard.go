@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DropCount is how many rows (documents, relationships) one table held right
+// before a drop. AtLeast marks a count that stopped at DropCountCap because an
+// exact count would be a full scan (ScyllaDB, SpiceDB); Missing marks a table
+// that could not be counted, usually because it does not exist.
+type DropCount struct {
+	Table   string `json:"table"`
+	Rows    int64  `json:"rows"`
+	AtLeast bool   `json:"at_least,omitempty"`
+	Missing bool   `json:"missing,omitempty"`
+}
+
+func (c DropCount) String() string {
+	switch {
+	case c.Missing:
+		return c.Table + ": missing"
+	case c.AtLeast:
+		return fmt.Sprintf("%s: >= %d rows", c.Table, c.Rows)
+	default:
+		return fmt.Sprintf("%s: %d rows", c.Table, c.Rows)
+	}
+}
+
+// dropForceThreshold is DROP_FORCE_THRESHOLD: a drop of more rows than this
+// needs --force (DROP_FORCE=true).
+func dropForceThreshold() int64 {
+	return int64(GetEnvInt("DROP_FORCE_THRESHOLD", 100000))
+}
+
+// DropCountCap is where capped counts stop: one past DROP_FORCE_THRESHOLD,
+// which is enough to tell whether the drop needs --force.
+func DropCountCap() int64 {
+	return dropForceThreshold() + 1
+}
+
+// CountSQLTables counts the rows of tables with COUNT(*); a table that fails
+// to count is reported Missing.
+func CountSQLTables(ctx context.Context, db *sql.DB, tables []string) []DropCount {
+	counts := make([]DropCount, 0, len(tables))
+	for _, table := range tables {
+		qctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+		var n int64
+		err := db.QueryRowContext(qctx, `SELECT COUNT(*) FROM `+table).Scan(&n)
+		cancel()
+		counts = append(counts, DropCount{Table: table, Rows: n, Missing: err != nil})
+	}
+	return counts
+}
+
+// dropSnapshot is the sidecar file GuardDrop writes.
+type dropSnapshot struct {
+	Engine string      `json:"engine"`
+	At     time.Time   `json:"at"`
+	Forced bool        `json:"forced"`
+	Total  int64       `json:"total_rows"`
+	Tables []DropCount `json:"tables"`
+}
+
+// GuardDrop is called by every drop before it destroys anything. It logs the
+// row counts, records them in DROP_SNAPSHOT_DIR (default drop-snapshots/) as
+// <engine>-<time>.json, and refuses to continue when more than
+// DROP_FORCE_THRESHOLD (default 100000) rows would be dropped, unless
+// DROP_FORCE=true (--force on the command line). A refused drop still leaves
+// its snapshot behind.
+func GuardDrop(engine string, counts []DropCount) {
+	var total int64
+	for _, c := range counts {
+		total += c.Rows
+		log.Printf("[%s] before drop: %s", engine, c)
+	}
+	forced := GetEnvWithDefault("DROP_FORCE", "false") == "true"
+
+	snap := dropSnapshot{Engine: engine, At: time.Now().UTC(), Forced: forced, Total: total, Tables: counts}
+	dir := GetEnvWithDefault("DROP_SNAPSHOT_DIR", "drop-snapshots")
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.json", engine, snap.At.Format("20060102T150405Z")))
+	b, _ := json.MarshalIndent(snap, "", "  ")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Fatalf("[%s] drop snapshot: %v", engine, err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		log.Fatalf("[%s] drop snapshot: %v", engine, err)
+	}
+	log.Printf("[%s] before drop: %d rows total, snapshot written to %s", engine, total, path)
+
+	if threshold := dropForceThreshold(); total > threshold && !forced {
+		log.Fatalf("[%s] refusing to drop %d rows (> DROP_FORCE_THRESHOLD=%d); rerun with --force", engine, total, threshold)
+	}
+}
+
+// OwnedObjects keeps a drop to what this tool creates, so it is safe to run
+// against a cluster other applications share. found is every table, view,
+// collection, index or definition the backend lists in its namespace, owned
+// the names create-schema, load-data and the experiments create, as
+// path.Match patterns ("resource_acl_p*"). It returns the found objects that
+// match, the ones to drop, and logs the others as left in place.
+//
+// With DROP_REQUIRE_NAMESPACE=true it refuses any drop outside an
+// RLP_NAMESPACE, so on a shared cluster the tool only ever drops from a
+// schema, database, keyspace or index prefix of its own.
+func OwnedObjects(engine string, found, owned []string) map[string]bool {
+	if GetEnvWithDefault("DROP_REQUIRE_NAMESPACE", "false") == "true" && Namespace() == "" {
+		log.Fatalf("[%s] refusing to drop outside a namespace (DROP_REQUIRE_NAMESPACE=true); set RLP_NAMESPACE", engine)
+	}
+	own := map[string]bool{}
+	for _, name := range found {
+		if slices.ContainsFunc(owned, func(pattern string) bool {
+			ok, _ := path.Match(pattern, name)
+			return ok
+		}) {
+			own[name] = true
+			continue
+		}
+		log.Printf("[%s] drop: leaving %s in place, not created by this tool", engine, name)
+	}
+	return own
+}
+
+// OwnedRecorded is OwnedObjects for a backend that records every object it
+// creates in its rlp_objects table. A name in owned alone does not make an
+// object the tool's: a users table another application created before
+// create-schema ran (which CREATE TABLE IF NOT EXISTS then left alone) shares
+// the name but was never recorded, and is left in place. Objects named with
+// the tool's rlp_ prefix (rlp_objects, rlp_dataset, rlp_schema_migrations)
+// are its own by name.
+func OwnedRecorded(engine string, found []string, recorded map[string]bool, owned []string) map[string]bool {
+	own := OwnedObjects(engine, found, owned)
+	for name := range own {
+		if !recorded[name] && !strings.HasPrefix(name, "rlp_") {
+			delete(own, name)
+			log.Printf("[%s] drop: leaving %s in place, not recorded as created by this tool", engine, name)
+		}
+	}
+	return own
+}
+
+// NewObjects returns the names of after that are not in before: the objects
+// a create step made rather than found in place, which the backend records
+// for OwnedRecorded.
+func NewObjects(before, after []string) []string {
+	var created []string
+	for _, name := range after {
+		if !slices.Contains(before, name) && !slices.Contains(created, name) {
+			created = append(created, name)
+		}
+	}
+	return created
+}
+
+// OwnedNames returns the names of names that own holds, in order: the tables
+// a drop counts for GuardDrop, which are the ones it is about to drop.
+func OwnedNames(own map[string]bool, names []string) []string {
+	var kept []string
+	for _, name := range names {
+		if own[name] {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// Querier is a *sql.DB or *sql.Tx.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// ListNames returns the first column of every row of query, the object
+// listing a SQL backend hands OwnedObjects.
+func ListNames(ctx context.Context, db Querier, query string, args ...any) ([]string, error) {
+	qctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	rows, err := db.QueryContext(qctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
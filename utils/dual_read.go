@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// RunDualReadProbe sends the same view check to two backends at once, each
+// behind "<module> serve", for PROBE_DURATION_SEC, to measure the consistency
+// window of a deployment that writes to both (a SQL source of truth and a
+// SpiceDB index, say) while changes are applied to them: run group-churn,
+// the write scenarios or the application's own writes against both stores
+// meanwhile. The pairs are those of check_view_worst_case, granted only
+// through nested groups, so hierarchy changes move their answers.
+//
+// Every PROBE_INTERVAL_MS it checks the next pair, round robin, plus every
+// pair the backends still disagree on, until they agree again. Every
+// PROBE_WINDOW_SEC it logs a WINDOW line with the checks and disagreements
+// of the window, so the disagreement rate can be followed over the churn. At
+// the end it logs DONE, ERRORS and a CONVERGENCE line with the latency of
+// the disagreements that resolved, from the first check that saw one to the
+// first that no longer did: the consistency window. A check that fails on
+// either side counts as an error, not a disagreement.
+//
+// Env vars:
+//
+//	PROBE_DURATION_SEC       (default: 300)
+//	PROBE_INTERVAL_MS        (default: 100)
+//	PROBE_WINDOW_SEC         (default: 10)
+//	PROBE_PAIRS              (default: 1000)
+//	PROBE_CHECK_TIMEOUT_SEC  (default: 2)
+func RunDualReadProbe(urlA, urlB string) {
+	const engine, scenario = "probe", "dual_read"
+	duration := time.Duration(GetEnvInt("PROBE_DURATION_SEC", 300)) * time.Second
+	interval := time.Duration(GetEnvInt("PROBE_INTERVAL_MS", 100)) * time.Millisecond
+	window := time.Duration(GetEnvInt("PROBE_WINDOW_SEC", 10)) * time.Second
+	pairs := worstCasePairs(GetEnvInt("PROBE_PAIRS", 1000))
+	if len(pairs) == 0 {
+		log.Fatalf("[%s] [%s] no group-only viewer grants in %s to probe", engine, scenario, DataDir())
+	}
+	client := &http.Client{Timeout: time.Duration(GetEnvInt("PROBE_CHECK_TIMEOUT_SEC", 2)) * time.Second}
+	a, b := serveChecker{client, urlA}, serveChecker{client, urlB}
+	log.Printf("[%s] [%s] a=%s b=%s pairs=%d duration=%s interval=%s window=%s", engine, scenario, urlA, urlB, len(pairs), duration, interval, window)
+
+	errs := NewErrorTally()
+	open := map[int]time.Time{} // pair -> first check that disagreed
+	var converged []time.Duration
+	checks, disagree := 0, 0
+	winChecks, winDisagree := 0, 0
+
+	probe := func(i int) {
+		p := pairs[i]
+		var allowedA, allowedB bool
+		var errA, errB error
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() { defer wg.Done(); allowedA, errA = a.check(p.resource, p.user, "view") }()
+		go func() { defer wg.Done(); allowedB, errB = b.check(p.resource, p.user, "view") }()
+		wg.Wait()
+		checks++
+		winChecks++
+		failed := false
+		for _, err := range []error{errA, errB} {
+			if err != nil {
+				failed = true
+				class := errs.Record(err)
+				log.Printf("[%s] [%s] resource=%s user=%s check failed class=%s: %v", engine, scenario, p.resource, p.user, class, err)
+			}
+		}
+		if failed {
+			return
+		}
+		now := time.Now()
+		since, wasOpen := open[i]
+		switch {
+		case allowedA != allowedB:
+			disagree++
+			winDisagree++
+			if !wasOpen {
+				open[i] = now
+				log.Printf("[%s] [%s] resource=%s user=%s a=%t b=%t: disagree", engine, scenario, p.resource, p.user, allowedA, allowedB)
+			}
+		case wasOpen:
+			delete(open, i)
+			converged = append(converged, now.Sub(since))
+			log.Printf("[%s] [%s] resource=%s user=%s allowed=%t: agree after %s", engine, scenario, p.resource, p.user, allowedA, now.Sub(since).Truncate(time.Millisecond))
+		}
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	nextWindow := start.Add(window)
+	next := 0
+	for now := range ticker.C {
+		if now.Sub(start) >= duration {
+			break
+		}
+		for i := range open {
+			probe(i)
+		}
+		if _, ok := open[next]; !ok {
+			probe(next)
+		}
+		next = (next + 1) % len(pairs)
+		if now.After(nextWindow) {
+			log.Printf("[%s] [%s] WINDOW: t=%s checks=%d disagree=%d rate=%.4f open=%d",
+				engine, scenario, now.Sub(start).Truncate(time.Second), winChecks, winDisagree, disagreeRate(winDisagree, winChecks), len(open))
+			winChecks, winDisagree = 0, 0
+			nextWindow = nextWindow.Add(window)
+		}
+	}
+
+	log.Printf("[%s] [%s] DONE: checks=%d disagree=%d rate=%.4f converged=%d unresolved=%d", engine, scenario, checks, disagree, disagreeRate(disagree, checks), len(converged), len(open))
+	log.Printf("[%s] [%s] ERRORS: %s", engine, scenario, errs.Summary(2*checks))
+	log.Printf("[%s] [%s] CONVERGENCE: %s", engine, scenario, LatencySummary(converged))
+}
+
+// disagreeRate is n of checks, 0 without checks.
+func disagreeRate(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return float64(n) / float64(of)
+}
+
+// serveChecker checks permissions on the /v1/check endpoint of a
+// "<module> serve" at base.
+type serveChecker struct {
+	client *http.Client
+	base   string
+}
+
+func (s serveChecker) check(resourceID, userID, permission string) (bool, error) {
+	q := url.Values{"resource_id": {resourceID}, "user_id": {userID}, "permission": {permission}}
+	resp, err := s.client.Get(s.base + "/v1/check?" + q.Encode())
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Allowed bool   `json:"allowed"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return false, fmt.Errorf("%s: %s: %w", s.base, resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%s: %s: %s", s.base, resp.Status, body.Error)
+	}
+	return body.Allowed, nil
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Error classes used to bucket per-iteration benchmark failures. The same
+// names are printed in the ERRORS summary line and parsed by benchmark/parse_all.go.
+const (
+	ErrClassTimeout     = "timeout"
+	ErrClassUnavailable = "unavailable"
+	ErrClassShed        = "shed"
+	ErrClassDeadlock    = "deadlock"
+	ErrClassNotFound    = "not-found"
+	ErrClassOther       = "other"
+)
+
+// ErrClasses lists every class in the order they are reported.
+var ErrClasses = []string{
+	ErrClassTimeout,
+	ErrClassUnavailable,
+	ErrClassShed,
+	ErrClassDeadlock,
+	ErrClassNotFound,
+	ErrClassOther,
+}
+
+// ClassifyError maps a gRPC, SQL or driver error onto one of the ErrClass*
+// buckets. gRPC status codes and sentinel errors are checked first; driver
+// specific errors (pq, clickhouse, gocql, mongo) fall back to message matching
+// so this package does not have to import every driver.
+//
+// ErrClassShed is the server pushing back rather than failing: gRPC
+// RESOURCE_EXHAUSTED, Postgres/CockroachDB 53300 (too many connections),
+// ClickHouse TOO_MANY_SIMULTANEOUS_QUERIES, an overloaded ScyllaDB node,
+// an Elasticsearch 429 rejection and a Mongo write concern timeout.
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return ErrClassTimeout
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrClassNotFound
+	}
+
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.DeadlineExceeded:
+			return ErrClassTimeout
+		case codes.ResourceExhausted:
+			return ErrClassShed
+		case codes.Unavailable:
+			return ErrClassUnavailable
+		case codes.Aborted:
+			return ErrClassDeadlock
+		case codes.NotFound:
+			return ErrClassNotFound
+		}
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case containsAny(msg, "53300", "too many connections", "too many clients", "too_many_simultaneous_queries", "overloaded",
+		"es_rejected_execution_exception", "429 too many requests", "writeconcernfailed", "waiting for replication timed out", "wtimeout"):
+		return ErrClassShed
+	case containsAny(msg, "deadlock", "40p01", "40001", "restart transaction", "serialization failure", "write conflict"):
+		return ErrClassDeadlock
+	case containsAny(msg, "timeout", "timed out", "deadline exceeded"):
+		return ErrClassTimeout
+	case containsAny(msg, "connection refused", "connection reset", "broken pipe", "unavailable", "no hosts available", "no connections", "server selection error"):
+		return ErrClassUnavailable
+	case containsAny(msg, "not found", "no documents in result", "no rows in result set"):
+		return ErrClassNotFound
+	}
+	return ErrClassOther
+}
+
+func containsAny(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorTally counts per-iteration failures of one benchmark scenario by class,
+// so a scenario can keep running after a failed check/lookup and report its
+// error rate at the end instead of aborting the whole run.
+type ErrorTally struct {
+	total  int
+	counts map[string]int
+}
+
+// NewErrorTally returns an empty tally.
+func NewErrorTally() *ErrorTally {
+	return &ErrorTally{counts: make(map[string]int)}
+}
+
+// Record classifies err, counts it and returns its class. Shedding is also
+// noted on the scenario's shedding timeline (see NoteShedding).
+func (t *ErrorTally) Record(err error) string {
+	class := ClassifyError(err)
+	if class == "" {
+		return ""
+	}
+	t.total++
+	t.counts[class]++
+	if class == ErrClassShed {
+		NoteShedding()
+	}
+	return class
+}
+
+// RecordClass counts a failure already classified elsewhere, such as one
+// a distributed worker reported.
+func (t *ErrorTally) RecordClass(class string) {
+	t.total++
+	t.counts[class]++
+	if class == ErrClassShed {
+		NoteShedding()
+	}
+}
+
+// Total returns the number of recorded failures.
+func (t *ErrorTally) Total() int { return t.total }
+
+// Count returns the number of recorded failures for a class.
+func (t *ErrorTally) Count(class string) int { return t.counts[class] }
+
+// Summary renders the tally for the ERRORS log line, e.g.
+// "attempts=1000 errors=3 rate=0.0030 timeout=2 unavailable=1 shed=0 deadlock=0 not-found=0 other=0".
+func (t *ErrorTally) Summary(attempts int) string {
+	rate := 0.0
+	if attempts > 0 {
+		rate = float64(t.total) / float64(attempts)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "attempts=%d errors=%d rate=%.4f", attempts, t.total, rate)
+	for _, class := range ErrClasses {
+		fmt.Fprintf(&b, " %s=%d", class, t.counts[class])
+	}
+	return b.String()
+}
+
+// timeoutBudget is BENCH_TIMEOUT_BUDGET: the share of a scenario's iterations
+// that may time out, checks and sampling queries alike, before Survive gives
+// up (default 0.05, at least one timeout).
+func timeoutBudget(iters int) int {
+	frac := 0.05
+	if v := os.Getenv("BENCH_TIMEOUT_BUDGET"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+			frac = f
+		}
+	}
+	return max(1, int(frac*float64(iters)))
+}
+
+// Survive is called where a scenario cannot go on with err, typically a
+// failed sampling query or stream. A timeout is counted and logged, and the
+// caller carries on (usually by retrying) while the scenario's timeouts stay
+// within BENCH_TIMEOUT_BUDGET, so an engine that occasionally blows past its
+// deadline stays in the comparison. Any other error, or a timeout past the
+// budget, is fatal as before. format and args describe the failure and
+// should end with err.
+func (t *ErrorTally) Survive(err error, iters int, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if ClassifyError(err) != ErrClassTimeout {
+		log.Fatal(msg)
+	}
+	t.Record(err)
+	if budget := timeoutBudget(iters); t.counts[ErrClassTimeout] > budget {
+		log.Fatalf("%s (timeouts=%d over BENCH_TIMEOUT_BUDGET=%d)", msg, t.counts[ErrClassTimeout], budget)
+	}
+	log.Printf("%s (timeout %d of %d allowed, continuing)", msg, t.counts[ErrClassTimeout], timeoutBudget(iters))
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+)
+
+// FixtureExpectations are the known answers for the small dataset in
+// testdata/fixture, one table of cases per scenario of Scenarios: direct
+// grants, groups and nested groups, group managers as members and org admins.
+// Manage-implies-view is not asserted. The groups belong to an org without
+// resources, so SpiceDB, where group members are members of the group's org,
+// answers like the other backends.
+type FixtureExpectations struct {
+	Checks  []FixtureCheck  `json:"checks"`
+	Lookups []FixtureLookup `json:"lookups"`
+}
+
+// FixtureCheck is one expected Check answer of Scenario.
+type FixtureCheck struct {
+	Scenario   string `json:"scenario"`
+	Resource   string `json:"resource"`
+	User       string `json:"user"`
+	Permission string `json:"permission"`
+	Allowed    bool   `json:"allowed"`
+}
+
+// FixtureLookup is one expected LookupResources answer of Scenario; Resources
+// is sorted.
+type FixtureLookup struct {
+	Scenario   string   `json:"scenario"`
+	User       string   `json:"user"`
+	Permission string   `json:"permission"`
+	Resources  []string `json:"resources"`
+}
+
+// ReadFixtureExpectations reads the expectations at path and checks that
+// every case names a scenario of Scenarios and asks for its permission.
+func ReadFixtureExpectations(path string) (FixtureExpectations, error) {
+	var want FixtureExpectations
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return want, err
+	}
+	if err := json.Unmarshal(b, &want); err != nil {
+		return want, fmt.Errorf("parse %s: %w", path, err)
+	}
+	validate := func(scenario, permission string) error {
+		sem, err := scenarioSemantics(scenario)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if permission != sem.Permission {
+			return fmt.Errorf("%s: %s case asks for %q, the scenario is about %q", path, scenario, permission, sem.Permission)
+		}
+		return nil
+	}
+	for _, c := range want.Checks {
+		if err := validate(c.Scenario, c.Permission); err != nil {
+			return want, err
+		}
+	}
+	for _, l := range want.Lookups {
+		if err := validate(l.Scenario, l.Permission); err != nil {
+			return want, err
+		}
+	}
+	return want, nil
+}
+
+// Verify runs the check against backend and returns an error when it fails
+// or answers something else than Allowed.
+func (c FixtureCheck) Verify(ctx context.Context, backend PermissionBackend) error {
+	allowed, err := backend.Check(ctx, c.Resource, c.User, c.Permission)
+	switch {
+	case err != nil:
+		return fmt.Errorf("check resource=%s user=%s permission=%s: %w", c.Resource, c.User, c.Permission, err)
+	case allowed != c.Allowed:
+		return fmt.Errorf("check resource=%s user=%s permission=%s: allowed=%t, want %t", c.Resource, c.User, c.Permission, allowed, c.Allowed)
+	}
+	return nil
+}
+
+// Verify runs the lookup against backend and returns an error when it fails
+// or streams other resources than Resources.
+func (l FixtureLookup) Verify(ctx context.Context, backend PermissionBackend) error {
+	got := []string{}
+	err := backend.LookupResources(ctx, l.User, l.Permission, func(resourceID string) {
+		got = append(got, resourceID)
+	})
+	slices.Sort(got)
+	switch {
+	case err != nil:
+		return fmt.Errorf("lookup user=%s permission=%s: %w", l.User, l.Permission, err)
+	case !slices.Equal(got, l.Resources):
+		return fmt.Errorf("lookup user=%s permission=%s: resources=%v, want %v", l.User, l.Permission, got, l.Resources)
+	}
+	return nil
+}
+
+// VerifyFixture runs the checks and lookups of FIXTURE_EXPECT (default
+// testdata/fixture/expected.json) against backend and compares the answers
+// exactly. It is meant to run right after load-data with
+// RLP_DATA_DIR=testdata/fixture, to catch query regressions when a benchmark
+// query is reworked and semantic drift between backends. Every case names the
+// scenario whose semantics (see Scenarios) it pins down, and must ask for that
+// scenario's permission. Every mismatch and error is logged, followed by a
+// PASS or FAIL line per scenario; any failure fails the run.
+func VerifyFixture(engine string, backend PermissionBackend) {
+	path := GetEnvWithDefault("FIXTURE_EXPECT", "testdata/fixture/expected.json")
+	want, err := ReadFixtureExpectations(path)
+	if err != nil {
+		log.Fatalf("[%s] [fixture] %v", engine, err)
+	}
+
+	cases, failures := map[string]int{}, map[string]int{}
+	record := func(scenario string, err error) {
+		cases[scenario]++
+		if err != nil {
+			failures[scenario]++
+			log.Printf("[%s] [fixture] [%s] FAIL %v", engine, scenario, err)
+		}
+	}
+
+	for _, c := range want.Checks {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		record(c.Scenario, c.Verify(ctx, backend))
+		cancel()
+	}
+	for _, l := range want.Lookups {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		record(l.Scenario, l.Verify(ctx, backend))
+		cancel()
+	}
+
+	failed := 0
+	for _, sem := range Scenarios {
+		n := cases[sem.Scenario]
+		switch {
+		case n == 0:
+			log.Printf("[%s] [fixture] [%s] no cases", engine, sem.Scenario)
+		case failures[sem.Scenario] > 0:
+			failed += failures[sem.Scenario]
+			log.Printf("[%s] [fixture] [%s] FAIL: %d/%d cases (%s is allowed by %s)", engine, sem.Scenario, failures[sem.Scenario], n, sem.Permission, sem.Allows)
+		default:
+			log.Printf("[%s] [fixture] [%s] PASS: %d cases", engine, sem.Scenario, n)
+		}
+	}
+
+	total := len(want.Checks) + len(want.Lookups)
+	if failed > 0 {
+		log.Fatalf("[%s] [fixture] %d/%d cases failed against %s", engine, failed, total, path)
+	}
+	log.Printf("[%s] [fixture] OK: %d checks, %d lookups match %s", engine, len(want.Checks), len(want.Lookups), path)
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+
+	"test-tls/ids"
+)
+
+// accessFrequency is how often each resource is accessed in production, read
+// from BENCH_ACCESS_FREQUENCY_FILE: the weight of every resource listed, and
+// fallback for the others.
+type accessFrequency struct {
+	weights  map[int]float64
+	fallback float64
+}
+
+var (
+	frequencyOnce sync.Once
+	frequency     *accessFrequency
+)
+
+// accessFrequencyFromEnv returns the weights of BENCH_ACCESS_FREQUENCY_FILE,
+// read once, or nil when it is unset and pairs are taken uniformly.
+//
+// The file is CSV with one resource_id,weight line per resource (a header
+// line is skipped), e.g. request counts per resource from access logs; the
+// ids may be in any RLP_ID_FORMAT. Resources not listed get
+// BENCH_ACCESS_FREQUENCY_DEFAULT (default 0, never drawn).
+func accessFrequencyFromEnv() *accessFrequency {
+	frequencyOnce.Do(func() {
+		path := GetEnvWithDefault("BENCH_ACCESS_FREQUENCY_FILE", "")
+		if path == "" {
+			return
+		}
+		weights, err := readAccessFrequency(path)
+		if err != nil {
+			log.Fatalf("[access-frequency] %s: %v", path, err)
+		}
+		fallback, err := strconv.ParseFloat(GetEnvWithDefault("BENCH_ACCESS_FREQUENCY_DEFAULT", "0"), 64)
+		if err != nil || fallback < 0 {
+			log.Fatalf("[access-frequency] BENCH_ACCESS_FREQUENCY_DEFAULT: want a weight >= 0, got %q", GetEnvWithDefault("BENCH_ACCESS_FREQUENCY_DEFAULT", "0"))
+		}
+		frequency = &accessFrequency{weights: weights, fallback: fallback}
+		log.Printf("[access-frequency] %s: resources=%d default_weight=%g", path, len(weights), fallback)
+	})
+	return frequency
+}
+
+// readAccessFrequency reads the resource_id,weight lines of path.
+func readAccessFrequency(path string) (map[int]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	weights := map[int]float64{}
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		w, werr := strconv.ParseFloat(rec[1], 64)
+		if line == 1 && werr != nil {
+			continue // header
+		}
+		if werr != nil || w < 0 {
+			return nil, fmt.Errorf("line %d: want a weight >= 0, got %q", line, rec[1])
+		}
+		id, err := ids.Parse(ids.Resource, rec[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		weights[id] += w
+	}
+	if len(weights) == 0 {
+		return nil, errors.New("no resource_id,weight lines")
+	}
+	return weights, nil
+}
+
+// weight is the weight of the resource with id s.
+func (f *accessFrequency) weight(s string) float64 {
+	id, err := ids.Parse(ids.Resource, s)
+	if err != nil {
+		return 0
+	}
+	if w, ok := f.weights[id]; ok {
+		return w
+	}
+	return f.fallback
+}
+
+// draw returns n (resource, user) pairs drawn from pairs with replacement,
+// each resource as often as its weight says: a resource's weight is split
+// evenly among its pairs. The draws are seeded with
+// BENCH_ACCESS_FREQUENCY_SEED (default 1), so every backend gets the same
+// sequence. It logs how skewed the draws came out.
+func (f *accessFrequency) draw(pairs [][2]string, n int) [][2]string {
+	perResource := map[string]int{}
+	for _, p := range pairs {
+		perResource[p[0]]++
+	}
+	cumulative := make([]float64, len(pairs))
+	total := 0.0
+	for i, p := range pairs {
+		total += f.weight(p[0]) / float64(perResource[p[0]])
+		cumulative[i] = total
+	}
+	if total == 0 || n <= 0 {
+		return nil
+	}
+
+	rng := rand.New(rand.NewSource(int64(GetEnvInt("BENCH_ACCESS_FREQUENCY_SEED", 1))))
+	drawn := make([][2]string, n)
+	hits := map[string]int{}
+	for i := range drawn {
+		j := sort.SearchFloat64s(cumulative, rng.Float64()*total)
+		drawn[i] = pairs[min(j, len(pairs)-1)]
+		hits[drawn[i][0]]++
+	}
+
+	counts := make([]int, 0, len(hits))
+	for _, c := range hits {
+		counts = append(counts, c)
+	}
+	slices.Sort(counts)
+	slices.Reverse(counts)
+	top, topHits := max(len(counts)/100, 1), 0
+	for _, c := range counts[:top] {
+		topHits += c
+	}
+	log.Printf("[access-frequency] pairs=%d candidates=%d resources=%d top_1pct_share=%.3f", n, len(pairs), len(hits), float64(topHits)/float64(n))
+	return drawn
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// RunFullExport runs export_full_acl: every grant of the backend streamed and
+// discarded, the bulk read that cache warm-ups and sync jobs start with and
+// whose throughput differs the most between engines. export streams the
+// whole ACL once (resource_acl rows, ACL array entries, relationships of the
+// resource type) and returns how many grants arrived. After ERRORS it logs a
+// THROUGHPUT line as the read_relationships_* scenarios do, with the grants
+// as rels.
+//
+// Env vars:
+//
+//	BENCH_EXPORT_ITER         (default: 0 = skip)
+//	BENCH_EXPORT_TIMEOUT_SEC  (default: 600)
+func RunFullExport(engine string, export func(ctx context.Context) (int, error)) {
+	const scenario = "export_full_acl"
+	iters := GetEnvInt("BENCH_EXPORT_ITER", 0)
+	if iters <= 0 {
+		return
+	}
+	timeout := time.Duration(GetEnvInt("BENCH_EXPORT_TIMEOUT_SEC", 600)) * time.Second
+	log.Printf("[%s] [%s] streaming mode. iterations=%d timeout=%s", engine, scenario, iters, timeout)
+	LogScenarioConfig(engine, scenario, iters, timeout)
+
+	errs := NewErrorTally()
+	var durs []time.Duration
+	var total time.Duration
+	rows := 0
+	for i := range iters {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		start := time.Now()
+		n, err := export(ctx)
+		dur := time.Since(start)
+		cancel()
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[%s] [%s] iter=%d export failed class=%s after %d rows: %v", engine, scenario, i, class, n, err)
+			continue
+		}
+		durs = append(durs, dur)
+		total += dur
+		rows += n
+		log.Printf("[%s] [%s] iter=%d rows=%d dur=%s rows_per_sec=%.0f", engine, scenario, i, n, dur, float64(n)/dur.Seconds())
+	}
+
+	rate := 0.0
+	if total > 0 {
+		rate = float64(rows) / total.Seconds()
+	}
+	log.Printf("[%s] [%s] DONE: iters=%d %s", engine, scenario, len(durs), LatencySummary(durs))
+	log.Printf("[%s] [%s] ERRORS: %s", engine, scenario, errs.Summary(iters))
+	log.Printf("[%s] [%s] THROUGHPUT: rels=%d total=%s rels_per_sec=%.0f", engine, scenario, rows, total.Truncate(time.Millisecond), rate)
+}
+
+// CountSQLRows streams every row of query, scanning each column as a driver
+// value, and returns how many arrived: the export of the SQL backends.
+func CountSQLRows(ctx context.Context, db *sql.DB, query string, args ...any) (int, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	vals := make([]any, len(cols))
+	dest := make([]any, len(cols))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// GrantWindow is the optional validity window of a resource_acl row, read from
+// the trailing valid_from,valid_until columns of resource_acl.csv (RFC 3339,
+// empty = open bound). Expired grants stay in the dataset as soft deletes, so
+// only checks that honour the window deny them.
+type GrantWindow struct {
+	From  *time.Time
+	Until *time.Time
+}
+
+// Open bounds of a partially bounded window where a store needs both ends:
+// the authzed_* modules write them into the valid_window caveat, and read
+// them back as open bounds on export.
+var (
+	GrantWindowMin = time.Unix(0, 0).UTC()
+	GrantWindowMax = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// ParseGrantWindow reads columns 4 and 5 of a resource_acl record. Older
+// four-column files parse as an unbounded window.
+func ParseGrantWindow(rec []string) (GrantWindow, error) {
+	var w GrantWindow
+	var err error
+	if len(rec) > 4 {
+		if w.From, err = parseGrantTime(rec[4]); err != nil {
+			return w, fmt.Errorf("valid_from: %w", err)
+		}
+	}
+	if len(rec) > 5 {
+		if w.Until, err = parseGrantTime(rec[5]); err != nil {
+			return w, fmt.Errorf("valid_until: %w", err)
+		}
+	}
+	return w, nil
+}
+
+func parseGrantTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Bounded reports whether either end of the window is set.
+func (w GrantWindow) Bounded() bool {
+	return w.From != nil || w.Until != nil
+}
+
+// ActiveAt reports whether the grant is valid at t: valid_from <= t < valid_until.
+func (w GrantWindow) ActiveAt(t time.Time) bool {
+	if w.From != nil && t.Before(*w.From) {
+		return false
+	}
+	return w.Until == nil || t.Before(*w.Until)
+}
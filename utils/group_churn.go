@@ -0,0 +1,274 @@
+package utils
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"test-tls/ids"
+)
+
+// GroupEdgeChange is a group hierarchy change event: the group_hierarchy edge
+// from Parent to Child with Relation ("member_group" or "manager_group") is
+// added or removed.
+type GroupEdgeChange struct {
+	Add           bool
+	Parent, Child int
+	Relation      string
+}
+
+// GroupMaintainer applies group hierarchy changes to a backend: the
+// group_hierarchy rows and the derived table its nested group queries read
+// (group_closure or group_members_expanded), updated incrementally.
+type GroupMaintainer interface {
+	// Edges returns up to n existing group_hierarchy edges, as adds.
+	Edges(ctx context.Context, n int) ([]GroupEdgeChange, error)
+	// Apply applies burst in order and brings the derived table up to date.
+	// It returns the derived rows written, plus those deleted where the
+	// backend reports them.
+	Apply(ctx context.Context, burst []GroupEdgeChange) (int, error)
+}
+
+// RunGroupChurn benchmarks the update latency of m per burst of hierarchy
+// changes, for each burst size of BENCH_GROUP_CHURN_BURSTS. It logs a
+// "[group_churn] variant=<burst size> query=<step> DONE:" line per size,
+// rows being the derived rows the last burst touched.
+//
+// With data/group_churn.csv (op,parent_group_id,child_group_id,relation; op
+// "add" or "remove") the changes are replayed in file order as consecutive
+// bursts (step "apply") and stay applied. Without it, existing edges are
+// removed and added back (steps "remove" and "add"), so the hierarchy ends as
+// it started unless a burst fails.
+//
+// Env vars:
+//
+//	BENCH_GROUP_CHURN_BURSTS  (default: "1,10,100")
+//	BENCH_GROUP_CHURN_ITER    (default: 5)
+func RunGroupChurn(engine string, m GroupMaintainer) {
+	const scenario = "group_churn"
+	bursts := groupChurnBursts(engine)
+	iters := GetEnvInt("BENCH_GROUP_CHURN_ITER", 5)
+	LogScenarioConfig(engine, scenario, iters, 10*time.Minute)
+
+	if churn := readGroupChurn(); len(churn) > 0 {
+		log.Printf("[%s] [%s] replaying %d changes of group_churn.csv: bursts=%v iters=%d", engine, scenario, len(churn), bursts, iters)
+		for _, size := range bursts {
+			var batches [][]GroupEdgeChange
+			for range iters {
+				if len(churn) == 0 {
+					break
+				}
+				n := min(size, len(churn))
+				batches = append(batches, churn[:n])
+				churn = churn[n:]
+			}
+			if len(batches) == 0 {
+				log.Printf("[%s] [%s] variant=%d skipped: group_churn.csv exhausted", engine, scenario, size)
+				continue
+			}
+			runGroupChurnStep(engine, size, "apply", m, batches)
+		}
+		log.Printf("[%s] [%s] DONE", engine, scenario)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	edges, err := m.Edges(ctx, slices.Max(bursts))
+	cancel()
+	if err != nil {
+		log.Fatalf("[%s] [%s] read group_hierarchy edges: %v", engine, scenario, err)
+	}
+	if len(edges) == 0 {
+		log.Printf("[%s] [%s] skipped: no group_hierarchy edges; run load-data first", engine, scenario)
+		return
+	}
+	log.Printf("[%s] [%s] remove and re-add existing edges: bursts=%v iters=%d edges=%d", engine, scenario, bursts, iters, len(edges))
+	for _, size := range bursts {
+		if size > len(edges) {
+			log.Printf("[%s] [%s] variant=%d skipped: only %d edges", engine, scenario, size, len(edges))
+			continue
+		}
+		removes := make([]GroupEdgeChange, size)
+		for i, e := range edges[:size] {
+			e.Add = false
+			removes[i] = e
+		}
+		// interleaved, so each remove burst is followed by the add burst
+		// that restores it
+		durations := map[string][]time.Duration{}
+		rows := map[string]int{}
+		errs := map[string]*ErrorTally{"remove": NewErrorTally(), "add": NewErrorTally()}
+		for i := range iters {
+			for _, step := range []struct {
+				name  string
+				burst []GroupEdgeChange
+			}{{"remove", removes}, {"add", edges[:size]}} {
+				dur, n, err := applyGroupBurst(m, step.burst)
+				if err != nil {
+					class := errs[step.name].Record(err)
+					log.Printf("[%s] [%s] variant=%d query=%s iter=%d failed class=%s: %v", engine, scenario, size, step.name, i, class, err)
+					continue
+				}
+				durations[step.name] = append(durations[step.name], dur)
+				rows[step.name] = n
+			}
+		}
+		for _, step := range []string{"remove", "add"} {
+			logGroupChurnStep(engine, size, step, durations[step], rows[step], errs[step], iters)
+		}
+	}
+	log.Printf("[%s] [%s] DONE", engine, scenario)
+}
+
+// runGroupChurnStep applies batches in order as step of burst size and logs
+// its DONE and ERRORS lines.
+func runGroupChurnStep(engine string, size int, step string, m GroupMaintainer, batches [][]GroupEdgeChange) {
+	durations := make([]time.Duration, 0, len(batches))
+	rows := 0
+	errs := NewErrorTally()
+	for i, burst := range batches {
+		dur, n, err := applyGroupBurst(m, burst)
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[%s] [group_churn] variant=%d query=%s iter=%d failed class=%s: %v", engine, size, step, i, class, err)
+			continue
+		}
+		durations = append(durations, dur)
+		rows = n
+	}
+	logGroupChurnStep(engine, size, step, durations, rows, errs, len(batches))
+}
+
+func applyGroupBurst(m GroupMaintainer, burst []GroupEdgeChange) (time.Duration, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	start := time.Now()
+	n, err := m.Apply(ctx, burst)
+	return time.Since(start), n, err
+}
+
+func logGroupChurnStep(engine string, size int, step string, durations []time.Duration, rows int, errs *ErrorTally, attempts int) {
+	log.Printf("[%s] [group_churn] variant=%d query=%s DONE: iters=%d rows=%d %s", engine, size, step, len(durations), rows, LatencySummary(durations))
+	log.Printf("[%s] [group_churn] variant=%d query=%s ERRORS: %s", engine, size, step, errs.Summary(attempts))
+}
+
+// groupChurnBursts parses BENCH_GROUP_CHURN_BURSTS.
+func groupChurnBursts(engine string) []int {
+	var bursts []int
+	for s := range strings.SplitSeq(GetEnvWithDefault("BENCH_GROUP_CHURN_BURSTS", "1,10,100"), ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n <= 0 {
+			log.Fatalf("[%s] BENCH_GROUP_CHURN_BURSTS: invalid burst size %q", engine, s)
+		}
+		bursts = append(bursts, n)
+	}
+	return bursts
+}
+
+// readGroupChurn reads data/group_churn.csv; a missing file yields nothing.
+func readGroupChurn() []GroupEdgeChange {
+	path := filepath.Join(DataDir(), "group_churn.csv")
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		log.Fatalf("[group_churn] open %s: %v", path, err)
+	}
+	defer f.Close()
+	r := ScopeCSV("group_churn.csv", csv.NewReader(f))
+	if _, err := r.Read(); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		log.Fatalf("[group_churn] %s: read header: %v", path, err)
+	}
+	var churn []GroupEdgeChange
+	for row := 1; ; row++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return churn
+		}
+		if err != nil {
+			log.Fatalf("[group_churn] %s row %d: %v", path, row, err)
+		}
+		// op,parent_group_id,child_group_id,relation
+		if len(rec) < 4 {
+			log.Fatalf("[group_churn] %s row %d: want 4 columns, got %#v", path, row, rec)
+		}
+		var c GroupEdgeChange
+		switch rec[0] {
+		case "add", "remove":
+			c.Add = rec[0] == "add"
+		default:
+			log.Fatalf("[group_churn] %s row %d: unknown op %q (want add or remove)", path, row, rec[0])
+		}
+		if c.Parent, err = ids.Parse(ids.Group, rec[1]); err == nil {
+			c.Child, err = ids.Parse(ids.Group, rec[2])
+		}
+		if err != nil {
+			log.Fatalf("[group_churn] %s row %d: %v", path, row, err)
+		}
+		if rec[3] != "member_group" && rec[3] != "manager_group" {
+			log.Fatalf("[group_churn] %s row %d: unknown relation %q", path, row, rec[3])
+		}
+		c.Relation = rec[3]
+		churn = append(churn, c)
+	}
+}
+
+// GroupGraph is an in-memory copy of group_hierarchy, for maintainers that
+// recompute the derived rows of the groups above a changed edge in process.
+type GroupGraph struct {
+	Children map[int]map[int]string // parent -> child -> relation
+	parents  map[int]map[int]bool   // child -> parents
+}
+
+// NewGroupGraph returns an empty graph.
+func NewGroupGraph() *GroupGraph {
+	return &GroupGraph{Children: map[int]map[int]string{}, parents: map[int]map[int]bool{}}
+}
+
+// Apply adds or removes the edge of c.
+func (g *GroupGraph) Apply(c GroupEdgeChange) {
+	if !c.Add {
+		delete(g.Children[c.Parent], c.Child)
+		delete(g.parents[c.Child], c.Parent)
+		return
+	}
+	if g.Children[c.Parent] == nil {
+		g.Children[c.Parent] = map[int]string{}
+	}
+	g.Children[c.Parent][c.Child] = c.Relation
+	if g.parents[c.Child] == nil {
+		g.parents[c.Child] = map[int]bool{}
+	}
+	g.parents[c.Child][c.Parent] = true
+}
+
+// Ancestors returns groups and every group above them through any relation,
+// once each; cycles are safe.
+func (g *GroupGraph) Ancestors(groups ...int) []int {
+	seen := map[int]bool{}
+	var out []int
+	for len(groups) > 0 {
+		id := groups[len(groups)-1]
+		groups = groups[:len(groups)-1]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+		for p := range g.parents[id] {
+			groups = append(groups, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// hdrSink keeps the latency of every timed call per scenario for the .hgrm
+// export of BENCH_HDR_DIR.
+type hdrSink struct {
+	mu        sync.Mutex
+	backend   string
+	latencies map[string][]time.Duration
+}
+
+// hdr is the sink of the running benchmark, nil unless BENCH_HDR_DIR is set.
+var hdr *hdrSink
+
+// hgrmTicksPerHalfDistance is the percentile resolution of the .hgrm output:
+// ten steps up to p50, then twice as many each time the distance to p100
+// halves, as HdrHistogram's outputPercentileDistribution prints them.
+const hgrmTicksPerHalfDistance = 5
+
+// StartHDR records the latency of every timed check and lookup (see
+// AuditCheck) when BENCH_HDR_DIR is set. The returned func writes one
+// percentile distribution per scenario to
+// <dir>/<backend>-<UTC timestamp>-<scenario>.hgrm, in the text format of
+// HdrHistogram's outputPercentileDistribution (values in milliseconds), so
+// the files load as-is into the HdrHistogram plotter and similar tools for
+// the tails the p50/p95/p99 summaries flatten. It is a no-op when
+// BENCH_HDR_DIR is unset.
+func StartHDR(backend string) func() {
+	dir := os.Getenv("BENCH_HDR_DIR")
+	if dir == "" {
+		return func() {}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Fatalf("[%s] failed to create HDR dir %q: %v", backend, dir, err)
+	}
+	s := &hdrSink{backend: backend, latencies: map[string][]time.Duration{}}
+	hdr = s
+	prefix := filepath.Join(dir, fmt.Sprintf("%s-%s-", backend, time.Now().UTC().Format("20060102T150405Z")))
+	log.Printf("[%s] recording latency histograms to %s<scenario>.hgrm", backend, prefix)
+
+	return func() {
+		hdr = nil
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, name := range slices.Sorted(maps.Keys(s.latencies)) {
+			path := prefix + name + ".hgrm"
+			if err := writeHgrmFile(path, s.latencies[name]); err != nil {
+				log.Printf("[%s] [%s] HDR export failed: %v", backend, name, err)
+				continue
+			}
+			log.Printf("[%s] [%s] HDR: samples=%d file=%s", backend, name, len(s.latencies[name]), path)
+		}
+	}
+}
+
+func (s *hdrSink) record(scenario string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies[scenario] = append(s.latencies[scenario], latency)
+}
+
+func writeHgrmFile(path string, latencies []time.Duration) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := writeHgrm(w, latencies); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeHgrm writes the percentile distribution of latencies in the .hgrm
+// text format: value (ms), percentile, count of values up to it and
+// 1/(1-percentile), then the mean, standard deviation, max and count.
+func writeHgrm(w io.Writer, latencies []time.Duration) error {
+	n := len(latencies)
+	if n == 0 {
+		return fmt.Errorf("no samples")
+	}
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+	fmt.Fprintf(w, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)")
+	for p := 0.0; ; {
+		i := max(int(math.Ceil(p/100*float64(n)))-1, 0)
+		if i >= n-1 {
+			break
+		}
+		v := sorted[i]
+		count := sort.Search(n, func(j int) bool { return sorted[j] > v })
+		fmt.Fprintf(w, "%12.3f %2.12f %10d %14.2f\n", ms(v), p/100, count, 1/(1-p/100))
+		ticks := hgrmTicksPerHalfDistance * math.Pow(2, math.Floor(math.Log2(100/(100-p)))+1)
+		p += 100 / ticks
+	}
+	fmt.Fprintf(w, "%12.3f %2.12f %10d\n", ms(sorted[n-1]), 1.0, n)
+
+	var sum, sq float64
+	for _, d := range sorted {
+		sum += ms(d)
+	}
+	mean := sum / float64(n)
+	for _, d := range sorted {
+		sq += (ms(d) - mean) * (ms(d) - mean)
+	}
+	fmt.Fprintf(w, "#[Mean    = %12.3f, StdDeviation   = %12.3f]\n", mean, math.Sqrt(sq/float64(n)))
+	_, err := fmt.Fprintf(w, "#[Max     = %12.3f, Total count    = %12d]\n", ms(sorted[n-1]), n)
+	return err
+}
@@ -44,3 +44,9 @@ func GetEnvInt(key string, def int) int {
 	}
 	return n
 }
+
+// DataDir is the directory the CSV dataset is read from: RLP_DATA_DIR
+// (--data-dir), default data. `csv generate` writes there too.
+func DataDir() string {
+	return GetEnvWithDefault("RLP_DATA_DIR", "data")
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// iterLog is the per-iteration logging of the scenarios, read from the
+// environment once, and when the current scenario last logged.
+var iterLog struct {
+	once     sync.Once
+	mu       sync.Mutex
+	every    int
+	interval time.Duration
+	last     time.Time
+}
+
+// LogIteration reports whether iteration done of the current scenario logs
+// its "iter=" line. By default every every-th iteration does, the scenario's
+// own count (100, 10 for the bulk checks). BENCH_LOG_EVERY sets one
+// count for all scenarios, 1 logging every iteration. BENCH_LOG_INTERVAL, a
+// duration such as 5s, logs the first iteration and then the first one after
+// each interval instead, so the output follows the run time rather than the
+// iteration count. The interval starts anew with each scenario (see
+// LogScenarioConfig).
+//
+// Env vars:
+//
+//	BENCH_LOG_EVERY     (default: 0, the scenario's count)
+//	BENCH_LOG_INTERVAL  (default: unset, count-based)
+func LogIteration(done, every int) bool {
+	iterLog.once.Do(func() {
+		iterLog.every = GetEnvInt("BENCH_LOG_EVERY", 0)
+		if s := GetEnvWithDefault("BENCH_LOG_INTERVAL", ""); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil || d <= 0 {
+				log.Fatalf("BENCH_LOG_INTERVAL: want a positive duration such as 5s, got %q", s)
+			}
+			iterLog.interval = d
+		}
+	})
+	if iterLog.interval > 0 {
+		iterLog.mu.Lock()
+		defer iterLog.mu.Unlock()
+		now := time.Now()
+		if iterLog.last.IsZero() || now.Sub(iterLog.last) >= iterLog.interval {
+			iterLog.last = now
+			return true
+		}
+		return false
+	}
+	if iterLog.every > 0 {
+		every = iterLog.every
+	}
+	return done%every == 0
+}
+
+// nextIterLogScenario starts the log interval of a new scenario, whose first
+// iteration logs. Called by LogScenarioConfig.
+func nextIterLogScenario() {
+	iterLog.mu.Lock()
+	iterLog.last = time.Time{}
+	iterLog.mu.Unlock()
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ApplyRunnerLimits applies BENCH_GOMAXPROCS to the benchmark process and logs
+// the CPU limits it runs under as a "LIMITS:" line, so runs on different
+// machines (or in differently sized containers) are annotated with them and
+// parse_all can list them. Without BENCH_GOMAXPROCS Go picks GOMAXPROCS from
+// the CPU count, the affinity mask and the cgroup CPU quota.
+//
+// A warning is logged when GOMAXPROCS exceeds the CPUs the process may run
+// on: high-concurrency scenarios are then bottlenecked by client-side CPU
+// contention, not by the backend. Pin the client with BENCH_CPUSET in
+// benchmark/3-benchmark.sh (taskset) to keep it off the backend's cores.
+//
+// Env vars:
+//
+//	BENCH_GOMAXPROCS  (default: 0 = Go's default)
+func ApplyRunnerLimits(engine string) {
+	if n := GetEnvInt("BENCH_GOMAXPROCS", 0); n > 0 {
+		runtime.GOMAXPROCS(n)
+	}
+	procs := runtime.GOMAXPROCS(0)
+
+	affinity, affinityCPUs := cpuAffinity()
+	quota := cgroupCPUQuota()
+	host, _ := os.Hostname()
+	log.Printf("[%s] LIMITS: gomaxprocs=%d num_cpu=%d affinity=%s cgroup_cpu=%s host=%s go=%s",
+		engine, procs, runtime.NumCPU(), affinity, formatQuota(quota), host, runtime.Version())
+
+	available := float64(runtime.NumCPU())
+	if affinityCPUs > 0 {
+		available = float64(affinityCPUs)
+	}
+	if quota > 0 && quota < available {
+		available = quota
+	}
+	if float64(procs) > available {
+		log.Printf("[%s] LIMITS: WARNING gomaxprocs=%d exceeds the %s CPUs available; client-side CPU contention may bottleneck concurrent scenarios", engine, procs, formatQuota(available))
+	}
+}
+
+// cpuAffinity returns the CPUs the process may run on, as listed by
+// /proc/self/status (e.g. "0-3,8"), and their count. It returns "n/a" and 0
+// where that is not available.
+func cpuAffinity() (string, int) {
+	data, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return "n/a", 0
+	}
+	for line := range strings.SplitSeq(string(data), "\n") {
+		list, ok := strings.CutPrefix(line, "Cpus_allowed_list:")
+		if !ok {
+			continue
+		}
+		list = strings.TrimSpace(list)
+		return list, countCPUList(list)
+	}
+	return "n/a", 0
+}
+
+// countCPUList counts the CPUs of a kernel CPU list such as "0-3,8".
+func countCPUList(list string) int {
+	n := 0
+	for part := range strings.SplitSeq(list, ",") {
+		lo, hi, isRange := strings.Cut(part, "-")
+		if !isRange {
+			hi = lo
+		}
+		a, err1 := strconv.Atoi(lo)
+		b, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || b < a {
+			continue
+		}
+		n += b - a + 1
+	}
+	return n
+}
+
+// cgroupCPUQuota returns the cgroup CPU quota in CPUs (e.g. 2.5), reading
+// cgroup v2 cpu.max or the v1 CFS quota. It returns 0 when there is no quota.
+func cgroupCPUQuota() float64 {
+	if data, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		// "max 100000" or "<quota> <period>"
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 && fields[0] != "max" {
+			return cpuRatio(fields[0], fields[1])
+		}
+		return 0
+	}
+	quota, err1 := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	period, err2 := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return cpuRatio(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// cpuRatio returns quota/period, or 0 for an unlimited (-1) or malformed quota.
+func cpuRatio(quota, period string) float64 {
+	q, err1 := strconv.ParseFloat(quota, 64)
+	p, err2 := strconv.ParseFloat(period, 64)
+	if err1 != nil || err2 != nil || q <= 0 || p <= 0 {
+		return 0
+	}
+	return q / p
+}
+
+// formatQuota formats a CPU count for the LIMITS line; 0 is "none".
+func formatQuota(cpus float64) string {
+	if cpus <= 0 {
+		return "none"
+	}
+	return strconv.FormatFloat(cpus, 'f', -1, 64)
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"test-tls/ids"
+)
+
+// ListedResource is one row of an authorized listing: a resource and its
+// created_at.
+type ListedResource struct {
+	ID        string
+	CreatedAt time.Time
+}
+
+// ListPage returns up to limit resources userID can view, newest first
+// (created_at, then resource_id, descending), after the last row of the
+// previous page, or from the start when after is nil.
+type ListPage func(ctx context.Context, userID string, limit int, after *ListedResource) ([]ListedResource, error)
+
+// RunRecentListing runs the list_recent_viewable scenario: the application
+// query "the 50 most recent resources user X can view", which has to filter
+// by permission, sort by created_at and paginate in one query. Plain lookups
+// only enumerate the resources and so underestimate what an application pays
+// for a listing page. Each iteration reads BENCH_LIST_RECENT_PAGES pages of
+// BENCH_LIST_RECENT_LIMIT rows for BENCH_LOOKUPRES_VIEW_USER, keyset-paginated
+// on (created_at, resource_id); the first page and the full iteration are
+// timed. Resources loaded from a resources.csv without created_at have none
+// and are not listed.
+//
+// Env vars:
+//
+//	BENCH_LIST_RECENT_ITER   (default: 100; 0 skips the scenario)
+//	BENCH_LIST_RECENT_LIMIT  (default: 50)
+//	BENCH_LIST_RECENT_PAGES  (default: 2)
+func RunRecentListing(engine string, page ListPage) {
+	const scenario = "list_recent_viewable"
+	iters := GetEnvInt("BENCH_LIST_RECENT_ITER", 100)
+	limit := GetEnvInt("BENCH_LIST_RECENT_LIMIT", 50)
+	pages := GetEnvInt("BENCH_LIST_RECENT_PAGES", 2)
+	userID := ids.DecimalEnv(ids.User, "BENCH_LOOKUPRES_VIEW_USER")
+	if iters <= 0 || userID == "" {
+		log.Printf("[%s] [%s] skipped: needs BENCH_LIST_RECENT_ITER and BENCH_LOOKUPRES_VIEW_USER", engine, scenario)
+		return
+	}
+	timeout := 30 * time.Second
+	log.Printf("[%s] [%s] streaming mode. iterations=%d user=%s limit=%d pages=%d", engine, scenario, iters, userID, limit, pages)
+	LogScenarioConfig(engine, scenario, iters, timeout, userID)
+
+	var first, full []time.Duration
+	rows := 0
+	errs := NewErrorTally()
+	for i := range iters {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		start := time.Now()
+		var firstDur time.Duration
+		var after *ListedResource
+		n := 0
+		var err error
+		for p := range pages {
+			var got []ListedResource
+			got, err = page(ctx, userID, limit, after)
+			if err != nil {
+				break
+			}
+			if p == 0 {
+				firstDur = time.Since(start)
+			}
+			n += len(got)
+			if len(got) < limit {
+				break
+			}
+			after = &got[len(got)-1]
+		}
+		dur := time.Since(start)
+		cancel()
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[%s] [%s] iter=%d list failed class=%s: %v", engine, scenario, i, class, err)
+			continue
+		}
+		first, full = append(first, firstDur), append(full, dur)
+		rows = n
+		log.Printf("[%s] [%s] iter=%d rows=%d first_page=%s dur=%s", engine, scenario, i, n, firstDur, dur)
+	}
+
+	log.Printf("[%s] [%s] DONE: iters=%d rows=%d %s", engine, scenario, iters, rows, LatencySummary(full))
+	log.Printf("[%s] [%s] FIRST_PAGE: %s", engine, scenario, LatencySummary(first))
+	log.Printf("[%s] [%s] ERRORS: %s", engine, scenario, errs.Summary(iters))
+}
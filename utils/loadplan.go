@@ -0,0 +1,260 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loadCalibration holds the rough per-row costs of loading one backend,
+// measured with the docker compose services on a laptop-class machine. They
+// are meant to tell a 10-minute load from a 10-hour one, not to be exact;
+// RLP_PLAN_ROWS_PER_SEC overrides the throughput with one measured on the
+// target (load-data logs its rows and elapsed time).
+type loadCalibration struct {
+	diskPerRow     float64 // on-disk bytes per CSV row, indexes included
+	diskPerDerived float64 // on-disk bytes per derived permission row (views, expanded tables)
+	rowsPerSec     float64 // CSV rows loaded per second
+	derivedPerSec  float64 // derived rows computed per second (refresh, expansion)
+	clientPerRow   float64 // loader memory per CSV row it keeps in memory; 0 when it streams
+	serverHotShare float64 // share of the on-disk size the server should hold in memory
+}
+
+// loadCalibrations are the loadCalibration of every backend. SpiceDB stores
+// every row as a relationship and derives nothing at load time.
+var loadCalibrations = map[string]loadCalibration{
+	"postgres":      {diskPerRow: 140, diskPerDerived: 90, rowsPerSec: 60000, derivedPerSec: 400000, serverHotShare: 0.25},
+	"cockroachdb":   {diskPerRow: 220, diskPerDerived: 120, rowsPerSec: 15000, derivedPerSec: 100000, serverHotShare: 0.25},
+	"clickhouse":    {diskPerRow: 12, diskPerDerived: 8, rowsPerSec: 300000, derivedPerSec: 1000000, clientPerRow: 16, serverHotShare: 0.05},
+	"scylladb":      {diskPerRow: 180, diskPerDerived: 200, rowsPerSec: 20000, derivedPerSec: 50000, clientPerRow: 120, serverHotShare: 0.1},
+	"mongodb":       {diskPerRow: 160, diskPerDerived: 80, rowsPerSec: 20000, derivedPerSec: 100000, serverHotShare: 0.5},
+	"elasticsearch": {diskPerRow: 60, diskPerDerived: 12, rowsPerSec: 30000, derivedPerSec: 200000, clientPerRow: 150, serverHotShare: 0.5},
+	"authzed_pgdb":  {diskPerRow: 250, rowsPerSec: 10000, serverHotShare: 0.25},
+	"authzed_crdb":  {diskPerRow: 350, rowsPerSec: 5000, serverHotShare: 0.25},
+}
+
+// planCSVs are the CSVs load-data reads, in load order; resource_bans only
+// by the backends enforcing denials.
+var planCSVs = []string{"organizations", "users", "groups", "org_memberships", "group_memberships", "group_hierarchy", "resources", "resource_acl", "resource_bans"}
+
+// DatasetRows are the row counts of the CSVs of one dataset, keyed by file
+// name without .csv, and how many resource_acl rows grant to a group.
+type DatasetRows struct {
+	Rows        map[string]int `json:"rows,omitempty"`
+	GroupGrants int            `json:"group_grants,omitempty"`
+}
+
+// total is the number of CSV rows.
+func (d DatasetRows) total() int {
+	n := 0
+	for _, name := range planCSVs {
+		n += d.Rows[name]
+	}
+	return n
+}
+
+// derived estimates the permission rows backends materialize from the ACL:
+// one per direct user grant, plus one per member of the group for every group
+// grant (members per group averaged over group_memberships).
+func (d DatasetRows) derived() int {
+	perGroup := 1.0
+	if d.Rows["groups"] > 0 {
+		perGroup = max(1, float64(d.Rows["group_memberships"])/float64(d.Rows["groups"]))
+	}
+	userGrants := d.Rows["resource_acl"] - d.GroupGrants
+	return userGrants + int(float64(d.GroupGrants)*perGroup)
+}
+
+// datasetRows returns the row counts recorded in the manifest of dir, or
+// counts the CSVs when there is none (or it predates row counts). The source
+// is "manifest" or "csv".
+func datasetRows(dir string) (DatasetRows, string, error) {
+	if b, err := os.ReadFile(filepath.Join(dir, "manifest.json")); err == nil {
+		var m DatasetManifest
+		if err := json.Unmarshal(b, &m); err == nil && len(m.Rows) > 0 {
+			return m.DatasetRows, "manifest", nil
+		}
+	}
+
+	d := DatasetRows{Rows: map[string]int{}}
+	for _, name := range planCSVs {
+		rows, groups, err := countCSV(filepath.Join(dir, name+".csv"), name == "resource_acl")
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return d, "csv", err
+		}
+		d.Rows[name] = rows
+		d.GroupGrants += groups
+	}
+	return d, "csv", nil
+}
+
+// countCSV counts the data rows of a CSV (header excluded) and, with groups,
+// the rows whose second column is "group".
+func countCSV(path string, groups bool) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 1<<20)
+	rows, groupRows := -1, 0
+	more := false // the previous read ended mid-line
+	for {
+		line, err := r.ReadSlice('\n')
+		if !more && len(bytes.TrimSpace(line)) > 0 {
+			rows++
+			if groups && rows > 0 && bytes.Contains(line, []byte(",group,")) {
+				groupRows++
+			}
+		}
+		more = err == bufio.ErrBufferFull
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !more {
+			return 0, 0, err
+		}
+	}
+	return max(rows, 0), groupRows, nil
+}
+
+// loadPlan is the estimate PlanLoad logs for one backend.
+type loadPlan struct {
+	Rows         int
+	Derived      int
+	DiskBytes    float64
+	Load         time.Duration
+	ServerMemory float64
+	ClientMemory float64
+}
+
+// estimateLoad applies the calibration of engine to d.
+func estimateLoad(engine string, d DatasetRows) (loadPlan, error) {
+	c, ok := loadCalibrations[engine]
+	if !ok {
+		return loadPlan{}, fmt.Errorf("no load calibration for %q", engine)
+	}
+	if v := GetEnvInt("RLP_PLAN_ROWS_PER_SEC", 0); v > 0 {
+		c.rowsPerSec = float64(v)
+	}
+	p := loadPlan{Rows: d.total()}
+	if c.diskPerDerived > 0 {
+		p.Derived = d.derived()
+	}
+	p.DiskBytes = float64(p.Rows)*c.diskPerRow + float64(p.Derived)*c.diskPerDerived
+	seconds := float64(p.Rows) / c.rowsPerSec
+	if p.Derived > 0 {
+		seconds += float64(p.Derived) / c.derivedPerSec
+	}
+	p.Load = time.Duration(seconds * float64(time.Second))
+	p.ServerMemory = p.DiskBytes * c.serverHotShare
+	p.ClientMemory = float64(p.Rows) * c.clientPerRow
+	return p, nil
+}
+
+// PlanLoad estimates, before load-data, what loading the CSVs of
+// RLP_DATA_DIR into engine takes: on-disk size, load duration and the memory
+// the server (hot data and indexes) and the loader need. Row counts come from
+// the manifest `csv generate` writes, or from counting the CSVs. It warns when
+// the target looks undersized for the dataset and then exits non-zero, so
+// `plan-load && load-data` stops before a doomed multi-hour load.
+//
+// Env vars (target sizing; 0 skips the check):
+//
+//	RLP_PLAN_DISK_GB        free disk on the backend
+//	RLP_PLAN_MEMORY_GB      memory of the backend
+//	RLP_PLAN_MAX_LOAD_MIN   longest acceptable load (default: 120)
+//	RLP_PLAN_ROWS_PER_SEC   measured load throughput, instead of the calibration
+//
+// The loader's memory is checked against MemAvailable of this machine.
+func PlanLoad(engine string) {
+	d, source, err := datasetRows(DataDir())
+	if err != nil {
+		log.Fatalf("[%s] [plan_load] count rows: %v", engine, err)
+	}
+	if d.total() == 0 {
+		log.Fatalf("[%s] [plan_load] no CSV rows in %s; run `csv generate` first", engine, DataDir())
+	}
+	p, err := estimateLoad(engine, d)
+	if err != nil {
+		log.Fatalf("[%s] [plan_load] %v", engine, err)
+	}
+
+	counts := make([]string, 0, len(planCSVs))
+	for _, name := range planCSVs {
+		counts = append(counts, fmt.Sprintf("%s=%d", name, d.Rows[name]))
+	}
+	log.Printf("[%s] [plan_load] source=%s rows=%d %s group_grants=%d", engine, source, p.Rows, strings.Join(counts, " "), d.GroupGrants)
+	log.Printf("[%s] [plan_load] ESTIMATE: disk=%s load=%s derived_rows=%d server_memory=%s client_memory=%s",
+		engine, formatSize(p.DiskBytes), p.Load.Truncate(time.Second), p.Derived, formatSize(p.ServerMemory), formatSize(p.ClientMemory))
+
+	warnings := 0
+	warn := func(format string, args ...any) {
+		warnings++
+		log.Printf("[%s] [plan_load] WARN: "+format, append([]any{engine}, args...)...)
+	}
+	const gb = 1 << 30
+	if disk := GetEnvInt("RLP_PLAN_DISK_GB", 0); disk > 0 && p.DiskBytes > float64(disk)*gb {
+		warn("disk %s exceeds RLP_PLAN_DISK_GB=%d", formatSize(p.DiskBytes), disk)
+	}
+	if mem := GetEnvInt("RLP_PLAN_MEMORY_GB", 0); mem > 0 && p.ServerMemory > float64(mem)*gb {
+		warn("hot data %s exceeds RLP_PLAN_MEMORY_GB=%d; reads will hit disk", formatSize(p.ServerMemory), mem)
+	}
+	if limit := GetEnvInt("RLP_PLAN_MAX_LOAD_MIN", 120); limit > 0 && p.Load > time.Duration(limit)*time.Minute {
+		warn("load %s exceeds RLP_PLAN_MAX_LOAD_MIN=%d", p.Load.Truncate(time.Second), limit)
+	}
+	if avail := memAvailable(); avail > 0 && p.ClientMemory > 0.8*float64(avail) {
+		warn("loader memory %s is close to or above the %s available here", formatSize(p.ClientMemory), formatSize(float64(avail)))
+	}
+
+	if warnings > 0 {
+		log.Fatalf("[%s] [plan_load] %d warnings: the target is likely undersized for this dataset", engine, warnings)
+	}
+	log.Printf("[%s] [plan_load] OK", engine)
+}
+
+// memAvailable returns MemAvailable of /proc/meminfo in bytes, or 0 where it
+// is not available.
+func memAvailable() int64 {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	for line := range strings.SplitSeq(string(data), "\n") {
+		rest, ok := strings.CutPrefix(line, "MemAvailable:")
+		if !ok {
+			continue
+		}
+		kb, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(rest), " kB"), 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb << 10
+	}
+	return 0
+}
+
+// formatSize formats bytes with a binary unit.
+func formatSize(b float64) string {
+	switch {
+	case b >= 1<<30:
+		return fmt.Sprintf("%.1fGiB", b/(1<<30))
+	case b >= 1<<20:
+		return fmt.Sprintf("%.1fMiB", b/(1<<20))
+	case b >= 1<<10:
+		return fmt.Sprintf("%.1fKiB", b/(1<<10))
+	}
+	return fmt.Sprintf("%.0fB", b)
+}
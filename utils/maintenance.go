@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Maintenance runs one round of a backend's background maintenance, the kind
+// of housekeeping that competes with reads in production: a ClickHouse merge,
+// a ScyllaDB compaction, a Postgres vacuum or a CockroachDB backup.
+type Maintenance func(ctx context.Context) error
+
+// RunMaintenancePhases runs a backend's read scenarios. By default run is
+// called once. With BENCH_MAINTENANCE=1 it is called twice: a quiet pass,
+// then a pass during which m runs round after round in the background, so
+// every scenario is measured with and without maintenance competing for the
+// server. Each pass starts with a "MAINTENANCE: phase=quiet|maintenance"
+// line; parse_all reports the two passes side by side.
+//
+// task names m in the log. A round still running when the maintenance pass
+// ends is cancelled; what the server does with a cancelled round (a backup
+// job may carry on) is up to the backend. Failed rounds are logged and
+// counted and the next one starts after the pause. Backends without a
+// maintenance operation pass nil and run once.
+//
+// Env vars:
+//
+//	BENCH_MAINTENANCE                    (default: 0)
+//	BENCH_MAINTENANCE_PAUSE_MS           (default: 0, rounds back to back)
+//	BENCH_MAINTENANCE_ROUND_TIMEOUT_SEC  (default: 600)
+func RunMaintenancePhases(engine, task string, m Maintenance, run func()) {
+	if GetEnvInt("BENCH_MAINTENANCE", 0) == 0 {
+		run()
+		return
+	}
+	if m == nil {
+		log.Printf("[%s] MAINTENANCE: no background maintenance operation; running the scenarios once", engine)
+		run()
+		return
+	}
+
+	log.Printf("[%s] MAINTENANCE: phase=quiet", engine)
+	run()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	var rounds, failed int
+	var busy time.Duration
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rounds, failed, busy = loopMaintenance(ctx, engine, task, m)
+	}()
+	log.Printf("[%s] MAINTENANCE: phase=maintenance task=%s", engine, task)
+	start := time.Now()
+	run()
+	elapsed := time.Since(start)
+	cancel()
+	wg.Wait()
+
+	avg := time.Duration(0)
+	if rounds > 0 {
+		avg = busy / time.Duration(rounds)
+	}
+	log.Printf("[%s] MAINTENANCE: DONE task=%s rounds=%d failed=%d busy=%s round_avg=%s pass=%s",
+		engine, task, rounds, failed, busy.Truncate(time.Millisecond), avg.Truncate(time.Millisecond), elapsed.Truncate(time.Millisecond))
+}
+
+// loopMaintenance runs m until ctx is done and returns the rounds it
+// started, how many of them failed (cancelled ones included) and the time
+// they took.
+func loopMaintenance(ctx context.Context, engine, task string, m Maintenance) (rounds, failed int, busy time.Duration) {
+	pause := time.Duration(GetEnvInt("BENCH_MAINTENANCE_PAUSE_MS", 0)) * time.Millisecond
+	timeout := time.Duration(GetEnvInt("BENCH_MAINTENANCE_ROUND_TIMEOUT_SEC", 600)) * time.Second
+	for ctx.Err() == nil {
+		rctx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		err := m(rctx)
+		dur := time.Since(start)
+		cancel()
+		rounds++
+		busy += dur
+		switch {
+		case err != nil && ctx.Err() != nil:
+			failed++
+			log.Printf("[%s] MAINTENANCE: task=%s round=%d cancelled at the end of the pass after %s", engine, task, rounds, dur.Truncate(time.Millisecond))
+		case err != nil:
+			failed++
+			log.Printf("[%s] MAINTENANCE: task=%s round=%d failed after %s: %v", engine, task, rounds, dur.Truncate(time.Millisecond), err)
+		default:
+			log.Printf("[%s] MAINTENANCE: task=%s round=%d done in %s", engine, task, rounds, dur.Truncate(time.Millisecond))
+		}
+		if pause > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(pause):
+			}
+		}
+	}
+	return rounds, failed, busy
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BenchEnvPath is the env file `csv generate` can record its recommended
+// bench users in besides the manifest (RLP_WRITE_BENCH_USERS); cmd/main.go
+// picks both up on startup. Unlike the manifest it is not per dataset.
+const BenchEnvPath = ".env.bench"
+
+// ManifestPath is the manifest of the dataset in RLP_DATA_DIR.
+func ManifestPath() string {
+	return filepath.Join(DataDir(), "manifest.json")
+}
+
+// DatasetManifest describes the CSVs of one `csv generate` run; its hash is
+// the id of the dataset in DatasetsDir.
+// BenchUsers maps env vars (BENCH_LOOKUPRES_MANAGE_USER, ...,
+// BENCH_VIRAL_RESOURCES) to user or resource ids in the dataset's id format.
+// The row counts are what plan-load estimates from.
+type DatasetManifest struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Seed        int64             `json:"seed"`
+	IDFormat    string            `json:"id_format"`
+	BenchUsers  map[string]string `json:"bench_users"`
+	DatasetRows
+}
+
+// WriteManifest writes m to ManifestPath.
+func WriteManifest(m DatasetManifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ManifestPath()), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(ManifestPath(), append(b, '\n'), 0o644)
+}
+
+// WriteBenchEnv writes the manifest's bench users to BenchEnvPath as
+// KEY=VALUE lines.
+func WriteBenchEnv(m DatasetManifest) error {
+	keys := make([]string, 0, len(m.BenchUsers))
+	for k := range m.BenchUsers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Written by `csv generate` at %s (seed=%d, id format=%s).\n", m.GeneratedAt.Format(time.RFC3339), m.Seed, m.IDFormat)
+	b.WriteString("# Loaded automatically by cmd/main.go after .env; regenerate instead of editing.\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", k, m.BenchUsers[k])
+	}
+	return os.WriteFile(BenchEnvPath, []byte(b.String()), 0o644)
+}
+
+// datasetSeed is the seed of the manifest ApplyManifestBenchUsers read, for
+// the CONFIG lines of LogScenarioConfig; 0 without a manifest.
+var datasetSeed int64
+
+// ApplyManifestBenchUsers sets the bench users recorded in ManifestPath for
+// every env var that is still unset, so a run without BENCH_LOOKUPRES_* in its
+// env files uses the users picked for the loaded dataset. A missing manifest
+// is not an error.
+func ApplyManifestBenchUsers() {
+	path := ManifestPath()
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("WARN: could not read %s: %v", path, err)
+		return
+	}
+	var m DatasetManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		log.Printf("WARN: could not parse %s: %v", path, err)
+		return
+	}
+	datasetSeed = m.Seed
+	for k, v := range m.BenchUsers {
+		if _, set := os.LookupEnv(k); !set {
+			_ = os.Setenv(k, v)
+		}
+	}
+}
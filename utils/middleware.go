@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"test-tls/authz"
+)
+
+// ResourceFetch reads the row (document) of one resource, the data fetch an
+// application handler runs once the request is authorized.
+type ResourceFetch func(ctx context.Context, resourceID string) error
+
+// RunMiddlewareOverhead runs middleware_overhead, the cost of authorization
+// as an application sees it: a handler that fetches a resource, with and
+// without a view check in front of it (the check of an authorization
+// middleware). Every iteration runs both variants on the same pair, fetch
+// alone then check + fetch, so caching and server drift hit them alike:
+//
+//	fetch        the resource fetch alone
+//	check_fetch  the view check, then the fetch when it is allowed
+//
+// The pairs are direct user grants from data/resource_acl.csv, drawn by
+// access frequency with BENCH_ACCESS_FREQUENCY_FILE (see workloadPairs), so
+// the checks are allowed and the fetch always runs. Each variant gets a DONE
+// line; check_fetch adds the overhead over fetch, absolute and as a
+// percentage of it, the number that tells whether inline checks are
+// affordable.
+//
+// Env vars:
+//
+//	BENCH_MIDDLEWARE_ITER   (default: 1000; 0 skips the scenario)
+//	BENCH_MIDDLEWARE_PAIRS  (default: 1000)
+func RunMiddlewareOverhead(engine string, backend PermissionBackend, fetch ResourceFetch) {
+	const scenario = "middleware_overhead"
+	iters := GetEnvInt("BENCH_MIDDLEWARE_ITER", 1000)
+	if iters <= 0 {
+		return
+	}
+	pairs := workloadPairs(authz.View, GetEnvInt("BENCH_MIDDLEWARE_PAIRS", 1000))
+	if len(pairs) == 0 {
+		log.Printf("[%s] [%s] skipped: no direct user grants in %s", engine, scenario, DataDir())
+		return
+	}
+	timeout := 2 * time.Second
+	log.Printf("[%s] [%s] iterations=%d pairs=%d", engine, scenario, iters, len(pairs))
+	LogScenarioConfig(engine, scenario, iters, timeout)
+
+	var fetchDurs, checkedDurs []time.Duration
+	fetchErrs, checkedErrs := NewErrorTally(), NewErrorTally()
+	denied := 0
+	for i := range iters {
+		p := pairs[i%len(pairs)]
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		start := time.Now()
+		err := fetch(ctx, p[0])
+		dur := time.Since(start)
+		cancel()
+		if err != nil {
+			class := fetchErrs.Record(err)
+			log.Printf("[%s] [%s] variant=fetch iter=%d resource=%s failed class=%s: %v", engine, scenario, i, p[0], class, err)
+		} else {
+			fetchDurs = append(fetchDurs, dur)
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		start = time.Now()
+		ok, err := backend.Check(ctx, p[0], p[1], authz.View)
+		checkDur := time.Since(start)
+		if err == nil && ok {
+			err = fetch(ctx, p[0])
+		}
+		dur = time.Since(start)
+		cancel()
+		AuditCheck(scenario, p[0], p[1], authz.View, ok, checkDur, err)
+		switch {
+		case err != nil:
+			class := checkedErrs.Record(err)
+			log.Printf("[%s] [%s] variant=check_fetch iter=%d resource=%s user=%s failed class=%s: %v", engine, scenario, i, p[0], p[1], class, err)
+		case !ok:
+			denied++
+		default:
+			checkedDurs = append(checkedDurs, dur)
+		}
+	}
+
+	base, checked := latencyStatsOf(fetchDurs), latencyStatsOf(checkedDurs)
+	log.Printf("[%s] [%s] variant=fetch DONE: iters=%d %s", engine, scenario, len(fetchDurs), base)
+	log.Printf("[%s] [%s] variant=check_fetch DONE: iters=%d %s overhead_avg=%s overhead_p50=%s overhead_p99=%s overhead_pct=%s denied=%d",
+		engine, scenario, len(checkedDurs), checked, checked.avg-base.avg, checked.p50-base.p50, checked.p99-base.p99, overheadPct(checked.avg, base.avg), denied)
+	log.Printf("[%s] [%s] variant=fetch ERRORS: %s", engine, scenario, fetchErrs.Summary(iters))
+	log.Printf("[%s] [%s] variant=check_fetch ERRORS: %s", engine, scenario, checkedErrs.Summary(iters))
+}
+
+// overheadPct is how much slower d is than base, as a percentage of base.
+func overheadPct(d, base time.Duration) string {
+	if base <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(d-base)/float64(base))
+}
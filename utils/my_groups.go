@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"test-tls/authz"
+)
+
+// RunMyGroups runs the "my groups" listing behind group pickers and profile
+// pages: every group a user belongs to, as list_my_groups_direct (direct
+// memberships and managed groups) and list_my_groups_effective (nested
+// groups included, managers counting as members). The backend must be an
+// authz.GroupLister; the scenarios are skipped otherwise.
+//
+// The users are BENCH_MY_GROUPS_USERS users of data/group_memberships.csv,
+// ranked by their number of direct groups and picked evenly from the lightest
+// to the heaviest, so every backend lists the same users; the iterations
+// cycle through them.
+//
+// Env vars:
+//
+//	BENCH_MY_GROUPS_ITER   (default: 100; 0 skips the scenarios)
+//	BENCH_MY_GROUPS_USERS  (default: 10)
+func RunMyGroups(engine string, backend PermissionBackend) {
+	iters := GetEnvInt("BENCH_MY_GROUPS_ITER", 100)
+	if iters <= 0 {
+		return
+	}
+	lister, ok := backend.(authz.GroupLister)
+	if !ok {
+		log.Printf("[%s] [list_my_groups_*] skipped: backend cannot list the groups of a user", engine)
+		return
+	}
+	users := myGroupsUsers(GetEnvInt("BENCH_MY_GROUPS_USERS", 10))
+	if len(users) == 0 {
+		log.Printf("[%s] [list_my_groups_*] skipped: no group memberships in %s", engine, DataDir())
+		return
+	}
+
+	for _, effective := range []bool{false, true} {
+		scenario := "list_my_groups_direct"
+		if effective {
+			scenario = "list_my_groups_effective"
+		}
+		timeout := 30 * time.Second
+		log.Printf("[%s] [%s] streaming mode. iterations=%d users=%d", engine, scenario, iters, len(users))
+		LogScenarioConfig(engine, scenario, iters, timeout)
+
+		var durs []time.Duration
+		groups := 0
+		errs := NewErrorTally()
+		for i := range iters {
+			user := users[i%len(users)]
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			n := 0
+			start := time.Now()
+			err := lister.LookupGroups(ctx, user, effective, func(string) { n++ })
+			dur := time.Since(start)
+			cancel()
+			AuditLookup(scenario, user, "member", n, dur, err)
+			if err != nil {
+				class := errs.Record(err)
+				log.Printf("[%s] [%s] iter=%d user=%s failed class=%s: %v", engine, scenario, i, user, class, err)
+				continue
+			}
+			durs = append(durs, dur)
+			groups = max(groups, n)
+			log.Printf("[%s] [%s] iter=%d user=%s groups=%d dur=%s", engine, scenario, i, user, n, dur)
+		}
+
+		log.Printf("[%s] [%s] DONE: iters=%d max_groups=%d %s", engine, scenario, len(durs), groups, LatencySummary(durs))
+		log.Printf("[%s] [%s] ERRORS: %s", engine, scenario, errs.Summary(iters))
+	}
+}
+
+// myGroupsUsers returns k users of data/group_memberships.csv spread evenly
+// over the users ranked by their number of direct groups, lightest first.
+func myGroupsUsers(k int) []string {
+	counts := map[string]int{}
+	eachDataRow("group_memberships.csv", func(rec []string) {
+		// group_id,user_id,role
+		counts[rec[1]]++
+	})
+	users := make([]string, 0, len(counts))
+	for id := range counts {
+		users = append(users, id)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if counts[users[i]] == counts[users[j]] {
+			return users[i] < users[j]
+		}
+		return counts[users[i]] < counts[users[j]]
+	})
+	if k <= 0 || k >= len(users) {
+		return users
+	}
+	picked := make([]string, k)
+	for i := range picked {
+		picked[i] = users[i*(len(users)-1)/max(k-1, 1)]
+	}
+	return picked
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"log"
+	"regexp"
+)
+
+// namespacePattern keeps RLP_NAMESPACE usable unquoted as a Postgres schema
+// and in ClickHouse database, Scylla keyspace, Mongo database and
+// Elasticsearch index names.
+var namespacePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+// Namespace returns RLP_NAMESPACE, the namespace create-schema, load-data and
+// benchmark work in so that several datasets or schema variants can share one
+// cluster: a schema of its own in Postgres and CockroachDB (search_path), and
+// a "<namespace>_" prefix on the ClickHouse and Mongo database, Scylla
+// keyspace and Elasticsearch index. "" (the default) keeps the plain names.
+func Namespace() string {
+	ns := GetEnvWithDefault("RLP_NAMESPACE", "")
+	if ns != "" && !namespacePattern.MatchString(ns) {
+		log.Fatalf("RLP_NAMESPACE=%q: want lowercase letters, digits and _, starting with a letter, at most 40", ns)
+	}
+	return ns
+}
+
+// Namespaced prefixes name with the namespace, if one is set.
+func Namespaced(name string) string {
+	if ns := Namespace(); ns != "" {
+		return ns + "_" + name
+	}
+	return name
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	reNDJSONSummary = regexp.MustCompile(`\[([a-z0-9_]+)\] \[([a-z0-9_]+)\] (DONE|ERRORS): (.*)$`)
+	reNDJSONField   = regexp.MustCompile(`(?:^|\s)([A-Za-z0-9_]+)=(\S+)`)
+)
+
+// ndjsonSink writes the result lines of --output=ndjson to stdout.
+type ndjsonSink struct {
+	mu      sync.Mutex
+	backend string
+	enc     *json.Encoder
+	pending map[string]map[string]any // DONE line of a scenario whose ERRORS line is still to come
+	partial []byte
+}
+
+// ndjson is the sink of the running benchmark, nil unless BENCH_OUTPUT=ndjson.
+var ndjson *ndjsonSink
+
+// StartNDJSONOutput streams benchmark results to stdout as NDJSON, for jq,
+// DuckDB or metrics ingestion without intermediate files; the log stays on
+// stderr. Every timed check and lookup (see AuditCheck) becomes a
+// {"type":"iteration",...} line with the fields of an audit record, and every
+// scenario a {"type":"scenario",...} line built from its DONE and ERRORS log
+// lines: numbers stay numbers, durations become <key>_ms. The returned func
+// writes the last scenario and restores the log output.
+func StartNDJSONOutput(backend string) func() {
+	s := &ndjsonSink{backend: backend, enc: json.NewEncoder(os.Stdout), pending: map[string]map[string]any{}}
+	ndjson = s
+	out := log.Writer()
+	log.SetOutput(io.MultiWriter(out, s))
+
+	return func() {
+		log.SetOutput(out)
+		ndjson = nil
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.flush()
+	}
+}
+
+// iteration writes rec as an iteration line.
+func (s *ndjsonSink) iteration(rec AuditRecord) {
+	line := struct {
+		Type string `json:"type"`
+		AuditRecord
+	}{"iteration", rec}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.encode(line)
+}
+
+// Write receives the log output and turns DONE and ERRORS lines into
+// scenario lines.
+func (s *ndjsonSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		s.observe(string(s.partial[:i]))
+		s.partial = s.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (s *ndjsonSink) observe(text string) {
+	m := reNDJSONSummary.FindStringSubmatch(text)
+	if m == nil || m[1] != s.backend {
+		return
+	}
+	scenario, kind, fields := m[2], m[3], m[4]
+	if kind == "DONE" {
+		// A scenario without an ERRORS line is written when the next one ends.
+		s.flush()
+		line := map[string]any{"type": "scenario", "backend": s.backend, "scenario": scenario, "ts": time.Now().UTC()}
+		ndjsonFields(line, fields)
+		s.pending[scenario] = line
+		return
+	}
+	line, ok := s.pending[scenario]
+	if !ok {
+		line = map[string]any{"type": "scenario", "backend": s.backend, "scenario": scenario, "ts": time.Now().UTC()}
+	}
+	errs := map[string]any{}
+	ndjsonFields(errs, fields)
+	line["errors"] = errs
+	delete(s.pending, scenario)
+	s.encode(line)
+}
+
+// flush writes the scenarios still waiting for their ERRORS line.
+func (s *ndjsonSink) flush() {
+	for scenario, line := range s.pending {
+		s.encode(line)
+		delete(s.pending, scenario)
+	}
+}
+
+func (s *ndjsonSink) encode(v any) {
+	if err := s.enc.Encode(v); err != nil {
+		log.SetOutput(os.Stderr)
+		log.Fatalf("[%s] ndjson output failed: %v", s.backend, err)
+	}
+}
+
+// ndjsonFields adds the key=value pairs of a summary line to line, as
+// numbers where they parse as one and as milliseconds for durations.
+func ndjsonFields(line map[string]any, fields string) {
+	for _, kv := range reNDJSONField.FindAllStringSubmatch(fields, -1) {
+		key, value := kv[1], kv[2]
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			line[key] = n
+		} else if f, err := strconv.ParseFloat(value, 64); err == nil {
+			line[key] = f
+		} else if d, err := time.ParseDuration(value); err == nil {
+			line[key+"_ms"] = float64(d) / float64(time.Millisecond)
+		} else {
+			line[key] = strings.Trim(value, ",")
+		}
+	}
+}
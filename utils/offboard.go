@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"test-tls/authz"
+	"test-tls/ids"
+)
+
+// UserOffboarder removes a user from a backend completely, as a GDPR-style
+// offboarding would.
+type UserOffboarder interface {
+	// Offboard deletes every org and group membership and every direct
+	// grant of userID (external id format), the user itself where the
+	// backend stores users, and brings the derived permission rows up to
+	// date. It returns the rows, documents or relationships deleted or
+	// updated where the backend reports them.
+	Offboard(ctx context.Context, userID string) (int, error)
+}
+
+// RunOffboarding benchmarks the removal of each user of BENCH_OFFBOARD_USERS
+// through o, and checks through checker that nothing is granted afterwards.
+// Per user it looks up the manage and view resources first (untimed), times
+// Offboard ("delete"), then times the manage and view lookups plus a check on
+// up to BENCH_OFFBOARD_CHECKS of the resources held before ("verify"). It
+// logs an "[offboard] variant=delete|verify query=offboard DONE:" line each:
+// rows of delete are the rows the last offboarding removed, rows of verify
+// the grants still answered after it, which is 0 on a consistent backend.
+//
+// The users stay deleted: reload the dataset to restore them.
+//
+// Env vars:
+//
+//	BENCH_OFFBOARD_USERS   (required, comma-separated user ids)
+//	BENCH_OFFBOARD_CHECKS  (default: 10)
+func RunOffboarding(engine string, o UserOffboarder, checker PermissionBackend) {
+	const scenario = "offboard"
+	users := offboardUsers(engine)
+	checks := GetEnvInt("BENCH_OFFBOARD_CHECKS", 10)
+	LogScenarioConfig(engine, scenario, len(users), 10*time.Minute, users...)
+	log.Printf("[%s] [%s] users=%d checks=%d", engine, scenario, len(users), checks)
+
+	var deletes, verifies []time.Duration
+	deleteErrs, verifyErrs := NewErrorTally(), NewErrorTally()
+	rows, stale := 0, 0
+	for i, user := range users {
+		held, err := offboardHeld(checker, user)
+		if err != nil {
+			log.Printf("[%s] [%s] iter=%d user=%s lookup before failed: %v", engine, scenario, i, user, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		start := time.Now()
+		n, err := o.Offboard(ctx, user)
+		dur := time.Since(start)
+		cancel()
+		if err != nil {
+			class := deleteErrs.Record(err)
+			log.Printf("[%s] [%s] variant=delete query=offboard iter=%d user=%s failed class=%s: %v", engine, scenario, i, user, class, err)
+			continue
+		}
+		deletes = append(deletes, dur)
+		rows = n
+
+		start = time.Now()
+		left, err := offboardVerify(checker, user, held, checks)
+		vdur := time.Since(start)
+		if err != nil {
+			class := verifyErrs.Record(err)
+			log.Printf("[%s] [%s] variant=verify query=offboard iter=%d user=%s failed class=%s: %v", engine, scenario, i, user, class, err)
+			continue
+		}
+		verifies = append(verifies, vdur)
+		stale += left
+		log.Printf("[%s] [%s] iter=%d user=%s rows=%d had_manage=%d had_view=%d stale=%d dur=%s verify=%s",
+			engine, scenario, i, user, n, len(held[authz.Manage]), len(held[authz.View]), left, dur, vdur)
+		if left > 0 {
+			log.Printf("[%s] [%s] iter=%d user=%s STALE: %d grants still answered after offboarding", engine, scenario, i, user, left)
+		}
+	}
+	log.Printf("[%s] [%s] variant=delete query=offboard DONE: iters=%d rows=%d %s", engine, scenario, len(deletes), rows, LatencySummary(deletes))
+	log.Printf("[%s] [%s] variant=delete query=offboard ERRORS: %s", engine, scenario, deleteErrs.Summary(len(users)))
+	log.Printf("[%s] [%s] variant=verify query=offboard DONE: iters=%d rows=%d %s", engine, scenario, len(verifies), stale, LatencySummary(verifies))
+	log.Printf("[%s] [%s] variant=verify query=offboard ERRORS: %s", engine, scenario, verifyErrs.Summary(len(deletes)))
+}
+
+// offboardUsers parses BENCH_OFFBOARD_USERS; offboarding is destructive, so
+// there is no default.
+func offboardUsers(engine string) []string {
+	spec := GetEnvWithDefault("BENCH_OFFBOARD_USERS", "")
+	if spec == "" {
+		log.Fatalf("[%s] offboard: set BENCH_OFFBOARD_USERS to the user ids to delete", engine)
+	}
+	var users []string
+	for s := range strings.SplitSeq(spec, ",") {
+		s = strings.TrimSpace(s)
+		if _, err := ids.Parse(ids.User, s); err != nil {
+			log.Fatalf("[%s] BENCH_OFFBOARD_USERS: %v", engine, err)
+		}
+		users = append(users, s)
+	}
+	return users
+}
+
+// offboardHeld returns the manage and view resources of user.
+func offboardHeld(checker PermissionBackend, user string) (map[string][]string, error) {
+	held := map[string][]string{}
+	for _, perm := range []string{authz.Manage, authz.View} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		err := checker.LookupResources(ctx, user, perm, func(res string) { held[perm] = append(held[perm], res) })
+		cancel()
+		if err != nil {
+			return held, err
+		}
+	}
+	return held, nil
+}
+
+// offboardVerify counts the grants still answered for user: the resources of
+// the manage and view lookups plus the granted checks on up to checks
+// resources of held per permission.
+func offboardVerify(checker PermissionBackend, user string, held map[string][]string, checks int) (int, error) {
+	stale := 0
+	for _, perm := range []string{authz.Manage, authz.View} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		err := checker.LookupResources(ctx, user, perm, func(string) { stale++ })
+		cancel()
+		if err != nil {
+			return stale, err
+		}
+		for _, res := range held[perm][:min(checks, len(held[perm]))] {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			ok, err := checker.Check(ctx, res, user, perm)
+			cancel()
+			if err != nil {
+				return stale, err
+			}
+			if ok {
+				stale++
+			}
+		}
+	}
+	return stale, nil
+}
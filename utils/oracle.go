@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// oracleSink re-checks the timed checks of a benchmark against the oracle,
+// a "<module> serve" at BENCH_ORACLE_URL, off the timed path.
+type oracleSink struct {
+	backend string
+	checker serveChecker
+	queue   chan AuditRecord
+	wg      sync.WaitGroup
+
+	mu        sync.Mutex
+	scenarios map[string]*oracleTally
+}
+
+// oracleTally is the verification of one scenario's checks.
+type oracleTally struct {
+	checks, agree, disagree, errors, skipped int
+}
+
+// oracle is the sink of the running benchmark, nil when verification is off.
+var oracle *oracleSink
+
+// oracleMismatchLogs caps the mismatches logged one by one per scenario.
+const oracleMismatchLogs = 10
+
+// StartOracle verifies every check the benchmark times (every AuditCheck)
+// against the oracle at BENCH_ORACLE_URL, usually Postgres behind `postgres
+// serve` on the same dataset, so each scenario's latency comes with its
+// correctness. Checks that failed on the backend are not verified.
+//
+// The oracle is asked by BENCH_ORACLE_WORKERS goroutines reading a queue of
+// BENCH_ORACLE_QUEUE checks, so its latency stays out of the timings; checks
+// that find the queue full are counted as skipped rather than waited on. The
+// returned func drains the queue and logs an ORACLE line per scenario with
+// the checks that agreed with the oracle, those that did not, and those the
+// oracle failed on. It is a no-op when BENCH_ORACLE_URL is unset.
+//
+// Env vars:
+//
+//	BENCH_ORACLE_URL          (default: "" = off)
+//	BENCH_ORACLE_WORKERS      (default: 4)
+//	BENCH_ORACLE_QUEUE        (default: 10000)
+//	BENCH_ORACLE_TIMEOUT_SEC  (default: 2)
+func StartOracle(backend string) func() {
+	url := os.Getenv("BENCH_ORACLE_URL")
+	if url == "" {
+		return func() {}
+	}
+	client := &http.Client{Timeout: time.Duration(GetEnvInt("BENCH_ORACLE_TIMEOUT_SEC", 2)) * time.Second}
+	s := &oracleSink{
+		backend:   backend,
+		checker:   serveChecker{client, url},
+		queue:     make(chan AuditRecord, GetEnvInt("BENCH_ORACLE_QUEUE", 10000)),
+		scenarios: map[string]*oracleTally{},
+	}
+	workers := GetEnvInt("BENCH_ORACLE_WORKERS", 4)
+	for range workers {
+		s.wg.Add(1)
+		go s.work()
+	}
+	oracle = s
+	log.Printf("[%s] verifying checks against the oracle at %s workers=%d", backend, url, workers)
+
+	return func() {
+		oracle = nil
+		close(s.queue)
+		s.wg.Wait()
+		names := make([]string, 0, len(s.scenarios))
+		for name := range s.scenarios {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			t := s.scenarios[name]
+			log.Printf("[%s] [%s] ORACLE: checks=%d agree=%d disagree=%d errors=%d skipped=%d accuracy=%.4f",
+				backend, name, t.checks, t.agree, t.disagree, t.errors, t.skipped, t.accuracy())
+		}
+	}
+}
+
+// accuracy is the share of the verified checks that agreed with the oracle,
+// 1 when none were verified.
+func (t *oracleTally) accuracy() float64 {
+	if t.agree+t.disagree == 0 {
+		return 1
+	}
+	return float64(t.agree) / float64(t.agree+t.disagree)
+}
+
+// verify queues rec for the oracle, counting it as skipped when the queue is
+// full.
+func (s *oracleSink) verify(rec AuditRecord) {
+	select {
+	case s.queue <- rec:
+	default:
+		s.mu.Lock()
+		s.tally(rec.Scenario).skipped++
+		s.mu.Unlock()
+	}
+}
+
+func (s *oracleSink) work() {
+	defer s.wg.Done()
+	for rec := range s.queue {
+		allowed, err := s.checker.check(rec.ResourceID, rec.UserID, rec.Permission)
+		s.mu.Lock()
+		t := s.tally(rec.Scenario)
+		t.checks++
+		switch {
+		case err != nil:
+			t.errors++
+			if t.errors <= oracleMismatchLogs {
+				log.Printf("[%s] [%s] oracle check failed resource=%s user=%s permission=%s: %v", s.backend, rec.Scenario, rec.ResourceID, rec.UserID, rec.Permission, err)
+			}
+		case allowed == rec.Allowed:
+			t.agree++
+		default:
+			t.disagree++
+			if t.disagree <= oracleMismatchLogs {
+				log.Printf("[%s] [%s] oracle mismatch resource=%s user=%s permission=%s allowed=%t oracle=%t", s.backend, rec.Scenario, rec.ResourceID, rec.UserID, rec.Permission, rec.Allowed, allowed)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// tally returns the tally of scenario, creating it; s.mu must be held.
+func (s *oracleSink) tally(scenario string) *oracleTally {
+	t := s.scenarios[scenario]
+	if t == nil {
+		t = &oracleTally{}
+		s.scenarios[scenario] = t
+	}
+	return t
+}
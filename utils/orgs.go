@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"test-tls/ids"
+)
+
+// OrgScope is the subset of organizations that load-data and the benchmarks
+// are restricted to (RLP_ORGS, or --orgs on the command line), together with
+// the users, groups and resources of those orgs as found in RLP_DATA_DIR. It
+// lets a dataset be loaded shard by shard across machines, or a single tenant
+// be benchmarked, without regenerating smaller CSVs.
+type OrgScope struct {
+	spec      string
+	orgs      map[int]struct{}
+	users     map[int]struct{}
+	groups    map[int]struct{}
+	resources map[int]struct{}
+}
+
+var (
+	orgScopeOnce sync.Once
+	orgScope     *OrgScope
+)
+
+// CurrentOrgScope returns the scope selected by RLP_ORGS (e.g. "1-8" or
+// "1,3,10-12"), built from RLP_DATA_DIR on first use. It returns nil when
+// RLP_ORGS is unset, meaning every org is in scope.
+func CurrentOrgScope() *OrgScope {
+	orgScopeOnce.Do(func() {
+		spec := strings.TrimSpace(os.Getenv("RLP_ORGS"))
+		if spec == "" {
+			return
+		}
+		orgs, err := ParseOrgRanges(spec)
+		if err != nil {
+			log.Fatalf("[orgs] RLP_ORGS: %v", err)
+		}
+		orgScope = buildOrgScope(DataDir(), spec, orgs)
+	})
+	return orgScope
+}
+
+// ParseOrgRanges parses a comma-separated list of org ids and inclusive
+// ranges, e.g. "1-8,12".
+func ParseOrgRanges(spec string) (map[int]struct{}, error) {
+	orgs := make(map[int]struct{})
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid org %q", part)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil || to < from {
+				return nil, fmt.Errorf("invalid org range %q", part)
+			}
+		}
+		for id := from; id <= to; id++ {
+			orgs[id] = struct{}{}
+		}
+	}
+	if len(orgs) == 0 {
+		return nil, fmt.Errorf("no orgs in %q", spec)
+	}
+	return orgs, nil
+}
+
+// buildOrgScope collects the groups and resources owned by orgs and the users
+// that are members of (or primarily belong to) one of them.
+func buildOrgScope(dir, spec string, orgs map[int]struct{}) *OrgScope {
+	s := &OrgScope{
+		spec:      spec,
+		orgs:      orgs,
+		users:     make(map[int]struct{}),
+		groups:    make(map[int]struct{}),
+		resources: make(map[int]struct{}),
+	}
+	collect := func(name string, idKind ids.Kind, idCol, orgCol int, into map[int]struct{}) {
+		scanCSV(filepath.Join(dir, name), func(rec []string) {
+			if org, err := ids.Parse(ids.Org, rec[orgCol]); err == nil && s.hasOrg(org) {
+				if id, err := ids.Parse(idKind, rec[idCol]); err == nil {
+					into[id] = struct{}{}
+				}
+			}
+		})
+	}
+	collect("groups.csv", ids.Group, 0, 1, s.groups)
+	collect("resources.csv", ids.Resource, 0, 1, s.resources)
+	collect("org_memberships.csv", ids.User, 1, 0, s.users)
+	collect("users.csv", ids.User, 0, 1, s.users)
+
+	log.Printf("[orgs] scope %s: orgs=%d users=%d groups=%d resources=%d", spec, len(s.orgs), len(s.users), len(s.groups), len(s.resources))
+	if len(s.resources) == 0 {
+		log.Fatalf("[orgs] scope %s matches no resources in %s", spec, dir)
+	}
+	return s
+}
+
+// scanCSV hands every data row of path (header skipped) to handle.
+func scanCSV(path string, handle func(rec []string)) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("[orgs] open %s: %v", path, err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	if _, err := r.Read(); err != nil {
+		log.Fatalf("[orgs] %s: read header: %v", path, err)
+	}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Fatalf("[orgs] %s: read failed: %v", path, err)
+		}
+		handle(rec)
+	}
+}
+
+func (s *OrgScope) hasOrg(id int) bool { _, ok := s.orgs[id]; return ok }
+
+// Orgs returns the org ids of the scope in ascending order, for backends that
+// filter by org server-side.
+func (s *OrgScope) Orgs() []int {
+	orgs := make([]int, 0, len(s.orgs))
+	for id := range s.orgs {
+		orgs = append(orgs, id)
+	}
+	sort.Ints(orgs)
+	return orgs
+}
+
+// Has reports whether an id of kind (an int or an id in any format) belongs to
+// the scope. A nil scope contains everything; unparseable ids are out.
+func (s *OrgScope) Has(kind ids.Kind, id any) bool {
+	if s == nil {
+		return true
+	}
+	n, err := ids.Parse(kind, fmt.Sprint(id))
+	if err != nil {
+		return false
+	}
+	var set map[int]struct{}
+	switch kind {
+	case ids.Org:
+		set = s.orgs
+	case ids.User:
+		set = s.users
+	case ids.Group:
+		set = s.groups
+	default:
+		set = s.resources
+	}
+	_, ok := set[n]
+	return ok
+}
+
+// keep reports whether a data row of the named ./data CSV belongs to the scope.
+func (s *OrgScope) keep(name string, rec []string) bool {
+	switch name {
+	case "organizations.csv", "org_memberships.csv":
+		return s.Has(ids.Org, rec[0])
+	case "users.csv":
+		return s.Has(ids.User, rec[0])
+	case "groups.csv", "resources.csv":
+		return s.Has(ids.Org, rec[1])
+	case "group_memberships.csv":
+		return s.Has(ids.Group, rec[0])
+	case "group_hierarchy.csv":
+		return s.Has(ids.Group, rec[0]) && s.Has(ids.Group, rec[1])
+	case "group_churn.csv":
+		return s.Has(ids.Group, rec[1]) && s.Has(ids.Group, rec[2])
+	case "resource_acl.csv", "resource_bans.csv":
+		return s.Has(ids.Resource, rec[0])
+	}
+	return true
+}
+
+// InOrgScope reports whether an id of kind belongs to the current org scope;
+// always true when no scope is set.
+func InOrgScope(kind ids.Kind, id any) bool {
+	return CurrentOrgScope().Has(kind, id)
+}
+
+// ScopeCSV wraps a reader over the ./data CSV name so that only the header and
+// the rows of the current org scope come through. Without a scope r is
+// returned as is. Callers read the result exactly like r.
+func ScopeCSV(name string, r *csv.Reader) *csv.Reader {
+	s := CurrentOrgScope()
+	if s == nil {
+		return r
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		w := csv.NewWriter(pw)
+		header := true
+		kept, skipped := 0, 0
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if !header && !s.keep(name, rec) {
+				skipped++
+				continue
+			}
+			if !header {
+				kept++
+			}
+			header = false
+			if err := w.Write(rec); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		w.Flush()
+		log.Printf("[orgs] %s: kept=%d skipped=%d (scope %s)", name, kept, skipped, s.spec)
+		pw.CloseWithError(w.Error())
+	}()
+	return csv.NewReader(pr)
+}
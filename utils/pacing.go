@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// PacedBackend lets at most a fixed number of calls of a PermissionBackend
+// run at once, queueing the others, for engines that serialize or reject
+// queries beyond a concurrency limit of their own (ClickHouse's
+// max_concurrent_queries). Concurrent runs (serve, worker) then measure the
+// backend at the load it accepts instead of its queue contention, and the
+// queued and rejected counts say how far off that load was.
+type PacedBackend struct {
+	engine  string
+	backend PermissionBackend
+	slots   chan struct{}
+	maxWait time.Duration
+
+	calls    atomic.Int64
+	queued   atomic.Int64 // waited for a slot
+	dropped  atomic.Int64 // no slot within maxWait
+	rejected atomic.Int64 // refused by the backend as overloaded (ErrClassShed)
+	waitNs   atomic.Int64
+}
+
+// NewPacedBackend paces backend to limit calls at once; a call waits up to
+// maxWait for a slot (0 = as long as its context allows) before it fails
+// without reaching the backend.
+func NewPacedBackend(engine string, backend PermissionBackend, limit int, maxWait time.Duration) *PacedBackend {
+	return &PacedBackend{engine: engine, backend: backend, slots: make(chan struct{}, limit), maxWait: maxWait}
+}
+
+func (p *PacedBackend) Check(ctx context.Context, resourceID, userID, permission string) (bool, error) {
+	if err := p.acquire(ctx); err != nil {
+		return false, err
+	}
+	defer func() { <-p.slots }()
+	ok, err := p.backend.Check(ctx, resourceID, userID, permission)
+	p.record(err)
+	return ok, err
+}
+
+func (p *PacedBackend) LookupResources(ctx context.Context, userID, permission string, handle func(resourceID string)) error {
+	if err := p.acquire(ctx); err != nil {
+		return err
+	}
+	defer func() { <-p.slots }()
+	err := p.backend.LookupResources(ctx, userID, permission, handle)
+	p.record(err)
+	return err
+}
+
+// acquire takes a slot, counting the calls that had to wait for one.
+func (p *PacedBackend) acquire(ctx context.Context) error {
+	p.calls.Add(1)
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	p.queued.Add(1)
+	start := time.Now()
+	defer func() { p.waitNs.Add(int64(time.Since(start))) }()
+
+	var timeout <-chan time.Time
+	if p.maxWait > 0 {
+		t := time.NewTimer(p.maxWait)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		p.dropped.Add(1)
+		return fmt.Errorf("%s query queue overloaded: no slot of %d free within %s", p.engine, cap(p.slots), p.maxWait)
+	case <-ctx.Done():
+		p.dropped.Add(1)
+		return ctx.Err()
+	}
+}
+
+func (p *PacedBackend) record(err error) {
+	if ClassifyError(err) == ErrClassShed {
+		p.rejected.Add(1)
+	}
+}
+
+// LogStats logs the PACING line: calls, how many waited for a slot and for
+// how long on average, how many gave up waiting (dropped) and how many the
+// backend still refused as overloaded (rejected).
+func (p *PacedBackend) LogStats() {
+	calls, queued := p.calls.Load(), p.queued.Load()
+	var waitAvg time.Duration
+	if queued > 0 {
+		waitAvg = time.Duration(p.waitNs.Load() / queued)
+	}
+	log.Printf("[%s] [pacing] PACING: limit=%d calls=%d queued=%d dropped=%d rejected=%d wait_avg=%s",
+		p.engine, cap(p.slots), calls, queued, p.dropped.Load(), p.rejected.Load(), waitAvg.Truncate(time.Microsecond))
+}
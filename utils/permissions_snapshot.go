@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// permissionSnapshotHeader is the header of the files export-permissions
+// writes and diff-permissions reads.
+var permissionSnapshotHeader = []string{"user_id", "permission", "resource_id"}
+
+// ExportPermissions writes a permission snapshot of backend: one
+// user_id,permission,resource_id row per resource each user can manage or
+// view, as LookupResources answers it, sorted by user and permission. Two
+// snapshots, of two backends or of one backend at two times, are compared
+// with diff-permissions. A failed lookup aborts the export, since a partial
+// snapshot would diff as removed grants.
+//
+// Env vars:
+//
+//	EXPORT_PERMISSIONS_OUT    (default: permissions_<engine>_<UTC timestamp>.csv)
+//	EXPORT_PERMISSIONS_USERS  (comma-separated user ids; default: every user of
+//	                           data/users.csv in the RLP_ORGS scope)
+func ExportPermissions(engine string, backend PermissionBackend) {
+	start := time.Now()
+	path := GetEnvWithDefault("EXPORT_PERMISSIONS_OUT",
+		fmt.Sprintf("permissions_%s_%s.csv", engine, start.UTC().Format("20060102T150405Z")))
+	users := snapshotUsers(engine)
+
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("[%s] [export] create %s: %v", engine, path, err)
+	}
+	w := csv.NewWriter(f)
+	if err := w.Write(permissionSnapshotHeader); err != nil {
+		log.Fatalf("[%s] [export] write %s: %v", engine, path, err)
+	}
+
+	rows := 0
+	for i, user := range users {
+		for _, permission := range []string{"manage", "view"} {
+			var resources []string
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			err := backend.LookupResources(ctx, user, permission, func(resourceID string) {
+				resources = append(resources, resourceID)
+			})
+			cancel()
+			if err != nil {
+				log.Fatalf("[%s] [export] lookup user=%s permission=%s: %v", engine, user, permission, err)
+			}
+			slices.Sort(resources)
+			for _, r := range slices.Compact(resources) {
+				if err := w.Write([]string{user, permission, r}); err != nil {
+					log.Fatalf("[%s] [export] write %s: %v", engine, path, err)
+				}
+				rows++
+			}
+		}
+		if (i+1)%100 == 0 {
+			log.Printf("[%s] [export] %d/%d users, %d rows", engine, i+1, len(users), rows)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatalf("[%s] [export] write %s: %v", engine, path, err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("[%s] [export] close %s: %v", engine, path, err)
+	}
+	log.Printf("[%s] [export] DONE: users=%d rows=%d out=%s at=%s elapsed=%s",
+		engine, len(users), rows, path, start.UTC().Format(time.RFC3339), time.Since(start).Truncate(time.Millisecond))
+}
+
+// snapshotUsers returns EXPORT_PERMISSIONS_USERS, else the user ids of
+// data/users.csv in the current org scope.
+func snapshotUsers(engine string) []string {
+	if v := os.Getenv("EXPORT_PERMISSIONS_USERS"); v != "" {
+		var users []string
+		for s := range strings.SplitSeq(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				users = append(users, s)
+			}
+		}
+		return users
+	}
+
+	path := filepath.Join(DataDir(), "users.csv")
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("[%s] [export] %s: %v (set EXPORT_PERMISSIONS_USERS to export without the dataset)", engine, path, err)
+	}
+	defer f.Close()
+	r := ScopeCSV("users.csv", csv.NewReader(f))
+	if _, err := r.Read(); err != nil {
+		log.Fatalf("[%s] [export] %s: read header: %v", engine, path, err)
+	}
+	var users []string
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return users
+		}
+		if err != nil {
+			log.Fatalf("[%s] [export] %s: read row: %v", engine, path, err)
+		}
+		// user_id,primary_org_id
+		users = append(users, rec[0])
+	}
+}
+
+// DiffPermissions compares the permission snapshots at paths before and after
+// and logs the grants only after has (added) and only before has (removed),
+// per permission, with up to DIFF_PERMISSIONS_SHOW examples of each (default
+// 20). With DIFF_PERMISSIONS_OUT it also writes every difference as a
+// change,user_id,permission,resource_id CSV. It reports whether the
+// snapshots hold the same grants.
+func DiffPermissions(before, after string) bool {
+	a, b := readPermissionSnapshot(before), readPermissionSnapshot(after)
+	added, removed := snapshotMinus(b, a), snapshotMinus(a, b)
+	log.Printf("[diff] %s: %d grants, %s: %d grants", before, len(a), after, len(b))
+
+	show := GetEnvInt("DIFF_PERMISSIONS_SHOW", 20)
+	for _, d := range []struct {
+		change string
+		rows   [][]string
+	}{{"added", added}, {"removed", removed}} {
+		per := map[string]int{}
+		for _, row := range d.rows {
+			per[row[1]]++
+		}
+		log.Printf("[diff] %s=%d manage=%d view=%d", d.change, len(d.rows), per["manage"], per["view"])
+		for _, row := range d.rows[:min(show, len(d.rows))] {
+			log.Printf("[diff]   %s user=%s permission=%s resource=%s", d.change, row[0], row[1], row[2])
+		}
+	}
+
+	if out := os.Getenv("DIFF_PERMISSIONS_OUT"); out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			log.Fatalf("[diff] create %s: %v", out, err)
+		}
+		w := csv.NewWriter(f)
+		w.Write(append([]string{"change"}, permissionSnapshotHeader...))
+		for _, row := range added {
+			w.Write(append([]string{"added"}, row...))
+		}
+		for _, row := range removed {
+			w.Write(append([]string{"removed"}, row...))
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			log.Fatalf("[diff] write %s: %v", out, err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatalf("[diff] close %s: %v", out, err)
+		}
+		log.Printf("[diff] wrote %d differences to %s", len(added)+len(removed), out)
+	}
+
+	if len(added)+len(removed) == 0 {
+		log.Printf("[diff] OK: snapshots hold the same %d grants", len(a))
+		return true
+	}
+	return false
+}
+
+// readPermissionSnapshot reads an export-permissions file as a set of
+// "user_id,permission,resource_id" keys.
+func readPermissionSnapshot(path string) map[string]bool {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("[diff] open %s: %v", path, err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err != nil {
+		log.Fatalf("[diff] %s: read header: %v", path, err)
+	}
+	if !slices.Equal(header, permissionSnapshotHeader) {
+		log.Fatalf("[diff] %s: header %v, want %v (an export-permissions file)", path, header, permissionSnapshotHeader)
+	}
+	grants := map[string]bool{}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return grants
+		}
+		if err != nil {
+			log.Fatalf("[diff] %s: %v", path, err)
+		}
+		grants[strings.Join(rec, ",")] = true
+	}
+}
+
+// snapshotMinus returns the grants of a that b lacks as sorted
+// user_id,permission,resource_id rows.
+func snapshotMinus(a, b map[string]bool) [][]string {
+	var keys []string
+	for k := range a {
+		if !b[k] {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	rows := make([][]string, len(keys))
+	for i, k := range keys {
+		rows[i] = strings.Split(k, ",")
+	}
+	return rows
+}
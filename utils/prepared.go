@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// PreparedDB runs queries through prepared statements: each query text is
+// prepared on first use and its *sql.Stmt reused after, which database/sql
+// prepares again on every new connection. With lib/pq, a query with
+// arguments is otherwise parsed and planned on every call (an unnamed
+// statement), which a server-side prepared statement skips.
+type PreparedDB struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+var (
+	preparedMu sync.Mutex
+	prepared   = map[*sql.DB]*PreparedDB{}
+)
+
+// PreparedStatements reports whether BENCH_PREPARED_STATEMENTS asks the SQL
+// backends to run their check and lookup queries as prepared statements
+// (server-side bound parameters on ClickHouse).
+func PreparedStatements() bool {
+	return GetEnvWithDefault("BENCH_PREPARED_STATEMENTS", "false") == "true"
+}
+
+// Prepared returns the PreparedDB of db, shared by every caller so each
+// statement is prepared once per connection.
+func Prepared(db *sql.DB) *PreparedDB {
+	preparedMu.Lock()
+	defer preparedMu.Unlock()
+	p := prepared[db]
+	if p == nil {
+		p = &PreparedDB{db: db, stmts: map[string]*sql.Stmt{}}
+		prepared[db] = p
+	}
+	return p
+}
+
+func (p *PreparedDB) stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.stmts[query]; ok {
+		return s, nil
+	}
+	s, err := p.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	p.stmts[query] = s
+	return s, nil
+}
+
+// QueryContext runs query as a prepared statement.
+func (p *PreparedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	s, err := p.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return s.QueryContext(ctx, args...)
+}
+
+// QueryRowContext runs query as a prepared statement. A query that fails to
+// prepare runs unprepared instead, so its error comes back from Scan.
+func (p *PreparedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	s, err := p.stmt(ctx, query)
+	if err != nil {
+		return p.db.QueryRowContext(ctx, query, args...)
+	}
+	return s.QueryRowContext(ctx, args...)
+}
@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"test-tls/ids"
+)
+
+// QuerySample is the parameters of one scenario --print-queries renders, as
+// external ids sampled from the dataset (see PrintQueries). Org and Group are
+// set for the scenarios that go through them, Relation for
+// check_time_bounded_direct_user, which checks the resource_acl relation of
+// the grant rather than a permission.
+type QuerySample struct {
+	Scenario   string
+	Permission string // "manage" or "view"
+	Relation   string
+	Resource   string // empty for lookups
+	User       string
+	Org, Group string
+}
+
+// QueryRenderer renders the requests a backend times for s: SQL or CQL with
+// the parameters inlined (see RenderSQL), a Mongo filter or Elasticsearch body
+// as JSON, a SpiceDB request as protojson. Requests a scenario sends one after
+// the other are rendered in order. An empty result means the backend does not
+// run the scenario.
+type QueryRenderer func(s QuerySample) string
+
+// PrintQueries is the --print-queries dry run of `<module> benchmark`: instead
+// of connecting it writes to stdout, per read scenario, the request render
+// would send for parameters sampled from the dataset under RLP_DATA_DIR
+// (honouring RLP_ORGS), so the queries can be reviewed or fed to EXPLAIN by
+// hand:
+//
+//	check_manage_direct_user        first direct manager grant of resource_acl.csv
+//	check_manage_org_admin          first org admin, a resource of that org
+//	check_view_via_group_member     first viewer group grant, a member of the group
+//	check_time_bounded_direct_user  first direct grant with a valid_until
+//	lookup_resources_manage_super   BENCH_LOOKUPRES_MANAGE_USER
+//	lookup_resources_view_regular   BENCH_LOOKUPRES_VIEW_USER
+//
+// The benchmark samples its own pairs from the backend; these are pairs of the
+// same shape, picked deterministically.
+func PrintQueries(engine string, render QueryRenderer) {
+	for _, s := range SampleQueries() {
+		if s.User == "" {
+			fmt.Printf("-- [%s] %s: skipped, no sample in the dataset\n\n", engine, s.Scenario)
+			continue
+		}
+		rendered := render(s)
+		if rendered == "" {
+			fmt.Printf("-- [%s] %s: not run by this backend\n\n", engine, s.Scenario)
+			continue
+		}
+		fmt.Printf("-- [%s] %s permission=%s resource=%s user=%s", engine, s.Scenario, s.Permission, s.Resource, s.User)
+		if s.Relation != "" {
+			fmt.Printf(" relation=%s", s.Relation)
+		}
+		if s.Org != "" {
+			fmt.Printf(" org=%s", s.Org)
+		}
+		if s.Group != "" {
+			fmt.Printf(" group=%s", s.Group)
+		}
+		fmt.Printf("\n%s\n\n", strings.TrimSpace(rendered))
+	}
+}
+
+// SampleQueries samples the parameters of the scenarios PrintQueries renders.
+// A scenario the dataset has no sample for comes back with an empty User.
+func SampleQueries() []QuerySample {
+	direct := QuerySample{Scenario: "check_manage_direct_user", Permission: "manage"}
+	if rec := firstDataRow("resource_acl.csv", func(rec []string) bool {
+		return rec[1] == "user" && (rec[3] == "manager_user" || rec[3] == "manager")
+	}); rec != nil {
+		direct.Resource, direct.User = rec[0], rec[2]
+	}
+
+	orgAdmin := QuerySample{Scenario: "check_manage_org_admin", Permission: "manage"}
+	if rec := firstDataRow("org_memberships.csv", func(rec []string) bool { return rec[2] == "admin" }); rec != nil {
+		if res := firstDataRow("resources.csv", func(r []string) bool { return r[1] == rec[0] }); res != nil {
+			orgAdmin.Resource, orgAdmin.User, orgAdmin.Org = res[0], rec[1], rec[0]
+		}
+	}
+
+	group := QuerySample{Scenario: "check_view_via_group_member", Permission: "view"}
+	if rec := firstDataRow("resource_acl.csv", func(rec []string) bool {
+		return rec[1] == "group" && (rec[3] == "viewer_group" || rec[3] == "viewer")
+	}); rec != nil {
+		if m := firstDataRow("group_memberships.csv", func(m []string) bool { return m[0] == rec[2] }); m != nil {
+			group.Resource, group.User, group.Group = rec[0], m[1], rec[2]
+		}
+	}
+
+	bounded := QuerySample{Scenario: "check_time_bounded_direct_user", Permission: "view"}
+	if rec := firstDataRow("resource_acl.csv", func(rec []string) bool {
+		return rec[1] == "user" && len(rec) > 5 && rec[5] != ""
+	}); rec != nil {
+		bounded.Resource, bounded.User, bounded.Relation = rec[0], rec[2], rec[3]
+		if strings.HasPrefix(rec[3], "manager") {
+			bounded.Permission = "manage"
+		}
+	}
+
+	return []QuerySample{
+		direct,
+		orgAdmin,
+		group,
+		bounded,
+		{Scenario: "lookup_resources_manage_super", Permission: "manage", User: GetEnvWithDefault("BENCH_LOOKUPRES_MANAGE_USER", "")},
+		{Scenario: "lookup_resources_view_regular", Permission: "view", User: GetEnvWithDefault("BENCH_LOOKUPRES_VIEW_USER", "")},
+	}
+}
+
+// firstDataRow returns the first row of the dataset file name (in the org
+// scope) that match accepts, or nil. Rows shorter than the header are
+// skipped.
+func firstDataRow(name string, match func(rec []string) bool) []string {
+	path := filepath.Join(DataDir(), name)
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("[print-queries] %s: %v", path, err)
+		return nil
+	}
+	defer f.Close()
+
+	r := ScopeCSV(name, csv.NewReader(f))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		log.Fatalf("[print-queries] %s: read header: %v", path, err)
+	}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Fatalf("[print-queries] %s: read row: %v", path, err)
+		}
+		if len(rec) >= len(header) && match(rec) {
+			return rec
+		}
+	}
+}
+
+// SampleInt is the int behind a sampled id of kind, for the backends with
+// integer columns. The dataset wrote the id, so it parses.
+func SampleInt(kind ids.Kind, s string) int {
+	n, err := ids.Parse(kind, s)
+	if err != nil {
+		log.Fatalf("[print-queries] %v", err)
+	}
+	return n
+}
+
+var sqlPlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+// RenderSQL inlines args into query as literals: $N placeholders (Postgres,
+// CockroachDB) by number, ? placeholders (ClickHouse, ScyllaDB) in order.
+// Strings are quoted, so the result can be pasted into a SQL shell.
+func RenderSQL(query string, args ...any) string {
+	if sqlPlaceholder.MatchString(query) {
+		return sqlPlaceholder.ReplaceAllStringFunc(query, func(p string) string {
+			n, _ := strconv.Atoi(p[1:])
+			if n < 1 || n > len(args) {
+				return p
+			}
+			return sqlLiteral(args[n-1])
+		})
+	}
+	var b strings.Builder
+	i := 0
+	for _, r := range query {
+		if r == '?' && i < len(args) {
+			b.WriteString(sqlLiteral(args[i]))
+			i++
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func sqlLiteral(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case int, int32, int64, uint32, uint64:
+		return fmt.Sprint(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return "'" + v.UTC().Format(time.RFC3339Nano) + "'"
+	}
+	return "'" + strings.ReplaceAll(fmt.Sprint(v), "'", "''") + "'"
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+// RelsFilter is the filter of one read_relationships_* scenario: a resource
+// type and relation, optionally narrowed to a subject type and subject id.
+type RelsFilter struct {
+	Scenario     string
+	ResourceType string
+	Relation     string
+	SubjectType  string // "" = any subject
+	SubjectID    string // "" = any id of SubjectType
+}
+
+// ReadRelsFilters returns the filters the read_relationships_* scenarios
+// stream resource#manager_user tuples with: by relation only, by subject type,
+// and by one subject (BENCH_LOOKUPRES_MANAGE_USER, left out when unset).
+func ReadRelsFilters() []RelsFilter {
+	filters := []RelsFilter{
+		{Scenario: "read_relationships_relation", ResourceType: "resource", Relation: "manager_user"},
+		{Scenario: "read_relationships_subject_type", ResourceType: "resource", Relation: "manager_user", SubjectType: "user"},
+	}
+	if userID := os.Getenv("BENCH_LOOKUPRES_MANAGE_USER"); userID != "" {
+		filters = append(filters, RelsFilter{Scenario: "read_relationships_subject_id", ResourceType: "resource", Relation: "manager_user", SubjectType: "user", SubjectID: userID})
+	}
+	return filters
+}
+
+// RunReadRelationships runs the read_relationships_* scenarios: bulk reads of
+// every tuple matching each of ReadRelsFilters, the reads sync tooling
+// depends on. read streams the tuples of one filter in pages of page tuples
+// (0 = one unbounded stream) and returns how many arrived. After ERRORS each
+// scenario logs a THROUGHPUT line with the tuples read, the time spent and the
+// tuples per second.
+//
+// Env vars:
+//
+//	BENCH_READ_RELS_ITER   (default: 0 = skip)
+//	BENCH_READ_RELS_PAGE   (default: 0 = one stream)
+func RunReadRelationships(engine string, read func(ctx context.Context, f RelsFilter, page int) (int, error)) {
+	iters := GetEnvInt("BENCH_READ_RELS_ITER", 0)
+	if iters <= 0 {
+		return
+	}
+	page := GetEnvInt("BENCH_READ_RELS_PAGE", 0)
+
+	for _, f := range ReadRelsFilters() {
+		log.Printf("[%s] [%s] streaming mode. iterations=%d page=%d", engine, f.Scenario, iters, page)
+		LogScenarioConfig(engine, f.Scenario, iters, 120*time.Second)
+		errs := NewErrorTally()
+		var total time.Duration
+		var rels int
+
+		for i := range iters {
+			ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+			start := time.Now()
+			n, err := read(ctx, f, page)
+			dur := time.Since(start)
+			cancel()
+			if err != nil {
+				class := errs.Record(err)
+				log.Printf("[%s] [%s] iter=%d read failed class=%s: %v", engine, f.Scenario, i, class, err)
+				continue
+			}
+			total += dur
+			rels += n
+			log.Printf("[%s] [%s] iter=%d rels=%d dur=%s", engine, f.Scenario, i, n, dur)
+		}
+
+		rate := 0.0
+		if total > 0 {
+			rate = float64(rels) / total.Seconds()
+		}
+		log.Printf("[%s] [%s] DONE: iters=%d", engine, f.Scenario, iters)
+		log.Printf("[%s] [%s] ERRORS: %s", engine, f.Scenario, errs.Summary(iters))
+		log.Printf("[%s] [%s] THROUGHPUT: rels=%d total=%s rels_per_sec=%.0f", engine, f.Scenario, rels, total.Truncate(time.Millisecond), rate)
+	}
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// replayStats accumulates the replay results of one recorded scenario.
+type replayStats struct {
+	records    int
+	mismatches int
+	durations  []time.Duration
+	errs       *ErrorTally
+}
+
+// ReplayAudit re-executes the checks and lookups of an audit file (see
+// StartAudit) against backend, in the recorded order, and reports per-scenario
+// latency plus how many answers differ from the recording. A check mismatches
+// when allowed differs, a lookup when the resource count differs; records that
+// failed on either side are counted as errors instead.
+//
+// Env vars:
+//
+//	REPLAY_AUDIT_FILE          (required) <backend>-<ts>.ndjson.gz written by a benchmark
+//	REPLAY_PRESERVE_TIMING     (default: false) wait so each call starts at its recorded offset
+//	REPLAY_CHECK_TIMEOUT_SEC   (default: 2)
+//	REPLAY_LOOKUP_TIMEOUT_SEC  (default: 60)
+func ReplayAudit(engine string, backend PermissionBackend) {
+	path := os.Getenv("REPLAY_AUDIT_FILE")
+	if path == "" {
+		log.Fatalf("[%s] [replay] REPLAY_AUDIT_FILE is required", engine)
+	}
+	preserveTiming := GetEnvWithDefault("REPLAY_PRESERVE_TIMING", "false") == "true"
+	checkTimeout := time.Duration(GetEnvInt("REPLAY_CHECK_TIMEOUT_SEC", 2)) * time.Second
+	lookupTimeout := time.Duration(GetEnvInt("REPLAY_LOOKUP_TIMEOUT_SEC", 60)) * time.Second
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("[%s] [replay] open audit file %s: %v", engine, path, err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		log.Fatalf("[%s] [replay] %s: gzip: %v", engine, path, err)
+	}
+	defer zr.Close()
+
+	log.Printf("[%s] [replay] == Replaying %s (preserve_timing=%t) ==", engine, path, preserveTiming)
+
+	var order []string
+	stats := make(map[string]*replayStats)
+	var recordedStart time.Time
+	replayStart := time.Now()
+	dec := json.NewDecoder(zr)
+	for i := 0; ; i++ {
+		var rec AuditRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			log.Fatalf("[%s] [replay] %s: read failed: %v", engine, path, err)
+		}
+
+		if i == 0 {
+			recordedStart, replayStart = rec.TS, time.Now()
+		} else if preserveTiming {
+			if wait := time.Until(replayStart.Add(rec.TS.Sub(recordedStart))); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		st := stats[rec.Scenario]
+		if st == nil {
+			st = &replayStats{errs: NewErrorTally()}
+			stats[rec.Scenario] = st
+			order = append(order, rec.Scenario)
+		}
+
+		dur, mismatch, err := replayCall(backend, rec, checkTimeout, lookupTimeout)
+
+		st.records++
+		if err != nil {
+			class := st.errs.Record(err)
+			log.Printf("[%s] [replay] [%s] iter=%d %s failed class=%s: %v", engine, rec.Scenario, i, recordKind(rec), class, err)
+			continue
+		}
+		st.durations = append(st.durations, dur)
+		if mismatch {
+			st.mismatches++
+		}
+		if LogIteration(i, 100) {
+			log.Printf("[%s] [replay] [%s] iter=%d %s user=%s resource=%s dur=%s recorded=%s", engine, rec.Scenario, i, recordKind(rec), rec.UserID, rec.ResourceID, dur, time.Duration(rec.LatencyUS)*time.Microsecond)
+		}
+	}
+
+	for _, scenario := range order {
+		st := stats[scenario]
+		log.Printf("[%s] [replay] [%s] DONE: records=%d mismatches=%d %s", engine, scenario, st.records, st.mismatches, latencyStatsOf(st.durations))
+		log.Printf("[%s] [replay] [%s] ERRORS: %s", engine, scenario, st.errs.Summary(st.records))
+	}
+	log.Printf("[%s] [replay] == Replay DONE: scenarios=%d elapsed=%s ==", engine, len(order), time.Since(replayStart).Truncate(time.Millisecond))
+}
+
+// replayCall re-executes rec against backend and returns its duration and
+// whether the answer differs from the recorded one.
+func replayCall(backend PermissionBackend, rec AuditRecord, checkTimeout, lookupTimeout time.Duration) (time.Duration, bool, error) {
+	start := time.Now()
+	if rec.IsLookup() {
+		ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+		defer cancel()
+		count := 0
+		err := backend.LookupResources(ctx, rec.UserID, rec.Permission, func(string) { count++ })
+		return time.Since(start), err == nil && rec.Error == "" && count != rec.Count, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	allowed, err := backend.Check(ctx, rec.ResourceID, rec.UserID, rec.Permission)
+	return time.Since(start), err == nil && rec.Error == "" && allowed != rec.Allowed, err
+}
+
+func recordKind(rec AuditRecord) string {
+	if rec.IsLookup() {
+		return AuditLookupKind
+	}
+	return AuditCheckKind
+}
+
+// latencyStats are the avg and p50/p95/p99 of a set of successful calls.
+type latencyStats struct {
+	avg, p50, p95, p99 time.Duration
+}
+
+func latencyStatsOf(durations []time.Duration) latencyStats {
+	if len(durations) == 0 {
+		return latencyStats{}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	pct := func(p float64) time.Duration {
+		return durations[int(p*float64(len(durations)-1))]
+	}
+	return latencyStats{avg: total / time.Duration(len(durations)), p50: pct(0.50), p95: pct(0.95), p99: pct(0.99)}
+}
+
+func (s latencyStats) String() string {
+	return fmt.Sprintf("avg=%s p50=%s p95=%s p99=%s", s.avg, s.p50, s.p95, s.p99)
+}
+
+// LatencySummary formats the avg and p50/p95/p99 of durations as
+// "avg=... p50=... p95=... p99=...", the form of the driver_overhead and
+// replay DONE lines. It sorts durations in place.
+func LatencySummary(durations []time.Duration) string {
+	return latencyStatsOf(durations).String()
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScenarioConfig is the effective configuration of one scenario run, logged
+// as one "CONFIG:" JSON line at the scenario's start so a result log is
+// self-describing and the run can be reproduced from it.
+type ScenarioConfig struct {
+	Engine      string            `json:"engine"`
+	Scenario    string            `json:"scenario"`
+	Iterations  int               `json:"iterations"`
+	Timeout     string            `json:"timeout"`
+	Users       []string          `json:"users,omitempty"`
+	Consistency string            `json:"consistency,omitempty"`
+	Concurrency int               `json:"concurrency"`
+	Seed        int64             `json:"seed,omitempty"`
+	GoMaxProcs  int               `json:"gomaxprocs"`
+	Env         map[string]string `json:"env"`
+}
+
+// configEnvPrefixes select the env vars echoed in a ScenarioConfig: every
+// setting that changes what a benchmark run does.
+var configEnvPrefixes = []string{"BENCH_", "RLP_", "SPICEDB_", "MONGO_", "SCYLLA_", "ES_", "LOOKUP_"}
+
+// configEnvSecrets are name fragments of env vars left out of the echo.
+var configEnvSecrets = []string{"PASSWORD", "TOKEN", "SECRET", "KEY"}
+
+var (
+	consistencyMu   sync.Mutex
+	readConsistency = map[string]string{} // engine -> last LogConsistency settings
+)
+
+// LogConsistency logs an engine's read consistency settings as its
+// "CONSISTENCY:" line and records them for the CONFIG lines that follow.
+func LogConsistency(engine, settings string) {
+	consistencyMu.Lock()
+	readConsistency[engine] = settings
+	consistencyMu.Unlock()
+	log.Printf("[%s] CONSISTENCY: %s", engine, settings)
+}
+
+// LogScenarioConfig logs the "CONFIG:" line of a scenario: its iterations,
+// per-request timeout (0 = none) and users, the engine's read consistency,
+// the dataset seed from the manifest, GOMAXPROCS and the benchmark env vars.
+// Scenarios issue one request at a time, so concurrency is always 1. With
+// BENCH_COST it also closes the cost accounting of the previous scenario.
+func LogScenarioConfig(engine, scenario string, iters int, timeout time.Duration, users ...string) {
+	nextCostScenario(engine, scenario)
+	nextSheddingScenario(engine, scenario)
+	nextIterLogScenario()
+	consistencyMu.Lock()
+	settings := readConsistency[engine]
+	consistencyMu.Unlock()
+
+	cfg := ScenarioConfig{
+		Engine:      engine,
+		Scenario:    scenario,
+		Iterations:  iters,
+		Timeout:     "none",
+		Users:       users,
+		Consistency: settings,
+		Concurrency: 1,
+		Seed:        datasetSeed,
+		GoMaxProcs:  runtime.GOMAXPROCS(0),
+		Env:         configEnv(),
+	}
+	if timeout > 0 {
+		cfg.Timeout = timeout.String()
+	}
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		log.Printf("[%s] [%s] CONFIG: marshal failed: %v", engine, scenario, err)
+		return
+	}
+	log.Printf("[%s] [%s] CONFIG: %s", engine, scenario, b)
+}
+
+// configEnv returns the env vars of configEnvPrefixes, without secrets.
+func configEnv() map[string]string {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		if !hasAnyPrefix(k, configEnvPrefixes) || containsAny(k, configEnvSecrets...) {
+			continue
+		}
+		env[k] = v
+	}
+	return env
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ScenarioSemantics is what a scenario's answers mean, independent of the
+// backend: which permission it asks for and which grants must allow it. Every
+// backend's Check and LookupResources for that permission must agree with it
+// on the fixture dataset (see VerifyFixture); a query that answers something
+// else measures a different question and its timings are not comparable.
+type ScenarioSemantics struct {
+	Scenario   string
+	Permission string // "manage" or "view"
+	Allows     string // the grants that must allow it
+}
+
+// Scenarios is the single definition of the benchmark scenarios' semantics,
+// plus the grant paths (check_manage_via_group_manager,
+// check_view_direct_user and the nested groups) the lookups rely on but no
+// check scenario samples on its own. Manage-implies-view is deliberately left
+// out: the SQL backends' direct queries do not model it, so no scenario
+// relies on it.
+var Scenarios = []ScenarioSemantics{
+	{"check_manage_direct_user", "manage", "a direct manager_user grant to the user"},
+	{"check_manage_org_admin", "manage", "an admin org membership of the user in the resource's org"},
+	{"check_manage_via_group_manager", "manage", "a manager_group grant to a group the user is a direct_manager of"},
+	{"check_manage_via_nested_group", "manage", "a manager_group grant to a group nesting, through manager_group, a group the user is a direct_manager of"},
+	{"check_view_direct_user", "view", "a direct viewer_user grant to the user"},
+	{"check_view_via_group_member", "view", "a viewer_group grant to a group the user is a direct_member or direct_manager of"},
+	{"check_view_via_nested_group", "view", "a viewer_group grant to a group nesting, through member_group, a group the user is a direct_member of"},
+	{"lookup_resources_manage_super", "manage", "the grants of the check_manage_* scenarios"},
+	{"lookup_resources_view_regular", "view", "the grants of the check_view_* scenarios"},
+}
+
+// scenarioSemantics returns the entry of Scenarios for scenario.
+func scenarioSemantics(scenario string) (ScenarioSemantics, error) {
+	for _, s := range Scenarios {
+		if s.Scenario == scenario {
+			return s, nil
+		}
+	}
+	names := make([]string, len(Scenarios))
+	for i, s := range Scenarios {
+		names[i] = s.Scenario
+	}
+	return ScenarioSemantics{}, fmt.Errorf("unknown scenario %q: want one of %s", scenario, strings.Join(names, ", "))
+}
@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"test-tls/authz"
+	"test-tls/ids"
+)
+
+// PermissionBackend is the engine's authz.Checker as used by the serve,
+// replay and lookup-mix harnesses.
+type PermissionBackend = authz.Checker
+
+// Serve exposes backend over HTTP so load-testing tools (k6, vegeta) and
+// non-Go clients can drive the same Check/Lookup workloads as the benchmarks.
+// It blocks until SIGINT/SIGTERM, then logs the request and error totals.
+//
+// Endpoints (query string or POST form):
+//
+//	/v1/check?resource_id=1&user_id=2&permission=manage -> {"allowed":true,"duration_ms":0.8}
+//	/v1/lookup?user_id=2&permission=view[&limit=100]    -> {"count":3,"resource_ids":["1","2","3"],"truncated":false,"duration_ms":12.1}
+//	/healthz                                            -> ok
+//
+// limit caps the returned ids, not count. Backend errors answer 504 for
+// timeouts and 502 otherwise, with {"error":"...","class":"..."}.
+//
+// Env vars:
+//
+//	SERVE_ADDR               (default: ":8080")
+//	SERVE_CHECK_TIMEOUT_SEC  (default: 2)
+//	SERVE_LOOKUP_TIMEOUT_SEC (default: 60)
+func Serve(engine string, backend PermissionBackend) {
+	s := &permissionServer{
+		engine:        engine,
+		backend:       backend,
+		checkTimeout:  time.Duration(GetEnvInt("SERVE_CHECK_TIMEOUT_SEC", 2)) * time.Second,
+		lookupTimeout: time.Duration(GetEnvInt("SERVE_LOOKUP_TIMEOUT_SEC", 60)) * time.Second,
+		errs:          NewErrorTally(),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/check", s.handleCheck)
+	mux.HandleFunc("/v1/lookup", s.handleLookup)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	addr := GetEnvWithDefault("SERVE_ADDR", ":8080")
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("[%s] [serve] listening on %s (check timeout=%s lookup timeout=%s)", engine, addr, s.checkTimeout, s.lookupTimeout)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("[%s] [serve] listen failed: %v", engine, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("[%s] [serve] DONE: checks=%d lookups=%d", engine, s.checks, s.lookups)
+	log.Printf("[%s] [serve] ERRORS: %s", engine, s.errs.Summary(s.checks+s.lookups))
+}
+
+type permissionServer struct {
+	engine        string
+	backend       PermissionBackend
+	checkTimeout  time.Duration
+	lookupTimeout time.Duration
+
+	mu      sync.Mutex
+	checks  int
+	lookups int
+	errs    *ErrorTally
+}
+
+func (s *permissionServer) handleCheck(w http.ResponseWriter, r *http.Request) {
+	resourceID, ok := idParam(w, r, "resource_id", ids.Resource)
+	if !ok {
+		return
+	}
+	userID, ok := idParam(w, r, "user_id", ids.User)
+	if !ok {
+		return
+	}
+	permission, ok := permissionParam(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.checkTimeout)
+	defer cancel()
+	start := time.Now()
+	allowed, err := s.backend.Check(ctx, resourceID, userID, permission)
+	dur := time.Since(start)
+	s.record(&s.checks, err)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"allowed":     allowed,
+		"duration_ms": durationMs(dur),
+	})
+}
+
+func (s *permissionServer) handleLookup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := idParam(w, r, "user_id", ids.User)
+	if !ok {
+		return
+	}
+	permission, ok := permissionParam(w, r)
+	if !ok {
+		return
+	}
+	limit := -1
+	if v := r.FormValue("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a non-negative integer"})
+			return
+		}
+		limit = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.lookupTimeout)
+	defer cancel()
+	start := time.Now()
+	count := 0
+	resourceIDs := []string{}
+	err := s.backend.LookupResources(ctx, userID, permission, func(resourceID string) {
+		count++
+		if limit < 0 || len(resourceIDs) < limit {
+			resourceIDs = append(resourceIDs, resourceID)
+		}
+	})
+	dur := time.Since(start)
+	s.record(&s.lookups, err)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"count":        count,
+		"resource_ids": resourceIDs,
+		"truncated":    len(resourceIDs) < count,
+		"duration_ms":  durationMs(dur),
+	})
+}
+
+func (s *permissionServer) record(counter *int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*counter++
+	if err != nil {
+		class := s.errs.Record(err)
+		log.Printf("[%s] [serve] request failed class=%s: %v", s.engine, class, err)
+	}
+}
+
+// idParam reads an id in any of the dataset's formats (see package ids) and
+// passes it on as given; rejecting anything else keeps ids safe to splice
+// into backend queries that cannot bind parameters.
+func idParam(w http.ResponseWriter, r *http.Request, name string, kind ids.Kind) (string, bool) {
+	v := r.FormValue(name)
+	if _, err := ids.Parse(kind, v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return "", false
+	}
+	return v, true
+}
+
+func permissionParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	switch p := r.FormValue("permission"); p {
+	case "manage", "view":
+		return p, true
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": `permission must be "manage" or "view"`})
+		return "", false
+	}
+}
+
+func writeBackendError(w http.ResponseWriter, err error) {
+	class := ClassifyError(err)
+	code := http.StatusBadGateway
+	if class == ErrClassTimeout {
+		code = http.StatusGatewayTimeout
+	}
+	writeJSON(w, code, map[string]string{"error": err.Error(), "class": class})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shedding is the timeline of the scenario running now: when the server
+// pushed back (ErrClassShed), in whole seconds since LogScenarioConfig
+// started the scenario.
+var shedding struct {
+	mu       sync.Mutex
+	engine   string
+	scenario string
+	start    time.Time
+	seconds  map[int]int
+}
+
+// NoteShedding puts a shedding event on the timeline of the current
+// scenario. ErrorTally.Record calls it for every ErrClassShed failure.
+func NoteShedding() {
+	shedding.mu.Lock()
+	defer shedding.mu.Unlock()
+	if shedding.scenario == "" {
+		return
+	}
+	shedding.seconds[int(time.Since(shedding.start).Seconds())]++
+}
+
+// nextSheddingScenario logs the timeline of the current scenario, if
+// any, and starts one for scenario. Called by LogScenarioConfig.
+func nextSheddingScenario(engine, scenario string) {
+	shedding.mu.Lock()
+	defer shedding.mu.Unlock()
+	logShedding()
+	shedding.engine, shedding.scenario = engine, scenario
+	shedding.start = time.Now()
+	shedding.seconds = map[int]int{}
+}
+
+// FlushShedding logs the timeline of the last scenario; dispatch defers it
+// around `benchmark`.
+func FlushShedding() {
+	shedding.mu.Lock()
+	defer shedding.mu.Unlock()
+	logShedding()
+	shedding.scenario = ""
+}
+
+// logShedding logs the "SHEDDING:" line of the current scenario when the
+// server pushed back during it: the event count, the first and last second
+// and the events per second, e.g.
+//
+//	[postgres] [check_manage_direct_user] SHEDDING: events=7 first=3s last=5s timeline=3s:2,4s:4,5s:1
+//
+// benchmark/parse_all.go reports it next to the latencies, so slow
+// percentiles caused by throttling can be told from slow queries.
+func logShedding() {
+	if shedding.scenario == "" || len(shedding.seconds) == 0 {
+		return
+	}
+	secs := make([]int, 0, len(shedding.seconds))
+	events := 0
+	for s, n := range shedding.seconds {
+		secs = append(secs, s)
+		events += n
+	}
+	slices.Sort(secs)
+	timeline := make([]string, len(secs))
+	for i, s := range secs {
+		timeline[i] = fmt.Sprintf("%ds:%d", s, shedding.seconds[s])
+	}
+	log.Printf("[%s] [%s] SHEDDING: events=%d first=%ds last=%ds timeline=%s",
+		shedding.engine, shedding.scenario, events, secs[0], secs[len(secs)-1], strings.Join(timeline, ","))
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"container/heap"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// slowestSink keeps the BENCH_SLOWEST_N slowest calls of every scenario.
+type slowestSink struct {
+	mu        sync.Mutex
+	backend   string
+	n         int
+	scenarios map[string]*slowHeap
+}
+
+// slowHeap is a min-heap of calls by latency, so its root is the fastest of
+// the slowest kept and the next to be replaced.
+type slowHeap []AuditRecord
+
+func (h slowHeap) Len() int           { return len(h) }
+func (h slowHeap) Less(i, j int) bool { return h[i].LatencyUS < h[j].LatencyUS }
+func (h slowHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *slowHeap) Push(x any)        { *h = append(*h, x.(AuditRecord)) }
+func (h *slowHeap) Pop() any {
+	old := *h
+	rec := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return rec
+}
+
+// slowest is the sink of the running benchmark, nil when BENCH_SLOWEST_N is 0.
+var slowest *slowestSink
+
+// StartSlowest keeps the BENCH_SLOWEST_N slowest checks and lookups of every
+// scenario (every AuditCheck and AuditLookup, failed calls included) with
+// their parameters, so the outliers an average hides can be replayed and
+// explained later. The returned func logs them as SLOW lines, slowest first:
+// latency, resource, user, permission, the result (allowed, denied, the
+// resources of a lookup or error:<class>) and when the call ran.
+//
+// Env vars:
+//
+//	BENCH_SLOWEST_N  (default: 10; 0 = off)
+func StartSlowest(backend string) func() {
+	n := GetEnvInt("BENCH_SLOWEST_N", 10)
+	if n <= 0 {
+		return func() {}
+	}
+	s := &slowestSink{backend: backend, n: n, scenarios: map[string]*slowHeap{}}
+	slowest = s
+
+	return func() {
+		slowest = nil
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, name := range slices.Sorted(maps.Keys(s.scenarios)) {
+			recs := slices.Clone(*s.scenarios[name])
+			slices.SortFunc(recs, func(a, b AuditRecord) int { return int(b.LatencyUS - a.LatencyUS) })
+			for i, rec := range recs {
+				resource := rec.ResourceID
+				if resource == "" {
+					resource = "-"
+				}
+				log.Printf("[%s] [%s] SLOW: rank=%d latency=%s kind=%s resource=%s user=%s permission=%s result=%s ts=%s",
+					backend, name, i+1, time.Duration(rec.LatencyUS)*time.Microsecond, rec.Kind, resource, rec.UserID, rec.Permission, slowResult(rec), rec.TS.Format(time.RFC3339Nano))
+			}
+		}
+	}
+}
+
+func (s *slowestSink) record(rec AuditRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.scenarios[rec.Scenario]
+	if h == nil {
+		h = &slowHeap{}
+		s.scenarios[rec.Scenario] = h
+	}
+	switch {
+	case h.Len() < s.n:
+		heap.Push(h, rec)
+	case rec.LatencyUS > (*h)[0].LatencyUS:
+		(*h)[0] = rec
+		heap.Fix(h, 0)
+	}
+}
+
+// slowResult is the result of rec as one token of a SLOW line.
+func slowResult(rec AuditRecord) string {
+	switch {
+	case rec.ErrorClass != "":
+		return "error:" + rec.ErrorClass
+	case rec.IsLookup():
+		return fmt.Sprint(rec.Count)
+	case rec.Allowed:
+		return "allowed"
+	}
+	return "denied"
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"log"
+	"sort"
+)
+
+type idCount struct {
+	id    int
+	count int
+}
+
+// SummarizeRelation logs, under the given [tag] prefix:
+// - total A nodes
+// - average B per A
+// - 3 A with fewest B
+// - 3 A with "typical" B (around median)
+// - 3 A with most B
+//
+// The CSV generator and every backend's `analyze stats` action share this
+// output so generated and loaded datasets can be diffed line by line.
+func SummarizeRelation(tag, name, aLabel, bLabel string, counts map[int]int) {
+	if len(counts) == 0 {
+		log.Printf("[%s] relation %s (%s -> %s): no data", tag, name, aLabel, bLabel)
+		return
+	}
+
+	pairs := make([]idCount, 0, len(counts))
+	total := 0
+	for id, c := range counts {
+		pairs = append(pairs, idCount{id: id, count: c})
+		total += c
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].count == pairs[j].count {
+			return pairs[i].id < pairs[j].id
+		}
+		return pairs[i].count < pairs[j].count
+	})
+
+	n := len(pairs)
+	avg := float64(total) / float64(n)
+
+	log.Printf("[%s] relation %s (%s -> %s): %d %s; avg %.2f %s per %s",
+		tag, name, aLabel, bLabel, n, aLabel, avg, bLabel, aLabel)
+
+	printSamples := func(label string, sample []idCount) {
+		log.Printf("[%s]   %s %s:", tag, label, aLabel)
+		for _, p := range sample {
+			log.Printf("[%s]     %s=%d => %d %s", tag, aLabel, p.id, p.count, bLabel)
+		}
+	}
+
+	// fewest
+	fewN := 3
+	if n < fewN {
+		fewN = n
+	}
+	printSamples("fewest", pairs[:fewN])
+
+	// typical (around median)
+	typ := make([]idCount, 0, 3)
+	mid := n / 2
+	for delta := -1; delta <= 1; delta++ {
+		i := mid + delta
+		if i >= 0 && i < n {
+			typ = append(typ, pairs[i])
+		}
+	}
+	printSamples("typical", typ)
+
+	// most
+	mostN := 3
+	if n < mostN {
+		mostN = n
+	}
+	printSamples("most", pairs[n-mostN:])
+}
+
+// RelationStats accumulates the relation fan-out maps printed by
+// SummarizeRelation, keyed the same way as the CSV generator.
+type RelationStats struct {
+	OrgToUsers         map[int]int
+	UserToOrgs         map[int]int
+	GroupToMembers     map[int]int
+	GroupToManagers    map[int]int
+	UserToGroups       map[int]int
+	GroupToChildGroups map[int]int
+	UserToResources    map[int]int
+	ResourceToUsers    map[int]int
+}
+
+// NewRelationStats returns a RelationStats with all maps allocated.
+func NewRelationStats() *RelationStats {
+	return &RelationStats{
+		OrgToUsers:         make(map[int]int),
+		UserToOrgs:         make(map[int]int),
+		GroupToMembers:     make(map[int]int),
+		GroupToManagers:    make(map[int]int),
+		UserToGroups:       make(map[int]int),
+		GroupToChildGroups: make(map[int]int),
+		UserToResources:    make(map[int]int),
+		ResourceToUsers:    make(map[int]int),
+	}
+}
+
+// Summarize prints every relation in the same order as `csv generate`.
+func (s *RelationStats) Summarize(tag string) {
+	SummarizeRelation(tag, "org->users", "org_id", "users", s.OrgToUsers)
+	SummarizeRelation(tag, "user->orgs", "user_id", "orgs", s.UserToOrgs)
+	SummarizeRelation(tag, "group->direct_members", "group_id", "direct_member users", s.GroupToMembers)
+	SummarizeRelation(tag, "group->direct_managers", "group_id", "direct_manager users", s.GroupToManagers)
+	SummarizeRelation(tag, "user->groups", "user_id", "groups", s.UserToGroups)
+	SummarizeRelation(tag, "group->child_groups", "parent_group_id", "child groups", s.GroupToChildGroups)
+	SummarizeRelation(tag, "user->resources", "user_id", "resources", s.UserToResources)
+	SummarizeRelation(tag, "resource->users", "resource_id", "users", s.ResourceToUsers)
+}
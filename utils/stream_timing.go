@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// StreamTiming records when the items of streamed lookups arrive, across the
+// iterations of a scenario: the time to the first result of each stream and
+// the gaps between consecutive items. Applications that only show the first
+// page wait for the former, not for the whole stream.
+//
+// Env vars:
+//
+//	BENCH_STREAM_TIMING        (default: true)
+//	BENCH_STREAM_GAP_BUCKETS   (gap histogram upper bounds; default: "10µs,100µs,1ms,10ms,100ms")
+type StreamTiming struct {
+	enabled bool
+	buckets []time.Duration
+	counts  []int // per bucket, plus one for gaps above the last bound
+	first   []time.Duration
+	gaps    []time.Duration
+
+	start, last time.Time
+	items       int
+}
+
+// NewStreamTiming returns a StreamTiming configured from the environment.
+func NewStreamTiming() *StreamTiming {
+	t := &StreamTiming{enabled: GetEnvWithDefault("BENCH_STREAM_TIMING", "true") == "true"}
+	for s := range strings.SplitSeq(GetEnvWithDefault("BENCH_STREAM_GAP_BUCKETS", "10µs,100µs,1ms,10ms,100ms"), ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil || (len(t.buckets) > 0 && d <= t.buckets[len(t.buckets)-1]) {
+			log.Fatalf("[stream] BENCH_STREAM_GAP_BUCKETS: want increasing durations, got %q", s)
+		}
+		t.buckets = append(t.buckets, d)
+	}
+	t.counts = make([]int, len(t.buckets)+1)
+	return t
+}
+
+// Start begins a stream sent at start.
+func (t *StreamTiming) Start(start time.Time) {
+	t.start, t.last, t.items = start, start, 0
+}
+
+// Item records the arrival of the next item of the current stream.
+func (t *StreamTiming) Item() {
+	if !t.enabled {
+		return
+	}
+	now := time.Now()
+	if t.items == 0 {
+		t.first = append(t.first, now.Sub(t.start))
+	} else {
+		gap := now.Sub(t.last)
+		t.gaps = append(t.gaps, gap)
+		i := 0
+		for i < len(t.buckets) && gap > t.buckets[i] {
+			i++
+		}
+		t.counts[i]++
+	}
+	t.last = now
+	t.items++
+}
+
+// First returns the time to the first item of the current stream, 0 when
+// none arrived or timing is off.
+func (t *StreamTiming) First() time.Duration {
+	if !t.enabled || t.items == 0 {
+		return 0
+	}
+	return t.first[len(t.first)-1]
+}
+
+// Log writes the STREAM line of scenario: the avg and p50/p95/p99 of the
+// times to first result and of the inter-item gaps over all streams, and the
+// gap histogram as "le_<bound>=count" fields plus "gt_<last bound>=count".
+func (t *StreamTiming) Log(engine, scenario string) {
+	if !t.enabled || len(t.first) == 0 {
+		return
+	}
+	first, gap := latencyStatsOf(t.first), latencyStatsOf(t.gaps)
+	hist := make([]string, 0, len(t.counts))
+	for i, n := range t.counts {
+		if i < len(t.buckets) {
+			hist = append(hist, fmt.Sprintf("le_%s=%d", t.buckets[i], n))
+		} else {
+			hist = append(hist, fmt.Sprintf("gt_%s=%d", t.buckets[len(t.buckets)-1], n))
+		}
+	}
+	log.Printf("[%s] [%s] STREAM: streams=%d first_avg=%s first_p50=%s first_p95=%s first_p99=%s gaps=%d gap_avg=%s gap_p50=%s gap_p95=%s gap_p99=%s %s",
+		engine, scenario, len(t.first), first.avg, first.p50, first.p95, first.p99,
+		len(t.gaps), gap.avg, gap.p50, gap.p95, gap.p99, strings.Join(hist, " "))
+}
@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MixUser is one user of a lookup user mix: Grants is the number of direct
+// user grants the user has in data/resource_acl.csv for the permission, and
+// Decile (0-9) where that count ranks among all users with a grant.
+type MixUser struct {
+	ID     string
+	Decile int
+	Grants int
+}
+
+// LookupUserMix samples k users for lookups of permission ("manage" or
+// "view"), stratified by their number of direct grants: the users are ranked
+// by grant count, split into deciles, and the picks are spread evenly over
+// the deciles (and within each decile), so a mix covers light through heavy
+// users. The mix is read from the CSVs, so every backend gets the same users
+// in the same order.
+func LookupUserMix(permission string, k int) []MixUser {
+	grants := directUserGrants(permission)
+	if len(grants) == 0 || k <= 0 {
+		return nil
+	}
+	users := make([]MixUser, 0, len(grants))
+	for id, n := range grants {
+		users = append(users, MixUser{ID: id, Grants: n})
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Grants == users[j].Grants {
+			return users[i].ID < users[j].ID
+		}
+		return users[i].Grants < users[j].Grants
+	})
+	for i := range users {
+		users[i].Decile = i * 10 / len(users)
+	}
+	if k >= len(users) {
+		return users
+	}
+
+	// picks[d] is how many users decile d contributes.
+	var picks [10]int
+	for i := 0; i < k; i++ {
+		picks[i%10]++
+	}
+	var mix []MixUser
+	for d := 0; d < 10; d++ {
+		lo, hi := d*len(users)/10, (d+1)*len(users)/10
+		for j := 0; j < picks[d] && hi > lo; j++ {
+			mix = append(mix, users[lo+(2*j+1)*(hi-lo)/(2*picks[d])])
+		}
+	}
+	return mix
+}
+
+// directUserGrants counts the direct user grants per user in
+// data/resource_acl.csv: manager grants for "manage", any grant for "view".
+func directUserGrants(permission string) map[string]int {
+	path := filepath.Join(DataDir(), "resource_acl.csv")
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("[mix] %s: %v", path, err)
+		return nil
+	}
+	defer f.Close()
+
+	r := ScopeCSV("resource_acl.csv", csv.NewReader(f))
+	r.FieldsPerRecord = -1
+	if _, err := r.Read(); err != nil {
+		log.Fatalf("[mix] %s: read header: %v", path, err)
+	}
+	grants := make(map[string]int)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatalf("[mix] %s: read row: %v", path, err)
+		}
+		// resource_id,subject_type,subject_id,relation[,valid_from,valid_until]
+		if len(rec) < 4 || rec[1] != "user" {
+			continue
+		}
+		if permission == "manage" && rec[3] != "manager_user" && rec[3] != "manager" {
+			continue
+		}
+		grants[rec[2]]++
+	}
+	return grants
+}
+
+// RunLookupMix runs the lookup_resources_manage_mix and
+// lookup_resources_view_mix scenarios: BENCH_LOOKUPRES_MIX_USERS users per
+// permission (see LookupUserMix), BENCH_LOOKUPRES_MIX_ITER lookups each. Next
+// to the usual per-iteration lines it logs a MIX line per user with the result
+// size and latency, which benchmark/parse_all.go groups into latency by
+// result-set size. Unset BENCH_LOOKUPRES_MIX_USERS (0) skips both scenarios.
+//
+// Env vars:
+//
+//	BENCH_LOOKUPRES_MIX_USERS   (default: 0 = off)
+//	BENCH_LOOKUPRES_MIX_ITER    (default: 3)
+func RunLookupMix(engine string, backend PermissionBackend) {
+	k := GetEnvInt("BENCH_LOOKUPRES_MIX_USERS", 0)
+	if k <= 0 {
+		return
+	}
+	iters := GetEnvInt("BENCH_LOOKUPRES_MIX_ITER", 3)
+	for _, permission := range []string{"manage", "view"} {
+		scenario := "lookup_resources_" + permission + "_mix"
+		users := LookupUserMix(permission, k)
+		if len(users) == 0 {
+			log.Printf("[%s] [%s] skipped: no users with %s grants in data/resource_acl.csv", engine, scenario, permission)
+			continue
+		}
+
+		log.Printf("[%s] [%s] iterations=%d user=mix:%d", engine, scenario, iters*len(users), len(users))
+		userIDs := make([]string, len(users))
+		for j, u := range users {
+			userIDs[j] = u.ID
+		}
+		runUserLookups(engine, scenario, permission, backend, userIDs, iters, func(j, count int, stats latencyStats) {
+			log.Printf("[%s] [%s] MIX: user=%s decile=%d grants=%d resources=%d %s",
+				engine, scenario, users[j].ID, users[j].Decile, users[j].Grants, count, stats)
+		})
+	}
+}
+
+// runUserLookups times iters lookups of permission for each of userIDs in
+// turn under scenario, logging the per-iteration, DONE and ERRORS lines. After
+// a user's lookups, done gets the user's index, the result size of its last
+// lookup and its latency stats, unless every lookup failed.
+func runUserLookups(engine, scenario, permission string, backend PermissionBackend, userIDs []string, iters int, done func(j, count int, stats latencyStats)) {
+	LogScenarioConfig(engine, scenario, iters*len(userIDs), 60*time.Second, userIDs...)
+	errs := NewErrorTally()
+	var total time.Duration
+	lastCount, ok, i := 0, 0, 0
+	for j, userID := range userIDs {
+		var durations []time.Duration
+		count := 0
+		for range iters {
+			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+			start := time.Now()
+			count = 0
+			err := backend.LookupResources(ctx, userID, permission, func(string) { count++ })
+			dur := time.Since(start)
+			cancel()
+			AuditLookup(scenario, userID, permission, count, dur, err)
+			if err != nil {
+				class := errs.Record(err)
+				log.Printf("[%s] [%s] iter=%d user=%s lookup failed class=%s: %v", engine, scenario, i, userID, class, err)
+				i++
+				continue
+			}
+			durations = append(durations, dur)
+			total += dur
+			ok++
+			log.Printf("[%s] [%s] iter=%d resources=%d duration=%s", engine, scenario, i, count, dur.Truncate(time.Millisecond))
+			i++
+		}
+		if len(durations) > 0 {
+			lastCount = count
+			done(j, count, latencyStatsOf(durations))
+		}
+	}
+
+	avg := time.Duration(0)
+	if ok > 0 {
+		avg = total / time.Duration(ok)
+	}
+	log.Printf("[%s] [%s] DONE: iters=%d lastCount=%d avg=%s total=%s", engine, scenario, i, lastCount, avg, total)
+	log.Printf("[%s] [%s] ERRORS: %s", engine, scenario, errs.Summary(i))
+}
+
+// PercentileUser is one user of BENCH_LOOKUPRES_*_PCT_USERS: the user at
+// Percentile (e.g. "p90") of the direct grant count distribution.
+type PercentileUser struct {
+	Percentile string
+	ID         string
+}
+
+// ParsePercentileUsers parses a BENCH_LOOKUPRES_*_PCT_USERS spec,
+// "p10:<user>,p50:<user>,...", as `csv generate` records it.
+func ParsePercentileUsers(spec string) ([]PercentileUser, error) {
+	var users []PercentileUser
+	for item := range strings.SplitSeq(spec, ",") {
+		pct, id, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok || !strings.HasPrefix(pct, "p") || id == "" {
+			return nil, fmt.Errorf("%q: want p<N>:<user id>", item)
+		}
+		if _, err := strconv.Atoi(pct[1:]); err != nil {
+			return nil, fmt.Errorf("%q: percentile %q: %v", item, pct, err)
+		}
+		users = append(users, PercentileUser{Percentile: pct, ID: id})
+	}
+	return users, nil
+}
+
+// RunPercentileLookups runs the lookup_resources_manage_pct and
+// lookup_resources_view_pct scenarios: BENCH_LOOKUPRES_PCT_ITER lookups for
+// each user of BENCH_LOOKUPRES_MANAGE_PCT_USERS and
+// BENCH_LOOKUPRES_VIEW_PCT_USERS, the users `csv generate` picks at the p10,
+// p50, p90 and p99 of the direct grant counts. Next to the usual
+// per-iteration lines it logs a PCT line per user with the result size and
+// latency, which benchmark/parse_all.go reports by percentile. A scenario
+// whose env var is unset is skipped.
+//
+// Env vars:
+//
+//	BENCH_LOOKUPRES_MANAGE_PCT_USERS  (default: "" = skip)
+//	BENCH_LOOKUPRES_VIEW_PCT_USERS    (default: "" = skip)
+//	BENCH_LOOKUPRES_PCT_ITER          (default: 3)
+func RunPercentileLookups(engine string, backend PermissionBackend) {
+	iters := GetEnvInt("BENCH_LOOKUPRES_PCT_ITER", 3)
+	for _, permission := range []string{"manage", "view"} {
+		scenario := "lookup_resources_" + permission + "_pct"
+		name := "BENCH_LOOKUPRES_" + strings.ToUpper(permission) + "_PCT_USERS"
+		spec := os.Getenv(name)
+		if spec == "" {
+			log.Printf("[%s] [%s] skipped: %s not set (csv generate records it)", engine, scenario, name)
+			continue
+		}
+		users, err := ParsePercentileUsers(spec)
+		if err != nil {
+			log.Fatalf("[%s] %s: %v", engine, name, err)
+		}
+
+		log.Printf("[%s] [%s] iterations=%d user=pct:%d", engine, scenario, iters*len(users), len(users))
+		userIDs := make([]string, len(users))
+		for j, u := range users {
+			userIDs[j] = u.ID
+		}
+		runUserLookups(engine, scenario, permission, backend, userIDs, iters, func(j, count int, stats latencyStats) {
+			log.Printf("[%s] [%s] PCT: user=%s percentile=%s resources=%d %s",
+				engine, scenario, users[j].ID, users[j].Percentile, count, stats)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RunViralFanIn runs the check_view_viral_direct_user and
+// lookup_resources_view_viral scenarios against the viral resources of
+// `csv generate` (RLP_VIRAL_RESOURCES): a few resources with direct viewer
+// grants to a large share of all users. They measure wide direct fan-in on
+// one resource, where the other scenarios measure fan-out through groups.
+//
+// The check scenario reads the viral grants from data/resource_acl.csv and
+// checks view for them round robin over the resources, so every backend
+// checks the same pairs. The lookup scenario looks up the resources of
+// BENCH_LOOKUPRES_VIRAL_USER, the user with the most viral grants. Both are
+// skipped while their env var is unset; `csv generate` records both in the
+// manifest.
+//
+// Env vars:
+//
+//	BENCH_VIRAL_RESOURCES        (comma-separated resource ids; default: "" = skip)
+//	BENCH_CHECK_VIRAL_ITER       (default: 1000)
+//	BENCH_LOOKUPRES_VIRAL_USER   (default: "" = skip)
+//	BENCH_LOOKUPRES_VIRAL_ITER   (default: 10)
+func RunViralFanIn(engine string, backend PermissionBackend) {
+	runViralChecks(engine, backend)
+	runViralLookup(engine, backend)
+}
+
+func runViralChecks(engine string, backend PermissionBackend) {
+	const scenario = "check_view_viral_direct_user"
+	spec := os.Getenv("BENCH_VIRAL_RESOURCES")
+	if spec == "" {
+		log.Printf("[%s] [%s] skipped: BENCH_VIRAL_RESOURCES not set (generate with RLP_VIRAL_RESOURCES)", engine, scenario)
+		return
+	}
+	iters := GetEnvInt("BENCH_CHECK_VIRAL_ITER", 1000)
+	log.Printf("[%s] [%s] streaming mode. iterations=%d resources=%s", engine, scenario, iters, spec)
+	LogScenarioConfig(engine, scenario, iters, 2*time.Second)
+
+	pairs := viralPairs(strings.Split(spec, ","), iters)
+	done, allowed := 0, 0
+	errs := NewErrorTally()
+	for _, p := range pairs {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		start := time.Now()
+		ok, err := backend.Check(ctx, p[0], p[1], "view")
+		dur := time.Since(start)
+		cancel()
+		AuditCheck(scenario, p[0], p[1], "view", ok, dur, err)
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[%s] [%s] iter=%d check failed class=%s: %v", engine, scenario, done, class, err)
+			done++
+			continue
+		}
+		if ok {
+			allowed++
+		}
+		if LogIteration(done, 100) {
+			log.Printf("[%s] [%s] iter=%d resource=%s user=%s allowed=%t dur=%s", engine, scenario, done, p[0], p[1], ok, dur)
+		}
+		done++
+	}
+	if done == 0 {
+		log.Printf("[%s] [%s] skipped: no viewer_user grants on %s in data/resource_acl.csv", engine, scenario, spec)
+	}
+	log.Printf("[%s] [%s] DONE: iters=%d allowed=%d denied=%d", engine, scenario, done, allowed, done-allowed-errs.Total())
+	log.Printf("[%s] [%s] ERRORS: %s", engine, scenario, errs.Summary(done))
+}
+
+// viralPairs returns up to n (resource, user) pairs with a direct viewer_user
+// grant on one of resources, taking one user per resource in turn.
+func viralPairs(resources []string, n int) [][2]string {
+	users := make(map[string][]string, len(resources))
+	for _, r := range resources {
+		users[strings.TrimSpace(r)] = nil
+	}
+
+	path := filepath.Join(DataDir(), "resource_acl.csv")
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("[viral] %s: %v", path, err)
+		return nil
+	}
+	defer f.Close()
+	r := ScopeCSV("resource_acl.csv", csv.NewReader(f))
+	r.FieldsPerRecord = -1
+	if _, err := r.Read(); err != nil {
+		log.Fatalf("[viral] %s: read header: %v", path, err)
+	}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatalf("[viral] %s: read row: %v", path, err)
+		}
+		// resource_id,subject_type,subject_id,relation[,valid_from,valid_until]
+		if len(rec) < 4 || rec[1] != "user" || rec[3] != "viewer_user" {
+			continue
+		}
+		if list, ok := users[rec[0]]; ok {
+			users[rec[0]] = append(list, rec[2])
+		}
+	}
+
+	var pairs [][2]string
+	for i := 0; len(pairs) < n; i++ {
+		added := false
+		for _, res := range resources {
+			res = strings.TrimSpace(res)
+			if list := users[res]; i < len(list) && len(pairs) < n {
+				pairs = append(pairs, [2]string{res, list[i]})
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return pairs
+}
+
+func runViralLookup(engine string, backend PermissionBackend) {
+	const scenario = "lookup_resources_view_viral"
+	userID := os.Getenv("BENCH_LOOKUPRES_VIRAL_USER")
+	if userID == "" {
+		log.Printf("[%s] [%s] skipped: no user specified", engine, scenario)
+		return
+	}
+	iters := GetEnvInt("BENCH_LOOKUPRES_VIRAL_ITER", 10)
+	log.Printf("[%s] [%s] iterations=%d user=%s", engine, scenario, iters, userID)
+	LogScenarioConfig(engine, scenario, iters, 60*time.Second, userID)
+
+	var total time.Duration
+	lastCount, ok := 0, 0
+	errs := NewErrorTally()
+	for i := range iters {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		start := time.Now()
+		count := 0
+		err := backend.LookupResources(ctx, userID, "view", func(string) { count++ })
+		dur := time.Since(start)
+		cancel()
+		AuditLookup(scenario, userID, "view", count, dur, err)
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[%s] [%s] iter=%d LookupResources failed class=%s: %v", engine, scenario, i, class, err)
+			continue
+		}
+		total += dur
+		lastCount = count
+		ok++
+		log.Printf("[%s] [%s] iter=%d resources=%d duration=%s", engine, scenario, i, count, dur.Truncate(time.Millisecond))
+	}
+
+	avg := time.Duration(0)
+	if ok > 0 {
+		avg = total / time.Duration(ok)
+	}
+	log.Printf("[%s] [%s] DONE: iters=%d lastCount=%d avg=%s total=%s", engine, scenario, iters, lastCount, avg, total)
+	log.Printf("[%s] [%s] ERRORS: %s", engine, scenario, errs.Summary(iters))
+}
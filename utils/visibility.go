@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// VisibilityTracker measures how long a write takes to show up in a change
+// stream (SpiceDB's Watch API): the delay from the write call returning to
+// the change arriving, per write key. The stream may deliver a change before
+// the write call returns; such changes count as early, with a delay of 0.
+// It is safe for one writer and one stream reader.
+//
+// Env vars:
+//
+//	BENCH_WATCH_DRAIN  (seconds Log waits for changes still in flight; default: 5)
+type VisibilityTracker struct {
+	mu      sync.Mutex
+	written map[string]time.Time // write returned, change not seen yet
+	seen    map[string]time.Time // change seen before its write returned
+	delays  []time.Duration
+	writes  int
+	early   int
+}
+
+// NewVisibilityTracker returns an empty tracker.
+func NewVisibilityTracker() *VisibilityTracker {
+	return &VisibilityTracker{written: map[string]time.Time{}, seen: map[string]time.Time{}}
+}
+
+// Written records that the write of key returned now.
+func (t *VisibilityTracker) Written(key string) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.writes++
+	if _, ok := t.seen[key]; ok {
+		delete(t.seen, key)
+		t.delays = append(t.delays, 0)
+		t.early++
+		return
+	}
+	t.written[key] = now
+}
+
+// Seen records that the change of key arrived now.
+func (t *VisibilityTracker) Seen(key string) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if at, ok := t.written[key]; ok {
+		delete(t.written, key)
+		t.delays = append(t.delays, now.Sub(at))
+		return
+	}
+	t.seen[key] = now
+}
+
+// Log waits up to BENCH_WATCH_DRAIN seconds for the changes of the writes so
+// far, then writes the VISIBILITY line of scenario: writes, changes seen,
+// writes whose change never arrived (missed), early changes and the avg and
+// p50/p95/p99 of the delays.
+func (t *VisibilityTracker) Log(engine, scenario string) {
+	deadline := time.Now().Add(time.Duration(GetEnvInt("BENCH_WATCH_DRAIN", 5)) * time.Second)
+	for {
+		t.mu.Lock()
+		pending := len(t.written)
+		t.mu.Unlock()
+		if pending == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	log.Printf("[%s] [%s] VISIBILITY: writes=%d seen=%d missed=%d early=%d %s",
+		engine, scenario, t.writes, len(t.delays), len(t.written), t.early, LatencySummary(t.delays))
+}
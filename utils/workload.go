@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// workloadOps are the operations a workload mixes, by name: a check of a
+// direct user grant, a full lookup, or a lookup stopped after the first
+// BENCH_WORKLOAD_PAGE resources (the first page of a scoped listing).
+var workloadOps = map[string]struct {
+	permission string
+	lookup     bool
+	page       bool
+}{
+	"check_view":         {"view", false, false},
+	"check_manage":       {"manage", false, false},
+	"lookup_view":        {"view", true, false},
+	"lookup_manage":      {"manage", true, false},
+	"lookup_view_page":   {"view", true, true},
+	"lookup_manage_page": {"manage", true, true},
+}
+
+// workloadShare is one operation of a workload with its share of the calls.
+type workloadShare struct {
+	op    string
+	share float64
+}
+
+// ParseWorkload parses a BENCH_WORKLOAD spec, "name:op=weight,op=weight,...",
+// into its name and operations, with the weights normalized to shares.
+func ParseWorkload(spec string) (string, []workloadShare, error) {
+	name, mix, ok := strings.Cut(spec, ":")
+	if !ok || !customNameRe.MatchString(name) {
+		return "", nil, fmt.Errorf("want name:op=weight,... with a name of [a-z0-9_], got %q", spec)
+	}
+	var ops []workloadShare
+	total := 0.0
+	for part := range strings.SplitSeq(mix, ",") {
+		op, w, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if _, known := workloadOps[op]; !ok || !known {
+			return "", nil, fmt.Errorf("unknown operation %q (want check_view, check_manage, lookup_view, lookup_manage, lookup_view_page or lookup_manage_page)", op)
+		}
+		if slices.ContainsFunc(ops, func(o workloadShare) bool { return o.op == op }) {
+			return "", nil, fmt.Errorf("operation %q given twice", op)
+		}
+		weight, err := strconv.ParseFloat(w, 64)
+		if err != nil || weight <= 0 {
+			return "", nil, fmt.Errorf("%s: weight must be a positive number, got %q", op, w)
+		}
+		ops = append(ops, workloadShare{op: op, share: weight})
+		total += weight
+	}
+	for i := range ops {
+		ops[i].share /= total
+	}
+	return name, ops, nil
+}
+
+// RunWorkload runs the blended workload of BENCH_WORKLOAD as scenario
+// workload_<name>: BENCH_WORKLOAD_ITER calls, each an operation drawn by its
+// share, so the backend serves the production mix at once instead of one
+// isolated scenario at a time. The draws come from BENCH_WORKLOAD_SEED, so
+// every backend gets the same sequence of calls.
+//
+// Checks take the direct user grants of data/resource_acl.csv (manager
+// grants for check_manage), cycling through the first BENCH_WORKLOAD_PAIRS,
+// or as many drawn by access frequency (BENCH_ACCESS_FREQUENCY_FILE); lookups cycle through the users of LookupUserMix, ten per permission,
+// light through heavy. It logs every 100th call as an iter line, then an OP
+// line per operation with its share, calls and latency, and a DONE line with
+// the blended latency of all calls.
+//
+// Env vars:
+//
+//	BENCH_WORKLOAD        (default: "" = skip) e.g. "prod:check_view=70,check_manage=20,lookup_view_page=8,lookup_view=2"
+//	BENCH_WORKLOAD_ITER   (default: 1000)
+//	BENCH_WORKLOAD_SEED   (default: 1)
+//	BENCH_WORKLOAD_PAGE   (default: 100) resources of a *_page lookup
+//	BENCH_WORKLOAD_PAIRS  (default: 1000)
+func RunWorkload(engine string, backend PermissionBackend) {
+	spec := GetEnvWithDefault("BENCH_WORKLOAD", "")
+	if spec == "" {
+		return
+	}
+	name, ops, err := ParseWorkload(spec)
+	if err != nil {
+		log.Fatalf("[%s] BENCH_WORKLOAD: %v", engine, err)
+	}
+	scenario := "workload_" + name
+	iters := GetEnvInt("BENCH_WORKLOAD_ITER", 1000)
+	page := GetEnvInt("BENCH_WORKLOAD_PAGE", 100)
+	npairs := GetEnvInt("BENCH_WORKLOAD_PAIRS", 1000)
+
+	pairs := map[string][][2]string{}
+	users := map[string][]string{}
+	for _, o := range ops {
+		def := workloadOps[o.op]
+		if def.lookup {
+			if users[def.permission] == nil {
+				for _, u := range LookupUserMix(def.permission, 10) {
+					users[def.permission] = append(users[def.permission], u.ID)
+				}
+			}
+			if len(users[def.permission]) == 0 {
+				log.Printf("[%s] [%s] skipped: no users with %s grants in %s for %s", engine, scenario, def.permission, DataDir(), o.op)
+				return
+			}
+			continue
+		}
+		if pairs[def.permission] == nil {
+			pairs[def.permission] = workloadPairs(def.permission, npairs)
+		}
+		if len(pairs[def.permission]) == 0 {
+			log.Printf("[%s] [%s] skipped: no direct %s grants in %s for %s", engine, scenario, def.permission, DataDir(), o.op)
+			return
+		}
+	}
+
+	mix := make([]string, len(ops))
+	for i, o := range ops {
+		mix[i] = fmt.Sprintf("%s=%.0f%%", o.op, 100*o.share)
+	}
+	log.Printf("[%s] [%s] streaming mode. iterations=%d mix=%s", engine, scenario, iters, strings.Join(mix, ","))
+	LogScenarioConfig(engine, scenario, iters, 60*time.Second)
+
+	rng := rand.New(rand.NewSource(int64(GetEnvInt("BENCH_WORKLOAD_SEED", 1))))
+	next := map[string]int{} // op -> calls, for cycling its parameters
+	durs := map[string][]time.Duration{}
+	var all []time.Duration
+	errs := NewErrorTally()
+	for i := range iters {
+		op := drawWorkloadOp(ops, rng.Float64())
+		def := workloadOps[op]
+		n := next[op]
+		next[op]++
+
+		var dur time.Duration
+		var err error
+		detail := ""
+		if def.lookup {
+			user := users[def.permission][n%len(users[def.permission])]
+			limit := 0
+			if def.page {
+				limit = page
+			}
+			var count int
+			count, dur, err = workloadLookup(backend, user, def.permission, limit)
+			detail = fmt.Sprintf("user=%s resources=%d", user, count)
+		} else {
+			p := pairs[def.permission][n%len(pairs[def.permission])]
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			start := time.Now()
+			var ok bool
+			ok, err = backend.Check(ctx, p[0], p[1], def.permission)
+			dur = time.Since(start)
+			cancel()
+			detail = fmt.Sprintf("resource=%s user=%s allowed=%t", p[0], p[1], ok)
+		}
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[%s] [%s] iter=%d op=%s failed class=%s: %v", engine, scenario, i, op, class, err)
+			continue
+		}
+		durs[op] = append(durs[op], dur)
+		all = append(all, dur)
+		if LogIteration(i, 100) {
+			log.Printf("[%s] [%s] iter=%d op=%s %s dur=%s", engine, scenario, i, op, detail, dur)
+		}
+	}
+
+	for _, o := range ops {
+		log.Printf("[%s] [%s] OP: op=%s share=%.3f calls=%d %s", engine, scenario, o.op, o.share, len(durs[o.op]), LatencySummary(durs[o.op]))
+	}
+	log.Printf("[%s] [%s] DONE: iters=%d %s", engine, scenario, len(all), LatencySummary(all))
+	log.Printf("[%s] [%s] ERRORS: %s", engine, scenario, errs.Summary(iters))
+}
+
+// drawWorkloadOp returns the op of ops that x (in [0, 1)) falls on when the
+// shares are laid end to end; the last takes any rounding remainder.
+func drawWorkloadOp(ops []workloadShare, x float64) string {
+	for _, o := range ops {
+		if x < o.share {
+			return o.op
+		}
+		x -= o.share
+	}
+	return ops[len(ops)-1].op
+}
+
+// workloadLookup runs a lookup of permission for user, stopped after limit
+// resources (0 = all), returning the resources seen and its duration.
+func workloadLookup(backend PermissionBackend, user, permission string, limit int) (int, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	pctx, stop := context.WithCancel(ctx)
+	defer stop()
+	count := 0
+	start := time.Now()
+	err := backend.LookupResources(pctx, user, permission, func(string) {
+		if count++; limit > 0 && count == limit {
+			stop()
+		}
+	})
+	dur := time.Since(start)
+	if err != nil && limit > 0 && count >= limit && ctx.Err() == nil && errors.Is(pctx.Err(), context.Canceled) {
+		err = nil // stopped after the page, not failed
+	}
+	return count, dur, err
+}
+
+// workloadPairs returns up to n (resource, user) pairs of direct user grants
+// in data/resource_acl.csv, in file order: manager grants for "manage", any
+// grant for "view". With BENCH_ACCESS_FREQUENCY_FILE it returns n pairs drawn
+// from all of them as often as their resources are accessed instead (see
+// accessFrequencyFromEnv), so hot resources come up again and again.
+func workloadPairs(permission string, n int) [][2]string {
+	freq := accessFrequencyFromEnv()
+	var pairs [][2]string
+	eachDataRow("resource_acl.csv", func(rec []string) {
+		// resource_id,subject_type,subject_id,relation[,valid_from,valid_until]
+		if (freq == nil && len(pairs) >= n) || rec[1] != "user" {
+			return
+		}
+		if permission == "manage" && rec[3] != "manager_user" && rec[3] != "manager" {
+			return
+		}
+		if freq != nil && freq.weight(rec[0]) == 0 {
+			return
+		}
+		pairs = append(pairs, [2]string{rec[0], rec[2]})
+	})
+	if freq != nil {
+		return freq.draw(pairs, n)
+	}
+	return pairs
+}
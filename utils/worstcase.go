@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// RunWorstCaseChecks runs check_view_worst_case: view checks on the
+// (resource, user) pairs whose only grant is the most expensive path of the
+// schema, where the other check scenarios sample whatever pairs come first.
+// A pair qualifies when the user has no direct grant on the resource and is
+// not in a group granted on it, but is a direct_member of a group nested
+// through member_group under a viewer_group grant, so every backend has to
+// resolve the nesting to answer. The deepest nesting comes first, then the
+// resources of the orgs with the most resources. A dataset without nested
+// groups falls back to the pairs granted only through one group.
+//
+// Backends that do not expand nested groups (SpiceDB schema 1, for example)
+// deny the nested pairs; the DONE line counts them as denied. Pairs are read
+// from the data/ CSVs, so every backend checks the same ones, cycling through
+// them when there are fewer than the iterations.
+//
+// Env vars:
+//
+//	BENCH_CHECK_WORST_ITER  (default: 1000; 0 = skip)
+func RunWorstCaseChecks(engine string, backend PermissionBackend) {
+	const scenario = "check_view_worst_case"
+	iters := GetEnvInt("BENCH_CHECK_WORST_ITER", 1000)
+	if iters <= 0 {
+		log.Printf("[%s] [%s] skipped: BENCH_CHECK_WORST_ITER=0", engine, scenario)
+		return
+	}
+	pairs := worstCasePairs(iters)
+	if len(pairs) == 0 {
+		log.Printf("[%s] [%s] skipped: no group-only viewer grants in %s", engine, scenario, DataDir())
+		return
+	}
+	log.Printf("[%s] [%s] streaming mode. iterations=%d pairs=%d depth=%d..%d", engine, scenario, iters, len(pairs), pairs[0].depth, pairs[len(pairs)-1].depth)
+	LogScenarioConfig(engine, scenario, iters, 2*time.Second)
+
+	done, allowed := 0, 0
+	errs := NewErrorTally()
+	for done < iters {
+		p := pairs[done%len(pairs)]
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		start := time.Now()
+		ok, err := backend.Check(ctx, p.resource, p.user, "view")
+		dur := time.Since(start)
+		cancel()
+		AuditCheck(scenario, p.resource, p.user, "view", ok, dur, err)
+		if err != nil {
+			class := errs.Record(err)
+			log.Printf("[%s] [%s] iter=%d check failed class=%s: %v", engine, scenario, done, class, err)
+			done++
+			continue
+		}
+		if ok {
+			allowed++
+		}
+		if LogIteration(done, 100) {
+			log.Printf("[%s] [%s] iter=%d resource=%s user=%s depth=%d allowed=%t dur=%s", engine, scenario, done, p.resource, p.user, p.depth, ok, dur)
+		}
+		done++
+	}
+	log.Printf("[%s] [%s] DONE: iters=%d allowed=%d denied=%d", engine, scenario, done, allowed, done-allowed-errs.Total())
+	log.Printf("[%s] [%s] ERRORS: %s", engine, scenario, errs.Summary(done))
+}
+
+// worstCasePair is a pair of check_view_worst_case: user views resource only
+// through depth member_group nestings below a viewer_group grant (0 = a
+// direct member of the granted group).
+type worstCasePair struct {
+	resource, user string
+	depth, orgSize int
+}
+
+// worstCasePairs returns up to n pairs of check_view_worst_case, worst first.
+func worstCasePairs(n int) []worstCasePair {
+	orgOf := map[string]string{}
+	orgSize := map[string]int{}
+	eachDataRow("resources.csv", func(rec []string) {
+		orgOf[rec[0]] = rec[1]
+		orgSize[rec[1]]++
+	})
+
+	// children nests groups through member_group; members are the direct
+	// members and managers of a group, which view through it without nesting.
+	children := map[string][]string{}
+	eachDataRow("group_hierarchy.csv", func(rec []string) {
+		if rec[2] == "member_group" {
+			children[rec[0]] = append(children[rec[0]], rec[1])
+		}
+	})
+	members := map[string][]string{}
+	eachDataRow("group_memberships.csv", func(rec []string) {
+		members[rec[0]] = append(members[rec[0]], rec[1])
+	})
+
+	// Every subject granted on a resource, in the order of resource_acl.csv.
+	type grants struct {
+		users, groups, viewerGroups []string
+	}
+	acl := map[string]*grants{}
+	var order []string
+	eachDataRow("resource_acl.csv", func(rec []string) {
+		g := acl[rec[0]]
+		if g == nil {
+			g = &grants{}
+			acl[rec[0]] = g
+			order = append(order, rec[0])
+		}
+		switch {
+		case rec[1] == "user":
+			g.users = append(g.users, rec[2])
+		case rec[3] == "viewer_group" || rec[3] == "viewer":
+			g.viewerGroups = append(g.viewerGroups, rec[2])
+			g.groups = append(g.groups, rec[2])
+		default:
+			g.groups = append(g.groups, rec[2])
+		}
+	})
+
+	byDepth := map[int][]worstCasePair{}
+	maxDepth := 0
+	for _, res := range order {
+		g := acl[res]
+		if len(g.viewerGroups) == 0 {
+			continue
+		}
+		// The shortest path of each user: -1 for a direct grant, 0 for a
+		// member of a granted group, else the member_group nesting depth
+		// below a viewer_group grant.
+		depth := map[string]int{}
+		for _, u := range g.users {
+			depth[u] = -1
+		}
+		for _, group := range g.groups {
+			for _, u := range members[group] {
+				if _, seen := depth[u]; !seen {
+					depth[u] = 0
+				}
+			}
+		}
+		seen := map[string]bool{}
+		level := g.viewerGroups
+		for d := 1; len(level) > 0; d++ {
+			var next []string
+			for _, parent := range level {
+				for _, child := range children[parent] {
+					if seen[child] {
+						continue
+					}
+					seen[child] = true
+					next = append(next, child)
+					for _, u := range members[child] {
+						if _, ok := depth[u]; !ok {
+							depth[u] = d
+						}
+					}
+				}
+			}
+			level = next
+		}
+		for _, group := range g.viewerGroups {
+			for _, u := range members[group] {
+				if depth[u] == 0 {
+					depth[u] = -1 // counted once below, not once per group
+					byDepth[0] = append(byDepth[0], worstCasePair{res, u, 0, orgSize[orgOf[res]]})
+				}
+			}
+		}
+		for u, d := range depth {
+			if d > 0 {
+				maxDepth = max(maxDepth, d)
+				byDepth[d] = append(byDepth[d], worstCasePair{res, u, d, orgSize[orgOf[res]]})
+			}
+		}
+	}
+
+	var pairs []worstCasePair
+	for d := maxDepth; d >= 0 && len(pairs) < n; d-- {
+		level := byDepth[d]
+		if d == 0 && len(pairs) > 0 {
+			break // group-only pairs are the fallback for datasets without nesting
+		}
+		slices.SortStableFunc(level, func(a, b worstCasePair) int {
+			return cmp.Or(cmp.Compare(b.orgSize, a.orgSize), cmp.Compare(a.resource, b.resource), cmp.Compare(a.user, b.user))
+		})
+		pairs = append(pairs, level[:min(n-len(pairs), len(level))]...)
+	}
+	return pairs
+}
+
+// eachDataRow calls fn with every row of the dataset file name (in the org
+// scope) that is at least as long as the header. A missing file is logged
+// and read as empty.
+func eachDataRow(name string, fn func(rec []string)) {
+	path := filepath.Join(DataDir(), name)
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("[worst-case] %s: %v", path, err)
+		return
+	}
+	defer f.Close()
+
+	r := ScopeCSV(name, csv.NewReader(f))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		log.Fatalf("[worst-case] %s: read header: %v", path, err)
+	}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Fatalf("[worst-case] %s: read row: %v", path, err)
+		}
+		if len(rec) >= len(header) {
+			fn(rec)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+)
+
+// ZedToken is the SpiceDB revision a write phase ended at: "load" for
+// load-data, or the name of a write scenario (write_grant, write_revoke,
+// org_admin_escalation). The SpiceDB backends keep the last one of every
+// phase between runs, so a later benchmark can read at_least_as_fresh as it
+// (SPICEDB_CONSISTENCY=zedtoken): the way an application pins its reads to
+// the token of its last write instead of asking for full consistency.
+type ZedToken struct {
+	Token string    `json:"token"`
+	At    time.Time `json:"at"`
+}
+
+// zedTokenFile is SPICEDB_ZEDTOKEN_FILE (default zedtokens.json), which holds
+// the ZedToken of every phase per engine.
+func zedTokenFile() string {
+	return GetEnvWithDefault("SPICEDB_ZEDTOKEN_FILE", "zedtokens.json")
+}
+
+func readZedTokens(path string) (map[string]map[string]ZedToken, error) {
+	tokens := map[string]map[string]ZedToken{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &tokens); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tokens, nil
+}
+
+// SaveZedToken records token as the revision engine's phase ended at. An
+// empty token, from a phase without a successful write, records nothing. A
+// file that cannot be written is only logged, as the phase itself succeeded.
+func SaveZedToken(engine, phase, token string) {
+	if token == "" {
+		return
+	}
+	path := zedTokenFile()
+	tokens, err := readZedTokens(path)
+	if err == nil {
+		if tokens[engine] == nil {
+			tokens[engine] = map[string]ZedToken{}
+		}
+		tokens[engine][phase] = ZedToken{Token: token, At: time.Now().UTC()}
+		b, _ := json.MarshalIndent(tokens, "", "  ")
+		err = os.WriteFile(path, append(b, '\n'), 0o644)
+	}
+	if err != nil {
+		log.Printf("[%s] WARN: save ZedToken of %s: %v", engine, phase, err)
+		return
+	}
+	log.Printf("[%s] ZEDTOKEN: saved phase=%s token=%s file=%s", engine, phase, token, path)
+}
+
+// LoadZedToken returns the ZedToken engine saved for phase, or for the phase
+// it saved last when phase is "", and the phase it belongs to.
+func LoadZedToken(engine, phase string) (string, ZedToken, error) {
+	path := zedTokenFile()
+	tokens, err := readZedTokens(path)
+	if err != nil {
+		return "", ZedToken{}, err
+	}
+	if phase != "" {
+		t, ok := tokens[engine][phase]
+		if !ok {
+			return "", ZedToken{}, fmt.Errorf("no ZedToken of phase %s for %s in %s", phase, engine, path)
+		}
+		return phase, t, nil
+	}
+	var latest ZedToken
+	for p, t := range tokens[engine] {
+		if t.At.After(latest.At) {
+			phase, latest = p, t
+		}
+	}
+	if phase == "" {
+		return "", ZedToken{}, fmt.Errorf("no ZedToken for %s in %s; run load-data or a write scenario first", engine, path)
+	}
+	return phase, latest, nil
+}